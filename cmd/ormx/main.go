@@ -0,0 +1,145 @@
+// Command ormx is the go-ormx command line tool.
+//
+//	ormx new [-module path] [-driver sqlite|postgres|mysql] [-ormx dir] <dir>
+//
+//	ormx gen -driver sqlite|postgres|mysql -dsn dsn [-out dir] [-package name] [-tables a,b] [-force]
+//
+// new generates a runnable example service in dir: models, repositories, a
+// configuration file, SQL migrations, health and metrics endpoints.
+//
+// gen generates a model per table of an existing database: a struct
+// embedding models.BaseModel when the table has its columns, a TableName
+// method, column name constants and a repository constructor.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/seasbee/go-ormx/pkg/codegen"
+	"github.com/seasbee/go-ormx/pkg/database"
+	"github.com/seasbee/go-ormx/pkg/scaffold"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command of args and returns the exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+
+	switch args[0] {
+	case "new":
+		return runNew(args[1:], stdout, stderr)
+	case "gen":
+		return runGen(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		usage(stdout)
+		return 0
+	}
+	fmt.Fprintf(stderr, "ormx: unknown command %q\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+// runNew generates an example service
+func runNew(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("new", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var opts scaffold.Options
+	flags.StringVar(&opts.Module, "module", "", "module path of the service (default the directory name)")
+	flags.StringVar(&opts.Driver, "driver", "sqlite", "database driver: sqlite, postgres or mysql")
+	flags.StringVar(&opts.ORMXPath, "ormx", "", "local go-ormx checkout to replace the module with")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: ormx new [-module path] [-driver sqlite|postgres|mysql] [-ormx dir] <dir>")
+		return 2
+	}
+
+	dir := flags.Arg(0)
+	files, err := scaffold.Generate(dir, opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "ormx: %v\n", err)
+		return 1
+	}
+	for _, file := range files {
+		fmt.Fprintf(stdout, "created %s\n", file)
+	}
+	fmt.Fprintf(stdout, "\nNext:\n\tcd %s\n\tgo mod tidy\n\tgo run .\n", dir)
+	return 0
+}
+
+// runGen generates the models of the tables of a database
+func runGen(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("gen", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var driver, dsn, out, tables string
+	var opts codegen.Options
+	flags.StringVar(&driver, "driver", "", "database driver: sqlite, postgres or mysql")
+	flags.StringVar(&dsn, "dsn", "", "data source name of the database")
+	flags.StringVar(&out, "out", "models", "directory of the generated files")
+	flags.StringVar(&opts.Package, "package", "", "package of the generated files (default the directory name)")
+	flags.StringVar(&tables, "tables", "", "comma separated tables to generate (default every table)")
+	flags.BoolVar(&opts.Overwrite, "force", false, "overwrite existing files")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if driver == "" || dsn == "" || flags.NArg() != 0 {
+		fmt.Fprintln(stderr, "usage: ormx gen -driver sqlite|postgres|mysql -dsn dsn [-out dir] [-package name] [-tables a,b] [-force]")
+		return 2
+	}
+
+	db, err := database.OpenDSN(driver, dsn)
+	if err != nil {
+		fmt.Fprintf(stderr, "ormx: %v\n", err)
+		return 1
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	var names []string
+	for _, name := range strings.Split(tables, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	inspected, err := codegen.Inspect(context.Background(), db, names...)
+	if err != nil {
+		fmt.Fprintf(stderr, "ormx: %v\n", err)
+		return 1
+	}
+	if len(inspected) == 0 {
+		fmt.Fprintln(stderr, "ormx: no tables to generate")
+		return 1
+	}
+	files, err := codegen.Generate(out, inspected, opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "ormx: %v\n", err)
+		return 1
+	}
+	for _, file := range files {
+		fmt.Fprintf(stdout, "created %s\n", filepath.Join(out, file))
+	}
+	return 0
+}
+
+// usage writes the usage of the tool to w
+func usage(w io.Writer) {
+	fmt.Fprintln(w, `Usage: ormx <command> [arguments]
+
+Commands:
+	new	generate a runnable example service
+	gen	generate models from the tables of a database`)
+}
@@ -0,0 +1,294 @@
+// Package audit records the changes made to entities through repositories in
+// the audit_logs table: the entity before and after each write, the JSON
+// Patch between them, and the actor, tenant and trace ID taken from the
+// context. Entries are written on the connection of the change, so writes
+// made inside a transaction are audited atomically with it.
+//
+//	auditor, err := audit.NewAuditor(db, logger, nil)
+//	users := audit.Track(auditor, repository.NewBaseRepository[User](db, logger, nil))
+//	history, err := auditor.History(ctx, userID, 50, 0)
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/seasbee/go-ormx/pkg/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AuditLog represents the record of one entity change. Before and After hold
+// the JSON encoded entity, empty for creates and deletes respectively, and
+// Diff the RFC 6902 JSON Patch turning Before into After.
+type AuditLog struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	EntityType string    `gorm:"size:191;not null;index:idx_audit_logs_entity,priority:1" json:"entity_type"`
+	EntityID   uuid.UUID `gorm:"type:uuid;not null;index:idx_audit_logs_entity,priority:2" json:"entity_id"`
+	Operation  string    `gorm:"size:16;not null" json:"operation"`
+	Actor      string    `gorm:"size:191;index" json:"actor,omitempty"`
+	TenantID   string    `gorm:"size:191;index" json:"tenant_id,omitempty"`
+	TraceID    string    `gorm:"size:191" json:"trace_id,omitempty"`
+	Before     string    `gorm:"type:text" json:"before,omitempty"`
+	After      string    `gorm:"type:text" json:"after,omitempty"`
+	Diff       string    `gorm:"type:text" json:"diff,omitempty"`
+	CreatedAt  time.Time `gorm:"not null;index" json:"created_at"`
+}
+
+// TableName returns the table used to persist audit logs
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+// DecodeBefore unmarshals the entity before the change into dest
+func (l *AuditLog) DecodeBefore(dest interface{}) error {
+	return decode(l.Before, dest)
+}
+
+// DecodeAfter unmarshals the entity after the change into dest
+func (l *AuditLog) DecodeAfter(dest interface{}) error {
+	return decode(l.After, dest)
+}
+
+// Patch returns the JSON Patch operations of the change
+func (l *AuditLog) Patch() ([]models.PatchOperation, error) {
+	var patch []models.PatchOperation
+	if err := decode(l.Diff, &patch); err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
+
+// decode unmarshals an encoded field of an audit log, leaving dest untouched when it is empty
+func decode(data string, dest interface{}) error {
+	if data == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(data), dest); err != nil {
+		return fmt.Errorf("failed to decode audit log: %w", err)
+	}
+	return nil
+}
+
+// AuditConfig represents auditor configuration
+type AuditConfig struct {
+	// ActorKey, TenantKey and TraceKey are the context keys of the actor,
+	// tenant and trace ID, the keys read by the logger by default
+	ActorKey  interface{} `json:"-"`
+	TenantKey interface{} `json:"-"`
+	TraceKey  interface{} `json:"-"`
+	// IgnorePaths are JSON Pointers of fields left out of the diff, such as
+	// timestamps maintained by the models
+	IgnorePaths []string `json:"ignore_paths"`
+	// SkipUnchanged does not record updates whose diff is empty
+	SkipUnchanged bool `json:"skip_unchanged"`
+}
+
+// DefaultAuditConfig returns default auditor configuration
+func DefaultAuditConfig() *AuditConfig {
+	return &AuditConfig{
+		ActorKey:      repository.ActorContextKey,
+		TenantKey:     repository.TenantContextKey,
+		TraceKey:      "trace_id",
+		IgnorePaths:   []string{"/updated_at"},
+		SkipUnchanged: true,
+	}
+}
+
+// Auditor records entity changes and queries their history
+type Auditor struct {
+	repo   *repository.BaseRepository[AuditLog]
+	logger logging.Logger
+	config *AuditConfig
+}
+
+// NewAuditor creates an auditor, creating the audit_logs table if needed
+func NewAuditor(db *gorm.DB, logger logging.Logger, config *AuditConfig) (*Auditor, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+	if config == nil {
+		config = DefaultAuditConfig()
+	}
+
+	if err := db.AutoMigrate(&AuditLog{}); err != nil {
+		return nil, fmt.Errorf("failed to create audit log table: %w", err)
+	}
+
+	repoConfig := repository.DefaultRepositoryConfig()
+	repoConfig.EnableValidation = false
+
+	return &Auditor{
+		repo:   repository.NewBaseRepository[AuditLog](db, logger, repoConfig),
+		logger: logger,
+		config: config,
+	}, nil
+}
+
+// Repository returns the repository reading the audit_logs table
+func (a *Auditor) Repository() *repository.BaseRepository[AuditLog] {
+	return a.repo
+}
+
+// Track records the changes of the entities written through repo and
+// returns repo
+func Track[T any](a *Auditor, repo *repository.BaseRepository[T]) *repository.BaseRepository[T] {
+	return repo.OnChange(func(ctx context.Context, change *repository.EntityChange[T]) error {
+		entry, err := newEntry(ctx, a, change)
+		if err != nil || entry == nil {
+			return err
+		}
+		if err := change.DB.Create(entry).Error; err != nil {
+			return fmt.Errorf("failed to record audit log: %w", err)
+		}
+		return nil
+	})
+}
+
+// newEntry builds the audit log of change, nil when it is not recorded
+func newEntry[T any](ctx context.Context, a *Auditor, change *repository.EntityChange[T]) (*AuditLog, error) {
+	diff, err := models.Diff(change.Before, change.After)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s %s: %w", change.Table, change.ID, err)
+	}
+	patch := a.filter(diff.Patch())
+	if len(patch) == 0 && change.Operation == repository.ChangeUpdate && a.config.SkipUnchanged {
+		return nil, nil
+	}
+
+	entry := &AuditLog{
+		ID:         utils.GenerateUUIDv7(),
+		EntityType: change.Table,
+		EntityID:   change.ID,
+		Operation:  change.Operation,
+		Actor:      contextString(ctx, a.config.ActorKey),
+		TenantID:   contextString(ctx, a.config.TenantKey),
+		TraceID:    contextString(ctx, a.config.TraceKey),
+		CreatedAt:  time.Now(),
+	}
+	if entry.Before, err = encode(change.Before); err != nil {
+		return nil, err
+	}
+	if entry.After, err = encode(change.After); err != nil {
+		return nil, err
+	}
+	if entry.Diff, err = encode(&patch); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// OperationMaskedRead is the operation of the audit logs of masked reads
+const OperationMaskedRead = "masked_read"
+
+// RecordMaskedRead records a read whose columns were hidden from its role,
+// with the role and the hidden columns as After. It is meant as the OnMasked
+// hook of a repository.ColumnMasker:
+//
+//	masker := repository.NewColumnMasker(&repository.MaskingConfig{
+//		Rules:    rules,
+//		OnMasked: auditor.RecordMaskedRead,
+//	})
+func (a *Auditor) RecordMaskedRead(ctx context.Context, read *repository.MaskedRead) error {
+	if read == nil {
+		return nil
+	}
+	if read.DB == nil {
+		return fmt.Errorf("database connection is required")
+	}
+	data, err := json.Marshal(map[string]interface{}{"role": read.Role, "columns": read.Columns})
+	if err != nil {
+		return fmt.Errorf("failed to encode audit log: %w", err)
+	}
+
+	entry := &AuditLog{
+		ID:         utils.GenerateUUIDv7(),
+		EntityType: read.Table,
+		EntityID:   uuid.Nil,
+		Operation:  OperationMaskedRead,
+		Actor:      contextString(ctx, a.config.ActorKey),
+		TenantID:   contextString(ctx, a.config.TenantKey),
+		TraceID:    contextString(ctx, a.config.TraceKey),
+		After:      string(data),
+		CreatedAt:  time.Now(),
+	}
+	if err := read.DB.Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to record audit log: %w", err)
+	}
+	return nil
+}
+
+// filter drops the patch operations on ignored paths
+func (a *Auditor) filter(patch []models.PatchOperation) []models.PatchOperation {
+	filtered := patch[:0]
+	for _, op := range patch {
+		if !a.ignored(op.Path) {
+			filtered = append(filtered, op)
+		}
+	}
+	return filtered
+}
+
+// ignored reports whether path is, or is nested in, an ignored path
+func (a *Auditor) ignored(path string) bool {
+	for _, ignored := range a.config.IgnorePaths {
+		if path == ignored || strings.HasPrefix(path, ignored+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// History returns the changes of the entity with id in the order they were
+// made, paginated like the repository finders
+func (a *Auditor) History(ctx context.Context, entityID uuid.UUID, limit, offset int) ([]AuditLog, error) {
+	if entityID == uuid.Nil {
+		return nil, fmt.Errorf("entity ID cannot be nil")
+	}
+
+	logs, err := a.repo.Query().
+		Where(clause.Eq{Column: clause.Column{Name: "entity_id"}, Value: entityID}).
+		OrderBy("created_at").
+		OrderBy("id").
+		Limit(limit).
+		Offset(offset).
+		Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history of %s: %w", entityID, err)
+	}
+	return logs, nil
+}
+
+// encode returns the JSON form of value, empty for a nil entity
+func encode[T any](value *T) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode audit log: %w", err)
+	}
+	return string(data), nil
+}
+
+// contextString returns the value of key in ctx as a string
+func contextString(ctx context.Context, key interface{}) string {
+	if key == nil {
+		return ""
+	}
+	value := ctx.Value(key)
+	if value == nil {
+		return ""
+	}
+	if s, ok := value.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,418 @@
+// Package codegen generates go-ormx models from the tables of an existing
+// database, for teams adopting the package on a schema they already have.
+// Each table yields a Go file holding its model, embedding models.BaseModel
+// when the table has all its columns, a TableName method, the column names
+// as constants and a repository constructor:
+//
+//	tables, err := codegen.Inspect(ctx, db)
+//	files, err := codegen.Generate("models", tables, codegen.Options{Package: "models"})
+//
+// The generated files are a starting point: validation tags, indexes and
+// relations are left to add by hand. `ormx gen` is its command line front end.
+package codegen
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/jinzhu/inflection"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+//go:embed model.go.tmpl
+var modelTemplate string
+
+// Table describes a database table
+type Table struct {
+	Name    string   `json:"name"`
+	Columns []Column `json:"columns"`
+}
+
+// Column describes a column of a table
+type Column struct {
+	Name string `json:"name"`
+	// DatabaseType is the type name reported by the database, e.g. varchar
+	DatabaseType string `json:"database_type"`
+	// Type is the full column type when the database reports it, e.g. varchar(255)
+	Type       string `json:"type,omitempty"`
+	Length     int64  `json:"length,omitempty"`
+	Nullable   bool   `json:"nullable"`
+	PrimaryKey bool   `json:"primary_key"`
+}
+
+// Options configures generated models
+type Options struct {
+	// Package is the package of the generated files (default the base name
+	// of their directory)
+	Package string
+	// Overwrite replaces existing files; otherwise Generate refuses to
+	// overwrite them
+	Overwrite bool
+}
+
+// internalTables matches the tables maintained by go-ormx itself, skipped
+// when inspecting every table
+var internalTables = regexp.MustCompile(`^(ormx_|schema_migrations|audit_logs$|sqlite_)`)
+
+// Inspect reads the columns of tables, or of every table except the ones
+// maintained by go-ormx (migrations, jobs, locks, settings, outbox and audit
+// logs) when none are given
+func Inspect(ctx context.Context, db *gorm.DB, tables ...string) ([]Table, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+	migrator := db.WithContext(ctx).Migrator()
+
+	if len(tables) == 0 {
+		all, err := migrator.GetTables()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tables: %w", err)
+		}
+		for _, table := range all {
+			if !internalTables.MatchString(table) {
+				tables = append(tables, table)
+			}
+		}
+		sort.Strings(tables)
+	}
+
+	inspected := make([]Table, 0, len(tables))
+	for _, name := range tables {
+		if !migrator.HasTable(name) {
+			return nil, fmt.Errorf("table %s does not exist", name)
+		}
+		columnTypes, err := migrator.ColumnTypes(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read columns of %s: %w", name, err)
+		}
+
+		table := Table{Name: name, Columns: make([]Column, 0, len(columnTypes))}
+		for _, columnType := range columnTypes {
+			column := Column{Name: columnType.Name(), DatabaseType: strings.ToLower(columnType.DatabaseTypeName())}
+			column.Type, _ = columnType.ColumnType()
+			column.Length, _ = columnType.Length()
+			column.Nullable, _ = columnType.Nullable()
+			column.PrimaryKey, _ = columnType.PrimaryKey()
+			table.Columns = append(table.Columns, column)
+		}
+		if db.Dialector.Name() == "sqlite" {
+			if err := sqliteColumns(db.WithContext(ctx), &table); err != nil {
+				return nil, err
+			}
+		}
+		inspected = append(inspected, table)
+	}
+	return inspected, nil
+}
+
+// sqliteColumns reads the declared types and constraints of the columns of
+// table from PRAGMA table_info, as the SQLite migrator parses them from the
+// CREATE TABLE statement and misses the ones of statements spanning lines
+func sqliteColumns(db *gorm.DB, table *Table) error {
+	rows, err := db.Raw("SELECT name, type, \"notnull\", pk FROM pragma_table_info(?)", table.Name).Rows()
+	if err != nil {
+		return fmt.Errorf("failed to read columns of %s: %w", table.Name, err)
+	}
+	defer rows.Close()
+
+	declared := make(map[string]Column)
+	for rows.Next() {
+		var column Column
+		var notNull, pk int
+		if err := rows.Scan(&column.Name, &column.Type, &notNull, &pk); err != nil {
+			return fmt.Errorf("failed to read columns of %s: %w", table.Name, err)
+		}
+		column.Nullable, column.PrimaryKey = notNull == 0 && pk == 0, pk > 0
+		declared[column.Name] = column
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read columns of %s: %w", table.Name, err)
+	}
+
+	for i, column := range table.Columns {
+		pragma, ok := declared[column.Name]
+		if !ok {
+			continue
+		}
+		column.Type = strings.ToLower(pragma.Type)
+		column.DatabaseType = column.Type
+		if open := strings.IndexByte(column.Type, '('); open >= 0 {
+			column.DatabaseType = strings.TrimSpace(column.Type[:open])
+			fmt.Sscanf(column.Type[open+1:], "%d", &column.Length)
+		}
+		column.Nullable, column.PrimaryKey = pragma.Nullable, pragma.PrimaryKey
+		table.Columns[i] = column
+	}
+	return nil
+}
+
+// Generate writes a model file per table to dir, creating it when needed,
+// and returns the paths of the files written relative to dir
+func Generate(dir string, tables []Table, opts Options) ([]string, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("directory is required")
+	}
+	if opts.Package == "" {
+		opts.Package = packageName(filepath.Base(filepath.Clean(dir)))
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	files := make([]string, 0, len(tables))
+	for _, table := range tables {
+		file := table.Name + ".go"
+		content, err := Render(table, opts.Package)
+		if err != nil {
+			return nil, err
+		}
+		target := filepath.Join(dir, file)
+		if !opts.Overwrite {
+			if _, err := os.Stat(target); err == nil {
+				return nil, fmt.Errorf("%s already exists", target)
+			}
+		}
+		if err := os.WriteFile(target, content, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", file, err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// field is a field of a generated model
+type field struct {
+	Name string
+	Type string
+	Tag  string
+}
+
+// constant is a column name constant of a generated model
+type constant struct {
+	Name   string
+	Column string
+}
+
+// Render returns the formatted Go source of the model of table
+func Render(table Table, pkg string) ([]byte, error) {
+	if len(table.Columns) == 0 {
+		return nil, fmt.Errorf("table %s has no columns", table.Name)
+	}
+	model := goName(inflection.Singular(table.Name))
+
+	embedBase := hasBaseColumns(table)
+	imports := map[string]bool{
+		"github.com/seasbee/go-ormx/pkg/logging":    true,
+		"github.com/seasbee/go-ormx/pkg/repository": true,
+		"gorm.io/gorm": true,
+	}
+	if embedBase {
+		imports["github.com/seasbee/go-ormx/pkg/models"] = true
+	}
+
+	var fields []field
+	constants := make([]constant, 0, len(table.Columns))
+	for _, column := range table.Columns {
+		constants = append(constants, constant{Name: model + "Column" + goName(column.Name), Column: column.Name})
+		if embedBase && baseColumns()[column.Name] {
+			continue
+		}
+
+		goType, pkgPath := goType(column)
+		if pkgPath != "" {
+			imports[pkgPath] = true
+		}
+		fields = append(fields, field{Name: goName(column.Name), Type: goType, Tag: fieldTag(column)})
+	}
+
+	// Standard library imports come first, in their own group
+	var stdlib, paths []string
+	for path := range imports {
+		if strings.Contains(path, ".") {
+			paths = append(paths, path)
+		} else {
+			stdlib = append(stdlib, path)
+		}
+	}
+	sort.Strings(stdlib)
+	sort.Strings(paths)
+
+	tmpl, err := template.New("model").Parse(modelTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse model template: %w", err)
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, map[string]interface{}{
+		"Package":   pkg,
+		"Stdlib":    stdlib,
+		"Imports":   paths,
+		"Table":     table.Name,
+		"Model":     model,
+		"EmbedBase": embedBase,
+		"Fields":    fields,
+		"Constants": constants,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render model of %s: %w", table.Name, err)
+	}
+
+	content, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format model of %s: %w", table.Name, err)
+	}
+	return content, nil
+}
+
+var (
+	baseColumnsOnce sync.Once
+	baseColumnSet   map[string]bool
+)
+
+// baseColumns returns the columns of models.BaseModel
+func baseColumns() map[string]bool {
+	baseColumnsOnce.Do(func() {
+		baseColumnSet = map[string]bool{}
+		s, err := schema.Parse(&models.BaseModel{}, &sync.Map{}, schema.NamingStrategy{})
+		if err != nil {
+			return
+		}
+		for _, name := range s.DBNames {
+			baseColumnSet[name] = true
+		}
+	})
+	return baseColumnSet
+}
+
+// hasBaseColumns reports whether table has every column of models.BaseModel,
+// which its model can then embed
+func hasBaseColumns(table Table) bool {
+	columns := make(map[string]bool, len(table.Columns))
+	for _, column := range table.Columns {
+		columns[column.Name] = true
+	}
+	base := baseColumns()
+	if len(base) == 0 {
+		return false
+	}
+	for name := range base {
+		if !columns[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// goType returns the Go type of column and the package it needs, if any.
+// Nullable columns are pointers, except byte slices.
+func goType(column Column) (string, string) {
+	goType, pkgPath := baseType(column)
+	if column.Nullable && !column.PrimaryKey && goType != "[]byte" {
+		goType = "*" + goType
+	}
+	return goType, pkgPath
+}
+
+// baseType maps the database type of column to a Go type
+func baseType(column Column) (string, string) {
+	dbType := column.DatabaseType
+	switch {
+	case dbType == "uuid" || dbType == "uniqueidentifier":
+		return "uuid.UUID", "github.com/google/uuid"
+	case (dbType == "char" || dbType == "bpchar") && column.Length == 36 && strings.HasSuffix(column.Name, "id"):
+		return "uuid.UUID", "github.com/google/uuid"
+	case dbType == "bool" || dbType == "boolean" || dbType == "bit":
+		return "bool", ""
+	case dbType == "tinyint" && strings.HasPrefix(strings.ToLower(column.Type), "tinyint(1)"):
+		return "bool", ""
+	case integerType.MatchString(dbType):
+		if strings.Contains(dbType, "big") || strings.HasSuffix(dbType, "int8") {
+			return "int64", ""
+		}
+		return "int", ""
+	case dbType == "real" || dbType == "float4":
+		return "float32", ""
+	case strings.Contains(dbType, "float") || strings.Contains(dbType, "double") ||
+		strings.Contains(dbType, "decimal") || strings.Contains(dbType, "numeric"):
+		return "float64", ""
+	case strings.Contains(dbType, "time") || dbType == "date":
+		if dbType == "time" || strings.HasPrefix(dbType, "time ") {
+			return "string", ""
+		}
+		return "time.Time", "time"
+	case strings.Contains(dbType, "blob") || strings.Contains(dbType, "binary") || dbType == "bytea":
+		return "[]byte", ""
+	}
+	return "string", ""
+}
+
+// fieldTag returns the struct tag of the field of column
+func fieldTag(column Column) string {
+	options := []string{"column:" + column.Name}
+	// Types cut short by the driver, such as SQLite reporting decimal(10 for
+	// decimal(10,2), are left to the default type of the field
+	if column.Type != "" && strings.Count(column.Type, "(") == strings.Count(column.Type, ")") {
+		options = append(options, "type:"+column.Type)
+	}
+	if column.PrimaryKey {
+		options = append(options, "primaryKey")
+	} else if !column.Nullable {
+		options = append(options, "not null")
+	}
+
+	json := column.Name
+	if column.Nullable && !column.PrimaryKey {
+		json += ",omitempty"
+	}
+	return fmt.Sprintf("`gorm:%q json:%q`", strings.Join(options, ";"), json)
+}
+
+// initialisms are the words written in upper case in Go names
+var initialisms = map[string]bool{
+	"API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true, "HTML": true,
+	"HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true, "SKU": true,
+	"SQL": true, "TTL": true, "UI": true, "URI": true, "URL": true, "UUID": true, "XML": true,
+}
+
+var integerType = regexp.MustCompile(`^(unsigned )?(tiny|small|medium|big)?(int(eger|[248])?|serial[248]?)( unsigned)?$`)
+
+var wordSeparator = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// goName returns the exported Go name of a snake_case database name
+func goName(name string) string {
+	var b strings.Builder
+	for _, word := range wordSeparator.Split(name, -1) {
+		if word == "" {
+			continue
+		}
+		if upper := strings.ToUpper(word); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	if b.Len() == 0 || (b.String()[0] >= '0' && b.String()[0] <= '9') {
+		return "X" + b.String()
+	}
+	return b.String()
+}
+
+// packageName returns a valid package name from a directory name
+func packageName(dir string) string {
+	name := strings.ToLower(wordSeparator.ReplaceAllString(dir, ""))
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return "models"
+	}
+	return name
+}
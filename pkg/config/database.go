@@ -2,13 +2,14 @@ package config
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
 // DatabaseConfig represents comprehensive database configuration with connection pooling and timeout support
 type DatabaseConfig struct {
 	// Basic connection settings
-	Driver   string `yaml:"driver" json:"driver" validate:"required,oneof=postgres mysql sqlite sqlserver,max=20" default:"postgres"`
+	Driver   string `yaml:"driver" json:"driver" validate:"required,oneof=postgres mysql sqlite sqlserver oracle cockroachdb,max=20" default:"postgres"`
 	Host     string `yaml:"host" json:"host" validate:"required,hostname,max=255" default:"localhost"`
 	Port     int    `yaml:"port" json:"port" validate:"required,min=1,max=65535" default:"5432"`
 	Database string `yaml:"database" json:"database" validate:"required,min=1,max=64" default:"ormx"`
@@ -26,7 +27,10 @@ type DatabaseConfig struct {
 	LeakDetection      bool          `yaml:"leak_detection" json:"leak_detection" default:"true"`
 	LeakTimeout        time.Duration `yaml:"leak_timeout" json:"leak_timeout" validate:"omitempty,min=1s,max=5m" default:"1m"`
 
-	// Timeout Configuration
+	// Timeout Configuration. QueryTimeout and TransactionTimeout bound the
+	// statements and transactions of repositories configured with them (see
+	// repository.RepositoryConfig); StatementTimeout is the statement_timeout
+	// of PostgreSQL and CockroachDB sessions, enforced by the server.
 	ConnectionTimeout  time.Duration `yaml:"connection_timeout" json:"connection_timeout" validate:"required,min=1s,max=30s" default:"10s"`
 	QueryTimeout       time.Duration `yaml:"query_timeout" json:"query_timeout" validate:"required,min=100ms,max=5m" default:"30s"`
 	TransactionTimeout time.Duration `yaml:"transaction_timeout" json:"transaction_timeout" validate:"required,min=1s,max=10m" default:"5m"`
@@ -63,6 +67,12 @@ type DatabaseConfig struct {
 
 	// Read Replicas Configuration
 	ReadReplicas []ReadReplicaConfig `yaml:"read_replicas" json:"read_replicas" validate:"omitempty,dive,max=10"`
+
+	// Startup Migration Configuration
+	StartupMigration *StartupMigrationConfig `yaml:"startup_migration" json:"startup_migration" validate:"omitempty"`
+
+	// Versioned Migrations Configuration
+	Migrations *MigrationsConfig `yaml:"migrations" json:"migrations" validate:"omitempty"`
 }
 
 // RetryConfig represents retry configuration
@@ -117,6 +127,45 @@ type ReadReplicaConfig struct {
 	IdleTimeout        time.Duration `yaml:"idle_timeout" json:"idle_timeout" validate:"omitempty,min=30s,max=1h" default:"5m"`
 }
 
+// StartupMigrationConfig represents automatic schema migration on boot, meant
+// as a development and staging convenience. Only the listed entities (type or
+// table names) are migrated, and production environments are refused unless
+// Force is set.
+type StartupMigrationConfig struct {
+	Enabled     bool     `yaml:"enabled" json:"enabled" default:"false"`
+	Environment string   `yaml:"environment" json:"environment" validate:"omitempty,max=32" default:"development"`
+	Entities    []string `yaml:"entities" json:"entities" validate:"omitempty,dive,min=1,max=128"`
+	Force       bool     `yaml:"force" json:"force" default:"false"`
+}
+
+// MigrationsConfig represents the versioned migration runner configuration.
+// SQL migrations are read from Dir as <version>_<name>.up.sql and
+// <version>_<name>.down.sql files.
+type MigrationsConfig struct {
+	Dir         string        `yaml:"dir" json:"dir" validate:"omitempty,max=255" default:"migrations"`
+	Table       string        `yaml:"table" json:"table" validate:"omitempty,max=64" default:"schema_migrations"`
+	LockTimeout time.Duration `yaml:"lock_timeout" json:"lock_timeout" validate:"omitempty,min=1s,max=1h" default:"1m"`
+	DryRun      bool          `yaml:"dry_run" json:"dry_run" default:"false"`
+	// Guard is the schema guard reaction to a schema version mismatch:
+	// refuse every statement or serve read-only
+	Guard string `yaml:"guard" json:"guard" validate:"omitempty,oneof=refuse read_only,max=20" default:"refuse"`
+	// FailOnDrift fails schema inspections finding the database schema
+	// different from the models
+	FailOnDrift bool `yaml:"fail_on_drift" json:"fail_on_drift" default:"false"`
+}
+
+// productionEnvironments are the environment names treated as production
+var productionEnvironments = map[string]bool{
+	"production": true,
+	"prod":       true,
+	"live":       true,
+}
+
+// IsProduction reports whether the configured environment is production
+func (c *StartupMigrationConfig) IsProduction() bool {
+	return productionEnvironments[strings.ToLower(strings.TrimSpace(c.Environment))]
+}
+
 // ObservabilityConfig represents observability configuration
 type ObservabilityConfig struct {
 	EnableMetrics bool   `yaml:"enable_metrics" json:"enable_metrics" default:"true"`
@@ -149,20 +198,33 @@ func (c *DatabaseConfig) ConnectionString() string {
 	}
 
 	switch c.Driver {
-	case "postgres":
+	case "postgres", "cockroachdb":
 		return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-			c.Host, c.Port, c.Username, c.Password, c.Database, c.SSLMode)
+			c.Host, c.Port, c.Username, c.Password, c.Database, c.SSLMode) + c.statementTimeoutParam()
 	case "mysql":
 		return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true",
 			c.Username, c.Password, c.Host, c.Port, c.Database)
 	case "sqlserver":
 		return fmt.Sprintf("server=%s;user id=%s;password=%s;database=%s;port=%d",
 			c.Host, c.Username, c.Password, c.Database, c.Port)
+	case "oracle":
+		return fmt.Sprintf("oracle://%s:%s@%s:%d/%s",
+			c.Username, c.Password, c.Host, c.Port, c.Database)
 	default:
 		return ""
 	}
 }
 
+// statementTimeoutParam returns the connection parameter setting the
+// statement_timeout of the PostgreSQL and CockroachDB sessions, so the server
+// aborts statements running longer than StatementTimeout; empty when zero
+func (c *DatabaseConfig) statementTimeoutParam() string {
+	if c.StatementTimeout <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" statement_timeout=%d", c.StatementTimeout.Milliseconds())
+}
+
 // Validate validates the DatabaseConfig
 func (c *DatabaseConfig) Validate() error {
 	// Validate required fields
@@ -267,6 +329,11 @@ func (c *DatabaseConfig) Validate() error {
 		return fmt.Errorf("read replica validation failed: %w", err)
 	}
 
+	// Validate startup migration configuration
+	if c.StartupMigration != nil && c.StartupMigration.Enabled && len(c.StartupMigration.Entities) == 0 {
+		return fmt.Errorf("startup_migration entities are required when enabled")
+	}
+
 	// Validate pagination consistency
 	if c.Pagination != nil {
 		if c.Pagination.MinLimit <= 0 || c.Pagination.MinLimit > 1000 {
@@ -345,9 +412,9 @@ func (c *DatabaseConfig) ReadReplicaDSNs() []string {
 // buildDSN builds a DSN string for GORM (Data Source Name)
 func (c *DatabaseConfig) buildDSN(host string, port int, username, password, database, sslMode string) string {
 	switch c.Driver {
-	case "postgres":
+	case "postgres", "cockroachdb":
 		return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-			host, port, username, password, database, sslMode)
+			host, port, username, password, database, sslMode) + c.statementTimeoutParam()
 	case "mysql":
 		return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true",
 			username, password, host, port, database)
@@ -356,6 +423,9 @@ func (c *DatabaseConfig) buildDSN(host string, port int, username, password, dat
 	case "sqlserver":
 		return fmt.Sprintf("server=%s;user id=%s;password=%s;database=%s;port=%d",
 			host, username, password, database, port)
+	case "oracle":
+		return fmt.Sprintf("oracle://%s:%s@%s:%d/%s",
+			username, password, host, port, database)
 	default:
 		return ""
 	}
@@ -419,9 +489,9 @@ func (c *DatabaseConfig) HasReadReplicas() bool {
 // buildConnectionString builds a connection string for the given parameters
 func (c *DatabaseConfig) buildConnectionString(host, port, username, password, database, sslMode string) string {
 	switch c.Driver {
-	case "postgres":
+	case "postgres", "cockroachdb":
 		return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-			host, port, username, password, database, sslMode)
+			host, port, username, password, database, sslMode) + c.statementTimeoutParam()
 	case "mysql":
 		return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
 			username, password, host, port, database)
@@ -430,6 +500,9 @@ func (c *DatabaseConfig) buildConnectionString(host, port, username, password, d
 	case "sqlserver":
 		return fmt.Sprintf("server=%s;user id=%s;password=%s;database=%s;port=%s",
 			host, username, password, database, port)
+	case "oracle":
+		return fmt.Sprintf("oracle://%s:%s@%s:%s/%s",
+			username, password, host, port, database)
 	default:
 		return ""
 	}
@@ -587,5 +660,19 @@ func DefaultDatabaseConfig() *DatabaseConfig {
 
 		// Read Replicas Configuration
 		ReadReplicas: []ReadReplicaConfig{},
+
+		// Startup Migration Configuration
+		StartupMigration: &StartupMigrationConfig{
+			Enabled:     false,
+			Environment: "development",
+		},
+
+		// Versioned Migrations Configuration
+		Migrations: &MigrationsConfig{
+			Dir:         "migrations",
+			Table:       "schema_migrations",
+			LockTimeout: time.Minute,
+			Guard:       "refuse",
+		},
 	}
 }
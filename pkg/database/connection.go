@@ -8,9 +8,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/seasbee/go-ormx/pkg/config"
-	"gorm.io/driver/mysql"
-	"gorm.io/driver/postgres"
-	"gorm.io/driver/sqlite"
+	"github.com/seasbee/go-ormx/pkg/observability"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
@@ -20,6 +18,8 @@ type ConnectionManager struct {
 	config     *config.DatabaseConfig
 	primaryDB  *gorm.DB
 	readDBs    []*gorm.DB
+	replicas   []*replica
+	routeMu    sync.Mutex
 	mu         sync.RWMutex
 	ctx        context.Context
 	cancel     context.CancelFunc
@@ -73,7 +73,7 @@ func NewConnectionManager(cfg *config.DatabaseConfig) (*ConnectionManager, error
 
 // initializePrimaryConnection sets up the primary database connection
 func (cm *ConnectionManager) initializePrimaryConnection() error {
-	db, err := cm.createConnection(*cm.config)
+	db, err := openConnection(*cm.config)
 	if err != nil {
 		return errors.Wrap(err, "failed to create primary connection")
 	}
@@ -84,58 +84,47 @@ func (cm *ConnectionManager) initializePrimaryConnection() error {
 		return errors.Wrap(err, "failed to get underlying sql.DB")
 	}
 
-	// Set connection pool settings
-	sqlDB.SetMaxOpenConns(cm.config.MaxConnections)
-	sqlDB.SetMaxIdleConns(cm.config.MaxIdleConnections)
-	sqlDB.SetConnMaxLifetime(cm.config.MaxLifetime)
-	sqlDB.SetConnMaxIdleTime(cm.config.IdleTimeout)
+	configurePool(sqlDB, *cm.config)
 
 	cm.primaryDB = db
 	return nil
 }
 
-// initializeReadReplicas sets up read replica connections
-func (cm *ConnectionManager) initializeReadReplicas() error {
-	// For now, we'll skip read replicas since they're not in the current config
-	// This can be extended later when read replica support is added
-	return nil
-}
-
-// createConnection creates a GORM connection based on the driver type
-func (cm *ConnectionManager) createConnection(connConfig config.DatabaseConfig) (*gorm.DB, error) {
-	var dialector gorm.Dialector
-
-	switch connConfig.Driver {
-	case "postgres":
-		dsn := connConfig.ConnectionString()
-		dialector = postgres.Open(dsn)
-	case "mysql":
-		dsn := connConfig.ConnectionString()
-		dialector = mysql.Open(dsn)
-	case "sqlite":
-		dsn := connConfig.ConnectionString()
-		dialector = sqlite.Open(dsn)
-	default:
+// openConnection creates a GORM connection based on the driver type
+func openConnection(connConfig config.DatabaseConfig) (*gorm.DB, error) {
+	open, ok := lookupDriver(connConfig.Driver)
+	if !ok {
 		return nil, fmt.Errorf("unsupported database driver: %s", connConfig.Driver)
 	}
+	dialector := open(connConfig.ConnectionString())
 
 	// Configure GORM logger
 	gormConfig := &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 	}
 
-	// Add timeout configuration
+	db, err := gorm.Open(dialector, gormConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the connection within the connection timeout. The timeout context
+	// must not stay attached to the returned DB, or every later query would
+	// run with an expired context.
 	if connConfig.ConnectionTimeout > 0 {
 		ctx, cancel := context.WithTimeout(context.Background(), connConfig.ConnectionTimeout)
 		defer cancel()
-		db, err := gorm.Open(dialector, gormConfig)
+
+		sqlDB, err := db.DB()
 		if err != nil {
 			return nil, err
 		}
-		return db.WithContext(ctx), nil
+		if err := sqlDB.PingContext(ctx); err != nil {
+			return nil, err
+		}
 	}
 
-	return gorm.Open(dialector, gormConfig)
+	return db, nil
 }
 
 // GetPrimaryDB returns the primary database connection
@@ -145,18 +134,13 @@ func (cm *ConnectionManager) GetPrimaryDB() *gorm.DB {
 	return cm.primaryDB
 }
 
-// GetReadDB returns a read replica database connection (round-robin)
+// GetReadDB returns an available read replica connection (weighted
+// round-robin), or the primary connection when none is available
 func (cm *ConnectionManager) GetReadDB() *gorm.DB {
-	cm.mu.RLock()
-	defer cm.mu.RUnlock()
-
-	if len(cm.readDBs) == 0 {
-		return cm.primaryDB
+	if r := cm.nextReplica(); r != nil {
+		return r.db
 	}
-
-	// Simple round-robin selection
-	index := time.Now().UnixNano() % int64(len(cm.readDBs))
-	return cm.readDBs[index]
+	return cm.GetPrimaryDB()
 }
 
 // GetAllReadDBs returns all read replica connections
@@ -196,15 +180,25 @@ func (cm *ConnectionManager) performHealthChecks() {
 		}
 	}
 
-	// Check read replicas
-	for i, readDB := range cm.readDBs {
-		if readDB != nil {
-			result := cm.checkConnectionHealth(readDB, fmt.Sprintf("read-replica-%d", i))
-			select {
-			case cm.healthChan <- result:
-			default:
-				// Channel is full, skip this result
-			}
+	// Check read replicas, taking unhealthy or slow ones out of rotation
+	for _, r := range cm.replicas {
+		start := time.Now()
+		result := cm.checkConnectionHealth(r.db, r.name)
+		latency := time.Since(start)
+		if result.Healthy && r.config.MaxLatency > 0 && latency > r.config.MaxLatency {
+			result.Healthy = false
+			result.Error = errors.Errorf("latency %v exceeds max_latency %v", latency, r.config.MaxLatency)
+		}
+		if result.Healthy {
+			cm.markReplicaUp(r)
+		} else {
+			cm.markReplicaDown(r)
+		}
+
+		select {
+		case cm.healthChan <- result:
+		default:
+			// Channel is full, skip this result
 		}
 	}
 }
@@ -347,3 +341,23 @@ func (cm *ConnectionManager) GetStats() map[string]interface{} {
 
 	return stats
 }
+
+// CollectConnectionStats registers the pools of the primary and read replica
+// connections with manager, which samples them every ConnectionStatsInterval
+// under the names "primary" and "replica_<n>"
+func (cm *ConnectionManager) CollectConnectionStats(manager *observability.ObservabilityManager) error {
+	sqlDB, err := cm.GetPrimaryDB().DB()
+	if err != nil {
+		return errors.Wrap(err, "failed to get primary connection pool")
+	}
+	manager.CollectConnectionStats("primary", sqlDB)
+
+	for i, readDB := range cm.GetAllReadDBs() {
+		sqlDB, err := readDB.DB()
+		if err != nil {
+			return errors.Wrapf(err, "failed to get read replica %d connection pool", i)
+		}
+		manager.CollectConnectionStats(fmt.Sprintf("replica_%d", i), sqlDB)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DialectorFactory opens a gorm dialector for a DSN
+type DialectorFactory func(dsn string) gorm.Dialector
+
+var (
+	driversMu sync.RWMutex
+	drivers   = map[string]DialectorFactory{}
+)
+
+// RegisterDriver registers a dialector factory for a driver name.
+// Drivers that are not bundled (e.g. sqlserver, oracle, or postgres and mysql
+// in builds tagged ormx_minimal) can be plugged in by registering their gorm
+// dialector, for example:
+//
+//	database.RegisterDriver("oracle", oracle.Open)
+func RegisterDriver(name string, factory DialectorFactory) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	drivers[name] = factory
+}
+
+// RegisteredDrivers returns the names of all registered drivers
+func RegisteredDrivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupDriver returns the dialector factory registered for a driver name
+func lookupDriver(name string) (DialectorFactory, bool) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	factory, ok := drivers[name]
+	return factory, ok
+}
+
+// OpenDSN opens a connection to dsn with the dialector registered for
+// driver, without the pool settings and health checks of a
+// ConnectionManager, for tools such as `ormx gen`
+func OpenDSN(driver, dsn string) (*gorm.DB, error) {
+	open, ok := lookupDriver(driver)
+	if !ok {
+		return nil, fmt.Errorf("unsupported database driver: %s", driver)
+	}
+	db, err := gorm.Open(open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s database: %w", driver, err)
+	}
+	return db, nil
+}
@@ -0,0 +1,15 @@
+//go:build !ormx_minimal
+
+package database
+
+import (
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+)
+
+// Server drivers are not bundled in builds tagged ormx_minimal
+func init() {
+	RegisterDriver("postgres", postgres.Open)
+	RegisterDriver("cockroachdb", postgres.Open)
+	RegisterDriver("mysql", mysql.Open)
+}
@@ -0,0 +1,13 @@
+//go:build !ormx_minimal || cgo
+
+package database
+
+import (
+	"gorm.io/driver/sqlite"
+)
+
+// SQLite stays bundled in builds tagged ormx_minimal when cgo is available
+// (e.g. mobile targets); wasm builds register a pure Go SQLite driver instead
+func init() {
+	RegisterDriver("sqlite", sqlite.Open)
+}
@@ -0,0 +1,268 @@
+package database
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/config"
+	"gorm.io/gorm"
+)
+
+// ErrDatabaseDown is returned by HealthChecker.Check while the database is down
+var ErrDatabaseDown = errors.New("database is down")
+
+// HealthState is the state of a database followed by a HealthChecker
+type HealthState string
+
+const (
+	// HealthStateHealthy means the last check succeeded and the database has
+	// been up for at least the recovery time
+	HealthStateHealthy HealthState = "healthy"
+	// HealthStateDegraded means recent checks failed, fewer than MaxFailures in a row
+	HealthStateDegraded HealthState = "degraded"
+	// HealthStateDown means at least MaxFailures checks failed in a row
+	HealthStateDown HealthState = "down"
+	// HealthStateRecovered means checks succeed again after the database was
+	// down, for less than the recovery time
+	HealthStateRecovered HealthState = "recovered"
+)
+
+// HealthStatus is the state of a database as seen by its last health check
+type HealthStatus struct {
+	Name                string      `json:"name"`
+	State               HealthState `json:"state"`
+	ConsecutiveFailures int         `json:"consecutive_failures"`
+	// Since is when the database entered its current state
+	Since       time.Time     `json:"since"`
+	LastCheck   time.Time     `json:"last_check"`
+	LastSuccess time.Time     `json:"last_success"`
+	Latency     time.Duration `json:"latency"`
+	LastError   string        `json:"last_error,omitempty"`
+}
+
+// Healthy reports whether the database serves queries, possibly degraded
+func (s HealthStatus) Healthy() bool {
+	return s.State != HealthStateDown
+}
+
+// HTTPStatus returns the HTTP status code reporting the status in a health
+// endpoint: 503 while the database is down, 200 otherwise
+func (s HealthStatus) HTTPStatus() int {
+	if !s.Healthy() {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// HealthTransition describes a change of state reported to the callbacks
+// registered with OnStateChange
+type HealthTransition struct {
+	From   HealthState  `json:"from"`
+	To     HealthState  `json:"to"`
+	Status HealthStatus `json:"status"`
+}
+
+// HealthChecker runs the configured health check query against a database
+// every interval and follows its state: failures make it degraded, then
+// down after MaxFailures consecutive failures; a success after being down
+// makes it recovered, and healthy again once checks kept succeeding for the
+// recovery time.
+type HealthChecker struct {
+	db     *gorm.DB
+	config config.HealthCheckConfig
+
+	mu        sync.Mutex
+	status    HealthStatus
+	checked   bool
+	callbacks []func(HealthTransition)
+	running   bool
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewHealthChecker creates a health checker of db, named name in its status
+func NewHealthChecker(name string, db *gorm.DB, cfg config.HealthCheckConfig) *HealthChecker {
+	if cfg.Query == "" {
+		cfg.Query = "SELECT 1"
+	}
+	return &HealthChecker{
+		db:     db,
+		config: cfg,
+		status: HealthStatus{Name: name, State: HealthStateHealthy, Since: time.Now()},
+	}
+}
+
+// HealthChecker returns a health checker of the pool configured by its
+// DatabaseConfig.HealthCheck
+func (p *Pool) HealthChecker() *HealthChecker {
+	return NewHealthChecker(p.Name(), p.db, p.config.HealthCheck)
+}
+
+// OnStateChange registers fn to be called on every change of state, from the
+// goroutine running the check
+func (hc *HealthChecker) OnStateChange(fn func(HealthTransition)) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.callbacks = append(hc.callbacks, fn)
+}
+
+// Start checks the database every interval until Stop is called
+func (hc *HealthChecker) Start() error {
+	if !hc.config.Enabled {
+		return errors.New("health check is disabled")
+	}
+	if hc.config.Interval <= 0 {
+		return errors.New("health check interval must be positive")
+	}
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if hc.running {
+		return errors.New("health checker is already running")
+	}
+	hc.running = true
+	hc.stop = make(chan struct{})
+
+	hc.wg.Add(1)
+	go func(stop chan struct{}) {
+		defer hc.wg.Done()
+		ticker := time.NewTicker(hc.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				hc.RunCheck(context.Background())
+			}
+		}
+	}(hc.stop)
+	return nil
+}
+
+// Stop stops the periodic checks and waits for a running check to finish
+func (hc *HealthChecker) Stop() {
+	hc.mu.Lock()
+	if !hc.running {
+		hc.mu.Unlock()
+		return
+	}
+	hc.running = false
+	close(hc.stop)
+	hc.mu.Unlock()
+
+	hc.wg.Wait()
+}
+
+// Status returns the status of the last check
+func (hc *HealthChecker) Status() HealthStatus {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	return hc.status
+}
+
+// Check returns the current status for health endpoints, checking the
+// database first if it was never checked. The error is ErrDatabaseDown while
+// the database is down.
+func (hc *HealthChecker) Check(ctx context.Context) (HealthStatus, error) {
+	hc.mu.Lock()
+	checked := hc.checked
+	hc.mu.Unlock()
+
+	status := hc.Status()
+	if !checked {
+		status = hc.RunCheck(ctx)
+	}
+	if !status.Healthy() {
+		return status, errors.Wrap(ErrDatabaseDown, status.LastError)
+	}
+	return status, nil
+}
+
+// RunCheck runs the health check query once, bounded by the check timeout,
+// and updates the state
+func (hc *HealthChecker) RunCheck(ctx context.Context) HealthStatus {
+	if hc.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hc.config.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := hc.query(ctx)
+	return hc.record(start, time.Since(start), err)
+}
+
+// query runs the health check query, reading its rows to the end
+func (hc *HealthChecker) query(ctx context.Context) error {
+	rows, err := hc.db.WithContext(ctx).Raw(hc.config.Query).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// record updates the state with the result of a check and notifies the
+// callbacks of a change of state
+func (hc *HealthChecker) record(at time.Time, latency time.Duration, err error) HealthStatus {
+	hc.mu.Lock()
+	status := hc.status
+	from := status.State
+
+	hc.checked = true
+	status.LastCheck = at
+	status.Latency = latency
+	if err != nil {
+		status.ConsecutiveFailures++
+		status.LastError = err.Error()
+		if status.ConsecutiveFailures >= hc.maxFailures() {
+			status.State = HealthStateDown
+		} else if from != HealthStateDown {
+			status.State = HealthStateDegraded
+		}
+	} else {
+		status.ConsecutiveFailures = 0
+		status.LastError = ""
+		status.LastSuccess = at
+		switch from {
+		case HealthStateDown:
+			status.State = HealthStateRecovered
+		case HealthStateRecovered:
+			if at.Sub(status.Since) >= hc.config.RecoveryTime {
+				status.State = HealthStateHealthy
+			}
+		default:
+			status.State = HealthStateHealthy
+		}
+	}
+	if status.State != from {
+		status.Since = at
+	}
+	hc.status = status
+	callbacks := append([]func(HealthTransition){}, hc.callbacks...)
+	hc.mu.Unlock()
+
+	if status.State != from {
+		transition := HealthTransition{From: from, To: status.State, Status: status}
+		for _, fn := range callbacks {
+			fn(transition)
+		}
+	}
+	return status
+}
+
+// maxFailures returns the consecutive failures after which the database is down
+func (hc *HealthChecker) maxFailures() int {
+	if hc.config.MaxFailures <= 0 {
+		return 1
+	}
+	return hc.config.MaxFailures
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/migrations"
+)
+
+// NewMigrator creates a versioned migration runner on the primary connection
+// according to the migrations configuration
+func (cm *ConnectionManager) NewMigrator(logger logging.Logger) (*migrations.Migrator, error) {
+	return migrations.NewMigratorFromConfig(cm.GetPrimaryDB(), cm.config.Migrations, logger)
+}
+
+// ExecScript splits script into statements and executes them in order on the
+// primary connection, reporting each statement
+func (cm *ConnectionManager) ExecScript(ctx context.Context, script string, config *migrations.ScriptConfig) (*migrations.ScriptReport, error) {
+	return migrations.ExecScript(ctx, cm.GetPrimaryDB(), script, config)
+}
+
+// InspectSchema compares models with the schema of the primary connection
+// and returns the diff, failing with migrations.ErrSchemaDrift on drift when
+// the migrations fail_on_drift setting is set
+func (cm *ConnectionManager) InspectSchema(ctx context.Context, logger logging.Logger, models ...interface{}) (*migrations.SchemaDiff, error) {
+	config := &migrations.SchemaInspectorConfig{}
+	if cm.config.Migrations != nil {
+		config.FailOnDrift = cm.config.Migrations.FailOnDrift
+	}
+	inspector, err := migrations.NewSchemaInspector(cm.GetPrimaryDB(), logger, config)
+	if err != nil {
+		return nil, err
+	}
+	return inspector.Inspect(ctx, models...)
+}
+
+// ReconcileIndexes creates the indexes declared on models that are missing
+// from the primary connection, concurrently where the dialect supports it,
+// reporting each index to progress when it is not nil
+func (cm *ConnectionManager) ReconcileIndexes(ctx context.Context, logger logging.Logger, progress func(migrations.IndexProgress), models ...interface{}) (*migrations.IndexReport, error) {
+	reconciler, err := migrations.NewIndexReconciler(cm.GetPrimaryDB(), logger, &migrations.IndexReconcilerConfig{Progress: progress})
+	if err != nil {
+		return nil, err
+	}
+	return reconciler.Reconcile(ctx, models...)
+}
+
+// GuardSchema pins the schema version to the migrations of migrator and
+// checks the database against it. The guard is installed on the primary and
+// read replica connections, which then reject every statement or the writes,
+// according to the migrations guard setting, while the schema mismatches. In
+// refuse mode the mismatch is returned so the application fails to start.
+func (cm *ConnectionManager) GuardSchema(ctx context.Context, migrator *migrations.Migrator, logger logging.Logger) (*migrations.SchemaGuard, error) {
+	mode := migrations.GuardRefuse
+	if cm.config.Migrations != nil && cm.config.Migrations.Guard != "" {
+		mode = migrations.GuardMode(cm.config.Migrations.Guard)
+	}
+	guard, err := migrations.NewSchemaGuard(migrator, mode, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cm.GetPrimaryDB().Use(guard); err != nil {
+		return nil, errors.Wrap(err, "failed to guard primary")
+	}
+	for i, readDB := range cm.GetAllReadDBs() {
+		if err := readDB.Use(guard); err != nil {
+			return nil, errors.Wrapf(err, "failed to guard read replica %d", i)
+		}
+	}
+
+	if _, err := guard.Check(ctx); err != nil {
+		return guard, err
+	}
+	return guard, nil
+}
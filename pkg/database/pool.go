@@ -0,0 +1,536 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/config"
+	ormerrors "github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/observability"
+	"gorm.io/gorm"
+)
+
+// ErrAcquireTimeout is returned when no pooled connection became available
+// within the configured acquire timeout. It is wrapped in an ORMError of type
+// ErrorTypeResource carrying the pool statistics in its context.
+var ErrAcquireTimeout = errors.New("timed out acquiring a database connection")
+
+// defaultLeakTimeout applies when leak detection is enabled without a leak timeout
+const defaultLeakTimeout = time.Minute
+
+// Pool is a database connection pool configured from a DatabaseConfig:
+//
+//   - MaxConnections, MaxIdleConnections, MaxLifetime and IdleTimeout bound
+//     the underlying sql.DB pool
+//   - MinConnections connections are established when the pool is opened.
+//     database/sql keeps no floor, idle connections beyond MaxIdleConnections
+//     or IdleTimeout are closed afterwards.
+//   - AcquireTimeout bounds the wait for a connection, after which statements
+//     fail with ErrAcquireTimeout and the OnExhausted callbacks are called
+//   - with LeakDetection, the stack trace of every acquisition is captured and
+//     connections held longer than LeakTimeout are reported as leaks
+//
+// Statements run through Row hold their connection until scanned and are not
+// bound by the acquire timeout nor followed by leak detection.
+type Pool struct {
+	db     *gorm.DB
+	sqlDB  *sql.DB
+	conns  *managedConnPool
+	config config.DatabaseConfig
+
+	mu       sync.Mutex
+	observed bool
+	stop     chan struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// PoolStats describes the state of a pool
+type PoolStats struct {
+	sql.DBStats
+	// MinConnections is the number of connections the pool was warmed up with
+	MinConnections int `json:"min_connections"`
+	// AcquireTimeouts is the number of acquisitions that timed out
+	AcquireTimeouts int64 `json:"acquire_timeouts"`
+	// LeakedConnections is the number of connections currently held beyond
+	// the leak timeout
+	LeakedConnections int `json:"leaked_connections"`
+	// LeaksDetected is the number of connections detected as leaked so far
+	LeaksDetected int64 `json:"leaks_detected"`
+}
+
+// Open opens a database connection pool configured from cfg
+func Open(cfg *config.DatabaseConfig) (*Pool, error) {
+	if cfg == nil {
+		return nil, errors.New("database config cannot be nil")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid database config")
+	}
+
+	db, err := openConnection(*cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open database connection")
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get underlying sql.DB")
+	}
+	configurePool(sqlDB, *cfg)
+
+	p := &Pool{
+		db:     db,
+		sqlDB:  sqlDB,
+		config: *cfg,
+		stop:   make(chan struct{}),
+	}
+	p.conns = &managedConnPool{name: p.Name(), db: sqlDB, acquireTimeout: cfg.AcquireTimeout}
+	if cfg.LeakDetection {
+		timeout := cfg.LeakTimeout
+		if timeout <= 0 {
+			timeout = defaultLeakTimeout
+		}
+		p.conns.leaks = &leakDetector{pool: p.Name(), timeout: timeout, leases: make(map[uint64]*lease)}
+	}
+
+	if err := p.warmUp(); err != nil {
+		_ = sqlDB.Close()
+		return nil, errors.Wrap(err, "failed to establish minimum connections")
+	}
+
+	db.ConnPool = p.conns
+	db.Statement.ConnPool = p.conns
+	return p, nil
+}
+
+// configurePool applies the pool settings of connConfig to sqlDB
+func configurePool(sqlDB *sql.DB, connConfig config.DatabaseConfig) {
+	sqlDB.SetMaxOpenConns(connConfig.MaxConnections)
+	sqlDB.SetMaxIdleConns(connConfig.MaxIdleConnections)
+	sqlDB.SetConnMaxLifetime(connConfig.MaxLifetime)
+	sqlDB.SetConnMaxIdleTime(connConfig.IdleTimeout)
+}
+
+// warmUp establishes the minimum connections, as many as may stay idle
+func (p *Pool) warmUp() error {
+	n := p.config.MinConnections
+	if n > p.config.MaxIdleConnections {
+		n = p.config.MaxIdleConnections
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	if p.config.ConnectionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.config.ConnectionTimeout)
+		defer cancel()
+	}
+
+	conns := make([]*sql.Conn, 0, n)
+	defer func() {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+	for i := 0; i < n; i++ {
+		conn, err := p.sqlDB.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		if err := conn.PingContext(ctx); err != nil {
+			_ = conn.Close()
+			return err
+		}
+		conns = append(conns, conn)
+	}
+	return nil
+}
+
+// Name returns the name of the pool in metrics, the configured database
+func (p *Pool) Name() string {
+	return p.config.Database
+}
+
+// DB returns the gorm connection using the pool
+func (p *Pool) DB() *gorm.DB {
+	return p.db
+}
+
+// Stats returns the current state of the pool
+func (p *Pool) Stats() PoolStats {
+	stats := PoolStats{
+		DBStats:         p.sqlDB.Stats(),
+		MinConnections:  p.config.MinConnections,
+		AcquireTimeouts: p.conns.acquireTimeouts.Load(),
+	}
+	if p.conns.leaks != nil {
+		stats.LeakedConnections = len(p.conns.leaks.scan(false))
+		stats.LeaksDetected = p.conns.leaks.detected.Load()
+	}
+	return stats
+}
+
+// Leaks returns the connections currently held beyond the leak timeout,
+// nil without leak detection
+func (p *Pool) Leaks() []observability.ConnectionLeak {
+	if p.conns.leaks == nil {
+		return nil
+	}
+	return p.conns.leaks.scan(false)
+}
+
+// RecordStats records the pool state to manager, and the leaks detected
+// since the previous call
+func (p *Pool) RecordStats(ctx context.Context, manager *observability.ObservabilityManager) {
+	stats := p.Stats()
+	manager.RecordPoolMetrics(ctx, observability.PoolMetrics{
+		Pool:              p.Name(),
+		MaxOpen:           stats.MaxOpenConnections,
+		MinConnections:    stats.MinConnections,
+		Open:              stats.OpenConnections,
+		InUse:             stats.InUse,
+		Idle:              stats.Idle,
+		WaitCount:         stats.WaitCount,
+		WaitDuration:      stats.WaitDuration,
+		MaxIdleClosed:     stats.MaxIdleClosed,
+		MaxIdleTimeClosed: stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed: stats.MaxLifetimeClosed,
+		AcquireTimeouts:   stats.AcquireTimeouts,
+		Leaked:            stats.LeakedConnections,
+	})
+
+	if p.conns.leaks != nil {
+		for _, leak := range p.conns.leaks.scan(true) {
+			manager.RecordConnectionLeak(ctx, leak)
+		}
+	}
+}
+
+// OnExhausted registers fn to be called, from the statement's goroutine, with
+// the error of every acquisition that timed out, e.g. to evaluate a circuit
+// breaker or run a health check
+func (p *Pool) OnExhausted(fn func(ctx context.Context, err *ormerrors.ORMError)) {
+	p.conns.mu.Lock()
+	defer p.conns.mu.Unlock()
+	p.conns.callbacks = append(p.conns.callbacks, fn)
+}
+
+// Observe records the pool state to manager every interval until the pool
+// is closed, and every acquisition timeout as it happens in
+// orm_pool_exhausted_total. A non-positive interval uses the health check
+// interval.
+func (p *Pool) Observe(manager *observability.ObservabilityManager, interval time.Duration) error {
+	if manager == nil {
+		return errors.New("observability manager is required")
+	}
+	if interval <= 0 {
+		interval = p.config.HealthCheckInterval
+	}
+	if interval <= 0 {
+		return errors.New("observation interval must be positive")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return errors.New("pool is closed")
+	}
+	if p.observed {
+		return errors.New("pool is already observed")
+	}
+	p.observed = true
+	p.conns.mu.Lock()
+	p.conns.manager = manager
+	p.conns.mu.Unlock()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.RecordStats(context.Background(), manager)
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops observing the pool and closes its connections
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.stop)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	if err := p.sqlDB.Close(); err != nil {
+		return errors.Wrap(err, "failed to close connection pool")
+	}
+	return nil
+}
+
+// managedConnPool runs statements on connections explicitly acquired from the
+// pool, bounding the wait by the acquire timeout and following the held
+// connections for leak detection
+type managedConnPool struct {
+	name            string
+	db              *sql.DB
+	acquireTimeout  time.Duration
+	leaks           *leakDetector
+	acquireTimeouts atomic.Int64
+
+	mu        sync.RWMutex
+	manager   *observability.ObservabilityManager
+	callbacks []func(context.Context, *ormerrors.ORMError)
+}
+
+// acquire takes a connection from the pool. The returned release function
+// returns it once the statements running on it are done.
+func (p *managedConnPool) acquire(ctx context.Context, operation string) (*sql.Conn, func(), error) {
+	acquireCtx := ctx
+	if p.acquireTimeout > 0 {
+		var cancel context.CancelFunc
+		acquireCtx, cancel = context.WithTimeout(ctx, p.acquireTimeout)
+		defer cancel()
+	}
+
+	conn, err := p.db.Conn(acquireCtx)
+	if err != nil {
+		if ctx.Err() == nil && stderrors.Is(acquireCtx.Err(), context.DeadlineExceeded) {
+			return nil, nil, p.exhausted(ctx, operation)
+		}
+		return nil, nil, err
+	}
+
+	untrack := p.leaks.track(operation)
+	return conn, func() {
+		// Close waits for the rows and transactions using the connection
+		_ = conn.Close()
+		untrack()
+	}, nil
+}
+
+// exhausted counts an acquisition timeout, reports it and returns its error,
+// a resource error distinguishing the exhausted pool from connection failures
+func (p *managedConnPool) exhausted(ctx context.Context, operation string) error {
+	p.acquireTimeouts.Add(1)
+	stats := p.db.Stats()
+
+	err := ormerrors.Wrap(errors.Wrapf(ErrAcquireTimeout, "no connection available within %s", p.acquireTimeout),
+		ormerrors.ErrorTypeResource, "connection pool exhausted").WithOperation(operation)
+	err.AddContext("pool", p.name)
+	err.AddContext("acquire_timeout", p.acquireTimeout)
+	err.AddContext("max_open", stats.MaxOpenConnections)
+	err.AddContext("open", stats.OpenConnections)
+	err.AddContext("in_use", stats.InUse)
+	err.AddContext("idle", stats.Idle)
+	err.AddContext("wait_count", stats.WaitCount)
+	err.AddContext("wait_duration", stats.WaitDuration)
+	err.AddContext("acquire_timeouts", p.acquireTimeouts.Load())
+
+	p.mu.RLock()
+	manager, callbacks := p.manager, p.callbacks
+	p.mu.RUnlock()
+
+	if manager != nil {
+		manager.RecordPoolExhausted(ctx, observability.PoolExhaustion{
+			Pool:      p.name,
+			Operation: operation,
+			Timeout:   p.acquireTimeout,
+			MaxOpen:   stats.MaxOpenConnections,
+			InUse:     stats.InUse,
+			WaitCount: stats.WaitCount,
+		})
+	}
+	for _, fn := range callbacks {
+		fn(ctx, err)
+	}
+	return err
+}
+
+// ExecContext executes the statement on an acquired connection
+func (p *managedConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	conn, release, err := p.acquire(ctx, "exec")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return conn.ExecContext(ctx, query, args...)
+}
+
+// QueryContext runs the query on an acquired connection, which returns to the
+// pool once the rows are closed
+func (p *managedConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	conn, release, err := p.acquire(ctx, "query")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	go release()
+	return rows, nil
+}
+
+// QueryRowContext runs the query on the pool directly, a row cannot carry an
+// acquisition error
+func (p *managedConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return p.db.QueryRowContext(ctx, query, args...)
+}
+
+// PrepareContext prepares the statement on the pool, prepared statements
+// acquire connections as they run
+func (p *managedConnPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return p.db.PrepareContext(ctx, query)
+}
+
+// BeginTx starts a transaction on an acquired connection, which returns to the
+// pool when the transaction ends
+func (p *managedConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	conn, release, err := p.acquire(ctx, "transaction")
+	if err != nil {
+		return nil, err
+	}
+	tx, err := conn.BeginTx(ctx, opts)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &managedTx{Tx: tx, db: p.db, release: release}, nil
+}
+
+// GetDBConn returns the underlying pool
+func (p *managedConnPool) GetDBConn() (*sql.DB, error) {
+	return p.db, nil
+}
+
+// managedTx is a transaction releasing its connection when it ends
+type managedTx struct {
+	*sql.Tx
+	db      *sql.DB
+	release func()
+	once    sync.Once
+}
+
+// Commit commits the transaction and releases its connection
+func (tx *managedTx) Commit() error {
+	err := tx.Tx.Commit()
+	tx.once.Do(tx.release)
+	return err
+}
+
+// Rollback aborts the transaction and releases its connection
+func (tx *managedTx) Rollback() error {
+	err := tx.Tx.Rollback()
+	tx.once.Do(tx.release)
+	return err
+}
+
+// GetDBConn returns the pool of the transaction
+func (tx *managedTx) GetDBConn() (*sql.DB, error) {
+	return tx.db, nil
+}
+
+var (
+	_ gorm.ConnPool         = (*managedConnPool)(nil)
+	_ gorm.ConnPoolBeginner = (*managedConnPool)(nil)
+	_ gorm.GetDBConnector   = (*managedConnPool)(nil)
+	_ gorm.Tx               = (*managedTx)(nil)
+	_ gorm.GetDBConnector   = (*managedTx)(nil)
+)
+
+// leakDetector follows the connections held by the application
+type leakDetector struct {
+	pool     string
+	timeout  time.Duration
+	mu       sync.Mutex
+	nextID   uint64
+	leases   map[uint64]*lease
+	detected atomic.Int64
+}
+
+// lease is a held connection
+type lease struct {
+	operation string
+	acquired  time.Time
+	stack     []byte
+	leaked    bool
+	recorded  bool
+}
+
+// track follows a connection acquired for operation until the returned
+// function is called. A nil detector follows nothing.
+func (d *leakDetector) track(operation string) func() {
+	if d == nil {
+		return func() {}
+	}
+
+	l := &lease{operation: operation, acquired: time.Now(), stack: debug.Stack()}
+	d.mu.Lock()
+	d.nextID++
+	id := d.nextID
+	d.leases[id] = l
+	d.mu.Unlock()
+
+	return func() {
+		d.mu.Lock()
+		delete(d.leases, id)
+		d.mu.Unlock()
+	}
+}
+
+// scan returns the connections held beyond the timeout. With unrecorded only
+// the leaks not returned by a previous unrecorded scan are returned.
+func (d *leakDetector) scan(unrecorded bool) []observability.ConnectionLeak {
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var leaks []observability.ConnectionLeak
+	for _, l := range d.leases {
+		held := now.Sub(l.acquired)
+		if held < d.timeout {
+			continue
+		}
+		if !l.leaked {
+			l.leaked = true
+			d.detected.Add(1)
+		}
+		if unrecorded {
+			if l.recorded {
+				continue
+			}
+			l.recorded = true
+		}
+		leaks = append(leaks, observability.ConnectionLeak{
+			Pool:       d.pool,
+			Operation:  l.operation,
+			AcquiredAt: l.acquired,
+			Held:       held,
+			Stack:      string(l.stack),
+		})
+	}
+	return leaks
+}
@@ -0,0 +1,80 @@
+//go:build !ormx_minimal
+
+package database
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// initializeReadReplicas opens a connection to every enabled read replica.
+// Replica settings left empty inherit the primary configuration.
+func (cm *ConnectionManager) initializeReadReplicas() error {
+	for i, replicaConfig := range cm.config.ReadReplicas {
+		if !replicaConfig.Enabled {
+			continue
+		}
+
+		connConfig := *cm.config
+		connConfig.Host = replicaConfig.Host
+		connConfig.Port = replicaConfig.Port
+		if replicaConfig.Database != "" {
+			connConfig.Database = replicaConfig.Database
+		}
+		if replicaConfig.Username != "" {
+			connConfig.Username = replicaConfig.Username
+		}
+		if replicaConfig.Password != "" {
+			connConfig.Password = replicaConfig.Password
+		}
+		if replicaConfig.SSLMode != "" {
+			connConfig.SSLMode = replicaConfig.SSLMode
+		}
+		if replicaConfig.MaxConnections > 0 {
+			connConfig.MaxConnections = replicaConfig.MaxConnections
+		}
+		if replicaConfig.MaxIdleConnections > 0 {
+			connConfig.MaxIdleConnections = replicaConfig.MaxIdleConnections
+		}
+		if replicaConfig.MaxLifetime > 0 {
+			connConfig.MaxLifetime = replicaConfig.MaxLifetime
+		}
+		if replicaConfig.IdleTimeout > 0 {
+			connConfig.IdleTimeout = replicaConfig.IdleTimeout
+		}
+
+		db, err := openConnection(connConfig)
+		if err != nil {
+			cm.closeReplicas()
+			return errors.Wrapf(err, "failed to create read replica %d connection", i)
+		}
+
+		sqlDB, err := db.DB()
+		if err != nil {
+			cm.closeReplicas()
+			return errors.Wrapf(err, "failed to get underlying sql.DB of read replica %d", i)
+		}
+
+		configurePool(sqlDB, connConfig)
+
+		cm.replicas = append(cm.replicas, &replica{
+			name:   fmt.Sprintf("read-replica-%d", i),
+			db:     db,
+			pool:   sqlDB,
+			config: replicaConfig,
+		})
+		cm.readDBs = append(cm.readDBs, db)
+	}
+
+	return nil
+}
+
+// closeReplicas closes the replica connections opened so far
+func (cm *ConnectionManager) closeReplicas() {
+	for _, r := range cm.replicas {
+		_ = r.pool.Close()
+	}
+	cm.replicas = nil
+	cm.readDBs = nil
+}
@@ -0,0 +1,9 @@
+//go:build ormx_minimal
+
+package database
+
+// initializeReadReplicas is a no-op in builds tagged ormx_minimal, where all
+// reads are served by the primary connection
+func (cm *ConnectionManager) initializeReadReplicas() error {
+	return nil
+}
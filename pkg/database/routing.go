@@ -0,0 +1,169 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	stderrors "errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/config"
+	"gorm.io/gorm"
+)
+
+// replica represents an open read replica connection and its routing state
+type replica struct {
+	name      string
+	db        *gorm.DB
+	pool      *sql.DB
+	config    config.ReadReplicaConfig
+	current   int
+	downUntil time.Time
+}
+
+// weight returns the load balancing weight of the replica
+func (r *replica) weight() int {
+	if r.config.Weight <= 0 {
+		return 1
+	}
+	return r.config.Weight
+}
+
+// nextReplica picks an available replica using smooth weighted round-robin,
+// or returns nil when none is available
+func (cm *ConnectionManager) nextReplica() *replica {
+	cm.routeMu.Lock()
+	defer cm.routeMu.Unlock()
+
+	now := time.Now()
+	total := 0
+	var best *replica
+	for _, r := range cm.replicas {
+		if now.Before(r.downUntil) {
+			continue
+		}
+		r.current += r.weight()
+		total += r.weight()
+		if best == nil || r.current > best.current {
+			best = r
+		}
+	}
+	if best != nil {
+		best.current -= total
+	}
+	return best
+}
+
+// markReplicaDown stops routing reads to r until the health check sees it
+// recover or the configured recovery time elapses
+func (cm *ConnectionManager) markReplicaDown(r *replica) {
+	recovery := cm.config.HealthCheck.RecoveryTime
+	if recovery <= 0 {
+		recovery = time.Minute
+	}
+
+	cm.routeMu.Lock()
+	r.downUntil = time.Now().Add(recovery)
+	cm.routeMu.Unlock()
+}
+
+// markReplicaUp resumes routing reads to r
+func (cm *ConnectionManager) markReplicaUp(r *replica) {
+	cm.routeMu.Lock()
+	r.downUntil = time.Time{}
+	cm.routeMu.Unlock()
+}
+
+// ReadDB returns a session for read-only queries routed to a read replica by
+// weighted round-robin. The session keeps the primary's configuration and
+// callbacks; a query failing because the replica is unreachable is retried on
+// the primary and the replica is taken out of rotation. Without available
+// replicas the primary is used.
+func (cm *ConnectionManager) ReadDB(ctx context.Context) *gorm.DB {
+	primary := cm.GetPrimaryDB()
+	r := cm.nextReplica()
+	if r == nil {
+		return primary.WithContext(ctx)
+	}
+
+	session := primary.WithContext(ctx)
+	session.Statement.ConnPool = &fallbackConnPool{
+		cm:      cm,
+		replica: r,
+		primary: primary.Statement.ConnPool,
+	}
+	return session
+}
+
+// fallbackConnPool sends queries to a replica and retries them on the
+// primary when the replica is unreachable
+type fallbackConnPool struct {
+	cm      *ConnectionManager
+	replica *replica
+	primary gorm.ConnPool
+}
+
+// PrepareContext prepares the statement on the replica, falling back to the primary
+func (p *fallbackConnPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	stmt, err := p.replica.pool.PrepareContext(ctx, query)
+	if err != nil && p.failover(ctx, err) {
+		return p.primary.PrepareContext(ctx, query)
+	}
+	return stmt, err
+}
+
+// ExecContext always runs on the primary, read sessions must not write to replicas
+func (p *fallbackConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return p.primary.ExecContext(ctx, query, args...)
+}
+
+// QueryContext runs the query on the replica, falling back to the primary
+func (p *fallbackConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := p.replica.pool.QueryContext(ctx, query, args...)
+	if err != nil && p.failover(ctx, err) {
+		return p.primary.QueryContext(ctx, query, args...)
+	}
+	return rows, err
+}
+
+// QueryRowContext runs the query on the replica, falling back to the primary
+func (p *fallbackConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	row := p.replica.pool.QueryRowContext(ctx, query, args...)
+	if err := row.Err(); err != nil && p.failover(ctx, err) {
+		return p.primary.QueryRowContext(ctx, query, args...)
+	}
+	return row
+}
+
+// failover reports whether err means the replica is unreachable, taking it
+// out of rotation if so
+func (p *fallbackConnPool) failover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || !isReplicaFailure(err) {
+		return false
+	}
+	p.cm.markReplicaDown(p.replica)
+	return true
+}
+
+// isReplicaFailure reports whether err is a connection-level failure rather
+// than an error of the query itself
+func isReplicaFailure(err error) bool {
+	if stderrors.Is(err, driver.ErrBadConn) || stderrors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+
+	var netErr net.Error
+	if stderrors.As(err, &netErr) {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, marker := range []string{"database is closed", "connection refused", "connection reset", "broken pipe", "no such host"} {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
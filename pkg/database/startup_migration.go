@@ -0,0 +1,204 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/config"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"gorm.io/gorm"
+)
+
+// ErrProductionMigration is returned when startup migration is enabled in a
+// production environment without Force
+var ErrProductionMigration = errors.New("startup migration refused in production environment")
+
+// TableChange describes the schema changes AutoMigrate applied to one entity's table
+type TableChange struct {
+	Entity         string   `json:"entity"`
+	Table          string   `json:"table"`
+	CreatedTable   bool     `json:"created_table"`
+	AddedColumns   []string `json:"added_columns,omitempty"`
+	AlteredColumns []string `json:"altered_columns,omitempty"`
+	AddedIndexes   []string `json:"added_indexes,omitempty"`
+}
+
+// Changed reports whether the table was modified
+func (c TableChange) Changed() bool {
+	return c.CreatedTable || len(c.AddedColumns) > 0 || len(c.AlteredColumns) > 0 || len(c.AddedIndexes) > 0
+}
+
+// StartupMigrationReport represents the outcome of a startup migration
+type StartupMigrationReport struct {
+	Environment string        `json:"environment"`
+	Changes     []TableChange `json:"changes"`
+}
+
+// tableSnapshot captures the columns (with database types) and indexes of a table
+type tableSnapshot struct {
+	exists  bool
+	columns map[string]string
+	indexes map[string]bool
+}
+
+// RunStartupMigration AutoMigrates the models allowlisted in cfg.Entities,
+// matched by type or table name. It does nothing when cfg is nil or disabled
+// and refuses to run in production unless cfg.Force is set. Every allowlisted
+// entity must be among models, so a typo fails the boot instead of silently
+// skipping a table.
+func RunStartupMigration(ctx context.Context, db *gorm.DB, cfg *config.StartupMigrationConfig, logger logging.Logger, models ...interface{}) (*StartupMigrationReport, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+	if db == nil {
+		return nil, errors.New("database cannot be nil")
+	}
+
+	if cfg.IsProduction() {
+		if !cfg.Force {
+			if logger != nil {
+				logger.Error(ctx, "Refusing startup migration in production",
+					logging.String("environment", cfg.Environment))
+			}
+			return nil, errors.Wrapf(ErrProductionMigration, "environment %q", cfg.Environment)
+		}
+		if logger != nil {
+			logger.Warn(ctx, "Forcing startup migration in production",
+				logging.String("environment", cfg.Environment))
+		}
+	}
+
+	byName := make(map[string]interface{}, len(models)*2)
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse model %T", model)
+		}
+		byName[stmt.Schema.Name] = model
+		byName[stmt.Schema.Table] = model
+	}
+
+	report := &StartupMigrationReport{Environment: cfg.Environment}
+	migrated := make(map[interface{}]bool, len(cfg.Entities))
+	for _, entity := range cfg.Entities {
+		model, ok := byName[entity]
+		if !ok {
+			return report, fmt.Errorf("startup migration entity %q is not a registered model", entity)
+		}
+		if migrated[model] {
+			continue
+		}
+		migrated[model] = true
+
+		change, err := migrateEntity(ctx, db, entity, model)
+		if err != nil {
+			return report, err
+		}
+		report.Changes = append(report.Changes, change)
+
+		if logger == nil {
+			continue
+		}
+		if change.Changed() {
+			logger.Info(ctx, "Startup migration changed table",
+				logging.String("entity", change.Entity),
+				logging.String("table", change.Table),
+				logging.Bool("created_table", change.CreatedTable),
+				logging.Any("added_columns", change.AddedColumns),
+				logging.Any("altered_columns", change.AlteredColumns),
+				logging.Any("added_indexes", change.AddedIndexes))
+		} else {
+			logger.Debug(ctx, "Startup migration left table unchanged",
+				logging.String("entity", change.Entity),
+				logging.String("table", change.Table))
+		}
+	}
+
+	return report, nil
+}
+
+// migrateEntity AutoMigrates model and diffs its table before and after
+func migrateEntity(ctx context.Context, db *gorm.DB, entity string, model interface{}) (TableChange, error) {
+	db = db.WithContext(ctx)
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return TableChange{}, errors.Wrapf(err, "failed to parse model %T", model)
+	}
+	change := TableChange{Entity: entity, Table: stmt.Schema.Table}
+
+	before, err := snapshotTable(db, model)
+	if err != nil {
+		return change, errors.Wrapf(err, "failed to inspect table %s", change.Table)
+	}
+	if err := db.AutoMigrate(model); err != nil {
+		return change, errors.Wrapf(err, "failed to migrate entity %s", entity)
+	}
+	after, err := snapshotTable(db, model)
+	if err != nil {
+		return change, errors.Wrapf(err, "failed to inspect table %s", change.Table)
+	}
+
+	change.CreatedTable = !before.exists && after.exists
+	if change.CreatedTable {
+		return change, nil
+	}
+
+	for column, newType := range after.columns {
+		oldType, existed := before.columns[column]
+		switch {
+		case !existed:
+			change.AddedColumns = append(change.AddedColumns, column)
+		case oldType != newType:
+			change.AlteredColumns = append(change.AlteredColumns, fmt.Sprintf("%s: %s -> %s", column, oldType, newType))
+		}
+	}
+	for index := range after.indexes {
+		if !before.indexes[index] {
+			change.AddedIndexes = append(change.AddedIndexes, index)
+		}
+	}
+	sort.Strings(change.AddedColumns)
+	sort.Strings(change.AlteredColumns)
+	sort.Strings(change.AddedIndexes)
+
+	return change, nil
+}
+
+// snapshotTable captures the current schema of model's table
+func snapshotTable(db *gorm.DB, model interface{}) (*tableSnapshot, error) {
+	migrator := db.Migrator()
+	snapshot := &tableSnapshot{
+		columns: make(map[string]string),
+		indexes: make(map[string]bool),
+	}
+	if !migrator.HasTable(model) {
+		return snapshot, nil
+	}
+	snapshot.exists = true
+
+	columnTypes, err := migrator.ColumnTypes(model)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range columnTypes {
+		snapshot.columns[column.Name()] = column.DatabaseTypeName()
+	}
+
+	// Not every dialect can list indexes; the diff then omits them
+	if indexes, err := migrator.GetIndexes(model); err == nil {
+		for _, index := range indexes {
+			snapshot.indexes[index.Name()] = true
+		}
+	}
+
+	return snapshot, nil
+}
+
+// RunStartupMigration AutoMigrates the allowlisted models on the primary
+// connection according to the startup_migration configuration
+func (cm *ConnectionManager) RunStartupMigration(ctx context.Context, logger logging.Logger, models ...interface{}) (*StartupMigrationReport, error) {
+	return RunStartupMigration(ctx, cm.GetPrimaryDB(), cm.config.StartupMigration, logger, models...)
+}
@@ -0,0 +1,260 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/observability"
+	"gorm.io/gorm"
+)
+
+// timeoutProbeKey is the statement instance key of the probe following a statement
+const timeoutProbeKey = "ormx:timeout_probe"
+
+// TimeoutObserver is a gorm plugin reporting the statements interrupted by the
+// deadline or cancellation of their context to an observability manager: the
+// stage they died in (connection acquisition, execution or row scanning), the
+// share of the deadline budget they consumed and whether the server stopped
+// executing them (see dialect.CancelDetector).
+//
+// The acquisition stage, including the BEGIN of gorm's default transaction,
+// is told apart when statements run on the connection pool itself. Within
+// transactions, prepared statement sessions and replica routing a connection
+// wait is reported as part of the execute stage. Statements run through Row
+// and Rows hand their rows to the caller and are not observed.
+type TimeoutObserver struct {
+	manager *observability.ObservabilityManager
+}
+
+// NewTimeoutObserver creates a timeout observer recording to manager
+func NewTimeoutObserver(manager *observability.ObservabilityManager) *TimeoutObserver {
+	return &TimeoutObserver{manager: manager}
+}
+
+// Name returns the plugin name
+func (o *TimeoutObserver) Name() string {
+	return "ormx:timeout_observer"
+}
+
+// Initialize registers the observer callbacks around the create, query,
+// update, delete and raw statements of db
+func (o *TimeoutObserver) Initialize(db *gorm.DB) error {
+	if o.manager == nil {
+		return errors.New("observability manager is required")
+	}
+
+	callbacks := db.Callback()
+	// The pool is wrapped around the statement only, the default transaction
+	// must begin and commit on the original pool
+	err := stderrors.Join(
+		callbacks.Create().Before("gorm:begin_transaction").Register("ormx:timeout_start_create", o.start("create")),
+		callbacks.Create().Before("gorm:create").After("gorm:save_before_associations").Register("ormx:timeout_watch_create", o.watch("create")),
+		callbacks.Create().After("gorm:create").Before("gorm:save_after_associations").Register("ormx:timeout_report_create", o.report),
+		callbacks.Query().Before("gorm:query").Register("ormx:timeout_watch_query", o.watch("query")),
+		callbacks.Query().After("gorm:query").Before("gorm:preload").Register("ormx:timeout_report_query", o.report),
+		callbacks.Update().Before("gorm:begin_transaction").Register("ormx:timeout_start_update", o.start("update")),
+		callbacks.Update().Before("gorm:update").After("gorm:save_before_associations").Register("ormx:timeout_watch_update", o.watch("update")),
+		callbacks.Update().After("gorm:update").Before("gorm:save_after_associations").Register("ormx:timeout_report_update", o.report),
+		callbacks.Delete().Before("gorm:begin_transaction").Register("ormx:timeout_start_delete", o.start("delete")),
+		callbacks.Delete().Before("gorm:delete").After("gorm:delete_before_associations").Register("ormx:timeout_watch_delete", o.watch("delete")),
+		callbacks.Delete().After("gorm:delete").Before("gorm:after_delete").Register("ormx:timeout_report_delete", o.report),
+		callbacks.Raw().Before("gorm:raw").Register("ormx:timeout_watch_raw", o.watch("raw")),
+		callbacks.Raw().After("gorm:raw").Register("ormx:timeout_report_raw", o.report),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to register timeout callbacks")
+	}
+	return nil
+}
+
+// ObserveTimeouts reports the statements of the primary and read replica
+// connections interrupted by their context to manager (see TimeoutObserver)
+func (cm *ConnectionManager) ObserveTimeouts(manager *observability.ObservabilityManager) error {
+	observer := NewTimeoutObserver(manager)
+	if err := cm.GetPrimaryDB().Use(observer); err != nil {
+		return errors.Wrap(err, "failed to observe primary timeouts")
+	}
+	for i, readDB := range cm.GetAllReadDBs() {
+		if err := readDB.Use(observer); err != nil {
+			return errors.Wrapf(err, "failed to observe read replica %d timeouts", i)
+		}
+	}
+	return nil
+}
+
+// timeoutProbe follows a statement through its stages
+type timeoutProbe struct {
+	operation string
+	started   time.Time
+	budget    time.Duration
+	stage     observability.QueryStage
+	pool      *timeoutConnPool
+	release   []func()
+	done      bool
+}
+
+// start begins following a statement before its default transaction starts,
+// so the wait for the connection opening the transaction is observed
+func (o *TimeoutObserver) start(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if db.Error != nil || ctx == nil || ctx.Done() == nil {
+			return
+		}
+
+		probe := &timeoutProbe{
+			operation: operation,
+			started:   time.Now(),
+			stage:     observability.QueryStageAcquire,
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			probe.budget = deadline.Sub(probe.started)
+		}
+		db.InstanceSet(timeoutProbeKey, probe)
+	}
+}
+
+// watch wraps the connection pool of the statement to follow its stages
+func (o *TimeoutObserver) watch(operation string) func(*gorm.DB) {
+	begin := o.start(operation)
+	return func(db *gorm.DB) {
+		// Statements of reused sessions still hold the probe of their previous run
+		if value, ok := db.InstanceGet(timeoutProbeKey); !ok || value.(*timeoutProbe).done {
+			begin(db)
+		}
+		value, ok := db.InstanceGet(timeoutProbeKey)
+		if !ok || db.Error != nil {
+			return
+		}
+
+		probe := value.(*timeoutProbe)
+		if probe.done {
+			return
+		}
+		probe.pool = &timeoutConnPool{ConnPool: db.Statement.ConnPool, probe: probe}
+		db.Statement.ConnPool = probe.pool
+	}
+}
+
+// report restores the connection pool of the statement and records it when
+// its context interrupted it
+func (o *TimeoutObserver) report(db *gorm.DB) {
+	value, ok := db.InstanceGet(timeoutProbeKey)
+	if !ok {
+		return
+	}
+	probe := value.(*timeoutProbe)
+	if probe.done {
+		return
+	}
+	probe.done = true
+	if probe.pool != nil && db.Statement.ConnPool == probe.pool {
+		db.Statement.ConnPool = probe.pool.ConnPool
+	}
+	for _, release := range probe.release {
+		release()
+	}
+	probe.release = nil
+
+	ctx := db.Statement.Context
+	cause, interrupted := interruptionCause(ctx, db.Error)
+	if !interrupted {
+		return
+	}
+
+	qi := observability.QueryInterruption{
+		Operation: probe.operation,
+		Table:     db.Statement.Table,
+		Query:     db.Statement.SQL.String(),
+		Stage:     probe.stage,
+		Cause:     cause,
+		Budget:    probe.budget,
+		Elapsed:   time.Since(probe.started),
+		Err:       db.Error,
+	}
+	if qi.Stage != observability.QueryStageAcquire {
+		if detector, ok := dialect.For(db).(dialect.CancelDetector); ok {
+			qi.ServerCancel = detector.ServerCancelled(db.Error)
+		}
+	}
+	o.manager.RecordQueryInterruption(ctx, qi)
+}
+
+// interruptionCause reports whether err comes from the interruption of the
+// statement by ctx, and why
+func interruptionCause(ctx context.Context, err error) (observability.InterruptionCause, bool) {
+	if err == nil {
+		return "", false
+	}
+	if ctx != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	switch {
+	case stderrors.Is(err, context.DeadlineExceeded):
+		return observability.InterruptionTimeout, true
+	case stderrors.Is(err, context.Canceled):
+		return observability.InterruptionCanceled, true
+	}
+	return "", false
+}
+
+// timeoutConnPool moves the probe of a statement through its stages
+type timeoutConnPool struct {
+	gorm.ConnPool
+	probe *timeoutProbe
+}
+
+// acquire takes a connection from the pool when the statement runs on the
+// connection pool itself, so the wait is observed apart from the execution
+func (p *timeoutConnPool) acquire(ctx context.Context) (gorm.ConnPool, func(), error) {
+	db, ok := p.ConnPool.(*sql.DB)
+	if !ok {
+		p.probe.stage = observability.QueryStageExecute
+		return p.ConnPool, func() {}, nil
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.probe.stage = observability.QueryStageExecute
+	return conn, func() { _ = conn.Close() }, nil
+}
+
+// ExecContext executes the statement on an acquired connection
+func (p *timeoutConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	pool, release, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return pool.ExecContext(ctx, query, args...)
+}
+
+// QueryContext runs the query on an acquired connection, which is released
+// once the statement completed and its rows are closed
+func (p *timeoutConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	pool, release, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := pool.QueryContext(ctx, query, args...)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	p.probe.release = append(p.probe.release, release)
+	p.probe.stage = observability.QueryStageScan
+	return rows, nil
+}
+
+// QueryRowContext runs the query on the wrapped pool, its errors surface when
+// the row is scanned
+func (p *timeoutConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	p.probe.stage = observability.QueryStageExecute
+	return p.ConnPool.QueryRowContext(ctx, query, args...)
+}
@@ -0,0 +1,760 @@
+package dialect
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Dialect describes the SQL differences between supported database engines
+type Dialect interface {
+	// Name returns the dialect name as reported by gorm's Dialector
+	Name() string
+	// Quote quotes an identifier
+	Quote(identifier string) string
+	// Placeholder returns the bind variable for the n-th (1-based) parameter
+	Placeholder(n int) string
+	// Paginate renders the pagination clause for the given limit and offset
+	Paginate(limit, offset int) string
+	// ForUpdate returns the row locking strategy for SELECT ... FOR UPDATE semantics
+	ForUpdate() Locking
+	// Upsert renders an insert-or-update statement for a single row
+	Upsert(table string, columns, conflictColumns, updateColumns []string) string
+	// TruncateTime renders an expression truncating a timestamp expression to the
+	// start of its unit; weeks start on Monday
+	TruncateTime(expr string, unit TimeUnit) string
+	// Random returns the expression used to order rows randomly
+	Random() string
+}
+
+// TimeUnit represents a time bucket granularity
+type TimeUnit string
+
+const (
+	TimeUnitHour  TimeUnit = "hour"
+	TimeUnitDay   TimeUnit = "day"
+	TimeUnitWeek  TimeUnit = "week"
+	TimeUnitMonth TimeUnit = "month"
+)
+
+// Valid reports whether the unit is supported
+func (u TimeUnit) Valid() bool {
+	switch u {
+	case TimeUnitHour, TimeUnitDay, TimeUnitWeek, TimeUnitMonth:
+		return true
+	}
+	return false
+}
+
+// FollowerReader is implemented by dialects that can serve stale-tolerant reads
+// from the nearest replica (follower reads)
+type FollowerReader interface {
+	// AsOfSystemTime renders the historical read clause appended to a table reference.
+	// A zero staleness selects the most recent timestamp safe for follower reads.
+	AsOfSystemTime(staleness time.Duration) string
+}
+
+// PercentileAggregator is implemented by dialects with an ordered-set
+// percentile_cont aggregate
+type PercentileAggregator interface {
+	// PercentileCont renders the continuous percentile (0..1) of expr as an aggregate
+	PercentileCont(expr string, percentile float64) string
+}
+
+// TableSampler is implemented by dialects that can sample a percentage of a
+// table's rows without scanning it
+type TableSampler interface {
+	// TableSample renders the sampling clause appended to a table reference,
+	// reporting false when the engine does not support it
+	TableSample(percent float64) (string, bool)
+}
+
+// RowEstimator is implemented by dialects exposing planner row count
+// statistics, which are cheaper than COUNT(*) on large tables
+type RowEstimator interface {
+	// EstimateRows renders a query returning the estimated row count of the
+	// table bound to its single "?" parameter
+	EstimateRows() string
+}
+
+// SkipLocker is implemented by dialects that can lock rows while skipping
+// those already locked by other transactions, so concurrent workers claim
+// disjoint rows instead of waiting on each other
+type SkipLocker interface {
+	// ForUpdateSkipLocked returns the row locking strategy for SELECT ... FOR
+	// UPDATE SKIP LOCKED semantics
+	ForUpdateSkipLocked() Locking
+}
+
+// ConstraintDeferrer is implemented by dialects that can defer the checking of
+// DEFERRABLE constraints to the end of the current transaction
+type ConstraintDeferrer interface {
+	// DeferConstraints renders the statement deferring all deferrable
+	// constraints, reporting false when the engine does not support it
+	DeferConstraints() (string, bool)
+}
+
+// IndexRenderer is implemented by dialects supporting partial (filtered) or
+// covering indexes
+type IndexRenderer interface {
+	// IndexInclude renders the clause storing non-key columns in an index,
+	// reporting false when the engine does not support it
+	IndexInclude(columns []string) (string, bool)
+	// IndexWhere renders the predicate clause of a partial index, reporting
+	// false when the engine does not support it
+	IndexWhere(predicate string) (string, bool)
+}
+
+// IndexMethodRenderer is implemented by dialects supporting index access
+// methods other than B-tree, such as GIN and GiST indexes
+type IndexMethodRenderer interface {
+	// IndexMethod renders the clause selecting the access method of an index,
+	// placed before its columns, reporting false when the engine does not
+	// support the method
+	IndexMethod(method string) (string, bool)
+}
+
+// ConcurrentIndexer is implemented by dialects that can build an index
+// without blocking writes to its table
+type ConcurrentIndexer interface {
+	// IndexConcurrently returns the keyword following CREATE INDEX that builds
+	// the index concurrently; such statements cannot run in a transaction
+	IndexConcurrently() string
+}
+
+// IndexInspector is implemented by dialects that can read back the definition
+// (CREATE INDEX statement) of an existing index
+type IndexInspector interface {
+	// IndexDefinition renders a query returning the definition of the index
+	// named by its second "?" parameter on the table named by the first
+	IndexDefinition() string
+}
+
+// PlanEstimator is implemented by dialects whose query planner reports the
+// estimated number of rows returned by a statement
+type PlanEstimator interface {
+	// ExplainRows renders the statement explaining query, keeping its bind variables
+	ExplainRows(query string) string
+	// PlanRows reads the estimated row count from the rows returned by the
+	// ExplainRows statement, reporting false when the plan has none
+	PlanRows(plan []map[string]interface{}) (int64, bool)
+}
+
+// QueryPlanner is implemented by dialects that can show the plan of a
+// statement without running it
+type QueryPlanner interface {
+	// ExplainPlan renders the statement returning the plan of query as rows of text
+	ExplainPlan(query string) string
+}
+
+// TableStatsReader is implemented by dialects exposing the storage and
+// maintenance statistics of tables
+type TableStatsReader interface {
+	// TableStats renders a query returning a single row with the columns
+	// row_estimate, table_bytes, index_bytes, dead_tuples, last_vacuum and
+	// last_analyze of the table named by its single "?" parameter, reporting
+	// false when the engine does not expose them. Columns the engine does not
+	// track are NULL.
+	TableStats() (string, bool)
+	// IndexStats renders a query returning the index_name and index_bytes of
+	// every index of the table named by its single "?" parameter
+	IndexStats() string
+}
+
+// CancelDetector is implemented by dialects whose errors tell whether an
+// interrupted statement was cancelled on the server, as opposed to the client
+// abandoning it while the server keeps executing it
+type CancelDetector interface {
+	// ServerCancelled reports whether err shows that the server received the
+	// cancellation (or hit its own statement timeout) and stopped the statement
+	ServerCancelled(err error) bool
+}
+
+// Locking describes how a dialect expresses row locks
+type Locking struct {
+	// TableHint is appended to the table reference (e.g. "WITH (UPDLOCK, ROWLOCK)")
+	TableHint string
+	// Suffix is appended to the statement (e.g. "FOR UPDATE")
+	Suffix string
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Dialect{}
+)
+
+func init() {
+	Register(Postgres{})
+	Register(MySQL{})
+	Register(SQLite{})
+	Register(SQLServer{})
+	Register(Oracle{})
+	Register(CockroachDB{})
+}
+
+// Register registers a dialect under its name, replacing any existing one
+func Register(d Dialect) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[d.Name()] = d
+}
+
+// Get returns the dialect registered under name
+func Get(name string) (Dialect, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	d, ok := registry[name]
+	return d, ok
+}
+
+// Names returns the names of all registered dialects
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// For returns the dialect matching the gorm connection, falling back to Postgres
+func For(db *gorm.DB) Dialect {
+	if db == nil || db.Dialector == nil {
+		return Postgres{}
+	}
+	if d, ok := Get(db.Dialector.Name()); ok {
+		return d
+	}
+	return Postgres{}
+}
+
+// Postgres implements the PostgreSQL dialect
+type Postgres struct{}
+
+// Name returns the dialect name
+func (Postgres) Name() string { return "postgres" }
+
+// Quote quotes an identifier
+func (Postgres) Quote(identifier string) string { return quoteWith(identifier, `"`, `"`) }
+
+// Placeholder returns the bind variable for the n-th parameter
+func (Postgres) Placeholder(n int) string { return fmt.Sprintf("$%d", n) }
+
+// Paginate renders LIMIT/OFFSET
+func (Postgres) Paginate(limit, offset int) string { return limitOffset(limit, offset) }
+
+// ForUpdate returns FOR UPDATE locking
+func (Postgres) ForUpdate() Locking { return Locking{Suffix: "FOR UPDATE"} }
+
+// ForUpdateSkipLocked returns FOR UPDATE SKIP LOCKED locking
+func (Postgres) ForUpdateSkipLocked() Locking { return Locking{Suffix: "FOR UPDATE SKIP LOCKED"} }
+
+// DeferConstraints renders SET CONSTRAINTS ALL DEFERRED
+func (Postgres) DeferConstraints() (string, bool) { return "SET CONSTRAINTS ALL DEFERRED", true }
+
+// Upsert renders INSERT ... ON CONFLICT
+func (d Postgres) Upsert(table string, columns, conflictColumns, updateColumns []string) string {
+	return onConflictUpsert(d, table, columns, conflictColumns, updateColumns)
+}
+
+// TruncateTime renders date_trunc
+func (Postgres) TruncateTime(expr string, unit TimeUnit) string {
+	return fmt.Sprintf("date_trunc('%s', %s)", unit, expr)
+}
+
+// PercentileCont renders percentile_cont ... WITHIN GROUP
+func (Postgres) PercentileCont(expr string, percentile float64) string {
+	return percentileCont(expr, percentile)
+}
+
+// Random returns random()
+func (Postgres) Random() string { return "random()" }
+
+// TableSample renders TABLESAMPLE BERNOULLI
+func (Postgres) TableSample(percent float64) (string, bool) {
+	return fmt.Sprintf("TABLESAMPLE BERNOULLI (%s)", formatPercent(percent)), true
+}
+
+// IndexInclude renders INCLUDE (PostgreSQL 11 and later)
+func (d Postgres) IndexInclude(columns []string) (string, bool) {
+	return fmt.Sprintf("INCLUDE (%s)", quoteList(d, columns)), true
+}
+
+// IndexWhere renders a partial index predicate
+func (Postgres) IndexWhere(predicate string) (string, bool) { return "WHERE " + predicate, true }
+
+// IndexMethod renders USING with any access method, built-in (btree, hash,
+// gist, spgist, gin, brin) or provided by an extension
+func (Postgres) IndexMethod(method string) (string, bool) {
+	if !indexMethodName.MatchString(method) {
+		return "", false
+	}
+	return "USING " + strings.ToLower(method), true
+}
+
+// IndexConcurrently returns CONCURRENTLY
+func (Postgres) IndexConcurrently() string { return "CONCURRENTLY" }
+
+// IndexDefinition reads indexdef from pg_indexes
+func (Postgres) IndexDefinition() string {
+	return "SELECT indexdef FROM pg_indexes WHERE schemaname = current_schema() AND tablename = ? AND indexname = ?"
+}
+
+// EstimateRows reads reltuples from pg_class
+func (Postgres) EstimateRows() string {
+	return "SELECT reltuples::bigint FROM pg_class WHERE oid = to_regclass(?)"
+}
+
+// ServerCancelled reports query_canceled (SQLSTATE 57014), returned once the
+// backend processed the cancel request or hit statement_timeout
+func (Postgres) ServerCancelled(err error) bool {
+	return errorContains(err, "57014", "canceling statement due to", "query execution canceled")
+}
+
+// CockroachDB implements the CockroachDB dialect on top of the Postgres wire protocol
+type CockroachDB struct {
+	Postgres
+}
+
+// Name returns the dialect name
+func (CockroachDB) Name() string { return "cockroachdb" }
+
+// Upsert renders UPSERT INTO when the conflict target is the primary key (no
+// conflict columns given) and INSERT ... ON CONFLICT otherwise
+func (d CockroachDB) Upsert(table string, columns, conflictColumns, updateColumns []string) string {
+	if len(conflictColumns) == 0 && len(updateColumns) > 0 {
+		return fmt.Sprintf("UPSERT INTO %s (%s) VALUES (%s)",
+			d.Quote(table), quoteList(d, columns), placeholders(d, len(columns)))
+	}
+	return onConflictUpsert(d, table, columns, conflictColumns, updateColumns)
+}
+
+// AsOfSystemTime renders an AS OF SYSTEM TIME clause for follower reads
+func (CockroachDB) AsOfSystemTime(staleness time.Duration) string {
+	if staleness <= 0 {
+		return "AS OF SYSTEM TIME follower_read_timestamp()"
+	}
+	return fmt.Sprintf("AS OF SYSTEM TIME '-%s'", staleness)
+}
+
+// TableSample reports false since CockroachDB has no TABLESAMPLE support
+func (CockroachDB) TableSample(percent float64) (string, bool) { return "", false }
+
+// IndexInclude renders STORING, CockroachDB's covering index clause
+func (d CockroachDB) IndexInclude(columns []string) (string, bool) {
+	return fmt.Sprintf("STORING (%s)", quoteList(d, columns)), true
+}
+
+// IndexMethod renders USING for the btree, gin (inverted) and gist (spatial)
+// methods; USING HASH declares hash-sharded indexes on CockroachDB
+func (CockroachDB) IndexMethod(method string) (string, bool) {
+	switch strings.ToLower(method) {
+	case "btree", "gin", "gist":
+		return "USING " + strings.ToLower(method), true
+	}
+	return "", false
+}
+
+// DeferConstraints reports false since CockroachDB has no SET CONSTRAINTS support
+func (CockroachDB) DeferConstraints() (string, bool) { return "", false }
+
+// MySQL implements the MySQL dialect
+type MySQL struct{}
+
+// Name returns the dialect name
+func (MySQL) Name() string { return "mysql" }
+
+// Quote quotes an identifier
+func (MySQL) Quote(identifier string) string { return quoteWith(identifier, "`", "`") }
+
+// Placeholder returns the bind variable for the n-th parameter
+func (MySQL) Placeholder(n int) string { return "?" }
+
+// Paginate renders LIMIT/OFFSET
+func (MySQL) Paginate(limit, offset int) string { return limitOffset(limit, offset) }
+
+// ForUpdate returns FOR UPDATE locking
+func (MySQL) ForUpdate() Locking { return Locking{Suffix: "FOR UPDATE"} }
+
+// ForUpdateSkipLocked returns FOR UPDATE SKIP LOCKED locking (MySQL 8.0 and later)
+func (MySQL) ForUpdateSkipLocked() Locking { return Locking{Suffix: "FOR UPDATE SKIP LOCKED"} }
+
+// Upsert renders INSERT ... ON DUPLICATE KEY UPDATE (or INSERT IGNORE when nothing is updated)
+func (d MySQL) Upsert(table string, columns, conflictColumns, updateColumns []string) string {
+	if len(updateColumns) == 0 {
+		return fmt.Sprintf("INSERT IGNORE INTO %s (%s) VALUES (%s)",
+			d.Quote(table), quoteList(d, columns), placeholders(d, len(columns)))
+	}
+
+	sets := make([]string, len(updateColumns))
+	for i, col := range updateColumns {
+		sets[i] = fmt.Sprintf("%s = VALUES(%s)", d.Quote(col), d.Quote(col))
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON DUPLICATE KEY UPDATE %s",
+		d.Quote(table), quoteList(d, columns), placeholders(d, len(columns)), strings.Join(sets, ", "))
+}
+
+// TruncateTime renders DATE_FORMAT, shifting to the preceding Monday for weeks
+func (MySQL) TruncateTime(expr string, unit TimeUnit) string {
+	switch unit {
+	case TimeUnitHour:
+		return fmt.Sprintf("DATE_FORMAT(%s, '%%Y-%%m-%%d %%H:00:00')", expr)
+	case TimeUnitWeek:
+		return fmt.Sprintf("DATE_FORMAT(DATE_SUB(%s, INTERVAL WEEKDAY(%s) DAY), '%%Y-%%m-%%d 00:00:00')", expr, expr)
+	case TimeUnitMonth:
+		return fmt.Sprintf("DATE_FORMAT(%s, '%%Y-%%m-01 00:00:00')", expr)
+	default:
+		return fmt.Sprintf("DATE_FORMAT(%s, '%%Y-%%m-%%d 00:00:00')", expr)
+	}
+}
+
+// Random returns RAND()
+func (MySQL) Random() string { return "RAND()" }
+
+// EstimateRows reads TABLE_ROWS from information_schema
+func (MySQL) EstimateRows() string {
+	return "SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?"
+}
+
+// ServerCancelled reports ER_QUERY_INTERRUPTED (1317) and the max_execution_time
+// error (3024). The driver closes the connection on context cancellation
+// instead of sending KILL QUERY, so client-side cancellations report false.
+func (MySQL) ServerCancelled(err error) bool {
+	return errorContains(err, "Error 1317", "Error 3024", "query execution was interrupted")
+}
+
+// SQLite implements the SQLite dialect
+type SQLite struct{}
+
+// Name returns the dialect name
+func (SQLite) Name() string { return "sqlite" }
+
+// Quote quotes an identifier
+func (SQLite) Quote(identifier string) string { return quoteWith(identifier, "`", "`") }
+
+// Placeholder returns the bind variable for the n-th parameter
+func (SQLite) Placeholder(n int) string { return "?" }
+
+// Paginate renders LIMIT/OFFSET
+func (SQLite) Paginate(limit, offset int) string {
+	if limit <= 0 && offset > 0 {
+		// SQLite requires a LIMIT before OFFSET
+		return fmt.Sprintf("LIMIT -1 OFFSET %d", offset)
+	}
+	return limitOffset(limit, offset)
+}
+
+// ForUpdate returns no locking since SQLite locks the whole database on write
+func (SQLite) ForUpdate() Locking { return Locking{} }
+
+// Upsert renders INSERT ... ON CONFLICT
+func (d SQLite) Upsert(table string, columns, conflictColumns, updateColumns []string) string {
+	return onConflictUpsert(d, table, columns, conflictColumns, updateColumns)
+}
+
+// TruncateTime renders strftime, shifting to the preceding Monday for weeks
+func (SQLite) TruncateTime(expr string, unit TimeUnit) string {
+	switch unit {
+	case TimeUnitHour:
+		return fmt.Sprintf("strftime('%%Y-%%m-%%d %%H:00:00', %s)", expr)
+	case TimeUnitWeek:
+		return fmt.Sprintf("strftime('%%Y-%%m-%%d 00:00:00', %s, 'weekday 0', '-6 days')", expr)
+	case TimeUnitMonth:
+		return fmt.Sprintf("strftime('%%Y-%%m-01 00:00:00', %s)", expr)
+	default:
+		return fmt.Sprintf("strftime('%%Y-%%m-%%d 00:00:00', %s)", expr)
+	}
+}
+
+// Random returns random()
+func (SQLite) Random() string { return "random()" }
+
+// IndexInclude reports false since SQLite has no covering index clause
+func (SQLite) IndexInclude(columns []string) (string, bool) { return "", false }
+
+// IndexWhere renders a partial index predicate
+func (SQLite) IndexWhere(predicate string) (string, bool) { return "WHERE " + predicate, true }
+
+// IndexDefinition reads the index statement from sqlite_master
+func (SQLite) IndexDefinition() string {
+	return "SELECT sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND name = ?"
+}
+
+// ServerCancelled reports true for any interruption error: the engine runs in
+// process and the driver interrupts the statement as soon as the context is done
+func (SQLite) ServerCancelled(err error) bool {
+	return err != nil
+}
+
+// SQLServer implements the Microsoft SQL Server dialect
+type SQLServer struct{}
+
+// Name returns the dialect name
+func (SQLServer) Name() string { return "sqlserver" }
+
+// Quote quotes an identifier
+func (SQLServer) Quote(identifier string) string { return quoteWith(identifier, "[", "]") }
+
+// Placeholder returns the bind variable for the n-th parameter
+func (SQLServer) Placeholder(n int) string { return fmt.Sprintf("@p%d", n) }
+
+// Paginate renders OFFSET/FETCH; SQL Server requires an ORDER BY clause in the statement
+func (SQLServer) Paginate(limit, offset int) string { return offsetFetch(limit, offset) }
+
+// ForUpdate returns UPDLOCK/ROWLOCK table hints
+func (SQLServer) ForUpdate() Locking { return Locking{TableHint: "WITH (UPDLOCK, ROWLOCK)"} }
+
+// ForUpdateSkipLocked returns UPDLOCK/ROWLOCK/READPAST table hints
+func (SQLServer) ForUpdateSkipLocked() Locking {
+	return Locking{TableHint: "WITH (UPDLOCK, ROWLOCK, READPAST)"}
+}
+
+// Upsert renders a MERGE statement
+func (d SQLServer) Upsert(table string, columns, conflictColumns, updateColumns []string) string {
+	source := fmt.Sprintf("(VALUES (%s)) AS source (%s)", placeholders(d, len(columns)), quoteList(d, columns))
+	return mergeUpsert(d, d.Quote(table)+" WITH (HOLDLOCK) AS target", source, columns, conflictColumns, updateColumns) + ";"
+}
+
+// TruncateTime renders DATEADD/DATEDIFF arithmetic from the 1900-01-01 epoch (a Monday)
+func (SQLServer) TruncateTime(expr string, unit TimeUnit) string {
+	switch unit {
+	case TimeUnitHour:
+		return fmt.Sprintf("DATEADD(hour, DATEDIFF(hour, 0, %s), 0)", expr)
+	case TimeUnitWeek:
+		return fmt.Sprintf("DATEADD(day, DATEDIFF(day, 0, %s) / 7 * 7, 0)", expr)
+	case TimeUnitMonth:
+		return fmt.Sprintf("DATEADD(month, DATEDIFF(month, 0, %s), 0)", expr)
+	default:
+		return fmt.Sprintf("DATEADD(day, DATEDIFF(day, 0, %s), 0)", expr)
+	}
+}
+
+// Random returns NEWID()
+func (SQLServer) Random() string { return "NEWID()" }
+
+// EstimateRows sums the rows of the heap or clustered index partitions
+func (SQLServer) EstimateRows() string {
+	return "SELECT SUM(rows) FROM sys.partitions WHERE object_id = OBJECT_ID(?) AND index_id IN (0, 1)"
+}
+
+// IndexInclude renders INCLUDE
+func (d SQLServer) IndexInclude(columns []string) (string, bool) {
+	return fmt.Sprintf("INCLUDE (%s)", quoteList(d, columns)), true
+}
+
+// IndexWhere renders a filtered index predicate
+func (SQLServer) IndexWhere(predicate string) (string, bool) { return "WHERE " + predicate, true }
+
+// TableSample renders TABLESAMPLE ... PERCENT
+func (SQLServer) TableSample(percent float64) (string, bool) {
+	return fmt.Sprintf("TABLESAMPLE (%s PERCENT)", formatPercent(percent)), true
+}
+
+// Oracle implements the Oracle dialect
+type Oracle struct{}
+
+// Name returns the dialect name
+func (Oracle) Name() string { return "oracle" }
+
+// Quote quotes an identifier
+func (Oracle) Quote(identifier string) string { return quoteWith(identifier, `"`, `"`) }
+
+// Placeholder returns the bind variable for the n-th parameter
+func (Oracle) Placeholder(n int) string { return fmt.Sprintf(":%d", n) }
+
+// Paginate renders OFFSET/FETCH (Oracle 12c and later)
+func (Oracle) Paginate(limit, offset int) string { return offsetFetch(limit, offset) }
+
+// ForUpdate returns FOR UPDATE locking
+func (Oracle) ForUpdate() Locking { return Locking{Suffix: "FOR UPDATE"} }
+
+// ForUpdateSkipLocked returns FOR UPDATE SKIP LOCKED locking
+func (Oracle) ForUpdateSkipLocked() Locking { return Locking{Suffix: "FOR UPDATE SKIP LOCKED"} }
+
+// DeferConstraints renders SET CONSTRAINTS ALL DEFERRED
+func (Oracle) DeferConstraints() (string, bool) { return "SET CONSTRAINTS ALL DEFERRED", true }
+
+// ServerCancelled reports ORA-01013 (user requested cancel of current operation)
+func (Oracle) ServerCancelled(err error) bool {
+	return errorContains(err, "ORA-01013")
+}
+
+// Upsert renders a MERGE statement selecting the new row from DUAL
+func (d Oracle) Upsert(table string, columns, conflictColumns, updateColumns []string) string {
+	selects := make([]string, len(columns))
+	for i, col := range columns {
+		selects[i] = fmt.Sprintf("%s AS %s", d.Placeholder(i+1), d.Quote(col))
+	}
+	source := fmt.Sprintf("(SELECT %s FROM dual) source", strings.Join(selects, ", "))
+	return mergeUpsert(d, d.Quote(table)+" target", source, columns, conflictColumns, updateColumns)
+}
+
+// PercentileCont renders PERCENTILE_CONT ... WITHIN GROUP
+func (Oracle) PercentileCont(expr string, percentile float64) string {
+	return percentileCont(expr, percentile)
+}
+
+// TruncateTime renders TRUNC with the matching date format model (IW for ISO weeks)
+func (Oracle) TruncateTime(expr string, unit TimeUnit) string {
+	format := map[TimeUnit]string{
+		TimeUnitHour:  "HH24",
+		TimeUnitDay:   "DD",
+		TimeUnitWeek:  "IW",
+		TimeUnitMonth: "MM",
+	}[unit]
+	if format == "" {
+		format = "DD"
+	}
+	return fmt.Sprintf("TRUNC(%s, '%s')", expr, format)
+}
+
+// Random returns DBMS_RANDOM.VALUE
+func (Oracle) Random() string { return "DBMS_RANDOM.VALUE" }
+
+// EstimateRows reads NUM_ROWS from the optimizer statistics
+func (Oracle) EstimateRows() string {
+	return "SELECT NUM_ROWS FROM USER_TABLES WHERE TABLE_NAME = UPPER(?)"
+}
+
+// TableSample renders SAMPLE
+func (Oracle) TableSample(percent float64) (string, bool) {
+	return fmt.Sprintf("SAMPLE (%s)", formatPercent(percent)), true
+}
+
+// quoteWith quotes each dot-separated part of an identifier
+// percentileCont renders the standard ordered-set percentile aggregate
+func percentileCont(expr string, percentile float64) string {
+	return fmt.Sprintf("percentile_cont(%s) WITHIN GROUP (ORDER BY %s)", strconv.FormatFloat(percentile, 'f', -1, 64), expr)
+}
+
+// formatPercent renders a sampling percentage clamped to (0, 100]
+func formatPercent(percent float64) string {
+	if percent > 100 {
+		percent = 100
+	}
+	if percent <= 0 {
+		percent = 0.0001
+	}
+	return strconv.FormatFloat(percent, 'f', -1, 64)
+}
+
+func quoteWith(identifier, open, close string) string {
+	parts := strings.Split(identifier, ".")
+	for i, part := range parts {
+		if part == "*" {
+			continue
+		}
+		escaped := strings.ReplaceAll(part, close, close+close)
+		parts[i] = open + escaped + close
+	}
+	return strings.Join(parts, ".")
+}
+
+// errorContains reports whether the message of err contains one of the
+// markers, ignoring case
+func errorContains(err error, markers ...string) bool {
+	if err == nil {
+		return false
+	}
+	message := strings.ToLower(err.Error())
+	for _, marker := range markers {
+		if strings.Contains(message, strings.ToLower(marker)) {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteList quotes and joins a list of identifiers
+func quoteList(d Dialect, identifiers []string) string {
+	quoted := make([]string, len(identifiers))
+	for i, identifier := range identifiers {
+		quoted[i] = d.Quote(identifier)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// placeholders renders n comma-separated bind variables
+func placeholders(d Dialect, n int) string {
+	vars := make([]string, n)
+	for i := range vars {
+		vars[i] = d.Placeholder(i + 1)
+	}
+	return strings.Join(vars, ", ")
+}
+
+// limitOffset renders a LIMIT/OFFSET clause
+func limitOffset(limit, offset int) string {
+	var parts []string
+	if limit > 0 {
+		parts = append(parts, fmt.Sprintf("LIMIT %d", limit))
+	}
+	if offset > 0 {
+		parts = append(parts, fmt.Sprintf("OFFSET %d", offset))
+	}
+	return strings.Join(parts, " ")
+}
+
+// offsetFetch renders an ANSI OFFSET/FETCH clause
+func offsetFetch(limit, offset int) string {
+	if offset < 0 {
+		offset = 0
+	}
+	clause := fmt.Sprintf("OFFSET %d ROWS", offset)
+	if limit > 0 {
+		clause += fmt.Sprintf(" FETCH NEXT %d ROWS ONLY", limit)
+	}
+	return clause
+}
+
+// onConflictUpsert renders INSERT ... ON CONFLICT for Postgres-compatible dialects
+func onConflictUpsert(d Dialect, table string, columns, conflictColumns, updateColumns []string) string {
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT",
+		d.Quote(table), quoteList(d, columns), placeholders(d, len(columns)))
+	if len(conflictColumns) > 0 {
+		stmt += fmt.Sprintf(" (%s)", quoteList(d, conflictColumns))
+	}
+	if len(updateColumns) == 0 {
+		return stmt + " DO NOTHING"
+	}
+
+	sets := make([]string, len(updateColumns))
+	for i, col := range updateColumns {
+		sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", d.Quote(col), d.Quote(col))
+	}
+	return stmt + " DO UPDATE SET " + strings.Join(sets, ", ")
+}
+
+// mergeUpsert renders a MERGE statement for dialects without ON CONFLICT support
+func mergeUpsert(d Dialect, target, source string, columns, conflictColumns, updateColumns []string) string {
+	matches := make([]string, len(conflictColumns))
+	for i, col := range conflictColumns {
+		matches[i] = fmt.Sprintf("target.%s = source.%s", d.Quote(col), d.Quote(col))
+	}
+
+	stmt := fmt.Sprintf("MERGE INTO %s USING %s ON (%s)", target, source, strings.Join(matches, " AND "))
+
+	if len(updateColumns) > 0 {
+		sets := make([]string, len(updateColumns))
+		for i, col := range updateColumns {
+			sets[i] = fmt.Sprintf("target.%s = source.%s", d.Quote(col), d.Quote(col))
+		}
+		stmt += " WHEN MATCHED THEN UPDATE SET " + strings.Join(sets, ", ")
+	}
+
+	values := make([]string, len(columns))
+	for i, col := range columns {
+		values[i] = "source." + d.Quote(col)
+	}
+	stmt += fmt.Sprintf(" WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)", quoteList(d, columns), strings.Join(values, ", "))
+
+	return stmt
+}
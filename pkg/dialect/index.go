@@ -0,0 +1,155 @@
+package dialect
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrUnsupportedIndex is returned when an index cannot be expressed in a dialect
+var ErrUnsupportedIndex = errors.New("index not supported by dialect")
+
+// indexMethodName matches the name of an index access method
+var indexMethodName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// IndexDefinition describes an index declared on a model
+type IndexDefinition struct {
+	Name    string        `json:"name"`
+	Table   string        `json:"table"`
+	Columns []IndexColumn `json:"columns"`
+	Unique  bool          `json:"unique"`
+	// Where is the predicate of a partial index
+	Where string `json:"where,omitempty"`
+	// Include lists the non-key columns stored in a covering index
+	Include []string `json:"include,omitempty"`
+	// Method is the access method of the index, such as gin or gist, empty
+	// for the default B-tree
+	Method string `json:"method,omitempty"`
+}
+
+// IndexColumn is a key column or expression of an index
+type IndexColumn struct {
+	Name       string `json:"name,omitempty"`
+	Expression string `json:"expression,omitempty"`
+	// Sort is ASC or DESC, empty for the default order
+	Sort string `json:"sort,omitempty"`
+}
+
+// KeyColumns returns the names of the key columns, or nil when the index has
+// expression columns
+func (idx IndexDefinition) KeyColumns() []string {
+	names := make([]string, 0, len(idx.Columns))
+	for _, column := range idx.Columns {
+		if column.Expression != "" {
+			return nil
+		}
+		names = append(names, column.Name)
+	}
+	return names
+}
+
+// CreateIndex renders the CREATE INDEX statement of idx in dialect d.
+// Dialects without covering indexes store the INCLUDE columns as trailing key
+// columns instead, which serves the same index-only reads; a unique index
+// cannot be widened that way and fails with ErrUnsupportedIndex, as does a
+// partial index on a dialect without partial indexes and an access method
+// other than btree on a dialect without it.
+func CreateIndex(d Dialect, idx IndexDefinition) (string, error) {
+	return createIndex(d, idx, "")
+}
+
+// CreateIndexConcurrently renders the CREATE INDEX statement of idx in
+// dialect d building the index without blocking writes to its table, as
+// CreateIndex does otherwise. Dialects without concurrent builds fail with
+// ErrUnsupportedIndex.
+func CreateIndexConcurrently(d Dialect, idx IndexDefinition) (string, error) {
+	indexer, ok := d.(ConcurrentIndexer)
+	if !ok {
+		return "", fmt.Errorf("%w: %s cannot build index %s concurrently", ErrUnsupportedIndex, d.Name(), idx.Name)
+	}
+	return createIndex(d, idx, indexer.IndexConcurrently())
+}
+
+// createIndex renders the CREATE INDEX statement of idx, with keyword
+// following INDEX when given
+func createIndex(d Dialect, idx IndexDefinition, keyword string) (string, error) {
+	if idx.Name == "" || idx.Table == "" || len(idx.Columns) == 0 {
+		return "", fmt.Errorf("index requires a name, a table and at least one column")
+	}
+
+	renderer, _ := d.(IndexRenderer)
+
+	var method string
+	if idx.Method != "" {
+		ok := false
+		if methods, supported := d.(IndexMethodRenderer); supported {
+			method, ok = methods.IndexMethod(idx.Method)
+		}
+		// B-tree is the default access method of every engine
+		if !ok && !strings.EqualFold(idx.Method, "btree") {
+			return "", fmt.Errorf("%w: %s has no %s indexes (index %s)", ErrUnsupportedIndex, d.Name(), idx.Method, idx.Name)
+		}
+	}
+
+	columns := make([]string, 0, len(idx.Columns)+len(idx.Include))
+	for _, column := range idx.Columns {
+		rendered := column.Expression
+		if rendered == "" {
+			rendered = d.Quote(column.Name)
+		}
+		if column.Sort != "" {
+			rendered += " " + strings.ToUpper(column.Sort)
+		}
+		columns = append(columns, rendered)
+	}
+
+	var include string
+	if len(idx.Include) > 0 {
+		ok := false
+		if renderer != nil {
+			include, ok = renderer.IndexInclude(idx.Include)
+		}
+		if !ok {
+			if idx.Unique {
+				return "", fmt.Errorf("%w: %s cannot include columns in unique index %s", ErrUnsupportedIndex, d.Name(), idx.Name)
+			}
+			for _, column := range idx.Include {
+				columns = append(columns, d.Quote(column))
+			}
+		}
+	}
+
+	var where string
+	if idx.Where != "" {
+		ok := false
+		if renderer != nil {
+			where, ok = renderer.IndexWhere(idx.Where)
+		}
+		if !ok {
+			return "", fmt.Errorf("%w: %s has no partial indexes (index %s)", ErrUnsupportedIndex, d.Name(), idx.Name)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("CREATE ")
+	if idx.Unique {
+		b.WriteString("UNIQUE ")
+	}
+	b.WriteString("INDEX ")
+	if keyword != "" {
+		b.WriteString(keyword + " ")
+	}
+	fmt.Fprintf(&b, "%s ON %s ", d.Quote(idx.Name), d.Quote(idx.Table))
+	if method != "" {
+		b.WriteString(method + " ")
+	}
+	fmt.Fprintf(&b, "(%s)", strings.Join(columns, ", "))
+	if include != "" {
+		b.WriteString(" " + include)
+	}
+	if where != "" {
+		b.WriteString(" " + where)
+	}
+	return b.String(), nil
+}
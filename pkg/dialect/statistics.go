@@ -0,0 +1,162 @@
+package dialect
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// cockroachRowCountRegex matches the row estimate of a CockroachDB plan node
+var cockroachRowCountRegex = regexp.MustCompile(`estimated row count: ([0-9,]+)`)
+
+// ExplainRows renders EXPLAIN (FORMAT JSON)
+func (Postgres) ExplainRows(query string) string { return "EXPLAIN (FORMAT JSON) " + query }
+
+// PlanRows reads the "Plan Rows" of the root plan node
+func (Postgres) PlanRows(plan []map[string]interface{}) (int64, bool) {
+	if len(plan) == 0 {
+		return 0, false
+	}
+	for _, value := range plan[0] {
+		var explained []struct {
+			Plan struct {
+				Rows float64 `json:"Plan Rows"`
+			} `json:"Plan"`
+		}
+		if err := json.Unmarshal([]byte(planText(value)), &explained); err != nil || len(explained) == 0 {
+			return 0, false
+		}
+		return int64(explained[0].Plan.Rows), true
+	}
+	return 0, false
+}
+
+// TableStats reads pg_class sizes and the pg_stat_all_tables counters
+func (Postgres) TableStats() (string, bool) {
+	return "SELECT c.reltuples::bigint AS row_estimate, pg_table_size(c.oid) AS table_bytes, " +
+		"pg_indexes_size(c.oid) AS index_bytes, s.n_dead_tup AS dead_tuples, " +
+		"GREATEST(s.last_vacuum, s.last_autovacuum) AS last_vacuum, " +
+		"GREATEST(s.last_analyze, s.last_autoanalyze) AS last_analyze " +
+		"FROM pg_class c LEFT JOIN pg_stat_all_tables s ON s.relid = c.oid WHERE c.oid = to_regclass(?)", true
+}
+
+// IndexStats reads the size of every index of the table
+func (Postgres) IndexStats() string {
+	return "SELECT i.relname AS index_name, pg_relation_size(i.oid) AS index_bytes " +
+		"FROM pg_index x JOIN pg_class i ON i.oid = x.indexrelid " +
+		"WHERE x.indrelid = to_regclass(?) ORDER BY i.relname"
+}
+
+// ExplainPlan renders EXPLAIN, whose text plan CockroachDB shares
+func (Postgres) ExplainPlan(query string) string { return "EXPLAIN " + query }
+
+// ExplainRows renders EXPLAIN, CockroachDB has no JSON plan output
+func (CockroachDB) ExplainRows(query string) string { return "EXPLAIN " + query }
+
+// PlanRows reads the estimated row count of the root plan node from the
+// textual plan
+func (CockroachDB) PlanRows(plan []map[string]interface{}) (int64, bool) {
+	for _, row := range plan {
+		for _, value := range row {
+			match := cockroachRowCountRegex.FindStringSubmatch(planText(value))
+			if match == nil {
+				continue
+			}
+			rows, err := strconv.ParseInt(strings.ReplaceAll(match[1], ",", ""), 10, 64)
+			return rows, err == nil
+		}
+	}
+	return 0, false
+}
+
+// TableStats reports false since CockroachDB has no pg_table_size or vacuum
+// statistics
+func (CockroachDB) TableStats() (string, bool) { return "", false }
+
+// ExplainRows renders EXPLAIN
+func (MySQL) ExplainRows(query string) string { return "EXPLAIN " + query }
+
+// ExplainPlan renders EXPLAIN, whose tabular plan MariaDB shares
+func (MySQL) ExplainPlan(query string) string { return "EXPLAIN " + query }
+
+// PlanRows multiplies the rows examined by the share of them matching the
+// conditions (filtered) for the first table of the plan
+func (MySQL) PlanRows(plan []map[string]interface{}) (int64, bool) {
+	if len(plan) == 0 {
+		return 0, false
+	}
+	rows, ok := planNumber(plan[0]["rows"])
+	if !ok {
+		return 0, false
+	}
+	if filtered, ok := planNumber(plan[0]["filtered"]); ok {
+		rows = rows * filtered / 100
+	}
+	return int64(rows + 0.5), true
+}
+
+// TableStats reads information_schema.TABLES. InnoDB tracks neither dead
+// rows nor maintenance times there.
+func (MySQL) TableStats() (string, bool) {
+	return "SELECT TABLE_ROWS AS row_estimate, DATA_LENGTH AS table_bytes, INDEX_LENGTH AS index_bytes, " +
+		"NULL AS dead_tuples, NULL AS last_vacuum, NULL AS last_analyze " +
+		"FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?", true
+}
+
+// IndexStats reads the InnoDB persistent index statistics
+func (MySQL) IndexStats() string {
+	return "SELECT index_name, stat_value * @@innodb_page_size AS index_bytes FROM mysql.innodb_index_stats " +
+		"WHERE database_name = DATABASE() AND table_name = ? AND stat_name = 'size' ORDER BY index_name"
+}
+
+// ExplainPlan renders EXPLAIN QUERY PLAN
+func (SQLite) ExplainPlan(query string) string { return "EXPLAIN QUERY PLAN " + query }
+
+// TableStats sums the pages of the heap or clustered index and of the other
+// indexes from sys.dm_db_partition_stats
+func (SQLServer) TableStats() (string, bool) {
+	return "SELECT SUM(CASE WHEN index_id IN (0, 1) THEN row_count ELSE 0 END) AS row_estimate, " +
+		"SUM(CASE WHEN index_id IN (0, 1) THEN used_page_count ELSE 0 END) * 8192 AS table_bytes, " +
+		"SUM(CASE WHEN index_id > 1 THEN used_page_count ELSE 0 END) * 8192 AS index_bytes, " +
+		"NULL AS dead_tuples, NULL AS last_vacuum, NULL AS last_analyze " +
+		"FROM sys.dm_db_partition_stats WHERE object_id = OBJECT_ID(?) HAVING COUNT(*) > 0", true
+}
+
+// IndexStats sums the used pages of every named index
+func (SQLServer) IndexStats() string {
+	return "SELECT i.name AS index_name, SUM(ps.used_page_count) * 8192 AS index_bytes " +
+		"FROM sys.dm_db_partition_stats ps JOIN sys.indexes i ON i.object_id = ps.object_id AND i.index_id = ps.index_id " +
+		"WHERE ps.object_id = OBJECT_ID(?) AND i.name IS NOT NULL GROUP BY i.name ORDER BY i.name"
+}
+
+// planText returns a plan column value as text
+func planText(value interface{}) string {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case nil:
+		return ""
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// planNumber returns a numeric plan column value
+func planNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case []byte, string:
+		n, err := strconv.ParseFloat(planText(v), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,89 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+)
+
+// BatchFailure describes a batch of a batch write that failed: its position,
+// the index of its first entity and its number of entities
+type BatchFailure struct {
+	Batch  int   `json:"batch"`
+	Offset int   `json:"offset"`
+	Size   int   `json:"size"`
+	Err    error `json:"-"`
+}
+
+// BatchError is returned when batches of a batch write fail while others
+// succeed or are not attempted. It is an ORMError of the type of the first
+// failure that also reports the entities written by the successful batches.
+type BatchError struct {
+	*ORMError
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	failures  []BatchFailure
+}
+
+// NewBatchError creates the error of operation on table writing total
+// entities, succeeded of them in successful batches, with the given failures
+func NewBatchError(operation, table string, total, succeeded int, failures []BatchFailure) *BatchError {
+	errorType := ErrorTypeQuery
+	if len(failures) > 0 {
+		if typed, ok := failures[0].Err.(interface{ GetType() ErrorType }); ok {
+			errorType = typed.GetType()
+		}
+	}
+	e := &BatchError{
+		ORMError:  New(errorType, "").WithOperation(operation).WithTable(table),
+		Total:     total,
+		Succeeded: succeeded,
+		failures:  failures,
+	}
+	e.Message = fmt.Sprintf("%d of %d entities not written, %d batches failed", e.Failed(), total, len(failures))
+	if len(failures) > 0 {
+		e.Message += fmt.Sprintf(": batch %d: %v", failures[0].Batch, failures[0].Err)
+	}
+	return e
+}
+
+// Failed returns the number of entities not written, by failed batches or
+// batches not attempted after a failure
+func (e *BatchError) Failed() int {
+	return e.Total - e.Succeeded
+}
+
+// ResumeIndex returns the index of the first entity not written by a batch
+// write stopping at its first failure, from which it can be resumed
+func (e *BatchError) ResumeIndex() int {
+	if len(e.failures) > 0 {
+		return e.failures[0].Offset
+	}
+	return e.Succeeded
+}
+
+// Failures returns the failed batches in order
+func (e *BatchError) Failures() []BatchFailure {
+	failures := make([]BatchFailure, len(e.failures))
+	copy(failures, e.failures)
+	return failures
+}
+
+// Unwrap returns the underlying ORMError and the errors of the failed
+// batches, so errors.Is and errors.As match them all
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.failures)+1)
+	errs = append(errs, e.ORMError)
+	for _, failure := range e.failures {
+		errs = append(errs, failure.Err)
+	}
+	return errs
+}
+
+// AsBatchError returns the BatchError in err's chain, if any
+func AsBatchError(err error) (*BatchError, bool) {
+	var batchErr *BatchError
+	if stderrors.As(err, &batchErr) {
+		return batchErr, true
+	}
+	return nil, false
+}
@@ -2,6 +2,8 @@ package errors
 
 import (
 	"context"
+	"database/sql"
+	stderrors "errors"
 	"fmt"
 	"strings"
 	"time"
@@ -52,6 +54,11 @@ const (
 	ErrorTypeUnknown  ErrorType = "unknown"
 )
 
+// ErrNotFound matches, with errors.Is, the errors reporting a missing record:
+// the ORMErrors of type ErrorTypeNotFound returned by the finders of the
+// repositories whatever the driver
+var ErrNotFound = stderrors.New("record not found")
+
 // ErrorSeverity represents the severity level of an error
 type ErrorSeverity string
 
@@ -105,6 +112,12 @@ func (e *ORMError) Unwrap() error {
 	return e.Cause
 }
 
+// Is reports whether the error matches target: errors of type
+// ErrorTypeNotFound match ErrNotFound
+func (e *ORMError) Is(target error) bool {
+	return target == ErrNotFound && e.Type == ErrorTypeNotFound
+}
+
 // IsRetryable returns true if the error is retryable
 func (e *ORMError) IsRetryable() bool {
 	return e.Retryable
@@ -330,6 +343,9 @@ func NewErrorClassifier() *ErrorClassifier {
 			pattern   string
 			errorType ErrorType
 		}{
+			{"connection pool exhausted", ErrorTypeResource},
+			{"timed out acquiring a database connection", ErrorTypeResource},
+			{"unique constraint failed", ErrorTypeDuplicate},
 			{"unique constraint violation", ErrorTypeConstraint},
 			{"foreign key constraint", ErrorTypeConstraint},
 			{"connection timeout", ErrorTypeTimeout},
@@ -340,6 +356,7 @@ func NewErrorClassifier() *ErrorClassifier {
 			{"deadlock", ErrorTypeDeadlock},
 			{"duplicate", ErrorTypeDuplicate},
 			{"not found", ErrorTypeNotFound},
+			{"no rows in result set", ErrorTypeNotFound},
 			{"validation", ErrorTypeValidation},
 			{"sql injection", ErrorTypeSQLInjection},
 			{"table does not exist", ErrorTypeNotFound},
@@ -410,6 +427,51 @@ func (ec *ErrorClassifier) isRetryable(message string) bool {
 	return false
 }
 
+// IsType reports whether err, or an error it wraps, is an ORMError of
+// errorType, validation errors included
+func IsType(err error, errorType ErrorType) bool {
+	for ; err != nil; err = stderrors.Unwrap(err) {
+		if typed, ok := err.(interface{ GetType() ErrorType }); ok && typed.GetType() == errorType {
+			return true
+		}
+	}
+	return false
+}
+
+// IsNotFound reports whether err reports a missing record: an ORMError of
+// type ErrorTypeNotFound, as returned by the repositories, ErrNotFound or
+// sql.ErrNoRows
+func IsNotFound(err error) bool {
+	return stderrors.Is(err, ErrNotFound) || stderrors.Is(err, sql.ErrNoRows)
+}
+
+// IsDuplicate reports whether err reports the violation of a unique key
+func IsDuplicate(err error) bool {
+	return IsType(err, ErrorTypeDuplicate)
+}
+
+// IsTimeout reports whether err reports an expired timeout: an ORMError of
+// type ErrorTypeTimeout or an expired context deadline
+func IsTimeout(err error) bool {
+	return IsType(err, ErrorTypeTimeout) || stderrors.Is(err, context.DeadlineExceeded)
+}
+
+// IsSerializationFailure reports whether err is a serialization failure (SQLSTATE 40001)
+// that the database expects the client to resolve by retrying the transaction
+func IsSerializationFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var stateErr interface{ SQLState() string }
+	if stderrors.As(err, &stateErr) {
+		return stateErr.SQLState() == "40001"
+	}
+
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "sqlstate 40001") || strings.Contains(message, "restart transaction")
+}
+
 // ErrorHandler handles errors with retry logic and logging
 type ErrorHandler struct {
 	classifier *ErrorClassifier
@@ -489,7 +551,7 @@ func (eh *ErrorHandler) RetryWithContext(ctx context.Context, operation func() e
 // getDefaultSeverity returns the default severity for an error type
 func getDefaultSeverity(errorType ErrorType) ErrorSeverity {
 	switch errorType {
-	case ErrorTypeConnection, ErrorTypeTimeout, ErrorTypeDeadlock:
+	case ErrorTypeConnection, ErrorTypeTimeout, ErrorTypeDeadlock, ErrorTypeResource:
 		return ErrorSeverityHigh
 	case ErrorTypeConstraint, ErrorTypeValidation, ErrorTypeNotFound:
 		return ErrorSeverityMedium
@@ -541,3 +603,7 @@ func NewConstraintError(message string) *ORMError {
 func NewSecurityError(message string) *ORMError {
 	return New(ErrorTypeSecurity, message)
 }
+
+func NewResourceError(message string) *ORMError {
+	return New(ErrorTypeResource, message)
+}
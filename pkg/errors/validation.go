@@ -0,0 +1,217 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FieldError describes the validation failure of a single field. The offending
+// value is deliberately not carried so errors can be returned to API clients
+// and logged without leaking sensitive data.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when an entity fails validation. It is an
+// ORMError of type validation that also exposes the failures per field.
+type ValidationError struct {
+	*ORMError
+	Entity string `json:"entity"`
+	Index  int    `json:"index"`
+	fields []FieldError
+}
+
+// NewFieldValidationError creates a validation error for entity with the given field failures
+func NewFieldValidationError(entity string, fields []FieldError) *ValidationError {
+	e := &ValidationError{
+		ORMError: New(ErrorTypeValidation, "validation failed").WithTable(entity),
+		Entity:   entity,
+		Index:    -1,
+		fields:   fields,
+	}
+	e.Message = e.message()
+	if len(fields) == 1 {
+		e.Field = fields[0].Field
+	}
+	return e
+}
+
+// WithIndex records the position of the entity in a batch
+func (e *ValidationError) WithIndex(index int) *ValidationError {
+	e.Index = index
+	e.Message = e.message()
+	return e
+}
+
+// Fields returns the field failures
+func (e *ValidationError) Fields() []FieldError {
+	fields := make([]FieldError, len(e.fields))
+	copy(fields, e.fields)
+	return fields
+}
+
+// FieldMap returns the failure messages keyed by field, ready to be returned by APIs
+func (e *ValidationError) FieldMap() map[string][]string {
+	fieldMap := make(map[string][]string, len(e.fields))
+	for _, f := range e.fields {
+		fieldMap[f.Field] = append(fieldMap[f.Field], f.Message)
+	}
+	return fieldMap
+}
+
+// Unwrap returns the underlying ORMError so errors.As matches both types
+func (e *ValidationError) Unwrap() error {
+	return e.ORMError
+}
+
+// AsValidationError returns the ValidationError in err's chain, if any
+func AsValidationError(err error) (*ValidationError, bool) {
+	var validationErr *ValidationError
+	if stderrors.As(err, &validationErr) {
+		return validationErr, true
+	}
+	return nil, false
+}
+
+// message renders the summary message of the failures
+func (e *ValidationError) message() string {
+	parts := make([]string, len(e.fields))
+	for i, f := range e.fields {
+		parts[i] = fmt.Sprintf("%s: %s", f.Field, f.Message)
+	}
+
+	prefix := "validation failed"
+	if e.Index >= 0 {
+		prefix = fmt.Sprintf("validation failed for entity %d", e.Index)
+	}
+	if len(parts) == 0 {
+		return prefix
+	}
+	return fmt.Sprintf("%s: %s", prefix, strings.Join(parts, "; "))
+}
+
+// BatchViolation describes a rule broken by rows of a batch together, such
+// as a value repeated within the batch. Rows are the positions of the
+// offending entities in the batch.
+type BatchViolation struct {
+	Rule    string `json:"rule"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+	Rows    []int  `json:"rows"`
+}
+
+// BatchValidationError is returned when a batch fails validation: entities
+// failing their own rules, with their index, and rules broken by rows
+// together. It is an ORMError of type validation that also exposes both.
+type BatchValidationError struct {
+	*ORMError
+	Entity     string `json:"entity"`
+	violations []BatchViolation
+	entities   []*ValidationError
+}
+
+// NewBatchValidationError creates a batch validation error for entity with the given violations
+func NewBatchValidationError(entity string, violations []BatchViolation) *BatchValidationError {
+	e := &BatchValidationError{
+		ORMError:   New(ErrorTypeValidation, "batch validation failed").WithTable(entity),
+		Entity:     entity,
+		violations: violations,
+	}
+	e.Message = e.message()
+	if len(violations) == 1 {
+		e.Field = violations[0].Field
+	}
+	return e
+}
+
+// WithEntityErrors records the validation errors of the invalid entities of
+// the batch, each carrying its index
+func (e *BatchValidationError) WithEntityErrors(errs []*ValidationError) *BatchValidationError {
+	e.entities = errs
+	e.Message = e.message()
+	return e
+}
+
+// EntityErrors returns the validation errors of the invalid entities, in
+// the order of the batch
+func (e *BatchValidationError) EntityErrors() []*ValidationError {
+	errs := make([]*ValidationError, len(e.entities))
+	copy(errs, e.entities)
+	return errs
+}
+
+// Violations returns the violations
+func (e *BatchValidationError) Violations() []BatchViolation {
+	violations := make([]BatchViolation, len(e.violations))
+	copy(violations, e.violations)
+	return violations
+}
+
+// Rows returns the positions of the offending entities in the batch, in order
+func (e *BatchValidationError) Rows() []int {
+	seen := make(map[int]bool)
+	var rows []int
+	for _, entityErr := range e.entities {
+		if !seen[entityErr.Index] {
+			seen[entityErr.Index] = true
+			rows = append(rows, entityErr.Index)
+		}
+	}
+	for _, v := range e.violations {
+		for _, row := range v.Rows {
+			if !seen[row] {
+				seen[row] = true
+				rows = append(rows, row)
+			}
+		}
+	}
+	sort.Ints(rows)
+	return rows
+}
+
+// Unwrap returns the underlying ORMError and the errors of the entities, so
+// errors.As matches them all
+func (e *BatchValidationError) Unwrap() []error {
+	errs := make([]error, 0, len(e.entities)+1)
+	errs = append(errs, e.ORMError)
+	for _, entityErr := range e.entities {
+		errs = append(errs, entityErr)
+	}
+	return errs
+}
+
+// AsBatchValidationError returns the BatchValidationError in err's chain, if any
+func AsBatchValidationError(err error) (*BatchValidationError, bool) {
+	var batchErr *BatchValidationError
+	if stderrors.As(err, &batchErr) {
+		return batchErr, true
+	}
+	return nil, false
+}
+
+// message renders the summary message of the entity errors and violations
+func (e *BatchValidationError) message() string {
+	parts := make([]string, 0, len(e.entities)+len(e.violations))
+	for _, entityErr := range e.entities {
+		parts = append(parts, strings.TrimPrefix(entityErr.message(), "validation failed for "))
+	}
+	for _, v := range e.violations {
+		rows := make([]string, len(v.Rows))
+		for j, row := range v.Rows {
+			rows[j] = fmt.Sprint(row)
+		}
+		part := fmt.Sprintf("%s (rows %s)", v.Message, strings.Join(rows, ", "))
+		if v.Field != "" {
+			part = v.Field + ": " + part
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return "batch validation failed"
+	}
+	return fmt.Sprintf("batch validation failed: %s", strings.Join(parts, "; "))
+}
@@ -0,0 +1,526 @@
+// Package filters parses the filter query strings of REST endpoints into
+// repository conditions, so APIs can expose filtering without building SQL:
+//
+//	// GET /users?filter=age>=30;name==Al*,role=in=(admin,owner)
+//	cond, err := filters.Parse[User](r.URL.Query().Get("filter"),
+//		filters.Fields("age", "name", "role"))
+//	if err != nil {
+//		return err // a validation error naming the position or the field
+//	}
+//	err = repo.FindAllByConditionsWithOffset(ctx, 50, 0, &users, cond)
+//
+// The syntax follows RSQL. A comparison is a field, an operator and a value:
+//
+//	==  !=                 equal, not equal; null matches NULL and * is a wildcard
+//	=gt= >   =ge= >=       greater than, greater than or equal
+//	=lt= <   =le= <=       less than, less than or equal
+//	=in=(a,b) =out=(a,b)   one of, none of
+//
+// Comparisons are joined by ; (or "and") and , (or "or"), with AND binding
+// tighter than OR; parentheses group them. Values containing spaces or
+// reserved characters are quoted with ' or ", in which \ escapes the next
+// character.
+//
+// Fields are named by their JSON name, column or Go name and resolved with the
+// default naming strategy. Values are converted to the type of the field and
+// always bound as parameters, so a filter can only compare the columns it is
+// allowed to. Without Fields, every column of the model may be filtered but
+// those whose JSON name is "-".
+package filters
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"gorm.io/gorm/schema"
+)
+
+// DefaultMaxComparisons bounds the comparisons of a filter when MaxComparisons is not given
+const DefaultMaxComparisons = 20
+
+// maxDepth bounds the nesting of parentheses
+const maxDepth = 10
+
+// schemas caches the parsed models
+var schemas sync.Map
+
+var (
+	uuidType = reflect.TypeOf(uuid.UUID{})
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+// Option configures Parse
+type Option func(*options)
+
+type options struct {
+	fields         []string
+	maxComparisons int
+}
+
+// Fields limits the filterable fields to names, given as JSON names, columns
+// or Go names
+func Fields(names ...string) Option {
+	return func(o *options) {
+		o.fields = append(o.fields, names...)
+	}
+}
+
+// MaxComparisons bounds the comparisons of a filter, DefaultMaxComparisons by default
+func MaxComparisons(n int) Option {
+	return func(o *options) {
+		o.maxComparisons = n
+	}
+}
+
+// Parse parses query into a condition on the columns of T. An empty query
+// returns the zero condition, which matches every row. Syntax errors, unknown
+// or disallowed fields and values that do not fit their field fail with an
+// errors.ErrorTypeValidation error.
+func Parse[T any](query string, opts ...Option) (repository.Cond, error) {
+	o := options{maxComparisons: DefaultMaxComparisons}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	s, err := schema.Parse(new(T), &schemas, schema.NamingStrategy{})
+	if err != nil {
+		return repository.Cond{}, errors.Wrap(err, errors.ErrorTypeModel, "failed to parse filtered model")
+	}
+	fields, err := filterable(s, o.fields)
+	if err != nil {
+		return repository.Cond{}, err
+	}
+
+	p := &parser{input: query, schema: s, fields: fields, maxComparisons: o.maxComparisons}
+	p.skipSpaces()
+	if p.done() {
+		return repository.Cond{}, nil
+	}
+	cond, err := p.parseOr(0)
+	if err != nil {
+		return repository.Cond{}, err
+	}
+	if !p.done() {
+		return repository.Cond{}, p.syntaxError("unexpected %q", p.input[p.pos])
+	}
+	return cond, nil
+}
+
+// filterable returns the fields of s that may be filtered, keyed by their
+// JSON names, columns and Go names
+func filterable(s *schema.Schema, allowed []string) (map[string]*schema.Field, error) {
+	names := make(map[string]*schema.Field)
+	hidden := make(map[*schema.Field]bool)
+	for _, field := range s.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		names[field.DBName] = field
+		names[field.Name] = field
+		switch json, _, _ := strings.Cut(field.Tag.Get("json"), ","); json {
+		case "-":
+			hidden[field] = true
+		case "":
+		default:
+			names[json] = field
+		}
+	}
+
+	if len(allowed) == 0 {
+		for name, field := range names {
+			if hidden[field] {
+				delete(names, name)
+			}
+		}
+		return names, nil
+	}
+
+	permitted := make(map[*schema.Field]bool, len(allowed))
+	for _, name := range allowed {
+		field, ok := names[name]
+		if !ok {
+			return nil, errors.New(errors.ErrorTypeConfig, fmt.Sprintf("filter field %q is not a column of %s", name, s.Table))
+		}
+		permitted[field] = true
+	}
+	for name, field := range names {
+		if !permitted[field] {
+			delete(names, name)
+		}
+	}
+	return names, nil
+}
+
+// parser is a recursive descent parser of a filter query
+type parser struct {
+	input          string
+	pos            int
+	schema         *schema.Schema
+	fields         map[string]*schema.Field
+	comparisons    int
+	maxComparisons int
+}
+
+// parseOr parses comparisons joined by OR
+func (p *parser) parseOr(depth int) (repository.Cond, error) {
+	var conds []repository.Cond
+	for {
+		cond, err := p.parseAnd(depth)
+		if err != nil {
+			return repository.Cond{}, err
+		}
+		conds = append(conds, cond)
+		if !p.separator(',', "or") {
+			return repository.Or(conds...), nil
+		}
+	}
+}
+
+// parseAnd parses comparisons joined by AND
+func (p *parser) parseAnd(depth int) (repository.Cond, error) {
+	var conds []repository.Cond
+	for {
+		cond, err := p.parseTerm(depth)
+		if err != nil {
+			return repository.Cond{}, err
+		}
+		conds = append(conds, cond)
+		if !p.separator(';', "and") {
+			return repository.And(conds...), nil
+		}
+	}
+}
+
+// parseTerm parses a parenthesized group or a comparison
+func (p *parser) parseTerm(depth int) (repository.Cond, error) {
+	p.skipSpaces()
+	if p.done() {
+		return repository.Cond{}, p.syntaxError("expected a comparison")
+	}
+	if p.input[p.pos] != '(' {
+		return p.parseComparison()
+	}
+
+	if depth >= maxDepth {
+		return repository.Cond{}, p.syntaxError("more than %d nested groups", maxDepth)
+	}
+	p.pos++
+	cond, err := p.parseOr(depth + 1)
+	if err != nil {
+		return repository.Cond{}, err
+	}
+	p.skipSpaces()
+	if p.done() || p.input[p.pos] != ')' {
+		return repository.Cond{}, p.syntaxError("expected )")
+	}
+	p.pos++
+	return cond, nil
+}
+
+// parseComparison parses a field, an operator and its value
+func (p *parser) parseComparison() (repository.Cond, error) {
+	start := p.pos
+	name := p.selector()
+	if name == "" {
+		return repository.Cond{}, p.syntaxError("expected a field")
+	}
+	field, ok := p.fields[name]
+	if !ok {
+		p.pos = start
+		return repository.Cond{}, p.fieldError(name, "filterable", "is not a filterable field")
+	}
+	if p.comparisons++; p.comparisons > p.maxComparisons {
+		p.pos = start
+		return repository.Cond{}, p.syntaxError("more than %d comparisons", p.maxComparisons)
+	}
+
+	p.skipSpaces()
+	op, ok := p.operator()
+	if !ok {
+		return repository.Cond{}, p.syntaxError("expected an operator after %s", name)
+	}
+	p.skipSpaces()
+	column := field.DBName
+
+	if op == "=in=" || op == "=out=" {
+		texts, err := p.list()
+		if err != nil {
+			return repository.Cond{}, err
+		}
+		values := make([]interface{}, len(texts))
+		for i, text := range texts {
+			if values[i], err = p.convert(name, field, text.value); err != nil {
+				return repository.Cond{}, err
+			}
+		}
+		if op == "=in=" {
+			return repository.In(column, values...), nil
+		}
+		return repository.NotIn(column, values...), nil
+	}
+
+	text, err := p.value()
+	if err != nil {
+		return repository.Cond{}, err
+	}
+	if (op == "==" || op == "!=") && !text.quoted {
+		if text.value == "null" {
+			if op == "==" {
+				return repository.IsNull(column), nil
+			}
+			return repository.IsNotNull(column), nil
+		}
+		if strings.Contains(text.value, "*") {
+			if field.IndirectFieldType.Kind() != reflect.String {
+				return repository.Cond{}, p.fieldError(name, "wildcard", "only text fields take wildcards")
+			}
+			like := repository.Like(column, strings.ReplaceAll(text.value, "*", "%"))
+			if op == "==" {
+				return like, nil
+			}
+			return repository.Not(like), nil
+		}
+	}
+
+	value, err := p.convert(name, field, text.value)
+	if err != nil {
+		return repository.Cond{}, err
+	}
+	switch op {
+	case "==":
+		return repository.Eq(column, value), nil
+	case "!=":
+		return repository.Neq(column, value), nil
+	case "=gt=", ">":
+		return repository.Gt(column, value), nil
+	case "=ge=", ">=":
+		return repository.Gte(column, value), nil
+	case "=lt=", "<":
+		return repository.Lt(column, value), nil
+	default:
+		return repository.Lte(column, value), nil
+	}
+}
+
+// operators lists the operators, longer ones before their prefixes
+var operators = []string{"==", "!=", "=gt=", "=ge=", "=lt=", "=le=", "=in=", "=out=", ">=", "<=", ">", "<"}
+
+// operator reads an operator
+func (p *parser) operator() (string, bool) {
+	for _, op := range operators {
+		if strings.HasPrefix(p.input[p.pos:], op) {
+			p.pos += len(op)
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// selector reads a field name
+func (p *parser) selector() string {
+	start := p.pos
+	for !p.done() {
+		c := p.input[p.pos]
+		if c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || p.pos > start && c >= '0' && c <= '9' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.input[start:p.pos]
+}
+
+// text is a value as written in the query
+type text struct {
+	value  string
+	quoted bool
+}
+
+// list reads the parenthesized values of =in= and =out=
+func (p *parser) list() ([]text, error) {
+	if p.done() || p.input[p.pos] != '(' {
+		return nil, p.syntaxError("expected ( starting a list of values")
+	}
+	p.pos++
+	var values []text
+	for {
+		p.skipSpaces()
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		p.skipSpaces()
+		if p.done() {
+			return nil, p.syntaxError("expected ) closing the list of values")
+		}
+		switch p.input[p.pos] {
+		case ',':
+			p.pos++
+		case ')':
+			p.pos++
+			return values, nil
+		default:
+			return nil, p.syntaxError("unexpected %q in a list of values", p.input[p.pos])
+		}
+	}
+}
+
+// value reads a quoted or unquoted value
+func (p *parser) value() (text, error) {
+	if p.done() {
+		return text{}, p.syntaxError("expected a value")
+	}
+	if quote := p.input[p.pos]; quote == '\'' || quote == '"' {
+		start := p.pos
+		p.pos++
+		var b strings.Builder
+		for !p.done() {
+			c := p.input[p.pos]
+			p.pos++
+			switch {
+			case c == quote:
+				return text{value: b.String(), quoted: true}, nil
+			case c == '\\' && !p.done():
+				b.WriteByte(p.input[p.pos])
+				p.pos++
+			default:
+				b.WriteByte(c)
+			}
+		}
+		p.pos = start
+		return text{}, p.syntaxError("unterminated quoted value")
+	}
+
+	start := p.pos
+	for !p.done() && !strings.ContainsRune(" \t\r\n;,()'\"", rune(p.input[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return text{}, p.syntaxError("expected a value")
+	}
+	return text{value: p.input[start:p.pos]}, nil
+}
+
+// separator reads the symbol or keyword joining two comparisons
+func (p *parser) separator(symbol byte, keyword string) bool {
+	p.skipSpaces()
+	if p.done() {
+		return false
+	}
+	if p.input[p.pos] == symbol {
+		p.pos++
+		return true
+	}
+	end := p.pos + len(keyword)
+	if end < len(p.input) && strings.EqualFold(p.input[p.pos:end], keyword) && strings.ContainsRune(" \t\r\n(", rune(p.input[end])) {
+		p.pos = end
+		return true
+	}
+	return false
+}
+
+func (p *parser) skipSpaces() {
+	for !p.done() && strings.ContainsRune(" \t\r\n", rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.input)
+}
+
+// syntaxError reports a malformed query at the current position
+func (p *parser) syntaxError(format string, args ...interface{}) error {
+	return errors.New(errors.ErrorTypeValidation,
+		fmt.Sprintf("invalid filter at position %d: %s", p.pos+1, fmt.Sprintf(format, args...))).WithTable(p.schema.Table)
+}
+
+// fieldError reports a comparison on name that is not allowed
+func (p *parser) fieldError(name, rule, message string) error {
+	return errors.NewFieldValidationError(p.schema.Table, []errors.FieldError{{
+		Field:   name,
+		Rule:    rule,
+		Message: fmt.Sprintf("position %d: %s", p.pos+1, message),
+	}})
+}
+
+// convert converts value to the type of field
+func (p *parser) convert(name string, field *schema.Field, value string) (interface{}, error) {
+	switch field.IndirectFieldType {
+	case uuidType:
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return nil, p.fieldError(name, "uuid", "must be a UUID")
+		}
+		return id, nil
+	case timeType:
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return t, nil
+		}
+		if t, err := time.Parse(time.DateOnly, value); err == nil {
+			return t, nil
+		}
+		return nil, p.fieldError(name, "datetime", "must be an RFC 3339 timestamp or a date")
+	}
+
+	switch field.IndirectFieldType.Kind() {
+	case reflect.String:
+		if values := enumValues(field); len(values) > 0 && !contains(values, value) {
+			return nil, p.fieldError(name, "oneof", fmt.Sprintf("must be one of [%s]", strings.Join(values, " ")))
+		}
+		return value, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, p.fieldError(name, "number", "must be an integer")
+		}
+		return n, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, p.fieldError(name, "number", "must be a non-negative integer")
+		}
+		return n, nil
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, p.fieldError(name, "number", "must be a number")
+		}
+		return n, nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, p.fieldError(name, "boolean", "must be a boolean")
+		}
+		return b, nil
+	}
+	return nil, p.fieldError(name, "filterable", "cannot be filtered")
+}
+
+// enumValues returns the values of the oneof rule of the validate tag of field
+func enumValues(field *schema.Field) []string {
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		if values, ok := strings.CutPrefix(rule, "oneof="); ok {
+			return strings.Fields(values)
+		}
+	}
+	return nil
+}
+
+// contains reports whether values holds value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
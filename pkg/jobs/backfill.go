@@ -0,0 +1,478 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"gorm.io/gorm"
+)
+
+// BackfillFunc updates one batch of rows inside the batch's transaction. It
+// must be idempotent: batches that ran after the checkpoint when a backfill
+// stopped run again when it resumes.
+type BackfillFunc[T any] func(ctx context.Context, tx *gorm.DB, batch []T) error
+
+// BackfillConfig represents backfill configuration
+type BackfillConfig struct {
+	// BatchSize is the number of rows loaded and updated per transaction
+	BatchSize int `json:"batch_size"`
+	// Concurrency is the number of batches updated in parallel
+	Concurrency int `json:"concurrency"`
+	// RowsPerSecond limits the rows handed to the backfill function, zero is unlimited
+	RowsPerSecond float64 `json:"rows_per_second"`
+	// KeyColumn orders the rows and keys the checkpoints (default "id")
+	KeyColumn string `json:"key_column"`
+	// CountTotal counts the rows left to backfill when a run starts, to report
+	// the completion ratio in the progress
+	CountTotal bool `json:"count_total"`
+}
+
+// DefaultBackfillConfig returns default backfill configuration
+func DefaultBackfillConfig() *BackfillConfig {
+	return &BackfillConfig{
+		BatchSize:   500,
+		Concurrency: 1,
+		KeyColumn:   "id",
+	}
+}
+
+// BackfillProgress reports the progress of a backfill
+type BackfillProgress struct {
+	Job     string `json:"job"`
+	Running bool   `json:"running"`
+	Paused  bool   `json:"paused"`
+	// Processed counts the rows backfilled, including previous runs
+	Processed int64 `json:"processed"`
+	// Total is Processed plus the rows left when the run started, when counted
+	Total   int64  `json:"total,omitempty"`
+	Batches int64  `json:"batches"`
+	Failed  int64  `json:"failed"`
+	LastKey string `json:"last_key"`
+	// RowsPerSecond is the throughput of the current run, pauses included
+	RowsPerSecond float64   `json:"rows_per_second"`
+	StartedAt     time.Time `json:"started_at"`
+	Completed     bool      `json:"completed"`
+}
+
+// Ratio returns the completed fraction of the backfill, 0 when the total was
+// not counted
+func (p BackfillProgress) Ratio() float64 {
+	if p.Completed {
+		return 1
+	}
+	if p.Total <= 0 {
+		return 0
+	}
+	return float64(p.Processed) / float64(p.Total)
+}
+
+// Backfill walks the rows of T selected by a scope in key order and updates
+// them batch by batch, replacing one-off scripts for column backfills. Batches
+// are updated in their own transaction, up to Concurrency at a time and
+// throttled to RowsPerSecond. The checkpoint advances past a batch once it
+// and all batches before it succeeded, so a backfill stopped by an error, a
+// cancellation or a deploy resumes where it left off.
+type Backfill[T any] struct {
+	name   string
+	db     *gorm.DB
+	store  CheckpointStore
+	logger logging.Logger
+	config *BackfillConfig
+	update BackfillFunc[T]
+	scope  func(*gorm.DB) *gorm.DB
+	keyOf  func(*T) string
+	guard  *LoadGuard
+
+	mu             sync.Mutex
+	progress       BackfillProgress
+	startProcessed int64
+	resume         chan struct{}
+}
+
+// NewBackfill creates the backfill named name, applying update to the rows of T
+func NewBackfill[T any](name string, db *gorm.DB, store CheckpointStore, logger logging.Logger, config *BackfillConfig, update BackfillFunc[T]) *Backfill[T] {
+	if config == nil {
+		config = DefaultBackfillConfig()
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultBackfillConfig().BatchSize
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	if config.KeyColumn == "" {
+		config.KeyColumn = DefaultBackfillConfig().KeyColumn
+	}
+
+	return &Backfill[T]{
+		name:     name,
+		db:       db,
+		store:    store,
+		logger:   logger,
+		config:   config,
+		update:   update,
+		keyOf:    entityKey[T],
+		progress: BackfillProgress{Job: name},
+	}
+}
+
+// WithScope restricts the backfill to the rows selected by scope, e.g. the
+// rows whose new column is still NULL
+func (b *Backfill[T]) WithScope(scope func(*gorm.DB) *gorm.DB) *Backfill[T] {
+	b.scope = scope
+	return b
+}
+
+// WithKey sets how the key of a row is read, when KeyColumn is not the ID
+func (b *Backfill[T]) WithKey(keyOf func(*T) string) *Backfill[T] {
+	b.keyOf = keyOf
+	return b
+}
+
+// WithLoadGuard makes the backfill wait for guard before every round of
+// batches, pausing it outside maintenance windows or under load
+func (b *Backfill[T]) WithLoadGuard(guard *LoadGuard) *Backfill[T] {
+	b.guard = guard
+	return b
+}
+
+// Pause stops the backfill from starting new batches until Resume is called.
+// Running batches complete and are checkpointed.
+func (b *Backfill[T]) Pause() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.resume == nil {
+		b.resume = make(chan struct{})
+		b.progress.Paused = true
+	}
+}
+
+// Resume lets a paused backfill continue
+func (b *Backfill[T]) Resume() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.resume != nil {
+		close(b.resume)
+		b.resume = nil
+		b.progress.Paused = false
+	}
+}
+
+// Progress returns the progress of the backfill
+func (b *Backfill[T]) Progress() BackfillProgress {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	progress := b.progress
+	if progress.Running {
+		if elapsed := time.Since(progress.StartedAt).Seconds(); elapsed > 0 {
+			progress.RowsPerSecond = float64(b.runProcessed()) / elapsed
+		}
+	}
+	return progress
+}
+
+// Reset removes the checkpoint of the backfill so it starts from scratch on
+// the next run
+func (b *Backfill[T]) Reset(ctx context.Context) error {
+	return b.store.Delete(ctx, b.name)
+}
+
+// Run backfills the rows after the last checkpoint until none is left. A
+// backfill that already completed returns immediately. When ctx is cancelled
+// the checkpoint is saved and ErrJobInterrupted is returned.
+func (b *Backfill[T]) Run(ctx context.Context) (*Checkpoint, error) {
+	cp, err := b.store.Load(ctx, b.name)
+	if err != nil {
+		return nil, err
+	}
+	if cp == nil {
+		cp = &Checkpoint{Job: b.name}
+	}
+	if err := b.start(cp); err != nil {
+		return cp, err
+	}
+	defer b.finish(cp)
+	if cp.Completed {
+		return cp, nil
+	}
+
+	if b.config.CountTotal {
+		var remaining int64
+		if err := b.query(ctx, cp.LastKey).Count(&remaining).Error; err != nil {
+			return cp, fmt.Errorf("failed to count rows to backfill: %w", err)
+		}
+		b.mu.Lock()
+		b.progress.Total = cp.Processed + remaining
+		b.mu.Unlock()
+	}
+
+	if cp.LastKey != "" && b.logger != nil {
+		b.logger.Info(ctx, "Resuming backfill from checkpoint",
+			logging.String("job", b.name),
+			logging.String("last_key", cp.LastKey),
+			logging.Int64("processed", cp.Processed))
+	}
+
+	limiter := newRowLimiter(b.config.RowsPerSecond)
+	for {
+		if err := b.wait(ctx); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return cp, b.interrupt(cp, ctxErr)
+			}
+			return cp, fmt.Errorf("backfill %s load check failed: %w", b.name, err)
+		}
+
+		batches, err := b.load(ctx, cp.LastKey)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return cp, b.interrupt(cp, ctxErr)
+			}
+			return cp, fmt.Errorf("backfill %s failed after key %q: %w", b.name, cp.LastKey, err)
+		}
+
+		errs := b.updateBatches(ctx, limiter, batches)
+
+		// Advance over the leading batches that succeeded
+		var failed error
+		for i, batch := range batches {
+			if errs[i] != nil {
+				failed = errs[i]
+				break
+			}
+			if len(batch) == 0 {
+				continue
+			}
+			cp.LastKey = b.keyOf(&batch[len(batch)-1])
+			cp.Processed += int64(len(batch))
+		}
+		cp.Completed = failed == nil && len(batches[len(batches)-1]) < b.config.BatchSize
+		b.record(cp, batches, errs)
+
+		// Persist even if the context was cancelled meanwhile, the batches themselves succeeded
+		if err := b.store.Save(context.WithoutCancel(ctx), cp); err != nil {
+			return cp, err
+		}
+
+		if failed != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return cp, b.interrupt(cp, ctxErr)
+			}
+			return cp, fmt.Errorf("backfill %s failed after key %q: %w", b.name, cp.LastKey, failed)
+		}
+		if cp.Completed {
+			return cp, nil
+		}
+	}
+}
+
+// start marks the backfill as running from cp
+func (b *Backfill[T]) start(cp *Checkpoint) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.progress.Running {
+		return fmt.Errorf("backfill %s is already running", b.name)
+	}
+	b.progress = BackfillProgress{
+		Job:       b.name,
+		Running:   true,
+		Paused:    b.resume != nil,
+		Processed: cp.Processed,
+		LastKey:   cp.LastKey,
+		StartedAt: time.Now(),
+		Completed: cp.Completed,
+		Total:     cp.Processed,
+	}
+	b.startProcessed = cp.Processed
+	return nil
+}
+
+// finish marks the backfill as stopped at cp
+func (b *Backfill[T]) finish(cp *Checkpoint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := time.Since(b.progress.StartedAt).Seconds(); elapsed > 0 {
+		b.progress.RowsPerSecond = float64(b.runProcessed()) / elapsed
+	}
+	b.progress.Running = false
+	b.progress.Completed = cp.Completed
+}
+
+// record updates the progress after a round of batches; callers do not hold the lock
+func (b *Backfill[T]) record(cp *Checkpoint, batches [][]T, errs []error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, err := range errs {
+		if err != nil {
+			b.progress.Failed++
+		} else if len(batches[i]) > 0 {
+			b.progress.Batches++
+		}
+	}
+	b.progress.Processed = cp.Processed
+	b.progress.LastKey = cp.LastKey
+	if b.progress.Total < cp.Processed {
+		b.progress.Total = cp.Processed
+	}
+}
+
+// runProcessed returns the rows processed by the current run; callers hold the lock
+func (b *Backfill[T]) runProcessed() int64 {
+	return b.progress.Processed - b.startProcessed
+}
+
+// wait blocks while the backfill is paused or the load guard holds it
+func (b *Backfill[T]) wait(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		b.mu.Lock()
+		resume := b.resume
+		b.mu.Unlock()
+		if resume == nil {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-resume:
+		}
+	}
+
+	if b.guard != nil {
+		return b.guard.Wait(ctx)
+	}
+	return nil
+}
+
+// query selects the rows of the scope after afterKey
+func (b *Backfill[T]) query(ctx context.Context, afterKey string) *gorm.DB {
+	query := b.db.WithContext(ctx).Model(new(T))
+	if b.scope != nil {
+		query = b.scope(query)
+	}
+	if afterKey != "" {
+		query = query.Where(b.config.KeyColumn+" > ?", afterKey)
+	}
+	return query
+}
+
+// load reads up to Concurrency batches following afterKey; the last batch is
+// shorter than BatchSize, or empty, when no rows are left
+func (b *Backfill[T]) load(ctx context.Context, afterKey string) ([][]T, error) {
+	var batches [][]T
+	for len(batches) < b.config.Concurrency {
+		var batch []T
+		err := b.query(ctx, afterKey).Order(b.config.KeyColumn).Limit(b.config.BatchSize).Find(&batch).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to load batch: %w", err)
+		}
+		if len(batch) == 0 {
+			if len(batches) == 0 {
+				batches = append(batches, batch)
+			}
+			break
+		}
+
+		batches = append(batches, batch)
+		if len(batch) < b.config.BatchSize {
+			break
+		}
+		afterKey = b.keyOf(&batch[len(batch)-1])
+	}
+	return batches, nil
+}
+
+// updateBatches updates the batches in parallel, each in its own
+// transaction, and returns their errors. Batches not started because the
+// rate limiter wait was cancelled report the context error.
+func (b *Backfill[T]) updateBatches(ctx context.Context, limiter *rowLimiter, batches [][]T) []error {
+	errs := make([]error, len(batches))
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		if len(batch) == 0 {
+			continue
+		}
+		if err := limiter.wait(ctx, len(batch)); err != nil {
+			for j := i; j < len(batches); j++ {
+				errs[j] = err
+			}
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, batch []T) {
+			defer wg.Done()
+			errs[i] = b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				return b.update(ctx, tx, batch)
+			})
+		}(i, batch)
+	}
+	wg.Wait()
+	return errs
+}
+
+// interrupt saves the checkpoint and returns the interruption error
+func (b *Backfill[T]) interrupt(cp *Checkpoint, cause error) error {
+	return checkpointInterrupted(b.store, b.logger, b.name, cp, cause)
+}
+
+// entityKey returns the ID of entities embedding models.BaseModel
+func entityKey[T any](entity *T) string {
+	if identified, ok := any(entity).(interface{ GetID() uuid.UUID }); ok {
+		return identified.GetID().String()
+	}
+	return ""
+}
+
+// rowLimiter spaces out rows to a maximum rate
+type rowLimiter struct {
+	perRow time.Duration
+	next   time.Time
+}
+
+// newRowLimiter creates a limiter of rowsPerSecond, unlimited when not positive
+func newRowLimiter(rowsPerSecond float64) *rowLimiter {
+	if rowsPerSecond <= 0 {
+		return &rowLimiter{}
+	}
+	return &rowLimiter{perRow: time.Duration(float64(time.Second) / rowsPerSecond)}
+}
+
+// wait blocks until n more rows fit in the rate or ctx is done
+func (l *rowLimiter) wait(ctx context.Context, n int) error {
+	if l.perRow <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(n) * l.perRow)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,233 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"gorm.io/gorm"
+)
+
+// ErrJobInterrupted is returned when a job stops before completion because its
+// context was cancelled or its deadline left no room for another batch. The
+// checkpoint is saved before returning, so running the job again resumes it.
+var ErrJobInterrupted = errors.New("job interrupted before completion")
+
+// BatchFunc processes up to limit items whose key is greater than afterKey
+// (an empty afterKey means start from the beginning). It returns the key of
+// the last item processed and the number of items processed; a count lower
+// than limit marks the end of the job.
+type BatchFunc func(ctx context.Context, afterKey string, limit int) (lastKey string, count int, err error)
+
+// BatchConfig represents batch runner configuration
+type BatchConfig struct {
+	BatchSize    int           `json:"batch_size"`
+	MinBatchSize int           `json:"min_batch_size"`
+	SafetyMargin time.Duration `json:"safety_margin"`
+}
+
+// DefaultBatchConfig returns default batch runner configuration
+func DefaultBatchConfig() *BatchConfig {
+	return &BatchConfig{
+		BatchSize:    1000,
+		MinBatchSize: 10,
+		SafetyMargin: 2 * time.Second,
+	}
+}
+
+// BatchRunner runs checkpointed batch jobs. Progress is persisted after every
+// batch and batches are shrunk to fit the remaining context deadline, so long
+// migrations and backfills survive deploys and cancellations.
+type BatchRunner struct {
+	store  CheckpointStore
+	logger logging.Logger
+	config *BatchConfig
+	guard  *LoadGuard
+}
+
+// NewBatchRunner creates a new batch runner
+func NewBatchRunner(store CheckpointStore, logger logging.Logger, config *BatchConfig) *BatchRunner {
+	if config == nil {
+		config = DefaultBatchConfig()
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultBatchConfig().BatchSize
+	}
+	if config.MinBatchSize <= 0 || config.MinBatchSize > config.BatchSize {
+		config.MinBatchSize = 1
+	}
+
+	return &BatchRunner{
+		store:  store,
+		logger: logger,
+		config: config,
+	}
+}
+
+// WithLoadGuard makes the runner wait for guard before every batch, pausing the
+// job outside maintenance windows or while the database is under load
+func (r *BatchRunner) WithLoadGuard(guard *LoadGuard) *BatchRunner {
+	r.guard = guard
+	return r
+}
+
+// Run executes fn in batches until it reports completion, resuming from the
+// last checkpoint of job. A job that already completed returns immediately.
+func (r *BatchRunner) Run(ctx context.Context, job string, fn BatchFunc) (*Checkpoint, error) {
+	cp, err := r.store.Load(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	if cp == nil {
+		cp = &Checkpoint{Job: job}
+	}
+	if cp.Completed {
+		return cp, nil
+	}
+
+	if cp.LastKey != "" && r.logger != nil {
+		r.logger.Info(ctx, "Resuming job from checkpoint",
+			logging.String("job", job),
+			logging.String("last_key", cp.LastKey),
+			logging.Int64("processed", cp.Processed))
+	}
+
+	var perItem time.Duration
+	for {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return cp, r.interrupt(job, cp, ctxErr)
+		}
+
+		if r.guard != nil {
+			if err := r.guard.Wait(ctx); err != nil {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return cp, r.interrupt(job, cp, ctxErr)
+				}
+				return cp, fmt.Errorf("job %s load check failed: %w", job, err)
+			}
+		}
+
+		limit := r.batchLimit(ctx, perItem)
+		if limit == 0 {
+			return cp, r.interrupt(job, cp, context.DeadlineExceeded)
+		}
+
+		start := time.Now()
+		lastKey, count, err := fn(ctx, cp.LastKey, limit)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return cp, r.interrupt(job, cp, ctxErr)
+			}
+			return cp, fmt.Errorf("job %s failed after key %q: %w", job, cp.LastKey, err)
+		}
+
+		if count > 0 {
+			perItem = estimatePerItem(perItem, time.Since(start)/time.Duration(count))
+			cp.LastKey = lastKey
+			cp.Processed += int64(count)
+		}
+		cp.Completed = count < limit
+
+		// Persist even if the context was cancelled meanwhile, the batch itself succeeded
+		if err := r.store.Save(context.WithoutCancel(ctx), cp); err != nil {
+			return cp, err
+		}
+
+		if cp.Completed {
+			return cp, nil
+		}
+	}
+}
+
+// Reset removes the checkpoint of job so it starts from scratch on the next run
+func (r *BatchRunner) Reset(ctx context.Context, job string) error {
+	return r.store.Delete(ctx, job)
+}
+
+// batchLimit returns the batch size that fits in the time left before the
+// context deadline, or 0 if not even the minimum batch fits
+func (r *BatchRunner) batchLimit(ctx context.Context, perItem time.Duration) int {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return r.config.BatchSize
+	}
+
+	remaining := time.Until(deadline) - r.config.SafetyMargin
+	if remaining <= 0 {
+		return 0
+	}
+	if perItem <= 0 {
+		return r.config.BatchSize
+	}
+
+	fit := int64(remaining / perItem)
+	if fit < int64(r.config.MinBatchSize) {
+		return 0
+	}
+	if fit < int64(r.config.BatchSize) {
+		return int(fit)
+	}
+	return r.config.BatchSize
+}
+
+// interrupt saves the checkpoint and returns the interruption error
+func (r *BatchRunner) interrupt(job string, cp *Checkpoint, cause error) error {
+	return checkpointInterrupted(r.store, r.logger, job, cp, cause)
+}
+
+// checkpointInterrupted saves the checkpoint of an interrupted job and
+// returns the interruption error
+func checkpointInterrupted(store CheckpointStore, logger logging.Logger, job string, cp *Checkpoint, cause error) error {
+	ctx := context.Background()
+	if err := store.Save(ctx, cp); err != nil {
+		return err
+	}
+
+	if logger != nil {
+		logger.Warn(ctx, "Job interrupted, progress checkpointed",
+			logging.String("job", job),
+			logging.String("last_key", cp.LastKey),
+			logging.Int64("processed", cp.Processed),
+			logging.ErrorField("cause", cause))
+	}
+
+	return fmt.Errorf("%w: %w", ErrJobInterrupted, cause)
+}
+
+// estimatePerItem smooths the observed per-item duration with an exponential moving average
+func estimatePerItem(previous, observed time.Duration) time.Duration {
+	if previous <= 0 {
+		return observed
+	}
+	return (previous*7 + observed*3) / 10
+}
+
+// KeysetBatches returns a BatchFunc that walks the rows of T ordered by
+// keyColumn and hands each batch to handle inside a transaction, so a batch is
+// either fully applied or retried from the same key on the next run.
+func KeysetBatches[T any](db *gorm.DB, keyColumn string, keyOf func(*T) string, handle func(ctx context.Context, tx *gorm.DB, batch []T) error) BatchFunc {
+	return func(ctx context.Context, afterKey string, limit int) (string, int, error) {
+		var batch []T
+		query := db.WithContext(ctx).Order(keyColumn).Limit(limit)
+		if afterKey != "" {
+			query = query.Where(keyColumn+" > ?", afterKey)
+		}
+		if err := query.Find(&batch).Error; err != nil {
+			return "", 0, fmt.Errorf("failed to load batch: %w", err)
+		}
+		if len(batch) == 0 {
+			return afterKey, 0, nil
+		}
+
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return handle(ctx, tx, batch)
+		})
+		if err != nil {
+			return "", 0, err
+		}
+
+		return keyOf(&batch[len(batch)-1]), len(batch), nil
+	}
+}
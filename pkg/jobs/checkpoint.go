@@ -0,0 +1,132 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Checkpoint represents the persisted progress of a long-running job
+type Checkpoint struct {
+	Job       string    `gorm:"primaryKey;size:255" json:"job"`
+	LastKey   string    `gorm:"size:255" json:"last_key"`
+	Processed int64     `gorm:"not null;default:0" json:"processed"`
+	Completed bool      `gorm:"not null;default:false" json:"completed"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName returns the table used to persist checkpoints
+func (Checkpoint) TableName() string {
+	return "ormx_job_checkpoints"
+}
+
+// CheckpointStore persists job checkpoints so jobs can resume after restarts
+type CheckpointStore interface {
+	// Load returns the checkpoint for job, or nil if the job has not started
+	Load(ctx context.Context, job string) (*Checkpoint, error)
+	// Save creates or replaces the checkpoint for cp.Job
+	Save(ctx context.Context, cp *Checkpoint) error
+	// Delete removes the checkpoint for job
+	Delete(ctx context.Context, job string) error
+}
+
+// DBCheckpointStore stores checkpoints in the ormx_job_checkpoints table
+type DBCheckpointStore struct {
+	db *gorm.DB
+}
+
+// NewDBCheckpointStore creates a database backed checkpoint store, creating the table if needed
+func NewDBCheckpointStore(db *gorm.DB) (*DBCheckpointStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+
+	if err := db.AutoMigrate(&Checkpoint{}); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint table: %w", err)
+	}
+
+	return &DBCheckpointStore{db: db}, nil
+}
+
+// Load returns the checkpoint for job
+func (s *DBCheckpointStore) Load(ctx context.Context, job string) (*Checkpoint, error) {
+	var cp Checkpoint
+	err := s.db.WithContext(ctx).Where("job = ?", job).Take(&cp).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	return &cp, nil
+}
+
+// Save creates or replaces the checkpoint for cp.Job
+func (s *DBCheckpointStore) Save(ctx context.Context, cp *Checkpoint) error {
+	cp.UpdatedAt = time.Now()
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "job"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_key", "processed", "completed", "updated_at"}),
+	}).Create(cp).Error
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the checkpoint for job
+func (s *DBCheckpointStore) Delete(ctx context.Context, job string) error {
+	if err := s.db.WithContext(ctx).Where("job = ?", job).Delete(&Checkpoint{}).Error; err != nil {
+		return fmt.Errorf("failed to delete checkpoint: %w", err)
+	}
+	return nil
+}
+
+// MemoryCheckpointStore keeps checkpoints in memory, useful for tests and single-process jobs
+type MemoryCheckpointStore struct {
+	mutex       sync.RWMutex
+	checkpoints map[string]Checkpoint
+}
+
+// NewMemoryCheckpointStore creates an in-memory checkpoint store
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{
+		checkpoints: make(map[string]Checkpoint),
+	}
+}
+
+// Load returns the checkpoint for job
+func (s *MemoryCheckpointStore) Load(ctx context.Context, job string) (*Checkpoint, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	cp, ok := s.checkpoints[job]
+	if !ok {
+		return nil, nil
+	}
+	return &cp, nil
+}
+
+// Save creates or replaces the checkpoint for cp.Job
+func (s *MemoryCheckpointStore) Save(ctx context.Context, cp *Checkpoint) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cp.UpdatedAt = time.Now()
+	s.checkpoints[cp.Job] = *cp
+	return nil
+}
+
+// Delete removes the checkpoint for job
+func (s *MemoryCheckpointStore) Delete(ctx context.Context, job string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.checkpoints, job)
+	return nil
+}
@@ -0,0 +1,239 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"gorm.io/gorm"
+)
+
+// MaintenanceWindow represents a recurring period in which heavy jobs may run.
+// Start and End are offsets from midnight; a window with End before Start spans
+// midnight. An empty Days list means every day.
+type MaintenanceWindow struct {
+	Start    time.Duration  `json:"start"`
+	End      time.Duration  `json:"end"`
+	Days     []time.Weekday `json:"days"`
+	Location *time.Location `json:"-"`
+}
+
+// Contains reports whether t falls inside the window
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	if w.Location != nil {
+		t = t.In(w.Location)
+	}
+
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+	day := t.Weekday()
+
+	if w.Start <= w.End {
+		return w.hasDay(day) && offset >= w.Start && offset < w.End
+	}
+
+	// Overnight window: the part after midnight belongs to the previous day's window
+	if offset >= w.Start {
+		return w.hasDay(day)
+	}
+	if offset < w.End {
+		return w.hasDay((day + 6) % 7)
+	}
+	return false
+}
+
+// hasDay reports whether the window applies to day
+func (w MaintenanceWindow) hasDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadStats represents database load signals sampled by a LoadProbe
+type LoadStats struct {
+	ActiveConnections int           `json:"active_connections"`
+	ActiveQueries     int           `json:"active_queries"`
+	ReplicationLag    time.Duration `json:"replication_lag"`
+}
+
+// LoadProbe samples current database load
+type LoadProbe func(ctx context.Context) (*LoadStats, error)
+
+// PostgresLoadProbe samples load from pg_stat_activity and pg_stat_replication.
+// Queries in the active state are used as a proxy for server CPU pressure.
+func PostgresLoadProbe(db *gorm.DB) LoadProbe {
+	return func(ctx context.Context) (*LoadStats, error) {
+		var activity struct {
+			Connections int
+			Active      int
+		}
+		err := db.WithContext(ctx).Raw(
+			"SELECT count(*) AS connections, count(*) FILTER (WHERE state = 'active' AND pid <> pg_backend_pid()) AS active " +
+				"FROM pg_stat_activity WHERE datname = current_database()").Scan(&activity).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to sample pg_stat_activity: %w", err)
+		}
+
+		var lagSeconds float64
+		err = db.WithContext(ctx).Raw(
+			"SELECT COALESCE(EXTRACT(EPOCH FROM max(replay_lag)), 0) FROM pg_stat_replication").Scan(&lagSeconds).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to sample pg_stat_replication: %w", err)
+		}
+
+		return &LoadStats{
+			ActiveConnections: activity.Connections,
+			ActiveQueries:     activity.Active,
+			ReplicationLag:    time.Duration(lagSeconds * float64(time.Second)),
+		}, nil
+	}
+}
+
+// PoolLoadProbe samples load from the client-side connection pool, used for
+// databases without server-side statistics views
+func PoolLoadProbe(db *gorm.DB) LoadProbe {
+	return func(ctx context.Context) (*LoadStats, error) {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get database instance: %w", err)
+		}
+
+		stats := sqlDB.Stats()
+		return &LoadStats{
+			ActiveConnections: stats.OpenConnections,
+			ActiveQueries:     stats.InUse,
+		}, nil
+	}
+}
+
+// LoadGuardConfig represents load guard configuration. Zero thresholds are not checked.
+type LoadGuardConfig struct {
+	Windows              []MaintenanceWindow `json:"windows"`
+	MaxActiveConnections int                 `json:"max_active_connections"`
+	MaxActiveQueries     int                 `json:"max_active_queries"`
+	MaxReplicationLag    time.Duration       `json:"max_replication_lag"`
+	PollInterval         time.Duration       `json:"poll_interval"`
+}
+
+// DefaultLoadGuardConfig returns default load guard configuration
+func DefaultLoadGuardConfig() *LoadGuardConfig {
+	return &LoadGuardConfig{
+		MaxActiveQueries:  50,
+		MaxReplicationLag: 30 * time.Second,
+		PollInterval:      30 * time.Second,
+	}
+}
+
+// LoadGuard gates heavy background jobs (retention, backfills, index builds)
+// on maintenance windows and database load
+type LoadGuard struct {
+	probe  LoadProbe
+	logger logging.Logger
+	config *LoadGuardConfig
+}
+
+// NewLoadGuard creates a load guard sampling db with the probe suited to its dialect
+func NewLoadGuard(db *gorm.DB, logger logging.Logger, config *LoadGuardConfig) *LoadGuard {
+	probe := PoolLoadProbe(db)
+	switch dialect.For(db).Name() {
+	case "postgres", "cockroachdb":
+		probe = PostgresLoadProbe(db)
+	}
+	return NewLoadGuardWithProbe(probe, logger, config)
+}
+
+// NewLoadGuardWithProbe creates a load guard using a custom load probe
+func NewLoadGuardWithProbe(probe LoadProbe, logger logging.Logger, config *LoadGuardConfig) *LoadGuard {
+	if config == nil {
+		config = DefaultLoadGuardConfig()
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultLoadGuardConfig().PollInterval
+	}
+
+	return &LoadGuard{
+		probe:  probe,
+		logger: logger,
+		config: config,
+	}
+}
+
+// Check reports whether heavy jobs may run now. When they may not, reason
+// describes why (outside maintenance window or which load threshold is exceeded).
+func (g *LoadGuard) Check(ctx context.Context) (allowed bool, reason string, err error) {
+	if !g.inWindow(time.Now()) {
+		return false, "outside maintenance window", nil
+	}
+
+	if g.probe == nil {
+		return true, "", nil
+	}
+
+	stats, err := g.probe(ctx)
+	if err != nil {
+		return false, "", err
+	}
+
+	switch {
+	case g.config.MaxActiveConnections > 0 && stats.ActiveConnections > g.config.MaxActiveConnections:
+		return false, fmt.Sprintf("active connections %d exceed %d", stats.ActiveConnections, g.config.MaxActiveConnections), nil
+	case g.config.MaxActiveQueries > 0 && stats.ActiveQueries > g.config.MaxActiveQueries:
+		return false, fmt.Sprintf("active queries %d exceed %d", stats.ActiveQueries, g.config.MaxActiveQueries), nil
+	case g.config.MaxReplicationLag > 0 && stats.ReplicationLag > g.config.MaxReplicationLag:
+		return false, fmt.Sprintf("replication lag %s exceeds %s", stats.ReplicationLag, g.config.MaxReplicationLag), nil
+	}
+
+	return true, "", nil
+}
+
+// Wait blocks until heavy jobs are allowed to run or ctx is done
+func (g *LoadGuard) Wait(ctx context.Context) error {
+	paused := false
+	for {
+		allowed, reason, err := g.Check(ctx)
+		if err != nil {
+			return err
+		}
+		if allowed {
+			if paused && g.logger != nil {
+				g.logger.Info(ctx, "Resuming background job")
+			}
+			return nil
+		}
+
+		if !paused && g.logger != nil {
+			g.logger.Warn(ctx, "Pausing background job", logging.String("reason", reason))
+		}
+		paused = true
+
+		timer := time.NewTimer(g.config.PollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// inWindow reports whether t is inside a configured maintenance window.
+// Without configured windows jobs may run at any time.
+func (g *LoadGuard) inWindow(t time.Time) bool {
+	if len(g.config.Windows) == 0 {
+		return true
+	}
+	for _, w := range g.config.Windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,451 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrScheduledJobNotFound is returned when a scheduled job is not defined
+var ErrScheduledJobNotFound = errors.New("scheduled job not found")
+
+// Misfire policies deciding what happens to runs missed by more than the
+// misfire threshold, e.g. while every worker was down
+const (
+	// MisfireRunOnce runs the job once and schedules the next run from now
+	MisfireRunOnce = "run_once"
+	// MisfireSkip records the missed run as skipped and schedules the next run from now
+	MisfireSkip = "skip"
+	// MisfireCatchUp runs the job once for every missed run time
+	MisfireCatchUp = "catch_up"
+)
+
+// Run statuses of a JobRun
+const (
+	RunStatusRunning   = "running"
+	RunStatusSucceeded = "succeeded"
+	RunStatusFailed    = "failed"
+	RunStatusSkipped   = "skipped"
+)
+
+// ScheduledJob represents a persistent scheduled job definition and its
+// claim state. Schedule is parsed with ParseSchedule.
+type ScheduledJob struct {
+	Name          string     `gorm:"primaryKey;size:191" json:"name"`
+	Schedule      string     `gorm:"size:255;not null" json:"schedule"`
+	Payload       string     `gorm:"type:text" json:"payload,omitempty"`
+	Paused        bool       `gorm:"not null;index:idx_ormx_scheduled_jobs_due,priority:1" json:"paused"`
+	MisfirePolicy string     `gorm:"size:32;not null" json:"misfire_policy"`
+	NextRunAt     time.Time  `gorm:"not null;index:idx_ormx_scheduled_jobs_due,priority:2" json:"next_run_at"`
+	LastRunAt     *time.Time `json:"last_run_at,omitempty"`
+	ClaimedBy     string     `gorm:"size:255" json:"claimed_by,omitempty"`
+	ClaimedUntil  *time.Time `json:"claimed_until,omitempty"`
+	Version       int64      `gorm:"not null" json:"version"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// TableName returns the table used to persist scheduled jobs
+func (ScheduledJob) TableName() string {
+	return "ormx_scheduled_jobs"
+}
+
+// JobRun represents one execution (or skipped execution) of a scheduled job
+type JobRun struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	Job         string     `gorm:"size:191;not null;index:idx_ormx_job_runs_job,priority:1" json:"job"`
+	ScheduledAt time.Time  `gorm:"not null;index:idx_ormx_job_runs_job,priority:2" json:"scheduled_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Status      string     `gorm:"size:32;not null" json:"status"`
+	Worker      string     `gorm:"size:255" json:"worker,omitempty"`
+	Error       string     `gorm:"type:text" json:"error,omitempty"`
+}
+
+// TableName returns the table used to persist job runs
+func (JobRun) TableName() string {
+	return "ormx_job_runs"
+}
+
+// ClaimedRun represents a due job claimed by this worker and its started run
+type ClaimedRun struct {
+	Job ScheduledJob
+	Run JobRun
+}
+
+// JobHandler executes a claimed scheduled job
+type JobHandler func(ctx context.Context, job *ScheduledJob) error
+
+// SchedulerConfig represents scheduler store configuration
+type SchedulerConfig struct {
+	// Worker identifies this process in claims and run history
+	Worker string `json:"worker"`
+	// LeaseDuration is how long a claim lasts before other workers may run the
+	// job again; it should exceed the longest run
+	LeaseDuration time.Duration `json:"lease_duration"`
+	// MisfireThreshold is how late a run may start before the job's misfire policy applies
+	MisfireThreshold time.Duration `json:"misfire_threshold"`
+	// ClaimBatchSize bounds the number of jobs claimed at once
+	ClaimBatchSize int `json:"claim_batch_size"`
+	// PollInterval is how often Start looks for due jobs
+	PollInterval time.Duration `json:"poll_interval"`
+}
+
+// DefaultSchedulerConfig returns default scheduler store configuration
+func DefaultSchedulerConfig() *SchedulerConfig {
+	hostname, _ := os.Hostname()
+	return &SchedulerConfig{
+		Worker:           fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		LeaseDuration:    5 * time.Minute,
+		MisfireThreshold: time.Minute,
+		ClaimBatchSize:   10,
+		PollInterval:     10 * time.Second,
+	}
+}
+
+// SchedulerStore persists scheduled job definitions and run history so that
+// any number of application instances can run scheduled jobs without an
+// external scheduler. Due jobs are claimed with SELECT ... FOR UPDATE SKIP
+// LOCKED where the dialect supports it and a versioned update otherwise, so
+// each run is executed by a single worker. Runs are at most once: the next run
+// time is advanced when a job is claimed, so a worker crashing mid-run leaves
+// the run in the running state rather than retrying it.
+type SchedulerStore struct {
+	db      *gorm.DB
+	logger  logging.Logger
+	config  *SchedulerConfig
+	dialect dialect.Dialect
+}
+
+// NewSchedulerStore creates a scheduler store, creating its tables if needed
+func NewSchedulerStore(db *gorm.DB, logger logging.Logger, config *SchedulerConfig) (*SchedulerStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+
+	defaults := DefaultSchedulerConfig()
+	if config == nil {
+		config = defaults
+	}
+	if config.Worker == "" {
+		config.Worker = defaults.Worker
+	}
+	if config.LeaseDuration <= 0 {
+		config.LeaseDuration = defaults.LeaseDuration
+	}
+	if config.MisfireThreshold <= 0 {
+		config.MisfireThreshold = defaults.MisfireThreshold
+	}
+	if config.ClaimBatchSize <= 0 {
+		config.ClaimBatchSize = defaults.ClaimBatchSize
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaults.PollInterval
+	}
+
+	if err := db.AutoMigrate(&ScheduledJob{}, &JobRun{}); err != nil {
+		return nil, fmt.Errorf("failed to create scheduler tables: %w", err)
+	}
+
+	return &SchedulerStore{
+		db:      db,
+		logger:  logger,
+		config:  config,
+		dialect: dialect.For(db),
+	}, nil
+}
+
+// Define creates or replaces the definition of job.Name. The next run time is
+// computed from the schedule unless job.NextRunAt is set, and an empty misfire
+// policy selects MisfireRunOnce. The claim state of an existing job is kept.
+func (s *SchedulerStore) Define(ctx context.Context, job *ScheduledJob) error {
+	if job == nil || job.Name == "" {
+		return fmt.Errorf("scheduled job name is required")
+	}
+
+	schedule, err := ParseSchedule(job.Schedule)
+	if err != nil {
+		return err
+	}
+
+	switch job.MisfirePolicy {
+	case "":
+		job.MisfirePolicy = MisfireRunOnce
+	case MisfireRunOnce, MisfireSkip, MisfireCatchUp:
+	default:
+		return fmt.Errorf("unknown misfire policy %q", job.MisfirePolicy)
+	}
+
+	if job.NextRunAt.IsZero() {
+		job.NextRunAt = schedule.Next(time.Now())
+	}
+	job.NextRunAt = job.NextRunAt.UTC()
+
+	err = s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"schedule", "payload", "paused", "misfire_policy", "next_run_at", "updated_at"}),
+	}).Create(job).Error
+	if err != nil {
+		return fmt.Errorf("failed to define scheduled job %s: %w", job.Name, err)
+	}
+	return nil
+}
+
+// Get returns the definition of job name. The error wraps
+// ErrScheduledJobNotFound when it is not defined.
+func (s *SchedulerStore) Get(ctx context.Context, name string) (*ScheduledJob, error) {
+	var job ScheduledJob
+	err := s.db.WithContext(ctx).Where("name = ?", name).Take(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrScheduledJobNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scheduled job %s: %w", name, err)
+	}
+	return &job, nil
+}
+
+// Remove deletes the definition and run history of job name
+func (s *SchedulerStore) Remove(ctx context.Context, name string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("job = ?", name).Delete(&JobRun{}).Error; err != nil {
+			return fmt.Errorf("failed to delete runs of scheduled job %s: %w", name, err)
+		}
+		if err := tx.Where("name = ?", name).Delete(&ScheduledJob{}).Error; err != nil {
+			return fmt.Errorf("failed to delete scheduled job %s: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// Claim claims up to ClaimBatchSize unpaused jobs that are due and not claimed
+// by another worker, starting a run for each. Each job's next run time is
+// advanced according to its schedule and misfire policy; misfired runs of
+// MisfireSkip jobs are recorded as skipped instead of being returned.
+func (s *SchedulerStore) Claim(ctx context.Context) ([]ClaimedRun, error) {
+	var claimed []ClaimedRun
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+
+		var due []ScheduledJob
+		query := s.lockSkipLocked(tx).
+			Where("paused = ? AND next_run_at <= ?", false, now).
+			Where("claimed_until IS NULL OR claimed_until < ?", now).
+			Order("next_run_at").
+			Limit(s.config.ClaimBatchSize)
+		if err := query.Find(&due).Error; err != nil {
+			return fmt.Errorf("failed to load due jobs: %w", err)
+		}
+
+		for _, job := range due {
+			run, ok, err := s.claimJob(tx, job, now)
+			if err != nil {
+				return err
+			}
+			if ok {
+				claimed = append(claimed, *run)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// claimJob advances job past its due run and records the run. It reports
+// false when another worker claimed the job first or the run was skipped.
+func (s *SchedulerStore) claimJob(tx *gorm.DB, job ScheduledJob, now time.Time) (*ClaimedRun, bool, error) {
+	schedule, err := ParseSchedule(job.Schedule)
+	if err != nil {
+		return nil, false, fmt.Errorf("scheduled job %s: %w", job.Name, err)
+	}
+
+	scheduledAt := job.NextRunAt
+	misfired := now.Sub(scheduledAt) > s.config.MisfireThreshold
+
+	next := schedule.Next(now)
+	if misfired && job.MisfirePolicy == MisfireCatchUp {
+		next = schedule.Next(scheduledAt)
+	}
+	skip := misfired && job.MisfirePolicy == MisfireSkip
+
+	updates := map[string]interface{}{
+		"next_run_at": next,
+		"version":     job.Version + 1,
+		"updated_at":  now,
+	}
+	if !skip {
+		updates["last_run_at"] = now
+		updates["claimed_by"] = s.config.Worker
+		updates["claimed_until"] = now.Add(s.config.LeaseDuration)
+	}
+
+	result := tx.Model(&ScheduledJob{}).Where("name = ? AND version = ?", job.Name, job.Version).Updates(updates)
+	if result.Error != nil {
+		return nil, false, fmt.Errorf("failed to claim scheduled job %s: %w", job.Name, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, false, nil
+	}
+
+	run := JobRun{
+		ID:          utils.GenerateUUIDv7(),
+		Job:         job.Name,
+		ScheduledAt: scheduledAt,
+		Status:      RunStatusRunning,
+		Worker:      s.config.Worker,
+	}
+	if skip {
+		run.Status = RunStatusSkipped
+		run.FinishedAt = &now
+	} else {
+		run.StartedAt = &now
+	}
+	if err := tx.Create(&run).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to record run of scheduled job %s: %w", job.Name, err)
+	}
+
+	if skip {
+		if s.logger != nil {
+			s.logger.Warn(tx.Statement.Context, "Skipping misfired scheduled job run",
+				logging.String("job", job.Name),
+				logging.String("scheduled_at", scheduledAt.Format(time.RFC3339)))
+		}
+		return nil, false, nil
+	}
+
+	job.NextRunAt = next
+	job.LastRunAt = &now
+	job.ClaimedBy = s.config.Worker
+	claimedUntil := now.Add(s.config.LeaseDuration)
+	job.ClaimedUntil = &claimedUntil
+	job.Version++
+
+	return &ClaimedRun{Job: job, Run: run}, true, nil
+}
+
+// Complete records the outcome of a claimed run and releases the job's claim
+func (s *SchedulerStore) Complete(ctx context.Context, claimed *ClaimedRun, runErr error) error {
+	now := time.Now().UTC()
+
+	status, message := RunStatusSucceeded, ""
+	if runErr != nil {
+		status, message = RunStatusFailed, runErr.Error()
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&JobRun{}).Where("id = ?", claimed.Run.ID).Updates(map[string]interface{}{
+			"status":      status,
+			"error":       message,
+			"finished_at": now,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&ScheduledJob{}).
+			Where("name = ? AND claimed_by = ?", claimed.Job.Name, s.config.Worker).
+			Updates(map[string]interface{}{
+				"claimed_by":    "",
+				"claimed_until": nil,
+				"updated_at":    now,
+			}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete run of scheduled job %s: %w", claimed.Job.Name, err)
+	}
+
+	claimed.Run.Status, claimed.Run.Error, claimed.Run.FinishedAt = status, message, &now
+	return nil
+}
+
+// History returns the most recent runs of job name, newest first
+func (s *SchedulerStore) History(ctx context.Context, name string, limit int) ([]JobRun, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	var runs []JobRun
+	err := s.db.WithContext(ctx).
+		Where("job = ?", name).
+		Order("scheduled_at DESC").Order("id DESC").
+		Limit(limit).
+		Find(&runs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load runs of scheduled job %s: %w", name, err)
+	}
+	return runs, nil
+}
+
+// RunDue claims the due jobs and runs them with handler, returning the number
+// of runs executed. Handler errors are recorded in the run history.
+func (s *SchedulerStore) RunDue(ctx context.Context, handler JobHandler) (int, error) {
+	claimed, err := s.Claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range claimed {
+		run := &claimed[i]
+		runErr := handler(ctx, &run.Job)
+		if runErr != nil && s.logger != nil {
+			s.logger.Error(ctx, "Scheduled job run failed",
+				logging.String("job", run.Job.Name),
+				logging.ErrorField("error", runErr))
+		}
+
+		// Record the outcome even if the context was cancelled during the run
+		if err := s.Complete(context.WithoutCancel(ctx), run, runErr); err != nil {
+			return i, err
+		}
+	}
+	return len(claimed), nil
+}
+
+// Start runs due jobs with handler every PollInterval until ctx is done
+func (s *SchedulerStore) Start(ctx context.Context, handler JobHandler) error {
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.RunDue(ctx, handler); err != nil && ctx.Err() == nil && s.logger != nil {
+			s.logger.Error(ctx, "Failed to run due scheduled jobs", logging.ErrorField("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// lockSkipLocked locks the selected rows, skipping rows locked by other
+// workers when the dialect supports it. Dialects without row locks (SQLite)
+// serialize writers, and the versioned claim update keeps claims exclusive.
+func (s *SchedulerStore) lockSkipLocked(tx *gorm.DB) *gorm.DB {
+	locker, ok := s.dialect.(dialect.SkipLocker)
+	if !ok {
+		return tx
+	}
+
+	locking := locker.ForUpdateSkipLocked()
+	if locking.TableHint != "" {
+		tx = tx.Table(fmt.Sprintf("%s %s", ScheduledJob{}.TableName(), locking.TableHint))
+	}
+	if locking.Suffix != "" {
+		tx = tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate, Options: "SKIP LOCKED"})
+	}
+	return tx
+}
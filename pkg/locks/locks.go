@@ -0,0 +1,382 @@
+// Package locks provides distributed locks for processes coordinating
+// through the same database, e.g. jobs that must not run concurrently on
+// several instances:
+//
+//	locker, err := locks.NewLocker(db, logger, nil)
+//	err = locker.WithLock(ctx, "reports:daily", func(ctx context.Context) error {
+//		return buildDailyReport(ctx)
+//	})
+//
+// Postgres locks are session advisory locks and MySQL locks are named locks
+// (GET_LOCK), both held on a dedicated connection and released by the server
+// when the connection closes. Other dialects (SQLite, SQL Server, Oracle,
+// CockroachDB) use leases in a lock table, taken over once their TTL
+// expires.
+package locks
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrNotAcquired is returned when a lock is held by another owner
+	ErrNotAcquired = errors.New("lock is held by another owner")
+	// ErrLockLost is returned when a lease expired and was taken over
+	ErrLockLost = errors.New("lock was lost")
+)
+
+// LockRecord represents a lease of the lock table
+type LockRecord struct {
+	Key        string    `gorm:"column:lock_key;primaryKey;size:191" json:"key"`
+	Owner      string    `gorm:"size:255;not null" json:"owner"`
+	Token      string    `gorm:"size:36;not null" json:"token"`
+	AcquiredAt time.Time `gorm:"not null" json:"acquired_at"`
+	ExpiresAt  time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+// TableName returns the table holding the leases
+func (LockRecord) TableName() string {
+	return "ormx_locks"
+}
+
+// LockerConfig represents locker configuration
+type LockerConfig struct {
+	// Owner identifies this process in leases; defaults to hostname and pid
+	Owner string `json:"owner"`
+	// TTL is the lease duration used by WithLock
+	TTL time.Duration `json:"ttl"`
+	// RetryInterval is the interval between attempts to take a held lock
+	RetryInterval time.Duration `json:"retry_interval"`
+}
+
+// DefaultLockerConfig returns default locker configuration
+func DefaultLockerConfig() *LockerConfig {
+	hostname, _ := os.Hostname()
+	return &LockerConfig{
+		Owner:         fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		TTL:           time.Minute,
+		RetryInterval: 250 * time.Millisecond,
+	}
+}
+
+// Locker acquires named locks through a database
+type Locker struct {
+	db      *gorm.DB
+	logger  logging.Logger
+	config  *LockerConfig
+	dialect dialect.Dialect
+}
+
+// NewLocker creates a locker, creating the lock table on dialects without
+// advisory locks
+func NewLocker(db *gorm.DB, logger logging.Logger, config *LockerConfig) (*Locker, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+
+	defaults := DefaultLockerConfig()
+	if config == nil {
+		config = defaults
+	}
+	if config.Owner == "" {
+		config.Owner = defaults.Owner
+	}
+	if config.TTL <= 0 {
+		config.TTL = defaults.TTL
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = defaults.RetryInterval
+	}
+
+	l := &Locker{db: db, logger: logger, config: config, dialect: dialect.For(db)}
+	if !l.advisory() {
+		if err := db.AutoMigrate(&LockRecord{}); err != nil {
+			return nil, fmt.Errorf("failed to create lock table: %w", err)
+		}
+	}
+	return l, nil
+}
+
+// advisory reports whether the dialect has advisory locks
+func (l *Locker) advisory() bool {
+	name := l.dialect.Name()
+	return name == "postgres" || name == "mysql"
+}
+
+// Lock is a held lock
+type Lock struct {
+	key    string
+	token  string
+	ttl    time.Duration
+	locker *Locker
+
+	mu       sync.Mutex
+	conn     *sql.Conn
+	released bool
+}
+
+// Key returns the key of the lock
+func (lock *Lock) Key() string {
+	return lock.key
+}
+
+// AcquireAdvisoryLock takes the lock named key, waiting while another owner
+// holds it until ctx is done. ttl bounds the lease of table-based locks,
+// which another owner takes over once it expires unless it is refreshed;
+// advisory locks are held until released or their connection closes.
+func (l *Locker) AcquireAdvisoryLock(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	for {
+		lock, err := l.TryAcquire(ctx, key, ttl)
+		if !errors.Is(err, ErrNotAcquired) {
+			return lock, err
+		}
+
+		timer := time.NewTimer(l.config.RetryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("%w: %s: %v", ErrNotAcquired, key, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// TryAcquire takes the lock named key without waiting, returning
+// ErrNotAcquired when another owner holds it
+func (l *Locker) TryAcquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	if key == "" {
+		return nil, fmt.Errorf("lock key is required")
+	}
+	if ttl <= 0 {
+		ttl = l.config.TTL
+	}
+
+	lock := &Lock{key: key, token: uuid.NewString(), ttl: ttl, locker: l}
+	var err error
+	if l.advisory() {
+		err = l.acquireAdvisory(ctx, lock)
+	} else {
+		err = l.acquireLease(ctx, lock)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if l.logger != nil {
+		l.logger.Debug(ctx, "Lock acquired",
+			logging.String("key", key),
+			logging.String("owner", l.config.Owner))
+	}
+	return lock, nil
+}
+
+// WithLock runs fn while holding the lock named key, waiting for it while ctx
+// allows. Leases are refreshed while fn runs; the context passed to fn is
+// cancelled if the lock is lost.
+func (l *Locker) WithLock(ctx context.Context, key string, fn func(ctx context.Context) error) error {
+	if fn == nil {
+		return fmt.Errorf("lock function is required")
+	}
+
+	lock, err := l.AcquireAdvisoryLock(ctx, key, l.config.TTL)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Release even when ctx is cancelled
+		if err := lock.Release(context.WithoutCancel(ctx)); err != nil && l.logger != nil {
+			l.logger.Error(ctx, "Failed to release lock",
+				logging.String("key", key),
+				logging.ErrorField("error", err))
+		}
+	}()
+
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if lock.conn == nil {
+		go lock.keepAlive(runCtx, cancel)
+	}
+	return fn(runCtx)
+}
+
+// keepAlive refreshes a lease until ctx is done, cancelling it when the lease is lost
+func (lock *Lock) keepAlive(ctx context.Context, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(lock.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := lock.Refresh(ctx); err != nil {
+				if ctx.Err() == nil {
+					cancel(err)
+				}
+				return
+			}
+		}
+	}
+}
+
+// Refresh extends the lease of a table-based lock by its TTL, returning
+// ErrLockLost when it expired and another owner took it. Advisory locks need
+// no refresh.
+func (lock *Lock) Refresh(ctx context.Context) error {
+	lock.mu.Lock()
+	defer lock.mu.Unlock()
+
+	if lock.released {
+		return fmt.Errorf("%w: %s was released", ErrLockLost, lock.key)
+	}
+	if lock.conn != nil {
+		return nil
+	}
+
+	result := lock.locker.db.WithContext(ctx).Model(&LockRecord{}).
+		Where("lock_key = ? AND token = ?", lock.key, lock.token).
+		Update("expires_at", time.Now().UTC().Add(lock.ttl))
+	if result.Error != nil {
+		return fmt.Errorf("failed to refresh lock %s: %w", lock.key, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrLockLost, lock.key)
+	}
+	return nil
+}
+
+// Release releases the lock. Releasing a lease taken over by another owner
+// returns ErrLockLost and leaves it to that owner.
+func (lock *Lock) Release(ctx context.Context) error {
+	lock.mu.Lock()
+	defer lock.mu.Unlock()
+
+	if lock.released {
+		return nil
+	}
+	lock.released = true
+
+	l := lock.locker
+	if lock.conn != nil {
+		defer lock.conn.Close()
+		var released sql.NullInt64
+		query, arg := l.advisoryStatement(lock.key, false)
+		if err := lock.conn.QueryRowContext(ctx, query, arg).Scan(&released); err != nil {
+			return fmt.Errorf("failed to release lock %s: %w", lock.key, err)
+		}
+		return nil
+	}
+
+	result := l.db.WithContext(ctx).Where("lock_key = ? AND token = ?", lock.key, lock.token).Delete(&LockRecord{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to release lock %s: %w", lock.key, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrLockLost, lock.key)
+	}
+	return nil
+}
+
+// acquireAdvisory takes an advisory lock on a dedicated connection
+func (l *Locker) acquireAdvisory(ctx context.Context, lock *Lock) error {
+	sqlDB, err := l.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+
+	var acquired sql.NullInt64
+	query, arg := l.advisoryStatement(lock.key, true)
+	if err := conn.QueryRowContext(ctx, query, arg).Scan(&acquired); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to acquire lock %s: %w", lock.key, err)
+	}
+	if acquired.Int64 != 1 {
+		conn.Close()
+		return fmt.Errorf("%w: %s", ErrNotAcquired, lock.key)
+	}
+	lock.conn = conn
+	return nil
+}
+
+// advisoryStatement returns the statement taking or releasing the advisory
+// lock of key and its argument: a 64-bit hash of the key on Postgres, the
+// key itself on MySQL, hashed when longer than its 64 characters limit
+func (l *Locker) advisoryStatement(key string, acquire bool) (string, interface{}) {
+	if l.dialect.Name() == "postgres" {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		if acquire {
+			return "SELECT CASE WHEN pg_try_advisory_lock($1) THEN 1 ELSE 0 END", int64(h.Sum64())
+		}
+		return "SELECT CASE WHEN pg_advisory_unlock($1) THEN 1 ELSE 0 END", int64(h.Sum64())
+	}
+
+	if len(key) > 64 {
+		sum := sha1.Sum([]byte(key))
+		key = hex.EncodeToString(sum[:])
+	}
+	if acquire {
+		return "SELECT GET_LOCK(?, 0)", key
+	}
+	return "SELECT RELEASE_LOCK(?)", key
+}
+
+// acquireLease takes a lease in the lock table, taking over an expired one
+func (l *Locker) acquireLease(ctx context.Context, lock *Lock) error {
+	db := l.db.WithContext(ctx)
+	now := time.Now().UTC()
+	record := &LockRecord{
+		Key:        lock.key,
+		Owner:      l.config.Owner,
+		Token:      lock.token,
+		AcquiredAt: now,
+		ExpiresAt:  now.Add(lock.ttl),
+	}
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return fmt.Errorf("failed to acquire lock %s: %w", lock.key, result.Error)
+	}
+	if result.RowsAffected == 1 {
+		return nil
+	}
+
+	result = db.Model(&LockRecord{}).
+		Where("lock_key = ? AND expires_at < ?", lock.key, now).
+		Updates(map[string]interface{}{
+			"owner":       record.Owner,
+			"token":       record.Token,
+			"acquired_at": record.AcquiredAt,
+			"expires_at":  record.ExpiresAt,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to acquire lock %s: %w", lock.key, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrNotAcquired, lock.key)
+	}
+	if l.logger != nil {
+		l.logger.Warn(ctx, "Took over expired lock",
+			logging.String("key", lock.key),
+			logging.String("owner", l.config.Owner))
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy decides what an asynchronous logger does with an entry
+// logged while its buffer is full
+type OverflowPolicy int
+
+const (
+	// OverflowDrop drops the entry, counted by Dropped
+	OverflowDrop OverflowPolicy = iota
+	// OverflowBlock waits until the flusher makes room for the entry
+	OverflowBlock
+)
+
+// AsyncConfig configures an asynchronous logger
+type AsyncConfig struct {
+	// BufferSize is the number of formatted entries buffered before the
+	// overflow policy applies (1024 when not set)
+	BufferSize int
+	// FlushInterval is the longest an entry waits in the buffer before it
+	// is written (100ms when not set); the buffer is also written as soon as
+	// it is half full
+	FlushInterval time.Duration
+	// Overflow is what happens to the entries logged while the buffer is full
+	Overflow OverflowPolicy
+}
+
+// DefaultAsyncConfig returns the default asynchronous logger configuration
+func DefaultAsyncConfig() AsyncConfig {
+	return AsyncConfig{
+		BufferSize:    1024,
+		FlushInterval: 100 * time.Millisecond,
+		Overflow:      OverflowDrop,
+	}
+}
+
+// NewAsyncLogger creates a logger formatting entries on the calling
+// goroutine and writing them to output from a background flusher, so
+// logging does not wait for the output. Flush waits until the buffered
+// entries are written and Close writes them before stopping the flusher;
+// entries logged after Close are written synchronously. Loggers derived with
+// WithFields and WithContext share the buffer.
+func NewAsyncLogger(level LogLevel, output io.Writer, formatter LogFormatter, config *AsyncConfig) *BaseLogger {
+	l := NewLogger(level, output, formatter)
+
+	async := DefaultAsyncConfig()
+	if config != nil {
+		async.Overflow = config.Overflow
+		if config.BufferSize > 0 {
+			async.BufferSize = config.BufferSize
+		}
+		if config.FlushInterval > 0 {
+			async.FlushInterval = config.FlushInterval
+		}
+	}
+	l.output.startAsync(async)
+	return l
+}
+
+// Flush waits until the entries buffered by an asynchronous logger are
+// written; it returns at once for a synchronous logger
+func (l *BaseLogger) Flush() error {
+	l.output.flush()
+	return nil
+}
+
+// Dropped returns the number of entries an asynchronous logger dropped
+// because its buffer was full
+func (l *BaseLogger) Dropped() int64 {
+	return l.output.dropped.Load()
+}
+
+// logOutput is the output shared by a logger and the loggers derived from
+// it. Writes are serialized, so concurrent entries are not interleaved, and
+// go through a ring buffer drained by a background flusher in async mode.
+type logOutput struct {
+	writeMu sync.Mutex
+	writer  io.Writer
+	// closer closes the writer the logger owns, nil when it owns none
+	closer io.Closer
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	async   bool
+	config  AsyncConfig
+	entries []outputEntry
+	head    int
+	count   int
+	// writing is set while the flusher writes entries taken from the buffer
+	writing bool
+	closed  bool
+	wake    chan struct{}
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+// outputEntry is a formatted entry and its level
+type outputEntry struct {
+	level LogLevel
+	data  []byte
+}
+
+// newLogOutput creates a synchronous output writing to writer
+func newLogOutput(writer io.Writer) *logOutput {
+	o := &logOutput{writer: writer}
+	o.cond = sync.NewCond(&o.mu)
+	return o
+}
+
+// startAsync switches the output to async mode and starts its flusher
+func (o *logOutput) startAsync(config AsyncConfig) {
+	o.async = true
+	o.config = config
+	o.entries = make([]outputEntry, config.BufferSize)
+	o.wake = make(chan struct{}, 1)
+	o.done = make(chan struct{})
+	go o.run()
+}
+
+// write writes an entry, or buffers it in async mode
+func (o *logOutput) write(entry outputEntry) {
+	if !o.async || !o.enqueue(entry) {
+		o.writeNow(entry)
+	}
+}
+
+// writeNow writes an entry to the writer
+func (o *logOutput) writeNow(entry outputEntry) {
+	o.writeMu.Lock()
+	defer o.writeMu.Unlock()
+
+	var err error
+	if writer, ok := o.writer.(levelWriter); ok {
+		_, err = writer.WriteLevel(entry.level, entry.data)
+	} else {
+		_, err = o.writer.Write(entry.data)
+	}
+	if err != nil {
+		// Log to stderr if we can't write to output
+		fmt.Fprintf(os.Stderr, "[ERROR] Failed to write log entry: %v\n", err)
+	}
+}
+
+// enqueue buffers an entry, applying the overflow policy when the buffer is
+// full; it reports false once the output is closed
+func (o *logOutput) enqueue(entry outputEntry) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	size := len(o.entries)
+	for o.count == size && !o.closed {
+		o.signal()
+		if o.config.Overflow != OverflowBlock {
+			o.dropped.Add(1)
+			return true
+		}
+		o.cond.Wait()
+	}
+	if o.closed {
+		return false
+	}
+
+	o.entries[(o.head+o.count)%size] = entry
+	o.count++
+	if o.count >= (size+1)/2 {
+		o.signal()
+	}
+	return true
+}
+
+// signal wakes the flusher; callers hold the lock
+func (o *logOutput) signal() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// take removes the buffered entries, marking them as being written, and
+// reports whether the output is closed
+func (o *logOutput) take() ([]outputEntry, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	batch := make([]outputEntry, 0, o.count)
+	for ; o.count > 0; o.count-- {
+		batch = append(batch, o.entries[o.head])
+		o.entries[o.head] = outputEntry{}
+		o.head = (o.head + 1) % len(o.entries)
+	}
+	o.writing = len(batch) > 0
+	if o.writing {
+		// Room was made for blocked writers
+		o.cond.Broadcast()
+	}
+	return batch, o.closed
+}
+
+// run is the flusher, writing the buffer when signaled and on every flush
+// interval until the output is closed and drained
+func (o *logOutput) run() {
+	defer close(o.done)
+
+	ticker := time.NewTicker(o.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.wake:
+		case <-ticker.C:
+		}
+		for {
+			batch, closed := o.take()
+			if len(batch) == 0 {
+				if closed {
+					return
+				}
+				break
+			}
+			o.writeBatch(batch)
+		}
+	}
+}
+
+// writeBatch writes entries taken from the buffer
+func (o *logOutput) writeBatch(batch []outputEntry) {
+	for _, entry := range batch {
+		o.writeNow(entry)
+	}
+
+	o.mu.Lock()
+	o.writing = false
+	o.cond.Broadcast()
+	o.mu.Unlock()
+}
+
+// flush waits until the buffered entries are written
+func (o *logOutput) flush() {
+	if !o.async {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for o.count > 0 || o.writing {
+		o.signal()
+		o.cond.Wait()
+	}
+}
+
+// close writes the buffered entries, stops the flusher and closes the
+// writer the logger owns
+func (o *logOutput) close() {
+	o.drain()
+
+	o.writeMu.Lock()
+	defer o.writeMu.Unlock()
+	if o.closer != nil {
+		if err := o.closer.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "[ERROR] Failed to close log output: %v\n", err)
+		}
+		o.closer = nil
+	}
+}
+
+// drain writes the buffered entries and stops the flusher
+func (o *logOutput) drain() {
+	if !o.async {
+		return
+	}
+	o.mu.Lock()
+	if o.closed {
+		o.mu.Unlock()
+		<-o.done
+		return
+	}
+	o.closed = true
+	o.cond.Broadcast()
+	o.signal()
+	o.mu.Unlock()
+
+	<-o.done
+}
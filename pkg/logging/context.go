@@ -0,0 +1,139 @@
+package logging
+
+import (
+	"context"
+	"sync"
+)
+
+// contextKey is the type of the context keys of this package, so they do
+// not collide with the keys of other packages
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	traceIDKey
+	spanIDKey
+	userIDKey
+	tenantIDKey
+)
+
+// ContextWithRequestID returns a context whose log entries carry request_id
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext returns the request ID set with ContextWithRequestID
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	return stringFromContext(ctx, requestIDKey)
+}
+
+// ContextWithTraceID returns a context whose log entries carry trace_id
+func ContextWithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey, traceID)
+}
+
+// TraceIDFromContext returns the trace ID set with ContextWithTraceID
+func TraceIDFromContext(ctx context.Context) (string, bool) {
+	return stringFromContext(ctx, traceIDKey)
+}
+
+// ContextWithSpanID returns a context whose log entries carry span_id
+func ContextWithSpanID(ctx context.Context, spanID string) context.Context {
+	return context.WithValue(ctx, spanIDKey, spanID)
+}
+
+// SpanIDFromContext returns the span ID set with ContextWithSpanID
+func SpanIDFromContext(ctx context.Context) (string, bool) {
+	return stringFromContext(ctx, spanIDKey)
+}
+
+// ContextWithUserID returns a context whose log entries carry user_id
+func ContextWithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// UserIDFromContext returns the user ID set with ContextWithUserID
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	return stringFromContext(ctx, userIDKey)
+}
+
+// ContextWithTenantID returns a context whose log entries carry tenant_id
+func ContextWithTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantIDKey, tenantID)
+}
+
+// TenantIDFromContext returns the tenant ID set with ContextWithTenantID
+func TenantIDFromContext(ctx context.Context) (string, bool) {
+	return stringFromContext(ctx, tenantIDKey)
+}
+
+// stringFromContext returns the string value of key in ctx
+func stringFromContext(ctx context.Context, key contextKey) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	value, ok := ctx.Value(key).(string)
+	return value, ok
+}
+
+// ContextFieldExtractor returns the fields of a context added to every log
+// entry logged with it
+type ContextFieldExtractor func(ctx context.Context) []LogField
+
+var (
+	extractorsMu   sync.RWMutex
+	extractorNames []string
+	extractors     = map[string]ContextFieldExtractor{}
+)
+
+// RegisterContextFieldExtractor registers an extractor under name, replacing
+// the extractor registered under the same name. The fields of the extractors
+// follow the built-in context fields in every entry, in registration order,
+// for example:
+//
+//	logging.RegisterContextFieldExtractor("session", func(ctx context.Context) []logging.LogField {
+//		if session, ok := ctx.Value(sessionKey{}).(*Session); ok {
+//			return []logging.LogField{logging.String("session_id", session.ID)}
+//		}
+//		return nil
+//	})
+func RegisterContextFieldExtractor(name string, extractor ContextFieldExtractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+
+	if _, ok := extractors[name]; !ok {
+		extractorNames = append(extractorNames, name)
+	}
+	extractors[name] = extractor
+}
+
+// UnregisterContextFieldExtractor removes the extractor registered under
+// name, if any
+func UnregisterContextFieldExtractor(name string) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+
+	if _, ok := extractors[name]; !ok {
+		return
+	}
+	delete(extractors, name)
+	for i, registered := range extractorNames {
+		if registered == name {
+			extractorNames = append(extractorNames[:i:i], extractorNames[i+1:]...)
+			break
+		}
+	}
+}
+
+// registeredExtractors returns the registered extractors in registration
+// order
+func registeredExtractors() []ContextFieldExtractor {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+
+	registered := make([]ContextFieldExtractor, 0, len(extractorNames))
+	for _, name := range extractorNames {
+		registered = append(registered, extractors[name])
+	}
+	return registered
+}
@@ -0,0 +1,216 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// GormLoggerConfig configures a GormLogger
+type GormLoggerConfig struct {
+	// SlowThreshold is the duration above which statements are logged as
+	// slow queries: the SlowQueryThreshold of DefaultLoggerConfig when zero,
+	// none when negative
+	SlowThreshold time.Duration
+	// IgnoreRecordNotFound does not log the queries failing only because
+	// they found no record
+	IgnoreRecordNotFound bool
+	// ParameterizedQueries logs statements with their placeholders instead
+	// of their parameters
+	ParameterizedQueries bool
+	// SensitiveColumns are the columns whose parameters are masked in the
+	// logged statements, such as "password", matched without their table and
+	// regardless of case
+	SensitiveColumns []string
+	// Mask replaces the masked parameters (default "***")
+	Mask string
+}
+
+// GormLogger routes the logs of gorm (gorm.Config.Logger) through a Logger:
+//
+//	db, err := gorm.Open(dialector, &gorm.Config{
+//		Logger: logging.NewGormLogger(logger, &logging.GormLoggerConfig{SensitiveColumns: []string{"password"}}),
+//	})
+//
+// Failed statements are logged as errors, slow statements as warnings and
+// the others at debug level. The gorm log level follows the level of the
+// logger unless set with LogMode: debug logs every statement, info and warn
+// the slow and failed ones, error and fatal only the failed ones.
+type GormLogger struct {
+	logger    Logger
+	config    GormLoggerConfig
+	level     gormlogger.LogLevel
+	sensitive map[string]bool
+}
+
+// NewGormLogger creates a gorm logger writing to logger, configured with the
+// defaults when config is nil
+func NewGormLogger(logger Logger, config *GormLoggerConfig) *GormLogger {
+	l := &GormLogger{logger: logger, sensitive: make(map[string]bool)}
+	if config != nil {
+		l.config = *config
+	}
+	if l.config.SlowThreshold == 0 {
+		l.config.SlowThreshold = DefaultLoggerConfig().SlowQueryThreshold
+	}
+	if l.config.Mask == "" {
+		l.config.Mask = "***"
+	}
+	for _, column := range l.config.SensitiveColumns {
+		l.sensitive[strings.ToLower(column)] = true
+	}
+
+	switch level := logger.GetLevel(); {
+	case level <= LogLevelDebug:
+		l.level = gormlogger.Info
+	case level <= LogLevelWarn:
+		l.level = gormlogger.Warn
+	default:
+		l.level = gormlogger.Error
+	}
+	return l
+}
+
+// LogMode returns the logger at the gorm log level
+func (l *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	clone := *l
+	clone.level = level
+	return &clone
+}
+
+// Info logs a gorm message at info level
+func (l *GormLogger) Info(ctx context.Context, message string, data ...interface{}) {
+	if l.level >= gormlogger.Info {
+		l.logger.Info(ctx, fmt.Sprintf(message, data...))
+	}
+}
+
+// Warn logs a gorm message at warn level
+func (l *GormLogger) Warn(ctx context.Context, message string, data ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		l.logger.Warn(ctx, fmt.Sprintf(message, data...))
+	}
+}
+
+// Error logs a gorm message at error level
+func (l *GormLogger) Error(ctx context.Context, message string, data ...interface{}) {
+	if l.level >= gormlogger.Error {
+		l.logger.Error(ctx, fmt.Sprintf(message, data...))
+	}
+}
+
+// Trace logs a finished statement
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	fields := func() []LogField {
+		sql, rows := fc()
+		return []LogField{
+			String("query", sql),
+			Duration("duration", elapsed),
+			Int64("rows", rows),
+		}
+	}
+	switch {
+	case err != nil && l.level >= gormlogger.Error && (!l.config.IgnoreRecordNotFound || !errors.Is(err, gormlogger.ErrRecordNotFound)):
+		l.logger.Error(ctx, "Query failed", append(fields(), ErrorField("error", err))...)
+	case l.config.SlowThreshold > 0 && elapsed > l.config.SlowThreshold && l.level >= gormlogger.Warn:
+		l.logger.Warn(ctx, "Slow query detected", append(fields(), Duration("threshold", l.config.SlowThreshold))...)
+	case l.level >= gormlogger.Info:
+		l.logger.Debug(ctx, "Query executed", fields()...)
+	}
+}
+
+// ParamsFilter returns the parameters rendered in the logged statements:
+// none with ParameterizedQueries, else the parameters with those of the
+// sensitive columns masked
+func (l *GormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if l.config.ParameterizedQueries {
+		return sql, nil
+	}
+	if len(l.sensitive) == 0 {
+		return sql, params
+	}
+	return sql, l.mask(sql, params)
+}
+
+var (
+	// statementPlaceholder matches the bind variables of every dialect,
+	// numbering them for Postgres ($1), SQL Server (@p1) and Oracle (:1)
+	statementPlaceholder = regexp.MustCompile(`\?|\$(\d+)|@p(\d+)|:(\d+)`)
+	// comparedColumn matches a column compared with or assigned the
+	// placeholder following it
+	comparedColumn = regexp.MustCompile(`(?i)([A-Za-z_][\w]*)["` + "`" + `\]]?\s*(?:=|<>|!=|<=|>=|<|>|\sLIKE|\sIN)\s*\(?\s*$`)
+	// listSeparator matches the text between two placeholders of a list
+	listSeparator = regexp.MustCompile(`^\s*,\s*$`)
+	// insertColumns matches the column list of an INSERT statement
+	insertColumns = regexp.MustCompile(`(?is)^\s*INSERT\s+INTO\s+\S+\s*\(([^)]*)\)\s*VALUES`)
+)
+
+// mask returns params with the parameters bound to sensitive columns by sql
+// replaced by the mask: the columns compared with or assigned a placeholder,
+// the IN lists and the values of INSERT statements
+func (l *GormLogger) mask(sql string, params []interface{}) []interface{} {
+	masked := append([]interface{}(nil), params...)
+
+	var columns []string
+	valuesAt := -1
+	if match := insertColumns.FindStringSubmatchIndex(sql); match != nil {
+		columns = strings.Split(sql[match[2]:match[3]], ",")
+		valuesAt = match[1]
+	}
+
+	next, value, previousEnd, previous := 0, 0, 0, ""
+	for _, loc := range statementPlaceholder.FindAllStringSubmatchIndex(sql, -1) {
+		index := next
+		next++
+		for group := 1; group < len(loc)/2; group++ {
+			if loc[2*group] >= 0 {
+				n, _ := strconv.Atoi(sql[loc[2*group]:loc[2*group+1]])
+				index = n - 1
+			}
+		}
+
+		start := loc[0] - 128
+		if start < 0 {
+			start = 0
+		}
+		var column string
+		switch match := comparedColumn.FindStringSubmatch(sql[start:loc[0]]); {
+		case match != nil:
+			column = match[1]
+		case valuesAt >= 0 && loc[0] >= valuesAt && len(columns) > 0:
+			column = columns[value%len(columns)]
+		case listSeparator.MatchString(sql[previousEnd:loc[0]]):
+			column = previous
+		}
+		if valuesAt >= 0 && loc[0] >= valuesAt {
+			value++
+		}
+
+		if index >= 0 && index < len(masked) && l.sensitive[columnName(column)] {
+			masked[index] = l.config.Mask
+		}
+		previousEnd, previous = loc[1], column
+	}
+	return masked
+}
+
+// columnName returns the lower case name of a column without its quotes
+// and table
+func columnName(column string) string {
+	column = strings.TrimSpace(column)
+	if i := strings.LastIndex(column, "."); i >= 0 {
+		column = column[i+1:]
+	}
+	return strings.ToLower(strings.Trim(column, "\"`[]"))
+}
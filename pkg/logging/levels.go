@@ -0,0 +1,262 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ComponentLevel is the log level of a component of a LevelController
+type ComponentLevel struct {
+	Component string `json:"component"`
+	Level     string `json:"level"`
+	// Default is the level the component reverts to
+	Default string `json:"default"`
+	// Expires is when a temporary level reverts to the default
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// LevelController changes the log levels of the components of an
+// application at runtime, for good or for a while:
+//
+//	levels := logging.NewLevelController()
+//	repo := repository.NewBaseRepository[User](db, levels.Component("repository", logger), nil)
+//	migrator, err := migrations.NewMigrator(db, levels.Component("migrations", logger), nil)
+//	mux.Handle("/debug/log-levels", levels)
+//
+//	// Debug the repository for the next 15 minutes
+//	levels.SetLevel("repository", logging.LogLevelDebug, 15*time.Minute)
+//
+// As an http.Handler, it lists the levels on GET, sets one on PUT or POST
+// from {"component": "repository", "level": "debug", "ttl": "15m"} and
+// resets one on DELETE ?component=repository. The component "*" stands for
+// every component. The handler does not authenticate its callers; mount it
+// on an internal or protected endpoint only.
+type LevelController struct {
+	mu         sync.Mutex
+	components map[string]*componentLevel
+}
+
+// componentLevel is the state of a component
+type componentLevel struct {
+	logger   Logger
+	defaults LogLevel
+	expires  time.Time
+	timer    *time.Timer
+	// generation tells the revert of the current temporary level from those
+	// of the replaced ones
+	generation uint64
+}
+
+// NewLevelController creates a level controller without components
+func NewLevelController() *LevelController {
+	return &LevelController{components: make(map[string]*componentLevel)}
+}
+
+// Register adds a component logging with logger, whose level is its
+// default; registering a name again replaces its logger
+func (c *LevelController) Register(component string, logger Logger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if previous, ok := c.components[component]; ok && previous.timer != nil {
+		previous.timer.Stop()
+	}
+	c.components[component] = &componentLevel{logger: logger, defaults: logger.GetLevel()}
+}
+
+// Component registers and returns a logger derived from logger for a
+// component, whose entries carry the component field
+func (c *LevelController) Component(component string, logger Logger) Logger {
+	derived := logger.WithFields(String("component", component))
+	c.Register(component, derived)
+	return derived
+}
+
+// SetLevel sets the level of a component, or of every component for "*".
+// A positive ttl reverts to the default level once elapsed; otherwise the
+// level becomes the default.
+func (c *LevelController) SetLevel(component string, level LogLevel, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	targets, err := c.lookup(component)
+	if err != nil {
+		return err
+	}
+	for name, target := range targets {
+		if target.timer != nil {
+			target.timer.Stop()
+			target.timer = nil
+		}
+		target.generation++
+		target.expires = time.Time{}
+		target.logger.SetLevel(level)
+
+		if ttl <= 0 {
+			target.defaults = level
+			continue
+		}
+		name, generation := name, target.generation
+		target.expires = time.Now().Add(ttl)
+		target.timer = time.AfterFunc(ttl, func() {
+			c.revert(name, generation)
+		})
+	}
+	return nil
+}
+
+// Reset reverts a component, or every component for "*", to its default
+// level
+func (c *LevelController) Reset(component string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	targets, err := c.lookup(component)
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		target.reset()
+	}
+	return nil
+}
+
+// Levels returns the levels of the components sorted by name
+func (c *LevelController) Levels() []ComponentLevel {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	levels := make([]ComponentLevel, 0, len(c.components))
+	for name, component := range c.components {
+		level := ComponentLevel{
+			Component: name,
+			Level:     component.logger.GetLevel().String(),
+			Default:   component.defaults.String(),
+		}
+		if !component.expires.IsZero() {
+			expires := component.expires
+			level.Expires = &expires
+		}
+		levels = append(levels, level)
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i].Component < levels[j].Component
+	})
+	return levels
+}
+
+// Close stops the pending reverts, leaving the levels as they are
+func (c *LevelController) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, component := range c.components {
+		if component.timer != nil {
+			component.timer.Stop()
+			component.timer = nil
+		}
+		component.generation++
+	}
+}
+
+// lookup returns the components named by component; callers hold the lock
+func (c *LevelController) lookup(component string) (map[string]*componentLevel, error) {
+	if component == "*" {
+		return c.components, nil
+	}
+	target, ok := c.components[component]
+	if !ok {
+		return nil, fmt.Errorf("unknown log component %q", component)
+	}
+	return map[string]*componentLevel{component: target}, nil
+}
+
+// revert reverts a component to its default level, unless its level changed
+// since the temporary level of generation was set
+func (c *LevelController) revert(component string, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if target, ok := c.components[component]; ok && target.generation == generation {
+		target.reset()
+	}
+}
+
+// reset reverts the component to its default level; callers hold the lock
+func (l *componentLevel) reset() {
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	l.generation++
+	l.expires = time.Time{}
+	l.logger.SetLevel(l.defaults)
+}
+
+// levelRequest is the body of a request setting a level
+type levelRequest struct {
+	Component string `json:"component"`
+	Level     string `json:"level"`
+	TTL       string `json:"ttl"`
+}
+
+// ServeHTTP lists the levels on GET, sets one on PUT or POST and resets one
+// on DELETE, replying with the levels
+func (c *LevelController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var request levelRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeLevelError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		level, ok := parseLogLevelStrict(request.Level)
+		if !ok {
+			writeLevelError(w, http.StatusBadRequest, fmt.Errorf("unknown log level %q", request.Level))
+			return
+		}
+		var ttl time.Duration
+		if request.TTL != "" {
+			var err error
+			if ttl, err = time.ParseDuration(request.TTL); err != nil {
+				writeLevelError(w, http.StatusBadRequest, fmt.Errorf("invalid ttl: %w", err))
+				return
+			}
+		}
+		if err := c.SetLevel(request.Component, level, ttl); err != nil {
+			writeLevelError(w, http.StatusNotFound, err)
+			return
+		}
+	case http.MethodDelete:
+		if err := c.Reset(r.URL.Query().Get("component")); err != nil {
+			writeLevelError(w, http.StatusNotFound, err)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+		writeLevelError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c.Levels())
+}
+
+// writeLevelError replies with an error of the level handler
+func writeLevelError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// parseLogLevelStrict parses a level name, reporting false for unknown names
+// instead of defaulting to info
+func parseLogLevelStrict(name string) (LogLevel, bool) {
+	level := ParseLogLevel(name)
+	return level, level.String() == name
+}
@@ -1,10 +1,15 @@
 package logging
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"reflect"
+	"runtime"
+	"strings"
 	"sync"
 	"time"
 )
@@ -90,10 +95,12 @@ type Logger interface {
 // BaseLogger implements the base logging functionality
 type BaseLogger struct {
 	level     LogLevel
-	output    io.Writer
+	output    *logOutput
 	formatter LogFormatter
 	mutex     sync.RWMutex
 	fields    []LogField
+	// caller adds the file and line of the logging call to the entries
+	caller bool
 }
 
 // LogFormatter interface for formatting log entries
@@ -101,28 +108,80 @@ type LogFormatter interface {
 	Format(entry LogEntry) ([]byte, error)
 }
 
-// JSONFormatter formats log entries as JSON
+// JSONFormatter formats log entries as JSON objects, one per line:
+//
+//	{"level":"info","message":"Entity created","time":"2026-01-02T15:04:05.123456789Z","caller":"repository/base.go:349","fields":{"table":"users","rows":3}}
+//
+// Field values keep their JSON types: numbers and booleans are not quoted,
+// and structs, maps and slices given to Any are nested. Errors and durations
+// are written as strings, e.g. "1.5s", and values that cannot be encoded
+// fall back to their fmt representation. When a key is repeated, its last
+// value is kept.
 type JSONFormatter struct{}
 
 // Format formats a log entry as JSON
 func (f *JSONFormatter) Format(entry LogEntry) ([]byte, error) {
-	// Simplified JSON formatting - in a real implementation, you'd use encoding/json
-	json := fmt.Sprintf(`{"level":"%s","message":"%s","time":"%s"`,
-		entry.Level.String(), entry.Message, entry.Time.Format(time.RFC3339))
+	var buf bytes.Buffer
+	buf.WriteString(`{"level":`)
+	writeJSON(&buf, entry.Level.String())
+	buf.WriteString(`,"message":`)
+	writeJSON(&buf, entry.Message)
+	buf.WriteString(`,"time":`)
+	writeJSON(&buf, entry.Time.Format(time.RFC3339Nano))
+	if entry.Caller != "" {
+		buf.WriteString(`,"caller":`)
+		writeJSON(&buf, entry.Caller)
+	}
 
 	if len(entry.Fields) > 0 {
-		json += `,"fields":{`
+		last := make(map[string]int, len(entry.Fields))
 		for i, field := range entry.Fields {
-			if i > 0 {
-				json += ","
+			last[field.Key] = i
+		}
+		buf.WriteString(`,"fields":{`)
+		first := true
+		for i, field := range entry.Fields {
+			if last[field.Key] != i {
+				continue
+			}
+			if !first {
+				buf.WriteByte(',')
 			}
-			json += fmt.Sprintf(`"%s":"%v"`, field.Key, field.Value)
+			first = false
+			writeJSON(&buf, field.Key)
+			buf.WriteByte(':')
+			writeJSONValue(&buf, field.Value)
 		}
-		json += "}"
+		buf.WriteByte('}')
 	}
 
-	json += "}\n"
-	return []byte(json), nil
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+}
+
+// writeJSON writes v encoded as JSON, without escaping HTML characters
+func writeJSON(buf *bytes.Buffer, v interface{}) error {
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	buf.Write(bytes.TrimSuffix(encoded.Bytes(), []byte("\n")))
+	return nil
+}
+
+// writeJSONValue writes a field value with its JSON type
+func writeJSONValue(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case error:
+		value = v.Error()
+	case time.Duration:
+		value = v.String()
+	}
+	if err := writeJSON(buf, value); err != nil {
+		writeJSON(buf, fmt.Sprintf("%v", value))
+	}
 }
 
 // TextFormatter formats log entries as text
@@ -130,9 +189,13 @@ type TextFormatter struct{}
 
 // Format formats a log entry as text
 func (f *TextFormatter) Format(entry LogEntry) ([]byte, error) {
+	level := entry.Level.String()
+	if entry.Caller != "" {
+		level += " " + entry.Caller
+	}
 	text := fmt.Sprintf("[%s] %s: %s",
 		entry.Time.Format("2006-01-02T15:04:05Z07:00"),
-		entry.Level.String(),
+		level,
 		entry.Message)
 
 	if len(entry.Fields) > 0 {
@@ -160,7 +223,7 @@ func NewLogger(level LogLevel, output io.Writer, formatter LogFormatter) *BaseLo
 
 	return &BaseLogger{
 		level:     level,
-		output:    output,
+		output:    newLogOutput(output),
 		formatter: formatter,
 		fields:    make([]LogField, 0),
 	}
@@ -191,19 +254,18 @@ func (l *BaseLogger) log(ctx context.Context, level LogLevel, message string, fi
 		Fields:  allFields,
 		Context: ctx,
 	}
+	if l.caller {
+		entry.Caller = callerOutsideLogging()
+	}
 
 	formatted, err := l.formatter.Format(entry)
 	if err != nil {
 		// Fallback to simple logging
-		fmt.Fprintf(l.output, "[ERROR] Failed to format log entry: %v\n", err)
+		l.output.write(outputEntry{level: LogLevelError, data: []byte(fmt.Sprintf("[ERROR] Failed to format log entry: %v\n", err))})
 		return
 	}
 
-	_, err = l.output.Write(formatted)
-	if err != nil {
-		// Log to stderr if we can't write to output
-		fmt.Fprintf(os.Stderr, "[ERROR] Failed to write log entry: %v\n", err)
-	}
+	l.output.write(outputEntry{level: level, data: formatted})
 }
 
 // Debug logs a debug message
@@ -229,6 +291,7 @@ func (l *BaseLogger) Error(ctx context.Context, message string, fields ...LogFie
 // Fatal logs a fatal message and exits
 func (l *BaseLogger) Fatal(ctx context.Context, message string, fields ...LogField) {
 	l.log(ctx, LogLevelFatal, message, fields...)
+	l.output.close()
 	os.Exit(1)
 }
 
@@ -239,6 +302,7 @@ func (l *BaseLogger) WithContext(ctx context.Context) Logger {
 		output:    l.output,
 		formatter: l.formatter,
 		fields:    l.fields,
+		caller:    l.caller,
 	}
 }
 
@@ -253,6 +317,7 @@ func (l *BaseLogger) WithFields(fields ...LogField) Logger {
 		output:    l.output,
 		formatter: l.formatter,
 		fields:    newFields,
+		caller:    l.caller,
 	}
 }
 
@@ -263,6 +328,14 @@ func (l *BaseLogger) SetLevel(level LogLevel) {
 	l.level = level
 }
 
+// SetCaller adds the file and line of the logging call to the entries, as
+// LoggerConfig.EnableCaller does; loggers derived afterwards inherit it
+func (l *BaseLogger) SetCaller(enabled bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.caller = enabled
+}
+
 // GetLevel gets the current log level
 func (l *BaseLogger) GetLevel() LogLevel {
 	l.mutex.RLock()
@@ -270,11 +343,40 @@ func (l *BaseLogger) GetLevel() LogLevel {
 	return l.level
 }
 
-// Close closes the logger
+// Close closes the logger, writing the entries buffered by an asynchronous
+// logger
 func (l *BaseLogger) Close() error {
+	l.output.close()
 	return nil
 }
 
+// loggingPackage is the import path of this package, whose frames are
+// skipped when looking for the caller
+var loggingPackage = reflect.TypeOf(BaseLogger{}).PkgPath()
+
+// callerOutsideLogging returns the file and line of the first caller outside
+// this package, the file with its directory only, e.g.
+// "repository/base.go:349"
+func callerOutsideLogging() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, loggingPackage+".") {
+			file := frame.File
+			if i := strings.LastIndex(file, "/"); i >= 0 {
+				if j := strings.LastIndex(file[:i], "/"); j >= 0 {
+					file = file[j+1:]
+				}
+			}
+			return fmt.Sprintf("%s:%d", file, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
 // extractContextFields extracts fields from context
 func extractContextFields(ctx context.Context) []LogField {
 	if ctx == nil {
@@ -282,32 +384,27 @@ func extractContextFields(ctx context.Context) []LogField {
 	}
 
 	var fields []LogField
-
-	// Extract request ID
-	if requestID := ctx.Value("request_id"); requestID != nil {
-		fields = append(fields, LogField{Key: "request_id", Value: requestID})
-	}
-
-	// Extract trace ID
-	if traceID := ctx.Value("trace_id"); traceID != nil {
-		fields = append(fields, LogField{Key: "trace_id", Value: traceID})
-	}
-
-	// Extract span ID
-	if spanID := ctx.Value("span_id"); spanID != nil {
-		fields = append(fields, LogField{Key: "span_id", Value: spanID})
-	}
-
-	// Extract user ID
-	if userID := ctx.Value("user_id"); userID != nil {
-		fields = append(fields, LogField{Key: "user_id", Value: userID})
+	for _, builtin := range []struct {
+		key   contextKey
+		field string
+	}{
+		{requestIDKey, "request_id"},
+		{traceIDKey, "trace_id"},
+		{spanIDKey, "span_id"},
+		{userIDKey, "user_id"},
+		{tenantIDKey, "tenant_id"},
+	} {
+		// The typed keys take precedence over the legacy string keys
+		if value := ctx.Value(builtin.key); value != nil {
+			fields = append(fields, LogField{Key: builtin.field, Value: value})
+		} else if value := ctx.Value(builtin.field); value != nil {
+			fields = append(fields, LogField{Key: builtin.field, Value: value})
+		}
 	}
 
-	// Extract tenant ID
-	if tenantID := ctx.Value("tenant_id"); tenantID != nil {
-		fields = append(fields, LogField{Key: "tenant_id", Value: tenantID})
+	for _, extractor := range registeredExtractors() {
+		fields = append(fields, extractor(ctx)...)
 	}
-
 	return fields
 }
 
@@ -452,6 +549,13 @@ type LoggerConfig struct {
 	SlowQueryThreshold time.Duration
 	EnableCaller       bool
 	EnableTimestamp    bool
+	// Async writes the entries from a background flusher when set (see
+	// NewAsyncLogger)
+	Async *AsyncConfig
+	// Rotation rotates the file output when set
+	Rotation *RotationConfig
+	// SyslogTag tags the entries of a syslog output ("ormx" when not set)
+	SyslogTag string
 }
 
 // DefaultLoggerConfig returns default logger configuration
@@ -466,22 +570,15 @@ func DefaultLoggerConfig() LoggerConfig {
 	}
 }
 
-// NewLoggerFromConfig creates a new logger from configuration
+// NewLoggerFromConfig creates a new logger from configuration. Output is
+// "stdout", "stderr", a file path, rotated when Rotation is set, or a syslog
+// target: "syslog" for the local daemon, "syslog://host:port" over UDP or
+// "syslog+tcp://host:port" over TCP. Closing the logger closes its file or
+// syslog connection.
 func NewLoggerFromConfig(config LoggerConfig) (Logger, error) {
-	var output io.Writer
-	switch config.Output {
-	case "stdout", "":
-		output = os.Stdout
-	case "stderr":
-		output = os.Stderr
-	default:
-		file, err := os.OpenFile(config.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-		if err != nil {
-			// Fallback to stdout if file can't be opened
-			output = os.Stdout
-		} else {
-			output = file
-		}
+	output, closer, err := openOutput(config)
+	if err != nil {
+		return nil, err
 	}
 
 	var formatter LogFormatter
@@ -494,7 +591,15 @@ func NewLoggerFromConfig(config LoggerConfig) (Logger, error) {
 		formatter = &TextFormatter{}
 	}
 
-	return NewLogger(config.Level, output, formatter), nil
+	var logger *BaseLogger
+	if config.Async != nil {
+		logger = NewAsyncLogger(config.Level, output, formatter, config.Async)
+	} else {
+		logger = NewLogger(config.Level, output, formatter)
+	}
+	logger.output.closer = closer
+	logger.caller = config.EnableCaller
+	return logger, nil
 }
 
 // MultiLogger logs to multiple outputs
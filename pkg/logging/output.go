@@ -0,0 +1,281 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotationConfig configures the rotation of a log file
+type RotationConfig struct {
+	// MaxSize is the size in bytes above which the file is rotated, none
+	// when zero
+	MaxSize int64
+	// MaxAge is how long the file is written before it is rotated, none
+	// when zero
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files kept, the oldest are
+	// removed first; all are kept when zero
+	MaxBackups int
+	// Compress gzips the rotated files
+	Compress bool
+}
+
+// RotatingFile is a log file rotated by size and age. A rotated file is
+// renamed with its rotation time, e.g. orm-20260102T150405.000000000.log
+// for orm.log, and gzipped when compressed.
+type RotatingFile struct {
+	path   string
+	config RotationConfig
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// NewRotatingFile opens the log file at path for appending, creating it and
+// its directory when missing
+func NewRotatingFile(path string, config RotationConfig) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, config: config}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write appends p to the file, rotating it first when p would take it over
+// its maximum size or when it is older than its maximum age
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	oversize := f.config.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.config.MaxSize
+	expired := f.config.MaxAge > 0 && time.Since(f.opened) >= f.config.MaxAge
+	if oversize || expired {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates the file now
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// Close closes the file
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// open opens the file for appending
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	f.opened = time.Now()
+	return nil
+}
+
+// rotate renames the file to its backup name, reopens it and removes the
+// backups over the maximum; callers hold the lock
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	f.file = nil
+
+	backup := f.backupName(time.Now())
+	if err := os.Rename(f.path, backup); err != nil {
+		if openErr := f.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	if f.config.Compress {
+		if err := compressFile(backup); err != nil {
+			return err
+		}
+	}
+	return f.removeBackups()
+}
+
+// backupTimeFormat is the format of the rotation time in the backup names
+const backupTimeFormat = "20060102T150405.000000000"
+
+// backupName returns an unused name for a backup rotated at t
+func (f *RotatingFile) backupName(t time.Time) string {
+	dir, prefix, ext := f.parts()
+	for {
+		name := filepath.Join(dir, prefix+t.UTC().Format(backupTimeFormat)+ext)
+		_, err := os.Stat(name)
+		_, gzErr := os.Stat(name + ".gz")
+		if os.IsNotExist(err) && os.IsNotExist(gzErr) {
+			return name
+		}
+		t = t.Add(time.Nanosecond)
+	}
+}
+
+// parts returns the directory of the file, and the prefix and extension of
+// its backups
+func (f *RotatingFile) parts() (string, string, string) {
+	base := filepath.Base(f.path)
+	ext := filepath.Ext(base)
+	return filepath.Dir(f.path), strings.TrimSuffix(base, ext) + "-", ext
+}
+
+// Backups returns the paths of the rotated files, oldest first
+func (f *RotatingFile) Backups() ([]string, error) {
+	dir, prefix, ext := f.parts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list log backups: %w", err)
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		stamp, ok := strings.CutPrefix(strings.TrimSuffix(name, ".gz"), prefix)
+		if entry.IsDir() || !ok || !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+		if _, err := time.Parse(backupTimeFormat, strings.TrimSuffix(stamp, ext)); err == nil {
+			backups = append(backups, filepath.Join(dir, name))
+		}
+	}
+	// The rotation times sort like the names
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// removeBackups removes the oldest backups over the maximum
+func (f *RotatingFile) removeBackups() error {
+	if f.config.MaxBackups <= 0 {
+		return nil
+	}
+	backups, err := f.Backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > f.config.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove log backup: %w", err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// compressFile replaces the file at path with its gzipped copy at path.gz
+func compressFile(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to compress log backup: %w", err)
+	}
+	defer source.Close()
+
+	target, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to compress log backup: %w", err)
+	}
+	writer := gzip.NewWriter(target)
+	_, err = io.Copy(writer, source)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return fmt.Errorf("failed to compress log backup: %w", err)
+	}
+
+	source.Close()
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove compressed log backup: %w", err)
+	}
+	return nil
+}
+
+// levelWriter is an output writing entries according to their level, such
+// as syslog
+type levelWriter interface {
+	WriteLevel(level LogLevel, p []byte) (int, error)
+}
+
+// openOutput opens the output named by config: stdout, stderr, a syslog
+// target or a file path, rotated with config.Rotation. The closer closes the
+// outputs the logger owns, nil for stdout and stderr.
+func openOutput(config LoggerConfig) (io.Writer, io.Closer, error) {
+	switch output := config.Output; {
+	case output == "stdout" || output == "":
+		return os.Stdout, nil, nil
+	case output == "stderr":
+		return os.Stderr, nil, nil
+	case output == "syslog" || strings.HasPrefix(output, "syslog://") || strings.HasPrefix(output, "syslog+tcp://"):
+		writer, err := openSyslog(output, config.SyslogTag)
+		if err != nil {
+			return nil, nil, err
+		}
+		return writer, writer, nil
+	case config.Rotation != nil:
+		file, err := NewRotatingFile(output, *config.Rotation)
+		if err != nil {
+			// Fallback to stdout if file can't be opened
+			return os.Stdout, nil, nil
+		}
+		return file, file, nil
+	default:
+		file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+		if err != nil {
+			// Fallback to stdout if file can't be opened
+			return os.Stdout, nil, nil
+		}
+		return file, file, nil
+	}
+}
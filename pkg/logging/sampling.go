@@ -0,0 +1,309 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SamplingConfig configures a SampledLogger
+type SamplingConfig struct {
+	// PerSecond is the number of entries logged per key and second, the
+	// others are suppressed (10 when not set)
+	PerSecond int
+	// KeyFields are the fields whose values are part of the key of an entry
+	// besides its level and message, e.g. "table" to limit every table on
+	// its own
+	KeyFields []string
+	// ReportInterval is how often the suppressed entries are reported
+	// (10s when not set)
+	ReportInterval time.Duration
+	// SampleErrors limits error entries too; they are never suppressed
+	// otherwise. Fatal entries are never suppressed.
+	SampleErrors bool
+}
+
+// DefaultSamplingConfig returns the default sampling configuration
+func DefaultSamplingConfig() SamplingConfig {
+	return SamplingConfig{
+		PerSecond:      10,
+		ReportInterval: 10 * time.Second,
+	}
+}
+
+// SampledLogger is a Logger limiting the entries written to its logger per
+// key, the level, message and KeyFields values of an entry:
+//
+//	logger = logging.NewSampledLogger(logger, &logging.SamplingConfig{PerSecond: 5, KeyFields: []string{"table"}})
+//
+// logs at most five "Entity created successfully" entries per second for
+// every table. Suppressed entries are counted and reported every
+// ReportInterval with a "Log entries suppressed" entry at their level,
+// carrying their message, key fields and count. Loggers derived with
+// WithFields and WithContext share the limits; Close reports the entries
+// suppressed since the last report, then closes the wrapped logger.
+type SampledLogger struct {
+	logger  Logger
+	fields  []LogField
+	sampler *logSampler
+}
+
+// logSampler holds the limits shared by a sampled logger and the loggers
+// derived from it
+type logSampler struct {
+	config SamplingConfig
+	logger Logger
+
+	mu   sync.Mutex
+	keys map[string]*sampleCounter
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// sampleCounter counts the entries of a key in the current second
+type sampleCounter struct {
+	level      LogLevel
+	message    string
+	fields     []LogField
+	window     time.Time
+	logged     int
+	suppressed int64
+}
+
+// NewSampledLogger creates a logger sampling the entries written to logger,
+// configured with the defaults when config is nil
+func NewSampledLogger(logger Logger, config *SamplingConfig) *SampledLogger {
+	sampling := DefaultSamplingConfig()
+	if config != nil {
+		sampling.KeyFields = config.KeyFields
+		sampling.SampleErrors = config.SampleErrors
+		if config.PerSecond > 0 {
+			sampling.PerSecond = config.PerSecond
+		}
+		if config.ReportInterval > 0 {
+			sampling.ReportInterval = config.ReportInterval
+		}
+	}
+
+	s := &logSampler{
+		config: sampling,
+		logger: logger,
+		keys:   make(map[string]*sampleCounter),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return &SampledLogger{logger: logger, sampler: s}
+}
+
+// Debug logs a debug message unless its key is over its limit
+func (l *SampledLogger) Debug(ctx context.Context, message string, fields ...LogField) {
+	if l.allow(LogLevelDebug, message, fields) {
+		l.logger.Debug(ctx, message, fields...)
+	}
+}
+
+// Info logs an info message unless its key is over its limit
+func (l *SampledLogger) Info(ctx context.Context, message string, fields ...LogField) {
+	if l.allow(LogLevelInfo, message, fields) {
+		l.logger.Info(ctx, message, fields...)
+	}
+}
+
+// Warn logs a warning message unless its key is over its limit
+func (l *SampledLogger) Warn(ctx context.Context, message string, fields ...LogField) {
+	if l.allow(LogLevelWarn, message, fields) {
+		l.logger.Warn(ctx, message, fields...)
+	}
+}
+
+// Error logs an error message, unless its key is over its limit when
+// errors are sampled
+func (l *SampledLogger) Error(ctx context.Context, message string, fields ...LogField) {
+	if !l.sampler.config.SampleErrors || l.allow(LogLevelError, message, fields) {
+		l.logger.Error(ctx, message, fields...)
+	}
+}
+
+// Fatal logs a fatal message and exits
+func (l *SampledLogger) Fatal(ctx context.Context, message string, fields ...LogField) {
+	l.sampler.report()
+	l.logger.Fatal(ctx, message, fields...)
+}
+
+// WithContext creates a new logger with context, sharing the limits
+func (l *SampledLogger) WithContext(ctx context.Context) Logger {
+	return &SampledLogger{logger: l.logger.WithContext(ctx), fields: l.fields, sampler: l.sampler}
+}
+
+// WithFields creates a new logger with additional fields, sharing the
+// limits; the fields count as key fields of its entries
+func (l *SampledLogger) WithFields(fields ...LogField) Logger {
+	newFields := make([]LogField, len(l.fields)+len(fields))
+	copy(newFields, l.fields)
+	copy(newFields[len(l.fields):], fields)
+
+	return &SampledLogger{logger: l.logger.WithFields(fields...), fields: newFields, sampler: l.sampler}
+}
+
+// SetLevel sets the log level of the wrapped logger
+func (l *SampledLogger) SetLevel(level LogLevel) {
+	l.logger.SetLevel(level)
+}
+
+// GetLevel gets the log level of the wrapped logger
+func (l *SampledLogger) GetLevel() LogLevel {
+	return l.logger.GetLevel()
+}
+
+// Close reports the suppressed entries and closes the wrapped logger
+func (l *SampledLogger) Close() error {
+	l.sampler.close()
+	return l.logger.Close()
+}
+
+// allow reports whether an entry is within the limit of its key, counting
+// it as suppressed otherwise
+func (l *SampledLogger) allow(level LogLevel, message string, fields []LogField) bool {
+	if level < l.logger.GetLevel() {
+		// Entries the logger drops count against no limit
+		return true
+	}
+	return l.sampler.allow(level, message, l.keyFields(fields), time.Now())
+}
+
+// keyFields returns the key fields of an entry, the fields logged with it
+// overriding those of the logger
+func (l *SampledLogger) keyFields(fields []LogField) []LogField {
+	keys := l.sampler.config.KeyFields
+	if len(keys) == 0 {
+		return nil
+	}
+	keyFields := make([]LogField, 0, len(keys))
+	for _, key := range keys {
+		if field, ok := lastField(fields, key); ok {
+			keyFields = append(keyFields, field)
+		} else if field, ok := lastField(l.fields, key); ok {
+			keyFields = append(keyFields, field)
+		}
+	}
+	return keyFields
+}
+
+// lastField returns the last of fields with key
+func lastField(fields []LogField, key string) (LogField, bool) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].Key == key {
+			return fields[i], true
+		}
+	}
+	return LogField{}, false
+}
+
+// allow counts an entry logged at now against the limit of its key
+func (s *logSampler) allow(level LogLevel, message string, fields []LogField, now time.Time) bool {
+	var key strings.Builder
+	fmt.Fprintf(&key, "%d|%s", level, message)
+	for _, field := range fields {
+		fmt.Fprintf(&key, "|%s=%v", field.Key, field.Value)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counter, ok := s.keys[key.String()]
+	if !ok {
+		counter = &sampleCounter{level: level, message: message, fields: fields, window: now}
+		s.keys[key.String()] = counter
+	}
+	if now.Sub(counter.window) >= time.Second {
+		counter.window = now
+		counter.logged = 0
+	}
+	if counter.logged < s.config.PerSecond {
+		counter.logged++
+		return true
+	}
+	counter.suppressed++
+	return false
+}
+
+// run reports the suppressed entries every report interval until closed
+func (s *logSampler) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.config.ReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.report()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// report logs the entries suppressed since the last report, and forgets
+// the keys idle for a report interval
+func (s *logSampler) report() {
+	type suppressed struct {
+		counter *sampleCounter
+		count   int64
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	var reports []suppressed
+	for key, counter := range s.keys {
+		if counter.suppressed > 0 {
+			reports = append(reports, suppressed{counter: counter, count: counter.suppressed})
+			counter.suppressed = 0
+		} else if now.Sub(counter.window) > s.config.ReportInterval {
+			delete(s.keys, key)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].counter.message != reports[j].counter.message {
+			return reports[i].counter.message < reports[j].counter.message
+		}
+		return fmt.Sprint(reports[i].counter.fields) < fmt.Sprint(reports[j].counter.fields)
+	})
+
+	ctx := context.Background()
+	for _, report := range reports {
+		fields := make([]LogField, 0, len(report.counter.fields)+2)
+		fields = append(fields, String("message", report.counter.message))
+		fields = append(fields, report.counter.fields...)
+		fields = append(fields, Int64("suppressed", report.count))
+
+		switch report.counter.level {
+		case LogLevelDebug:
+			s.logger.Debug(ctx, "Log entries suppressed", fields...)
+		case LogLevelInfo:
+			s.logger.Info(ctx, "Log entries suppressed", fields...)
+		case LogLevelWarn:
+			s.logger.Warn(ctx, "Log entries suppressed", fields...)
+		default:
+			s.logger.Error(ctx, "Log entries suppressed", fields...)
+		}
+	}
+}
+
+// close stops the reports and reports the entries suppressed since the
+// last one
+func (s *logSampler) close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+		s.report()
+	})
+}
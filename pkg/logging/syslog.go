@@ -0,0 +1,67 @@
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"fmt"
+	"log/syslog"
+	"strings"
+)
+
+// syslogWriter writes the entries to syslog at the priority of their level
+type syslogWriter struct {
+	writer *syslog.Writer
+}
+
+// openSyslog connects to the syslog target: "syslog" for the local daemon,
+// "syslog://host:port" over UDP or "syslog+tcp://host:port" over TCP
+func openSyslog(target, tag string) (*syslogWriter, error) {
+	if tag == "" {
+		tag = "ormx"
+	}
+
+	var network, address string
+	if host, ok := strings.CutPrefix(target, "syslog+tcp://"); ok {
+		network, address = "tcp", host
+	} else if host, ok := strings.CutPrefix(target, "syslog://"); ok {
+		network, address = "udp", host
+	}
+
+	writer, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return &syslogWriter{writer: writer}, nil
+}
+
+// Write writes an entry at info priority
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(LogLevelInfo, p)
+}
+
+// WriteLevel writes an entry at the priority of level
+func (w *syslogWriter) WriteLevel(level LogLevel, p []byte) (int, error) {
+	message := strings.TrimSuffix(string(p), "\n")
+	var err error
+	switch level {
+	case LogLevelDebug:
+		err = w.writer.Debug(message)
+	case LogLevelInfo:
+		err = w.writer.Info(message)
+	case LogLevelWarn:
+		err = w.writer.Warning(message)
+	case LogLevelError:
+		err = w.writer.Err(message)
+	default:
+		err = w.writer.Crit(message)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the connection to syslog
+func (w *syslogWriter) Close() error {
+	return w.writer.Close()
+}
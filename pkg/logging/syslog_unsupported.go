@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package logging
+
+import (
+	"fmt"
+	"io"
+)
+
+// openSyslog fails, syslog is not available on this platform
+func openSyslog(target, tag string) (io.WriteCloser, error) {
+	return nil, fmt.Errorf("syslog output is not supported on this platform")
+}
@@ -0,0 +1,134 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// DefaultDrift describes a column whose database default differs from the
+// default declared by its gorm tag
+type DefaultDrift struct {
+	Table    string `json:"table"`
+	Column   string `json:"column"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// String returns a readable description of the drift
+func (d DefaultDrift) String() string {
+	if d.Actual == "" {
+		return fmt.Sprintf("%s.%s: default missing (expected %q)", d.Table, d.Column, d.Expected)
+	}
+	return fmt.Sprintf("%s.%s: default differs (expected %q, actual %q)", d.Table, d.Column, d.Expected, d.Actual)
+}
+
+// typeCast matches the cast Postgres appends to stored defaults
+var typeCast = regexp.MustCompile(`::[A-Za-z_ "]+(?:\([0-9, ]*\))?(?:\[\])?$`)
+
+// CheckDefaults compares the column defaults declared on models with
+// `gorm:"default:..."` tags, which AutoMigrate and the default values plugin
+// apply, with the defaults of the database columns and returns the columns
+// that drifted. Defaults are compared after removing quotes, casts,
+// parentheses and letter case, since engines store them differently. Tables
+// and columns not created yet are left to AutoMigrate.
+func CheckDefaults(ctx context.Context, db *gorm.DB, models ...interface{}) ([]DefaultDrift, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+	db = db.WithContext(ctx)
+	migrator := db.Migrator()
+
+	var drifts []DefaultDrift
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, fmt.Errorf("failed to parse model %T: %w", model, err)
+		}
+		if !migrator.HasTable(model) {
+			continue
+		}
+		columnTypes, err := migrator.ColumnTypes(model)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read columns of %s: %w", stmt.Schema.Table, err)
+		}
+		columns := make(map[string]gorm.ColumnType, len(columnTypes))
+		for _, columnType := range columnTypes {
+			columns[strings.ToLower(columnType.Name())] = columnType
+		}
+
+		for _, field := range stmt.Schema.Fields {
+			expected, ok := declaredDefault(field)
+			if !ok {
+				continue
+			}
+			columnType, ok := columns[strings.ToLower(field.DBName)]
+			if !ok {
+				continue
+			}
+			actual, _ := columnType.DefaultValue()
+			if !sameDefault(field, expected, actual) {
+				drifts = append(drifts, DefaultDrift{Table: stmt.Schema.Table, Column: field.DBName, Expected: expected, Actual: actual})
+			}
+		}
+	}
+	return drifts, nil
+}
+
+// declaredDefault returns the default declared on field, if any
+func declaredDefault(field *schema.Field) (string, bool) {
+	if field.DBName == "" || field.PrimaryKey || !field.HasDefaultValue {
+		return "", false
+	}
+	value := strings.TrimSpace(field.DefaultValue)
+	if value == "" || value == "(-)" || strings.EqualFold(value, "null") {
+		return "", false
+	}
+	return value, true
+}
+
+// sameDefault reports whether the database default actual matches the
+// default expected of field
+func sameDefault(field *schema.Field, expected, actual string) bool {
+	expected, actual = normalizeDefault(expected), normalizeDefault(actual)
+	if expected == actual {
+		return true
+	}
+	switch field.DataType {
+	case schema.Bool:
+		e, err1 := strconv.ParseBool(expected)
+		a, err2 := strconv.ParseBool(actual)
+		return err1 == nil && err2 == nil && e == a
+	case schema.Int, schema.Uint, schema.Float:
+		e, err1 := strconv.ParseFloat(expected, 64)
+		a, err2 := strconv.ParseFloat(actual, 64)
+		return err1 == nil && err2 == nil && e == a
+	}
+	return false
+}
+
+// normalizeDefault strips the notation engines add to stored defaults.
+// Parentheses are dropped altogether: engines add enclosing ones and the
+// SQLite driver reads them back unbalanced.
+func normalizeDefault(value string) string {
+	value = strings.TrimSpace(value)
+	for {
+		trimmed := strings.Trim(strings.TrimSpace(typeCast.ReplaceAllString(value, "")), "()")
+		if len(trimmed) >= 2 && (trimmed[0] == '\'' || trimmed[0] == '"') && trimmed[len(trimmed)-1] == trimmed[0] {
+			trimmed = trimmed[1 : len(trimmed)-1]
+		}
+		if trimmed == value {
+			break
+		}
+		value = trimmed
+	}
+	return strings.ToLower(parentheses.Replace(value))
+}
+
+// parentheses removes parentheses
+var parentheses = strings.NewReplacer("(", "", ")", "")
@@ -0,0 +1,218 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"gorm.io/gorm"
+)
+
+// Index drift problems
+const (
+	DriftMissing = "missing"
+	DriftColumns = "columns"
+	DriftUnique  = "unique"
+	DriftWhere   = "where"
+	DriftInclude = "include"
+	DriftMethod  = "method"
+)
+
+// IndexDrift describes a difference between a declared index and the database
+type IndexDrift struct {
+	Table    string `json:"table"`
+	Index    string `json:"index"`
+	Problem  string `json:"problem"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// String returns a readable description of the drift
+func (d IndexDrift) String() string {
+	if d.Problem == DriftMissing {
+		return fmt.Sprintf("%s.%s: missing", d.Table, d.Index)
+	}
+	return fmt.Sprintf("%s.%s: %s differs (expected %q, actual %q)", d.Table, d.Index, d.Problem, d.Expected, d.Actual)
+}
+
+var (
+	whereClause   = regexp.MustCompile(`(?i)\bWHERE\b`)
+	includeClause = regexp.MustCompile(`(?i)\b(?:INCLUDE|STORING)\s*\(([^)]*)\)`)
+	usingClause   = regexp.MustCompile(`(?i)\bUSING\s+(\w+)\s*\(`)
+)
+
+// CheckIndexes compares the indexes declared on models with the database and
+// returns the drifts found: missing indexes, different key columns or
+// uniqueness and, on dialects that can read index definitions back (Postgres,
+// CockroachDB, SQLite), a missing or unexpected partial index predicate,
+// different included columns and, where the definition names it, a different
+// access method. Predicates are compared by presence only since
+// engines normalize their text.
+func CheckIndexes(ctx context.Context, db *gorm.DB, models ...interface{}) ([]IndexDrift, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+	db = db.WithContext(ctx)
+	d := dialect.For(db)
+	migrator := db.Migrator()
+
+	var drifts []IndexDrift
+	for _, model := range models {
+		declared, err := ModelIndexes(db, model)
+		if err != nil {
+			return nil, err
+		}
+		if len(declared) == 0 {
+			continue
+		}
+
+		existing := make(map[string]gorm.Index)
+		listed := false
+		if migrator.HasTable(model) {
+			if indexes, err := migrator.GetIndexes(model); err == nil {
+				listed = true
+				for _, index := range indexes {
+					existing[index.Name()] = index
+				}
+			}
+		}
+
+		for _, index := range declared {
+			actual, found := existing[index.Name]
+			if !listed {
+				found = migrator.HasIndex(model, index.Name)
+			}
+			if !found {
+				drifts = append(drifts, IndexDrift{Table: index.Table, Index: index.Name, Problem: DriftMissing})
+				continue
+			}
+			if actual != nil {
+				drifts = append(drifts, compareIndex(d, index, actual)...)
+			}
+			drift, err := compareDefinition(db, d, index)
+			if err != nil {
+				return nil, err
+			}
+			drifts = append(drifts, drift...)
+		}
+	}
+	return drifts, nil
+}
+
+// compareIndex compares the key columns and uniqueness of an existing index.
+// The stored columns may list the included columns after the key columns,
+// as covering indexes do on some engines or when emulated as key columns.
+func compareIndex(d dialect.Dialect, index dialect.IndexDefinition, actual gorm.Index) []IndexDrift {
+	var drifts []IndexDrift
+
+	if keys := index.KeyColumns(); keys != nil {
+		columns := actual.Columns()
+		withInclude := append(append([]string{}, keys...), index.Include...)
+		if !equalFold(columns, keys) && !equalFold(columns, withInclude) {
+			expected := keys
+			if len(index.Include) > 0 && !supportsInclude(d) {
+				expected = withInclude
+			}
+			drifts = append(drifts, IndexDrift{
+				Table: index.Table, Index: index.Name, Problem: DriftColumns,
+				Expected: strings.Join(expected, ", "), Actual: strings.Join(columns, ", "),
+			})
+		}
+	}
+
+	if unique, ok := actual.Unique(); ok && unique != index.Unique {
+		drifts = append(drifts, IndexDrift{
+			Table: index.Table, Index: index.Name, Problem: DriftUnique,
+			Expected: fmt.Sprint(index.Unique), Actual: fmt.Sprint(unique),
+		})
+	}
+	return drifts
+}
+
+// compareDefinition checks the predicate, included columns and access method
+// of an existing index against its definition when the dialect can read it
+// back
+func compareDefinition(db *gorm.DB, d dialect.Dialect, index dialect.IndexDefinition) ([]IndexDrift, error) {
+	inspector, ok := d.(dialect.IndexInspector)
+	if !ok {
+		return nil, nil
+	}
+
+	var definition string
+	if err := db.Raw(inspector.IndexDefinition(), index.Table, index.Name).Row().Scan(&definition); err != nil {
+		return nil, fmt.Errorf("failed to read definition of index %s: %w", index.Name, err)
+	}
+
+	var drifts []IndexDrift
+	if partial := whereClause.MatchString(definition); partial != (index.Where != "") {
+		drifts = append(drifts, IndexDrift{
+			Table: index.Table, Index: index.Name, Problem: DriftWhere,
+			Expected: index.Where, Actual: definition,
+		})
+	}
+
+	if match := usingClause.FindStringSubmatch(definition); match != nil {
+		expected := index.Method
+		if expected == "" {
+			expected = "btree"
+		}
+		if !strings.EqualFold(match[1], expected) {
+			drifts = append(drifts, IndexDrift{
+				Table: index.Table, Index: index.Name, Problem: DriftMethod,
+				Expected: strings.ToLower(expected), Actual: strings.ToLower(match[1]),
+			})
+		}
+	}
+
+	if supportsInclude(d) {
+		var included []string
+		if match := includeClause.FindStringSubmatch(definition); match != nil {
+			for _, column := range strings.Split(match[1], ",") {
+				included = append(included, strings.Trim(strings.TrimSpace(column), "\"`[]"))
+			}
+		}
+		if !equalFold(sorted(included), sorted(index.Include)) {
+			drifts = append(drifts, IndexDrift{
+				Table: index.Table, Index: index.Name, Problem: DriftInclude,
+				Expected: strings.Join(index.Include, ", "), Actual: strings.Join(included, ", "),
+			})
+		}
+	}
+	return drifts, nil
+}
+
+// supportsInclude reports whether d renders INCLUDE columns natively
+func supportsInclude(d dialect.Dialect) bool {
+	renderer, ok := d.(dialect.IndexRenderer)
+	if !ok {
+		return false
+	}
+	_, ok = renderer.IndexInclude([]string{"column"})
+	return ok
+}
+
+// equalFold reports whether a and b hold the same names, ignoring case
+func equalFold(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sorted returns a lower-cased sorted copy of names
+func sorted(names []string) []string {
+	out := make([]string, len(names))
+	for i, name := range names {
+		out[i] = strings.ToLower(name)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"gorm.io/gorm"
+)
+
+// GenerateIndexes renders, in the dialect of db, the CREATE INDEX statements of
+// the indexes declared on models that do not exist in the database yet
+func GenerateIndexes(ctx context.Context, db *gorm.DB, models ...interface{}) ([]string, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+	db = db.WithContext(ctx)
+	d := dialect.For(db)
+	migrator := db.Migrator()
+
+	var statements []string
+	for _, model := range models {
+		indexes, err := ModelIndexes(db, model)
+		if err != nil {
+			return nil, err
+		}
+		for _, index := range indexes {
+			if migrator.HasIndex(model, index.Name) {
+				continue
+			}
+			statement, err := dialect.CreateIndex(d, index)
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, statement)
+		}
+	}
+	return statements, nil
+}
+
+// CreateIndexes creates the indexes declared on models that do not exist in
+// the database yet, returning the executed statements
+func CreateIndexes(ctx context.Context, db *gorm.DB, models ...interface{}) ([]string, error) {
+	statements, err := GenerateIndexes(ctx, db, models...)
+	if err != nil {
+		return nil, err
+	}
+	for i, statement := range statements {
+		if err := db.WithContext(ctx).Exec(statement).Error; err != nil {
+			return statements[:i], fmt.Errorf("failed to create index (%s): %w", statement, err)
+		}
+	}
+	return statements, nil
+}
@@ -0,0 +1,231 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSchemaBehind is returned when registered migrations are not applied
+	// to the database, e.g. the binary was deployed before its migrations ran
+	ErrSchemaBehind = errors.New("database schema is behind the application")
+	// ErrSchemaAhead is returned when the database has applied migrations the
+	// binary does not know, e.g. an older binary runs after a newer deploy
+	ErrSchemaAhead = errors.New("database schema is ahead of the application")
+	// ErrSchemaReadOnly is returned for writes while a read-only schema guard
+	// holds a mismatch
+	ErrSchemaReadOnly = errors.New("writes are disabled until the database schema matches the application")
+)
+
+// GuardMode selects how a SchemaGuard reacts to a schema version mismatch
+type GuardMode string
+
+const (
+	// GuardRefuse fails the check and rejects every statement
+	GuardRefuse GuardMode = "refuse"
+	// GuardReadOnly serves reads and rejects writes
+	GuardReadOnly GuardMode = "read_only"
+)
+
+// SchemaCheck compares the migrations compiled into the binary with the
+// versions applied to the database
+type SchemaCheck struct {
+	// Expected is the highest registered version, the version the binary was built for
+	Expected int64 `json:"expected"`
+	// Actual is the highest applied version
+	Actual int64 `json:"actual"`
+	// Pending lists the registered versions not applied yet
+	Pending []int64 `json:"pending,omitempty"`
+	// Unknown lists the applied versions without a registered migration
+	Unknown []int64 `json:"unknown,omitempty"`
+}
+
+// Err returns ErrSchemaAhead when the database has unknown migrations,
+// ErrSchemaBehind when migrations are pending and nil when the schema matches
+func (c *SchemaCheck) Err() error {
+	switch {
+	case len(c.Unknown) > 0:
+		return fmt.Errorf("%w: expected version %d, database at %d with unknown versions %v", ErrSchemaAhead, c.Expected, c.Actual, c.Unknown)
+	case len(c.Pending) > 0:
+		return fmt.Errorf("%w: expected version %d, database at %d with pending versions %v", ErrSchemaBehind, c.Expected, c.Actual, c.Pending)
+	}
+	return nil
+}
+
+// ExpectedVersion returns the highest registered version, 0 when none is registered
+func (m *Migrator) ExpectedVersion() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// CheckSchema compares the registered migrations with the applied versions
+func (m *Migrator) CheckSchema(ctx context.Context) (*SchemaCheck, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	check := &SchemaCheck{Expected: m.ExpectedVersion()}
+	registered := make(map[int64]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		registered[migration.Version] = true
+		if _, ok := applied[migration.Version]; !ok {
+			check.Pending = append(check.Pending, migration.Version)
+		}
+	}
+	for version := range applied {
+		if version > check.Actual {
+			check.Actual = version
+		}
+		if !registered[version] {
+			check.Unknown = append(check.Unknown, version)
+		}
+	}
+	sort.Slice(check.Unknown, func(i, j int) bool { return check.Unknown[i] < check.Unknown[j] })
+	return check, nil
+}
+
+// guardBypassKey marks the contexts of statements run by the migrator, which
+// a schema guard lets through so the schema can be brought up to date
+type guardBypassKey struct{}
+
+// bypassGuard marks ctx as running migrations
+func bypassGuard(ctx context.Context) context.Context {
+	return context.WithValue(ctx, guardBypassKey{}, true)
+}
+
+// SchemaGuard pins the schema version the binary was built for, the
+// migrations registered on its migrator, and keeps the application from
+// running against a database whose schema is behind or ahead after a partial
+// deploy. Check compares the versions at startup; installed as a gorm plugin
+// the guard then rejects every statement (GuardRefuse) or the writes
+// (GuardReadOnly) while the mismatch holds. Statements of the migrator are
+// let through, and checking again after migrating lifts the restriction.
+type SchemaGuard struct {
+	migrator *Migrator
+	mode     GuardMode
+	logger   logging.Logger
+
+	mu    sync.RWMutex
+	check *SchemaCheck
+	err   error
+}
+
+// NewSchemaGuard creates a schema guard pinned to the migrations of migrator;
+// an empty mode refuses
+func NewSchemaGuard(migrator *Migrator, mode GuardMode, logger logging.Logger) (*SchemaGuard, error) {
+	if migrator == nil {
+		return nil, fmt.Errorf("migrator is required")
+	}
+	switch mode {
+	case "":
+		mode = GuardRefuse
+	case GuardRefuse, GuardReadOnly:
+	default:
+		return nil, fmt.Errorf("unknown schema guard mode %q", mode)
+	}
+	return &SchemaGuard{migrator: migrator, mode: mode, logger: logger}, nil
+}
+
+// Check compares the schema versions and records the mismatch. In refuse
+// mode the mismatch is returned; in read-only mode it is only logged, and
+// Err reports it.
+func (g *SchemaGuard) Check(ctx context.Context) (*SchemaCheck, error) {
+	check, err := g.migrator.CheckSchema(bypassGuard(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check schema version: %w", err)
+	}
+	mismatch := check.Err()
+
+	g.mu.Lock()
+	g.check, g.err = check, mismatch
+	g.mu.Unlock()
+
+	if g.logger != nil {
+		fields := []logging.LogField{
+			logging.Int64("expected_version", check.Expected),
+			logging.Int64("actual_version", check.Actual),
+			logging.String("mode", string(g.mode)),
+		}
+		if mismatch != nil {
+			fields = append(fields, logging.Any("pending", check.Pending), logging.Any("unknown", check.Unknown))
+			g.logger.Error(ctx, "Database schema version mismatch", fields...)
+		} else {
+			g.logger.Debug(ctx, "Database schema version matches", fields...)
+		}
+	}
+
+	if mismatch != nil && g.mode == GuardRefuse {
+		return check, mismatch
+	}
+	return check, nil
+}
+
+// Err returns the mismatch found by the last check, nil when the schema
+// matched or was not checked
+func (g *SchemaGuard) Err() error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.err
+}
+
+// LastCheck returns the result of the last check, nil before the first
+func (g *SchemaGuard) LastCheck() *SchemaCheck {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.check
+}
+
+// ReadOnly reports whether writes are rejected
+func (g *SchemaGuard) ReadOnly() bool {
+	return g.mode == GuardReadOnly && g.Err() != nil
+}
+
+// Name returns the plugin name
+func (g *SchemaGuard) Name() string {
+	return "ormx:schema_guard"
+}
+
+// Initialize registers the guard callbacks before the statements of db
+func (g *SchemaGuard) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	err := errors.Join(
+		callbacks.Create().Before("gorm:begin_transaction").Register("ormx:schema_guard_create", g.guard(true)),
+		callbacks.Update().Before("gorm:begin_transaction").Register("ormx:schema_guard_update", g.guard(true)),
+		callbacks.Delete().Before("gorm:begin_transaction").Register("ormx:schema_guard_delete", g.guard(true)),
+		callbacks.Raw().Before("gorm:raw").Register("ormx:schema_guard_raw", g.guard(true)),
+		callbacks.Query().Before("gorm:query").Register("ormx:schema_guard_query", g.guard(false)),
+		callbacks.Row().Before("gorm:row").Register("ormx:schema_guard_row", g.guard(false)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register schema guard callbacks: %w", err)
+	}
+	return nil
+}
+
+// guard rejects the statement while a mismatch holds, reads only in refuse mode
+func (g *SchemaGuard) guard(write bool) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		mismatch := g.Err()
+		if db.Error != nil || mismatch == nil {
+			return
+		}
+		if ctx := db.Statement.Context; ctx != nil && ctx.Value(guardBypassKey{}) != nil {
+			return
+		}
+		switch {
+		case g.mode == GuardRefuse:
+			_ = db.AddError(mismatch)
+		case write:
+			_ = db.AddError(fmt.Errorf("%w: %v", ErrSchemaReadOnly, mismatch))
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Package migrations runs versioned schema migrations (see Migrator) and
+// generates and verifies the indexes declared on models.
+//
+// Indexes are declared with gorm's index tags, extended with an include
+// setting listing the non-key columns of a covering index:
+//
+//	CustomerID uint   `gorm:"index:idx_orders_open,where:closed_at IS NULL,include:total status"`
+//	Email      string `gorm:"uniqueIndex:idx_users_email,where:deleted_at IS NULL"`
+//
+// Fields sharing an index name form a composite index ordered by the priority
+// setting, and the type setting selects the access method, such as gin or
+// gist on Postgres. Models implementing IndexDeclarer declare more indexes in
+// code. GenerateIndexes renders the missing indexes for the dialect of the
+// connection, IndexReconciler creates them, concurrently where supported, and
+// CheckIndexes reports indexes whose definition drifted from the declaration.
+package migrations
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// IndexDeclarer is implemented by models declaring indexes in code, such as
+// indexes on expressions too long for a tag:
+//
+//	func (Order) DeclareIndexes() []dialect.IndexDefinition {
+//		return []dialect.IndexDefinition{{
+//			Name:    "idx_orders_tags",
+//			Columns: []dialect.IndexColumn{{Name: "tags"}},
+//			Method:  "gin",
+//		}}
+//	}
+//
+// Declared indexes default to the table of the model. AutoMigrate does not
+// create them; CreateIndexes and IndexReconciler do.
+type IndexDeclarer interface {
+	DeclareIndexes() []dialect.IndexDefinition
+}
+
+// ModelIndexes returns the indexes declared on model, by tags then by
+// IndexDeclarer. FULLTEXT and SPATIAL indexes are engine specific and left to
+// AutoMigrate.
+func ModelIndexes(db *gorm.DB, model interface{}) ([]dialect.IndexDefinition, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, fmt.Errorf("failed to parse model %T: %w", model, err)
+	}
+	s := stmt.Schema
+
+	includes, err := parseIncludes(db, s)
+	if err != nil {
+		return nil, err
+	}
+
+	var indexes []dialect.IndexDefinition
+	for _, index := range s.ParseIndexes() {
+		if index.Class != "" && index.Class != "UNIQUE" {
+			continue
+		}
+		def := dialect.IndexDefinition{
+			Name:    index.Name,
+			Table:   s.Table,
+			Unique:  index.Class == "UNIQUE",
+			Where:   index.Where,
+			Include: includes[index.Name],
+			Method:  index.Type,
+		}
+		for _, option := range index.Fields {
+			column := dialect.IndexColumn{Expression: option.Expression, Sort: option.Sort}
+			if option.Expression == "" {
+				column.Name = option.DBName
+			}
+			def.Columns = append(def.Columns, column)
+		}
+		indexes = append(indexes, def)
+	}
+
+	declared, err := declaredIndexes(s, indexes)
+	if err != nil {
+		return nil, err
+	}
+	return append(indexes, declared...), nil
+}
+
+// declaredIndexes returns the indexes the model of s declares in code, which
+// must not reuse the name of an index of tagged
+func declaredIndexes(s *schema.Schema, tagged []dialect.IndexDefinition) ([]dialect.IndexDefinition, error) {
+	declarer, ok := reflect.New(s.ModelType).Interface().(IndexDeclarer)
+	if !ok {
+		return nil, nil
+	}
+
+	names := make(map[string]bool, len(tagged))
+	for _, index := range tagged {
+		names[index.Name] = true
+	}
+	declared := declarer.DeclareIndexes()
+	for i := range declared {
+		index := &declared[i]
+		if index.Name == "" || len(index.Columns) == 0 {
+			return nil, fmt.Errorf("index declared by %s requires a name and at least one column", s.Name)
+		}
+		if names[index.Name] {
+			return nil, fmt.Errorf("index %s of %s is declared twice", index.Name, s.Name)
+		}
+		names[index.Name] = true
+		if index.Table == "" {
+			index.Table = s.Table
+		}
+	}
+	return declared, nil
+}
+
+// parseIncludes reads the include setting of the index tags of s, keyed by
+// index name. Included columns are given by field or column name.
+func parseIncludes(db *gorm.DB, s *schema.Schema) (map[string][]string, error) {
+	includes := make(map[string][]string)
+	for _, field := range s.Fields {
+		for _, value := range strings.Split(field.Tag.Get("gorm"), ";") {
+			parts := strings.SplitN(value, ":", 2)
+			key := strings.TrimSpace(strings.ToUpper(parts[0]))
+			if len(parts) != 2 || (key != "INDEX" && key != "UNIQUEINDEX") {
+				continue
+			}
+
+			name, settings := parts[1], ""
+			if i := strings.IndexByte(parts[1], ','); i >= 0 {
+				name, settings = parts[1][:i], parts[1][i+1:]
+			}
+			parsed := schema.ParseTagSetting(settings, ",")
+			include := strings.Fields(parsed["INCLUDE"])
+			if len(include) == 0 {
+				continue
+			}
+
+			if name == "" {
+				subName := field.Name
+				if composite := parsed["COMPOSITE"]; composite != "" && composite != "COMPOSITE" {
+					subName = composite
+				}
+				name = db.NamingStrategy.IndexName(s.Table, subName)
+			}
+
+			for _, column := range include {
+				included := s.LookUpField(column)
+				if included == nil || included.DBName == "" {
+					return nil, fmt.Errorf("index %s of %s includes unknown column %q", name, s.Name, column)
+				}
+				includes[name] = append(includes[name], included.DBName)
+			}
+		}
+	}
+	return includes, nil
+}
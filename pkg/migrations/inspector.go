@@ -0,0 +1,296 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ErrSchemaDrift is returned by schema inspectors failing on drift when the
+// database schema differs from the models
+var ErrSchemaDrift = errors.New("database schema drifted from the models")
+
+// Column drift problems, besides DriftMissing
+const (
+	DriftType     = "type"
+	DriftNullable = "nullable"
+	DriftExtra    = "extra"
+)
+
+// ColumnDrift describes a difference between a column declared on a model
+// and the database
+type ColumnDrift struct {
+	Table    string `json:"table"`
+	Column   string `json:"column"`
+	Problem  string `json:"problem"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// String returns a readable description of the drift
+func (d ColumnDrift) String() string {
+	switch d.Problem {
+	case DriftMissing:
+		return fmt.Sprintf("%s.%s: missing (expected %s)", d.Table, d.Column, d.Expected)
+	case DriftExtra:
+		return fmt.Sprintf("%s.%s: not declared by the model (actual %s)", d.Table, d.Column, d.Actual)
+	}
+	return fmt.Sprintf("%s.%s: %s differs (expected %q, actual %q)", d.Table, d.Column, d.Problem, d.Expected, d.Actual)
+}
+
+// SchemaDiff is the drift of the database schema from the models
+type SchemaDiff struct {
+	// MissingTables lists the tables of the models missing from the database
+	MissingTables []string       `json:"missing_tables,omitempty"`
+	Columns       []ColumnDrift  `json:"columns,omitempty"`
+	Indexes       []IndexDrift   `json:"indexes,omitempty"`
+	Defaults      []DefaultDrift `json:"defaults,omitempty"`
+}
+
+// Empty reports whether the database schema matches the models
+func (d *SchemaDiff) Empty() bool {
+	return len(d.MissingTables) == 0 && len(d.Columns) == 0 && len(d.Indexes) == 0 && len(d.Defaults) == 0
+}
+
+// Count returns the number of drifts
+func (d *SchemaDiff) Count() int {
+	return len(d.MissingTables) + len(d.Columns) + len(d.Indexes) + len(d.Defaults)
+}
+
+// Lines returns a readable description of each drift, tables first
+func (d *SchemaDiff) Lines() []string {
+	lines := make([]string, 0, d.Count())
+	for _, table := range d.MissingTables {
+		lines = append(lines, fmt.Sprintf("%s: missing table", table))
+	}
+	for _, drift := range d.Columns {
+		lines = append(lines, drift.String())
+	}
+	for _, drift := range d.Indexes {
+		lines = append(lines, drift.String())
+	}
+	for _, drift := range d.Defaults {
+		lines = append(lines, drift.String())
+	}
+	return lines
+}
+
+// String returns the drifts one per line
+func (d *SchemaDiff) String() string {
+	return strings.Join(d.Lines(), "\n")
+}
+
+// Err returns ErrSchemaDrift describing the drifts, nil when the schema
+// matches
+func (d *SchemaDiff) Err() error {
+	if d.Empty() {
+		return nil
+	}
+	return fmt.Errorf("%w: %d differences: %s", ErrSchemaDrift, d.Count(), strings.Join(d.Lines(), "; "))
+}
+
+// SchemaInspectorConfig represents schema inspector configuration
+type SchemaInspectorConfig struct {
+	// FailOnDrift makes Inspect return ErrSchemaDrift with the diff when the
+	// database schema differs from the models
+	FailOnDrift bool `json:"fail_on_drift"`
+	// ReportExtraColumns reports the columns of the database the models do
+	// not declare, such as columns left behind by a removed field
+	ReportExtraColumns bool `json:"report_extra_columns"`
+}
+
+// SchemaInspector compares the models with the live database schema and
+// reports the tables and columns missing from the database, the columns
+// whose type or nullability differ, the missing or drifted indexes (see
+// CheckIndexes) and the drifted defaults (see CheckDefaults). It suits CI
+// jobs and startup checks:
+//
+//	inspector, err := migrations.NewSchemaInspector(db, logger, &migrations.SchemaInspectorConfig{FailOnDrift: true})
+//	diff, err := inspector.Inspect(ctx, &User{}, &Order{})
+//	if err != nil {
+//		log.Fatal(err) // errors.Is(err, migrations.ErrSchemaDrift)
+//	}
+//
+// Types are compared as AutoMigrate does: the database type must prefix the
+// type the dialect renders for the field, or one of its aliases, and sizes
+// must match when both are known.
+type SchemaInspector struct {
+	db     *gorm.DB
+	logger logging.Logger
+	config *SchemaInspectorConfig
+}
+
+// NewSchemaInspector creates a schema inspector of db
+func NewSchemaInspector(db *gorm.DB, logger logging.Logger, config *SchemaInspectorConfig) (*SchemaInspector, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+	if config == nil {
+		config = &SchemaInspectorConfig{}
+	}
+	return &SchemaInspector{db: db, logger: logger, config: config}, nil
+}
+
+// Inspect compares models with the database and returns the diff. With
+// FailOnDrift, a non-empty diff is also returned as an ErrSchemaDrift error.
+func (i *SchemaInspector) Inspect(ctx context.Context, models ...interface{}) (*SchemaDiff, error) {
+	db := i.db.WithContext(ctx)
+	migrator := db.Migrator()
+
+	diff := &SchemaDiff{}
+	var present []interface{}
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, fmt.Errorf("failed to parse model %T: %w", model, err)
+		}
+		if !migrator.HasTable(model) {
+			diff.MissingTables = append(diff.MissingTables, stmt.Schema.Table)
+			continue
+		}
+		present = append(present, model)
+
+		drifts, err := i.compareColumns(db, stmt.Schema, model)
+		if err != nil {
+			return nil, err
+		}
+		diff.Columns = append(diff.Columns, drifts...)
+	}
+
+	indexes, err := CheckIndexes(ctx, i.db, present...)
+	if err != nil {
+		return nil, err
+	}
+	diff.Indexes = indexes
+	defaults, err := CheckDefaults(ctx, i.db, present...)
+	if err != nil {
+		return nil, err
+	}
+	diff.Defaults = defaults
+
+	i.log(ctx, diff)
+	if i.config.FailOnDrift {
+		if err := diff.Err(); err != nil {
+			return diff, err
+		}
+	}
+	return diff, nil
+}
+
+// compareColumns compares the columns declared on the model of s with its
+// table
+func (i *SchemaInspector) compareColumns(db *gorm.DB, s *schema.Schema, model interface{}) ([]ColumnDrift, error) {
+	migrator := db.Migrator()
+	columnTypes, err := migrator.ColumnTypes(model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %w", s.Table, err)
+	}
+	columns := make(map[string]gorm.ColumnType, len(columnTypes))
+	for _, columnType := range columnTypes {
+		columns[strings.ToLower(columnType.Name())] = columnType
+	}
+
+	var drifts []ColumnDrift
+	declared := make(map[string]bool, len(s.DBNames))
+	for _, name := range s.DBNames {
+		field := s.FieldsByDBName[name]
+		if field.IgnoreMigration {
+			continue
+		}
+		declared[strings.ToLower(name)] = true
+		expected := strings.ToLower(strings.TrimSpace(db.Dialector.DataTypeOf(field)))
+
+		columnType, ok := columns[strings.ToLower(name)]
+		if !ok {
+			drifts = append(drifts, ColumnDrift{Table: s.Table, Column: name, Problem: DriftMissing, Expected: expected})
+			continue
+		}
+		if actual, same := sameType(migrator, field, expected, columnType); !same {
+			drifts = append(drifts, ColumnDrift{Table: s.Table, Column: name, Problem: DriftType, Expected: expected, Actual: actual})
+		}
+		if nullable, ok := columnType.Nullable(); ok && !field.PrimaryKey && nullable == field.NotNull {
+			drifts = append(drifts, ColumnDrift{
+				Table: s.Table, Column: name, Problem: DriftNullable,
+				Expected: nullability(!field.NotNull), Actual: nullability(nullable),
+			})
+		}
+	}
+
+	if i.config.ReportExtraColumns {
+		var extra []ColumnDrift
+		for _, columnType := range columnTypes {
+			if !declared[strings.ToLower(columnType.Name())] {
+				extra = append(extra, ColumnDrift{Table: s.Table, Column: columnType.Name(), Problem: DriftExtra, Actual: columnDataType(columnType)})
+			}
+		}
+		sort.Slice(extra, func(a, b int) bool { return extra[a].Column < extra[b].Column })
+		drifts = append(drifts, extra...)
+	}
+	return drifts, nil
+}
+
+// typeSize matches the size of a data type, such as varchar(255)
+var typeSize = regexp.MustCompile(`\((\d+)\)`)
+
+// sameType reports whether the type of column matches the type expected by
+// field, and returns the type of column
+func sameType(migrator gorm.Migrator, field *schema.Field, expected string, column gorm.ColumnType) (string, bool) {
+	actual := columnDataType(column)
+	base := strings.ToLower(column.DatabaseTypeName())
+	if base == "" {
+		return actual, true
+	}
+
+	same := strings.HasPrefix(expected, base)
+	for _, alias := range migrator.GetTypeAliases(base) {
+		same = same || strings.HasPrefix(expected, strings.ToLower(alias))
+	}
+	if !same {
+		return actual, false
+	}
+
+	// Sizes are only known when the dialect renders them and the database
+	// reports them
+	length, ok := column.Length()
+	if match := typeSize.FindStringSubmatch(expected); ok && length > 0 && match != nil && !field.PrimaryKey {
+		return actual, match[1] == fmt.Sprint(length)
+	}
+	return actual, true
+}
+
+// columnDataType returns the full type of column, with its size
+func columnDataType(column gorm.ColumnType) string {
+	if full, ok := column.ColumnType(); ok && full != "" {
+		return strings.ToLower(full)
+	}
+	return strings.ToLower(column.DatabaseTypeName())
+}
+
+// nullability describes whether a column is nullable
+func nullability(nullable bool) string {
+	if nullable {
+		return "NULL"
+	}
+	return "NOT NULL"
+}
+
+// log logs the drifts found, or that the schema matches
+func (i *SchemaInspector) log(ctx context.Context, diff *SchemaDiff) {
+	if i.logger == nil {
+		return
+	}
+	if diff.Empty() {
+		i.logger.Debug(ctx, "Database schema matches the models")
+		return
+	}
+	for _, line := range diff.Lines() {
+		i.logger.Warn(ctx, "Database schema drift detected", logging.String("drift", line))
+	}
+}
@@ -0,0 +1,585 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/config"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrLocked is returned when another process holds the migration lock
+	// beyond the lock timeout
+	ErrLocked = errors.New("migrations are locked by another process")
+	// ErrIrreversible is returned when rolling back a migration without a down step
+	ErrIrreversible = errors.New("migration has no down step")
+	// ErrLockLost is returned when another process took over the migration
+	// lock while migrating; the running migration is cancelled
+	ErrLockLost = errors.New("migration lock was lost")
+
+	// errMigrated marks a migration already applied, or rolled back, by
+	// another process since the applied versions were read
+	errMigrated = errors.New("migration already run")
+)
+
+// MigrationFunc is a migration step written in Go
+type MigrationFunc func(ctx context.Context, tx *gorm.DB) error
+
+// Migration is a versioned schema change. Each direction is given as SQL or
+// as a Go function; when both are set the SQL runs first. SQL may hold
+// several statements, run one by one as by ExecScript.
+type Migration struct {
+	Version int64
+	Name    string
+	UpSQL   string
+	DownSQL string
+	Up      MigrationFunc
+	Down    MigrationFunc
+}
+
+// reversible reports whether the migration can be rolled back
+func (m Migration) reversible() bool {
+	return m.DownSQL != "" || m.Down != nil
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// MigrationResult describes a migration run (or planned, in dry-run mode)
+type MigrationResult struct {
+	Version  int64         `json:"version"`
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+}
+
+// MigrationReport summarizes an Up or Down run
+type MigrationReport struct {
+	Direction string            `json:"direction"`
+	DryRun    bool              `json:"dry_run"`
+	Results   []MigrationResult `json:"results"`
+}
+
+// schemaMigration is a row of the migrations table
+type schemaMigration struct {
+	Version   int64  `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:255"`
+	AppliedAt time.Time
+}
+
+// migrationLock is the single row of the lock table held while migrating
+type migrationLock struct {
+	ID       int    `gorm:"primaryKey;autoIncrement:false"`
+	LockedBy string `gorm:"size:255"`
+	LockedAt time.Time
+}
+
+// MigratorConfig represents migrator configuration
+type MigratorConfig struct {
+	// Table records the applied versions; the lock is held in Table + "_lock"
+	Table string `json:"table"`
+	// LockTimeout bounds the wait for another process to release the lock
+	LockTimeout time.Duration `json:"lock_timeout"`
+	// StaleLockAfter is the age after which a lock left by a crashed process is taken over
+	// and a third of it the interval at which the holder refreshes its lock
+	StaleLockAfter time.Duration `json:"stale_lock_after"`
+	// DryRun reports the pending migrations without running them
+	DryRun bool `json:"dry_run"`
+	// Owner identifies the process holding the lock; defaults to hostname and pid
+	Owner string `json:"owner"`
+}
+
+// DefaultMigratorConfig returns the default migrator configuration
+func DefaultMigratorConfig() *MigratorConfig {
+	return &MigratorConfig{
+		Table:          "schema_migrations",
+		LockTimeout:    time.Minute,
+		StaleLockAfter: 15 * time.Minute,
+	}
+}
+
+// lockPollInterval is the interval between attempts to take the lock
+const lockPollInterval = 250 * time.Millisecond
+
+// Migrator applies and rolls back versioned migrations, recording the applied
+// versions in a migrations table. Runs are serialized across processes by a
+// lock row, so concurrent deploys do not apply a migration twice. Each
+// migration runs in its own transaction together with its version record;
+// engines that auto-commit DDL (MySQL) cannot roll back a failed migration's
+// schema changes.
+type Migrator struct {
+	db         *gorm.DB
+	logger     logging.Logger
+	config     *MigratorConfig
+	migrations []Migration
+}
+
+// NewMigrator creates a new migrator
+func NewMigrator(db *gorm.DB, logger logging.Logger, config *MigratorConfig) (*Migrator, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+	if config == nil {
+		config = DefaultMigratorConfig()
+	}
+	if config.Table == "" {
+		config.Table = DefaultMigratorConfig().Table
+	}
+	if config.Owner == "" {
+		host, _ := os.Hostname()
+		config.Owner = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	return &Migrator{db: db, logger: logger, config: config}, nil
+}
+
+// NewMigratorFromConfig creates a migrator from the migrations section of the
+// database configuration, loading the SQL migrations of its directory when it exists
+func NewMigratorFromConfig(db *gorm.DB, cfg *config.MigrationsConfig, logger logging.Logger) (*Migrator, error) {
+	migratorConfig := DefaultMigratorConfig()
+	dir := ""
+	if cfg != nil {
+		if cfg.Table != "" {
+			migratorConfig.Table = cfg.Table
+		}
+		if cfg.LockTimeout > 0 {
+			migratorConfig.LockTimeout = cfg.LockTimeout
+		}
+		migratorConfig.DryRun = cfg.DryRun
+		dir = cfg.Dir
+	}
+
+	m, err := NewMigrator(db, logger, migratorConfig)
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			if err := m.AddFS(os.DirFS(dir), "."); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m, nil
+}
+
+// Add registers migrations. Versions must be positive and unique and every
+// migration needs an up step.
+func (m *Migrator) Add(migrations ...Migration) error {
+	known := make(map[int64]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+	}
+	for _, migration := range migrations {
+		if migration.Version <= 0 {
+			return fmt.Errorf("migration %q: version must be positive", migration.Name)
+		}
+		if known[migration.Version] {
+			return fmt.Errorf("migration %d: duplicate version", migration.Version)
+		}
+		if migration.UpSQL == "" && migration.Up == nil {
+			return fmt.Errorf("migration %d: up step is required", migration.Version)
+		}
+		known[migration.Version] = true
+		m.migrations = append(m.migrations, migration)
+	}
+	sort.Slice(m.migrations, func(i, j int) bool { return m.migrations[i].Version < m.migrations[j].Version })
+	return nil
+}
+
+// migrationFile matches <version>_<name>.up.sql and <version>_<name>.down.sql
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// AddFS registers the SQL migrations of dir in fsys, named
+// <version>_<name>.up.sql with an optional <version>_<name>.down.sql.
+// Other files are ignored.
+func (m *Migrator) AddFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration := byVersion[version]
+		if migration == nil {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return fmt.Errorf("migration %d has files with different names (%s, %s)", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.UpSQL = string(content)
+		} else {
+			migration.DownSQL = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		migrations = append(migrations, *migration)
+	}
+	return m.Add(migrations...)
+}
+
+// Migrations returns the registered migrations ordered by version
+func (m *Migrator) Migrations() []Migration {
+	return append([]Migration(nil), m.migrations...)
+}
+
+// Status lists the registered migrations and the applied versions without a
+// registered migration, ordered by version
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	registered := make(map[int64]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		registered[migration.Version] = true
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.Applied, status.AppliedAt = true, &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	for version, row := range applied {
+		if !registered[version] {
+			appliedAt := row.AppliedAt
+			statuses = append(statuses, MigrationStatus{Version: version, Name: row.Name, Applied: true, AppliedAt: &appliedAt})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Version returns the highest applied version, 0 when none is applied
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var version int64
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// Up applies every pending migration in version order
+func (m *Migrator) Up(ctx context.Context) (*MigrationReport, error) {
+	return m.UpTo(ctx, 0)
+}
+
+// UpTo applies the pending migrations up to and including version; 0 applies all
+func (m *Migrator) UpTo(ctx context.Context, version int64) (*MigrationReport, error) {
+	ctx = bypassGuard(ctx)
+	report := &MigrationReport{Direction: "up", DryRun: m.config.DryRun}
+	err := m.withLock(ctx, func(ctx context.Context) error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if version > 0 && migration.Version > version {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			result, err := m.run(ctx, migration, true)
+			if err != nil {
+				return err
+			}
+			if result != nil {
+				report.Results = append(report.Results, *result)
+			}
+		}
+		return nil
+	})
+	return report, err
+}
+
+// Down rolls back the last steps applied migrations, newest first
+func (m *Migrator) Down(ctx context.Context, steps int) (*MigrationReport, error) {
+	ctx = bypassGuard(ctx)
+	report := &MigrationReport{Direction: "down", DryRun: m.config.DryRun}
+	if steps <= 0 {
+		return report, nil
+	}
+
+	registered := make(map[int64]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		registered[migration.Version] = migration
+	}
+
+	err := m.withLock(ctx, func(ctx context.Context) error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		versions := make([]int64, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, version := range versions {
+			if steps == 0 {
+				break
+			}
+			migration, ok := registered[version]
+			if !ok || !migration.reversible() {
+				return fmt.Errorf("%w: version %d", ErrIrreversible, version)
+			}
+			result, err := m.run(ctx, migration, false)
+			if err != nil {
+				return err
+			}
+			if result != nil {
+				report.Results = append(report.Results, *result)
+				steps--
+			}
+		}
+		return nil
+	})
+	return report, err
+}
+
+// run applies or rolls back one migration together with its version record.
+// The record is checked again in the migration transaction; a migration run
+// by another process in the meantime is skipped and returns no result.
+func (m *Migrator) run(ctx context.Context, migration Migration, up bool) (*MigrationResult, error) {
+	result := MigrationResult{Version: migration.Version, Name: migration.Name}
+	direction := "down"
+	if up {
+		direction = "up"
+	}
+
+	if m.config.DryRun {
+		m.log(ctx, "Migration pending (dry run)", migration, direction)
+		return &result, nil
+	}
+
+	start := time.Now()
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var recorded int64
+		if err := tx.Table(m.config.Table).Where("version = ?", migration.Version).Count(&recorded).Error; err != nil {
+			return err
+		}
+		if (recorded > 0) == up {
+			return errMigrated
+		}
+
+		sql, fn := migration.DownSQL, migration.Down
+		if up {
+			sql, fn = migration.UpSQL, migration.Up
+		}
+		if sql != "" {
+			if _, err := ExecScript(ctx, tx, sql, nil); err != nil {
+				return err
+			}
+		}
+		if fn != nil {
+			if err := fn(ctx, tx); err != nil {
+				return err
+			}
+		}
+
+		if up {
+			return tx.Table(m.config.Table).Create(&schemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now().UTC(),
+			}).Error
+		}
+		return tx.Table(m.config.Table).Where("version = ?", migration.Version).Delete(&schemaMigration{}).Error
+	})
+	result.Duration = time.Since(start)
+	if errors.Is(err, errMigrated) {
+		m.log(ctx, "Migration already run by another process, skipping", migration, direction)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("migration %d (%s) %s failed: %w", migration.Version, migration.Name, direction, err)
+	}
+
+	m.log(ctx, "Migration applied", migration, direction, logging.Duration("duration", result.Duration))
+	return &result, nil
+}
+
+// applied returns the applied migrations keyed by version. A missing table
+// means nothing has been applied yet.
+func (m *Migrator) applied(ctx context.Context) (map[int64]schemaMigration, error) {
+	db := m.db.WithContext(bypassGuard(ctx))
+	applied := make(map[int64]schemaMigration)
+	if !db.Migrator().HasTable(m.config.Table) {
+		return applied, nil
+	}
+
+	var rows []schemaMigration
+	if err := db.Table(m.config.Table).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// withLock runs fn while holding the migration lock. The lock is refreshed
+// while fn runs so it never looks stale to other processes; the context passed
+// to fn is cancelled if the lock is lost. Dry runs only read and neither create
+// tables nor take the lock.
+func (m *Migrator) withLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	if m.config.DryRun {
+		return fn(ctx)
+	}
+
+	db := m.db.WithContext(ctx)
+	lockTable := m.config.Table + "_lock"
+	if err := db.Table(m.config.Table).AutoMigrate(&schemaMigration{}); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+	if err := db.Table(lockTable).AutoMigrate(&migrationLock{}); err != nil {
+		return fmt.Errorf("failed to create migrations lock table: %w", err)
+	}
+
+	if err := m.lock(ctx, lockTable); err != nil {
+		return err
+	}
+	defer func() {
+		// Release even when ctx is canceled so the next run does not wait for the stale timeout
+		release := m.db.WithContext(context.Background()).Table(lockTable).
+			Where("id = ? AND locked_by = ?", 1, m.config.Owner).Delete(&migrationLock{})
+		if release.Error != nil && m.logger != nil {
+			m.logger.Error(ctx, "Failed to release migration lock", logging.ErrorField("error", release.Error))
+		}
+	}()
+
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if m.config.StaleLockAfter > 0 {
+		go m.heartbeat(runCtx, cancel, lockTable)
+	}
+	err := fn(runCtx)
+	if cause := context.Cause(runCtx); err != nil && errors.Is(cause, ErrLockLost) {
+		return cause
+	}
+	return err
+}
+
+// heartbeat refreshes locked_at until ctx is done, cancelling ctx when the
+// lock row no longer belongs to this process
+func (m *Migrator) heartbeat(ctx context.Context, cancel context.CancelCauseFunc, lockTable string) {
+	ticker := time.NewTicker(m.config.StaleLockAfter / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result := m.db.WithContext(ctx).Table(lockTable).
+				Where("id = ? AND locked_by = ?", 1, m.config.Owner).
+				Update("locked_at", time.Now().UTC())
+			switch {
+			case ctx.Err() != nil:
+				return
+			case result.Error != nil:
+				// Retried on the next tick; the lock only goes stale after StaleLockAfter
+				if m.logger != nil {
+					m.logger.Warn(ctx, "Failed to refresh migration lock", logging.ErrorField("error", result.Error))
+				}
+			case result.RowsAffected == 0:
+				cancel(fmt.Errorf("%w: no longer held by %s", ErrLockLost, m.config.Owner))
+				return
+			}
+		}
+	}
+}
+
+// lock inserts the lock row, waiting up to LockTimeout for the current holder
+// and taking over locks older than StaleLockAfter
+func (m *Migrator) lock(ctx context.Context, lockTable string) error {
+	db := m.db.WithContext(ctx)
+	deadline := time.Now().Add(m.config.LockTimeout)
+	for {
+		err := db.Table(lockTable).Create(&migrationLock{ID: 1, LockedBy: m.config.Owner, LockedAt: time.Now().UTC()}).Error
+		if err == nil {
+			return nil
+		}
+
+		var holder migrationLock
+		findErr := db.Table(lockTable).Where("id = ?", 1).Take(&holder).Error
+		switch {
+		case errors.Is(findErr, gorm.ErrRecordNotFound):
+			// Released in the meantime, or the insert failed for another reason
+			if !time.Now().Before(deadline) {
+				return fmt.Errorf("failed to acquire migration lock: %w", err)
+			}
+		case findErr != nil:
+			return fmt.Errorf("failed to acquire migration lock: %w", findErr)
+		case m.config.StaleLockAfter > 0 && time.Since(holder.LockedAt) > m.config.StaleLockAfter:
+			if m.logger != nil {
+				m.logger.Warn(ctx, "Taking over stale migration lock",
+					logging.String("locked_by", holder.LockedBy),
+					logging.Time("locked_at", holder.LockedAt))
+			}
+			db.Table(lockTable).Where("id = ? AND locked_by = ?", 1, holder.LockedBy).Delete(&migrationLock{})
+			continue
+		case !time.Now().Before(deadline):
+			return fmt.Errorf("%w: held by %s since %s", ErrLocked, holder.LockedBy, holder.LockedAt.Format(time.RFC3339))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// log logs a migration step
+func (m *Migrator) log(ctx context.Context, message string, migration Migration, direction string, fields ...logging.LogField) {
+	if m.logger == nil {
+		return
+	}
+	fields = append([]logging.LogField{
+		logging.Int64("version", migration.Version),
+		logging.String("name", migration.Name),
+		logging.String("direction", direction),
+	}, fields...)
+	m.logger.Info(ctx, message, fields...)
+}
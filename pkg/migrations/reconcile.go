@@ -0,0 +1,236 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"gorm.io/gorm"
+)
+
+// IndexBuild describes an index created by a reconciler
+type IndexBuild struct {
+	Table     string `json:"table"`
+	Index     string `json:"index"`
+	Statement string `json:"statement"`
+	// Concurrently reports whether the index was built without blocking
+	// writes to its table
+	Concurrently bool          `json:"concurrently"`
+	Duration     time.Duration `json:"duration"`
+}
+
+// IndexProgress reports the progress of a reconciliation after each index
+type IndexProgress struct {
+	IndexBuild
+	// Done is the number of indexes created so far
+	Done int `json:"done"`
+	// Total is the number of indexes to create
+	Total int `json:"total"`
+	// Elapsed is the time since the reconciliation started
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// IndexReport is the outcome of a reconciliation
+type IndexReport struct {
+	// Created lists the indexes created, in order
+	Created []IndexBuild `json:"created,omitempty"`
+	// Existing is the number of declared indexes already in the database
+	Existing int `json:"existing"`
+	// Pending lists the indexes left to create when the reconciliation
+	// stopped
+	Pending  []string      `json:"pending,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// IndexReconcilerConfig represents index reconciler configuration
+type IndexReconcilerConfig struct {
+	// Blocking creates the indexes with plain CREATE INDEX statements even
+	// on dialects that can build them concurrently, such as on empty tables
+	Blocking bool `json:"blocking"`
+	// Progress, when set, is called after each index is created
+	Progress func(IndexProgress) `json:"-"`
+}
+
+// IndexReconciler creates the indexes declared on models, by tags or by
+// IndexDeclarer, that are missing from the database. On Postgres and
+// CockroachDB they are built with CREATE INDEX CONCURRENTLY, which does not
+// block writes but cannot run in a transaction: a reconciler on a
+// transaction builds them with plain statements. It suits deployments
+// adding indexes to large tables:
+//
+//	reconciler, err := migrations.NewIndexReconciler(db, logger, &migrations.IndexReconcilerConfig{
+//		Progress: func(p migrations.IndexProgress) {
+//			log.Printf("%d/%d: %s built in %s", p.Done, p.Total, p.Index, p.Duration)
+//		},
+//	})
+//	report, err := reconciler.Reconcile(ctx, &User{}, &Order{})
+//
+// Every statement is rendered before the first index is built, so an index
+// the dialect cannot express fails the reconciliation without building any.
+// The context is checked between indexes. A failed concurrent build leaves an
+// invalid index behind on Postgres, which the reconciler drops so the next
+// run builds it again.
+type IndexReconciler struct {
+	db     *gorm.DB
+	logger logging.Logger
+	config *IndexReconcilerConfig
+}
+
+// NewIndexReconciler creates an index reconciler of db
+func NewIndexReconciler(db *gorm.DB, logger logging.Logger, config *IndexReconcilerConfig) (*IndexReconciler, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+	if config == nil {
+		config = &IndexReconcilerConfig{}
+	}
+	return &IndexReconciler{db: db, logger: logger, config: config}, nil
+}
+
+// pendingIndex is an index to create
+type pendingIndex struct {
+	model     interface{}
+	index     dialect.IndexDefinition
+	statement string
+}
+
+// Reconcile creates the indexes of models missing from the database, in
+// declaration order, and returns the report. On failure the report lists the
+// indexes created before it and the pending ones.
+func (r *IndexReconciler) Reconcile(ctx context.Context, models ...interface{}) (*IndexReport, error) {
+	start := time.Now()
+	db := r.db.WithContext(ctx)
+	concurrently := r.concurrently(db)
+
+	report := &IndexReport{}
+	pending, err := r.plan(db, concurrently, report, models)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, build := range pending {
+		if err := ctx.Err(); err != nil {
+			report.Pending = pendingNames(pending[i:])
+			report.Duration = time.Since(start)
+			return report, fmt.Errorf("index %s not started: %w", build.index.Name, err)
+		}
+		if r.logger != nil {
+			r.logger.Info(ctx, "Creating index",
+				logging.String("table", build.index.Table),
+				logging.String("index", build.index.Name),
+				logging.Bool("concurrently", concurrently))
+		}
+
+		built := time.Now()
+		if err := db.Exec(build.statement).Error; err != nil {
+			if concurrently {
+				r.dropInvalid(ctx, db, build)
+			}
+			report.Pending = pendingNames(pending[i:])
+			report.Duration = time.Since(start)
+			return report, fmt.Errorf("failed to create index (%s): %w", build.statement, err)
+		}
+
+		created := IndexBuild{
+			Table:        build.index.Table,
+			Index:        build.index.Name,
+			Statement:    build.statement,
+			Concurrently: concurrently,
+			Duration:     time.Since(built),
+		}
+		report.Created = append(report.Created, created)
+		if r.logger != nil {
+			r.logger.Info(ctx, "Index created",
+				logging.String("table", created.Table),
+				logging.String("index", created.Index),
+				logging.Duration("duration", created.Duration))
+		}
+		if r.config.Progress != nil {
+			r.config.Progress(IndexProgress{IndexBuild: created, Done: i + 1, Total: len(pending), Elapsed: time.Since(start)})
+		}
+	}
+
+	report.Duration = time.Since(start)
+	if r.logger != nil {
+		r.logger.Info(ctx, "Indexes reconciled",
+			logging.Int("created", len(report.Created)),
+			logging.Int("existing", report.Existing),
+			logging.Duration("duration", report.Duration))
+	}
+	return report, nil
+}
+
+// concurrently reports whether indexes are built concurrently on db
+func (r *IndexReconciler) concurrently(db *gorm.DB) bool {
+	if r.config.Blocking {
+		return false
+	}
+	if _, ok := dialect.For(db).(dialect.ConcurrentIndexer); !ok {
+		return false
+	}
+	_, inTx := db.Statement.ConnPool.(gorm.TxCommitter)
+	return !inTx
+}
+
+// plan renders the statements of the indexes of models missing from the
+// database, counting the existing ones in report
+func (r *IndexReconciler) plan(db *gorm.DB, concurrently bool, report *IndexReport, models []interface{}) ([]pendingIndex, error) {
+	d := dialect.For(db)
+	migrator := db.Migrator()
+
+	var pending []pendingIndex
+	for _, model := range models {
+		indexes, err := ModelIndexes(db, model)
+		if err != nil {
+			return nil, err
+		}
+		if len(indexes) == 0 {
+			continue
+		}
+		if !migrator.HasTable(model) {
+			return nil, fmt.Errorf("table %s of index %s does not exist", indexes[0].Table, indexes[0].Name)
+		}
+
+		for _, index := range indexes {
+			if migrator.HasIndex(model, index.Name) {
+				report.Existing++
+				continue
+			}
+			render := dialect.CreateIndex
+			if concurrently {
+				render = dialect.CreateIndexConcurrently
+			}
+			statement, err := render(d, index)
+			if err != nil {
+				return nil, err
+			}
+			pending = append(pending, pendingIndex{model: model, index: index, statement: statement})
+		}
+	}
+	return pending, nil
+}
+
+// dropInvalid drops the index a failed concurrent build left behind
+func (r *IndexReconciler) dropInvalid(ctx context.Context, db *gorm.DB, build pendingIndex) {
+	migrator := db.Migrator()
+	if !migrator.HasIndex(build.model, build.index.Name) {
+		return
+	}
+	if err := migrator.DropIndex(build.model, build.index.Name); err != nil && r.logger != nil {
+		r.logger.Warn(ctx, "Failed to drop invalid index",
+			logging.String("table", build.index.Table),
+			logging.String("index", build.index.Name),
+			logging.ErrorField("error", err))
+	}
+}
+
+// pendingNames returns the names of the indexes of pending
+func pendingNames(pending []pendingIndex) []string {
+	names := make([]string, len(pending))
+	for i, build := range pending {
+		names[i] = build.index.Name
+	}
+	return names
+}
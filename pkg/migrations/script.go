@@ -0,0 +1,326 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"gorm.io/gorm"
+)
+
+// ScriptStatement is a statement of a SQL script
+type ScriptStatement struct {
+	// Index is the 1-based position of the statement in the script
+	Index int `json:"index"`
+	// Line is the 1-based line the statement starts on
+	Line int    `json:"line"`
+	SQL  string `json:"sql"`
+}
+
+// StatementResult describes a statement run (or planned, in dry-run mode)
+type StatementResult struct {
+	ScriptStatement
+	Duration     time.Duration `json:"duration"`
+	RowsAffected int64         `json:"rows_affected"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// ScriptReport summarizes a script run
+type ScriptReport struct {
+	DryRun     bool              `json:"dry_run"`
+	Statements []StatementResult `json:"statements"`
+	Duration   time.Duration     `json:"duration"`
+	Failed     int               `json:"failed"`
+}
+
+// ScriptConfig represents script execution configuration
+type ScriptConfig struct {
+	// DryRun splits the script and reports its statements without running them
+	DryRun bool `json:"dry_run"`
+	// ContinueOnError runs the remaining statements after a failure instead
+	// of stopping at the first one
+	ContinueOnError bool `json:"continue_on_error"`
+}
+
+// ExecScript splits script into statements in the dialect of db and executes
+// them in order, reporting the duration, affected rows and error of each.
+// Run it on a transaction for the script to apply atomically. The returned
+// error wraps the errors of the failed statements.
+func ExecScript(ctx context.Context, db *gorm.DB, script string, config *ScriptConfig) (*ScriptReport, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+	if config == nil {
+		config = &ScriptConfig{}
+	}
+
+	statements, err := SplitScript(script, dialect.For(db).Name())
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ScriptReport{DryRun: config.DryRun, Statements: make([]StatementResult, 0, len(statements))}
+	start := time.Now()
+	defer func() { report.Duration = time.Since(start) }()
+
+	var errs []error
+	for _, statement := range statements {
+		result := StatementResult{ScriptStatement: statement}
+		if config.DryRun {
+			report.Statements = append(report.Statements, result)
+			continue
+		}
+
+		statementStart := time.Now()
+		exec := db.WithContext(ctx).Exec(statement.SQL)
+		result.Duration = time.Since(statementStart)
+		result.RowsAffected = exec.RowsAffected
+		if exec.Error != nil {
+			result.Error = exec.Error.Error()
+			report.Failed++
+			errs = append(errs, fmt.Errorf("statement %d at line %d failed: %w", statement.Index, statement.Line, exec.Error))
+		}
+		report.Statements = append(report.Statements, result)
+
+		if exec.Error != nil && !config.ContinueOnError {
+			break
+		}
+	}
+	return report, errors.Join(errs...)
+}
+
+// dollarTag matches the opening tag of a PostgreSQL dollar-quoted string
+var dollarTag = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+// SplitScript splits script into its statements following the lexical rules
+// of the named dialect (see dialect.Dialect.Name):
+//
+//   - statements end with a semicolon outside of quotes and comments
+//   - postgres and cockroachdb bodies may be dollar-quoted ($$ ... $$)
+//   - mysql scripts may change the terminator with DELIMITER lines, as
+//     stored routines are written for the mysql client
+//   - sqlserver batches may also end with GO lines
+//   - the BEGIN ... END body of sqlite triggers is kept whole
+//
+// Comments before a statement and empty statements are dropped.
+func SplitScript(script, dialectName string) ([]ScriptStatement, error) {
+	s := &scriptSplitter{script: script, dialect: dialectName, delimiter: ";", line: 1}
+	return s.split()
+}
+
+// scriptSplitter scans a script for statement boundaries
+type scriptSplitter struct {
+	script    string
+	dialect   string
+	delimiter string
+	line      int
+
+	statements []ScriptStatement
+	// start and startLine locate the first content of the pending statement,
+	// start is negative while it has none
+	start     int
+	startLine int
+	// words are the leading keywords of the pending statement, telling
+	// triggers apart; depth counts their open BEGIN and CASE blocks
+	words   []string
+	trigger bool
+	depth   int
+}
+
+// split scans the whole script
+func (s *scriptSplitter) split() ([]ScriptStatement, error) {
+	s.start = -1
+	n := len(s.script)
+	for i := 0; i < n; {
+		if i == 0 || s.script[i-1] == '\n' {
+			if next, ok := s.directive(i); ok {
+				i = next
+				continue
+			}
+		}
+
+		c := s.script[i]
+		switch {
+		case c == '\n':
+			s.line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(s.script[i:], "--") || (c == '#' && s.dialect == "mysql"):
+			end := strings.IndexByte(s.script[i:], '\n')
+			if end < 0 {
+				end = n - i
+			}
+			i += end
+		case strings.HasPrefix(s.script[i:], "/*"):
+			end := strings.Index(s.script[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment at line %d", s.line)
+			}
+			if strings.HasPrefix(s.script[i:], "/*!") {
+				// MySQL executable comment
+				s.content(i)
+			}
+			next := i + 2 + end + 2
+			s.line += strings.Count(s.script[i:next], "\n")
+			i = next
+		case strings.HasPrefix(s.script[i:], s.delimiter) && s.depth == 0:
+			s.flush(i)
+			i += len(s.delimiter)
+		case c == '\'' || c == '"' || (c == '`' && s.dialect != "postgres" && s.dialect != "cockroachdb"):
+			next, err := s.quoted(i, c)
+			if err != nil {
+				return nil, err
+			}
+			i = next
+		case c == '[' && (s.dialect == "sqlserver" || s.dialect == "sqlite"):
+			next, err := s.quoted(i, ']')
+			if err != nil {
+				return nil, err
+			}
+			i = next
+		case c == '$' && (s.dialect == "postgres" || s.dialect == "cockroachdb") && !s.inWord(i):
+			tag := dollarTag.FindString(s.script[i:])
+			if tag == "" {
+				s.content(i)
+				i++
+				continue
+			}
+			end := strings.Index(s.script[i+len(tag):], tag)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated dollar-quoted string at line %d", s.line)
+			}
+			s.content(i)
+			next := i + len(tag) + end + len(tag)
+			s.line += strings.Count(s.script[i:next], "\n")
+			i = next
+		case isWordChar(c):
+			end := i
+			for end < n && isWordChar(s.script[end]) {
+				end++
+			}
+			s.content(i)
+			s.word(s.script[i:end])
+			i = end
+		default:
+			s.content(i)
+			i++
+		}
+	}
+
+	if s.depth > 0 {
+		return nil, fmt.Errorf("unterminated trigger body at line %d", s.startLine)
+	}
+	s.flush(n)
+	return s.statements, nil
+}
+
+// directive handles the client directives of the line starting at i,
+// returning the position after it
+func (s *scriptSplitter) directive(i int) (int, bool) {
+	end := strings.IndexByte(s.script[i:], '\n')
+	if end < 0 {
+		end = len(s.script) - i
+	}
+	line := strings.TrimSpace(s.script[i : i+end])
+	fields := strings.Fields(line)
+
+	switch {
+	case s.dialect == "mysql" && len(fields) == 2 && strings.EqualFold(fields[0], "DELIMITER"):
+		s.flush(i)
+		s.delimiter = fields[1]
+	case s.dialect == "sqlserver" && strings.EqualFold(line, "GO"):
+		s.flush(i)
+	default:
+		return i, false
+	}
+	return i + end, true
+}
+
+// quoted skips the string or identifier opened at i and closed by closing,
+// where a doubled closing character (and in MySQL a backslash) escapes it
+func (s *scriptSplitter) quoted(i int, closing byte) (int, error) {
+	s.content(i)
+	line := s.line
+	for j := i + 1; j < len(s.script); j++ {
+		c := s.script[j]
+		switch {
+		case c == '\n':
+			s.line++
+		case c == '\\' && s.dialect == "mysql" && closing != '`':
+			j++
+			if j < len(s.script) && s.script[j] == '\n' {
+				s.line++
+			}
+		case c == closing:
+			if j+1 < len(s.script) && s.script[j+1] == closing {
+				j++
+				continue
+			}
+			return j + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated quoted text at line %d", line)
+}
+
+// content marks the pending statement as started at i
+func (s *scriptSplitter) content(i int) {
+	if s.start < 0 {
+		s.start = i
+		s.startLine = s.line
+	}
+}
+
+// word follows the keywords of the pending statement: SQLite trigger bodies
+// contain statements terminated by semicolons, up to their END
+func (s *scriptSplitter) word(word string) {
+	upper := strings.ToUpper(word)
+	if len(s.words) < 3 {
+		s.words = append(s.words, upper)
+		if s.dialect == "sqlite" && s.words[0] == "CREATE" && upper == "TRIGGER" {
+			s.trigger = true
+		}
+		return
+	}
+	if !s.trigger {
+		return
+	}
+	switch upper {
+	case "BEGIN", "CASE":
+		s.depth++
+	case "END":
+		if s.depth > 0 {
+			s.depth--
+		}
+	}
+}
+
+// inWord reports whether i follows an identifier character, as in a
+// positional parameter ($1) or an identifier containing a dollar sign
+func (s *scriptSplitter) inWord(i int) bool {
+	return i > 0 && (isWordChar(s.script[i-1]) || s.script[i-1] == '$')
+}
+
+// flush ends the pending statement at i
+func (s *scriptSplitter) flush(i int) {
+	if s.start >= 0 {
+		s.statements = append(s.statements, ScriptStatement{
+			Index: len(s.statements) + 1,
+			Line:  s.startLine,
+			SQL:   strings.TrimSpace(s.script[s.start:i]),
+		})
+	}
+	s.start = -1
+	s.words = s.words[:0]
+	s.trigger = false
+	s.depth = 0
+}
+
+// isWordChar reports whether c may be part of an identifier or keyword
+func isWordChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,247 @@
+package models
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// StringArray is a list of strings column, stored as a text[] array on
+// Postgres and CockroachDB and as a JSON array elsewhere. A nil slice is
+// stored as NULL; NULL elements of Postgres arrays are read as "".
+type StringArray []string
+
+// Value encodes the slice as a JSON array
+func (a StringArray) Value() (driver.Value, error) {
+	return arrayValue(a)
+}
+
+// GormValue encodes the slice as an array literal on Postgres and as a JSON
+// array elsewhere
+func (a StringArray) GormValue(ctx context.Context, db *gorm.DB) clause.Expr {
+	if a != nil && isArrayDialect(db) {
+		elements := make([]string, len(a))
+		for i, s := range a {
+			elements[i] = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+		}
+		return clause.Expr{SQL: "?", Vars: []interface{}{"{" + strings.Join(elements, ",") + "}"}}
+	}
+	return arrayGormValue(a)
+}
+
+// Scan decodes a Postgres array literal or a JSON array
+func (a *StringArray) Scan(value interface{}) error {
+	elements, isArray, err := scanArray(value, a)
+	if err != nil || !isArray {
+		return err
+	}
+	if elements == nil {
+		*a = nil
+		return nil
+	}
+	result := make(StringArray, len(elements))
+	for i, element := range elements {
+		if element != nil {
+			result[i] = *element
+		}
+	}
+	*a = result
+	return nil
+}
+
+// GormDataType returns the generic data type of the column
+func (StringArray) GormDataType() string {
+	return "array"
+}
+
+// GormDBDataType returns the column type of the database
+func (StringArray) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return arrayDBDataType(db, "text[]")
+}
+
+// Int64Array is a list of integers column, stored as a bigint[] array on
+// Postgres and CockroachDB and as a JSON array elsewhere. A nil slice is
+// stored as NULL.
+type Int64Array []int64
+
+// Value encodes the slice as a JSON array
+func (a Int64Array) Value() (driver.Value, error) {
+	return arrayValue(a)
+}
+
+// GormValue encodes the slice as an array literal on Postgres and as a JSON
+// array elsewhere
+func (a Int64Array) GormValue(ctx context.Context, db *gorm.DB) clause.Expr {
+	if a != nil && isArrayDialect(db) {
+		elements := make([]string, len(a))
+		for i, n := range a {
+			elements[i] = strconv.FormatInt(n, 10)
+		}
+		return clause.Expr{SQL: "?", Vars: []interface{}{"{" + strings.Join(elements, ",") + "}"}}
+	}
+	return arrayGormValue(a)
+}
+
+// Scan decodes a Postgres array literal or a JSON array
+func (a *Int64Array) Scan(value interface{}) error {
+	elements, isArray, err := scanArray(value, a)
+	if err != nil || !isArray {
+		return err
+	}
+	if elements == nil {
+		*a = nil
+		return nil
+	}
+	result := make(Int64Array, len(elements))
+	for i, element := range elements {
+		if element == nil {
+			return fmt.Errorf("cannot scan NULL element into Int64Array")
+		}
+		n, err := strconv.ParseInt(*element, 10, 64)
+		if err != nil {
+			return fmt.Errorf("cannot scan Int64Array: %w", err)
+		}
+		result[i] = n
+	}
+	*a = result
+	return nil
+}
+
+// GormDataType returns the generic data type of the column
+func (Int64Array) GormDataType() string {
+	return "array"
+}
+
+// GormDBDataType returns the column type of the database
+func (Int64Array) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return arrayDBDataType(db, "bigint[]")
+}
+
+// isArrayDialect reports whether db has native array columns
+func isArrayDialect(db *gorm.DB) bool {
+	if db == nil || db.Dialector == nil {
+		return false
+	}
+	name := db.Dialector.Name()
+	return name == "postgres" || name == "cockroachdb"
+}
+
+// arrayDBDataType returns the native array type on Postgres and a JSON
+// column elsewhere
+func arrayDBDataType(db *gorm.DB, native string) string {
+	if isArrayDialect(db) {
+		return native
+	}
+	if db.Dialector.Name() == "sqlserver" {
+		return "NVARCHAR(MAX)"
+	}
+	return "JSON"
+}
+
+// arrayValue encodes a slice as a JSON array, nil as NULL
+func arrayValue[S ~[]E, E any](a S) (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal([]E(a))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// arrayGormValue binds a slice encoded as a JSON array
+func arrayGormValue[S ~[]E, E any](a S) clause.Expr {
+	value, err := arrayValue(a)
+	if err != nil {
+		return clause.Expr{SQL: "?", Vars: []interface{}{nil}}
+	}
+	return clause.Expr{SQL: "?", Vars: []interface{}{value}}
+}
+
+// scanArray decodes a JSON array into target, or splits a Postgres array
+// literal into its elements, nil standing for NULL elements. isArray is
+// false when the value was decoded as JSON; elements is nil for NULL.
+func scanArray(value interface{}, target interface{}) (elements []*string, isArray bool, err error) {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		return nil, true, nil
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return nil, false, fmt.Errorf("cannot scan %T into %T", value, target)
+	}
+
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "[") {
+		if err := json.Unmarshal([]byte(text), target); err != nil {
+			return nil, false, fmt.Errorf("cannot scan %T: %w", target, err)
+		}
+		return nil, false, nil
+	}
+	elements, err = parseArrayLiteral(text)
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot scan %T: %w", target, err)
+	}
+	return elements, true, nil
+}
+
+// parseArrayLiteral splits a one-dimensional Postgres array literal such as
+// {a,"b c",NULL} into its elements
+func parseArrayLiteral(text string) ([]*string, error) {
+	if !strings.HasPrefix(text, "{") || !strings.HasSuffix(text, "}") {
+		return nil, fmt.Errorf("invalid array literal %q", text)
+	}
+	body := text[1 : len(text)-1]
+	elements := []*string{}
+	if body == "" {
+		return elements, nil
+	}
+
+	for i := 0; i <= len(body); {
+		var element strings.Builder
+		quoted := i < len(body) && body[i] == '"'
+		if quoted {
+			i++
+			for ; i < len(body) && body[i] != '"'; i++ {
+				if body[i] == '\\' && i+1 < len(body) {
+					i++
+				}
+				element.WriteByte(body[i])
+			}
+			if i == len(body) {
+				return nil, fmt.Errorf("unterminated element in array literal %q", text)
+			}
+			i++
+		} else {
+			for ; i < len(body) && body[i] != ','; i++ {
+				if body[i] == '{' || body[i] == '"' {
+					return nil, fmt.Errorf("unsupported array literal %q", text)
+				}
+				element.WriteByte(body[i])
+			}
+		}
+		if i < len(body) && body[i] != ',' {
+			return nil, fmt.Errorf("invalid array literal %q", text)
+		}
+		i++
+
+		s := element.String()
+		if !quoted && strings.EqualFold(s, "NULL") {
+			elements = append(elements, nil)
+		} else {
+			elements = append(elements, &s)
+		}
+	}
+	return elements, nil
+}
@@ -8,7 +8,9 @@ import (
 	"gorm.io/gorm"
 )
 
-// BaseModel provides the foundation for all models with UUIDv7 primary key
+// BaseModel provides the foundation for all models with UUIDv7 primary key.
+// UUIDv7s are ordered by creation time, keeping inserts on the last pages of
+// the primary key index; see utils.UUIDv7FromV4 to convert random v4 keys.
 type BaseModel struct {
 	ID        uuid.UUID  `gorm:"type:uuid;primary_key" json:"id" validate:"required"`
 	CreatedAt time.Time  `gorm:"autoCreateTime;not null" json:"created_at" validate:"required"`
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Change operations, named after the RFC 6902 operations they translate to
+const (
+	ChangeAdd     = "add"
+	ChangeRemove  = "remove"
+	ChangeReplace = "replace"
+)
+
+// FieldChange describes a field whose value differs between two entities.
+// Fields are compared in their JSON form: Path is a JSON Pointer (RFC 6901)
+// built from the json names of the fields, and Old and New hold the values as
+// decoded from JSON (numbers are json.Number, nested structs are maps).
+type FieldChange struct {
+	Op   string      `json:"op"`
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// PatchOperation is an RFC 6902 JSON Patch operation
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// MarshalJSON encodes the operation, with a value unless it is a removal
+func (op PatchOperation) MarshalJSON() ([]byte, error) {
+	if op.Op == ChangeRemove {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{op.Op, op.Path})
+	}
+
+	type operation PatchOperation
+	return json.Marshal(operation(op))
+}
+
+// EntityDiff is the field-level difference between two entities
+type EntityDiff struct {
+	Changes []FieldChange `json:"changes"`
+}
+
+// Diff compares two entities field by field, descending into nested structs,
+// maps and equal-length slices; slices whose length changed are replaced as a
+// whole. A nil old or new entity compares as an entity without fields, and
+// changes are ordered by path.
+func Diff[T any](old, new *T) (*EntityDiff, error) {
+	oldValue, err := jsonValue(old)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode old entity: %w", err)
+	}
+	newValue, err := jsonValue(new)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode new entity: %w", err)
+	}
+
+	diff := &EntityDiff{}
+	diff.compare("", oldValue, newValue)
+	return diff, nil
+}
+
+// Empty reports whether the entities are equal
+func (d *EntityDiff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+// Paths returns the paths of the changed fields
+func (d *EntityDiff) Paths() []string {
+	paths := make([]string, len(d.Changes))
+	for i, change := range d.Changes {
+		paths[i] = change.Path
+	}
+	return paths
+}
+
+// Changed reports whether the field at path, or a field nested in it, changed
+func (d *EntityDiff) Changed(path string) bool {
+	for _, change := range d.Changes {
+		if change.Path == path || strings.HasPrefix(change.Path, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Patch returns the JSON Patch turning the old entity into the new one
+func (d *EntityDiff) Patch() []PatchOperation {
+	patch := make([]PatchOperation, len(d.Changes))
+	for i, change := range d.Changes {
+		patch[i] = PatchOperation{Op: change.Op, Path: change.Path, Value: change.New}
+	}
+	return patch
+}
+
+// JSONPatch returns the JSON Patch document turning the old entity into the new one
+func (d *EntityDiff) JSONPatch() ([]byte, error) {
+	return json.Marshal(d.Patch())
+}
+
+// compare records the changes between the JSON values at path
+func (d *EntityDiff) compare(path string, old, new interface{}) {
+	oldObject, oldIsObject := old.(map[string]interface{})
+	newObject, newIsObject := new.(map[string]interface{})
+	if oldIsObject && newIsObject {
+		keys := make([]string, 0, len(oldObject)+len(newObject))
+		for key := range oldObject {
+			keys = append(keys, key)
+		}
+		for key := range newObject {
+			if _, ok := oldObject[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			child := path + "/" + escapePointer(key)
+			oldChild, inOld := oldObject[key]
+			newChild, inNew := newObject[key]
+			switch {
+			case !inOld:
+				d.Changes = append(d.Changes, FieldChange{Op: ChangeAdd, Path: child, New: newChild})
+			case !inNew:
+				d.Changes = append(d.Changes, FieldChange{Op: ChangeRemove, Path: child, Old: oldChild})
+			default:
+				d.compare(child, oldChild, newChild)
+			}
+		}
+		return
+	}
+
+	oldArray, oldIsArray := old.([]interface{})
+	newArray, newIsArray := new.([]interface{})
+	if oldIsArray && newIsArray && len(oldArray) == len(newArray) {
+		for i := range oldArray {
+			d.compare(path+"/"+strconv.Itoa(i), oldArray[i], newArray[i])
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		d.Changes = append(d.Changes, FieldChange{Op: ChangeReplace, Path: path, Old: old, New: new})
+	}
+}
+
+// jsonValue returns the JSON form of entity, an empty object when it is nil
+func jsonValue[T any](entity *T) (interface{}, error) {
+	if entity == nil {
+		return map[string]interface{}{}, nil
+	}
+
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// escapePointer escapes a JSON Pointer reference token (RFC 6901)
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// EnumType is implemented by the string types of enum columns, listing the
+// values they accept:
+//
+//	type Status string
+//
+//	func (Status) Values() []Status { return []Status{"active", "suspended"} }
+type EnumType[T any] interface {
+	~string
+	Values() []T
+}
+
+// Enum is an enum column of type T, stored as a string. Values outside
+// T.Values() fail to be stored, scanned or decoded from JSON, so invalid
+// values neither reach nor leave the database:
+//
+//	type User struct {
+//		models.BaseModel
+//		Status models.Enum[Status]
+//	}
+//
+// The zero Enum has no value and is stored as NULL.
+type Enum[T EnumType[T]] struct {
+	value T
+}
+
+// NewEnum returns the enum holding value, or an error if value is not one
+// of the values of T
+func NewEnum[T EnumType[T]](value T) (Enum[T], error) {
+	var e Enum[T]
+	return e, e.Set(value)
+}
+
+// Get returns the value of the enum, empty when it has none
+func (e Enum[T]) Get() T {
+	return e.value
+}
+
+// Set changes the value of the enum, failing if value is not one of the
+// values of T. Setting the empty value clears the enum.
+func (e *Enum[T]) Set(value T) error {
+	if value != "" && !slices.Contains(value.Values(), value) {
+		var zero T
+		return fmt.Errorf("invalid %T value %q: must be one of %v", zero, string(value), value.Values())
+	}
+	e.value = value
+	return nil
+}
+
+// IsZero reports whether the enum has no value
+func (e Enum[T]) IsZero() bool {
+	return e.value == ""
+}
+
+// String returns the value of the enum
+func (e Enum[T]) String() string {
+	return string(e.value)
+}
+
+// Value stores the enum as its string value, NULL when it has none
+func (e Enum[T]) Value() (driver.Value, error) {
+	if e.value == "" {
+		return nil, nil
+	}
+	if err := new(Enum[T]).Set(e.value); err != nil {
+		return nil, err
+	}
+	return string(e.value), nil
+}
+
+// Scan reads an enum value from the database, rejecting unknown values
+func (e *Enum[T]) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		e.value = ""
+		return nil
+	case []byte:
+		return e.Set(T(v))
+	case string:
+		return e.Set(T(v))
+	default:
+		return fmt.Errorf("cannot scan %T into %T", value, e)
+	}
+}
+
+// MarshalJSON encodes the enum as its string value, null when it has none
+func (e Enum[T]) MarshalJSON() ([]byte, error) {
+	if e.value == "" {
+		return []byte("null"), nil
+	}
+	return json.Marshal(string(e.value))
+}
+
+// UnmarshalJSON decodes a string value, rejecting unknown values
+func (e *Enum[T]) UnmarshalJSON(data []byte) error {
+	var value *string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	if value == nil {
+		e.value = ""
+		return nil
+	}
+	return e.Set(T(*value))
+}
+
+// GormDataType returns the generic data type of the column
+func (Enum[T]) GormDataType() string {
+	return "string"
+}
+
+// GormDBDataType sizes the column to the longest value on MySQL, whose
+// unsized strings cannot be indexed
+func (Enum[T]) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	if db.Dialector.Name() != "mysql" || field.Size > 0 {
+		return ""
+	}
+	var zero T
+	size := 1
+	for _, value := range zero.Values() {
+		size = max(size, len(value))
+	}
+	return fmt.Sprintf("VARCHAR(%d)", size)
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// JSONMap is a JSON object column, stored as JSONB on Postgres and JSON on
+// MySQL and SQLite. Query it with repository.JSONContains,
+// repository.JSONExtractEq and repository.JSONKeyExists:
+//
+//	type Product struct {
+//		models.BaseModel
+//		Attributes models.JSONMap
+//	}
+//
+// A nil map is stored as NULL.
+type JSONMap map[string]interface{}
+
+// Value encodes the map as JSON
+func (m JSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(map[string]interface{}(m))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan decodes a JSON object read from the database
+func (m *JSONMap) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into JSONMap", value)
+	}
+
+	decoded := make(map[string]interface{})
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return fmt.Errorf("cannot scan JSONMap: %w", err)
+	}
+	*m = decoded
+	return nil
+}
+
+// GormDataType returns the generic data type of the column
+func (JSONMap) GormDataType() string {
+	return "json"
+}
+
+// GormDBDataType returns the column type of the database
+func (JSONMap) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return "JSONB"
+	case "sqlserver":
+		return "NVARCHAR(MAX)"
+	}
+	return "JSON"
+}
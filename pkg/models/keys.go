@@ -0,0 +1,262 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// EntityID is the primary key of an entity: a uuid.UUID for BaseModel, an
+// int64 for BaseModelInt64 or a ULID for BaseModelULID. Repository methods
+// taking an ID accept the key type of their entity, integer constants for
+// integer keys and the text form of UUIDs and ULIDs.
+type EntityID = interface{}
+
+// TrackingFields holds the creation, update and deletion times and actors
+// of BaseModelInt64 and BaseModelULID, the fields of BaseModel besides its
+// key
+type TrackingFields struct {
+	CreatedAt time.Time  `gorm:"autoCreateTime;not null" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime;not null" json:"updated_at"`
+	DeletedAt *time.Time `gorm:"index" json:"deleted_at,omitempty"`
+	CreatedBy *uuid.UUID `gorm:"type:uuid;index" json:"created_by,omitempty"`
+	UpdatedBy *uuid.UUID `gorm:"type:uuid;index" json:"updated_by,omitempty"`
+	DeletedBy *uuid.UUID `gorm:"type:uuid;index" json:"deleted_by,omitempty"`
+}
+
+// BeforeCreate is called before creating a new record
+func (m *TrackingFields) BeforeCreate(tx *gorm.DB) error {
+	if userID := getUserIDFromContext(tx); userID != uuid.Nil {
+		m.CreatedBy = &userID
+	}
+	return nil
+}
+
+// BeforeUpdate is called before updating a record
+func (m *TrackingFields) BeforeUpdate(tx *gorm.DB) error {
+	m.UpdatedAt = time.Now()
+	if userID := getUserIDFromContext(tx); userID != uuid.Nil {
+		m.UpdatedBy = &userID
+	}
+	return nil
+}
+
+// BeforeDelete is called before deleting a record
+func (m *TrackingFields) BeforeDelete(tx *gorm.DB) error {
+	now := time.Now()
+	m.DeletedAt = &now
+	return nil
+}
+
+// IsDeleted checks if the model is soft deleted
+func (m *TrackingFields) IsDeleted() bool {
+	return m.DeletedAt != nil
+}
+
+// Restore restores a soft deleted model
+func (m *TrackingFields) Restore() {
+	m.DeletedAt = nil
+}
+
+// GetCreatedAt returns the creation timestamp
+func (m *TrackingFields) GetCreatedAt() time.Time {
+	return m.CreatedAt
+}
+
+// GetUpdatedAt returns the last update timestamp
+func (m *TrackingFields) GetUpdatedAt() time.Time {
+	return m.UpdatedAt
+}
+
+// GetDeletedAt returns the deletion timestamp
+func (m *TrackingFields) GetDeletedAt() *time.Time {
+	return m.DeletedAt
+}
+
+// BaseModelInt64 is BaseModel keyed by an auto-incremented integer, for
+// tables shared with systems expecting integer keys
+type BaseModelInt64 struct {
+	ID int64 `gorm:"primaryKey;autoIncrement" json:"id"`
+	TrackingFields
+}
+
+// GetID returns the model's ID
+func (m *BaseModelInt64) GetID() int64 {
+	return m.ID
+}
+
+// SetID sets the model's ID
+func (m *BaseModelInt64) SetID(id int64) {
+	m.ID = id
+}
+
+// BaseModelULID is BaseModel keyed by a ULID, ordered by creation time like
+// UUIDv7s and stored as 26 characters of text
+type BaseModelULID struct {
+	ID ULID `gorm:"primaryKey" json:"id"`
+	TrackingFields
+}
+
+// BeforeCreate assigns a new ULID to the model unless it has one
+func (m *BaseModelULID) BeforeCreate(tx *gorm.DB) error {
+	if m.ID.IsZero() {
+		m.ID = NewULID()
+	}
+	return m.TrackingFields.BeforeCreate(tx)
+}
+
+// GetID returns the model's ID
+func (m *BaseModelULID) GetID() ULID {
+	return m.ID
+}
+
+// SetID sets the model's ID
+func (m *BaseModelULID) SetID(id ULID) {
+	m.ID = id
+}
+
+// ulidAlphabet is the Crockford base32 alphabet of ULIDs
+const ulidAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID is a Universally Unique Lexicographically Sortable Identifier: a
+// millisecond timestamp followed by 80 random bits, written as 26
+// characters of Crockford base32. ULIDs created in the same millisecond are
+// in random order.
+type ULID [16]byte
+
+// NewULID returns a new ULID of the current time
+func NewULID() ULID {
+	var id ULID
+	ms := uint64(time.Now().UnixMilli())
+	binary.BigEndian.PutUint16(id[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(ms))
+	if _, err := rand.Read(id[6:]); err != nil {
+		panic(fmt.Sprintf("models: cannot generate ULID: %v", err))
+	}
+	return id
+}
+
+// ParseULID parses the 26 character text form of a ULID, in either case
+func ParseULID(s string) (ULID, error) {
+	var id ULID
+	if len(s) != 26 {
+		return id, fmt.Errorf("invalid ULID %q: must be 26 characters", s)
+	}
+	if s[0] > '7' {
+		return id, fmt.Errorf("invalid ULID %q: overflows 128 bits", s)
+	}
+	// The 26 characters hold 130 bits, the first two always zero
+	for i := 0; i < len(s); i++ {
+		value := strings.IndexByte(ulidAlphabet, upper(s[i]))
+		if value < 0 {
+			return ULID{}, fmt.Errorf("invalid ULID %q: invalid character %q", s, s[i])
+		}
+		for bit := 0; bit < 5; bit++ {
+			position := i*5 + bit - 2
+			if position >= 0 && value&(1<<(4-bit)) != 0 {
+				id[position/8] |= 1 << (7 - position%8)
+			}
+		}
+	}
+	return id, nil
+}
+
+// upper returns the upper case of an ASCII letter
+func upper(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+// String returns the 26 character text form of the ULID
+func (id ULID) String() string {
+	var s [26]byte
+	for i := range s {
+		value := 0
+		for bit := 0; bit < 5; bit++ {
+			position := i*5 + bit - 2
+			value <<= 1
+			if position >= 0 && id[position/8]&(1<<(7-position%8)) != 0 {
+				value |= 1
+			}
+		}
+		s[i] = ulidAlphabet[value]
+	}
+	return string(s[:])
+}
+
+// Time returns the creation time of the ULID, to the millisecond
+func (id ULID) Time() time.Time {
+	ms := uint64(binary.BigEndian.Uint16(id[0:2]))<<32 | uint64(binary.BigEndian.Uint32(id[2:6]))
+	return time.UnixMilli(int64(ms))
+}
+
+// IsZero reports whether the ULID is unset
+func (id ULID) IsZero() bool {
+	return id == ULID{}
+}
+
+// Value stores the ULID as text, NULL when unset
+func (id ULID) Value() (driver.Value, error) {
+	if id.IsZero() {
+		return nil, nil
+	}
+	return id.String(), nil
+}
+
+// Scan reads a ULID stored as text or as 16 bytes
+func (id *ULID) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*id = ULID{}
+		return nil
+	case string:
+		parsed, err := ParseULID(v)
+		if err != nil {
+			return err
+		}
+		*id = parsed
+		return nil
+	case []byte:
+		if len(v) == len(id) {
+			copy(id[:], v)
+			return nil
+		}
+		return id.Scan(string(v))
+	default:
+		return fmt.Errorf("cannot scan %T into ULID", value)
+	}
+}
+
+// MarshalText encodes the ULID as its text form
+func (id ULID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText decodes the text form of a ULID
+func (id *ULID) UnmarshalText(data []byte) error {
+	parsed, err := ParseULID(string(data))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// GormDataType returns the generic data type of the column
+func (ULID) GormDataType() string {
+	return "string"
+}
+
+// GormDBDataType returns the column type of the database
+func (ULID) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return "CHAR(26)"
+}
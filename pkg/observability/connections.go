@@ -0,0 +1,90 @@
+package observability
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+)
+
+// ConnectionStatsSource reports the statistics of a connection pool, such
+// as *sql.DB
+type ConnectionStatsSource interface {
+	Stats() sql.DBStats
+}
+
+// RecordConnectionStats records a connection pool sample in the
+// orm_connections_* metrics family, one series per pool
+func (om *ORMMetrics) RecordConnectionStats(ctx context.Context, pool string, stats sql.DBStats) {
+	labels := map[string]string{"pool": pool}
+
+	om.setSeries("orm_connections_max", MetricTypeGauge, float64(stats.MaxOpenConnections), labels, "Maximum number of connections", "connections")
+	om.setSeries("orm_connections_open", MetricTypeGauge, float64(stats.OpenConnections), labels, "Number of established connections", "connections")
+	om.setSeries("orm_connections_active", MetricTypeGauge, float64(stats.InUse), labels, "Number of active connections", "connections")
+	om.setSeries("orm_connections_idle", MetricTypeGauge, float64(stats.Idle), labels, "Number of idle connections", "connections")
+	om.setSeries("orm_connections_wait_total", MetricTypeCounter, float64(stats.WaitCount), labels, "Total number of connections waited for", "waits")
+	om.setSeries("orm_connections_wait_seconds_total", MetricTypeCounter, stats.WaitDuration.Seconds(), labels, "Total time spent waiting for connections", "seconds")
+
+	// Connection utilization percentage
+	utilization := 0.0
+	if stats.MaxOpenConnections > 0 {
+		utilization = float64(stats.InUse) / float64(stats.MaxOpenConnections) * 100
+	}
+	om.setSeries("orm_connections_utilization_percent", MetricTypeGauge, utilization, labels, "Connection pool utilization percentage", "percent")
+}
+
+// CollectConnectionStats samples the statistics of source under name every
+// ConnectionStatsInterval while the manager is started, recording them with
+// RecordConnectionStats. Registering a name again replaces its source.
+func (om *ObservabilityManager) CollectConnectionStats(name string, source ConnectionStatsSource) {
+	om.mutex.Lock()
+	defer om.mutex.Unlock()
+
+	if om.connections == nil {
+		om.connections = make(map[string]ConnectionStatsSource)
+	}
+	om.connections[name] = source
+}
+
+// RecordConnectionStats records a connection pool sample and logs it
+func (om *ObservabilityManager) RecordConnectionStats(ctx context.Context, pool string, stats sql.DBStats) {
+	// Record metrics
+	if om.config.MetricsEnabled {
+		om.metrics.RecordConnectionStats(ctx, pool, stats)
+	}
+
+	om.logger.Info(ctx, "Connection pool statistics",
+		logging.String("pool", pool),
+		logging.Int("max_open", stats.MaxOpenConnections),
+		logging.Int("open", stats.OpenConnections),
+		logging.Int("in_use", stats.InUse),
+		logging.Int("idle", stats.Idle),
+		logging.Int64("wait_count", stats.WaitCount),
+		logging.Duration("wait_duration", stats.WaitDuration))
+}
+
+// connectionStatsRoutine samples the registered pools every interval until
+// the manager is stopped
+func (om *ObservabilityManager) connectionStatsRoutine(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-om.ctx.Done():
+			return
+		case <-ticker.C:
+			om.mutex.RLock()
+			sources := make(map[string]ConnectionStatsSource, len(om.connections))
+			for name, source := range om.connections {
+				sources[name] = source
+			}
+			om.mutex.RUnlock()
+
+			for name, source := range sources {
+				om.RecordConnectionStats(om.ctx, name, source.Stats())
+			}
+		}
+	}
+}
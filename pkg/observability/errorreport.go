@@ -0,0 +1,286 @@
+package observability
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	stderrors "errors"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/logging"
+)
+
+// ErrorGroup aggregates the occurrences of the errors sharing a fingerprint
+type ErrorGroup struct {
+	Fingerprint string           `json:"fingerprint"`
+	Type        errors.ErrorType `json:"type"`
+	Table       string           `json:"table,omitempty"`
+	Operation   string           `json:"operation,omitempty"`
+	// Message is the error message with its values replaced by "?"
+	Message string `json:"message"`
+	// Example is the message of the last occurrence
+	Example string `json:"example"`
+	// Count is the number of occurrences since the group was first seen
+	Count int64 `json:"count"`
+	// Recent is the number of occurrences since the last summary
+	Recent    int64     `json:"recent"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// ErrorReporterConfig configures an ErrorReporter
+type ErrorReporterConfig struct {
+	// Interval is how often the summary is emitted (1m when not set)
+	Interval time.Duration `json:"interval"`
+	// MaxGroups bounds the groups followed; the errors of new fingerprints
+	// beyond it are counted as dropped (1000 when not set)
+	MaxGroups int `json:"max_groups"`
+	// Retention is how long a group without occurrences is kept (24h when
+	// not set)
+	Retention time.Duration `json:"retention"`
+}
+
+// ErrorReporter groups errors by fingerprint, their type, table, operation
+// and message without its values, and emits a summary of the groups every
+// interval instead of one log line per occurrence:
+//
+//	reporter := observability.NewErrorReporter(logger, manager, nil)
+//	defer reporter.Close()
+//	if err := repo.Create(ctx, user); err != nil {
+//		reporter.Report(ctx, err)
+//	}
+//
+// The summary logs an "Error summary" warning per group seen since the last
+// one, with its counts and first and last occurrences, and records the
+// total of every group in orm_error_groups_total when manager is not nil.
+type ErrorReporter struct {
+	logger  logging.Logger
+	manager *ObservabilityManager
+	config  ErrorReporterConfig
+
+	mu      sync.Mutex
+	groups  map[string]*ErrorGroup
+	dropped int64
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewErrorReporter creates an error reporter emitting its summaries to
+// logger and manager, configured with the defaults when config is nil
+func NewErrorReporter(logger logging.Logger, manager *ObservabilityManager, config *ErrorReporterConfig) *ErrorReporter {
+	r := &ErrorReporter{
+		logger:  logger,
+		manager: manager,
+		groups:  make(map[string]*ErrorGroup),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if config != nil {
+		r.config = *config
+	}
+	if r.config.Interval <= 0 {
+		r.config.Interval = time.Minute
+	}
+	if r.config.MaxGroups <= 0 {
+		r.config.MaxGroups = 1000
+	}
+	if r.config.Retention <= 0 {
+		r.config.Retention = 24 * time.Hour
+	}
+	go r.run()
+	return r
+}
+
+// Report counts an occurrence of err in its group and returns the
+// fingerprint of the group; nil errors are ignored
+func (r *ErrorReporter) Report(ctx context.Context, err error) string {
+	if err == nil {
+		return ""
+	}
+	group := newErrorGroup(err)
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.groups[group.Fingerprint]
+	if !ok {
+		if len(r.groups) >= r.config.MaxGroups {
+			r.dropped++
+			return group.Fingerprint
+		}
+		group.FirstSeen = now
+		existing = &group
+		r.groups[group.Fingerprint] = existing
+	}
+	existing.Count++
+	existing.Recent++
+	existing.LastSeen = now
+	existing.Example = err.Error()
+	return group.Fingerprint
+}
+
+// Groups returns the groups followed, the most frequent first
+func (r *ErrorReporter) Groups() []ErrorGroup {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	groups := make([]ErrorGroup, 0, len(r.groups))
+	for _, group := range r.groups {
+		groups = append(groups, *group)
+	}
+	sortErrorGroups(groups)
+	return groups
+}
+
+// Dropped returns the number of errors not grouped because MaxGroups groups
+// were followed already
+func (r *ErrorReporter) Dropped() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
+
+// Flush emits the summary of the groups seen since the last one now, and
+// forgets the groups idle for longer than the retention
+func (r *ErrorReporter) Flush(ctx context.Context) {
+	now := time.Now()
+
+	r.mu.Lock()
+	var recent, all []ErrorGroup
+	for fingerprint, group := range r.groups {
+		if group.Recent > 0 {
+			recent = append(recent, *group)
+			group.Recent = 0
+		} else if now.Sub(group.LastSeen) > r.config.Retention {
+			delete(r.groups, fingerprint)
+			continue
+		}
+		all = append(all, *group)
+	}
+	dropped := r.dropped
+	r.dropped = 0
+	r.mu.Unlock()
+
+	sortErrorGroups(recent)
+	for _, group := range recent {
+		r.logger.Warn(ctx, "Error summary",
+			logging.String("fingerprint", group.Fingerprint),
+			logging.String("type", string(group.Type)),
+			logging.String("table", group.Table),
+			logging.String("operation", group.Operation),
+			logging.String("message", group.Message),
+			logging.String("example", group.Example),
+			logging.Int64("count", group.Recent),
+			logging.Int64("total", group.Count),
+			logging.Time("first_seen", group.FirstSeen),
+			logging.Time("last_seen", group.LastSeen))
+	}
+	if dropped > 0 {
+		r.logger.Warn(ctx, "Error groups limit reached",
+			logging.Int("max_groups", r.config.MaxGroups),
+			logging.Int64("dropped", dropped))
+	}
+
+	if r.manager != nil {
+		for _, group := range all {
+			r.manager.RecordErrorGroup(ctx, group)
+		}
+	}
+}
+
+// Close stops the summaries and emits the last one
+func (r *ErrorReporter) Close() {
+	r.closeOnce.Do(func() {
+		close(r.stop)
+		<-r.done
+		r.Flush(context.Background())
+	})
+}
+
+// run emits the summary every interval until closed
+func (r *ErrorReporter) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.Flush(context.Background())
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// errorValues matches the values of error messages: quoted strings, UUIDs,
+// hexadecimal and decimal numbers
+var errorValues = regexp.MustCompile(`'[^']*'|"[^"]*"|\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b|\b0x[0-9a-fA-F]+\b|\b\d+(?:\.\d+)?\b`)
+
+// NormalizeErrorMessage returns message with its values replaced by "?", so
+// the messages of errors differing by their values only are equal
+func NormalizeErrorMessage(message string) string {
+	return errorValues.ReplaceAllString(message, "?")
+}
+
+// ErrorFingerprint returns the fingerprint of err: a hash of its type,
+// table, operation and normalized message, taken from the ORMError in its
+// chain when there is one
+func ErrorFingerprint(err error) string {
+	return newErrorGroup(err).Fingerprint
+}
+
+// newErrorGroup returns the empty group of err
+func newErrorGroup(err error) ErrorGroup {
+	group := ErrorGroup{Type: errors.ErrorTypeUnknown}
+	var ormErr *errors.ORMError
+	if stderrors.As(err, &ormErr) {
+		if ormErr.Type != "" {
+			group.Type = ormErr.Type
+		}
+		group.Table = ormErr.Table
+		group.Operation = ormErr.Operation
+	}
+	group.Message = NormalizeErrorMessage(err.Error())
+
+	sum := sha1.Sum([]byte(string(group.Type) + "|" + group.Table + "|" + group.Operation + "|" + group.Message))
+	group.Fingerprint = hex.EncodeToString(sum[:8])
+	return group
+}
+
+// sortErrorGroups sorts groups by decreasing count, then fingerprint
+func sortErrorGroups(groups []ErrorGroup) {
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].Fingerprint < groups[j].Fingerprint
+	})
+}
+
+// RecordErrorGroup records the total occurrences of an error group in
+// orm_error_groups_total, one series per fingerprint
+func (om *ORMMetrics) RecordErrorGroup(ctx context.Context, group ErrorGroup) {
+	om.setSeries("orm_error_groups_total", MetricTypeCounter, float64(group.Count), map[string]string{
+		"fingerprint": group.Fingerprint,
+		"type":        string(group.Type),
+		"table":       group.Table,
+		"operation":   group.Operation,
+	}, "Occurrences of the errors grouped by fingerprint", "errors")
+}
+
+// RecordErrorGroup records the occurrences of an error group
+func (om *ObservabilityManager) RecordErrorGroup(ctx context.Context, group ErrorGroup) {
+	// Record metrics
+	if om.config.MetricsEnabled {
+		om.metrics.RecordErrorGroup(ctx, group)
+	}
+}
@@ -0,0 +1,268 @@
+//go:build !ormx_minimal
+
+package observability
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+)
+
+// Backend exporters are excluded from builds tagged ormx_minimal; the
+// in-process JSONExporter stays available there.
+
+// prometheusContentType is the content type of the Prometheus text exposition format
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// PrometheusExporter keeps the metrics last exported by the ObservabilityManager
+// and serves them in the Prometheus text exposition format
+type PrometheusExporter struct {
+	logger  logging.Logger
+	mutex   sync.RWMutex
+	metrics []Metric
+}
+
+// NewPrometheusExporter creates a new Prometheus exporter
+func NewPrometheusExporter(logger logging.Logger) *PrometheusExporter {
+	return &PrometheusExporter{
+		logger: logger,
+	}
+}
+
+// Export replaces the metrics served by the exporter
+func (pe *PrometheusExporter) Export(ctx context.Context, metrics []Metric) error {
+	snapshot := make([]Metric, len(metrics))
+	copy(snapshot, metrics)
+
+	pe.mutex.Lock()
+	pe.metrics = snapshot
+	pe.mutex.Unlock()
+	return nil
+}
+
+// ExportSummary does nothing, the summary is derived from the exported metrics
+func (pe *PrometheusExporter) ExportSummary(ctx context.Context, summary map[string]interface{}) error {
+	return nil
+}
+
+// WriteTo writes the last exported metrics in Prometheus text format
+func (pe *PrometheusExporter) WriteTo(w io.Writer) (int64, error) {
+	pe.mutex.RLock()
+	metrics := pe.metrics
+	pe.mutex.RUnlock()
+
+	return WritePrometheus(w, metrics)
+}
+
+// Handler returns an HTTP handler serving the last exported metrics
+func (pe *PrometheusExporter) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", prometheusContentType)
+		if _, err := pe.WriteTo(w); err != nil && pe.logger != nil {
+			pe.logger.Error(r.Context(), "Failed to write Prometheus metrics", logging.ErrorField("error", err))
+		}
+	})
+}
+
+// PrometheusHandler returns an HTTP handler collecting the metrics of collector
+// (e.g. ObservabilityManager.GetMetrics()) on every scrape and serving them in
+// Prometheus text format
+func PrometheusHandler(collector MetricCollector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metrics, err := collector.Collect(r.Context())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to collect metrics: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", prometheusContentType)
+		_, _ = WritePrometheus(w, metrics)
+	})
+}
+
+// WritePrometheus writes metrics in the Prometheus text exposition format,
+// grouped and sorted by name. Counters are exposed as counters; gauges and the
+// single observations recorded for histograms and summaries as gauges.
+func WritePrometheus(w io.Writer, metrics []Metric) (int64, error) {
+	sorted := make([]Metric, len(metrics))
+	copy(sorted, metrics)
+	for i := range sorted {
+		sorted[i].Name = sanitizeMetricName(sorted[i].Name)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return formatLabels(sorted[i].Labels) < formatLabels(sorted[j].Labels)
+	})
+
+	var b strings.Builder
+	for i, metric := range sorted {
+		if i == 0 || sorted[i-1].Name != metric.Name {
+			if metric.Description != "" {
+				fmt.Fprintf(&b, "# HELP %s %s\n", metric.Name, escapeHelp(metric.Description))
+			}
+			fmt.Fprintf(&b, "# TYPE %s %s\n", metric.Name, prometheusType(metric.Type))
+		}
+		fmt.Fprintf(&b, "%s%s %s\n", metric.Name, formatLabels(metric.Labels), formatValue(metric.Value))
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// prometheusType maps a metric type to its Prometheus type
+func prometheusType(metricType MetricType) string {
+	if metricType == MetricTypeCounter {
+		return "counter"
+	}
+	return "gauge"
+}
+
+// formatLabels renders labels as {name="value",...} sorted by name
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf(`%s="%s"`, sanitizeLabelName(name), escapeLabelValue(labels[name]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// formatValue renders a sample value, including the special values +Inf, -Inf and NaN
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// escapeHelp escapes backslashes and line feeds in HELP text
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+// escapeLabelValue escapes backslashes, double quotes and line feeds in label values
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// sanitizeMetricName replaces characters not allowed in metric names with underscores
+func sanitizeMetricName(name string) string {
+	return sanitizeName(name, true)
+}
+
+// sanitizeLabelName replaces characters not allowed in label names with underscores
+func sanitizeLabelName(name string) string {
+	return sanitizeName(name, false)
+}
+
+// sanitizeName keeps [a-zA-Z_] (and ':' in metric names) plus digits after the first character
+func sanitizeName(name string, allowColon bool) string {
+	if name == "" {
+		return "_"
+	}
+	var b strings.Builder
+	for i, r := range name {
+		valid := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') ||
+			(allowColon && r == ':') || (i > 0 && r >= '0' && r <= '9')
+		if valid {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// JaegerExporter exports traces to Jaeger
+type JaegerExporter struct {
+	logger logging.Logger
+}
+
+// NewJaegerExporter creates a new Jaeger exporter
+func NewJaegerExporter(logger logging.Logger) *JaegerExporter {
+	return &JaegerExporter{
+		logger: logger,
+	}
+}
+
+// Export exports multiple spans to Jaeger
+func (je *JaegerExporter) Export(ctx context.Context, spans []*Span) error {
+	for _, span := range spans {
+		if err := je.ExportSpan(ctx, span); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportSpan exports a single span to Jaeger
+func (je *JaegerExporter) ExportSpan(ctx context.Context, span *Span) error {
+	// This is a simplified implementation
+	// In a real implementation, you would send the span to Jaeger
+	je.logger.Info(ctx, "Jaeger span export",
+		logging.String("trace_id", string(span.TraceID)),
+		logging.String("span_id", string(span.SpanID)),
+		logging.String("name", span.Name),
+		logging.String("status", string(span.Status)),
+		logging.Duration("duration", span.Duration))
+	return nil
+}
+
+// ZipkinExporter exports traces to Zipkin
+type ZipkinExporter struct {
+	logger logging.Logger
+}
+
+// NewZipkinExporter creates a new Zipkin exporter
+func NewZipkinExporter(logger logging.Logger) *ZipkinExporter {
+	return &ZipkinExporter{
+		logger: logger,
+	}
+}
+
+// Export exports multiple spans to Zipkin
+func (ze *ZipkinExporter) Export(ctx context.Context, spans []*Span) error {
+	for _, span := range spans {
+		if err := ze.ExportSpan(ctx, span); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportSpan exports a single span to Zipkin
+func (ze *ZipkinExporter) ExportSpan(ctx context.Context, span *Span) error {
+	// This is a simplified implementation
+	// In a real implementation, you would send the span to Zipkin
+	ze.logger.Info(ctx, "Zipkin span export",
+		logging.String("trace_id", string(span.TraceID)),
+		logging.String("span_id", string(span.SpanID)),
+		logging.String("name", span.Name),
+		logging.String("status", string(span.Status)),
+		logging.Duration("duration", span.Duration))
+	return nil
+}
@@ -419,39 +419,6 @@ type MetricsExporter interface {
 	ExportSummary(ctx context.Context, summary map[string]interface{}) error
 }
 
-// PrometheusExporter exports metrics in Prometheus format
-type PrometheusExporter struct {
-	logger logging.Logger
-}
-
-// NewPrometheusExporter creates a new Prometheus exporter
-func NewPrometheusExporter(logger logging.Logger) *PrometheusExporter {
-	return &PrometheusExporter{
-		logger: logger,
-	}
-}
-
-// Export exports metrics in Prometheus format
-func (pe *PrometheusExporter) Export(ctx context.Context, metrics []Metric) error {
-	// This is a simplified implementation
-	// In a real implementation, you would format metrics according to Prometheus specification
-	for _, metric := range metrics {
-		pe.logger.Info(ctx, "Prometheus metric",
-			logging.String("name", metric.Name),
-			logging.String("type", string(metric.Type)),
-			logging.Float64("value", metric.Value),
-			logging.Any("labels", metric.Labels))
-	}
-
-	return nil
-}
-
-// ExportSummary exports metrics summary
-func (pe *PrometheusExporter) ExportSummary(ctx context.Context, summary map[string]interface{}) error {
-	pe.logger.Info(ctx, "Prometheus metrics summary", logging.Any("summary", summary))
-	return nil
-}
-
 // JSONExporter exports metrics in JSON format
 type JSONExporter struct {
 	logger logging.Logger
@@ -17,6 +17,9 @@ type ObservabilityConfig struct {
 	ExportInterval    time.Duration
 	MetricsExporters  []MetricsExporter
 	TraceExporters    []TraceExporter
+	// ConnectionStatsInterval is how often the connection pools registered
+	// with CollectConnectionStats are sampled; zero disables sampling
+	ConnectionStatsInterval time.Duration
 }
 
 // DefaultObservabilityConfig returns default observability configuration
@@ -28,6 +31,8 @@ func DefaultObservabilityConfig() ObservabilityConfig {
 		ExportInterval:    time.Minute * 5,
 		MetricsExporters:  []MetricsExporter{},
 		TraceExporters:    []TraceExporter{},
+
+		ConnectionStatsInterval: 30 * time.Second,
 	}
 }
 
@@ -41,6 +46,8 @@ type ObservabilityManager struct {
 	ctx     context.Context
 	cancel  context.CancelFunc
 	started bool
+	// connections are the pools sampled by the connection stats routine, by name
+	connections map[string]ConnectionStatsSource
 }
 
 // NewObservabilityManager creates a new observability manager
@@ -93,6 +100,11 @@ func (om *ObservabilityManager) Start(ctx context.Context) error {
 		go om.exportRoutine()
 	}
 
+	// Start connection stats sampling
+	if om.config.ConnectionStatsInterval > 0 {
+		go om.connectionStatsRoutine(om.config.ConnectionStatsInterval)
+	}
+
 	om.started = true
 	om.logger.Info(ctx, "Observability manager started successfully")
 	return nil
@@ -425,9 +437,16 @@ func (om *ObservabilityManager) exportAll(ctx context.Context) error {
 
 	// Export traces
 	if om.config.TracingEnabled && len(om.config.TraceExporters) > 0 {
-		// Note: In a real implementation, you would collect spans from the tracer
-		// For now, we'll just log that tracing is enabled
-		om.logger.Debug(ctx, "Tracing enabled, spans would be exported")
+		if spans := om.tracer.FinishedSpans(); len(spans) > 0 {
+			for _, exporter := range om.config.TraceExporters {
+				if exporter == nil {
+					continue
+				}
+				if err := exporter.Export(ctx, spans); err != nil {
+					om.logger.Error(ctx, "Failed to export spans", logging.ErrorField("error", err))
+				}
+			}
+		}
 	}
 
 	return nil
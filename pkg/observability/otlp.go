@@ -0,0 +1,293 @@
+//go:build !ormx_minimal
+
+package observability
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+)
+
+// otlpScopeName is the instrumentation scope reported with exported spans
+const otlpScopeName = "github.com/seasbee/go-ormx"
+
+// OTLPConfig configures the OTLP trace exporter
+type OTLPConfig struct {
+	// Endpoint is the OTLP/HTTP traces URL of a collector or backend
+	Endpoint string `json:"endpoint"`
+	// Headers are added to every export request, e.g. for authentication
+	Headers map[string]string `json:"headers"`
+	// ServiceName is reported as the service.name resource attribute
+	ServiceName string `json:"service_name"`
+	// ResourceAttributes are additional resource attributes
+	ResourceAttributes map[string]string `json:"resource_attributes"`
+	// Timeout bounds each export request
+	Timeout time.Duration `json:"timeout"`
+}
+
+// DefaultOTLPConfig returns the default OTLP configuration, targeting a local collector
+func DefaultOTLPConfig() *OTLPConfig {
+	return &OTLPConfig{
+		Endpoint:    "http://localhost:4318/v1/traces",
+		ServiceName: "go-ormx",
+		Timeout:     10 * time.Second,
+	}
+}
+
+// OTLPExporter exports spans over OTLP/HTTP with JSON encoding, so the spans
+// of the ORM tracer (StartQuerySpan, StartTransactionSpan, ...) join existing
+// OpenTelemetry pipelines through a collector or any OTLP-capable backend.
+// Trace context crosses process boundaries through the W3C traceparent header
+// handled by InjectTraceContext and ExtractTraceContext.
+type OTLPExporter struct {
+	config *OTLPConfig
+	client *http.Client
+	logger logging.Logger
+}
+
+// NewOTLPExporter creates a new OTLP exporter
+func NewOTLPExporter(logger logging.Logger, config *OTLPConfig) *OTLPExporter {
+	if config == nil {
+		config = DefaultOTLPConfig()
+	}
+	return &OTLPExporter{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		logger: logger,
+	}
+}
+
+// Export exports multiple spans in one request
+func (oe *OTLPExporter) Export(ctx context.Context, spans []*Span) error {
+	payload := oe.encode(spans)
+	if payload == nil {
+		return nil
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, oe.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create OTLP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range oe.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := oe.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export spans: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to export spans: %s: %s", resp.Status, bytes.TrimSpace(message))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if oe.logger != nil {
+		oe.logger.Debug(ctx, "OTLP spans exported",
+			logging.Int("spans", len(spans)),
+			logging.String("endpoint", oe.config.Endpoint))
+	}
+	return nil
+}
+
+// ExportSpan exports a single span
+func (oe *OTLPExporter) ExportSpan(ctx context.Context, span *Span) error {
+	return oe.Export(ctx, []*Span{span})
+}
+
+// OTLP/JSON payload types (ExportTraceServiceRequest)
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Events            []otlpEvent    `json:"events,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpEvent struct {
+		TimeUnixNano string         `json:"timeUnixNano"`
+		Name         string         `json:"name"`
+		Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+)
+
+// OTLP span kind and status codes
+const (
+	otlpKindInternal = 1
+	otlpKindServer   = 2
+	otlpKindClient   = 3
+	otlpKindProducer = 4
+	otlpKindConsumer = 5
+
+	otlpStatusOK    = 1
+	otlpStatusError = 2
+)
+
+// encode builds the OTLP request for spans, or nil when there is nothing to export
+func (oe *OTLPExporter) encode(spans []*Span) *otlpRequest {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		if span == nil {
+			continue
+		}
+		encoded = append(encoded, encodeOTLPSpan(span))
+	}
+	if len(encoded) == 0 {
+		return nil
+	}
+
+	resource := map[string]string{}
+	for key, value := range oe.config.ResourceAttributes {
+		resource[key] = value
+	}
+	if oe.config.ServiceName != "" {
+		resource["service.name"] = oe.config.ServiceName
+	}
+
+	return &otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: otlpAttributes(resource)},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: otlpScopeName},
+			Spans: encoded,
+		}},
+	}}}
+}
+
+// encodeOTLPSpan converts a span to its OTLP representation
+func encodeOTLPSpan(span *Span) otlpSpan {
+	end := span.EndTime
+	if end.IsZero() {
+		end = span.StartTime.Add(span.Duration)
+	}
+
+	encoded := otlpSpan{
+		TraceID:           otlpID(string(span.TraceID), 16),
+		SpanID:            otlpID(string(span.SpanID), 8),
+		Name:              span.Name,
+		Kind:              otlpKind(span.Kind),
+		StartTimeUnixNano: otlpTime(span.StartTime),
+		EndTimeUnixNano:   otlpTime(end),
+		Attributes:        otlpAttributes(span.Attributes),
+		Status:            otlpStatus{Code: otlpStatusOK},
+	}
+	if span.ParentSpanID != "" {
+		encoded.ParentSpanID = otlpID(string(span.ParentSpanID), 8)
+	}
+	if span.Status == SpanStatusError {
+		encoded.Status.Code = otlpStatusError
+		if span.Error != nil {
+			encoded.Status.Message = span.Error.Error()
+		}
+	}
+	for _, event := range span.Events {
+		encoded.Events = append(encoded.Events, otlpEvent{
+			TimeUnixNano: otlpTime(event.Timestamp),
+			Name:         event.Name,
+			Attributes:   otlpAttributes(event.Attributes),
+		})
+	}
+	return encoded
+}
+
+// otlpID returns id as the hex string of size bytes OTLP expects. IDs that are
+// not W3C IDs are hashed, keeping parent links between spans consistent.
+func otlpID(id string, size int) string {
+	if isHexID(id, size*2) {
+		return id
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:size])
+}
+
+// otlpKind maps a span kind to its OTLP code
+func otlpKind(kind SpanKind) int {
+	switch kind {
+	case SpanKindServer:
+		return otlpKindServer
+	case SpanKindClient:
+		return otlpKindClient
+	case SpanKindProducer:
+		return otlpKindProducer
+	case SpanKindConsumer:
+		return otlpKindConsumer
+	default:
+		return otlpKindInternal
+	}
+}
+
+// otlpTime formats t as Unix nanoseconds, zero for the zero time
+func otlpTime(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// otlpAttributes converts attributes to OTLP key values sorted by key
+func otlpAttributes(attributes map[string]string) []otlpKeyValue {
+	if len(attributes) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make([]otlpKeyValue, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, otlpKeyValue{Key: key, Value: otlpValue{StringValue: attributes[key]}})
+	}
+	return values
+}
@@ -0,0 +1,60 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SlowQuery describes a statement slower than the slow query threshold
+// together with the plan captured for it
+type SlowQuery struct {
+	// Summary is the verb and table of the statement, e.g. "SELECT users"
+	Summary string `json:"summary"`
+	// Table is the table of the repository running the statement
+	Table string `json:"table,omitempty"`
+	// Query is the SQL of the statement
+	Query string `json:"query"`
+	// Started is when the statement started
+	Started time.Time `json:"started"`
+	// Duration is how long the statement ran
+	Duration time.Duration `json:"duration"`
+	// Plan is the plan of the statement as reported by the database, cut to
+	// the maximum plan size
+	Plan string `json:"plan"`
+	// Truncated reports whether the plan was cut
+	Truncated bool `json:"truncated"`
+}
+
+// RecordSlowQuery counts an explained slow statement in
+// orm_slow_query_plans_total, one series per statement summary
+func (om *ORMMetrics) RecordSlowQuery(ctx context.Context, sq SlowQuery) {
+	om.incrementSeries("orm_slow_query_plans_total", map[string]string{
+		"query": sq.Summary,
+	}, "Slow statements whose plan was captured", "queries")
+}
+
+// RecordSlowQuery records an explained slow statement in the metrics and as
+// a query span carrying its plan
+func (om *ObservabilityManager) RecordSlowQuery(ctx context.Context, sq SlowQuery) {
+	// Record metrics
+	if om.config.MetricsEnabled {
+		om.metrics.RecordSlowQuery(ctx, sq)
+	}
+
+	// Record tracing
+	if om.config.TracingEnabled {
+		_, span := om.tracer.StartQuerySpan(ctx, sq.Query, "slow_query")
+		if span != nil {
+			span.StartTime = sq.Started
+			if sq.Table != "" {
+				om.tracer.AddSpanAttribute(span, "table", sq.Table)
+			}
+			om.tracer.AddSpanAttribute(span, "summary", sq.Summary)
+			om.tracer.AddSpanAttribute(span, "duration_ms", fmt.Sprintf("%d", sq.Duration.Milliseconds()))
+			om.tracer.AddSpanAttribute(span, "plan", sq.Plan)
+			om.tracer.AddSpanAttribute(span, "plan.truncated", fmt.Sprintf("%t", sq.Truncated))
+			om.tracer.EndSpan(span, nil)
+		}
+	}
+}
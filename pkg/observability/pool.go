@@ -0,0 +1,157 @@
+package observability
+
+import (
+	"context"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+)
+
+// PoolMetrics is a snapshot of the state of a connection pool
+type PoolMetrics struct {
+	// Pool names the pool, e.g. the database it connects to
+	Pool string `json:"pool"`
+	// MaxOpen is the maximum number of open connections, zero when unlimited
+	MaxOpen int `json:"max_open"`
+	// MinConnections is the number of connections the pool was warmed up with
+	MinConnections int `json:"min_connections"`
+	// Open is the number of established connections, in use or idle
+	Open int `json:"open"`
+	// InUse is the number of connections currently in use
+	InUse int `json:"in_use"`
+	// Idle is the number of idle connections
+	Idle int `json:"idle"`
+	// WaitCount is the total number of connections waited for
+	WaitCount int64 `json:"wait_count"`
+	// WaitDuration is the total time spent waiting for connections
+	WaitDuration time.Duration `json:"wait_duration"`
+	// MaxIdleClosed is the total number of connections closed by the idle limit
+	MaxIdleClosed int64 `json:"max_idle_closed"`
+	// MaxIdleTimeClosed is the total number of connections closed by the idle timeout
+	MaxIdleTimeClosed int64 `json:"max_idle_time_closed"`
+	// MaxLifetimeClosed is the total number of connections closed by their maximum lifetime
+	MaxLifetimeClosed int64 `json:"max_lifetime_closed"`
+	// AcquireTimeouts is the total number of acquisitions that timed out
+	AcquireTimeouts int64 `json:"acquire_timeouts"`
+	// Leaked is the number of connections currently held beyond the leak timeout
+	Leaked int `json:"leaked"`
+}
+
+// ConnectionLeak describes a connection held longer than the leak timeout
+type ConnectionLeak struct {
+	// Pool names the pool the connection belongs to
+	Pool string `json:"pool"`
+	// Operation is what the connection was acquired for (exec, query, transaction)
+	Operation string `json:"operation"`
+	// AcquiredAt is when the connection was taken from the pool
+	AcquiredAt time.Time `json:"acquired_at"`
+	// Held is how long the connection had been held when the leak was detected
+	Held time.Duration `json:"held"`
+	// Stack is the stack trace of the goroutine that acquired the connection
+	Stack string `json:"stack"`
+}
+
+// PoolExhaustion describes an acquisition that timed out because every
+// connection of the pool was in use
+type PoolExhaustion struct {
+	// Pool names the pool the connection was requested from
+	Pool string `json:"pool"`
+	// Operation is what the connection was requested for (exec, query, transaction)
+	Operation string `json:"operation"`
+	// Timeout is the acquire timeout that elapsed
+	Timeout time.Duration `json:"timeout"`
+	// MaxOpen, InUse and WaitCount are the pool statistics when the acquisition timed out
+	MaxOpen   int   `json:"max_open"`
+	InUse     int   `json:"in_use"`
+	WaitCount int64 `json:"wait_count"`
+}
+
+// RecordPoolMetrics records a connection pool snapshot in the orm_pool_*
+// metrics family, one series per pool
+func (om *ORMMetrics) RecordPoolMetrics(ctx context.Context, pm PoolMetrics) {
+	labels := map[string]string{"pool": pm.Pool}
+
+	om.setSeries("orm_pool_connections_max", MetricTypeGauge, float64(pm.MaxOpen), labels, "Maximum number of open connections", "connections")
+	om.setSeries("orm_pool_connections_min", MetricTypeGauge, float64(pm.MinConnections), labels, "Number of connections the pool was warmed up with", "connections")
+	om.setSeries("orm_pool_connections_open", MetricTypeGauge, float64(pm.Open), labels, "Number of established connections", "connections")
+	om.setSeries("orm_pool_connections_in_use", MetricTypeGauge, float64(pm.InUse), labels, "Number of connections in use", "connections")
+	om.setSeries("orm_pool_connections_idle", MetricTypeGauge, float64(pm.Idle), labels, "Number of idle connections", "connections")
+	om.setSeries("orm_pool_connections_leaked", MetricTypeGauge, float64(pm.Leaked), labels, "Number of connections held beyond the leak timeout", "connections")
+	om.setSeries("orm_pool_wait_total", MetricTypeCounter, float64(pm.WaitCount), labels, "Total number of connections waited for", "waits")
+	om.setSeries("orm_pool_wait_seconds_total", MetricTypeCounter, pm.WaitDuration.Seconds(), labels, "Total time spent waiting for connections", "seconds")
+	om.setSeries("orm_pool_acquire_timeouts_total", MetricTypeCounter, float64(pm.AcquireTimeouts), labels, "Total number of connection acquisitions that timed out", "acquisitions")
+
+	closed := map[string]int64{
+		"max_idle":      pm.MaxIdleClosed,
+		"max_idle_time": pm.MaxIdleTimeClosed,
+		"max_lifetime":  pm.MaxLifetimeClosed,
+	}
+	for reason, count := range closed {
+		om.setSeries("orm_pool_connections_closed_total", MetricTypeCounter, float64(count),
+			map[string]string{"pool": pm.Pool, "reason": reason}, "Total number of connections closed by the pool limits", "connections")
+	}
+
+	om.logger.Debug(ctx, "Pool metrics recorded",
+		logging.String("pool", pm.Pool),
+		logging.Int("open", pm.Open),
+		logging.Int("in_use", pm.InUse),
+		logging.Int("idle", pm.Idle),
+		logging.Int64("wait_count", pm.WaitCount),
+		logging.Int("leaked", pm.Leaked))
+}
+
+// RecordConnectionLeak counts a leaked connection in orm_pool_leaks_total
+func (om *ORMMetrics) RecordConnectionLeak(ctx context.Context, leak ConnectionLeak) {
+	om.incrementSeries("orm_pool_leaks_total", map[string]string{
+		"pool":      leak.Pool,
+		"operation": leak.Operation,
+	}, "Connections held beyond the leak timeout", "connections")
+}
+
+// RecordPoolExhausted counts an exhausted pool in orm_pool_exhausted_total
+func (om *ORMMetrics) RecordPoolExhausted(ctx context.Context, pe PoolExhaustion) {
+	om.incrementSeries("orm_pool_exhausted_total", map[string]string{
+		"pool":      pe.Pool,
+		"operation": pe.Operation,
+	}, "Connection acquisitions that timed out with every connection in use", "acquisitions")
+}
+
+// RecordPoolMetrics records a connection pool snapshot
+func (om *ObservabilityManager) RecordPoolMetrics(ctx context.Context, pm PoolMetrics) {
+	// Record metrics
+	if om.config.MetricsEnabled {
+		om.metrics.RecordPoolMetrics(ctx, pm)
+	}
+}
+
+// RecordConnectionLeak records a leaked connection and logs the stack trace
+// of its acquisition
+func (om *ObservabilityManager) RecordConnectionLeak(ctx context.Context, leak ConnectionLeak) {
+	// Record metrics
+	if om.config.MetricsEnabled {
+		om.metrics.RecordConnectionLeak(ctx, leak)
+	}
+
+	om.logger.Warn(ctx, "Connection held beyond the leak timeout",
+		logging.String("pool", leak.Pool),
+		logging.String("operation", leak.Operation),
+		logging.Duration("held", leak.Held),
+		logging.String("stack", leak.Stack))
+}
+
+// RecordPoolExhausted records an acquisition that timed out on an exhausted
+// pool and logs the pool statistics
+func (om *ObservabilityManager) RecordPoolExhausted(ctx context.Context, pe PoolExhaustion) {
+	// Record metrics
+	if om.config.MetricsEnabled {
+		om.metrics.RecordPoolExhausted(ctx, pe)
+	}
+
+	om.logger.Warn(ctx, "Connection pool exhausted",
+		logging.String("pool", pe.Pool),
+		logging.String("operation", pe.Operation),
+		logging.Duration("timeout", pe.Timeout),
+		logging.Int("max_open", pe.MaxOpen),
+		logging.Int("in_use", pe.InUse),
+		logging.Int64("wait_count", pe.WaitCount))
+}
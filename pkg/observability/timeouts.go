@@ -0,0 +1,204 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+)
+
+// QueryStage is the stage of a query's life cycle
+type QueryStage string
+
+const (
+	// QueryStageAcquire is the wait for a connection from the pool
+	QueryStageAcquire QueryStage = "acquire"
+	// QueryStageExecute is the round trip running the statement
+	QueryStageExecute QueryStage = "execute"
+	// QueryStageScan is the reading of the returned rows
+	QueryStageScan QueryStage = "scan"
+)
+
+// InterruptionCause tells why a query was interrupted
+type InterruptionCause string
+
+const (
+	// InterruptionTimeout means the context deadline expired
+	InterruptionTimeout InterruptionCause = "timeout"
+	// InterruptionCanceled means the context was cancelled
+	InterruptionCanceled InterruptionCause = "canceled"
+)
+
+// QueryInterruption describes a query interrupted by its context
+type QueryInterruption struct {
+	// Operation is the kind of statement (create, query, update, delete, raw)
+	Operation string `json:"operation"`
+	// Table is the table of the statement, when known
+	Table string `json:"table,omitempty"`
+	// Query is the SQL of the statement
+	Query string `json:"query"`
+	// Stage is the stage the query was in when interrupted
+	Stage QueryStage `json:"stage"`
+	// Cause tells whether the deadline expired or the context was cancelled
+	Cause InterruptionCause `json:"cause"`
+	// Budget is the time left before the context deadline when the query
+	// started, zero when the context had no deadline
+	Budget time.Duration `json:"budget"`
+	// Elapsed is the time from the start of the query to its interruption
+	Elapsed time.Duration `json:"elapsed"`
+	// ServerCancel reports whether the server stopped executing the statement.
+	// When false the statement may still be running on the server.
+	ServerCancel bool `json:"server_cancel"`
+	// Err is the error returned to the caller
+	Err error `json:"-"`
+}
+
+// BudgetConsumed returns the fraction of the budget consumed by the query,
+// zero when the context had no deadline
+func (qi QueryInterruption) BudgetConsumed() float64 {
+	if qi.Budget <= 0 {
+		return 0
+	}
+	return qi.Elapsed.Seconds() / qi.Budget.Seconds()
+}
+
+// RecordQueryInterruption records a query interrupted by a timeout or a
+// cancellation in the orm_query_timeout* metrics family, one series per
+// operation, stage and cause
+func (om *ORMMetrics) RecordQueryInterruption(ctx context.Context, qi QueryInterruption) {
+	labels := map[string]string{
+		"operation": qi.Operation,
+		"stage":     string(qi.Stage),
+		"cause":     string(qi.Cause),
+	}
+
+	om.incrementSeries("orm_query_timeouts_total", labels, "Queries interrupted by a timeout or cancellation", "queries")
+	om.setSeries("orm_query_timeout_elapsed_seconds", MetricTypeHistogram, qi.Elapsed.Seconds(), labels, "Time from query start to interruption", "seconds")
+	if qi.Budget > 0 {
+		om.setSeries("orm_query_timeout_budget_consumed_ratio", MetricTypeHistogram, qi.BudgetConsumed(), labels, "Fraction of the context deadline budget consumed before interruption", "ratio")
+	}
+	if qi.Stage != QueryStageAcquire {
+		om.incrementSeries("orm_query_timeout_server_cancels_total", map[string]string{
+			"operation": qi.Operation,
+			"delivered": fmt.Sprintf("%t", qi.ServerCancel),
+		}, "Interrupted statements by whether the server stopped executing them", "queries")
+	}
+
+	om.logger.Debug(ctx, "Query interruption recorded",
+		logging.String("operation", qi.Operation),
+		logging.String("stage", string(qi.Stage)),
+		logging.String("cause", string(qi.Cause)),
+		logging.Duration("budget", qi.Budget),
+		logging.Duration("elapsed", qi.Elapsed),
+		logging.Bool("server_cancel", qi.ServerCancel))
+}
+
+// AddInterruptionSpanEvent adds a query interruption event to a span and
+// marks the span with the interruption attributes
+func (ot *ORMTracer) AddInterruptionSpanEvent(span *Span, qi QueryInterruption) {
+	if span == nil {
+		return
+	}
+
+	attributes := map[string]string{
+		"stage":         string(qi.Stage),
+		"cause":         string(qi.Cause),
+		"elapsed_ms":    fmt.Sprintf("%.2f", float64(qi.Elapsed.Microseconds())/1000),
+		"server_cancel": fmt.Sprintf("%t", qi.ServerCancel),
+	}
+	if qi.Budget > 0 {
+		attributes["budget_ms"] = fmt.Sprintf("%.2f", float64(qi.Budget.Microseconds())/1000)
+		attributes["budget_consumed"] = fmt.Sprintf("%.3f", qi.BudgetConsumed())
+	}
+
+	ot.AddSpanEvent(span, "query_interrupted", attributes)
+	ot.AddSpanAttribute(span, "interruption.stage", string(qi.Stage))
+	ot.AddSpanAttribute(span, "interruption.cause", string(qi.Cause))
+	ot.AddSpanAttribute(span, "interruption.server_cancel", fmt.Sprintf("%t", qi.ServerCancel))
+}
+
+// RecordQueryInterruption records an interrupted query in the timeout metrics
+// and as a query span carrying a query_interrupted event
+func (om *ObservabilityManager) RecordQueryInterruption(ctx context.Context, qi QueryInterruption) {
+	// Record metrics
+	if om.config.MetricsEnabled {
+		om.metrics.RecordQueryInterruption(ctx, qi)
+	}
+
+	// Record tracing
+	if om.config.TracingEnabled {
+		_, span := om.tracer.StartQuerySpan(ctx, qi.Query, qi.Operation)
+		if span != nil {
+			if qi.Table != "" {
+				om.tracer.AddSpanAttribute(span, "table", qi.Table)
+			}
+			om.tracer.AddInterruptionSpanEvent(span, qi)
+			err := qi.Err
+			if err == nil {
+				err = fmt.Errorf("query interrupted by %s", qi.Cause)
+			}
+			om.tracer.EndSpan(span, err)
+		}
+	}
+}
+
+// incrementSeries increments the counter series of name identified by labels.
+// Unlike incrementMetric, every label set is kept as a separate metric.
+func (bmc *BaseMetricCollector) incrementSeries(name string, labels map[string]string, description, unit string) {
+	bmc.mutex.Lock()
+	defer bmc.mutex.Unlock()
+
+	key := seriesKey(name, labels)
+	if metric, exists := bmc.metrics[key]; exists && metric.Type == MetricTypeCounter {
+		metric.Value++
+		metric.Timestamp = time.Now()
+		return
+	}
+	bmc.metrics[key] = &Metric{
+		Name:        name,
+		Type:        MetricTypeCounter,
+		Value:       1,
+		Labels:      labels,
+		Timestamp:   time.Now(),
+		Description: description,
+		Unit:        unit,
+	}
+}
+
+// setSeries sets the series of name identified by labels
+func (bmc *BaseMetricCollector) setSeries(name string, metricType MetricType, value float64, labels map[string]string, description, unit string) {
+	bmc.mutex.Lock()
+	defer bmc.mutex.Unlock()
+
+	bmc.metrics[seriesKey(name, labels)] = &Metric{
+		Name:        name,
+		Type:        metricType,
+		Value:       value,
+		Labels:      labels,
+		Timestamp:   time.Now(),
+		Description: description,
+		Unit:        unit,
+	}
+}
+
+// seriesKey identifies the series of name with the given labels
+func seriesKey(name string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	for _, key := range keys {
+		b.WriteString("|")
+		b.WriteString(key)
+		b.WriteString("=")
+		b.WriteString(labels[key])
+	}
+	return b.String()
+}
@@ -2,7 +2,10 @@ package observability
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -70,12 +73,19 @@ type Tracer interface {
 	ExtractTraceContext(carrier map[string]string) context.Context
 }
 
+// TraceparentHeader is the W3C Trace Context header carrying the trace and parent span IDs
+const TraceparentHeader = "traceparent"
+
+// maxFinishedSpans bounds the ended spans buffered until the next export
+const maxFinishedSpans = 2048
+
 // BaseTracer implements basic tracing functionality
 type BaseTracer struct {
-	spans   map[SpanID]*Span
-	mutex   sync.RWMutex
-	logger  logging.Logger
-	enabled bool
+	spans    map[SpanID]*Span
+	finished []*Span
+	mutex    sync.RWMutex
+	logger   logging.Logger
+	enabled  bool
 }
 
 // NewBaseTracer creates a new base tracer
@@ -142,9 +152,13 @@ func (bt *BaseTracer) EndSpan(span *Span, err error) {
 		span.Error = err
 	}
 
-	// Remove span from storage
+	// Move span from storage to the export buffer, dropping the oldest when full
 	bt.mutex.Lock()
 	delete(bt.spans, span.SpanID)
+	if len(bt.finished) >= maxFinishedSpans {
+		bt.finished = bt.finished[1:]
+	}
+	bt.finished = append(bt.finished, span)
 	bt.mutex.Unlock()
 
 	bt.logger.Debug(span.Context, "Span ended",
@@ -156,6 +170,15 @@ func (bt *BaseTracer) EndSpan(span *Span, err error) {
 		logging.ErrorField("error", err))
 }
 
+// FinishedSpans returns the spans ended since the previous call, for trace exporters
+func (bt *BaseTracer) FinishedSpans() []*Span {
+	bt.mutex.Lock()
+	defer bt.mutex.Unlock()
+	spans := bt.finished
+	bt.finished = nil
+	return spans
+}
+
 // AddSpanEvent adds an event to a span
 func (bt *BaseTracer) AddSpanEvent(span *Span, name string, attributes map[string]string) {
 	if !bt.enabled || span == nil {
@@ -208,7 +231,9 @@ func (bt *BaseTracer) GetSpanID(ctx context.Context) SpanID {
 	return ""
 }
 
-// InjectTraceContext injects trace context into a carrier
+// InjectTraceContext injects trace context into a carrier as a W3C traceparent
+// header. Contexts whose IDs are not W3C IDs (e.g. extracted from a legacy
+// carrier) are injected under the trace_id and span_id keys instead.
 func (bt *BaseTracer) InjectTraceContext(ctx context.Context, carrier map[string]string) {
 	if !bt.enabled {
 		return
@@ -217,6 +242,11 @@ func (bt *BaseTracer) InjectTraceContext(ctx context.Context, carrier map[string
 	traceID := bt.GetTraceID(ctx)
 	spanID := bt.GetSpanID(ctx)
 
+	if isHexID(string(traceID), 32) && isHexID(string(spanID), 16) {
+		carrier[TraceparentHeader] = fmt.Sprintf("00-%s-%s-01", traceID, spanID)
+		return
+	}
+
 	if traceID != "" {
 		carrier["trace_id"] = string(traceID)
 	}
@@ -225,7 +255,9 @@ func (bt *BaseTracer) InjectTraceContext(ctx context.Context, carrier map[string
 	}
 }
 
-// ExtractTraceContext extracts trace context from a carrier
+// ExtractTraceContext extracts trace context from a carrier, reading the W3C
+// traceparent header (matched case-insensitively) and falling back to the
+// legacy trace_id and span_id keys
 func (bt *BaseTracer) ExtractTraceContext(carrier map[string]string) context.Context {
 	if !bt.enabled {
 		return context.Background()
@@ -233,6 +265,16 @@ func (bt *BaseTracer) ExtractTraceContext(carrier map[string]string) context.Con
 
 	ctx := context.Background()
 
+	for key, value := range carrier {
+		if !strings.EqualFold(key, TraceparentHeader) {
+			continue
+		}
+		if traceID, spanID, ok := ParseTraceparent(value); ok {
+			ctx = context.WithValue(ctx, "trace_id", traceID)
+			return context.WithValue(ctx, "span_id", spanID)
+		}
+	}
+
 	if traceID, ok := carrier["trace_id"]; ok {
 		ctx = context.WithValue(ctx, "trace_id", TraceID(traceID))
 	}
@@ -243,6 +285,36 @@ func (bt *BaseTracer) ExtractTraceContext(carrier map[string]string) context.Con
 	return ctx
 }
 
+// ParseTraceparent parses a W3C traceparent header value
+// (version-traceid-parentid-flags), rejecting all-zero IDs
+func ParseTraceparent(value string) (TraceID, SpanID, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || !isHexID(parts[0], 2) || parts[0] == "ff" {
+		return "", "", false
+	}
+	// Version 00 has exactly four fields; later versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return "", "", false
+	}
+	traceID, spanID := strings.ToLower(parts[1]), strings.ToLower(parts[2])
+	if !isHexID(traceID, 32) || !isHexID(spanID, 16) || !isHexID(parts[3], 2) {
+		return "", "", false
+	}
+	if strings.Trim(traceID, "0") == "" || strings.Trim(spanID, "0") == "" {
+		return "", "", false
+	}
+	return TraceID(traceID), SpanID(spanID), true
+}
+
+// isHexID reports whether id is a hex string of the given length
+func isHexID(id string, length int) bool {
+	if len(id) != length {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
 // generateTraceID generates a new trace ID
 func (bt *BaseTracer) generateTraceID(ctx context.Context) TraceID {
 	// Check if trace ID already exists in context
@@ -250,13 +322,23 @@ func (bt *BaseTracer) generateTraceID(ctx context.Context) TraceID {
 		return existingTraceID
 	}
 
-	// Generate new trace ID
-	return TraceID(fmt.Sprintf("trace_%d", time.Now().UnixNano()))
+	// Generate a new W3C trace ID (16 random bytes)
+	return TraceID(randomHexID(16))
 }
 
-// generateSpanID generates a new span ID
+// generateSpanID generates a new W3C span ID (8 random bytes)
 func (bt *BaseTracer) generateSpanID() SpanID {
-	return SpanID(fmt.Sprintf("span_%d", time.Now().UnixNano()))
+	return SpanID(randomHexID(8))
+}
+
+// randomHexID returns n random bytes hex-encoded
+func randomHexID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand does not fail on supported platforms; keep IDs unique regardless
+		return fmt.Sprintf("%0*x", n*2, time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
 }
 
 // ORMTracer represents ORM-specific tracing functionality
@@ -371,73 +453,3 @@ type TraceExporter interface {
 	Export(ctx context.Context, spans []*Span) error
 	ExportSpan(ctx context.Context, span *Span) error
 }
-
-// JaegerExporter exports traces to Jaeger
-type JaegerExporter struct {
-	logger logging.Logger
-}
-
-// NewJaegerExporter creates a new Jaeger exporter
-func NewJaegerExporter(logger logging.Logger) *JaegerExporter {
-	return &JaegerExporter{
-		logger: logger,
-	}
-}
-
-// Export exports multiple spans to Jaeger
-func (je *JaegerExporter) Export(ctx context.Context, spans []*Span) error {
-	for _, span := range spans {
-		if err := je.ExportSpan(ctx, span); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-// ExportSpan exports a single span to Jaeger
-func (je *JaegerExporter) ExportSpan(ctx context.Context, span *Span) error {
-	// This is a simplified implementation
-	// In a real implementation, you would send the span to Jaeger
-	je.logger.Info(ctx, "Jaeger span export",
-		logging.String("trace_id", string(span.TraceID)),
-		logging.String("span_id", string(span.SpanID)),
-		logging.String("name", span.Name),
-		logging.String("status", string(span.Status)),
-		logging.Duration("duration", span.Duration))
-	return nil
-}
-
-// ZipkinExporter exports traces to Zipkin
-type ZipkinExporter struct {
-	logger logging.Logger
-}
-
-// NewZipkinExporter creates a new Zipkin exporter
-func NewZipkinExporter(logger logging.Logger) *ZipkinExporter {
-	return &ZipkinExporter{
-		logger: logger,
-	}
-}
-
-// Export exports multiple spans to Zipkin
-func (ze *ZipkinExporter) Export(ctx context.Context, spans []*Span) error {
-	for _, span := range spans {
-		if err := ze.ExportSpan(ctx, span); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-// ExportSpan exports a single span to Zipkin
-func (ze *ZipkinExporter) ExportSpan(ctx context.Context, span *Span) error {
-	// This is a simplified implementation
-	// In a real implementation, you would send the span to Zipkin
-	ze.logger.Info(ctx, "Zipkin span export",
-		logging.String("trace_id", string(span.TraceID)),
-		logging.String("span_id", string(span.SpanID)),
-		logging.String("name", span.Name),
-		logging.String("status", string(span.Status)),
-		logging.Duration("duration", span.Duration))
-	return nil
-}
@@ -0,0 +1,96 @@
+// Package ormxtest isolates tests in database transactions. WithTx opens a
+// transaction rolled back when the test ends, so tests share a migrated
+// database without truncating or recreating tables between them:
+//
+//	func TestOrders(t *testing.T) {
+//		tx := ormxtest.WithTx(t, db)
+//		orders := ormxtest.Repository[Order](tx)
+//		...
+//		t.Run("refund", func(t *testing.T) {
+//			nested := tx.Nested(t) // rolled back to a savepoint after the subtest
+//			...
+//		})
+//	}
+//
+// Statements of a test run on a single connection and subtests using nested
+// transactions must not run in parallel with their parent.
+package ormxtest
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"gorm.io/gorm"
+)
+
+// savepoints numbers the savepoints of nested transactions
+var savepoints atomic.Int64
+
+// Tx is a transaction rolled back at the end of a test, with the settings
+// of the repositories bound to it
+type Tx struct {
+	// DB is the transaction
+	DB *gorm.DB
+	// Logger is the logger of the repositories, discarding all but errors by default
+	Logger logging.Logger
+	// Config is the configuration of the repositories
+	Config *repository.RepositoryConfig
+}
+
+// WithTx begins a transaction on db rolled back when t ends. When db is
+// already a transaction, e.g. the DB of another Tx, a savepoint is taken and
+// rolled back to instead.
+func WithTx(t testing.TB, db *gorm.DB) *Tx {
+	t.Helper()
+	if db == nil {
+		t.Fatal("ormxtest: database connection is required")
+	}
+
+	var tx *gorm.DB
+	if committer, ok := db.Statement.ConnPool.(gorm.TxCommitter); ok && committer != nil {
+		name := fmt.Sprintf("ormxtest_%d", savepoints.Add(1))
+		if err := db.SavePoint(name).Error; err != nil {
+			t.Fatalf("ormxtest: failed to create savepoint: %v", err)
+		}
+		tx = db
+		t.Cleanup(func() {
+			if err := tx.RollbackTo(name).Error; err != nil {
+				t.Errorf("ormxtest: failed to roll back to savepoint: %v", err)
+			}
+		})
+	} else {
+		tx = db.Begin()
+		if tx.Error != nil {
+			t.Fatalf("ormxtest: failed to begin transaction: %v", tx.Error)
+		}
+		t.Cleanup(func() {
+			if err := tx.Rollback().Error; err != nil {
+				t.Errorf("ormxtest: failed to roll back transaction: %v", err)
+			}
+		})
+	}
+
+	return &Tx{
+		DB:     tx,
+		Logger: logging.NewLogger(logging.LogLevelError, io.Discard, &logging.TextFormatter{}),
+		Config: repository.DefaultRepositoryConfig(),
+	}
+}
+
+// Nested takes a savepoint in the transaction rolled back to when t ends,
+// usually a subtest, keeping the settings of tx
+func (tx *Tx) Nested(t testing.TB) *Tx {
+	t.Helper()
+	nested := WithTx(t, tx.DB)
+	nested.Logger, nested.Config = tx.Logger, tx.Config
+	return nested
+}
+
+// Repository returns a repository of T bound to the transaction
+func Repository[T any](tx *Tx) *repository.BaseRepository[T] {
+	return repository.NewBaseRepository[T](tx.DB, tx.Logger, tx.Config)
+}
@@ -0,0 +1,196 @@
+// Package outbox implements the transactional outbox pattern: events
+// describing entity changes are inserted into the ormx_outbox_events table on
+// the connection of the change, so they commit or roll back with it, and a
+// Relay delivers the committed events to a message broker afterwards. Events
+// are delivered at least once: consumers deduplicate them by ID.
+//
+//	events, err := outbox.NewOutboxPublisher(db, logger, nil)
+//	users := outbox.Track(events, repository.NewBaseRepository[User](db, logger, nil))
+//	relay := outbox.NewRelay(events, broker, logger, nil)
+//	go relay.Start(ctx)
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/observability"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/seasbee/go-ormx/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// Event represents an event recorded in the outbox. Payload holds the JSON
+// encoded entity after the change (before it for deletes) and TraceContext
+// the JSON encoded trace context of the write, propagated by the publisher.
+type Event struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	EntityType    string     `gorm:"size:191;not null" json:"entity_type"`
+	EntityID      uuid.UUID  `gorm:"type:uuid;index" json:"entity_id"`
+	Operation     string     `gorm:"size:32;not null" json:"operation"`
+	Payload       string     `gorm:"type:text" json:"payload,omitempty"`
+	TraceContext  string     `gorm:"type:text" json:"trace_context,omitempty"`
+	CreatedAt     time.Time  `gorm:"not null" json:"created_at"`
+	Attempts      int        `gorm:"not null" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_ormx_outbox_events_due,priority:2" json:"next_attempt_at"`
+	PublishedAt   *time.Time `gorm:"index:idx_ormx_outbox_events_due,priority:1" json:"published_at,omitempty"`
+	LastError     string     `gorm:"type:text" json:"last_error,omitempty"`
+	ClaimedBy     string     `gorm:"size:255" json:"claimed_by,omitempty"`
+	ClaimedUntil  *time.Time `json:"claimed_until,omitempty"`
+}
+
+// TableName returns the table used to persist outbox events
+func (Event) TableName() string {
+	return "ormx_outbox_events"
+}
+
+// Decode unmarshals the payload of the event into dest
+func (e *Event) Decode(dest interface{}) error {
+	if e.Payload == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(e.Payload), dest); err != nil {
+		return fmt.Errorf("failed to decode outbox event %s: %w", e.ID, err)
+	}
+	return nil
+}
+
+// Carrier returns the trace context of the write that recorded the event, to
+// propagate with the message (e.g. as its headers) or to extract with
+// Tracer.ExtractTraceContext
+func (e *Event) Carrier() map[string]string {
+	carrier := map[string]string{}
+	if e.TraceContext != "" {
+		_ = json.Unmarshal([]byte(e.TraceContext), &carrier)
+	}
+	return carrier
+}
+
+// OutboxConfig represents outbox configuration
+type OutboxConfig struct {
+	// Tracer injects the trace context of the writes into their events
+	// (default an enabled observability.BaseTracer)
+	Tracer observability.Tracer `json:"-"`
+}
+
+// DefaultOutboxConfig returns default outbox configuration
+func DefaultOutboxConfig() *OutboxConfig {
+	return &OutboxConfig{}
+}
+
+// OutboxPublisher records events in the outbox table
+type OutboxPublisher struct {
+	db     *gorm.DB
+	logger logging.Logger
+	config *OutboxConfig
+}
+
+// NewOutboxPublisher creates an outbox publisher, creating the outbox table
+// if needed
+func NewOutboxPublisher(db *gorm.DB, logger logging.Logger, config *OutboxConfig) (*OutboxPublisher, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+	if config == nil {
+		config = DefaultOutboxConfig()
+	}
+	if config.Tracer == nil {
+		config.Tracer = observability.NewBaseTracer(logger, true)
+	}
+
+	if err := db.AutoMigrate(&Event{}); err != nil {
+		return nil, fmt.Errorf("failed to create outbox table: %w", err)
+	}
+
+	return &OutboxPublisher{db: db, logger: logger, config: config}, nil
+}
+
+// Publish records event on tx, the transaction of the write it describes, so
+// it is delivered only if the transaction commits; a nil tx records it on its
+// own. The ID, creation time and trace context of ctx are set when empty.
+func (p *OutboxPublisher) Publish(ctx context.Context, tx *gorm.DB, event *Event) error {
+	if event == nil || event.EntityType == "" || event.Operation == "" {
+		return fmt.Errorf("outbox event entity type and operation are required")
+	}
+	if tx == nil {
+		tx = p.db
+	}
+
+	now := time.Now().UTC()
+	if event.ID == uuid.Nil {
+		event.ID = utils.GenerateUUIDv7()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = now
+	}
+	if event.NextAttemptAt.IsZero() {
+		event.NextAttemptAt = event.CreatedAt
+	}
+	if event.TraceContext == "" {
+		carrier := map[string]string{}
+		p.config.Tracer.InjectTraceContext(ctx, carrier)
+		if len(carrier) > 0 {
+			data, err := json.Marshal(carrier)
+			if err != nil {
+				return fmt.Errorf("failed to encode trace context: %w", err)
+			}
+			event.TraceContext = string(data)
+		}
+	}
+
+	if err := tx.WithContext(ctx).Create(event).Error; err != nil {
+		return fmt.Errorf("failed to record outbox event: %w", err)
+	}
+	return nil
+}
+
+// Track records an event for every change of the entities written through
+// repo, in the transaction of the write, and returns repo
+func Track[T any](p *OutboxPublisher, repo *repository.BaseRepository[T]) *repository.BaseRepository[T] {
+	return repo.OnChange(func(ctx context.Context, change *repository.EntityChange[T]) error {
+		entity := change.After
+		if entity == nil {
+			entity = change.Before
+		}
+		var payload string
+		if entity != nil {
+			data, err := json.Marshal(entity)
+			if err != nil {
+				return fmt.Errorf("failed to encode outbox event of %s %s: %w", change.Table, change.ID, err)
+			}
+			payload = string(data)
+		}
+
+		return p.Publish(ctx, change.DB, &Event{
+			EntityType: change.Table,
+			EntityID:   change.ID,
+			Operation:  change.Operation,
+			Payload:    payload,
+		})
+	})
+}
+
+// Pending returns the number of events not delivered yet
+func (p *OutboxPublisher) Pending(ctx context.Context) (int64, error) {
+	var count int64
+	if err := p.db.WithContext(ctx).Model(&Event{}).Where("published_at IS NULL").Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count pending outbox events: %w", err)
+	}
+	return count, nil
+}
+
+// Purge deletes the events delivered more than olderThan ago and returns
+// their number
+func (p *OutboxPublisher) Purge(ctx context.Context, olderThan time.Duration) (int64, error) {
+	result := p.db.WithContext(ctx).
+		Where("published_at IS NOT NULL AND published_at < ?", time.Now().UTC().Add(-olderThan)).
+		Delete(&Event{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge outbox events: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
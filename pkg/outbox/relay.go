@@ -0,0 +1,282 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Publisher delivers outbox events to a message broker. An error leaves the
+// event in the outbox to be delivered again later.
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+// PublisherFunc adapts a function to the Publisher interface
+type PublisherFunc func(ctx context.Context, event *Event) error
+
+// Publish calls f
+func (f PublisherFunc) Publish(ctx context.Context, event *Event) error {
+	return f(ctx, event)
+}
+
+// RelayConfig represents relay configuration
+type RelayConfig struct {
+	// Worker identifies this process in the claims of the events
+	Worker string `json:"worker"`
+	// BatchSize bounds the number of events claimed at once
+	BatchSize int `json:"batch_size"`
+	// PollInterval is how often Start looks for events to deliver
+	PollInterval time.Duration `json:"poll_interval"`
+	// LeaseDuration is how long a claim lasts before other relays may deliver
+	// the events again; it should exceed the delivery of a batch
+	LeaseDuration time.Duration `json:"lease_duration"`
+	// RetryDelay is the delay before the first redelivery of a failed event,
+	// doubled on every further failure up to MaxRetryDelay
+	RetryDelay    time.Duration `json:"retry_delay"`
+	MaxRetryDelay time.Duration `json:"max_retry_delay"`
+}
+
+// DefaultRelayConfig returns default relay configuration
+func DefaultRelayConfig() *RelayConfig {
+	hostname, _ := os.Hostname()
+	return &RelayConfig{
+		Worker:        fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		BatchSize:     100,
+		PollInterval:  time.Second,
+		LeaseDuration: time.Minute,
+		RetryDelay:    time.Second,
+		MaxRetryDelay: 5 * time.Minute,
+	}
+}
+
+// Relay delivers the events of an outbox to a publisher. Any number of relays
+// may run against the same outbox: events are claimed with SELECT ... FOR
+// UPDATE SKIP LOCKED where the dialect supports it and a conditional update
+// otherwise, so each claimed event is delivered by a single relay at a time.
+//
+// Delivery is at least once. An event is marked published only after the
+// publisher accepted it; a relay stopping in between leaves the event
+// claimed, and it is delivered again once the claim expires. Events are handed
+// to the publisher in the order they were recorded, except that a failed
+// event is retried after a backoff, possibly after later events.
+type Relay struct {
+	outbox    *OutboxPublisher
+	publisher Publisher
+	logger    logging.Logger
+	config    *RelayConfig
+	dialect   dialect.Dialect
+}
+
+// NewRelay creates a relay delivering the events of outbox to publisher
+func NewRelay(outbox *OutboxPublisher, publisher Publisher, logger logging.Logger, config *RelayConfig) *Relay {
+	defaults := DefaultRelayConfig()
+	if config == nil {
+		config = defaults
+	}
+	if config.Worker == "" {
+		config.Worker = defaults.Worker
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaults.PollInterval
+	}
+	if config.LeaseDuration <= 0 {
+		config.LeaseDuration = defaults.LeaseDuration
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = defaults.RetryDelay
+	}
+	if config.MaxRetryDelay < config.RetryDelay {
+		config.MaxRetryDelay = max(defaults.MaxRetryDelay, config.RetryDelay)
+	}
+
+	return &Relay{
+		outbox:    outbox,
+		publisher: publisher,
+		logger:    logger,
+		config:    config,
+		dialect:   dialect.For(outbox.db),
+	}
+}
+
+// RelayOnce claims up to BatchSize due events and delivers them, returning
+// the number of events published. Delivery failures are recorded on the
+// events, which are retried later, and do not fail the call.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	events, err := r.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for i := range events {
+		event := &events[i]
+		deliveryErr := r.publisher.Publish(ctx, event)
+		if deliveryErr != nil && r.logger != nil {
+			r.logger.Warn(ctx, "Outbox event delivery failed",
+				logging.String("event", event.ID.String()),
+				logging.String("entity_type", event.EntityType),
+				logging.Int("attempts", event.Attempts+1),
+				logging.ErrorField("error", deliveryErr))
+		}
+
+		// Record the outcome even if the context was cancelled during the delivery
+		if err := r.complete(context.WithoutCancel(ctx), event, deliveryErr); err != nil {
+			return published, err
+		}
+		if deliveryErr == nil {
+			published++
+		}
+	}
+	return published, nil
+}
+
+// Start delivers due events every PollInterval until ctx is done. A full
+// batch is followed by the next one without waiting.
+func (r *Relay) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		published, err := r.RelayOnce(ctx)
+		if err != nil && ctx.Err() == nil && r.logger != nil {
+			r.logger.Error(ctx, "Failed to relay outbox events", logging.ErrorField("error", err))
+		}
+		if err == nil && published == r.config.BatchSize {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim claims the due events not published nor claimed by another relay,
+// oldest first
+func (r *Relay) claim(ctx context.Context) ([]Event, error) {
+	var claimed []Event
+	err := r.outbox.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		available := func(query *gorm.DB) *gorm.DB {
+			return query.
+				Where("published_at IS NULL AND next_attempt_at <= ?", now).
+				Where("claimed_until IS NULL OR claimed_until < ?", now)
+		}
+
+		var ids []interface{}
+		var due []Event
+		if err := available(r.lockSkipLocked(tx)).Select("id").Order("id").Limit(r.config.BatchSize).Find(&due).Error; err != nil {
+			return fmt.Errorf("failed to load due outbox events: %w", err)
+		}
+		if len(due) == 0 {
+			return nil
+		}
+		for _, event := range due {
+			ids = append(ids, event.ID)
+		}
+
+		// The conditions are checked again so a concurrent relay without row
+		// locks cannot claim the same events. The claim is read back by its
+		// expiry, truncated to the precision of every database.
+		until := now.Add(r.config.LeaseDuration).Truncate(time.Millisecond)
+		err := available(tx.Model(&Event{})).
+			Where(clause.IN{Column: clause.PrimaryColumn, Values: ids}).
+			Updates(map[string]interface{}{"claimed_by": r.config.Worker, "claimed_until": until}).Error
+		if err != nil {
+			return fmt.Errorf("failed to claim outbox events: %w", err)
+		}
+
+		err = tx.Where(clause.IN{Column: clause.PrimaryColumn, Values: ids}).
+			Where("claimed_by = ? AND claimed_until = ?", r.config.Worker, until).
+			Order("id").
+			Find(&claimed).Error
+		if err != nil {
+			return fmt.Errorf("failed to load claimed outbox events: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// complete records the outcome of the delivery of event and releases its claim
+func (r *Relay) complete(ctx context.Context, event *Event, deliveryErr error) error {
+	now := time.Now().UTC()
+	updates := map[string]interface{}{
+		"attempts":      event.Attempts + 1,
+		"claimed_by":    "",
+		"claimed_until": nil,
+	}
+	if deliveryErr == nil {
+		updates["published_at"] = now
+		updates["last_error"] = ""
+	} else {
+		updates["next_attempt_at"] = now.Add(r.backoff(event.Attempts + 1))
+		updates["last_error"] = deliveryErr.Error()
+	}
+
+	err := r.outbox.db.WithContext(ctx).Model(&Event{}).
+		Where("id = ? AND claimed_by = ?", event.ID, r.config.Worker).
+		Updates(updates).Error
+	if err != nil {
+		return fmt.Errorf("failed to complete delivery of outbox event %s: %w", event.ID, err)
+	}
+
+	event.Attempts++
+	event.ClaimedBy, event.ClaimedUntil = "", nil
+	if deliveryErr == nil {
+		event.PublishedAt, event.LastError = &now, ""
+	} else {
+		event.NextAttemptAt, event.LastError = updates["next_attempt_at"].(time.Time), deliveryErr.Error()
+	}
+	return nil
+}
+
+// backoff returns the delay before the delivery following the given number
+// of failed attempts
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.config.RetryDelay
+	for i := 1; i < attempts && delay < r.config.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, r.config.MaxRetryDelay)
+}
+
+// lockSkipLocked locks the selected events, skipping the events locked by
+// other relays when the dialect supports it. Dialects without row locks
+// (SQLite) serialize writers, and the conditional claim update keeps claims
+// exclusive.
+func (r *Relay) lockSkipLocked(tx *gorm.DB) *gorm.DB {
+	locker, ok := r.dialect.(dialect.SkipLocker)
+	if !ok {
+		return tx.Model(&Event{})
+	}
+
+	locking := locker.ForUpdateSkipLocked()
+	if locking.TableHint != "" {
+		tx = tx.Table(fmt.Sprintf("%s %s", Event{}.TableName(), locking.TableHint))
+	} else {
+		tx = tx.Model(&Event{})
+	}
+	if locking.Suffix != "" {
+		tx = tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate, Options: "SKIP LOCKED"})
+	}
+	return tx
+}
@@ -0,0 +1,278 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"reflect"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ActorContextKey is the default context key of the acting user, also read
+// by the logger to tag log entries with user_id and by the auditor
+const ActorContextKey = "user_id"
+
+// ErrActorRequired is returned by strict actor stampers when a write to a
+// model with actor columns runs without an actor in its context
+var ErrActorRequired = stderrors.New("actor required in context")
+
+// WithActor returns a context acting as actor under ActorContextKey
+func WithActor(ctx context.Context, actor interface{}) context.Context {
+	return context.WithValue(ctx, ActorContextKey, actor)
+}
+
+// ActorConfig configures an ActorStamper
+type ActorConfig struct {
+	// ContextKey is the context key of the actor (default ActorContextKey)
+	ContextKey interface{} `json:"-"`
+	// CreatedByColumn is filled on creates when empty (default "created_by")
+	CreatedByColumn string `json:"created_by_column"`
+	// UpdatedByColumn is filled on creates when empty and set on every
+	// update (default "updated_by")
+	UpdatedByColumn string `json:"updated_by_column"`
+	// Strict refuses writes to models having either column when the context
+	// carries no actor; otherwise they leave the columns alone
+	Strict bool `json:"strict"`
+}
+
+// DefaultActorConfig returns default actor configuration
+func DefaultActorConfig() *ActorConfig {
+	return &ActorConfig{
+		ContextKey:      ActorContextKey,
+		CreatedByColumn: "created_by",
+		UpdatedByColumn: "updated_by",
+	}
+}
+
+// ActorStamper is a gorm plugin filling the created_by and updated_by
+// columns of the models having them (such as models.BaseModel) from the
+// actor of the statement context, so every write records who made it
+// without each call site setting the fields. Bulk updates (Updates with a
+// map, UpdateAllByConditions) get the column added to their SET clause.
+//
+// Actors are converted to the column type: UUID columns accept uuid.UUID
+// values and UUID strings, string columns any value formatted with fmt.
+// Statements without a model, such as raw SQL, are not stamped.
+type ActorStamper struct {
+	config *ActorConfig
+}
+
+// NewActorStamper creates an actor stamper
+func NewActorStamper(config *ActorConfig) *ActorStamper {
+	defaults := DefaultActorConfig()
+	if config == nil {
+		config = defaults
+	}
+	if config.ContextKey == nil {
+		config.ContextKey = defaults.ContextKey
+	}
+	if config.CreatedByColumn == "" {
+		config.CreatedByColumn = defaults.CreatedByColumn
+	}
+	if config.UpdatedByColumn == "" {
+		config.UpdatedByColumn = defaults.UpdatedByColumn
+	}
+	return &ActorStamper{config: config}
+}
+
+// Name returns the plugin name
+func (s *ActorStamper) Name() string {
+	return "ormx:actor_stamper"
+}
+
+// Initialize registers the stamper callbacks after the before hooks of the
+// creates and updates of db, so hooks cannot overwrite the actor
+func (s *ActorStamper) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	err := stderrors.Join(
+		callbacks.Create().After("gorm:before_create").Before("gorm:create").Register("ormx:actor_create", s.stampCreate),
+		callbacks.Update().After("gorm:before_update").Before("gorm:update").Register("ormx:actor_update", s.stampUpdate),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register actor callbacks: %w", err)
+	}
+	return nil
+}
+
+// Actor returns the actor of ctx
+func (s *ActorStamper) Actor(ctx context.Context) (interface{}, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	actor := ctx.Value(s.config.ContextKey)
+	if actor == nil {
+		return nil, false
+	}
+	if value := reflect.ValueOf(actor); value.IsZero() {
+		return nil, false
+	}
+	return actor, true
+}
+
+// fields returns the actor fields of the statement model and its actor,
+// failing the statement in strict mode when the context has none
+func (s *ActorStamper) fields(db *gorm.DB) (createdBy, updatedBy *schema.Field, actor interface{}, ok bool) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return nil, nil, nil, false
+	}
+	createdBy = db.Statement.Schema.LookUpField(s.config.CreatedByColumn)
+	updatedBy = db.Statement.Schema.LookUpField(s.config.UpdatedByColumn)
+	if createdBy == nil && updatedBy == nil {
+		return nil, nil, nil, false
+	}
+
+	actor, ok = s.Actor(db.Statement.Context)
+	if !ok {
+		if s.config.Strict {
+			_ = db.AddError(fmt.Errorf("%w: writing %s", ErrActorRequired, db.Statement.Schema.Table))
+		}
+		return nil, nil, nil, false
+	}
+	return createdBy, updatedBy, actor, true
+}
+
+// stampCreate fills the empty actor columns of the created rows
+func (s *ActorStamper) stampCreate(db *gorm.DB) {
+	createdBy, updatedBy, actor, ok := s.fields(db)
+	if !ok {
+		return
+	}
+
+	stmt := db.Statement
+	for _, field := range []*schema.Field{createdBy, updatedBy} {
+		if field == nil || !s.selected(stmt, field) {
+			continue
+		}
+		value, err := actorValue(field, actor)
+		if err != nil {
+			_ = db.AddError(err)
+			return
+		}
+
+		switch dest := stmt.Dest.(type) {
+		case map[string]interface{}:
+			fillMap(dest, field, value)
+			continue
+		case []map[string]interface{}:
+			for _, row := range dest {
+				fillMap(row, field, value)
+			}
+			continue
+		}
+
+		switch stmt.ReflectValue.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < stmt.ReflectValue.Len(); i++ {
+				s.fill(db, field, reflect.Indirect(stmt.ReflectValue.Index(i)), value)
+			}
+		case reflect.Struct:
+			s.fill(db, field, stmt.ReflectValue, value)
+		}
+	}
+	s.include(stmt, createdBy, updatedBy)
+}
+
+// stampUpdate sets the updated by column of the updated rows
+func (s *ActorStamper) stampUpdate(db *gorm.DB) {
+	_, updatedBy, actor, ok := s.fields(db)
+	if !ok || updatedBy == nil || !s.selected(db.Statement, updatedBy) {
+		return
+	}
+
+	value, err := actorValue(updatedBy, actor)
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	db.Statement.SetColumn(updatedBy.DBName, value, true)
+	s.include(db.Statement, updatedBy)
+}
+
+// fill sets an actor field of a row when empty
+func (s *ActorStamper) fill(db *gorm.DB, field *schema.Field, row reflect.Value, value interface{}) {
+	if row.Kind() != reflect.Struct || !row.CanAddr() {
+		return
+	}
+	if _, zero := field.ValueOf(db.Statement.Context, row); zero {
+		_ = db.AddError(field.Set(db.Statement.Context, row, value))
+	}
+}
+
+// fillMap sets an actor column of a map row when absent
+func fillMap(row map[string]interface{}, field *schema.Field, value interface{}) {
+	if _, ok := row[field.DBName]; ok {
+		return
+	}
+	if _, ok := row[field.Name]; ok {
+		return
+	}
+	row[field.DBName] = value
+}
+
+// selected reports whether the statement may write field, which it may
+// unless omitted
+func (s *ActorStamper) selected(stmt *gorm.Statement, field *schema.Field) bool {
+	for _, omit := range stmt.Omits {
+		if omit == field.DBName || omit == field.Name || omit == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+// include adds the actor fields to statements restricted to selected columns
+func (s *ActorStamper) include(stmt *gorm.Statement, fields ...*schema.Field) {
+	if len(stmt.Selects) == 0 {
+		return
+	}
+	for _, selected := range stmt.Selects {
+		if selected == "*" {
+			return
+		}
+	}
+	for _, field := range fields {
+		if field != nil {
+			stmt.Selects = append(stmt.Selects, field.DBName)
+		}
+	}
+}
+
+// uuidType is the type of UUID actor columns
+var uuidType = reflect.TypeOf(uuid.UUID{})
+
+// actorValue converts actor to the type of an actor column
+func actorValue(field *schema.Field, actor interface{}) (interface{}, error) {
+	switch field.IndirectFieldType {
+	case uuidType:
+		switch v := actor.(type) {
+		case uuid.UUID:
+			return v, nil
+		case *uuid.UUID:
+			return *v, nil
+		case string:
+			id, err := uuid.Parse(v)
+			if err != nil {
+				return nil, fmt.Errorf("actor %q is not a UUID for %s: %w", v, field.DBName, err)
+			}
+			return id, nil
+		case fmt.Stringer:
+			id, err := uuid.Parse(v.String())
+			if err != nil {
+				return nil, fmt.Errorf("actor %q is not a UUID for %s: %w", v.String(), field.DBName, err)
+			}
+			return id, nil
+		}
+		return nil, fmt.Errorf("actor of type %T is not a UUID for %s", actor, field.DBName)
+	}
+
+	if field.IndirectFieldType.Kind() == reflect.String {
+		if s, ok := actor.(fmt.Stringer); ok {
+			return s.String(), nil
+		}
+		return fmt.Sprint(actor), nil
+	}
+	return actor, nil
+}
@@ -0,0 +1,610 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// columnNameRegex matches plain or table-qualified column names accepted by aggregate helpers
+var columnNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// bucketTimeLayouts are the textual timestamp formats returned by dialects that truncate to strings
+var bucketTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+// TimeBucketCount represents the number of entities in a time bucket
+type TimeBucketCount struct {
+	Bucket time.Time `json:"bucket"`
+	Count  int64     `json:"count"`
+}
+
+// PercentileValue represents the value of a column at a percentile
+type PercentileValue struct {
+	Percentile float64 `json:"percentile"`
+	Value      float64 `json:"value"`
+}
+
+// HistogramBucket represents the number of entities whose column value falls
+// in [Lower, Upper). A nil bound means the bucket is unbounded on that side.
+type HistogramBucket struct {
+	Lower *float64 `json:"lower,omitempty"`
+	Upper *float64 `json:"upper,omitempty"`
+	Count int64    `json:"count"`
+}
+
+// TimeSeriesCount counts entities grouped by timeColumn truncated to interval
+// (hour, day, week or month), ordered by bucket. Empty buckets are omitted.
+func (r *BaseRepository[T]) TimeSeriesCount(ctx context.Context, timeColumn string, interval dialect.TimeUnit, conds ...interface{}) ([]TimeBucketCount, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "TimeSeriesCount", time.Since(start))
+	}()
+
+	if !columnNameRegex.MatchString(timeColumn) {
+		r.metrics.IncrementOperationsFor("TimeSeriesCount", false)
+		return nil, r.argumentError("TimeSeriesCount", fmt.Sprintf("invalid time column: %q", timeColumn))
+	}
+	if !interval.Valid() {
+		r.metrics.IncrementOperationsFor("TimeSeriesCount", false)
+		return nil, r.argumentError("TimeSeriesCount", fmt.Sprintf("unsupported time bucket interval: %q", interval))
+	}
+
+	bucket := r.dialect.TruncateTime(r.dialect.Quote(timeColumn), interval)
+	query := r.readDB(ctx).Model(new(T)).
+		Select(bucket + " AS bucket, COUNT(*) AS count").
+		Group(bucket).
+		Order(bucket)
+	if len(conds) > 0 {
+		query = query.Where(conds[0], conds[1:]...)
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		r.metrics.IncrementOperationsFor("TimeSeriesCount", false)
+		return nil, r.wrapError(err, "TimeSeriesCount", "failed to count entities by time bucket")
+	}
+	defer rows.Close()
+
+	var results []TimeBucketCount
+	for rows.Next() {
+		var raw interface{}
+		var count int64
+		if err := rows.Scan(&raw, &count); err != nil {
+			r.metrics.IncrementOperationsFor("TimeSeriesCount", false)
+			return nil, r.wrapError(err, "TimeSeriesCount", "failed to scan time bucket")
+		}
+
+		bucketTime, err := parseBucketTime(raw)
+		if err != nil {
+			r.metrics.IncrementOperationsFor("TimeSeriesCount", false)
+			return nil, err
+		}
+		results = append(results, TimeBucketCount{Bucket: bucketTime, Count: count})
+	}
+	if err := rows.Err(); err != nil {
+		r.metrics.IncrementOperationsFor("TimeSeriesCount", false)
+		return nil, r.wrapError(err, "TimeSeriesCount", "failed to read time buckets")
+	}
+
+	r.metrics.IncrementOperationsFor("TimeSeriesCount", true)
+	return results, nil
+}
+
+// parseBucketTime converts a scanned bucket value to time.Time
+func parseBucketTime(raw interface{}) (time.Time, error) {
+	var text string
+	switch v := raw.(type) {
+	case time.Time:
+		return v, nil
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	case nil:
+		return time.Time{}, nil
+	default:
+		return time.Time{}, fmt.Errorf("unsupported time bucket type %T", raw)
+	}
+
+	for _, layout := range bucketTimeLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("failed to parse time bucket %q", text)
+}
+
+// PercentileBy returns the continuous percentiles (0..1) of a numeric column,
+// ignoring NULLs. Dialects with percentile_cont compute them in a single query;
+// elsewhere each percentile is interpolated from the two nearest ordered rows.
+// An empty result set yields no values.
+func (r *BaseRepository[T]) PercentileBy(ctx context.Context, column string, percentiles []float64, conds ...interface{}) ([]PercentileValue, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "PercentileBy", time.Since(start))
+	}()
+
+	if !columnNameRegex.MatchString(column) {
+		r.metrics.IncrementOperationsFor("PercentileBy", false)
+		return nil, r.argumentError("PercentileBy", fmt.Sprintf("invalid column: %q", column))
+	}
+	for _, p := range percentiles {
+		if p < 0 || p > 1 || math.IsNaN(p) {
+			r.metrics.IncrementOperationsFor("PercentileBy", false)
+			return nil, r.argumentError("PercentileBy", fmt.Sprintf("percentile must be between 0 and 1, got %v", p))
+		}
+	}
+	if len(percentiles) == 0 {
+		r.metrics.IncrementOperationsFor("PercentileBy", true)
+		return nil, nil
+	}
+
+	var results []PercentileValue
+	var err error
+	if aggregator, ok := r.dialect.(dialect.PercentileAggregator); ok {
+		results, err = r.percentileCont(ctx, aggregator, column, percentiles, conds)
+	} else {
+		results, err = r.percentileInterpolated(ctx, column, percentiles, conds)
+	}
+	if err != nil {
+		r.metrics.IncrementOperationsFor("PercentileBy", false)
+		return nil, err
+	}
+
+	r.metrics.IncrementOperationsFor("PercentileBy", true)
+	return results, nil
+}
+
+// HistogramBy counts entities per bucket of a numeric column. bounds are the
+// bucket boundaries; values below the first bound and at or above the last one
+// get their own unbounded buckets, so len(bounds)+1 buckets are returned.
+func (r *BaseRepository[T]) HistogramBy(ctx context.Context, column string, bounds []float64, conds ...interface{}) ([]HistogramBucket, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "HistogramBy", time.Since(start))
+	}()
+
+	if !columnNameRegex.MatchString(column) {
+		r.metrics.IncrementOperationsFor("HistogramBy", false)
+		return nil, r.argumentError("HistogramBy", fmt.Sprintf("invalid column: %q", column))
+	}
+	if len(bounds) == 0 {
+		r.metrics.IncrementOperationsFor("HistogramBy", false)
+		return nil, r.argumentError("HistogramBy", "at least one histogram bound is required")
+	}
+	if !sort.Float64sAreSorted(bounds) {
+		r.metrics.IncrementOperationsFor("HistogramBy", false)
+		return nil, r.argumentError("HistogramBy", "histogram bounds must be sorted in ascending order")
+	}
+
+	quoted := r.dialect.Quote(column)
+	selects := make([]string, len(bounds)+1)
+	for i := range selects {
+		var predicate []string
+		if i > 0 {
+			predicate = append(predicate, fmt.Sprintf("%s >= %s", quoted, formatBound(bounds[i-1])))
+		}
+		if i < len(bounds) {
+			predicate = append(predicate, fmt.Sprintf("%s < %s", quoted, formatBound(bounds[i])))
+		}
+		selects[i] = fmt.Sprintf("SUM(CASE WHEN %s THEN 1 ELSE 0 END) AS b%d", strings.Join(predicate, " AND "), i)
+	}
+
+	counts := make([]sql.NullInt64, len(selects))
+	dests := make([]interface{}, len(counts))
+	for i := range counts {
+		dests[i] = &counts[i]
+	}
+
+	if err := r.aggregateQuery(ctx, column, conds).Select(strings.Join(selects, ", ")).Row().Scan(dests...); err != nil {
+		r.metrics.IncrementOperationsFor("HistogramBy", false)
+		return nil, r.wrapError(err, "HistogramBy", "failed to compute histogram")
+	}
+
+	buckets := make([]HistogramBucket, len(counts))
+	for i, count := range counts {
+		if i > 0 {
+			lower := bounds[i-1]
+			buckets[i].Lower = &lower
+		}
+		if i < len(bounds) {
+			upper := bounds[i]
+			buckets[i].Upper = &upper
+		}
+		buckets[i].Count = count.Int64
+	}
+
+	r.metrics.IncrementOperationsFor("HistogramBy", true)
+	return buckets, nil
+}
+
+// aggregateQuery returns a read query over T restricted to conds and non-NULL column values
+func (r *BaseRepository[T]) aggregateQuery(ctx context.Context, column string, conds []interface{}) *gorm.DB {
+	query := r.readDB(ctx).Model(new(T)).Where(r.dialect.Quote(column) + " IS NOT NULL")
+	if len(conds) > 0 {
+		query = query.Where(conds[0], conds[1:]...)
+	}
+	return query
+}
+
+// percentileCont computes all percentiles with the dialect's percentile_cont aggregate
+func (r *BaseRepository[T]) percentileCont(ctx context.Context, aggregator dialect.PercentileAggregator, column string, percentiles []float64, conds []interface{}) ([]PercentileValue, error) {
+	quoted := r.dialect.Quote(column)
+	selects := make([]string, len(percentiles))
+	values := make([]sql.NullFloat64, len(percentiles))
+	dests := make([]interface{}, len(percentiles))
+	for i, p := range percentiles {
+		selects[i] = fmt.Sprintf("%s AS p%d", aggregator.PercentileCont(quoted, p), i)
+		dests[i] = &values[i]
+	}
+
+	if err := r.aggregateQuery(ctx, column, conds).Select(strings.Join(selects, ", ")).Row().Scan(dests...); err != nil {
+		return nil, r.wrapError(err, "PercentileBy", "failed to compute percentiles")
+	}
+
+	var results []PercentileValue
+	for i, v := range values {
+		if !v.Valid {
+			return nil, nil
+		}
+		results = append(results, PercentileValue{Percentile: percentiles[i], Value: v.Float64})
+	}
+	return results, nil
+}
+
+// percentileInterpolated computes each percentile by linear interpolation
+// between the two ordered rows surrounding its rank
+func (r *BaseRepository[T]) percentileInterpolated(ctx context.Context, column string, percentiles []float64, conds []interface{}) ([]PercentileValue, error) {
+	var total int64
+	if err := r.aggregateQuery(ctx, column, conds).Count(&total).Error; err != nil {
+		return nil, r.wrapError(err, "PercentileBy", "failed to count percentile rows")
+	}
+	if total == 0 {
+		return nil, nil
+	}
+
+	results := make([]PercentileValue, len(percentiles))
+	for i, p := range percentiles {
+		position := p * float64(total-1)
+		rank := math.Floor(position)
+
+		var values []float64
+		err := r.aggregateQuery(ctx, column, conds).
+			Order(r.dialect.Quote(column)).
+			Offset(int(rank)).
+			Limit(2).
+			Pluck(column, &values).Error
+		if err != nil {
+			return nil, r.wrapError(err, "PercentileBy", fmt.Sprintf("failed to compute percentile %v", p))
+		}
+		if len(values) == 0 {
+			return nil, r.newError(errors.ErrorTypeQuery, "PercentileBy", fmt.Sprintf("failed to compute percentile %v: rows changed during computation", p))
+		}
+
+		value := values[0]
+		if len(values) > 1 {
+			value += (position - rank) * (values[1] - values[0])
+		}
+		results[i] = PercentileValue{Percentile: p, Value: value}
+	}
+	return results, nil
+}
+
+// formatBound renders a histogram bound as a SQL numeric literal
+func formatBound(bound float64) string {
+	return strconv.FormatFloat(bound, 'g', -1, 64)
+}
+
+// AggregateFunc is an aggregate function computed by GroupBy
+type AggregateFunc string
+
+const (
+	AggregateCount AggregateFunc = "COUNT"
+	AggregateSum   AggregateFunc = "SUM"
+	AggregateAvg   AggregateFunc = "AVG"
+	AggregateMin   AggregateFunc = "MIN"
+	AggregateMax   AggregateFunc = "MAX"
+)
+
+// Aggregate is an aggregate computed per group by GroupBy, such as
+// repository.SumOf("amount").As("total")
+type Aggregate struct {
+	Func AggregateFunc
+	// Column is the aggregated column; empty counts the rows of COUNT
+	Column string
+	// Alias keys the aggregate in the results, by default the lowercase
+	// function and column ("sum_amount", or "count" for CountAll)
+	Alias string
+}
+
+// CountAll counts the rows of each group
+func CountAll() Aggregate { return Aggregate{Func: AggregateCount} }
+
+// CountOf counts the non-NULL values of column in each group
+func CountOf(column string) Aggregate { return Aggregate{Func: AggregateCount, Column: column} }
+
+// SumOf sums column in each group
+func SumOf(column string) Aggregate { return Aggregate{Func: AggregateSum, Column: column} }
+
+// AvgOf averages column in each group
+func AvgOf(column string) Aggregate { return Aggregate{Func: AggregateAvg, Column: column} }
+
+// MinOf returns the smallest value of column in each group
+func MinOf(column string) Aggregate { return Aggregate{Func: AggregateMin, Column: column} }
+
+// MaxOf returns the largest value of column in each group
+func MaxOf(column string) Aggregate { return Aggregate{Func: AggregateMax, Column: column} }
+
+// As keys the aggregate in the results of GroupBy by alias
+func (a Aggregate) As(alias string) Aggregate {
+	a.Alias = alias
+	return a
+}
+
+// key returns the key of the aggregate in the results of GroupBy
+func (a Aggregate) key() string {
+	if a.Alias != "" {
+		return a.Alias
+	}
+	if a.Column == "" {
+		return strings.ToLower(string(a.Func))
+	}
+	return strings.ToLower(string(a.Func)) + "_" + strings.ReplaceAll(a.Column, ".", "_")
+}
+
+// aliasRegex matches the aliases of aggregates
+var aliasRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validate checks the function, column and alias of the aggregate
+func (a Aggregate) validate() error {
+	switch a.Func {
+	case AggregateCount, AggregateSum, AggregateAvg, AggregateMin, AggregateMax:
+	default:
+		return fmt.Errorf("unsupported aggregate function: %q", a.Func)
+	}
+	if a.Column == "" && a.Func != AggregateCount {
+		return fmt.Errorf("%s requires a column", a.Func)
+	}
+	if a.Column != "" && !columnNameRegex.MatchString(a.Column) {
+		return fmt.Errorf("invalid column: %q", a.Column)
+	}
+	if !aliasRegex.MatchString(a.key()) {
+		return fmt.Errorf("invalid alias: %q", a.Alias)
+	}
+	return nil
+}
+
+// SumByConditions sums a numeric column over the entities matching conds,
+// ignoring NULLs; the sum of no values is 0
+func (r *BaseRepository[T]) SumByConditions(ctx context.Context, column string, conds ...interface{}) (float64, error) {
+	return r.aggregateFloat(ctx, "SumByConditions", AggregateSum, column, conds)
+}
+
+// AvgByConditions averages a numeric column over the entities matching
+// conds, ignoring NULLs; the average of no values is 0
+func (r *BaseRepository[T]) AvgByConditions(ctx context.Context, column string, conds ...interface{}) (float64, error) {
+	return r.aggregateFloat(ctx, "AvgByConditions", AggregateAvg, column, conds)
+}
+
+// MinBy scans the smallest value of column over the entities matching conds
+// into dest, a pointer such as *int, *string or *time.Time. It fails with a
+// not found error when no entity has a value.
+func (r *BaseRepository[T]) MinBy(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	return r.aggregateValue(ctx, "MinBy", AggregateMin, column, dest, conds)
+}
+
+// MaxBy scans the largest value of column over the entities matching conds
+// into dest, a pointer such as *int, *string or *time.Time. It fails with a
+// not found error when no entity has a value.
+func (r *BaseRepository[T]) MaxBy(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	return r.aggregateValue(ctx, "MaxBy", AggregateMax, column, dest, conds)
+}
+
+// GroupBy computes aggregates per group of the entities matching conds, one
+// row per distinct combination of groupCols ordered by them. Rows are keyed
+// by the group columns and the aliases of the aggregates, with the values
+// returned by the driver (text as string):
+//
+//	rows, err := repo.GroupBy(ctx, []string{"status"},
+//		[]repository.Aggregate{repository.CountAll(), repository.SumOf("amount").As("total")},
+//		"created_at >= ?", since)
+//	// rows[0]["status"], rows[0]["count"], rows[0]["total"]
+//
+// Without group columns a single row aggregates all matching entities.
+func (r *BaseRepository[T]) GroupBy(ctx context.Context, groupCols []string, aggregates []Aggregate, conds ...interface{}) ([]map[string]interface{}, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "GroupBy", time.Since(start))
+	}()
+
+	if len(aggregates) == 0 {
+		r.metrics.IncrementOperationsFor("GroupBy", false)
+		return nil, r.argumentError("GroupBy", "at least one aggregate is required")
+	}
+	keys := make([]string, 0, len(groupCols)+len(aggregates))
+	selects := make([]string, 0, len(groupCols)+len(aggregates))
+	groups := make([]string, len(groupCols))
+	for i, column := range groupCols {
+		if !columnNameRegex.MatchString(column) {
+			r.metrics.IncrementOperationsFor("GroupBy", false)
+			return nil, r.argumentError("GroupBy", fmt.Sprintf("invalid group column: %q", column))
+		}
+		groups[i] = r.dialect.Quote(column)
+		keys = append(keys, column)
+		selects = append(selects, groups[i])
+	}
+	for _, aggregate := range aggregates {
+		if err := aggregate.validate(); err != nil {
+			r.metrics.IncrementOperationsFor("GroupBy", false)
+			return nil, r.argumentError("GroupBy", err.Error())
+		}
+		keys = append(keys, aggregate.key())
+		selects = append(selects, fmt.Sprintf("%s AS %s", r.aggregateExpression(aggregate), r.dialect.Quote(aggregate.key())))
+	}
+
+	query := r.readDB(ctx).Model(new(T)).Select(strings.Join(selects, ", "))
+	if len(groups) > 0 {
+		query = query.Group(strings.Join(groups, ", ")).Order(strings.Join(groups, ", "))
+	}
+	if len(conds) > 0 {
+		query = query.Where(conds[0], conds[1:]...)
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		r.metrics.IncrementOperationsFor("GroupBy", false)
+		return nil, r.wrapError(err, "GroupBy", "failed to aggregate entities by group")
+	}
+	defer rows.Close()
+
+	var results []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(keys))
+		dests := make([]interface{}, len(keys))
+		for i := range values {
+			dests[i] = &values[i]
+		}
+		if err := rows.Scan(dests...); err != nil {
+			r.metrics.IncrementOperationsFor("GroupBy", false)
+			return nil, r.wrapError(err, "GroupBy", "failed to scan group")
+		}
+		row := make(map[string]interface{}, len(keys))
+		for i, key := range keys {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			row[key] = values[i]
+		}
+		results = append(results, row)
+	}
+	if err := rows.Err(); err != nil {
+		r.metrics.IncrementOperationsFor("GroupBy", false)
+		return nil, r.wrapError(err, "GroupBy", "failed to read groups")
+	}
+
+	r.metrics.IncrementOperationsFor("GroupBy", true)
+	return results, nil
+}
+
+// aggregateExpression renders an aggregate as SQL
+func (r *BaseRepository[T]) aggregateExpression(aggregate Aggregate) string {
+	if aggregate.Column == "" {
+		return "COUNT(*)"
+	}
+	return fmt.Sprintf("%s(%s)", aggregate.Func, r.dialect.Quote(aggregate.Column))
+}
+
+// aggregateFloat computes a numeric aggregate of column over the entities
+// matching conds
+func (r *BaseRepository[T]) aggregateFloat(ctx context.Context, operation string, fn AggregateFunc, column string, conds []interface{}) (float64, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, operation, time.Since(start))
+	}()
+
+	if !columnNameRegex.MatchString(column) {
+		r.metrics.IncrementOperationsFor(operation, false)
+		return 0, r.argumentError(operation, fmt.Sprintf("invalid column: %q", column))
+	}
+
+	var value sql.NullFloat64
+	expression := r.aggregateExpression(Aggregate{Func: fn, Column: column})
+	if err := r.aggregateQuery(ctx, column, conds).Select(expression).Row().Scan(&value); err != nil {
+		r.metrics.IncrementOperationsFor(operation, false)
+		return 0, r.wrapError(err, operation, fmt.Sprintf("failed to compute %s of %s", strings.ToLower(string(fn)), column))
+	}
+
+	r.metrics.IncrementOperationsFor(operation, true)
+	return value.Float64, nil
+}
+
+// aggregateValue scans MIN or MAX of column over the entities matching
+// conds into dest
+func (r *BaseRepository[T]) aggregateValue(ctx context.Context, operation string, fn AggregateFunc, column string, dest interface{}, conds []interface{}) error {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, operation, time.Since(start))
+	}()
+
+	if !columnNameRegex.MatchString(column) {
+		r.metrics.IncrementOperationsFor(operation, false)
+		return r.argumentError(operation, fmt.Sprintf("invalid column: %q", column))
+	}
+	if dest == nil || reflect.ValueOf(dest).Kind() != reflect.Ptr || reflect.ValueOf(dest).IsNil() {
+		r.metrics.IncrementOperationsFor(operation, false)
+		return r.argumentError(operation, "destination must be a non-nil pointer")
+	}
+
+	var raw interface{}
+	expression := r.aggregateExpression(Aggregate{Func: fn, Column: column})
+	err := r.aggregateQuery(ctx, column, conds).Select(expression).Row().Scan(&raw)
+	if err == nil && raw == nil {
+		err = gorm.ErrRecordNotFound
+	}
+	if err == nil {
+		err = assignAggregate(raw, dest)
+	}
+	if err != nil {
+		r.metrics.IncrementOperationsFor(operation, false)
+		return r.wrapError(err, operation, fmt.Sprintf("failed to compute %s of %s", strings.ToLower(string(fn)), column))
+	}
+
+	r.metrics.IncrementOperationsFor(operation, true)
+	return nil
+}
+
+// assignAggregate stores an aggregated value in dest. Aggregates lose the
+// column types on some drivers, so timestamps returned as text are parsed
+// and numbers are converted to the type of dest.
+func assignAggregate(raw interface{}, dest interface{}) error {
+	if scanner, ok := dest.(sql.Scanner); ok {
+		return scanner.Scan(raw)
+	}
+	if b, ok := raw.([]byte); ok {
+		raw = string(b)
+	}
+
+	target := reflect.ValueOf(dest).Elem()
+	if _, ok := target.Interface().(time.Time); ok {
+		if text, ok := raw.(string); ok {
+			parsed, err := parseBucketTime(text)
+			if err != nil {
+				return err
+			}
+			raw = parsed
+		}
+	}
+
+	value := reflect.ValueOf(raw)
+	if target.Kind() == reflect.String {
+		value = reflect.ValueOf(fmt.Sprint(raw))
+	} else if text, ok := raw.(string); ok {
+		// Numbers returned as text, such as MySQL decimals
+		parsed, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return fmt.Errorf("cannot store %q in %s", text, target.Type())
+		}
+		value = reflect.ValueOf(parsed)
+	}
+	if !value.Type().ConvertibleTo(target.Type()) {
+		return fmt.Errorf("cannot store %T in %s", raw, target.Type())
+	}
+	target.Set(value.Convert(target.Type()))
+	return nil
+}
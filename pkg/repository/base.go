@@ -4,65 +4,118 @@ import (
 	"context"
 	"fmt"
 	"reflect"
-	"sync"
+	"strings"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/config"
+	"github.com/seasbee/go-ormx/pkg/dialect"
 	"github.com/seasbee/go-ormx/pkg/errors"
 	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/models"
 	"github.com/seasbee/go-validatorx"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
-// Repository represents a generic repository interface
-type Repository[T any] interface {
-	// Basic CRUD operations
-	Create(ctx context.Context, entity *T) error
-	CreateInBatches(ctx context.Context, entities []T, batchSize int) error
-
-	FindFirstByID(ctx context.Context, id uuid.UUID) (*T, error)
+// ReadRepository represents the reading operations of a repository, for
+// services that only query entities, such as reporting paths and replicas
+type ReadRepository[T any] interface {
+	FindFirstByID(ctx context.Context, id models.EntityID, opts ...FindOption) (*T, error)
+	FindAllByIDs(ctx context.Context, ids []models.EntityID, opts ...FindOption) (map[models.EntityID]*T, error)
+	FindFirstByKey(ctx context.Context, key map[string]interface{}, opts ...FindOption) (*T, error)
 	FindFirstByConditions(ctx context.Context, dest *T, conds ...interface{}) error
+	FindFirstBy(ctx context.Context, dest *T, column string, value interface{}) error
 	FirstOrInitByConditions(ctx context.Context, dest *T, conds ...interface{}) error
 
-	FindAllWithOffset(ctx context.Context, limit int, offset int, dest *[]T) error
-	FindAllInBatchesWithOffset(ctx context.Context, limit int, offset int, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error) error
+	FindAllWithOffset(ctx context.Context, limit int, offset int, dest *[]T, opts ...FindOption) error
+	FindAllInBatchesWithOffset(ctx context.Context, limit int, offset int, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, opts ...FindOption) error
 	FindAllByConditionsWithOffset(ctx context.Context, limit int, offset int, dest *[]T, conds ...interface{}) error
 	FindAllInBatchesByConditionsWithOffset(ctx context.Context, limit int, offset int, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, conds ...interface{}) error
 
-	FindAllWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T) error
-	FindAllInBatchesWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error) error
+	FindAllWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, opts ...FindOption) error
+	FindAllInBatchesWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, opts ...FindOption) error
 	FindAllByConditionsWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, conds ...interface{}) error
 	FindAllInBatchesByConditionsWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, conds ...interface{}) error
 
+	FindAllIncludingDeleted(ctx context.Context, limit int, offset int, dest *[]T, opts ...FindOption) error
+
+	ExistsByID(ctx context.Context, id models.EntityID) (bool, error)
+	ExistsByKey(ctx context.Context, key map[string]interface{}) (bool, error)
+	ExistsByConditions(ctx context.Context, conds ...interface{}) (bool, error)
+	CountByConditions(ctx context.Context, conds ...interface{}) (int64, error)
+	PluckByConditions(ctx context.Context, column string, dest interface{}, conds ...interface{}) error
+	DistinctPluckByConditions(ctx context.Context, column string, dest interface{}, conds ...interface{}) error
+
+	// Analytics
+	TimeSeriesCount(ctx context.Context, timeColumn string, interval dialect.TimeUnit, conds ...interface{}) ([]TimeBucketCount, error)
+	PercentileBy(ctx context.Context, column string, percentiles []float64, conds ...interface{}) ([]PercentileValue, error)
+	HistogramBy(ctx context.Context, column string, bounds []float64, conds ...interface{}) ([]HistogramBucket, error)
+	SumByConditions(ctx context.Context, column string, conds ...interface{}) (float64, error)
+	AvgByConditions(ctx context.Context, column string, conds ...interface{}) (float64, error)
+	MinBy(ctx context.Context, column string, dest interface{}, conds ...interface{}) error
+	MaxBy(ctx context.Context, column string, dest interface{}, conds ...interface{}) error
+	GroupBy(ctx context.Context, groupCols []string, aggregates []Aggregate, conds ...interface{}) ([]map[string]interface{}, error)
+	SampleByConditions(ctx context.Context, n int, strategy SampleStrategy, dest *[]T, conds ...interface{}) error
+	EstimateCount(ctx context.Context, conds ...interface{}) (int64, error)
+	TableStats(ctx context.Context) (*TableStats, error)
+
+	TakeByConditions(ctx context.Context, dest *T, conds ...interface{}) error
+	LastByConditions(ctx context.Context, dest *T, conds ...interface{}) error
+}
+
+// WriteRepository represents the mutating operations of a repository
+type WriteRepository[T any] interface {
+	Create(ctx context.Context, entity *T) error
+	CreateInBatches(ctx context.Context, entities []T, batchSize int) error
+	CreateInBatchesIgnoreConflicts(ctx context.Context, entities []T, batchSize int, conflictColumns ...string) (*IngestResult, error)
+	BulkInsertFast(ctx context.Context, entities []T) (*IngestResult, error)
+	FindOrCreateByConditions(ctx context.Context, dest *T, attrs interface{}, conds ...interface{}) (bool, error)
+
 	Update(ctx context.Context, entity *T) error
-	UpdateByID(ctx context.Context, entity *T, id uuid.UUID) error
+	UpdateByID(ctx context.Context, entity *T, id models.EntityID) error
 	UpdateByConditions(ctx context.Context, entity *T, conds ...interface{}) error
+	UpdateAllByConditions(ctx context.Context, values map[string]interface{}, conds ...interface{}) (int64, error)
+	UpdateIf(ctx context.Context, id models.EntityID, expected map[string]interface{}, updates map[string]interface{}) (bool, error)
 
-	Upsert(ctx context.Context, entity *T, conflictClause string) error
-	UpsertByID(ctx context.Context, entity *T, id uuid.UUID, conflictClause string) error
-	UpsertByConditions(ctx context.Context, entity *T, conflictClause string, conds ...interface{}) error
-	UpsertInBatches(ctx context.Context, entities []T, batchSize int, conflictClause string) error
-	UpsertInBatchesByConditions(ctx context.Context, entities []T, batchSize int, conflictClause string, conds ...interface{}) error
+	Upsert(ctx context.Context, entity *T, conflict ConflictOptions) error
+	UpsertByID(ctx context.Context, entity *T, id models.EntityID, conflict ConflictOptions) error
+	UpsertByConditions(ctx context.Context, entity *T, conflict ConflictOptions, conds ...interface{}) error
+	UpsertInBatches(ctx context.Context, entities []T, batchSize int, conflict ConflictOptions) error
+	UpsertInBatchesByConditions(ctx context.Context, entities []T, batchSize int, conflict ConflictOptions, conds ...interface{}) error
 
-	DeleteByID(ctx context.Context, id uuid.UUID) error
+	DeleteByID(ctx context.Context, id models.EntityID) error
+	DeleteByKey(ctx context.Context, key map[string]interface{}) error
 	DeleteByConditions(ctx context.Context, entity *T, conds ...interface{}) error
+	DeleteAllByConditions(ctx context.Context, conds ...interface{}) (int64, error)
 	DeleteInBatches(ctx context.Context, entities []T, batchSize int) error
 	DeleteInBatchesByConditions(ctx context.Context, entities []T, batchSize int, conds ...interface{}) error
 
-	ExistsByID(ctx context.Context, id uuid.UUID) (bool, error)
-	ExistsByConditions(ctx context.Context, conds ...interface{}) (bool, error)
-	CountByConditions(ctx context.Context, conds ...interface{}) (int64, error)
+	// Soft delete
+	SoftDeleteByID(ctx context.Context, id models.EntityID) error
+	SoftDeleteByConditions(ctx context.Context, conds ...interface{}) (int64, error)
+	RestoreByID(ctx context.Context, id models.EntityID) error
+}
 
-	TakeByConditions(ctx context.Context, dest *T, conds ...interface{}) error
-	LastByConditions(ctx context.Context, dest *T, conds ...interface{}) error
+// Repository represents a generic repository interface
+type Repository[T any] interface {
+	ReadRepository[T]
+	WriteRepository[T]
 
 	Begin(ctx context.Context) (*gorm.DB, error)
 	Commit(ctx context.Context) error
 	Rollback(ctx context.Context) error
+	SavePoint(ctx context.Context, name string) error
+	NewSavePoint(ctx context.Context) (string, error)
+	RollbackTo(ctx context.Context, name string) error
 
 	// Advanced operations
 	WithTransaction(ctx context.Context, fn func(Repository[T]) error) error
+	WithTransactionOpts(ctx context.Context, opts TxOptions, fn func(ctx context.Context, tx Repository[T]) error) error
+	WithTx(ctx context.Context) Repository[T]
+
+	// Transaction hooks
+	AfterCommit(ctx context.Context, fn TxHook)
+	AfterRollback(ctx context.Context, fn TxHook)
 }
 
 // RepositoryConfig represents repository configuration
@@ -71,6 +124,71 @@ type RepositoryConfig struct {
 	EnableMetrics    bool `json:"enable_metrics"`
 	DefaultLimit     int  `json:"default_limit"`
 	MaxLimit         int  `json:"max_limit"`
+
+	// Dialect overrides the SQL dialect detected from the connection
+	// (e.g. "cockroachdb", which connects through the postgres driver)
+	Dialect string `json:"dialect,omitempty"`
+	// MaxTransactionRetries bounds client-side retries of serialization failures
+	// for dialects using the CockroachDB retry protocol
+	MaxTransactionRetries int `json:"max_transaction_retries"`
+	// MaxSampleScanRows bounds the rows sorted randomly or scanned by
+	// SampleByConditions, which refuses to sample more matching rows
+	MaxSampleScanRows int `json:"max_sample_scan_rows"`
+	// MaxIDsPerQuery bounds the IDs of each IN clause of FindAllByIDs (1000
+	// when not set)
+	MaxIDsPerQuery int `json:"max_ids_per_query"`
+	// MaxResultSize bounds the bytes of the values loaded by
+	// PluckByConditions, usually DatabaseConfig.MaxResultSize; zero
+	// disables it
+	MaxResultSize int `json:"max_result_size"`
+	// SortableColumns restricts the columns WithOrderBy may sort by; empty
+	// allows every column of the entity
+	SortableColumns []string `json:"sortable_columns,omitempty"`
+	// DeleteMode selects soft ("soft") or hard ("hard") deletes for Delete*
+	// methods; empty follows the model (soft only for gorm.DeletedAt fields)
+	DeleteMode string `json:"delete_mode,omitempty"`
+	// Cache enables the by-ID entity cache, nil disables it (see CacheConfig)
+	Cache *CacheConfig `json:"cache,omitempty"`
+	// Retry retries statements failing with transient errors outside
+	// transactions, usually DatabaseConfig.Retry; nil disables retries
+	Retry *config.RetryConfig `json:"retry,omitempty"`
+	// QueryTimeout bounds each statement of the repository, cancelling its
+	// context once exceeded, usually DatabaseConfig.QueryTimeout; statements
+	// whose context expires sooner keep their deadline. Zero disables it.
+	QueryTimeout time.Duration `json:"query_timeout"`
+	// TransactionTimeout bounds the transactions begun by the repository,
+	// from BEGIN to COMMIT, usually DatabaseConfig.TransactionTimeout.
+	// Zero disables it.
+	TransactionTimeout time.Duration `json:"transaction_timeout"`
+	// SlowQueryThreshold is the duration above which the statements of the
+	// repository are logged as slow; zero uses the logger default
+	SlowQueryThreshold time.Duration `json:"slow_query_threshold"`
+	// ExplainSlowQueries captures the plans of sampled slow statements (see
+	// ExplainConfig); nil disables it
+	ExplainSlowQueries *ExplainConfig `json:"explain_slow_queries,omitempty"`
+
+	// SkipDefaultTransaction runs single creates, updates and deletes
+	// without the transaction gorm wraps them in, saving a round trip each.
+	// CreateInBatches still opens one, so its batches stay all or none.
+	SkipDefaultTransaction bool `json:"skip_default_transaction"`
+	// FullSaveAssociations updates the associated records saved with an
+	// entity instead of only inserting missing ones; off by default
+	FullSaveAssociations bool `json:"full_save_associations"`
+	// QueryFields selects the model's columns by name instead of with *
+	QueryFields bool `json:"query_fields"`
+	// CreateBatchSize splits the inserts of slices, including associations,
+	// into statements of at most that many rows; zero inserts them at once
+	CreateBatchSize int `json:"create_batch_size"`
+}
+
+// session returns the gorm session options of the configuration
+func (c *RepositoryConfig) session() *gorm.Session {
+	return &gorm.Session{
+		SkipDefaultTransaction: c.SkipDefaultTransaction,
+		FullSaveAssociations:   c.FullSaveAssociations,
+		QueryFields:            c.QueryFields,
+		CreateBatchSize:        c.CreateBatchSize,
+	}
 }
 
 // DefaultRepositoryConfig returns default repository configuration
@@ -80,74 +198,13 @@ func DefaultRepositoryConfig() *RepositoryConfig {
 		EnableMetrics:    true,
 		DefaultLimit:     20,
 		MaxLimit:         1000,
-	}
-}
-
-// RepositoryMetrics represents repository metrics
-type RepositoryMetrics struct {
-	TotalOperations      int64         `json:"total_operations"`
-	SuccessfulOperations int64         `json:"successful_operations"`
-	FailedOperations     int64         `json:"failed_operations"`
-	AverageQueryTime     time.Duration `json:"average_query_time"`
-	LastReset            time.Time     `json:"last_reset"`
-	mu                   sync.RWMutex
-}
 
-// NewRepositoryMetrics creates new repository metrics
-func NewRepositoryMetrics() *RepositoryMetrics {
-	return &RepositoryMetrics{
-		LastReset: time.Now(),
+		MaxTransactionRetries: 5,
+		MaxSampleScanRows:     100000,
+		SlowQueryThreshold:    time.Second,
 	}
 }
 
-// IncrementOperations increments operation counters
-func (rm *RepositoryMetrics) IncrementOperations(success bool) {
-	rm.mu.Lock()
-	defer rm.mu.Unlock()
-
-	rm.TotalOperations++
-	if success {
-		rm.SuccessfulOperations++
-	} else {
-		rm.FailedOperations++
-	}
-}
-
-// RecordQueryTime records query execution time
-func (rm *RepositoryMetrics) RecordQueryTime(duration time.Duration) {
-	rm.mu.Lock()
-	defer rm.mu.Unlock()
-
-	if rm.AverageQueryTime == 0 {
-		rm.AverageQueryTime = duration
-	} else {
-		rm.AverageQueryTime = (rm.AverageQueryTime + duration) / 2
-	}
-}
-
-// Reset resets all metrics
-func (rm *RepositoryMetrics) Reset() {
-	rm.mu.Lock()
-	defer rm.mu.Unlock()
-
-	rm.TotalOperations = 0
-	rm.SuccessfulOperations = 0
-	rm.FailedOperations = 0
-	rm.AverageQueryTime = 0
-	rm.LastReset = time.Now()
-}
-
-// GetSuccessRate returns operation success rate
-func (rm *RepositoryMetrics) GetSuccessRate() float64 {
-	rm.mu.RLock()
-	defer rm.mu.RUnlock()
-
-	if rm.TotalOperations == 0 {
-		return 0
-	}
-	return float64(rm.SuccessfulOperations) / float64(rm.TotalOperations)
-}
-
 // BaseRepository provides a base implementation of the Repository interface
 type BaseRepository[T any] struct {
 	db        *gorm.DB
@@ -156,6 +213,27 @@ type BaseRepository[T any] struct {
 	metrics   *RepositoryMetrics
 	tableName string
 	modelType reflect.Type
+	dialect   dialect.Dialect
+	router    ReadRouter
+
+	softDelete softDeleteKind
+	hooks      *txHooks
+	references *deferredReferences
+	lookups    *lookupStatements
+	cache      *entityCache[T]
+
+	changeHooks     []ChangeHook[T]
+	validators      []EntityValidator[T]
+	batchValidators []BatchValidator[T]
+	dependencies    *DependencyGraph
+	queryCache      *QueryCache
+	observer        *queryObserver
+}
+
+// ReadRouter routes read-only operations to read replicas. It is implemented
+// by database.ConnectionManager.
+type ReadRouter interface {
+	ReadDB(ctx context.Context) *gorm.DB
 }
 
 // NewBaseRepository creates a new base repository
@@ -172,14 +250,54 @@ func NewBaseRepository[T any](db *gorm.DB, logger logging.Logger, config *Reposi
 
 	tableName := getTableName(entity)
 
-	return &BaseRepository[T]{
-		db:        db,
+	sqlDialect := dialect.For(db)
+	if config.Dialect != "" {
+		if override, ok := dialect.Get(config.Dialect); ok {
+			sqlDialect = override
+		}
+	}
+
+	threshold := config.SlowQueryThreshold
+	if threshold <= 0 {
+		threshold = logging.DefaultLoggerConfig().SlowQueryThreshold
+	}
+
+	r := &BaseRepository[T]{
 		logger:    logger,
 		config:    config,
 		metrics:   NewRepositoryMetrics(),
 		tableName: tableName,
 		modelType: modelType,
+		dialect:   sqlDialect,
+
+		softDelete: detectSoftDelete(modelType),
+		lookups:    &lookupStatements{},
+		cache:      newEntityCache[T](config.Cache),
+		observer:   &queryObserver{table: tableName, threshold: threshold, logger: logger},
 	}
+	r.observer.explainer = newPlanExplainer(config.ExplainSlowQueries, sqlDialect, db)
+	r.db = r.observe(db)
+	return r
+}
+
+// followerReadKey is the context key for follower read staleness
+type followerReadKey struct{}
+
+// WithFollowerRead marks read operations executed with the returned context as
+// tolerant to bounded staleness, allowing dialects that support it (CockroachDB)
+// to serve them from the nearest replica via AS OF SYSTEM TIME. A zero staleness
+// uses the most recent timestamp that is safe for follower reads.
+func WithFollowerRead(ctx context.Context, staleness time.Duration) context.Context {
+	return context.WithValue(ctx, followerReadKey{}, staleness)
+}
+
+// followerReadFromContext returns the follower read staleness carried by ctx
+func followerReadFromContext(ctx context.Context) (time.Duration, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	staleness, ok := ctx.Value(followerReadKey{}).(time.Duration)
+	return staleness, ok
 }
 
 // getTableName extracts table name from entity
@@ -201,79 +319,114 @@ func getTableName(entity interface{}) string {
 func (r *BaseRepository[T]) Create(ctx context.Context, entity *T) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "Create", time.Since(start))
 	}()
 
 	// Validate entity if enabled
 	if r.config.EnableValidation {
 		if result, err := r.Validate(ctx, entity); err != nil {
-			r.metrics.IncrementOperations(false)
-			return fmt.Errorf("validation failed: %w", err)
+			r.metrics.IncrementOperationsFor("Create", false)
+			return r.wrapError(err, "Create", "validation failed")
 		} else if !result.Valid {
-			r.metrics.IncrementOperations(false)
-			return errors.New(errors.ErrorTypeValidation, fmt.Sprintf("validation failed: %v", result.Errors))
+			r.metrics.IncrementOperationsFor("Create", false)
+			return r.validationError(ctx, "Create", result)
 		}
 	}
 
 	// Create entity
-	if err := r.db.WithContext(ctx).Create(entity).Error; err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to create entity: %w", err)
+	if err := r.retry(ctx, "Create", func() error {
+		return r.db.WithContext(ctx).Create(entity).Error
+	}); err != nil {
+		r.metrics.IncrementOperationsFor("Create", false)
+		return r.wrapError(err, "Create", "failed to create entity")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.deferReferences(ctx, entity)
+
+	r.cacheWritten(ctx, entity)
+	if err := r.notifyChange(ctx, ChangeCreate, r.getEntityID(entity), nil, entity); err != nil {
+		r.metrics.IncrementOperationsFor("Create", false)
+		return err
+	}
+	r.metrics.IncrementOperationsFor("Create", true)
 	r.logger.Info(ctx, "Entity created successfully",
 		logging.String("table", r.tableName),
-		logging.String("id", r.getEntityID(entity).String()))
+		logging.String("id", idString(r.getEntityID(entity))))
 
 	return nil
 }
 
-// CreateInBatches creates multiple entities in batches
+// CreateInBatches creates multiple entities in batches, all or none; a
+// batchSize of zero uses the configured CreateBatchSize. With a context from
+// WithBatchProgress, the batches are committed one at a time instead.
 func (r *BaseRepository[T]) CreateInBatches(ctx context.Context, entities []T, batchSize int) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "CreateInBatches", time.Since(start))
 	}()
 
+	if batchSize <= 0 {
+		batchSize = r.config.CreateBatchSize
+	}
+
 	// Validate entity if enabled
 	if r.config.EnableValidation {
 		if entities == nil {
-			r.metrics.IncrementOperations(false)
-			return fmt.Errorf("entities cannot be nil")
+			r.metrics.IncrementOperationsFor("CreateInBatches", false)
+			return r.argumentError("CreateInBatches", "entities cannot be nil")
 		}
 
 		if len(entities) == 0 {
-			r.metrics.IncrementOperations(false)
-			return fmt.Errorf("entities cannot be empty")
+			r.metrics.IncrementOperationsFor("CreateInBatches", false)
+			return r.argumentError("CreateInBatches", "entities cannot be empty")
 		}
 
 		// Validate batch size
 		if batchSize <= 0 {
-			r.metrics.IncrementOperations(false)
-			return fmt.Errorf("batch size must be greater than 0, got %d", batchSize)
+			r.metrics.IncrementOperationsFor("CreateInBatches", false)
+			return r.argumentError("CreateInBatches", fmt.Sprintf("batch size must be greater than 0, got %d", batchSize))
 		}
+	}
 
-		// Validate each entity in the batch
-		for i, entity := range entities {
-			if result, err := r.Validate(ctx, &entity); err != nil {
-				r.metrics.IncrementOperations(false)
-				return fmt.Errorf("validation failed for entity %d: %w", i, err)
-			} else if !result.Valid {
-				r.metrics.IncrementOperations(false)
-				return errors.New(errors.ErrorTypeValidation,
-					fmt.Sprintf("validation failed for entity %d: %v", i, result.Errors))
-			}
+	// Validate each entity and the whole batch
+	if err := r.validateBatch(ctx, "CreateInBatches", entities, true); err != nil {
+		r.metrics.IncrementOperationsFor("CreateInBatches", false)
+		return err
+	}
+
+	if progress, ok := batchProgressFrom(ctx); ok {
+		return r.createInResumableBatches(ctx, entities, batchSize, progress)
+	}
+
+	// Create entities. gorm only wraps the batches in a transaction when its
+	// default transaction is on.
+	if err := r.retry(ctx, "CreateInBatches", func() error {
+		db := r.db.WithContext(ctx)
+		if db.SkipDefaultTransaction && !isTransaction(db) {
+			return db.Transaction(func(tx *gorm.DB) error {
+				return tx.CreateInBatches(entities, batchSize).Error
+			})
 		}
+		return db.CreateInBatches(entities, batchSize).Error
+	}); err != nil {
+		r.metrics.IncrementOperationsFor("CreateInBatches", false)
+		return r.wrapError(err, "CreateInBatches", "failed to create entities")
 	}
 
-	// Create entities
-	if err := r.db.WithContext(ctx).CreateInBatches(entities, batchSize).Error; err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to create entities: %w", err)
+	written := make([]*T, len(entities))
+	for i := range entities {
+		r.deferReferences(ctx, &entities[i])
+		written[i] = &entities[i]
+	}
+	r.cacheWritten(ctx, written...)
+	for _, entity := range written {
+		if err := r.notifyChange(ctx, ChangeCreate, r.getEntityID(entity), nil, entity); err != nil {
+			r.metrics.IncrementOperationsFor("CreateInBatches", false)
+			return err
+		}
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.metrics.IncrementOperationsFor("CreateInBatches", true)
 	r.logger.Info(ctx, "Entities created successfully",
 		logging.String("table", r.tableName),
 		logging.Int("batch_size", batchSize),
@@ -282,43 +435,188 @@ func (r *BaseRepository[T]) CreateInBatches(ctx context.Context, entities []T, b
 	return nil
 }
 
-// FindFirstByID finds entity by ID
-func (r *BaseRepository[T]) FindFirstByID(ctx context.Context, id uuid.UUID) (*T, error) {
+// IngestResult reports the rows inserted and skipped by
+// CreateInBatchesIgnoreConflicts and BulkInsertFast, which loads them in one
+// batch and reports no Batches
+type IngestResult struct {
+	Inserted int64         `json:"inserted"`
+	Skipped  int64         `json:"skipped"`
+	Batches  []BatchResult `json:"batches"`
+}
+
+// BatchResult reports the rows inserted and skipped by one batch
+type BatchResult struct {
+	Batch    int   `json:"batch"`
+	Inserted int64 `json:"inserted"`
+	Skipped  int64 `json:"skipped"`
+}
+
+// CreateInBatchesIgnoreConflicts inserts entities in batches, silently
+// skipping those conflicting with existing rows on conflictColumns (any
+// unique key when empty, as supported by the dialect), for idempotent
+// ingestion: ON CONFLICT DO NOTHING on Postgres and SQLite, INSERT IGNORE
+// semantics on MySQL. Each batch is a separate statement, so the batches
+// inserted before a failing one are kept; the result covers them. The IDs
+// assigned to skipped entities do not exist in the database. A batchSize of
+// zero uses the configured CreateBatchSize.
+func (r *BaseRepository[T]) CreateInBatchesIgnoreConflicts(ctx context.Context, entities []T, batchSize int, conflictColumns ...string) (*IngestResult, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "CreateInBatchesIgnoreConflicts", time.Since(start))
+	}()
+
+	if batchSize <= 0 {
+		batchSize = r.config.CreateBatchSize
+	}
+
+	if len(entities) == 0 {
+		r.metrics.IncrementOperationsFor("CreateInBatchesIgnoreConflicts", false)
+		return nil, r.argumentError("CreateInBatchesIgnoreConflicts", "entities cannot be empty")
+	}
+
+	// Validate batch size
+	if batchSize <= 0 {
+		r.metrics.IncrementOperationsFor("CreateInBatchesIgnoreConflicts", false)
+		return nil, r.argumentError("CreateInBatchesIgnoreConflicts", fmt.Sprintf("batch size must be greater than 0, got %d", batchSize))
+	}
+
+	// Validate each entity and the whole batch
+	if err := r.validateBatch(ctx, "CreateInBatchesIgnoreConflicts", entities, true); err != nil {
+		r.metrics.IncrementOperationsFor("CreateInBatchesIgnoreConflicts", false)
+		return nil, err
+	}
+
+	onConflict := clause.OnConflict{DoNothing: true}
+	for _, column := range conflictColumns {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: column})
+	}
+
+	ingested := &IngestResult{}
+	for offset, batch := 0, 0; offset < len(entities); offset, batch = offset+batchSize, batch+1 {
+		end := offset + batchSize
+		if end > len(entities) {
+			end = len(entities)
+		}
+		rows := entities[offset:end]
+
+		var existing map[string]bool
+		if len(r.changeHooks) > 0 {
+			var err error
+			if existing, err = r.storedIDs(ctx, rows); err != nil {
+				r.metrics.IncrementOperationsFor("CreateInBatchesIgnoreConflicts", false)
+				return ingested, r.wrapError(err, "CreateInBatchesIgnoreConflicts", fmt.Sprintf("failed to read entities of batch %d", batch))
+			}
+		}
+
+		var inserted int64
+		err := r.retry(ctx, "CreateInBatchesIgnoreConflicts", func() error {
+			result := r.db.WithContext(ctx).Clauses(onConflict).Create(&rows)
+			inserted = result.RowsAffected
+			return result.Error
+		})
+		if err != nil {
+			r.metrics.IncrementOperationsFor("CreateInBatchesIgnoreConflicts", false)
+			return ingested, r.wrapError(err, "CreateInBatchesIgnoreConflicts", fmt.Sprintf("failed to create entities of batch %d", batch))
+		}
+
+		skipped := int64(len(rows)) - inserted
+		ingested.Inserted += inserted
+		ingested.Skipped += skipped
+		ingested.Batches = append(ingested.Batches, BatchResult{Batch: batch, Inserted: inserted, Skipped: skipped})
+		for i := range rows {
+			r.deferReferences(ctx, &rows[i])
+		}
+		if err := r.notifyInserted(ctx, rows, inserted, existing); err != nil {
+			r.metrics.IncrementOperationsFor("CreateInBatchesIgnoreConflicts", false)
+			return ingested, err
+		}
+	}
+
+	if ingested.Inserted > 0 {
+		r.invalidateDependents(ctx)
+		r.queryCacheInvalidate(ctx)
+	}
+	r.metrics.IncrementOperationsFor("CreateInBatchesIgnoreConflicts", true)
+	r.logger.Info(ctx, "Entities ingested successfully",
+		logging.String("table", r.tableName),
+		logging.Int("batch_size", batchSize),
+		logging.Int64("inserted", ingested.Inserted),
+		logging.Int64("skipped", ingested.Skipped))
+
+	return ingested, nil
+}
+
+// FindFirstByID finds entity by ID, loading it as configured by opts
+func (r *BaseRepository[T]) FindFirstByID(ctx context.Context, id models.EntityID, opts ...FindOption) (*T, error) {
+	id = normalizeID(id, keyTypeOf[T]())
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "FindFirstByID", time.Since(start))
 	}()
 
+	if len(opts) == 0 {
+		if cached, ok := r.cacheRead(ctx, id); ok {
+			r.metrics.IncrementOperationsFor("FindFirstByID", true)
+			return cached, nil
+		}
+	}
+
 	var entity T
-	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&entity).Error; err != nil {
-		r.metrics.IncrementOperations(false)
-		return nil, fmt.Errorf("failed to find entity by ID: %w", err)
+	if err := r.retry(ctx, "FindFirstByID", func() error {
+		return r.findDB(ctx, opts).Where(clause.Eq{Column: idColumn, Value: id}).First(&entity).Error
+	}); err != nil {
+		r.metrics.IncrementOperationsFor("FindFirstByID", false)
+		return nil, r.wrapError(err, "FindFirstByID", "failed to find entity by ID")
+	}
+	if len(opts) == 0 {
+		r.cacheLoaded(ctx, &entity)
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.metrics.IncrementOperationsFor("FindFirstByID", true)
 	return &entity, nil
 }
 
-// FindFirstByConditions finds first entity by conditions
-func (r *BaseRepository[T]) FindFirstByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
+// FindFirstByIDForUpdate finds entity by ID and locks the row until the
+// surrounding transaction ends. Soft deleted entities are not found unless
+// the context is from WithDeleted.
+func (r *BaseRepository[T]) FindFirstByIDForUpdate(ctx context.Context, id models.EntityID) (*T, error) {
+	id = normalizeID(id, keyTypeOf[T]())
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "FindFirstByIDForUpdate", time.Since(start))
 	}()
 
-	var err error
-	if len(conds) == 0 {
-		err = r.db.WithContext(ctx).First(dest).Error
-	} else {
-		err = r.db.WithContext(ctx).Where(conds[0], conds[1:]...).First(dest).Error
+	var entity T
+	query := r.lockForUpdate(r.scopeDeleted(ctx, r.db.WithContext(ctx)))
+	if err := query.Where(clause.Eq{Column: idColumn, Value: id}).First(&entity).Error; err != nil {
+		r.metrics.IncrementOperationsFor("FindFirstByIDForUpdate", false)
+		return nil, r.wrapError(err, "FindFirstByIDForUpdate", "failed to find entity by ID for update")
 	}
 
+	r.metrics.IncrementOperationsFor("FindFirstByIDForUpdate", true)
+	return &entity, nil
+}
+
+// FindFirstByConditions finds first entity by conditions
+func (r *BaseRepository[T]) FindFirstByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "FindFirstByConditions", time.Since(start))
+	}()
+
+	conds, opts := splitFindOptions(conds)
+	err := r.retry(ctx, "FindFirstByConditions", func() error {
+		if len(conds) == 0 {
+			return r.findDB(ctx, opts).First(dest).Error
+		}
+		return r.findDB(ctx, opts).Where(conds[0], conds[1:]...).First(dest).Error
+	})
 	if err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to find entity by conditions: %w", err)
+		r.metrics.IncrementOperationsFor("FindFirstByConditions", false)
+		return r.wrapError(err, "FindFirstByConditions", "failed to find entity by conditions")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.metrics.IncrementOperationsFor("FindFirstByConditions", true)
 	return nil
 }
 
@@ -326,64 +624,66 @@ func (r *BaseRepository[T]) FindFirstByConditions(ctx context.Context, dest *T,
 func (r *BaseRepository[T]) FirstOrInitByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "FirstOrInitByConditions", time.Since(start))
 	}()
 
-	var err error
-	if len(conds) == 0 {
-		err = r.db.WithContext(ctx).FirstOrInit(dest).Error
-	} else {
-		err = r.db.WithContext(ctx).Where(conds[0], conds[1:]...).FirstOrInit(dest).Error
-	}
-
+	conds, opts := splitFindOptions(conds)
+	err := r.retry(ctx, "FirstOrInitByConditions", func() error {
+		if len(conds) == 0 {
+			return r.findDB(ctx, opts).FirstOrInit(dest).Error
+		}
+		return r.findDB(ctx, opts).Where(conds[0], conds[1:]...).FirstOrInit(dest).Error
+	})
 	if err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to find entity by conditions: %w", err)
+		r.metrics.IncrementOperationsFor("FirstOrInitByConditions", false)
+		return r.wrapError(err, "FirstOrInitByConditions", "failed to find entity by conditions")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.metrics.IncrementOperationsFor("FirstOrInitByConditions", true)
 	return nil
 }
 
 // FindAllWithOffset finds all entities
-func (r *BaseRepository[T]) FindAllWithOffset(ctx context.Context, limit int, offset int, dest *[]T) error {
+func (r *BaseRepository[T]) FindAllWithOffset(ctx context.Context, limit int, offset int, dest *[]T, opts ...FindOption) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "FindAllWithOffset", time.Since(start))
 	}()
 
 	limit, offset = r.validateOffsetPaginationParams(limit, offset)
 
-	if err := r.db.WithContext(ctx).Limit(limit).Offset(offset).Find(dest).Error; err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to find all entities: %w", err)
+	if err := r.retry(ctx, "FindAllWithOffset", func() error {
+		return r.paginate(r.findDB(ctx, opts), limit, offset).Find(dest).Error
+	}); err != nil {
+		r.metrics.IncrementOperationsFor("FindAllWithOffset", false)
+		return r.wrapError(err, "FindAllWithOffset", "failed to find all entities")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.metrics.IncrementOperationsFor("FindAllWithOffset", true)
 	return nil
 }
 
 // FindAllInBatchesWithOffset finds all entities in batches
-func (r *BaseRepository[T]) FindAllInBatchesWithOffset(ctx context.Context, limit int, offset int, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error) error {
+func (r *BaseRepository[T]) FindAllInBatchesWithOffset(ctx context.Context, limit int, offset int, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, opts ...FindOption) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "FindAllInBatchesWithOffset", time.Since(start))
 	}()
 
 	limit, offset = r.validateOffsetPaginationParams(limit, offset)
 
 	// Validate batch size
 	if batchSize <= 0 {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("batch size must be greater than 0, got %d", batchSize)
+		r.metrics.IncrementOperationsFor("FindAllInBatchesWithOffset", false)
+		return r.argumentError("FindAllInBatchesWithOffset", fmt.Sprintf("batch size must be greater than 0, got %d", batchSize))
 	}
 
-	if err := r.db.WithContext(ctx).Limit(limit).Offset(offset).FindInBatches(dest, batchSize, fc).Error; err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to find all entities in batches: %w", err)
+	if err := r.findUnorderedDB(ctx, opts, "FindAllInBatchesWithOffset").Limit(limit).Offset(offset).FindInBatches(dest, batchSize, fc).Error; err != nil {
+		r.metrics.IncrementOperationsFor("FindAllInBatchesWithOffset", false)
+		return r.wrapError(err, "FindAllInBatchesWithOffset", "failed to find all entities in batches")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.metrics.IncrementOperationsFor("FindAllInBatchesWithOffset", true)
 	return nil
 }
 
@@ -391,24 +691,24 @@ func (r *BaseRepository[T]) FindAllInBatchesWithOffset(ctx context.Context, limi
 func (r *BaseRepository[T]) FindAllByConditionsWithOffset(ctx context.Context, limit int, offset int, dest *[]T, conds ...interface{}) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "FindAllByConditionsWithOffset", time.Since(start))
 	}()
 
 	limit, offset = r.validateOffsetPaginationParams(limit, offset)
 
-	var err error
-	if len(conds) == 0 {
-		err = r.db.WithContext(ctx).Limit(limit).Offset(offset).Find(dest).Error
-	} else {
-		err = r.db.WithContext(ctx).Limit(limit).Offset(offset).Where(conds[0], conds[1:]...).Find(dest).Error
-	}
-
+	conds, opts := splitFindOptions(conds)
+	err := r.retry(ctx, "FindAllByConditionsWithOffset", func() error {
+		if len(conds) == 0 {
+			return r.paginate(r.findDB(ctx, opts), limit, offset).Find(dest).Error
+		}
+		return r.paginate(r.findDB(ctx, opts), limit, offset).Where(conds[0], conds[1:]...).Find(dest).Error
+	})
 	if err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to find all entities by conditions: %w", err)
+		r.metrics.IncrementOperationsFor("FindAllByConditionsWithOffset", false)
+		return r.wrapError(err, "FindAllByConditionsWithOffset", "failed to find all entities by conditions")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.metrics.IncrementOperationsFor("FindAllByConditionsWithOffset", true)
 	return nil
 }
 
@@ -416,98 +716,102 @@ func (r *BaseRepository[T]) FindAllByConditionsWithOffset(ctx context.Context, l
 func (r *BaseRepository[T]) FindAllInBatchesByConditionsWithOffset(ctx context.Context, limit int, offset int, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, conds ...interface{}) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "FindAllInBatchesByConditionsWithOffset", time.Since(start))
 	}()
 
 	limit, offset = r.validateOffsetPaginationParams(limit, offset)
 
 	// Validate batch size
 	if batchSize <= 0 {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("batch size must be greater than 0, got %d", batchSize)
+		r.metrics.IncrementOperationsFor("FindAllInBatchesByConditionsWithOffset", false)
+		return r.argumentError("FindAllInBatchesByConditionsWithOffset", fmt.Sprintf("batch size must be greater than 0, got %d", batchSize))
 	}
 
+	conds, opts := splitFindOptions(conds)
 	var err error
 	if len(conds) == 0 {
-		err = r.db.WithContext(ctx).Limit(limit).Offset(offset).FindInBatches(dest, batchSize, fc).Error
+		err = r.findUnorderedDB(ctx, opts, "FindAllInBatchesByConditionsWithOffset").Limit(limit).Offset(offset).FindInBatches(dest, batchSize, fc).Error
 	} else {
-		err = r.db.WithContext(ctx).Limit(limit).Offset(offset).Where(conds[0], conds[1:]...).FindInBatches(dest, batchSize, fc).Error
+		err = r.findUnorderedDB(ctx, opts, "FindAllInBatchesByConditionsWithOffset").Limit(limit).Offset(offset).Where(conds[0], conds[1:]...).FindInBatches(dest, batchSize, fc).Error
 	}
 
 	if err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to find all entities in batches by conditions: %w", err)
+		r.metrics.IncrementOperationsFor("FindAllInBatchesByConditionsWithOffset", false)
+		return r.wrapError(err, "FindAllInBatchesByConditionsWithOffset", "failed to find all entities in batches by conditions")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.metrics.IncrementOperationsFor("FindAllInBatchesByConditionsWithOffset", true)
 	return nil
 }
 
 // FindAllWithCursor finds all entities
-func (r *BaseRepository[T]) FindAllWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T) error {
+func (r *BaseRepository[T]) FindAllWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, opts ...FindOption) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "FindAllWithCursor", time.Since(start))
 	}()
 
 	cursor, limit, direction = r.validateCursorPaginationParams(cursor, limit, direction)
 
-	// Build query with cursor
-	query := r.db.WithContext(ctx).Limit(limit)
-
-	if cursor != "" {
-		// For cursor-based pagination, we need to know the cursor field
-		// This is a simplified implementation - in practice, you'd need to specify the cursor field
-		if direction == "next" {
-			query = query.Where("id > ?", cursor)
-		} else {
-			query = query.Where("id < ?", cursor)
+	err := r.retry(ctx, "FindAllWithCursor", func() error {
+		// Build query with cursor
+		query := r.findUnorderedDB(ctx, opts, "FindAllWithCursor").Limit(limit)
+
+		if cursor != "" {
+			// For cursor-based pagination, we need to know the cursor field
+			// This is a simplified implementation - in practice, you'd need to specify the cursor field
+			if direction == "next" {
+				query = query.Where(clause.Gt{Column: idColumn, Value: cursor})
+			} else {
+				query = query.Where(clause.Lt{Column: idColumn, Value: cursor})
+			}
 		}
-	}
 
-	if err := query.Find(dest).Error; err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to find all entities: %w", err)
+		return query.Find(dest).Error
+	})
+	if err != nil {
+		r.metrics.IncrementOperationsFor("FindAllWithCursor", false)
+		return r.wrapError(err, "FindAllWithCursor", "failed to find all entities")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.metrics.IncrementOperationsFor("FindAllWithCursor", true)
 	return nil
 }
 
 // FindAllInBatchesWithCursor finds all entities in batches
-func (r *BaseRepository[T]) FindAllInBatchesWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error) error {
+func (r *BaseRepository[T]) FindAllInBatchesWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, opts ...FindOption) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "FindAllInBatchesWithCursor", time.Since(start))
 	}()
 
 	cursor, limit, direction = r.validateCursorPaginationParams(cursor, limit, direction)
 
 	// Build query with cursor
-	query := r.db.WithContext(ctx).Limit(limit)
+	query := r.findUnorderedDB(ctx, opts, "FindAllInBatchesWithCursor").Limit(limit)
 
 	if cursor != "" {
 		// For cursor-based pagination, we need to know the cursor field
 		// This is a simplified implementation - in practice, you'd need to specify the cursor field
 		if direction == "next" {
-			query = query.Where("id > ?", cursor)
+			query = query.Where(clause.Gt{Column: idColumn, Value: cursor})
 		} else {
-			query = query.Where("id < ?", cursor)
+			query = query.Where(clause.Lt{Column: idColumn, Value: cursor})
 		}
 	}
 
 	// Validate batch size
 	if batchSize <= 0 {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("batch size must be greater than 0, got %d", batchSize)
+		r.metrics.IncrementOperationsFor("FindAllInBatchesWithCursor", false)
+		return r.argumentError("FindAllInBatchesWithCursor", fmt.Sprintf("batch size must be greater than 0, got %d", batchSize))
 	}
 
 	if err := query.FindInBatches(dest, batchSize, fc).Error; err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to find all entities in batches: %w", err)
+		r.metrics.IncrementOperationsFor("FindAllInBatchesWithCursor", false)
+		return r.wrapError(err, "FindAllInBatchesWithCursor", "failed to find all entities in batches")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.metrics.IncrementOperationsFor("FindAllInBatchesWithCursor", true)
 	return nil
 }
 
@@ -515,37 +819,37 @@ func (r *BaseRepository[T]) FindAllInBatchesWithCursor(ctx context.Context, curs
 func (r *BaseRepository[T]) FindAllByConditionsWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, conds ...interface{}) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "FindAllByConditionsWithCursor", time.Since(start))
 	}()
 
+	conds, opts := splitFindOptions(conds)
 	cursor, limit, direction = r.validateCursorPaginationParams(cursor, limit, direction)
 
-	// Build query with cursor
-	query := r.db.WithContext(ctx).Limit(limit)
-
-	if cursor != "" {
-		// For cursor-based pagination, we need to know the cursor field
-		// This is a simplified implementation - in practice, you'd need to specify the cursor field
-		if direction == "next" {
-			query = query.Where("id > ?", cursor)
-		} else {
-			query = query.Where("id < ?", cursor)
+	err := r.retry(ctx, "FindAllByConditionsWithCursor", func() error {
+		// Build query with cursor
+		query := r.findUnorderedDB(ctx, opts, "FindAllByConditionsWithCursor").Limit(limit)
+
+		if cursor != "" {
+			// For cursor-based pagination, we need to know the cursor field
+			// This is a simplified implementation - in practice, you'd need to specify the cursor field
+			if direction == "next" {
+				query = query.Where(clause.Gt{Column: idColumn, Value: cursor})
+			} else {
+				query = query.Where(clause.Lt{Column: idColumn, Value: cursor})
+			}
 		}
-	}
-
-	var err error
-	if len(conds) == 0 {
-		err = query.Find(dest).Error
-	} else {
-		err = query.Where(conds[0], conds[1:]...).Find(dest).Error
-	}
 
+		if len(conds) == 0 {
+			return query.Find(dest).Error
+		}
+		return query.Where(conds[0], conds[1:]...).Find(dest).Error
+	})
 	if err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to find all entities by conditions: %w", err)
+		r.metrics.IncrementOperationsFor("FindAllByConditionsWithCursor", false)
+		return r.wrapError(err, "FindAllByConditionsWithCursor", "failed to find all entities by conditions")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.metrics.IncrementOperationsFor("FindAllByConditionsWithCursor", true)
 	return nil
 }
 
@@ -553,28 +857,29 @@ func (r *BaseRepository[T]) FindAllByConditionsWithCursor(ctx context.Context, c
 func (r *BaseRepository[T]) FindAllInBatchesByConditionsWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, conds ...interface{}) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "FindAllInBatchesByConditionsWithCursor", time.Since(start))
 	}()
 
+	conds, opts := splitFindOptions(conds)
 	cursor, limit, direction = r.validateCursorPaginationParams(cursor, limit, direction)
 
 	// Build query with cursor
-	query := r.db.WithContext(ctx).Limit(limit)
+	query := r.findUnorderedDB(ctx, opts, "FindAllInBatchesByConditionsWithCursor").Limit(limit)
 
 	if cursor != "" {
 		// For cursor-based pagination, we need to know the cursor field
 		// This is a simplified implementation - in practice, you'd need to specify the cursor field
 		if direction == "next" {
-			query = query.Where("id > ?", cursor)
+			query = query.Where(clause.Gt{Column: idColumn, Value: cursor})
 		} else {
-			query = query.Where("id < ?", cursor)
+			query = query.Where(clause.Lt{Column: idColumn, Value: cursor})
 		}
 	}
 
 	// Validate batch size
 	if batchSize <= 0 {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("batch size must be greater than 0, got %d", batchSize)
+		r.metrics.IncrementOperationsFor("FindAllInBatchesByConditionsWithCursor", false)
+		return r.argumentError("FindAllInBatchesByConditionsWithCursor", fmt.Sprintf("batch size must be greater than 0, got %d", batchSize))
 	}
 
 	var err error
@@ -585,11 +890,11 @@ func (r *BaseRepository[T]) FindAllInBatchesByConditionsWithCursor(ctx context.C
 	}
 
 	if err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to find all entities in batches by conditions: %w", err)
+		r.metrics.IncrementOperationsFor("FindAllInBatchesByConditionsWithCursor", false)
+		return r.wrapError(err, "FindAllInBatchesByConditionsWithCursor", "failed to find all entities in batches by conditions")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.metrics.IncrementOperationsFor("FindAllInBatchesByConditionsWithCursor", true)
 	return nil
 }
 
@@ -597,248 +902,448 @@ func (r *BaseRepository[T]) FindAllInBatchesByConditionsWithCursor(ctx context.C
 func (r *BaseRepository[T]) Update(ctx context.Context, entity *T) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "Update", time.Since(start))
 	}()
 
 	// Check for nil entity
 	if entity == nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("entity cannot be nil")
+		r.metrics.IncrementOperationsFor("Update", false)
+		return r.argumentError("Update", "entity cannot be nil")
 	}
 
 	// Check if entity has a valid ID
 	entityID := r.getEntityID(entity)
-	if entityID == uuid.Nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("entity must have a valid ID")
+	if isZeroID(entityID) {
+		r.metrics.IncrementOperationsFor("Update", false)
+		return r.argumentError("Update", "entity must have a valid ID")
 	}
 
 	// Validate entity if enabled
 	if r.config.EnableValidation {
 		if result, err := r.Validate(ctx, entity); err != nil {
-			r.metrics.IncrementOperations(false)
-			return fmt.Errorf("validation failed: %w", err)
+			r.metrics.IncrementOperationsFor("Update", false)
+			return r.wrapError(err, "Update", "validation failed")
 		} else if !result.Valid {
-			r.metrics.IncrementOperations(false)
-			return errors.New(errors.ErrorTypeValidation, fmt.Sprintf("validation failed: %v", result.Errors))
+			r.metrics.IncrementOperationsFor("Update", false)
+			return r.validationError(ctx, "Update", result)
 		}
 	}
 
 	// Update entity
-	if err := r.db.WithContext(ctx).Save(entity).Error; err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to update entity: %w", err)
+	before := r.snapshot(ctx, entityID)
+	if err := r.retry(ctx, "Update", func() error {
+		return r.db.WithContext(ctx).Save(entity).Error
+	}); err != nil {
+		r.metrics.IncrementOperationsFor("Update", false)
+		return r.wrapError(err, "Update", "failed to update entity")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.deferReferences(ctx, entity)
+
+	r.cacheWritten(ctx, entity)
+	if err := r.notifyChange(ctx, saveOperation(before), entityID, before, entity); err != nil {
+		r.metrics.IncrementOperationsFor("Update", false)
+		return err
+	}
+	r.metrics.IncrementOperationsFor("Update", true)
 	r.logger.Info(ctx, "Entity updated successfully",
 		logging.String("table", r.tableName),
-		logging.String("id", r.getEntityID(entity).String()))
+		logging.String("id", idString(r.getEntityID(entity))))
 
 	return nil
 }
 
 // UpdateByID updates an entity by ID
-func (r *BaseRepository[T]) UpdateByID(ctx context.Context, entity *T, id uuid.UUID) error {
+func (r *BaseRepository[T]) UpdateByID(ctx context.Context, entity *T, id models.EntityID) error {
+	id = normalizeID(id, keyTypeOf[T]())
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "UpdateByID", time.Since(start))
 	}()
 
 	// Check for nil entity
 	if entity == nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("entity cannot be nil")
+		r.metrics.IncrementOperationsFor("UpdateByID", false)
+		return r.argumentError("UpdateByID", "entity cannot be nil")
 	}
 
 	// Check if ID is valid
-	if id == uuid.Nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("ID cannot be nil")
+	if isZeroID(id) {
+		r.metrics.IncrementOperationsFor("UpdateByID", false)
+		return r.argumentError("UpdateByID", "ID cannot be nil")
 	}
 
 	// Check if entity has a valid ID
 	entityID := r.getEntityID(entity)
-	if entityID == uuid.Nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("entity must have a valid ID")
+	if isZeroID(entityID) {
+		r.metrics.IncrementOperationsFor("UpdateByID", false)
+		return r.argumentError("UpdateByID", "entity must have a valid ID")
 	}
 
 	if entityID != id {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("entity id must match id")
+		r.metrics.IncrementOperationsFor("UpdateByID", false)
+		return r.argumentError("UpdateByID", "entity id must match id")
 	}
 
 	// Validate entity if enabled
 	if r.config.EnableValidation {
 		if result, err := r.Validate(ctx, entity); err != nil {
-			r.metrics.IncrementOperations(false)
-			return fmt.Errorf("validation failed: %w", err)
+			r.metrics.IncrementOperationsFor("UpdateByID", false)
+			return r.wrapError(err, "UpdateByID", "validation failed")
 		} else if !result.Valid {
-			r.metrics.IncrementOperations(false)
-			return errors.New(errors.ErrorTypeValidation, fmt.Sprintf("validation failed: %v", result.Errors))
+			r.metrics.IncrementOperationsFor("UpdateByID", false)
+			return r.validationError(ctx, "UpdateByID", result)
 		}
 	}
 
-	if err := r.db.WithContext(ctx).Where("id = ?", id).Save(entity).Error; err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to update entity by ID: %w", err)
+	before := r.snapshot(ctx, id)
+	if err := r.retry(ctx, "UpdateByID", func() error {
+		return r.db.WithContext(ctx).Where("id = ?", id).Save(entity).Error
+	}); err != nil {
+		r.metrics.IncrementOperationsFor("UpdateByID", false)
+		return r.wrapError(err, "UpdateByID", "failed to update entity by ID")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.deferReferences(ctx, entity)
+
+	r.cacheWritten(ctx, entity)
+	if err := r.notifyChange(ctx, saveOperation(before), id, before, entity); err != nil {
+		r.metrics.IncrementOperationsFor("UpdateByID", false)
+		return err
+	}
+	r.metrics.IncrementOperationsFor("UpdateByID", true)
 	return nil
 }
 
 func (r *BaseRepository[T]) UpdateByConditions(ctx context.Context, entity *T, conds ...interface{}) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "UpdateByConditions", time.Since(start))
 	}()
 
 	// Check for nil entity
 	if entity == nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("entity cannot be nil")
+		r.metrics.IncrementOperationsFor("UpdateByConditions", false)
+		return r.argumentError("UpdateByConditions", "entity cannot be nil")
 	}
 
 	// // Check if entity has a valid ID
 	// entityID := r.getEntityID(entity)
-	// if entityID == uuid.Nil {
-	// 	r.metrics.IncrementOperations(false)
+	// if isZeroID(entityID) {
+	// 	r.metrics.IncrementOperationsFor("UpdateByConditions", false)
 	// 	return fmt.Errorf("entity must have a valid ID")
 	// }
 
 	// Validate entity if enabled
 	if r.config.EnableValidation {
 		if result, err := r.Validate(ctx, entity); err != nil {
-			r.metrics.IncrementOperations(false)
-			return fmt.Errorf("validation failed: %w", err)
+			r.metrics.IncrementOperationsFor("UpdateByConditions", false)
+			return r.wrapError(err, "UpdateByConditions", "validation failed")
 		} else if !result.Valid {
-			r.metrics.IncrementOperations(false)
-			return errors.New(errors.ErrorTypeValidation, fmt.Sprintf("validation failed: %v", result.Errors))
+			r.metrics.IncrementOperationsFor("UpdateByConditions", false)
+			return r.validationError(ctx, "UpdateByConditions", result)
 		}
 	}
 
-	var err error
+	err := r.retry(ctx, "UpdateByConditions", func() error {
+		if len(conds) == 0 {
+			return r.db.WithContext(ctx).Save(entity).Error
+		}
+		// For bulk updates by conditions, use Updates instead of Save
+		return r.db.WithContext(ctx).Model(new(T)).Where(conds[0], conds[1:]...).Updates(entity).Error
+	})
+	if err != nil {
+		r.metrics.IncrementOperationsFor("UpdateByConditions", false)
+		return r.wrapError(err, "UpdateByConditions", "failed to update entity by conditions")
+	}
 	if len(conds) == 0 {
-		err = r.db.WithContext(ctx).Save(entity).Error
+		r.cacheWritten(ctx, entity)
 	} else {
-		// For bulk updates by conditions, use Updates instead of Save
-		err = r.db.WithContext(ctx).Model(new(T)).Where(conds[0], conds[1:]...).Updates(entity).Error
+		r.cacheInvalidate(ctx)
 	}
 
+	r.metrics.IncrementOperationsFor("UpdateByConditions", true)
+	return nil
+}
+
+// UpdateAllByConditions sets the given columns on every entity matching the
+// conditions and returns the number of updated rows. Soft deleted entities are
+// left untouched unless ctx includes them (see WithDeleted).
+func (r *BaseRepository[T]) UpdateAllByConditions(ctx context.Context, values map[string]interface{}, conds ...interface{}) (int64, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "UpdateAllByConditions", time.Since(start))
+	}()
+
+	if len(values) == 0 {
+		r.metrics.IncrementOperationsFor("UpdateAllByConditions", false)
+		return 0, r.argumentError("UpdateAllByConditions", "values cannot be empty")
+	}
+
+	// Require at least one condition for safety
+	if len(conds) == 0 {
+		r.metrics.IncrementOperationsFor("UpdateAllByConditions", false)
+		return 0, r.argumentError("UpdateAllByConditions", "WHERE conditions required")
+	}
+
+	var result *gorm.DB
+	err := r.retry(ctx, "UpdateAllByConditions", func() error {
+		result = r.scopeDeleted(ctx, r.db.WithContext(ctx).Model(new(T)).Where(conds[0], conds[1:]...)).Updates(values)
+		return result.Error
+	})
 	if err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to update entity by conditions: %w", err)
+		r.metrics.IncrementOperationsFor("UpdateAllByConditions", false)
+		return 0, r.wrapError(err, "UpdateAllByConditions", "failed to update entities by conditions")
 	}
 
-	r.metrics.IncrementOperations(true)
-	return nil
+	r.cacheInvalidate(ctx)
+	r.metrics.IncrementOperationsFor("UpdateAllByConditions", true)
+	return result.RowsAffected, nil
 }
 
-func (r *BaseRepository[T]) Upsert(ctx context.Context, entity *T, conflictClause string) error {
+// UpdateIf atomically sets updates on the entity with id if its columns still
+// hold the expected values (nil expects NULL), in a single UPDATE statement,
+// and reports whether the precondition matched. This allows compare-and-set
+// workflows such as claiming a job only while its status is "pending" without
+// a transaction. Soft deleted entities never match unless ctx includes them.
+func (r *BaseRepository[T]) UpdateIf(ctx context.Context, id models.EntityID, expected map[string]interface{}, updates map[string]interface{}) (bool, error) {
+	id = normalizeID(id, keyTypeOf[T]())
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "UpdateIf", time.Since(start))
 	}()
 
-	// Check for nil entity
-	if entity == nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("entity cannot be nil")
+	if isZeroID(id) {
+		r.metrics.IncrementOperationsFor("UpdateIf", false)
+		return false, r.argumentError("UpdateIf", "ID cannot be nil")
 	}
 
-	// Check for empty conflict clause
-	if conflictClause == "" {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("conflict clause cannot be empty")
+	if len(updates) == 0 {
+		r.metrics.IncrementOperationsFor("UpdateIf", false)
+		return false, r.argumentError("UpdateIf", "updates cannot be empty")
 	}
 
-	if err := r.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Save(entity).Error; err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to upsert entity: %w", err)
+	query := r.db.WithContext(ctx).Model(new(T)).Where(clause.Eq{Column: idColumn, Value: id})
+	if len(expected) > 0 {
+		query = query.Where(expected)
+	}
+	result := r.scopeDeleted(ctx, query).Updates(updates)
+	if result.Error != nil {
+		r.metrics.IncrementOperationsFor("UpdateIf", false)
+		return false, r.wrapError(result.Error, "UpdateIf", "failed to update entity conditionally")
 	}
 
-	r.metrics.IncrementOperations(true)
-	return nil
+	matched := result.RowsAffected > 0
+	if matched {
+		r.cacheInvalidate(ctx, id)
+	}
+	r.metrics.IncrementOperationsFor("UpdateIf", true)
+	return matched, nil
 }
 
-func (r *BaseRepository[T]) UpsertByID(ctx context.Context, entity *T, id uuid.UUID, conflictClause string) error {
+// Upsert inserts entity or, when it conflicts with an existing row, resolves
+// the conflict as described by conflict: updating the row, some of its
+// columns or nothing. When the conflict target is not the primary key the
+// entity takes the ID of the row it was merged into.
+func (r *BaseRepository[T]) Upsert(ctx context.Context, entity *T, conflict ConflictOptions) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "Upsert", time.Since(start))
 	}()
 
-	if err := r.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Save(entity).Error; err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to upsert entity by ID: %w", err)
+	// Check for nil entity
+	if entity == nil {
+		r.metrics.IncrementOperationsFor("Upsert", false)
+		return r.argumentError("Upsert", "entity cannot be nil")
+	}
+
+	before, after, err := r.upsertOne(ctx, entity, conflict, nil)
+	if err != nil {
+		r.metrics.IncrementOperationsFor("Upsert", false)
+		return r.wrapError(err, "Upsert", "failed to upsert entity")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.deferReferences(ctx, entity)
+
+	r.cacheInvalidate(ctx)
+	if err := r.notifyChange(ctx, saveOperation(before), r.getEntityID(entity), before, after); err != nil {
+		r.metrics.IncrementOperationsFor("Upsert", false)
+		return err
+	}
+	r.metrics.IncrementOperationsFor("Upsert", true)
 	return nil
 }
 
-func (r *BaseRepository[T]) UpsertByConditions(ctx context.Context, entity *T, conflictClause string, conds ...interface{}) error {
+// UpsertByID upserts entity with id as its primary key
+func (r *BaseRepository[T]) UpsertByID(ctx context.Context, entity *T, id models.EntityID, conflict ConflictOptions) error {
+	id = normalizeID(id, keyTypeOf[T]())
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "UpsertByID", time.Since(start))
 	}()
 
-	if err := r.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Save(entity).Error; err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to upsert entity by conditions: %w", err)
+	if entity == nil {
+		r.metrics.IncrementOperationsFor("UpsertByID", false)
+		return r.argumentError("UpsertByID", "entity cannot be nil")
+	}
+	if isZeroID(id) {
+		r.metrics.IncrementOperationsFor("UpsertByID", false)
+		return r.argumentError("UpsertByID", "ID cannot be nil")
 	}
+	if current := r.getEntityID(entity); !isZeroID(current) && current != id {
+		r.metrics.IncrementOperationsFor("UpsertByID", false)
+		return r.argumentError("UpsertByID", fmt.Sprintf("entity ID %s does not match %s", current, id))
+	}
+	r.setEntityID(entity, id)
 
-	r.metrics.IncrementOperations(true)
+	before, after, err := r.upsertOne(ctx, entity, conflict, nil)
+	if err != nil {
+		r.metrics.IncrementOperationsFor("UpsertByID", false)
+		return r.wrapError(err, "UpsertByID", "failed to upsert entity by ID")
+	}
+
+	r.cacheInvalidate(ctx, id)
+	if err := r.notifyChange(ctx, saveOperation(before), r.getEntityID(entity), before, after); err != nil {
+		r.metrics.IncrementOperationsFor("UpsertByID", false)
+		return err
+	}
+	r.metrics.IncrementOperationsFor("UpsertByID", true)
 	return nil
 }
 
-func (r *BaseRepository[T]) UpsertInBatches(ctx context.Context, entities []T, batchSize int, conflictClause string) error {
+// UpsertByConditions upserts entity, only updating a conflicting row matching
+// conds. Not supported by MySQL, SQL Server and Oracle.
+func (r *BaseRepository[T]) UpsertByConditions(ctx context.Context, entity *T, conflict ConflictOptions, conds ...interface{}) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "UpsertByConditions", time.Since(start))
 	}()
 
-	// Validate batch size
-	if batchSize <= 0 {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("batch size must be greater than 0, got %d", batchSize)
+	if entity == nil {
+		r.metrics.IncrementOperationsFor("UpsertByConditions", false)
+		return r.argumentError("UpsertByConditions", "entity cannot be nil")
 	}
 
-	if err := r.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Save(&entities).Error; err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to upsert entities in batches: %w", err)
+	if _, _, err := r.upsertOne(ctx, entity, conflict, conds); err != nil {
+		r.metrics.IncrementOperationsFor("UpsertByConditions", false)
+		return r.wrapError(err, "UpsertByConditions", "failed to upsert entity by conditions")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.cacheInvalidate(ctx)
+	r.metrics.IncrementOperationsFor("UpsertByConditions", true)
 	return nil
 }
 
-func (r *BaseRepository[T]) UpsertInBatchesByConditions(ctx context.Context, entities []T, batchSize int, conflictClause string, conds ...interface{}) error {
+// UpsertInBatches upserts entities batchSize rows per statement, or the
+// configured CreateBatchSize when zero. Each batch is a separate statement,
+// so the batches upserted before a failing one are kept: the failure is
+// reported by an errors.BatchError, which also counts them. The remaining
+// batches are upserted too when conflict.ContinueOnError is set.
+func (r *BaseRepository[T]) UpsertInBatches(ctx context.Context, entities []T, batchSize int, conflict ConflictOptions) error {
+	return r.upsertInBatches(ctx, "UpsertInBatches", entities, batchSize, conflict, nil)
+}
+
+// UpsertInBatchesByConditions upserts entities batchSize rows per statement
+// like UpsertInBatches, only updating conflicting rows matching conds. Not
+// supported by MySQL, SQL Server and Oracle.
+func (r *BaseRepository[T]) UpsertInBatchesByConditions(ctx context.Context, entities []T, batchSize int, conflict ConflictOptions, conds ...interface{}) error {
+	return r.upsertInBatches(ctx, "UpsertInBatchesByConditions", entities, batchSize, conflict, conds)
+}
+
+// upsertInBatches upserts entities one batch per statement, stopping at the
+// first failing batch unless conflict.ContinueOnError is set
+func (r *BaseRepository[T]) upsertInBatches(ctx context.Context, operation string, entities []T, batchSize int, conflict ConflictOptions, conds []interface{}) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, operation, time.Since(start))
 	}()
 
+	if batchSize <= 0 {
+		batchSize = r.config.CreateBatchSize
+	}
+
+	if len(entities) == 0 {
+		r.metrics.IncrementOperationsFor(operation, false)
+		return r.argumentError(operation, "entities cannot be empty")
+	}
+
 	// Validate batch size
 	if batchSize <= 0 {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("batch size must be greater than 0, got %d", batchSize)
+		r.metrics.IncrementOperationsFor(operation, false)
+		return r.argumentError(operation, fmt.Sprintf("batch size must be greater than 0, got %d", batchSize))
 	}
 
-	var err error
-	if len(conds) == 0 {
-		err = r.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Save(&entities).Error
-	} else {
-		err = r.db.WithContext(ctx).Where(conds[0], conds[1:]...).Clauses(clause.OnConflict{UpdateAll: true}).Save(&entities).Error
+	if err := r.validateBatch(ctx, operation, entities, false); err != nil {
+		r.metrics.IncrementOperationsFor(operation, false)
+		return err
 	}
 
+	onConflict, err := r.onConflict(conflict, conds)
 	if err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to upsert entities in batches by conditions: %w", err)
+		r.metrics.IncrementOperationsFor(operation, false)
+		return r.wrapError(err, operation, "failed to upsert entities in batches")
 	}
 
-	r.metrics.IncrementOperations(true)
+	var failures []errors.BatchFailure
+	succeeded := 0
+	for offset, batch := 0, 0; offset < len(entities); offset, batch = offset+batchSize, batch+1 {
+		end := offset + batchSize
+		if end > len(entities) {
+			end = len(entities)
+		}
+		rows := entities[offset:end]
+
+		var befores []*T
+		if len(r.changeHooks) > 0 {
+			befores = make([]*T, len(rows))
+			for i := range rows {
+				befores[i] = r.upsertBefore(ctx, &rows[i], conflict)
+			}
+		}
+
+		if err := r.retry(ctx, operation, func() error {
+			if r.mergesUpserts() {
+				return r.mergeRows(ctx, pointers(rows), conflict)
+			}
+			return r.db.WithContext(ctx).Clauses(onConflict).Create(&rows).Error
+		}); err != nil {
+			failures = append(failures, errors.BatchFailure{
+				Batch:  batch,
+				Offset: offset,
+				Size:   len(rows),
+				Err:    r.wrapError(err, operation, fmt.Sprintf("failed to upsert entities of batch %d", batch)),
+			})
+			if !conflict.ContinueOnError {
+				break
+			}
+			continue
+		}
+		succeeded += len(rows)
+
+		for i, before := range befores {
+			after := r.upserted(ctx, &rows[i], conflict, conds)
+			if err := r.notifyChange(ctx, saveOperation(before), r.getEntityID(after), before, after); err != nil {
+				r.cacheInvalidate(ctx)
+				r.metrics.IncrementOperationsFor(operation, false)
+				return err
+			}
+		}
+	}
+
+	if succeeded > 0 {
+		r.cacheInvalidate(ctx)
+	}
+	if len(failures) > 0 {
+		r.metrics.IncrementOperationsFor(operation, false)
+		r.logger.Error(ctx, "Batch upsert failed",
+			logging.String("table", r.tableName),
+			logging.Int("batch_size", batchSize),
+			logging.Int("upserted", succeeded),
+			logging.Int("failed_batches", len(failures)))
+		return errors.NewBatchError(operation, r.tableName, len(entities), succeeded, failures)
+	}
+
+	r.metrics.IncrementOperationsFor(operation, true)
 	return nil
 }
 
@@ -846,40 +1351,64 @@ func (r *BaseRepository[T]) UpsertInBatchesByConditions(ctx context.Context, ent
 func (r *BaseRepository[T]) Delete(ctx context.Context, entity *T) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "Delete", time.Since(start))
 	}()
 
-	if err := r.db.WithContext(ctx).Delete(entity).Error; err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to delete entity: %w", err)
+	var before *T
+	if entity != nil {
+		before = r.snapshot(ctx, r.getEntityID(entity))
+	}
+	if err := r.retry(ctx, "Delete", func() error {
+		return r.remove(r.db.WithContext(ctx), entity)
+	}); err != nil {
+		r.metrics.IncrementOperationsFor("Delete", false)
+		return r.wrapError(err, "Delete", "failed to delete entity")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.cacheInvalidate(ctx, r.getEntityID(entity))
+	if before != nil {
+		if err := r.notifyChange(ctx, ChangeDelete, r.getEntityID(entity), before, nil); err != nil {
+			r.metrics.IncrementOperationsFor("Delete", false)
+			return err
+		}
+	}
+	r.metrics.IncrementOperationsFor("Delete", true)
 	return nil
 }
 
 // DeleteByID deletes an entity
-func (r *BaseRepository[T]) DeleteByID(ctx context.Context, id uuid.UUID) error {
+func (r *BaseRepository[T]) DeleteByID(ctx context.Context, id models.EntityID) error {
+	id = normalizeID(id, keyTypeOf[T]())
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "DeleteByID", time.Since(start))
 	}()
 
 	// Check if ID is valid
-	if id == uuid.Nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("ID cannot be nil")
+	if isZeroID(id) {
+		r.metrics.IncrementOperationsFor("DeleteByID", false)
+		return r.argumentError("DeleteByID", "ID cannot be nil")
 	}
 
-	if err := r.db.WithContext(ctx).Delete(new(T), "id = ?", id).Error; err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to delete entity: %w", err)
+	before := r.snapshot(ctx, id)
+	if err := r.retry(ctx, "DeleteByID", func() error {
+		return r.remove(r.db.WithContext(ctx), new(T), "id = ?", id)
+	}); err != nil {
+		r.metrics.IncrementOperationsFor("DeleteByID", false)
+		return r.wrapError(err, "DeleteByID", "failed to delete entity")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.cacheInvalidate(ctx, id)
+	if before != nil {
+		if err := r.notifyChange(ctx, ChangeDelete, id, before, nil); err != nil {
+			r.metrics.IncrementOperationsFor("DeleteByID", false)
+			return err
+		}
+	}
+	r.metrics.IncrementOperationsFor("DeleteByID", true)
 	r.logger.Info(ctx, "Entity deleted successfully",
 		logging.String("table", r.tableName),
-		logging.String("id", id.String()))
+		logging.String("id", idString(id)))
 
 	return nil
 }
@@ -887,95 +1416,137 @@ func (r *BaseRepository[T]) DeleteByID(ctx context.Context, id uuid.UUID) error
 func (r *BaseRepository[T]) DeleteByConditions(ctx context.Context, entity *T, conds ...interface{}) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "DeleteByConditions", time.Since(start))
 	}()
 
 	// Check for nil entity
 	if entity == nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("entity cannot be nil")
+		r.metrics.IncrementOperationsFor("DeleteByConditions", false)
+		return r.argumentError("DeleteByConditions", "entity cannot be nil")
 	}
 
 	// Require at least one condition for safety
 	if len(conds) == 0 {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("WHERE conditions required")
+		r.metrics.IncrementOperationsFor("DeleteByConditions", false)
+		return r.argumentError("DeleteByConditions", "WHERE conditions required")
 	}
 
-	var err = r.db.WithContext(ctx).Where(conds[0], conds[1:]...).Delete(entity).Error
+	err := r.retry(ctx, "DeleteByConditions", func() error {
+		return r.remove(r.db.WithContext(ctx).Where(conds[0], conds[1:]...), entity)
+	})
 	if err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to delete entity by conditions: %w", err)
+		r.metrics.IncrementOperationsFor("DeleteByConditions", false)
+		return r.wrapError(err, "DeleteByConditions", "failed to delete entity by conditions")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.cacheInvalidate(ctx)
+	r.metrics.IncrementOperationsFor("DeleteByConditions", true)
 	return nil
 }
 
+// DeleteAllByConditions deletes every entity matching the conditions according
+// to the delete mode and returns the number of deleted rows
+func (r *BaseRepository[T]) DeleteAllByConditions(ctx context.Context, conds ...interface{}) (int64, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "DeleteAllByConditions", time.Since(start))
+	}()
+
+	// Require at least one condition for safety
+	if len(conds) == 0 {
+		r.metrics.IncrementOperationsFor("DeleteAllByConditions", false)
+		return 0, r.argumentError("DeleteAllByConditions", "WHERE conditions required")
+	}
+
+	var result *gorm.DB
+	err := r.retry(ctx, "DeleteAllByConditions", func() error {
+		result = r.removeRows(r.db.WithContext(ctx).Where(conds[0], conds[1:]...), new(T))
+		return result.Error
+	})
+	if err != nil {
+		r.metrics.IncrementOperationsFor("DeleteAllByConditions", false)
+		return 0, r.wrapError(err, "DeleteAllByConditions", "failed to delete entities by conditions")
+	}
+
+	r.cacheInvalidate(ctx)
+	r.metrics.IncrementOperationsFor("DeleteAllByConditions", true)
+	return result.RowsAffected, nil
+}
+
 // DeleteInBatches deletes entities in batches
 func (r *BaseRepository[T]) DeleteInBatches(ctx context.Context, entities []T, batchSize int) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "DeleteInBatches", time.Since(start))
 	}()
 
 	// Validate batch size
 	if batchSize <= 0 {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("batch size must be greater than 0, got %d", batchSize)
+		r.metrics.IncrementOperationsFor("DeleteInBatches", false)
+		return r.argumentError("DeleteInBatches", fmt.Sprintf("batch size must be greater than 0, got %d", batchSize))
 	}
 
-	if err := r.db.WithContext(ctx).Delete(&entities, batchSize).Error; err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to delete entities in batches: %w", err)
+	if err := r.remove(r.db.WithContext(ctx), &entities, batchSize); err != nil {
+		r.metrics.IncrementOperationsFor("DeleteInBatches", false)
+		return r.wrapError(err, "DeleteInBatches", "failed to delete entities in batches")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.cacheInvalidate(ctx)
+	r.metrics.IncrementOperationsFor("DeleteInBatches", true)
 	return nil
 }
 
 func (r *BaseRepository[T]) DeleteInBatchesByConditions(ctx context.Context, entities []T, batchSize int, conds ...interface{}) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "DeleteInBatchesByConditions", time.Since(start))
 	}()
 
 	// Validate batch size
 	if batchSize <= 0 {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("batch size must be greater than 0, got %d", batchSize)
+		r.metrics.IncrementOperationsFor("DeleteInBatchesByConditions", false)
+		return r.argumentError("DeleteInBatchesByConditions", fmt.Sprintf("batch size must be greater than 0, got %d", batchSize))
 	}
 
 	var err error
 	if len(conds) == 0 {
-		err = r.db.WithContext(ctx).Delete(&entities, batchSize).Error
+		err = r.remove(r.db.WithContext(ctx), &entities, batchSize)
 	} else {
-		err = r.db.WithContext(ctx).Where(conds[0], conds[1:]...).Delete(&entities, batchSize).Error
+		err = r.remove(r.db.WithContext(ctx).Where(conds[0], conds[1:]...), &entities, batchSize)
 	}
 
 	if err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to delete entities in batches by conditions: %w", err)
+		r.metrics.IncrementOperationsFor("DeleteInBatchesByConditions", false)
+		return r.wrapError(err, "DeleteInBatchesByConditions", "failed to delete entities in batches by conditions")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.cacheInvalidate(ctx)
+	r.metrics.IncrementOperationsFor("DeleteInBatchesByConditions", true)
 	return nil
 }
 
 // ExistsByID checks if an entity exists
-func (r *BaseRepository[T]) ExistsByID(ctx context.Context, id uuid.UUID) (bool, error) {
+func (r *BaseRepository[T]) ExistsByID(ctx context.Context, id models.EntityID) (bool, error) {
+	id = normalizeID(id, keyTypeOf[T]())
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "ExistsByID", time.Since(start))
 	}()
 
+	if _, ok := r.cacheRead(ctx, id); ok {
+		r.metrics.IncrementOperationsFor("ExistsByID", true)
+		return true, nil
+	}
+
 	var count int64
-	if err := r.db.WithContext(ctx).Model(new(T)).Where("id = ?", id).Count(&count).Error; err != nil {
-		r.metrics.IncrementOperations(false)
-		return false, fmt.Errorf("failed to check entity existence: %w", err)
+	if err := r.retry(ctx, "ExistsByID", func() error {
+		return r.readDB(ctx).Model(new(T)).Where("id = ?", id).Count(&count).Error
+	}); err != nil {
+		r.metrics.IncrementOperationsFor("ExistsByID", false)
+		return false, r.wrapError(err, "ExistsByID", "failed to check entity existence")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.metrics.IncrementOperationsFor("ExistsByID", true)
 	return count > 0, nil
 }
 
@@ -983,23 +1554,22 @@ func (r *BaseRepository[T]) ExistsByID(ctx context.Context, id uuid.UUID) (bool,
 func (r *BaseRepository[T]) ExistsByConditions(ctx context.Context, conds ...interface{}) (bool, error) {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "ExistsByConditions", time.Since(start))
 	}()
 
 	var count int64
-	var err error
-	if len(conds) == 0 {
-		err = r.db.WithContext(ctx).Model(new(T)).Count(&count).Error
-	} else {
-		err = r.db.WithContext(ctx).Model(new(T)).Where(conds[0], conds[1:]...).Count(&count).Error
-	}
-
+	err := r.retry(ctx, "ExistsByConditions", func() error {
+		if len(conds) == 0 {
+			return r.readDB(ctx).Model(new(T)).Count(&count).Error
+		}
+		return r.readDB(ctx).Model(new(T)).Where(conds[0], conds[1:]...).Count(&count).Error
+	})
 	if err != nil {
-		r.metrics.IncrementOperations(false)
-		return false, fmt.Errorf("failed to check entity existence by conditions: %w", err)
+		r.metrics.IncrementOperationsFor("ExistsByConditions", false)
+		return false, r.wrapError(err, "ExistsByConditions", "failed to check entity existence by conditions")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.metrics.IncrementOperationsFor("ExistsByConditions", true)
 	return count > 0, nil
 }
 
@@ -1007,23 +1577,22 @@ func (r *BaseRepository[T]) ExistsByConditions(ctx context.Context, conds ...int
 func (r *BaseRepository[T]) CountByConditions(ctx context.Context, conds ...interface{}) (int64, error) {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "CountByConditions", time.Since(start))
 	}()
 
 	var count int64
-	var err error
-	if len(conds) == 0 {
-		err = r.db.WithContext(ctx).Model(new(T)).Count(&count).Error
-	} else {
-		err = r.db.WithContext(ctx).Model(new(T)).Where(conds[0], conds[1:]...).Count(&count).Error
-	}
-
+	err := r.retry(ctx, "CountByConditions", func() error {
+		if len(conds) == 0 {
+			return r.readDB(ctx).Model(new(T)).Count(&count).Error
+		}
+		return r.readDB(ctx).Model(new(T)).Where(conds[0], conds[1:]...).Count(&count).Error
+	})
 	if err != nil {
-		r.metrics.IncrementOperations(false)
-		return 0, fmt.Errorf("failed to count entities by conditions: %w", err)
+		r.metrics.IncrementOperationsFor("CountByConditions", false)
+		return 0, r.wrapError(err, "CountByConditions", "failed to count entities by conditions")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.metrics.IncrementOperationsFor("CountByConditions", true)
 	return count, nil
 }
 
@@ -1031,16 +1600,18 @@ func (r *BaseRepository[T]) CountByConditions(ctx context.Context, conds ...inte
 func (r *BaseRepository[T]) CountAll(ctx context.Context) (int64, error) {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "CountAll", time.Since(start))
 	}()
 
 	var count int64
-	if err := r.db.WithContext(ctx).Model(new(T)).Count(&count).Error; err != nil {
-		r.metrics.IncrementOperations(false)
-		return 0, fmt.Errorf("failed to count entities: %w", err)
+	if err := r.retry(ctx, "CountAll", func() error {
+		return r.readDB(ctx).Model(new(T)).Count(&count).Error
+	}); err != nil {
+		r.metrics.IncrementOperationsFor("CountAll", false)
+		return 0, r.wrapError(err, "CountAll", "failed to count entities")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.metrics.IncrementOperationsFor("CountAll", true)
 	return count, nil
 }
 
@@ -1048,22 +1619,22 @@ func (r *BaseRepository[T]) CountAll(ctx context.Context) (int64, error) {
 func (r *BaseRepository[T]) TakeByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "TakeByConditions", time.Since(start))
 	}()
 
-	var err error
-	if len(conds) == 0 {
-		err = r.db.WithContext(ctx).Take(dest).Error
-	} else {
-		err = r.db.WithContext(ctx).Where(conds[0], conds[1:]...).Take(dest).Error
-	}
-
+	conds, opts := splitFindOptions(conds)
+	err := r.retry(ctx, "TakeByConditions", func() error {
+		if len(conds) == 0 {
+			return r.findDB(ctx, opts).Take(dest).Error
+		}
+		return r.findDB(ctx, opts).Where(conds[0], conds[1:]...).Take(dest).Error
+	})
 	if err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to take entity by conditions: %w", err)
+		r.metrics.IncrementOperationsFor("TakeByConditions", false)
+		return r.wrapError(err, "TakeByConditions", "failed to take entity by conditions")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.metrics.IncrementOperationsFor("TakeByConditions", true)
 	return nil
 }
 
@@ -1071,22 +1642,22 @@ func (r *BaseRepository[T]) TakeByConditions(ctx context.Context, dest *T, conds
 func (r *BaseRepository[T]) LastByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "LastByConditions", time.Since(start))
 	}()
 
-	var err error
-	if len(conds) == 0 {
-		err = r.db.WithContext(ctx).Last(dest).Error
-	} else {
-		err = r.db.WithContext(ctx).Where(conds[0], conds[1:]...).Last(dest).Error
-	}
-
+	conds, opts := splitFindOptions(conds)
+	err := r.retry(ctx, "LastByConditions", func() error {
+		if len(conds) == 0 {
+			return r.findUnorderedDB(ctx, opts, "LastByConditions").Last(dest).Error
+		}
+		return r.findUnorderedDB(ctx, opts, "LastByConditions").Where(conds[0], conds[1:]...).Last(dest).Error
+	})
 	if err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to last entity by conditions: %w", err)
+		r.metrics.IncrementOperationsFor("LastByConditions", false)
+		return r.wrapError(err, "LastByConditions", "failed to last entity by conditions")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.metrics.IncrementOperationsFor("LastByConditions", true)
 	return nil
 }
 
@@ -1094,39 +1665,28 @@ func (r *BaseRepository[T]) LastByConditions(ctx context.Context, dest *T, conds
 func (r *BaseRepository[T]) WithTransaction(ctx context.Context, fn func(Repository[T]) error) error {
 	start := time.Now()
 	defer func() {
-		r.metrics.RecordQueryTime(time.Since(start))
+		r.metrics.RecordTableQueryTime(r.tableName, "WithTransaction", time.Since(start))
 	}()
 
 	// Check if function is nil
 	if fn == nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("transaction function cannot be nil")
+		r.metrics.IncrementOperationsFor("WithTransaction", false)
+		return r.argumentError("WithTransaction", "transaction function cannot be nil")
 	}
 
-	var txErr error
-	if err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		txRepo := NewBaseRepository[T](tx, r.logger, r.config)
-
-		// Add panic recovery
-		defer func() {
-			if r := recover(); r != nil {
-				txErr = fmt.Errorf("transaction function panicked: %v", r)
-			}
-		}()
-
-		return fn(txRepo)
-	}); err != nil {
-		r.metrics.IncrementOperations(false)
-		return fmt.Errorf("failed to execute function within transaction: %w", err)
+	panicked, err := r.transaction(ctx, r.db, r.scope(), nil, func(_ context.Context, tx *BaseRepository[T]) error {
+		return fn(tx)
+	})
+	if panicked {
+		r.metrics.IncrementOperationsFor("WithTransaction", false)
+		return err
 	}
-
-	// Check for panic
-	if txErr != nil {
-		r.metrics.IncrementOperations(false)
-		return txErr
+	if err != nil {
+		r.metrics.IncrementOperationsFor("WithTransaction", false)
+		return r.wrapError(err, "WithTransaction", "failed to execute function within transaction")
 	}
 
-	r.metrics.IncrementOperations(true)
+	r.metrics.IncrementOperationsFor("WithTransaction", true)
 	return nil
 }
 
@@ -1142,7 +1702,9 @@ func (r *BaseRepository[T]) Rollback(ctx context.Context) error {
 	return r.db.WithContext(ctx).Rollback().Error //nolint:wrapcheck
 }
 
-// Validate validates an entity
+// Validate validates an entity against the rules of its validate tags
+// (required, email, min, gte, oneof, ...) and the validators registered with
+// AddValidator
 func (r *BaseRepository[T]) Validate(ctx context.Context, entity *T) (*validatorx.ValidationResult, error) {
 	if !r.config.EnableValidation {
 		return &validatorx.ValidationResult{Valid: true}, nil
@@ -1150,16 +1712,208 @@ func (r *BaseRepository[T]) Validate(ctx context.Context, entity *T) (*validator
 
 	// Check if entity is nil
 	if entity == nil {
-		return &validatorx.ValidationResult{Valid: false}, fmt.Errorf("entity cannot be nil")
+		return &validatorx.ValidationResult{Valid: false}, r.argumentError("Validate", "entity cannot be nil")
 	}
 
 	// Use context-aware validation if available
 	result := validatorx.ValidateStructWithContext(ctx, entity)
+
+	// Then the entity validators registered on the repository
+	for _, validator := range r.validators {
+		for _, failure := range validator(ctx, entity) {
+			result.Valid = false
+			result.Errors = append(result.Errors, &validatorx.ValidationError{
+				Field:    failure.Field,
+				Rule:     failure.Rule,
+				Message:  failure.Message,
+				Severity: validatorx.ValidationSeverityError,
+			})
+		}
+	}
 	return result, nil
 }
 
+// validationError records the failed fields of result in the logs and metrics
+// and returns them as a typed ValidationError. Field values are never logged.
+func (r *BaseRepository[T]) validationError(ctx context.Context, operation string, result *validatorx.ValidationResult) *errors.ValidationError {
+	fields := make([]errors.FieldError, 0, len(result.Errors))
+	for _, failure := range result.Errors {
+		if failure == nil {
+			continue
+		}
+
+		fields = append(fields, errors.FieldError{
+			Field:   failure.Field,
+			Rule:    failure.Rule,
+			Message: failure.Message,
+		})
+		r.metrics.RecordValidationFailure(failure.Field, failure.Rule)
+		r.logger.Warn(ctx, "Entity validation failed",
+			logging.String("table", r.tableName),
+			logging.String("operation", operation),
+			logging.String("field", failure.Field),
+			logging.String("rule", failure.Rule),
+			logging.String("value_type", fmt.Sprintf("%T", failure.Value)))
+	}
+
+	return errors.NewFieldValidationError(r.tableName, fields)
+}
+
+// cockroachRestartSavepoint is the savepoint name reserved by CockroachDB's client-side retry protocol
+const cockroachRestartSavepoint = "cockroach_restart"
+
+// runWithRestartSavepoint runs fn inside tx using CockroachDB's client-side retry
+// protocol: serialization failures (SQLSTATE 40001) roll back to the restart
+// savepoint and fn is retried up to MaxTransactionRetries times. The
+// callbacks registered in hooks and the references collected by an attempt
+// rolled back are dropped, and its after rollback callbacks run.
+func (r *BaseRepository[T]) runWithRestartSavepoint(ctx context.Context, tx *gorm.DB, hooks *txHooks, references *deferredReferences, fn func() error) error {
+	if err := setSavepoint(ctx, tx, hooks, cockroachRestartSavepoint); err != nil {
+		return fmt.Errorf("failed to create restart savepoint: %w", err)
+	}
+	var collected map[referenceTarget]map[string]interface{}
+	if references != nil {
+		collected = references.snapshot()
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil {
+			err = tx.Exec("RELEASE SAVEPOINT " + cockroachRestartSavepoint).Error
+			if err == nil {
+				return nil
+			}
+		}
+
+		if !errors.IsSerializationFailure(err) || attempt >= r.config.MaxTransactionRetries {
+			return err
+		}
+
+		r.logger.Warn(ctx, "Retrying transaction after serialization failure",
+			logging.String("table", r.tableName),
+			logging.Int("attempt", attempt+1),
+			logging.ErrorField("error", err))
+
+		if rbErr := rollbackToSavepoint(ctx, tx, hooks, cockroachRestartSavepoint, r.logger); rbErr != nil {
+			return fmt.Errorf("failed to roll back to restart savepoint: %w", rbErr)
+		}
+		if references != nil {
+			references.reset(collected)
+			collected = references.snapshot()
+		}
+	}
+}
+
+// GetMetrics returns a snapshot of the repository metrics, including
+// per-operation counters, latency histograms and success rates
+func (r *BaseRepository[T]) GetMetrics() MetricsSnapshot {
+	return r.metrics.Snapshot()
+}
+
+// WithReadRouter routes the repository's read-only operations (Find*, Count*,
+// Exists* and analytics) through router. Writes and reads inside transactions
+// keep using the repository's connection.
+func (r *BaseRepository[T]) WithReadRouter(router ReadRouter) *BaseRepository[T] {
+	r.router = router
+	return r
+}
+
+// WithMetrics records the metrics of the repository into metrics, shared
+// with other repositories for a breakdown of the latency by table
+func (r *BaseRepository[T]) WithMetrics(metrics *RepositoryMetrics) *BaseRepository[T] {
+	if metrics != nil {
+		r.metrics = metrics
+	}
+	return r
+}
+
+// ResetMetrics resets the repository metrics
+func (r *BaseRepository[T]) ResetMetrics() {
+	r.metrics.Reset()
+}
+
+// Dialect returns the SQL dialect of the underlying connection
+func (r *BaseRepository[T]) Dialect() dialect.Dialect {
+	return r.dialect
+}
+
 // Helper methods
 
+// readDB returns a session for read-only operations, routed to a read replica
+// when a router is set, excluding soft deleted entities unless the context
+// includes them, and applying follower reads when requested by the context
+// and supported by the dialect
+func (r *BaseRepository[T]) readDB(ctx context.Context) *gorm.DB {
+	query := r.db.WithContext(ctx)
+	if r.router != nil && !isTransaction(r.db) {
+		query = r.observe(r.router.ReadDB(ctx))
+	}
+	query = r.scopeDeleted(ctx, query)
+
+	if staleness, ok := followerReadFromContext(ctx); ok && !isTransaction(r.db) {
+		if follower, ok := r.dialect.(dialect.FollowerReader); ok {
+			query = query.Table(fmt.Sprintf("%s %s", r.schemaTable(), follower.AsOfSystemTime(staleness)))
+		}
+	}
+
+	return query
+}
+
+// isTransaction reports whether db is bound to an open transaction
+func isTransaction(db *gorm.DB) bool {
+	if db == nil || db.Statement == nil {
+		return false
+	}
+	_, ok := db.Statement.ConnPool.(gorm.TxCommitter)
+	return ok
+}
+
+// lockForUpdate applies the dialect's row locking strategy to a query
+func (r *BaseRepository[T]) lockForUpdate(query *gorm.DB) *gorm.DB {
+	locking := r.dialect.ForUpdate()
+	if locking.TableHint != "" {
+		query = query.Table(fmt.Sprintf("%s %s", r.schemaTable(), locking.TableHint))
+	}
+	if locking.Suffix != "" {
+		query = query.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate})
+	}
+	return query
+}
+
+// paginate limits query to the page of limit entities from offset. The page
+// is rendered by the dialect when the gorm dialector leaves it to gorm's
+// LIMIT/OFFSET, which SQL Server and Oracle reject, ordered by primary key
+// unless ordered otherwise, as their OFFSET/FETCH requires.
+func (r *BaseRepository[T]) paginate(query *gorm.DB, limit, offset int) *gorm.DB {
+	if _, native := query.ClauseBuilders["LIMIT"]; native || strings.HasPrefix(r.dialect.Paginate(limit, offset), "LIMIT") {
+		return query.Limit(limit).Offset(offset)
+	}
+	if _, ordered := query.Statement.Clauses["ORDER BY"]; !ordered {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: clause.PrimaryKey}})
+	}
+	return query.Clauses(dialectPage{dialect: r.dialect, limit: limit, offset: offset})
+}
+
+// dialectPage is a LIMIT clause rendered by a dialect
+type dialectPage struct {
+	dialect       dialect.Dialect
+	limit, offset int
+}
+
+// Name returns the name of the clause it replaces
+func (dialectPage) Name() string { return "LIMIT" }
+
+// Build renders the page
+func (p dialectPage) Build(builder clause.Builder) {
+	builder.WriteString(p.dialect.Paginate(p.limit, p.offset))
+}
+
+// MergeClause replaces the LIMIT clause of the statement
+func (p dialectPage) MergeClause(c *clause.Clause) {
+	c.Name = ""
+	c.Expression = p
+}
+
 // validateOffsetPaginationParams validates and normalizes pagination parameters
 func (r *BaseRepository[T]) validateOffsetPaginationParams(limit, offset int) (int, int) {
 	limit = r.validateLimit(limit)
@@ -1196,22 +1950,9 @@ func (r *BaseRepository[T]) validateCursorPaginationParams(cursor string, limit
 }
 
 // getEntityID extracts ID from entity
-func (r *BaseRepository[T]) getEntityID(entity *T) uuid.UUID {
+func (r *BaseRepository[T]) getEntityID(entity *T) models.EntityID {
 	if entity == nil {
-		return uuid.Nil
+		return nil
 	}
-
-	// Try to get ID using reflection
-	val := reflect.ValueOf(entity)
-	if val.Kind() == reflect.Ptr {
-		val = val.Elem()
-	}
-
-	if idField := val.FieldByName("ID"); idField.IsValid() {
-		if id, ok := idField.Interface().(uuid.UUID); ok {
-			return id
-		}
-	}
-
-	return uuid.Nil
+	return entityIDOf(entity)
 }
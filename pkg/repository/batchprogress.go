@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/logging"
+)
+
+// BatchProgress reports the progress of CreateInBatches after each batch
+type BatchProgress struct {
+	// Batch is the position of the batch just written
+	Batch int `json:"batch"`
+	// Done is the number of entities written so far
+	Done int `json:"done"`
+	// Total is the number of entities to write
+	Total int `json:"total"`
+	// Elapsed is the time since the write started
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// batchProgressKey is the context key of the progress callback of
+// CreateInBatches
+type batchProgressKey struct{}
+
+// WithBatchProgress makes CreateInBatches with the returned context write
+// its batches one statement each, committed as they go, instead of all or
+// none, calling progress (which may be nil) after every batch:
+//
+//	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+//	defer cancel()
+//	ctx = repository.WithBatchProgress(ctx, func(p repository.BatchProgress) {
+//		log.Printf("%d/%d rows in %s", p.Done, p.Total, p.Elapsed)
+//	})
+//	if err := repo.CreateInBatches(ctx, rows, 1000); err != nil {
+//		if batchErr, ok := errors.AsBatchError(err); ok {
+//			rows = rows[batchErr.ResumeIndex():] // retry these later
+//		}
+//	}
+//
+// The context is checked between batches: a batch is not started once it is
+// done, nor when its deadline is nearer than the average batch takes. The
+// write then stops with an errors.BatchError whose ResumeIndex is the index
+// of the first entity not written.
+func WithBatchProgress(ctx context.Context, progress func(BatchProgress)) context.Context {
+	if progress == nil {
+		progress = func(BatchProgress) {}
+	}
+	return context.WithValue(ctx, batchProgressKey{}, progress)
+}
+
+// batchProgressFrom returns the progress callback of ctx, if any
+func batchProgressFrom(ctx context.Context) (func(BatchProgress), bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	progress, ok := ctx.Value(batchProgressKey{}).(func(BatchProgress))
+	return progress, ok
+}
+
+// nextBatchErr returns why the next batch of a write started at start, having
+// written done batches, must not start: the context is done or its deadline
+// is nearer than the average batch takes
+func nextBatchErr(ctx context.Context, start time.Time, done int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok || done == 0 {
+		return nil
+	}
+	if average := time.Since(start) / time.Duration(done); time.Until(deadline) < average {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// createInResumableBatches creates entities one committed batch at a time,
+// reporting progress after each and stopping at the first failure or when
+// the context leaves no time for the next batch
+func (r *BaseRepository[T]) createInResumableBatches(ctx context.Context, entities []T, batchSize int, progress func(BatchProgress)) error {
+	start := time.Now()
+
+	var failure *errors.BatchFailure
+	done, batch := 0, 0
+	for offset := 0; offset < len(entities); offset, batch = offset+batchSize, batch+1 {
+		end := offset + batchSize
+		if end > len(entities) {
+			end = len(entities)
+		}
+		rows := entities[offset:end]
+
+		if err := nextBatchErr(ctx, start, batch); err != nil {
+			failure = &errors.BatchFailure{
+				Batch:  batch,
+				Offset: offset,
+				Size:   len(entities) - offset,
+				Err:    r.wrapError(err, "CreateInBatches", fmt.Sprintf("batch %d not started", batch)),
+			}
+			break
+		}
+		if err := r.retry(ctx, "CreateInBatches", func() error {
+			return r.db.WithContext(ctx).Create(&rows).Error
+		}); err != nil {
+			failure = &errors.BatchFailure{
+				Batch:  batch,
+				Offset: offset,
+				Size:   len(rows),
+				Err:    r.wrapError(err, "CreateInBatches", fmt.Sprintf("failed to create entities of batch %d", batch)),
+			}
+			break
+		}
+
+		for i := range rows {
+			r.deferReferences(ctx, &rows[i])
+		}
+		done = end
+		progress(BatchProgress{Batch: batch, Done: done, Total: len(entities), Elapsed: time.Since(start)})
+	}
+
+	written := make([]*T, done)
+	for i := range written {
+		written[i] = &entities[i]
+	}
+	r.cacheWritten(ctx, written...)
+	var notifyErr error
+	for _, entity := range written {
+		if notifyErr = r.notifyChange(ctx, ChangeCreate, r.getEntityID(entity), nil, entity); notifyErr != nil {
+			break
+		}
+	}
+
+	if failure != nil {
+		r.metrics.IncrementOperationsFor("CreateInBatches", false)
+		r.logger.Error(ctx, "Batch create stopped",
+			logging.String("table", r.tableName),
+			logging.Int("batch_size", batchSize),
+			logging.Int("created", done),
+			logging.Int("total_entities", len(entities)),
+			logging.ErrorField("error", failure.Err))
+		batchErr := errors.NewBatchError("CreateInBatches", r.tableName, len(entities), done, []errors.BatchFailure{*failure})
+		if notifyErr != nil {
+			return stderrors.Join(batchErr, notifyErr)
+		}
+		return batchErr
+	}
+	if notifyErr != nil {
+		r.metrics.IncrementOperationsFor("CreateInBatches", false)
+		return notifyErr
+	}
+
+	r.metrics.IncrementOperationsFor("CreateInBatches", true)
+	r.logger.Info(ctx, "Entities created successfully",
+		logging.String("table", r.tableName),
+		logging.Int("batch_size", batchSize),
+		logging.Int("total_entities", len(entities)))
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/logging"
+)
+
+// BatchValidator checks rules spanning the entities of a batch, such as
+// uniqueness within the batch, and returns the violations found, nil when the
+// batch is valid. Rows are reported by their position in entities.
+type BatchValidator[T any] func(ctx context.Context, entities []T) []errors.BatchViolation
+
+// EntityValidator checks an entity beyond the rules of its validate tags and
+// returns the failures found, nil when the entity is valid
+type EntityValidator[T any] func(ctx context.Context, entity *T) []errors.FieldError
+
+// AddValidator registers validator to be run by Validate, after the rules
+// of the validate tags, so every operation validating entities applies it.
+// Like tag rules, entity validators only run when validation is enabled.
+func (r *BaseRepository[T]) AddValidator(validator EntityValidator[T]) *BaseRepository[T] {
+	if validator != nil {
+		r.validators = append(r.validators, validator)
+	}
+	return r
+}
+
+// AddBatchValidator registers validator to be run by ValidateBatch,
+// CreateInBatches, CreateInBatchesIgnoreConflicts, UpsertInBatches and
+// UpsertInBatchesByConditions on the whole batch before any SQL executes.
+// Batch validators run whether or not entity validation is enabled.
+func (r *BaseRepository[T]) AddBatchValidator(validator BatchValidator[T]) *BaseRepository[T] {
+	if validator != nil {
+		r.batchValidators = append(r.batchValidators, validator)
+	}
+	return r
+}
+
+// ValidateBatch validates every entity of a batch, as Validate does, then
+// runs the batch validators on the whole batch. It returns nil when the batch
+// is valid, else an errors.BatchValidationError holding the failures of each
+// invalid entity, with its index, and the cross-row violations. The errors
+// of the entities are also matched by errors.AsValidationError.
+func (r *BaseRepository[T]) ValidateBatch(ctx context.Context, entities []T) error {
+	return r.validateBatch(ctx, "ValidateBatch", entities, true)
+}
+
+// validateBatch validates the entities of a batch, one by one when entityRules
+// is set, then as a whole
+func (r *BaseRepository[T]) validateBatch(ctx context.Context, operation string, entities []T, entityRules bool) error {
+	if len(entities) == 0 {
+		return nil
+	}
+
+	var invalid []*errors.ValidationError
+	if entityRules && r.config.EnableValidation {
+		for i := range entities {
+			result, err := r.Validate(ctx, &entities[i])
+			if err != nil {
+				return r.wrapError(err, operation, fmt.Sprintf("validation failed for entity %d", i))
+			}
+			if !result.Valid {
+				invalid = append(invalid, r.validationError(ctx, operation, result).WithIndex(i))
+			}
+		}
+	}
+
+	var violations []errors.BatchViolation
+	for _, validator := range r.batchValidators {
+		violations = append(violations, validator(ctx, entities)...)
+	}
+	for _, violation := range violations {
+		r.metrics.RecordValidationFailure(violation.Field, violation.Rule)
+		r.logger.Warn(ctx, "Batch validation failed",
+			logging.String("table", r.tableName),
+			logging.String("operation", operation),
+			logging.String("field", violation.Field),
+			logging.String("rule", violation.Rule),
+			logging.Int("rows", len(violation.Rows)))
+	}
+
+	if len(invalid) == 0 && len(violations) == 0 {
+		return nil
+	}
+	return errors.NewBatchValidationError(r.tableName, violations).WithEntityErrors(invalid)
+}
+
+// UniqueInBatch returns a batch validator rejecting entities sharing the
+// values of fields, named as in the Go struct, with another entity of the
+// batch. Each group of duplicates is one violation listing its rows. Entities
+// whose fields are all zero are not compared.
+func UniqueInBatch[T any](fields ...string) BatchValidator[T] {
+	field := strings.Join(fields, ",")
+	return func(ctx context.Context, entities []T) []errors.BatchViolation {
+		groups := make(map[string][]int)
+		var keys []string
+		for i := range entities {
+			row := reflect.ValueOf(&entities[i]).Elem()
+			values := make([]interface{}, len(fields))
+			zero := true
+			for j, name := range fields {
+				value := fieldByName(row, name)
+				if !value.IsValid() {
+					return []errors.BatchViolation{{Rule: "unique_in_batch", Field: name, Message: "unknown field", Rows: []int{i}}}
+				}
+				zero = zero && value.IsZero()
+				values[j] = value.Interface()
+			}
+			if zero {
+				continue
+			}
+
+			key := fmt.Sprintf("%#v", values)
+			if _, ok := groups[key]; !ok {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], i)
+		}
+
+		var violations []errors.BatchViolation
+		for _, key := range keys {
+			if rows := groups[key]; len(rows) > 1 {
+				violations = append(violations, errors.BatchViolation{
+					Rule:    "unique_in_batch",
+					Field:   field,
+					Message: "duplicate value within the batch",
+					Rows:    rows,
+				})
+			}
+		}
+		return violations
+	}
+}
+
+// SumInBatch returns a batch validator rejecting batches whose numeric field,
+// named as in the Go struct, sums to more than max across the entities. The
+// violation lists the rows with a non-zero value.
+func SumInBatch[T any](field string, max float64) BatchValidator[T] {
+	return func(ctx context.Context, entities []T) []errors.BatchViolation {
+		var sum float64
+		var rows []int
+		for i := range entities {
+			value := fieldByName(reflect.ValueOf(&entities[i]).Elem(), field)
+			number, ok := numericValue(value)
+			if !ok {
+				return []errors.BatchViolation{{Rule: "sum_in_batch", Field: field, Message: "not a numeric field", Rows: []int{i}}}
+			}
+			if number != 0 {
+				sum += number
+				rows = append(rows, i)
+			}
+		}
+		if sum <= max {
+			return nil
+		}
+		return []errors.BatchViolation{{
+			Rule:    "sum_in_batch",
+			Field:   field,
+			Message: fmt.Sprintf("sum %g exceeds %g", sum, max),
+			Rows:    rows,
+		}}
+	}
+}
+
+// fieldByName returns the field name of the struct row, following pointers
+// to embedded structs; the zero Value when there is none
+func fieldByName(row reflect.Value, name string) reflect.Value {
+	if row.Kind() == reflect.Ptr {
+		if row.IsNil() {
+			return reflect.Value{}
+		}
+		row = row.Elem()
+	}
+	if row.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	field, ok := row.Type().FieldByName(name)
+	if !ok {
+		return reflect.Value{}
+	}
+	value, err := row.FieldByIndexErr(field.Index)
+	if err != nil {
+		return reflect.Value{}
+	}
+	return value
+}
+
+// numericValue returns value as a float64, dereferencing pointers; nil
+// pointers are zero
+func numericValue(value reflect.Value) (float64, bool) {
+	for value.IsValid() && value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return 0, true
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	}
+	return 0, false
+}
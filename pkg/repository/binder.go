@@ -0,0 +1,375 @@
+package repository
+
+import (
+	stderrors "errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ConditionBinder is a gorm plugin checking the parameters of the WHERE
+// conditions of queries, counts, updates and deletes against the types of
+// the columns they are compared with, before the SQL is built. String
+// parameters are converted to the column type: UUIDs for UUID columns,
+// RFC 3339 timestamps for time columns, numbers and booleans; string columns
+// declaring an enum with a `validate:"oneof=..."` tag only accept its values.
+// A parameter that does not fit fails the statement with a validation error
+// (errors.ValidationError) naming the column and the parameter position,
+// instead of a driver conversion error or an empty result.
+//
+// Parameters are matched with the columns of conditions such as
+// "status = ?", "created_at BETWEEN ? AND ?" and "id IN ?", and of map and
+// struct conditions. Parameters compared with expressions, unknown columns,
+// LIKE patterns and subqueries are left to the database, except for typed
+// conditions (Eq, In, ...) naming a column the model does not have, which
+// fail the statement.
+type ConditionBinder struct{}
+
+// NewConditionBinder creates a condition binder
+func NewConditionBinder() *ConditionBinder {
+	return &ConditionBinder{}
+}
+
+// Name returns the plugin name
+func (b *ConditionBinder) Name() string {
+	return "ormx:condition_binder"
+}
+
+// Initialize registers the binder callbacks before the statements of db
+func (b *ConditionBinder) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	err := stderrors.Join(
+		callbacks.Query().Before("gorm:query").Register("ormx:bind_query", b.bind),
+		callbacks.Row().Before("gorm:row").Register("ormx:bind_row", b.bind),
+		callbacks.Update().Before("gorm:begin_transaction").Register("ormx:bind_update", b.bind),
+		callbacks.Delete().Before("gorm:begin_transaction").Register("ormx:bind_delete", b.bind),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register condition binder callbacks: %w", err)
+	}
+	return nil
+}
+
+// bind checks and converts the WHERE parameters of the statement
+func (b *ConditionBinder) bind(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil {
+		return
+	}
+	c, ok := stmt.Clauses["WHERE"]
+	if !ok {
+		return
+	}
+	where, ok := c.Expression.(clause.Where)
+	if !ok {
+		return
+	}
+
+	exprs, err := b.bindAll(stmt.Schema, where.Exprs)
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	c.Expression = clause.Where{Exprs: exprs}
+	stmt.Clauses["WHERE"] = c
+}
+
+// bindAll binds a list of expressions, copying it so the expressions of the
+// session the statement was built from are left alone
+func (b *ConditionBinder) bindAll(s *schema.Schema, exprs []clause.Expression) ([]clause.Expression, error) {
+	bound := make([]clause.Expression, len(exprs))
+	for i, expr := range exprs {
+		var err error
+		if bound[i], err = b.bindExpr(s, expr); err != nil {
+			return nil, err
+		}
+	}
+	return bound, nil
+}
+
+// bindExpr binds the parameters of an expression
+func (b *ConditionBinder) bindExpr(s *schema.Schema, expr clause.Expression) (clause.Expression, error) {
+	switch e := expr.(type) {
+	case clause.Expr:
+		columns := placeholderColumns(e.SQL)
+		vars := make([]interface{}, len(e.Vars))
+		copy(vars, e.Vars)
+		for i := range vars {
+			if i >= len(columns) || columns[i] == "" {
+				continue
+			}
+			field := s.LookUpField(columns[i])
+			if field == nil {
+				continue
+			}
+			value, err := bindParameter(s, field, i, vars[i])
+			if err != nil {
+				return nil, err
+			}
+			vars[i] = value
+		}
+		e.Vars = vars
+		return e, nil
+	case clause.Eq:
+		return bindComparison(s, e.Column, e.Value, func(v interface{}) clause.Expression { e.Value = v; return e })
+	case clause.Neq:
+		return bindComparison(s, e.Column, e.Value, func(v interface{}) clause.Expression { e.Value = v; return e })
+	case clause.Gt:
+		return bindComparison(s, e.Column, e.Value, func(v interface{}) clause.Expression { e.Value = v; return e })
+	case clause.Gte:
+		return bindComparison(s, e.Column, e.Value, func(v interface{}) clause.Expression { e.Value = v; return e })
+	case clause.Lt:
+		return bindComparison(s, e.Column, e.Value, func(v interface{}) clause.Expression { e.Value = v; return e })
+	case clause.Lte:
+		return bindComparison(s, e.Column, e.Value, func(v interface{}) clause.Expression { e.Value = v; return e })
+	case clause.IN:
+		field := lookupColumn(s, e.Column)
+		if field == nil {
+			return e, nil
+		}
+		values := make([]interface{}, len(e.Values))
+		for i, value := range e.Values {
+			var err error
+			if values[i], err = bindParameter(s, field, i, value); err != nil {
+				return nil, err
+			}
+		}
+		e.Values = values
+		return e, nil
+	case clause.AndConditions:
+		exprs, err := b.bindAll(s, e.Exprs)
+		e.Exprs = exprs
+		return e, err
+	case clause.OrConditions:
+		exprs, err := b.bindAll(s, e.Exprs)
+		e.Exprs = exprs
+		return e, err
+	case clause.NotConditions:
+		exprs, err := b.bindAll(s, e.Exprs)
+		e.Exprs = exprs
+		return e, err
+	case clause.Where:
+		exprs, err := b.bindAll(s, e.Exprs)
+		e.Exprs = exprs
+		return e, err
+	case Cond:
+		return b.bindCond(s, e)
+	}
+	return expr, nil
+}
+
+// bindCond binds the parameters of a composable condition
+func (b *ConditionBinder) bindCond(s *schema.Schema, c Cond) (Cond, error) {
+	if c.column != "" {
+		if err := checkColumns(s, c); err != nil {
+			return c, err
+		}
+	}
+	if c.expr != nil {
+		expr, err := b.bindExpr(s, c.expr)
+		if err != nil {
+			return c, err
+		}
+		c.expr = expr
+	}
+	if len(c.conds) > 0 {
+		conds := make([]Cond, len(c.conds))
+		for i, cond := range c.conds {
+			var err error
+			if conds[i], err = b.bindCond(s, cond); err != nil {
+				return c, err
+			}
+		}
+		c.conds = conds
+	}
+	return c, nil
+}
+
+// bindComparison binds the value compared with a column
+func bindComparison(s *schema.Schema, column, value interface{}, rebuild func(interface{}) clause.Expression) (clause.Expression, error) {
+	field := lookupColumn(s, column)
+	if field == nil {
+		return rebuild(value), nil
+	}
+	bound, err := bindParameter(s, field, 0, value)
+	if err != nil {
+		return nil, err
+	}
+	return rebuild(bound), nil
+}
+
+// lookupColumn returns the field of a clause column, nil for expressions
+func lookupColumn(s *schema.Schema, column interface{}) *schema.Field {
+	switch c := column.(type) {
+	case clause.Column:
+		if c.Raw {
+			return nil
+		}
+		return s.LookUpField(c.Name)
+	case string:
+		if i := strings.LastIndexByte(c, '.'); i >= 0 {
+			c = c[i+1:]
+		}
+		return s.LookUpField(strings.Trim(c, "`\"[]"))
+	}
+	return nil
+}
+
+// placeholderComparison matches a column compared with the placeholder ending
+// a condition prefix, through an operator, BETWEEN or the list of IN
+var placeholderComparison = regexp.MustCompile(`(?i)([A-Za-z_][A-Za-z0-9_]*)[` + "`" + `"\]]?\s*(=|<>|!=|<=|>=|<|>|\bNOT\s+LIKE\b|\bI?LIKE\b|\bNOT\s+IN\b|\bIN\b|\bNOT\s+BETWEEN\b|\bBETWEEN\b|\bBETWEEN\s+\?\s+AND\b)\s*\(?(?:\s*\?\s*,)*\s*$`)
+
+// placeholderColumns returns the column compared with each placeholder of a
+// condition, empty when it is not a plain column or compared with LIKE
+func placeholderColumns(sql string) []string {
+	var columns []string
+	quoted := false
+	for i := 0; i < len(sql); i++ {
+		switch sql[i] {
+		case '\'':
+			quoted = !quoted
+		case '?':
+			if quoted {
+				continue
+			}
+			column := ""
+			if match := placeholderComparison.FindStringSubmatch(sql[:i]); match != nil && !strings.Contains(strings.ToUpper(match[2]), "LIKE") {
+				column = match[1]
+			}
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// timeType is the type of time columns
+var timeType = reflect.TypeOf(time.Time{})
+
+// bindParameter converts the parameter at position of a condition to the
+// type of the column of field, or fails with a validation error
+func bindParameter(s *schema.Schema, field *schema.Field, position int, value interface{}) (interface{}, error) {
+	fail := func(rule, format string, args ...interface{}) error {
+		message := fmt.Sprintf("parameter %d: %s", position+1, fmt.Sprintf(format, args...))
+		return errors.NewFieldValidationError(s.Table, []errors.FieldError{{Field: field.DBName, Rule: rule, Message: message}})
+	}
+
+	switch value.(type) {
+	case nil, clause.Expression, *gorm.DB, []byte:
+		return value, nil
+	}
+
+	// Lists, e.g. of IN conditions, are bound element by element
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		if rv.Type() == reflect.TypeOf(uuid.UUID{}) {
+			return value, nil
+		}
+		values := make([]interface{}, rv.Len())
+		for i := range values {
+			bound, err := bindParameter(s, field, position, rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			values[i] = bound
+		}
+		return values, nil
+	}
+
+	switch field.IndirectFieldType {
+	case uuidType:
+		switch v := value.(type) {
+		case uuid.UUID, *uuid.UUID:
+			return value, nil
+		case string:
+			id, err := uuid.Parse(v)
+			if err != nil {
+				return nil, fail("uuid", "must be a UUID")
+			}
+			return id, nil
+		}
+		return nil, fail("uuid", "must be a UUID, got %T", value)
+	case timeType:
+		switch v := value.(type) {
+		case time.Time, *time.Time:
+			return value, nil
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return nil, fail("datetime", "must be an RFC 3339 timestamp")
+			}
+			return t, nil
+		}
+		return nil, fail("datetime", "must be a time, got %T", value)
+	}
+
+	text, isText := value.(string)
+	switch field.IndirectFieldType.Kind() {
+	case reflect.String:
+		if isText {
+			if values := enumValues(field); len(values) > 0 && !contains(values, text) {
+				return nil, fail("oneof", "must be one of [%s]", strings.Join(values, " "))
+			}
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if isText {
+			n, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+			if err != nil {
+				return nil, fail("number", "must be an integer")
+			}
+			return n, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if isText {
+			n, err := strconv.ParseUint(strings.TrimSpace(text), 10, 64)
+			if err != nil {
+				return nil, fail("number", "must be a non-negative integer")
+			}
+			return n, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if isText {
+			n, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+			if err != nil {
+				return nil, fail("number", "must be a number")
+			}
+			return n, nil
+		}
+	case reflect.Bool:
+		if isText {
+			b, err := strconv.ParseBool(strings.TrimSpace(text))
+			if err != nil {
+				return nil, fail("boolean", "must be a boolean")
+			}
+			return b, nil
+		}
+	}
+	return value, nil
+}
+
+// enumValues returns the values of the oneof rule of the validate tag of field
+func enumValues(field *schema.Field) []string {
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		if values, ok := strings.CutPrefix(rule, "oneof="); ok {
+			return strings.Fields(values)
+		}
+	}
+	return nil
+}
+
+// contains reports whether values holds value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
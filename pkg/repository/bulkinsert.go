@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	stderrors "errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/schema"
+)
+
+// bulkLoader loads rows into a table through the bulk protocol of a driver
+type bulkLoader interface {
+	// supports reports whether the connection of db can bulk load
+	supports(db *gorm.DB) bool
+	// load loads rows of the given columns into table and returns the number
+	// of rows loaded; errBulkUnsupported means nothing was loaded and the
+	// rows must be inserted otherwise
+	load(ctx context.Context, db *gorm.DB, table string, columns []string, rows [][]interface{}) (int64, error)
+	// skipsDuplicates reports whether load skips the rows duplicating a
+	// unique key instead of failing
+	skipsDuplicates() bool
+}
+
+// errBulkUnsupported is returned by bulk loaders refused by the server
+var errBulkUnsupported = stderrors.New("bulk load not supported")
+
+var (
+	bulkLoadersMu sync.RWMutex
+	// bulkLoaders are the loaders by gorm dialector name
+	bulkLoaders = map[string]bulkLoader{}
+)
+
+// registerBulkLoader registers the loader of the connections of dialector
+func registerBulkLoader(dialector string, loader bulkLoader) {
+	bulkLoadersMu.Lock()
+	defer bulkLoadersMu.Unlock()
+	bulkLoaders[dialector] = loader
+}
+
+// bulkLoaderFor returns the loader of the connection of db, if it has one
+func bulkLoaderFor(db *gorm.DB) (bulkLoader, bool) {
+	bulkLoadersMu.RLock()
+	loader, ok := bulkLoaders[db.Dialector.Name()]
+	bulkLoadersMu.RUnlock()
+	if !ok || !loader.supports(db) {
+		return nil, false
+	}
+	return loader, true
+}
+
+// BulkInsertFast inserts entities through the bulk load protocol of the
+// driver, bypassing row by row inserts for very large imports: COPY FROM on
+// PostgreSQL and CockroachDB, LOAD DATA LOCAL INFILE on MySQL (the server
+// must allow local_infile). Other drivers, builds tagged ormx_minimal and
+// PostgreSQL transactions fall back to CreateInBatches, as does MySQL when
+// the server refuses LOAD DATA LOCAL, without validating the entities or
+// running their before hooks again.
+//
+// The entities are validated as by CreateInBatches and loaded in one
+// statement, all or none. Their BeforeSave, BeforeCreate, AfterCreate and
+// AfterSave hooks run and their zero creation and update times are set, but
+// the callbacks registered on gorm, such as the actor stamper and the
+// default values, do not run, nor are associations saved. IDs assigned by
+// the database are not read back. On MySQL, rows duplicating a unique key
+// are skipped by the server: the result counts them, and the after hooks,
+// cache and change hooks only see the rows loaded.
+func (r *BaseRepository[T]) BulkInsertFast(ctx context.Context, entities []T) (*IngestResult, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "BulkInsertFast", time.Since(start))
+	}()
+
+	if len(entities) == 0 {
+		r.metrics.IncrementOperationsFor("BulkInsertFast", false)
+		return nil, r.argumentError("BulkInsertFast", "entities cannot be empty")
+	}
+
+	db := r.db.WithContext(ctx)
+	loader, ok := bulkLoaderFor(db)
+	if !ok {
+		return r.bulkInsertFallback(ctx, entities)
+	}
+
+	// Validate each entity and the whole batch
+	if err := r.validateBatch(ctx, "BulkInsertFast", entities, true); err != nil {
+		r.metrics.IncrementOperationsFor("BulkInsertFast", false)
+		return nil, err
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		r.metrics.IncrementOperationsFor("BulkInsertFast", false)
+		return nil, r.wrapError(err, "BulkInsertFast", "failed to parse entity schema")
+	}
+
+	// Entities given an ID by the caller may duplicate a stored row, which
+	// loaders skipping duplicates leave in place
+	var existing map[string]bool
+	if loader.skipsDuplicates() {
+		var err error
+		if existing, err = r.storedIDs(ctx, entities); err != nil {
+			r.metrics.IncrementOperationsFor("BulkInsertFast", false)
+			return nil, r.wrapError(err, "BulkInsertFast", "failed to read existing entities")
+		}
+	}
+
+	columns, rows, err := bulkRows(db, stmt.Schema, pointers(entities))
+	if err != nil {
+		r.metrics.IncrementOperationsFor("BulkInsertFast", false)
+		return nil, r.wrapError(err, "BulkInsertFast", "failed to prepare entities")
+	}
+
+	var loaded int64
+	err = r.retry(ctx, "BulkInsertFast", func() error {
+		var err error
+		loaded, err = loader.load(ctx, db, stmt.Schema.Table, columns, rows)
+		return err
+	})
+	if stderrors.Is(err, errBulkUnsupported) {
+		r.logger.Warn(ctx, "Bulk load refused, inserting in batches",
+			logging.String("table", r.tableName),
+			logging.ErrorField("error", err))
+		// The entities are validated and their before hooks ran already
+		loaded = int64(len(entities))
+		err = r.retry(ctx, "BulkInsertFast", func() error {
+			return db.Session(&gorm.Session{SkipHooks: true}).CreateInBatches(entities, r.bulkBatchSize()).Error
+		})
+	}
+	if err != nil {
+		r.metrics.IncrementOperationsFor("BulkInsertFast", false)
+		return nil, r.wrapError(err, "BulkInsertFast", "failed to bulk insert entities")
+	}
+
+	ingested := &IngestResult{Inserted: loaded, Skipped: int64(len(entities)) - loaded}
+	var stored map[string]bool
+	if ingested.Skipped > 0 {
+		if stored, err = r.storedIDs(ctx, entities); err != nil {
+			r.invalidateDependents(ctx)
+			r.queryCacheInvalidate(ctx)
+			r.metrics.IncrementOperationsFor("BulkInsertFast", false)
+			return ingested, r.wrapError(err, "BulkInsertFast", "failed to read loaded entities")
+		}
+	}
+
+	written := make([]*T, 0, loaded)
+	for i := range entities {
+		if stored != nil {
+			id := idString(r.getEntityID(&entities[i]))
+			if !stored[id] || existing[id] {
+				continue
+			}
+		}
+		if err := afterCreateHooks(db, &entities[i]); err != nil {
+			r.metrics.IncrementOperationsFor("BulkInsertFast", false)
+			return ingested, r.wrapError(err, "BulkInsertFast", "after create hook failed")
+		}
+		r.deferReferences(ctx, &entities[i])
+		written = append(written, &entities[i])
+	}
+	r.cacheWritten(ctx, written...)
+	for _, entity := range written {
+		if err := r.notifyChange(ctx, ChangeCreate, r.getEntityID(entity), nil, entity); err != nil {
+			r.metrics.IncrementOperationsFor("BulkInsertFast", false)
+			return ingested, err
+		}
+	}
+
+	r.metrics.IncrementOperationsFor("BulkInsertFast", true)
+	r.logger.Info(ctx, "Entities bulk inserted successfully",
+		logging.String("table", r.tableName),
+		logging.Int64("inserted", ingested.Inserted),
+		logging.Int64("skipped", ingested.Skipped))
+
+	return ingested, nil
+}
+
+// bulkInsertFallback inserts entities with CreateInBatches
+func (r *BaseRepository[T]) bulkInsertFallback(ctx context.Context, entities []T) (*IngestResult, error) {
+	if err := r.CreateInBatches(ctx, entities, r.bulkBatchSize()); err != nil {
+		return nil, err
+	}
+	return &IngestResult{Inserted: int64(len(entities))}, nil
+}
+
+// bulkBatchSize returns the batch size of the fallback of BulkInsertFast:
+// the configured CreateBatchSize or 1000 rows
+func (r *BaseRepository[T]) bulkBatchSize() int {
+	if r.config.CreateBatchSize > 0 {
+		return r.config.CreateBatchSize
+	}
+	return 1000
+}
+
+// bulkRows runs the before hooks of entities, sets their zero creation and
+// update times, and returns the columns inserted and the values of each
+// entity. Columns with a database default are only inserted when an entity
+// sets them, as gorm does.
+func bulkRows[T any](db *gorm.DB, s *schema.Schema, entities []*T) ([]string, [][]interface{}, error) {
+	ctx := db.Statement.Context
+	now := db.NowFunc()
+
+	values := make([]reflect.Value, len(entities))
+	for i := range entities {
+		if err := beforeCreateHooks(db, entities[i]); err != nil {
+			return nil, nil, err
+		}
+		values[i] = reflect.ValueOf(entities[i]).Elem()
+		for _, field := range s.Fields {
+			if field.DBName == "" || (field.AutoCreateTime == 0 && field.AutoUpdateTime == 0) {
+				continue
+			}
+			if _, zero := field.ValueOf(ctx, values[i]); zero {
+				if err := field.Set(ctx, values[i], now); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+
+	var fields []*schema.Field
+	for _, name := range s.DBNames {
+		field := s.FieldsByDBName[name]
+		if !field.Creatable {
+			continue
+		}
+		if field.HasDefaultValue && field.DefaultValueInterface == nil && !anySet(ctx, field, values) {
+			continue
+		}
+		fields = append(fields, field)
+	}
+
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = field.DBName
+	}
+	rows := make([][]interface{}, len(values))
+	for i, value := range values {
+		row := make([]interface{}, len(fields))
+		for j, field := range fields {
+			fieldValue, _ := field.ValueOf(ctx, value)
+			converted, err := driver.DefaultParameterConverter.ConvertValue(fieldValue)
+			if err != nil {
+				return nil, nil, fmt.Errorf("column %s: %w", field.DBName, err)
+			}
+			row[j] = converted
+		}
+		rows[i] = row
+	}
+	return columns, rows, nil
+}
+
+// anySet reports whether field is set on any of values
+func anySet(ctx context.Context, field *schema.Field, values []reflect.Value) bool {
+	for _, value := range values {
+		if _, zero := field.ValueOf(ctx, value); !zero {
+			return true
+		}
+	}
+	return false
+}
+
+// beforeCreateHooks runs the BeforeSave and BeforeCreate hooks of entity
+func beforeCreateHooks(db *gorm.DB, entity interface{}) error {
+	if hook, ok := entity.(callbacks.BeforeSaveInterface); ok {
+		if err := hook.BeforeSave(db); err != nil {
+			return err
+		}
+	}
+	if hook, ok := entity.(callbacks.BeforeCreateInterface); ok {
+		return hook.BeforeCreate(db)
+	}
+	return nil
+}
+
+// afterCreateHooks runs the AfterCreate and AfterSave hooks of entity
+func afterCreateHooks(db *gorm.DB, entity interface{}) error {
+	if hook, ok := entity.(callbacks.AfterCreateInterface); ok {
+		if err := hook.AfterCreate(db); err != nil {
+			return err
+		}
+	}
+	if hook, ok := entity.(callbacks.AfterSaveInterface); ok {
+		return hook.AfterSave(db)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+//go:build !ormx_minimal
+
+package repository
+
+import (
+	"bufio"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// Bulk loaders of the server drivers, which are not bundled in builds tagged
+// ormx_minimal
+func init() {
+	registerBulkLoader("postgres", copyFromLoader{})
+	registerBulkLoader("mysql", loadDataLoader{})
+}
+
+// copyFromLoader loads rows with COPY FROM through a pgx connection
+type copyFromLoader struct{}
+
+// supports reports whether db is not a transaction, whose connection
+// database/sql does not hand out
+func (copyFromLoader) supports(db *gorm.DB) bool {
+	_, inTx := db.Statement.ConnPool.(gorm.TxCommitter)
+	return !inTx
+}
+
+// skipsDuplicates is false: COPY FROM fails on duplicates
+func (copyFromLoader) skipsDuplicates() bool {
+	return false
+}
+
+// load copies rows into table on a connection of the pool
+func (copyFromLoader) load(ctx context.Context, db *gorm.DB, table string, columns []string, rows [][]interface{}) (int64, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errBulkUnsupported, err)
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var copied int64
+	err = conn.Raw(func(driverConn interface{}) error {
+		pgxConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("%w: driver connection %T is not pgx", errBulkUnsupported, driverConn)
+		}
+		var err error
+		copied, err = pgxConn.Conn().CopyFrom(ctx, pgx.Identifier(strings.Split(table, ".")), columns, pgx.CopyFromRows(rows))
+		return err
+	})
+	return copied, err
+}
+
+// loadDataLoader loads rows with LOAD DATA LOCAL INFILE, streaming them as
+// tab separated values
+type loadDataLoader struct{}
+
+// supports reports whether db connects through the go-sql-driver driver
+func (loadDataLoader) supports(db *gorm.DB) bool {
+	dialector, ok := db.Dialector.(*gormmysql.Dialector)
+	return ok && (dialector.DriverName == "" || dialector.DriverName == gormmysql.DefaultDriverName)
+}
+
+// skipsDuplicates is true: LOAD DATA LOCAL skips duplicates as IGNORE does
+func (loadDataLoader) skipsDuplicates() bool {
+	return true
+}
+
+// load streams rows into table through a reader registered with the driver
+func (loadDataLoader) load(ctx context.Context, db *gorm.DB, table string, columns []string, rows [][]interface{}) (int64, error) {
+	reader, writer := io.Pipe()
+	name := "ormx-" + uuid.NewString()
+	mysql.RegisterReaderHandler(name, func() io.Reader { return reader })
+	defer mysql.DeregisterReaderHandler(name)
+
+	location := loadDataLocation(db)
+	go func() {
+		writer.CloseWithError(writeLoadData(writer, rows, location))
+	}()
+	// Unblocks the writer when the statement fails before reading it all
+	defer reader.Close()
+
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = "`" + strings.ReplaceAll(column, "`", "``") + "`"
+	}
+	query := fmt.Sprintf("LOAD DATA LOCAL INFILE 'Reader::%s' INTO TABLE `%s` CHARACTER SET utf8mb4 "+
+		"FIELDS TERMINATED BY '\\t' ESCAPED BY '\\\\' LINES TERMINATED BY '\\n' (%s)",
+		name, strings.ReplaceAll(table, "`", "``"), strings.Join(quoted, ", "))
+
+	result := db.Exec(query)
+	if result.Error != nil {
+		var mysqlErr *mysql.MySQLError
+		// ER_NOT_ALLOWED_COMMAND and ER_CLIENT_LOCAL_FILES_DISABLED: the
+		// server does not allow LOAD DATA LOCAL
+		if stderrors.As(result.Error, &mysqlErr) && (mysqlErr.Number == 1148 || mysqlErr.Number == 3948) {
+			return 0, fmt.Errorf("%w: %v", errBulkUnsupported, result.Error)
+		}
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+// loadDataLocation returns the time zone the driver of db writes times in,
+// UTC unless its DSN sets loc
+func loadDataLocation(db *gorm.DB) *time.Location {
+	dialector, ok := db.Dialector.(*gormmysql.Dialector)
+	if !ok {
+		return time.UTC
+	}
+	config := dialector.DSNConfig
+	if config == nil && dialector.DSN != "" {
+		config, _ = mysql.ParseDSN(dialector.DSN)
+	}
+	if config == nil || config.Loc == nil {
+		return time.UTC
+	}
+	return config.Loc
+}
+
+// loadDataEscaper escapes the values of LOAD DATA fields
+var loadDataEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`, "\x00", `\0`)
+
+// writeLoadData writes rows as LOAD DATA lines: tab separated, escaped
+// fields, \N standing for NULL, times in location
+func writeLoadData(w io.Writer, rows [][]interface{}, location *time.Location) error {
+	buffered := bufio.NewWriterSize(w, 64*1024)
+	for _, row := range rows {
+		for i, value := range row {
+			if i > 0 {
+				buffered.WriteByte('\t')
+			}
+			buffered.WriteString(loadDataField(value, location))
+		}
+		if err := buffered.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return buffered.Flush()
+}
+
+// loadDataField formats a driver value as a LOAD DATA field
+func loadDataField(value interface{}, location *time.Location) string {
+	switch v := value.(type) {
+	case nil:
+		return `\N`
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case []byte:
+		return loadDataEscaper.Replace(string(v))
+	case string:
+		return loadDataEscaper.Replace(v)
+	case time.Time:
+		return v.In(location).Format("2006-01-02 15:04:05.999999")
+	default:
+		return loadDataEscaper.Replace(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/models"
+	"gorm.io/gorm/clause"
+)
+
+// defaultMaxIDsPerQuery bounds the IDs of an IN clause when MaxIDsPerQuery
+// is not set, below the parameter limits of the supported drivers
+const defaultMaxIDsPerQuery = 1000
+
+// FindAllByIDs finds the entities with ids, keyed by ID. Large ID lists are
+// split into queries of at most MaxIDsPerQuery IDs; IDs without an entity
+// are missing from the result. IDs are converted to the key type of T as by
+// FindFirstByID, and the result is keyed by the converted IDs. Use InIDOrder
+// to list them in the order of ids:
+//
+//	found, err := repo.FindAllByIDs(ctx, ids)
+//	users := repository.InIDOrder(ids, found)
+func (r *BaseRepository[T]) FindAllByIDs(ctx context.Context, ids []models.EntityID, opts ...FindOption) (map[models.EntityID]*T, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "FindAllByIDs", time.Since(start))
+	}()
+
+	found := make(map[models.EntityID]*T, len(ids))
+	pending := make([]interface{}, 0, len(ids))
+	seen := make(map[models.EntityID]bool, len(ids))
+	keyType := keyTypeOf[T]()
+	for _, id := range ids {
+		id = normalizeID(id, keyType)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if len(opts) == 0 {
+			if cached, ok := r.cacheRead(ctx, id); ok {
+				found[id] = cached
+				continue
+			}
+		}
+		pending = append(pending, id)
+	}
+
+	chunk := r.config.MaxIDsPerQuery
+	if chunk <= 0 {
+		chunk = defaultMaxIDsPerQuery
+	}
+	for offset := 0; offset < len(pending); offset += chunk {
+		var entities []T
+		if err := r.retry(ctx, "FindAllByIDs", func() error {
+			entities = nil
+			return r.findDB(ctx, opts).
+				Where(clause.IN{Column: idColumn, Values: pending[offset:min(offset+chunk, len(pending))]}).
+				Find(&entities).Error
+		}); err != nil {
+			r.metrics.IncrementOperationsFor("FindAllByIDs", false)
+			return nil, r.wrapError(err, "FindAllByIDs", "failed to find entities by IDs")
+		}
+		for i := range entities {
+			entity := &entities[i]
+			found[r.getEntityID(entity)] = entity
+			if len(opts) == 0 {
+				r.cacheLoaded(ctx, entity)
+			}
+		}
+	}
+
+	r.metrics.IncrementOperationsFor("FindAllByIDs", true)
+	return found, nil
+}
+
+// InIDOrder returns the entities of found in the order of ids, skipping the
+// IDs without an entity, such as the result of FindAllByIDs. IDs are
+// converted to the key type of T as FindAllByIDs does.
+func InIDOrder[T any](ids []models.EntityID, found map[models.EntityID]*T) []*T {
+	ordered := make([]*T, 0, len(found))
+	keyType := keyTypeOf[T]()
+	for _, id := range ids {
+		if entity, ok := found[normalizeID(id, keyType)]; ok {
+			ordered = append(ordered, entity)
+		}
+	}
+	return ordered
+}
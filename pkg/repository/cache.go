@@ -0,0 +1,295 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/models"
+)
+
+// defaultCacheEntries bounds the entity cache when MaxEntries is not set
+const defaultCacheEntries = 10000
+
+// CacheConfig configures the by-ID entity cache of a repository. The cache
+// serves FindFirstByID, FindFirstBy on "id" and ExistsByID outside
+// transactions, follower reads and find options. Writes by ID refresh or
+// invalidate their entity and writes by conditions clear the cache; writes
+// inside a transaction take effect when it commits. Entities are cached by
+// value, slices and maps of cached entities are shared with the callers.
+type CacheConfig struct {
+	// TTL is how long entities stay cached, zero keeps them until evicted
+	TTL time.Duration `json:"ttl"`
+	// MaxEntries bounds the cached entities, the least recently used are
+	// evicted first (10000 when not set)
+	MaxEntries int `json:"max_entries"`
+	// PrimeOnWrite caches the entities written by Create, CreateInBatches,
+	// Update and UpdateByID, so reading them back right after the write hits
+	// the cache instead of a possibly lagging read replica. Otherwise written
+	// entities are only invalidated.
+	PrimeOnWrite bool `json:"prime_on_write"`
+}
+
+// CacheStats reports the activity of a repository's entity cache
+type CacheStats struct {
+	Entries   int   `json:"entries"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	// Primed counts the entities cached by writes
+	Primed int64 `json:"primed"`
+	// PrimedHits counts the hits on entities cached by writes
+	PrimedHits int64 `json:"primed_hits"`
+	// PrimedUsed counts the entities cached by writes read at least once
+	// before leaving the cache
+	PrimedUsed int64 `json:"primed_used"`
+}
+
+// HitRate returns the fraction of cache lookups that were hits
+func (s CacheStats) HitRate() float64 {
+	return successRate(s.Hits, s.Hits+s.Misses)
+}
+
+// PrimedHitRate returns the fraction of entities cached by writes that were
+// read from the cache, i.e. how often priming saved a read
+func (s CacheStats) PrimedHitRate() float64 {
+	return successRate(s.PrimedUsed, s.Primed)
+}
+
+// entityCache is a least recently used cache of entities by ID
+type entityCache[T any] struct {
+	config  CacheConfig
+	mu      sync.Mutex
+	entries map[models.EntityID]*list.Element
+	order   *list.List
+	stats   CacheStats
+}
+
+// cacheEntry is a cached entity
+type cacheEntry[T any] struct {
+	id      models.EntityID
+	value   T
+	expires time.Time
+	primed  bool
+	used    bool
+}
+
+// newEntityCache creates an entity cache, nil when config is nil
+func newEntityCache[T any](config *CacheConfig) *entityCache[T] {
+	if config == nil {
+		return nil
+	}
+	c := &entityCache[T]{
+		config:  *config,
+		entries: make(map[models.EntityID]*list.Element),
+		order:   list.New(),
+	}
+	if c.config.MaxEntries <= 0 {
+		c.config.MaxEntries = defaultCacheEntries
+	}
+	return c
+}
+
+// get returns a copy of the entity cached for id
+func (c *entityCache[T]) get(id models.EntityID) (*T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[id]
+	if ok {
+		entry := element.Value.(*cacheEntry[T])
+		if entry.expires.IsZero() || time.Now().Before(entry.expires) {
+			c.stats.Hits++
+			if entry.primed {
+				c.stats.PrimedHits++
+				if !entry.used {
+					c.stats.PrimedUsed++
+				}
+			}
+			entry.used = true
+			c.order.MoveToFront(element)
+			value := entry.value
+			return &value, true
+		}
+		c.removeElement(element)
+	}
+	c.stats.Misses++
+	return nil, false
+}
+
+// put caches a copy of entity under id, primed when it comes from a write
+func (c *entityCache[T]) put(id models.EntityID, entity *T, primed bool) {
+	if isZeroID(id) || entity == nil {
+		return
+	}
+
+	entry := &cacheEntry[T]{id: id, value: *entity, primed: primed}
+	if c.config.TTL > 0 {
+		entry.expires = time.Now().Add(c.config.TTL)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[id]; ok {
+		c.removeElement(element)
+	}
+	c.entries[id] = c.order.PushFront(entry)
+	if primed {
+		c.stats.Primed++
+	}
+	for c.order.Len() > c.config.MaxEntries {
+		c.removeElement(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// invalidate drops the entities cached for ids, all of them without ids
+func (c *entityCache[T]) invalidate(ids ...models.EntityID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(ids) == 0 {
+		c.entries = make(map[models.EntityID]*list.Element)
+		c.order.Init()
+		return
+	}
+	for _, id := range ids {
+		if element, ok := c.entries[id]; ok {
+			c.removeElement(element)
+		}
+	}
+}
+
+// removeElement drops a cached entity; callers hold the lock
+func (c *entityCache[T]) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry[T]).id)
+}
+
+// snapshot returns the cache statistics
+func (c *entityCache[T]) snapshot() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	return stats
+}
+
+// CacheStats returns the statistics of the entity cache, zero when the
+// repository has no cache configured
+func (r *BaseRepository[T]) CacheStats() CacheStats {
+	if r.cache == nil {
+		return CacheStats{}
+	}
+	return r.cache.snapshot()
+}
+
+// cacheable reports whether a by-ID read may be served by the entity cache
+func (r *BaseRepository[T]) cacheable(ctx context.Context) bool {
+	if r.cache == nil || isTransaction(r.db) {
+		return false
+	}
+	_, follower := followerReadFromContext(ctx)
+	return !follower
+}
+
+// cacheRead returns the entity cached for id
+func (r *BaseRepository[T]) cacheRead(ctx context.Context, id models.EntityID) (*T, bool) {
+	if !r.cacheable(ctx) {
+		return nil, false
+	}
+	return r.cache.get(id)
+}
+
+// cacheLoaded caches an entity read from the database. Entities read with
+// WithDeleted may be soft deleted and are not cached.
+func (r *BaseRepository[T]) cacheLoaded(ctx context.Context, entity *T) {
+	if !r.cacheable(ctx) || includeDeleted(ctx) {
+		return
+	}
+	r.cache.put(r.getEntityID(entity), entity, false)
+}
+
+// cacheWritten primes or invalidates the cache with written entities, once
+// the surrounding transaction commits, and invalidates the read models and
+// cached queries depending on the table
+func (r *BaseRepository[T]) cacheWritten(ctx context.Context, entities ...*T) {
+	if len(entities) == 0 {
+		return
+	}
+	r.invalidateDependents(ctx)
+	r.queryCacheInvalidate(ctx)
+	if r.cache == nil {
+		return
+	}
+
+	ids := make([]models.EntityID, 0, len(entities))
+	for _, entity := range entities {
+		ids = append(ids, r.getEntityID(entity))
+	}
+	if !r.cache.config.PrimeOnWrite {
+		r.cacheDrop(ctx, ids...)
+		return
+	}
+
+	// Entities may be modified by the caller once the write returns
+	values := make([]T, len(entities))
+	for i, entity := range entities {
+		values[i] = *entity
+	}
+	prime := func(context.Context) {
+		for i := range values {
+			r.cache.put(ids[i], &values[i], true)
+		}
+	}
+
+	if r.hooks == nil {
+		// Repositories bound to a transaction by hand cannot tell when it commits
+		if isTransaction(r.db) {
+			r.cache.invalidate(ids...)
+			return
+		}
+		prime(ctx)
+		return
+	}
+	// Readers must not see uncommitted entities, nor keep stale ones meanwhile
+	r.cache.invalidate(ids...)
+	r.AfterCommit(ctx, prime)
+}
+
+// cacheInvalidate drops the cached entities with ids, all of them without
+// ids, and again when the surrounding transaction commits. The read models
+// and cached queries depending on the table are invalidated once it commits.
+func (r *BaseRepository[T]) cacheInvalidate(ctx context.Context, ids ...models.EntityID) {
+	r.invalidateDependents(ctx)
+	r.queryCacheInvalidate(ctx)
+	r.cacheDrop(ctx, ids...)
+}
+
+// cacheDrop drops the cached entities with ids, all of them without ids, and
+// again when the surrounding transaction commits
+func (r *BaseRepository[T]) cacheDrop(ctx context.Context, ids ...models.EntityID) {
+	if r.cache == nil {
+		return
+	}
+	r.cache.invalidate(ids...)
+	if r.hooks != nil {
+		r.AfterCommit(ctx, func(context.Context) {
+			r.cache.invalidate(ids...)
+		})
+	}
+}
+
+// cachedLookupID returns the ID looked up by FindFirstBy when it is a
+// by-ID lookup of a value of, or convertible to, keyType
+func cachedLookupID(column string, value interface{}, keyType reflect.Type) (models.EntityID, bool) {
+	if column != "id" || keyType == nil || value == nil {
+		return nil, false
+	}
+	id := normalizeID(value, keyType)
+	return id, reflect.TypeOf(id) == keyType
+}
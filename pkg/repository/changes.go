@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Change operations reported to change hooks
+const (
+	ChangeCreate  = "create"
+	ChangeUpdate  = "update"
+	ChangeDelete  = "delete"
+	ChangeRestore = "restore"
+)
+
+// EntityChange describes an entity written through a repository. Before is
+// nil for creates and After is nil for deletes. Key is the key of the
+// entity and ID the same key for entities keyed by a UUID, uuid.Nil for
+// other keys.
+type EntityChange[T any] struct {
+	Operation string
+	Table     string
+	ID        uuid.UUID
+	Key       models.EntityID
+	Before    *T
+	After     *T
+	// DB is the connection the write ran on: the transaction when the write
+	// is part of one, so records persisted by hooks commit or roll back with it
+	DB *gorm.DB
+}
+
+// ChangeHook is called after an entity is written. An error fails the write
+// operation, rolling back the surrounding transaction.
+type ChangeHook[T any] func(ctx context.Context, change *EntityChange[T]) error
+
+// OnChange registers hook to be called after every entity written by Create,
+// CreateInBatches, CreateInBatchesIgnoreConflicts, Update, UpdateByID,
+// Upsert, UpsertByID, UpsertInBatches, UpsertInBatchesByConditions, Delete,
+// DeleteByID, SoftDeleteByID and RestoreByID, with the entity before and
+// after the write. The entity before an update or delete is read from the
+// primary (or the transaction) before writing, so registering a hook costs
+// one extra read per write. Rows skipped by CreateInBatchesIgnoreConflicts
+// are not reported, nor are bulk writes by conditions.
+func (r *BaseRepository[T]) OnChange(hook ChangeHook[T]) *BaseRepository[T] {
+	if hook != nil {
+		r.changeHooks = append(r.changeHooks, hook)
+	}
+	return r
+}
+
+// snapshot returns the stored entity with id when change hooks are
+// registered, nil otherwise or when it does not exist
+func (r *BaseRepository[T]) snapshot(ctx context.Context, id models.EntityID) *T {
+	if len(r.changeHooks) == 0 || isZeroID(id) {
+		return nil
+	}
+
+	var entity T
+	if err := r.db.WithContext(ctx).Unscoped().Where(clause.Eq{Column: idColumn, Value: id}).Take(&entity).Error; err != nil {
+		return nil
+	}
+	return &entity
+}
+
+// notifyChange calls the change hooks with a copy of the written entity
+func (r *BaseRepository[T]) notifyChange(ctx context.Context, operation string, id models.EntityID, before, after *T) error {
+	if len(r.changeHooks) == 0 {
+		return nil
+	}
+
+	change := &EntityChange[T]{
+		Operation: operation,
+		Table:     r.tableName,
+		ID:        uuidOfID(id),
+		Key:       id,
+		Before:    before,
+		DB:        r.db.WithContext(ctx),
+	}
+	if after != nil {
+		written := *after
+		change.After = &written
+	}
+
+	for _, hook := range r.changeHooks {
+		if err := hook(ctx, change); err != nil {
+			return r.wrapError(err, "OnChange", fmt.Sprintf("change hook failed for %s %s", r.tableName, id))
+		}
+	}
+	return nil
+}
+
+// saveOperation returns the change operation of a save, which inserts the
+// entity when it did not exist before
+func saveOperation[T any](before *T) string {
+	if before == nil {
+		return ChangeCreate
+	}
+	return ChangeUpdate
+}
+
+// storedIDs returns the text of the IDs of rows that are stored, queried
+// at most MaxIDsPerQuery IDs at a time. Rows without an ID yet are not
+// looked up.
+func (r *BaseRepository[T]) storedIDs(ctx context.Context, rows []T) (map[string]bool, error) {
+	ids := make([]interface{}, 0, len(rows))
+	for i := range rows {
+		if id := r.getEntityID(&rows[i]); !isZeroID(id) {
+			ids = append(ids, id)
+		}
+	}
+
+	chunk := r.config.MaxIDsPerQuery
+	if chunk <= 0 {
+		chunk = defaultMaxIDsPerQuery
+	}
+	stored := make(map[string]bool, len(ids))
+	for offset := 0; offset < len(ids); offset += chunk {
+		var found []T
+		if err := r.db.WithContext(ctx).Unscoped().Select(r.primaryKeyColumn()).
+			Where(clause.IN{Column: idColumn, Values: ids[offset:min(offset+chunk, len(ids))]}).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		for i := range found {
+			stored[idString(r.getEntityID(&found[i]))] = true
+		}
+	}
+	return stored, nil
+}
+
+// notifyInserted reports the rows of a batch created while ignoring
+// conflicts. When some were skipped, the rows reported are those stored
+// after the batch that were not stored before it, as listed by existing.
+func (r *BaseRepository[T]) notifyInserted(ctx context.Context, rows []T, inserted int64, existing map[string]bool) error {
+	if len(r.changeHooks) == 0 || inserted == 0 {
+		return nil
+	}
+
+	var stored map[string]bool
+	if inserted < int64(len(rows)) {
+		var err error
+		if stored, err = r.storedIDs(ctx, rows); err != nil {
+			return r.wrapError(err, "CreateInBatchesIgnoreConflicts", "failed to read inserted entities")
+		}
+	}
+	for i := range rows {
+		id := r.getEntityID(&rows[i])
+		if stored != nil && (!stored[idString(id)] || existing[idString(id)]) {
+			continue
+		}
+		if err := r.notifyChange(ctx, ChangeCreate, id, nil, &rows[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
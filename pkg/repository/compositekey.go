@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"gorm.io/gorm/schema"
+)
+
+// keySchemas caches the schemas parsed by KeyOf
+var keySchemas sync.Map
+
+// EntityKey is the primary key of the entity type T, made of one or more
+// columns tagged primaryKey. Rows of entities keyed by several columns are
+// addressed with FindFirstByKey, ExistsByKey and DeleteByKey, which take the
+// key as a map of its columns:
+//
+//	type Membership struct {
+//		TeamID uuid.UUID `gorm:"primaryKey"`
+//		UserID uuid.UUID `gorm:"primaryKey"`
+//		Role   string
+//	}
+//
+//	key, err := repository.KeyOf[Membership]()
+//	err = repo.DeleteByKey(ctx, key.Of(&membership))
+type EntityKey[T any] struct {
+	fields []*schema.Field
+}
+
+// KeyOf returns the primary key of T, failing when T is not a model or has
+// no primary key
+func KeyOf[T any]() (*EntityKey[T], error) {
+	s, err := schema.Parse(new(T), &keySchemas, schema.NamingStrategy{})
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeConfig, fmt.Sprintf("cannot parse entity %T", *new(T)))
+	}
+	if len(s.PrimaryFields) == 0 {
+		return nil, errors.New(errors.ErrorTypeConfig, fmt.Sprintf("entity %s has no primary key", s.Name)).WithTable(s.Table)
+	}
+	return &EntityKey[T]{fields: s.PrimaryFields}, nil
+}
+
+// Columns returns the columns of the key in declaration order
+func (k *EntityKey[T]) Columns() []string {
+	columns := make([]string, len(k.fields))
+	for i, field := range k.fields {
+		columns[i] = field.DBName
+	}
+	return columns
+}
+
+// Of returns the key of entity, its key column values by column name
+func (k *EntityKey[T]) Of(entity *T) map[string]interface{} {
+	key := make(map[string]interface{}, len(k.fields))
+	if entity == nil {
+		return key
+	}
+	v := reflect.ValueOf(entity).Elem()
+	for _, field := range k.fields {
+		key[field.DBName], _ = field.ValueOf(context.Background(), v)
+	}
+	return key
+}
+
+// keyCond returns the condition matching the row of s with key, which names
+// every primary key column, by column or field name, and nothing else
+func keyCond(s *schema.Schema, key map[string]interface{}) (Cond, error) {
+	if len(s.PrimaryFields) == 0 {
+		return Cond{}, fmt.Errorf("entity %s has no primary key", s.Name)
+	}
+
+	values := make(map[string]interface{}, len(key))
+	for name, value := range key {
+		field := s.LookUpField(name)
+		if field == nil || !field.PrimaryKey {
+			return Cond{}, fmt.Errorf("%q is not a primary key column of %s", name, s.Name)
+		}
+		if value == nil {
+			return Cond{}, fmt.Errorf("key column %q cannot be nil", field.DBName)
+		}
+		values[field.DBName] = value
+	}
+
+	conds := make([]Cond, 0, len(s.PrimaryFields))
+	var missing []string
+	for _, field := range s.PrimaryFields {
+		value, ok := values[field.DBName]
+		if !ok {
+			missing = append(missing, field.DBName)
+			continue
+		}
+		conds = append(conds, Eq(field.DBName, value))
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return Cond{}, fmt.Errorf("key of %s is missing %s", s.Name, strings.Join(missing, ", "))
+	}
+	return And(conds...), nil
+}
+
+// keyCond returns the condition matching the entity with key, or the
+// argument error of operation
+func (r *BaseRepository[T]) keyCond(operation string, key map[string]interface{}) (Cond, error) {
+	s, err := r.schema()
+	if err != nil {
+		return Cond{}, err
+	}
+	cond, err := keyCond(s, key)
+	if err != nil {
+		return Cond{}, r.argumentError(operation, err.Error())
+	}
+	return cond, nil
+}
+
+// FindFirstByKey finds the entity with key, the values of its primary key
+// columns (see EntityKey), loading it as configured by opts
+func (r *BaseRepository[T]) FindFirstByKey(ctx context.Context, key map[string]interface{}, opts ...FindOption) (*T, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "FindFirstByKey", time.Since(start))
+	}()
+
+	cond, err := r.keyCond("FindFirstByKey", key)
+	if err != nil {
+		r.metrics.IncrementOperationsFor("FindFirstByKey", false)
+		return nil, err
+	}
+
+	var entity T
+	if err := r.retry(ctx, "FindFirstByKey", func() error {
+		return r.findDB(ctx, opts).Where(cond).Take(&entity).Error
+	}); err != nil {
+		r.metrics.IncrementOperationsFor("FindFirstByKey", false)
+		return nil, r.wrapError(err, "FindFirstByKey", "failed to find entity by key")
+	}
+
+	r.metrics.IncrementOperationsFor("FindFirstByKey", true)
+	return &entity, nil
+}
+
+// ExistsByKey reports whether an entity has key
+func (r *BaseRepository[T]) ExistsByKey(ctx context.Context, key map[string]interface{}) (bool, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "ExistsByKey", time.Since(start))
+	}()
+
+	cond, err := r.keyCond("ExistsByKey", key)
+	if err != nil {
+		r.metrics.IncrementOperationsFor("ExistsByKey", false)
+		return false, err
+	}
+
+	var count int64
+	if err := r.retry(ctx, "ExistsByKey", func() error {
+		return r.readDB(ctx).Model(new(T)).Where(cond).Count(&count).Error
+	}); err != nil {
+		r.metrics.IncrementOperationsFor("ExistsByKey", false)
+		return false, r.wrapError(err, "ExistsByKey", "failed to check entity existence")
+	}
+
+	r.metrics.IncrementOperationsFor("ExistsByKey", true)
+	return count > 0, nil
+}
+
+// DeleteByKey deletes the entity with key according to the delete mode
+func (r *BaseRepository[T]) DeleteByKey(ctx context.Context, key map[string]interface{}) error {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "DeleteByKey", time.Since(start))
+	}()
+
+	cond, err := r.keyCond("DeleteByKey", key)
+	if err != nil {
+		r.metrics.IncrementOperationsFor("DeleteByKey", false)
+		return err
+	}
+
+	var before *T
+	if len(r.changeHooks) > 0 {
+		var entity T
+		if err := r.db.WithContext(ctx).Unscoped().Where(cond).Take(&entity).Error; err == nil {
+			before = &entity
+		}
+	}
+	if err := r.retry(ctx, "DeleteByKey", func() error {
+		return r.remove(r.db.WithContext(ctx), new(T), cond)
+	}); err != nil {
+		r.metrics.IncrementOperationsFor("DeleteByKey", false)
+		return r.wrapError(err, "DeleteByKey", "failed to delete entity")
+	}
+
+	r.cacheInvalidate(ctx)
+	if before != nil {
+		if err := r.notifyChange(ctx, ChangeDelete, r.getEntityID(before), before, nil); err != nil {
+			r.metrics.IncrementOperationsFor("DeleteByKey", false)
+			return err
+		}
+	}
+	r.metrics.IncrementOperationsFor("DeleteByKey", true)
+	r.logger.Info(ctx, "Entity deleted successfully",
+		logging.String("table", r.tableName),
+		logging.String("key", fmt.Sprint(key)))
+
+	return nil
+}
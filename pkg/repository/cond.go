@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"sort"
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+// Cond is a composable condition accepted by every method taking conditions
+// (FindAllByConditions*, CountByConditions, UpdateAllByConditions, Query.Where,
+// ...) in place of a hand-concatenated SQL string:
+//
+//	cond := repository.Expr("status = ?", "active").And(
+//		repository.Or(
+//			repository.Expr("age >= @min", map[string]interface{}{"min": 18}),
+//			repository.Match(map[string]interface{}{"guardian_approved": true}),
+//		),
+//	)
+//	count, err := repo.CountByConditions(ctx, cond)
+//	// WHERE (status = 'active' AND (age >= 18 OR `guardian_approved` = true))
+//
+// Combined conditions are always parenthesized, so they keep their meaning
+// next to the conditions added by the repository, such as the soft delete
+// filter. A zero Cond matches every row.
+type Cond struct {
+	op    string
+	expr  clause.Expression
+	conds []Cond
+	// column is the column of the model compared by typed conditions
+	column string
+}
+
+// Expr returns a condition from SQL with positional (?) parameters, or named
+// (@name) parameters taken from a single map or struct argument
+func Expr(sql string, args ...interface{}) Cond {
+	if len(args) == 1 && strings.Contains(sql, "@") && !strings.Contains(sql, "?") {
+		return Cond{expr: clause.NamedExpr{SQL: sql, Vars: args}}
+	}
+	return Cond{expr: clause.Expr{SQL: sql, Vars: args}}
+}
+
+// Match returns a condition matching the columns of values with their
+// values: equality for single values, IN for slices
+func Match(values map[string]interface{}) Cond {
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	conds := make([]Cond, 0, len(columns))
+	for _, column := range columns {
+		conds = append(conds, Cond{expr: clause.Eq{Column: condColumn(column), Value: values[column]}})
+	}
+	return And(conds...)
+}
+
+// condColumn returns the column of a condition: a column of the queried
+// table, unless qualified by another
+func condColumn(column string) clause.Column {
+	if strings.Contains(column, ".") {
+		return clause.Column{Name: column}
+	}
+	return clause.Column{Table: clause.CurrentTable, Name: column}
+}
+
+// And returns a condition matched when all conds are; zero conditions are skipped
+func And(conds ...Cond) Cond {
+	return combine("AND", conds)
+}
+
+// Or returns a condition matched when any of conds is; zero conditions are skipped
+func Or(conds ...Cond) Cond {
+	return combine("OR", conds)
+}
+
+// Not returns a condition matched when cond is not
+func Not(cond Cond) Cond {
+	if cond.IsZero() {
+		return cond
+	}
+	return Cond{op: "NOT", conds: []Cond{cond}}
+}
+
+// And returns a condition matched when c and all conds are
+func (c Cond) And(conds ...Cond) Cond {
+	return And(append([]Cond{c}, conds...)...)
+}
+
+// Or returns a condition matched when c or any of conds is
+func (c Cond) Or(conds ...Cond) Cond {
+	return Or(append([]Cond{c}, conds...)...)
+}
+
+// IsZero reports whether c is the zero condition, matching every row
+func (c Cond) IsZero() bool {
+	return c.op == "" && c.expr == nil
+}
+
+// combine joins the non-zero conds with op, flattening a single one
+func combine(op string, conds []Cond) Cond {
+	kept := make([]Cond, 0, len(conds))
+	for _, cond := range conds {
+		if cond.IsZero() {
+			continue
+		}
+		// Nested groups of the same operator are merged: (a AND (b AND c)) is (a AND b AND c)
+		if cond.op == op {
+			kept = append(kept, cond.conds...)
+			continue
+		}
+		kept = append(kept, cond)
+	}
+
+	switch len(kept) {
+	case 0:
+		return Cond{}
+	case 1:
+		return kept[0]
+	}
+	return Cond{op: op, conds: kept}
+}
+
+// Build writes the condition to a statement
+func (c Cond) Build(builder clause.Builder) {
+	switch c.op {
+	case "":
+		if c.expr == nil {
+			// The zero condition matches every row
+			_, _ = builder.WriteString("1 = 1")
+			return
+		}
+		c.buildGrouped(builder)
+	case "NOT":
+		_, _ = builder.WriteString("NOT ")
+		c.conds[0].buildGrouped(builder)
+	default:
+		_ = builder.WriteByte('(')
+		for i, cond := range c.conds {
+			if i > 0 {
+				_, _ = builder.WriteString(" " + c.op + " ")
+			}
+			cond.buildGrouped(builder)
+		}
+		_ = builder.WriteByte(')')
+	}
+}
+
+// buildGrouped writes the condition, parenthesized when its operators could
+// bind to the surrounding ones
+func (c Cond) buildGrouped(builder clause.Builder) {
+	if c.op != "" {
+		if c.op == "NOT" {
+			_ = builder.WriteByte('(')
+			c.Build(builder)
+			_ = builder.WriteByte(')')
+			return
+		}
+		c.Build(builder)
+		return
+	}
+
+	var sql string
+	switch e := c.expr.(type) {
+	case clause.Expr:
+		sql = e.SQL
+	case clause.NamedExpr:
+		sql = e.SQL
+	}
+	if sql == "" {
+		c.expr.Build(builder)
+		return
+	}
+	_ = builder.WriteByte('(')
+	c.expr.Build(builder)
+	_ = builder.WriteByte(')')
+}
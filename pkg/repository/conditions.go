@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Typed conditions compare a column with parameters without writing SQL:
+//
+//	cond := repository.And(
+//		repository.Eq("status", "active"),
+//		repository.Or(repository.Gte("age", 18), repository.IsNull("birth_date")),
+//		repository.In("country", "FR", "DE"),
+//	)
+//	err := repo.FindAllByConditionsWithOffset(ctx, 50, 0, &users, cond)
+//
+// The column is quoted and the values are bound as parameters. Unqualified
+// columns belong to the queried model: the condition binder rejects those
+// the model does not have, and CheckConditions reports them up front.
+
+// Eq returns a condition matching rows whose column equals value, or is NULL
+// when value is nil
+func Eq(column string, value interface{}) Cond {
+	return typedCond(column, clause.Eq{Column: condColumn(column), Value: value})
+}
+
+// Neq returns a condition matching rows whose column differs from value, or
+// is not NULL when value is nil
+func Neq(column string, value interface{}) Cond {
+	return typedCond(column, clause.Neq{Column: condColumn(column), Value: value})
+}
+
+// Gt returns a condition matching rows whose column is greater than value
+func Gt(column string, value interface{}) Cond {
+	return typedCond(column, clause.Gt{Column: condColumn(column), Value: value})
+}
+
+// Gte returns a condition matching rows whose column is greater than or equal to value
+func Gte(column string, value interface{}) Cond {
+	return typedCond(column, clause.Gte{Column: condColumn(column), Value: value})
+}
+
+// Lt returns a condition matching rows whose column is less than value
+func Lt(column string, value interface{}) Cond {
+	return typedCond(column, clause.Lt{Column: condColumn(column), Value: value})
+}
+
+// Lte returns a condition matching rows whose column is less than or equal to value
+func Lte(column string, value interface{}) Cond {
+	return typedCond(column, clause.Lte{Column: condColumn(column), Value: value})
+}
+
+// In returns a condition matching rows whose column is one of values, given
+// as arguments or as a single slice. No values match no rows.
+func In(column string, values ...interface{}) Cond {
+	return typedCond(column, clause.IN{Column: condColumn(column), Values: flattenValues(values)})
+}
+
+// NotIn returns a condition matching rows whose column is none of values
+func NotIn(column string, values ...interface{}) Cond {
+	return Not(In(column, values...))
+}
+
+// Like returns a condition matching rows whose column matches pattern, in
+// which % and _ are wildcards
+func Like(column string, pattern string) Cond {
+	return typedCond(column, clause.Like{Column: condColumn(column), Value: pattern})
+}
+
+// Between returns a condition matching rows whose column is between low and
+// high inclusive
+func Between(column string, low, high interface{}) Cond {
+	return And(Gte(column, low), Lte(column, high))
+}
+
+// IsNull returns a condition matching rows whose column is NULL
+func IsNull(column string) Cond {
+	return Eq(column, nil)
+}
+
+// IsNotNull returns a condition matching rows whose column is not NULL
+func IsNotNull(column string) Cond {
+	return Neq(column, nil)
+}
+
+// Columns returns the unqualified columns compared by the typed conditions of c
+func (c Cond) Columns() []string {
+	var columns []string
+	c.walk(func(leaf Cond) {
+		if leaf.column != "" && !strings.Contains(leaf.column, ".") && !contains(columns, leaf.column) {
+			columns = append(columns, leaf.column)
+		}
+	})
+	return columns
+}
+
+// CheckConditions reports the typed conditions of conds comparing columns
+// the entity does not have, as a validation error naming them, so
+// conditions built once can be checked at startup or in tests
+func (r *BaseRepository[T]) CheckConditions(conds ...interface{}) error {
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+	for _, cond := range conds {
+		c, ok := cond.(Cond)
+		if !ok {
+			continue
+		}
+		if err := checkColumns(s, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// typedCond returns a condition comparing column
+func typedCond(column string, expr clause.Expression) Cond {
+	return Cond{expr: expr, column: column}
+}
+
+// walk calls fn on the leaves of c
+func (c Cond) walk(fn func(leaf Cond)) {
+	if c.expr != nil {
+		fn(c)
+	}
+	for _, cond := range c.conds {
+		cond.walk(fn)
+	}
+}
+
+// checkColumns returns a validation error naming the first column compared
+// by c that s does not have
+func checkColumns(s *schema.Schema, c Cond) error {
+	for _, column := range c.Columns() {
+		if s.LookUpField(column) == nil {
+			return errors.NewFieldValidationError(s.Table, []errors.FieldError{{
+				Field:   column,
+				Rule:    "column",
+				Message: "unknown column of " + s.Table,
+			}})
+		}
+	}
+	return nil
+}
+
+// flattenValues expands a single slice argument into its elements
+func flattenValues(values []interface{}) []interface{} {
+	if len(values) != 1 {
+		return values
+	}
+	v := reflect.ValueOf(values[0])
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return values
+	}
+	if v.Type().Elem().Kind() == reflect.Uint8 {
+		// Byte slices are single values
+		return values
+	}
+	flattened := make([]interface{}, v.Len())
+	for i := range flattened {
+		flattened[i] = v.Index(i).Interface()
+	}
+	return flattened
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// DefaultFunc computes the default value of a column for a row being created
+type DefaultFunc func(ctx context.Context) (interface{}, error)
+
+// maxDefaultExpressions bounds the expressions evaluated by one statement
+const maxDefaultExpressions = 500
+
+// DefaultValues is a gorm plugin giving the empty fields of created rows
+// their declared default value before the INSERT is built, so the entities
+// hold the values the database stores and every row of a batch or an upsert
+// is defaulted the same way. Defaults are declared in gorm tags:
+//
+//	Status    string    `gorm:"default:pending"`           // literal
+//	ExpiresAt time.Time `gorm:"default:CURRENT_TIMESTAMP"` // SQL expression
+//	Number    string    `gorm:"defaultFunc:order_number"`  // Go callback
+//
+// Literals are applied by gorm itself. SQL expressions, which gorm leaves
+// to the database without reading them back, are evaluated with a SELECT
+// in the statement's connection or transaction, once per row, so volatile
+// functions such as gen_random_uuid() give each row its own value. Go
+// callbacks are registered with WithFunc under the name of their tag.
+//
+// Literals and SQL expressions are also the column defaults AutoMigrate
+// creates, so application and schema defaults come from one declaration;
+// migrations.CheckDefaults reports columns whose database default drifted.
+// Go callbacks have no schema counterpart. Creates from maps are left alone.
+type DefaultValues struct {
+	funcs map[string]DefaultFunc
+}
+
+// NewDefaultValues creates a default values plugin
+func NewDefaultValues() *DefaultValues {
+	return &DefaultValues{funcs: make(map[string]DefaultFunc)}
+}
+
+// WithFunc registers fn as the default callback named name
+func (d *DefaultValues) WithFunc(name string, fn DefaultFunc) *DefaultValues {
+	d.funcs[name] = fn
+	return d
+}
+
+// Name returns the plugin name
+func (d *DefaultValues) Name() string {
+	return "ormx:default_values"
+}
+
+// Initialize registers the defaults callback after the before hooks of the
+// creates of db, so hooks can still set the fields themselves
+func (d *DefaultValues) Initialize(db *gorm.DB) error {
+	err := db.Callback().Create().After("gorm:before_create").Before("gorm:create").Register("ormx:defaults_create", d.apply)
+	if err != nil {
+		return fmt.Errorf("failed to register default values callback: %w", err)
+	}
+	return nil
+}
+
+// pendingDefault is an empty field of a created row defaulted by a SQL expression
+type pendingDefault struct {
+	field *schema.Field
+	row   reflect.Value
+}
+
+// apply fills the empty defaulted fields of the created rows
+func (d *DefaultValues) apply(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil {
+		return
+	}
+	rows := createdRows(stmt.ReflectValue)
+	if len(rows) == 0 {
+		return
+	}
+
+	ctx := stmt.Context
+	selectColumns, restricted := stmt.SelectAndOmitColumns(true, false)
+	var pending []pendingDefault
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" || !field.Creatable || field.AutoIncrement {
+			continue
+		}
+		if v, ok := selectColumns[field.DBName]; (ok && !v) || (!ok && restricted) {
+			continue
+		}
+
+		if name, ok := field.TagSettings["DEFAULTFUNC"]; ok {
+			fn := d.funcs[name]
+			if fn == nil {
+				_ = db.AddError(fmt.Errorf("unknown default function %q of %s.%s", name, stmt.Schema.Name, field.Name))
+				return
+			}
+			for _, row := range rows {
+				if _, zero := field.ValueOf(ctx, row); !zero {
+					continue
+				}
+				value, err := fn(ctx)
+				if err != nil {
+					_ = db.AddError(fmt.Errorf("failed to compute default of %s.%s: %w", stmt.Schema.Name, field.Name, err))
+					return
+				}
+				if err := field.Set(ctx, row, value); err != nil {
+					_ = db.AddError(err)
+					return
+				}
+			}
+			continue
+		}
+
+		if defaultExpression(field) == "" {
+			continue
+		}
+		for _, row := range rows {
+			if _, zero := field.ValueOf(ctx, row); zero {
+				pending = append(pending, pendingDefault{field: field, row: row})
+			}
+		}
+	}
+
+	if len(pending) > 0 {
+		_ = db.AddError(evaluateDefaults(db, pending))
+	}
+}
+
+// createdRows returns the addressable struct rows of a create
+func createdRows(value reflect.Value) []reflect.Value {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		rows := make([]reflect.Value, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			if row := reflect.Indirect(value.Index(i)); row.Kind() == reflect.Struct && row.CanAddr() {
+				rows = append(rows, row)
+			}
+		}
+		return rows
+	case reflect.Struct:
+		if value.CanAddr() {
+			return []reflect.Value{value}
+		}
+	}
+	return nil
+}
+
+// defaultExpression returns the SQL expression field defaults to, empty for
+// literals, which gorm parses, and for NULL
+func defaultExpression(field *schema.Field) string {
+	if !field.HasDefaultValue || field.DefaultValueInterface != nil {
+		return ""
+	}
+	expression := strings.TrimSpace(field.DefaultValue)
+	if expression == "(-)" || strings.EqualFold(expression, "null") {
+		return ""
+	}
+	return expression
+}
+
+// evaluateDefaults evaluates the SQL expressions of pending in the connection
+// of the statement and sets the fields to their values
+func evaluateDefaults(db *gorm.DB, pending []pendingDefault) error {
+	stmt := db.Statement
+	session := db.Session(&gorm.Session{NewDB: true})
+	from := ""
+	if dialect.For(db).Name() == "oracle" {
+		from = " FROM dual"
+	}
+
+	for start := 0; start < len(pending); start += maxDefaultExpressions {
+		chunk := pending[start:min(start+maxDefaultExpressions, len(pending))]
+		expressions := make([]string, len(chunk))
+		values := make([]interface{}, len(chunk))
+		dest := make([]interface{}, len(chunk))
+		for i, p := range chunk {
+			expressions[i] = p.field.DefaultValue
+			dest[i] = &values[i]
+		}
+
+		row := session.Raw("SELECT " + strings.Join(expressions, ", ") + from).Row()
+		if err := row.Scan(dest...); err != nil {
+			return fmt.Errorf("failed to evaluate defaults of %s: %w", stmt.Schema.Table, err)
+		}
+		for i, p := range chunk {
+			if err := p.field.Set(stmt.Context, p.row, values[i]); err != nil {
+				return fmt.Errorf("failed to set default of %s.%s: %w", stmt.Schema.Name, p.field.Name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrMissingReference is wrapped by the errors reporting references that do
+// not exist when a transaction in deferred validation mode commits
+var ErrMissingReference = stderrors.New("referenced row does not exist")
+
+// referenceCheckBatchSize bounds the number of values checked per query
+const referenceCheckBatchSize = 500
+
+// MissingReferenceError reports the values of a foreign key column that do
+// not match any row of the referenced table
+type MissingReferenceError struct {
+	Table  string        `json:"table"`
+	Column string        `json:"column"`
+	Values []interface{} `json:"values"`
+}
+
+// Error returns the error message
+func (e *MissingReferenceError) Error() string {
+	return fmt.Sprintf("%v: %s.%s %v", ErrMissingReference, e.Table, e.Column, e.Values)
+}
+
+// Unwrap returns ErrMissingReference
+func (e *MissingReferenceError) Unwrap() error {
+	return ErrMissingReference
+}
+
+// deferredConstraintsKey is the context key for deferred constraint checking
+type deferredConstraintsKey struct{}
+
+// deferredValidationKey is the context key for deferred reference validation
+type deferredValidationKey struct{}
+
+// WithDeferredConstraints makes transactions started by WithTransaction with
+// the returned context defer their DEFERRABLE constraints (SET CONSTRAINTS ALL
+// DEFERRED) to commit, on dialects supporting it (Postgres, Oracle). Other
+// dialects check constraints as usual.
+func WithDeferredConstraints(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deferredConstraintsKey{}, true)
+}
+
+// WithDeferredValidation makes transactions started by WithTransaction with
+// the returned context validate belongs-to references of the entities written
+// through the transaction repository in bulk just before commit, instead of
+// relying on the database. Interdependent rows can then be inserted in any
+// order within the transaction on any dialect, provided the database does not
+// enforce the foreign keys itself (or defers them, see WithDeferredConstraints).
+// A missing reference rolls the transaction back with a MissingReferenceError.
+func WithDeferredValidation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deferredValidationKey{}, true)
+}
+
+// contextFlag reports whether ctx carries the boolean flag key
+func contextFlag(ctx context.Context, key interface{}) bool {
+	if ctx == nil {
+		return false
+	}
+	flag, _ := ctx.Value(key).(bool)
+	return flag
+}
+
+// referenceTarget identifies a referenced table column
+type referenceTarget struct {
+	table  string
+	column string
+}
+
+// deferredReferences collects the references to validate at commit
+type deferredReferences struct {
+	mu     sync.Mutex
+	values map[referenceTarget]map[string]interface{}
+}
+
+// newDeferredReferences creates an empty reference collector
+func newDeferredReferences() *deferredReferences {
+	return &deferredReferences{values: make(map[referenceTarget]map[string]interface{})}
+}
+
+// add records that value must exist in table.column
+func (d *deferredReferences) add(target referenceTarget, value interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.values[target] == nil {
+		d.values[target] = make(map[string]interface{})
+	}
+	d.values[target][referenceKey(value)] = value
+}
+
+// mergeInto hands the references of a nested transaction over to its parent
+func (d *deferredReferences) mergeInto(parent *deferredReferences) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for target, values := range d.values {
+		for _, value := range values {
+			parent.add(target, value)
+		}
+	}
+}
+
+// snapshot returns a copy of the collected references, for reset
+func (d *deferredReferences) snapshot() map[referenceTarget]map[string]interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	copied := make(map[referenceTarget]map[string]interface{}, len(d.values))
+	for target, values := range d.values {
+		copied[target] = make(map[string]interface{}, len(values))
+		for key, value := range values {
+			copied[target][key] = value
+		}
+	}
+	return copied
+}
+
+// reset replaces the collected references by a snapshot, dropping those
+// collected since it was taken
+func (d *deferredReferences) reset(snapshot map[referenceTarget]map[string]interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.values = snapshot
+}
+
+// validate checks every collected reference with one query per target and
+// batch of values, returning the missing ones
+func (d *deferredReferences) validate(tx *gorm.DB) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	targets := make([]referenceTarget, 0, len(d.values))
+	for target := range d.values {
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].table != targets[j].table {
+			return targets[i].table < targets[j].table
+		}
+		return targets[i].column < targets[j].column
+	})
+
+	var errs []error
+	for _, target := range targets {
+		keys := make([]string, 0, len(d.values[target]))
+		for key := range d.values[target] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		found := make(map[string]bool, len(keys))
+		for start := 0; start < len(keys); start += referenceCheckBatchSize {
+			end := start + referenceCheckBatchSize
+			if end > len(keys) {
+				end = len(keys)
+			}
+			values := make([]interface{}, 0, end-start)
+			for _, key := range keys[start:end] {
+				values = append(values, d.values[target][key])
+			}
+
+			var existing []interface{}
+			err := tx.Table(target.table).
+				Where(clause.IN{Column: clause.Column{Name: target.column}, Values: values}).
+				Pluck(target.column, &existing).Error
+			if err != nil {
+				return fmt.Errorf("failed to validate references to %s.%s: %w", target.table, target.column, err)
+			}
+			for _, value := range existing {
+				found[referenceKey(value)] = true
+			}
+		}
+
+		missing := &MissingReferenceError{Table: target.table, Column: target.column}
+		for _, key := range keys {
+			if !found[key] {
+				missing.Values = append(missing.Values, d.values[target][key])
+			}
+		}
+		if len(missing.Values) > 0 {
+			errs = append(errs, missing)
+		}
+	}
+	return stderrors.Join(errs...)
+}
+
+// referenceKey normalizes a key value so values read back from the database
+// (often strings or bytes) match the values held by entities
+func referenceKey(value interface{}) string {
+	switch v := value.(type) {
+	case []byte:
+		return strings.ToLower(string(v))
+	case string:
+		return strings.ToLower(v)
+	}
+	return strings.ToLower(fmt.Sprint(value))
+}
+
+// deferReferences records the belongs-to references of entities when the
+// repository runs in a transaction in deferred validation mode. Relations
+// with composite keys and unset (zero) foreign keys are skipped.
+func (r *BaseRepository[T]) deferReferences(ctx context.Context, entities ...*T) {
+	if r.references == nil || len(entities) == 0 {
+		return
+	}
+
+	s, err := r.schema()
+	if err != nil {
+		return
+	}
+
+	for _, rel := range s.Relationships.Relations {
+		if rel.Type != schema.BelongsTo || len(rel.References) != 1 {
+			continue
+		}
+		ref := rel.References[0]
+		if ref.ForeignKey == nil || ref.PrimaryKey == nil {
+			continue
+		}
+
+		target := referenceTarget{table: rel.FieldSchema.Table, column: ref.PrimaryKey.DBName}
+		for _, entity := range entities {
+			if entity == nil {
+				continue
+			}
+			value, zero := ref.ForeignKey.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+			if zero {
+				continue
+			}
+			if rv := reflect.ValueOf(value); rv.Kind() == reflect.Ptr {
+				if rv.IsNil() {
+					continue
+				}
+				value = rv.Elem().Interface()
+			}
+			r.references.add(target, value)
+		}
+	}
+}
+
+// deferConstraints defers the DEFERRABLE constraints of tx when the dialect supports it
+func (r *BaseRepository[T]) deferConstraints(ctx context.Context, tx *gorm.DB) error {
+	deferrer, ok := r.dialect.(dialect.ConstraintDeferrer)
+	if !ok {
+		r.logger.Debug(ctx, "Dialect cannot defer constraints, checking them immediately",
+			logging.String("dialect", r.dialect.Name()))
+		return nil
+	}
+
+	statement, ok := deferrer.DeferConstraints()
+	if !ok {
+		r.logger.Debug(ctx, "Dialect cannot defer constraints, checking them immediately",
+			logging.String("dialect", r.dialect.Name()))
+		return nil
+	}
+
+	if err := tx.Exec(statement).Error; err != nil {
+		return fmt.Errorf("failed to defer constraints: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+)
+
+// ReadModel is a cached query or materialized read model derived from tables
+type ReadModel struct {
+	// Name identifies the read model. Other read models may depend on it.
+	Name string
+	// DependsOn lists the tables and read models the read model is derived from
+	DependsOn []string
+	// Invalidate drops or refreshes the read model after one of its
+	// dependencies was written
+	Invalidate func(ctx context.Context) error
+}
+
+// DependencyGraph links read models to the tables they depend on, so writes
+// to a table invalidate its dependents instead of cache-busting calls spread
+// through application code. Repositories attached with WithDependencies
+// report their writes once the transaction commits; writes made elsewhere,
+// e.g. consumed from change data capture, are reported with TablesWritten.
+type DependencyGraph struct {
+	logger logging.Logger
+
+	mu         sync.RWMutex
+	models     map[string]ReadModel
+	dependents map[string][]string
+}
+
+// NewDependencyGraph creates an empty dependency graph
+func NewDependencyGraph(logger logging.Logger) *DependencyGraph {
+	return &DependencyGraph{
+		logger:     logger,
+		models:     make(map[string]ReadModel),
+		dependents: make(map[string][]string),
+	}
+}
+
+// Register adds a read model to the graph. Its name must be unique and its
+// dependencies must not lead back to it.
+func (g *DependencyGraph) Register(model ReadModel) error {
+	if model.Name == "" {
+		return fmt.Errorf("read model name is required")
+	}
+	if len(model.DependsOn) == 0 {
+		return fmt.Errorf("read model %s has no dependencies", model.Name)
+	}
+	if model.Invalidate == nil {
+		return fmt.Errorf("read model %s has no invalidation function", model.Name)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.models[model.Name]; ok {
+		return fmt.Errorf("read model %s is already registered", model.Name)
+	}
+	for _, dependency := range model.DependsOn {
+		if dependency == model.Name || g.reaches(model.Name, dependency) {
+			return fmt.Errorf("read model %s cannot depend on %s: dependency cycle", model.Name, dependency)
+		}
+	}
+
+	g.models[model.Name] = model
+	for _, dependency := range model.DependsOn {
+		g.dependents[dependency] = append(g.dependents[dependency], model.Name)
+	}
+	return nil
+}
+
+// reaches reports whether target depends on from, directly or not; callers
+// hold the lock
+func (g *DependencyGraph) reaches(from, target string) bool {
+	for _, dependent := range g.dependents[from] {
+		if dependent == target || g.reaches(dependent, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Dependents returns the read models depending on the tables, directly or
+// through other read models, each after the read models it depends on
+func (g *DependencyGraph) Dependents(tables ...string) []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.dependentsOf(tables)
+}
+
+// dependentsOf orders the dependents of tables topologically; callers hold the lock
+func (g *DependencyGraph) dependentsOf(tables []string) []string {
+	affected := make(map[string]bool)
+	var collect func(name string)
+	collect = func(name string) {
+		for _, dependent := range g.dependents[name] {
+			if !affected[dependent] {
+				affected[dependent] = true
+				collect(dependent)
+			}
+		}
+	}
+	for _, table := range tables {
+		collect(table)
+	}
+
+	names := make([]string, 0, len(affected))
+	for name := range affected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ordered := make([]string, 0, len(names))
+	visited := make(map[string]bool, len(names))
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, dependency := range g.models[name].DependsOn {
+			if affected[dependency] {
+				visit(dependency)
+			}
+		}
+		ordered = append(ordered, name)
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	return ordered
+}
+
+// TablesWritten invalidates the read models depending on the written tables,
+// each once and after the read models it depends on. Every dependent is
+// invalidated even when others fail; the returned error joins the failures.
+func (g *DependencyGraph) TablesWritten(ctx context.Context, tables ...string) error {
+	g.mu.RLock()
+	names := g.dependentsOf(tables)
+	models := make([]ReadModel, len(names))
+	for i, name := range names {
+		models[i] = g.models[name]
+	}
+	g.mu.RUnlock()
+
+	var errs []error
+	for _, model := range models {
+		if err := model.Invalidate(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to invalidate read model %s: %w", model.Name, err))
+		}
+	}
+	return stderrors.Join(errs...)
+}
+
+// WithDependencies reports the writes of the repository to graph, which
+// invalidates the read models depending on its table once the transaction
+// of the write commits, at most once per transaction. Writes outside a
+// transaction, or in a transaction opened by hand, invalidate immediately.
+func (r *BaseRepository[T]) WithDependencies(graph *DependencyGraph) *BaseRepository[T] {
+	r.dependencies = graph
+	return r
+}
+
+// invalidateDependents invalidates the read models depending on the table
+// after a write, logging the failures
+func (r *BaseRepository[T]) invalidateDependents(ctx context.Context) {
+	graph := r.dependencies
+	if graph == nil || (r.hooks != nil && !r.hooks.once("dependents:"+r.tableName)) {
+		return
+	}
+
+	r.AfterCommit(ctx, func(ctx context.Context) {
+		if err := graph.TablesWritten(ctx, r.schemaTable()); err != nil && graph.logger != nil {
+			graph.logger.Error(ctx, "Failed to invalidate read models",
+				logging.String("table", r.tableName),
+				logging.ErrorField("error", err))
+		}
+	})
+}
+
+// CachedQuery caches the result of a query until one of the tables it
+// depends on is written, or its TTL expires
+type CachedQuery[V any] struct {
+	load func(ctx context.Context) (V, error)
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	value   V
+	loaded  time.Time
+	valid   bool
+	version uint64
+}
+
+// NewCachedQuery registers in graph a read model named name caching the
+// result of load, invalidated when any of dependsOn is written. A zero ttl
+// keeps the result until then.
+func NewCachedQuery[V any](graph *DependencyGraph, name string, dependsOn []string, ttl time.Duration, load func(ctx context.Context) (V, error)) (*CachedQuery[V], error) {
+	if graph == nil {
+		return nil, fmt.Errorf("dependency graph is required")
+	}
+	if load == nil {
+		return nil, fmt.Errorf("query function is required")
+	}
+
+	q := &CachedQuery[V]{load: load, ttl: ttl}
+	err := graph.Register(ReadModel{
+		Name:      name,
+		DependsOn: dependsOn,
+		Invalidate: func(ctx context.Context) error {
+			q.Invalidate()
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Get returns the cached result, running the query when it is not cached
+func (q *CachedQuery[V]) Get(ctx context.Context) (V, error) {
+	q.mu.Lock()
+	if q.valid && (q.ttl <= 0 || time.Since(q.loaded) < q.ttl) {
+		value := q.value
+		q.mu.Unlock()
+		return value, nil
+	}
+	version := q.version
+	q.mu.Unlock()
+
+	value, err := q.load(ctx)
+	if err != nil {
+		return value, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	// A result loaded while the query was invalidated may already be stale
+	if q.version == version {
+		q.value, q.loaded, q.valid = value, time.Now(), true
+	}
+	return value, nil
+}
+
+// Invalidate drops the cached result
+func (q *CachedQuery[V]) Invalidate() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var zero V
+	q.value, q.valid = zero, false
+	q.version++
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// operationError wraps err, the failure of operation on table, in an
+// ORMError typed by its cause: the type, retry information, query and
+// operation of the ORMError it wraps if any, else the classification of the
+// database error. Missing records, reported by gorm or by the driver, are not
+// found errors matching errors.ErrNotFound. errors.IsNotFound,
+// errors.IsDuplicate and errors.IsTimeout hold for the errors of the
+// repositories, and errors.Is still matches their causes.
+func operationError(err error, table, operation, message string) *errors.ORMError {
+	var wrapped *errors.ORMError
+	var typed interface{ GetType() errors.ErrorType }
+	switch {
+	case stderrors.As(err, &typed):
+		wrapped = errors.Wrap(err, typed.GetType(), message)
+		if cause, ok := typed.(*errors.ORMError); ok {
+			wrapped.WithRetry(cause.Retryable, cause.RetryCount, cause.RetryDelay).
+				WithQuery(cause.Query, cause.Params...)
+			if cause.Operation != "" {
+				// The innermost failing operation names the error, such as
+				// a write refused within a transaction
+				operation, table = cause.Operation, cause.Table
+			}
+		}
+	case stderrors.Is(err, gorm.ErrRecordNotFound), stderrors.Is(err, sql.ErrNoRows):
+		wrapped = errors.Wrap(err, errors.ErrorTypeNotFound, message)
+	case stderrors.Is(err, gorm.ErrDuplicatedKey):
+		wrapped = errors.Wrap(err, errors.ErrorTypeDuplicate, message)
+	case stderrors.Is(err, context.DeadlineExceeded):
+		wrapped = errors.Wrap(err, errors.ErrorTypeTimeout, message)
+	default:
+		classified := retryClassifier.ClassifyError(err, operation)
+		wrapped = errors.Wrap(err, classified.Type, message).WithRetry(classified.Retryable, 0, 0)
+	}
+	return wrapped.WithOperation(operation).WithTable(table)
+}
+
+// newError returns an ORMError of errorType reporting the failure of
+// operation on table
+func newError(errorType errors.ErrorType, table, operation, message string) *errors.ORMError {
+	return errors.New(errorType, message).WithOperation(operation).WithTable(table)
+}
+
+// wrapError wraps err, the failure of operation, in an ORMError typed by its
+// cause (see operationError)
+func (r *BaseRepository[T]) wrapError(err error, operation, message string) error {
+	return operationError(err, r.tableName, operation, message)
+}
+
+// newError returns an error of errorType reporting the failure of operation
+func (r *BaseRepository[T]) newError(errorType errors.ErrorType, operation, message string) error {
+	return newError(errorType, r.tableName, operation, message)
+}
+
+// argumentError returns the error of an invalid argument of operation
+func (r *BaseRepository[T]) argumentError(operation, message string) error {
+	return newError(errors.ErrorTypeValidation, r.tableName, operation, message)
+}
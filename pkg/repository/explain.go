@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/observability"
+	"gorm.io/gorm"
+)
+
+// Defaults of ExplainConfig
+const (
+	defaultMaxPlanSize    = 4096
+	defaultExplainTimeout = time.Second
+)
+
+// ExplainConfig configures the capture of the plans of slow statements. A
+// sampled share of the SELECT statements slower than SlowQueryThreshold is
+// explained with the EXPLAIN statement of the dialect, and the plan is added
+// to the slow query log entry and, with WithObservability, to a query span.
+// Dialects without one (SQL Server, Oracle) capture no plans.
+type ExplainConfig struct {
+	// SampleRate is the share of slow statements explained, from 0 to 1
+	SampleRate float64 `json:"sample_rate"`
+	// MaxPlanSize cuts plans to that many bytes; zero uses 4 KiB
+	MaxPlanSize int `json:"max_plan_size"`
+	// Timeout bounds each EXPLAIN statement; zero uses one second
+	Timeout time.Duration `json:"timeout"`
+}
+
+// planExplainer explains the slow statements of a repository
+type planExplainer struct {
+	config  ExplainConfig
+	planner dialect.QueryPlanner
+	pool    gorm.ConnPool
+}
+
+// newPlanExplainer returns the explainer of the statements run on db, nil
+// when config disables it or the dialect has no EXPLAIN statement
+func newPlanExplainer(config *ExplainConfig, d dialect.Dialect, db *gorm.DB) *planExplainer {
+	if config == nil || config.SampleRate <= 0 || db == nil {
+		return nil
+	}
+	planner, ok := d.(dialect.QueryPlanner)
+	if !ok {
+		return nil
+	}
+	pool := db.Statement.ConnPool
+	if pool == nil {
+		pool = db.ConnPool
+	}
+	return &planExplainer{config: *config, planner: planner, pool: pool}
+}
+
+// explain returns the plan of a sampled SELECT statement, cut to the
+// maximum plan size, and whether it was cut. Failures to explain are
+// reported as no plan.
+func (e *planExplainer) explain(ctx context.Context, sql string) (plan string, truncated, ok bool) {
+	if e == nil || !isSelect(sql) || rand.Float64() >= e.config.SampleRate {
+		return "", false, false
+	}
+
+	timeout := e.config.Timeout
+	if timeout <= 0 {
+		timeout = defaultExplainTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	rows, err := e.pool.QueryContext(ctx, e.planner.ExplainPlan(sql))
+	if err != nil {
+		return "", false, false
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", false, false
+	}
+	var lines []string
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return "", false, false
+		}
+		lines = append(lines, planLine(columns, values))
+	}
+	if rows.Err() != nil || len(lines) == 0 {
+		return "", false, false
+	}
+
+	plan = strings.Join(lines, "\n")
+	size := e.config.MaxPlanSize
+	if size <= 0 {
+		size = defaultMaxPlanSize
+	}
+	if len(plan) > size {
+		return plan[:size] + " ...", true, true
+	}
+	return plan, false, true
+}
+
+// planLine renders a row of a plan: the value of single column plans, the
+// columns and values of tabular ones
+func planLine(columns []string, values []interface{}) string {
+	if len(columns) == 1 {
+		return planValue(values[0])
+	}
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		parts[i] = column + "=" + planValue(values[i])
+	}
+	return strings.Join(parts, " ")
+}
+
+// planValue renders a plan value as text
+func planValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// isSelect reports whether sql is a SELECT statement, the only ones explained
+func isSelect(sql string) bool {
+	sql = strings.TrimSpace(sql)
+	return len(sql) >= 6 && strings.EqualFold(sql[:6], "SELECT")
+}
+
+// recordPlan records an explained slow statement to the observability manager
+func (o *queryObserver) recordPlan(ctx context.Context, summary, sql, plan string, truncated bool, begin time.Time, elapsed time.Duration) {
+	if o.manager == nil {
+		return
+	}
+	o.manager.RecordSlowQuery(ctx, observability.SlowQuery{
+		Summary:   summary,
+		Table:     o.table,
+		Query:     sql,
+		Started:   begin,
+		Duration:  elapsed,
+		Plan:      plan,
+		Truncated: truncated,
+	})
+}
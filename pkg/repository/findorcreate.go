@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FindOrCreateByConditions finds the first entity matching conds into dest or,
+// when none matches, creates it and reports whether it did. Unlike
+// FirstOrInitByConditions, the entity is persisted. It is initialized from a
+// map or entity struct condition, then from attrs (a map keyed by column or a
+// struct of the entity), which only apply to created entities:
+//
+//	created, err := repo.FindOrCreateByConditions(ctx, &user,
+//		map[string]interface{}{"plan": "free"}, // attrs
+//		map[string]interface{}{"email": email}) // conds
+//
+// The lookup runs on the primary, and the insert skips conflicting rows (ON
+// CONFLICT DO NOTHING where supported), so concurrent callers racing to
+// create the same entity through a unique constraint all end with the one
+// row: the losers read the winner's entity and report false.
+func (r *BaseRepository[T]) FindOrCreateByConditions(ctx context.Context, dest *T, attrs interface{}, conds ...interface{}) (bool, error) {
+	return r.findOrCreate(ctx, dest, attrs, conds, nil)
+}
+
+// findOrCreate implements FindOrCreateByConditions, calling prepare on the
+// entity about to be created after its initialization
+func (r *BaseRepository[T]) findOrCreate(ctx context.Context, dest *T, attrs interface{}, conds []interface{}, prepare func(*T) error) (bool, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "FindOrCreateByConditions", time.Since(start))
+	}()
+
+	if dest == nil {
+		r.metrics.IncrementOperationsFor("FindOrCreateByConditions", false)
+		return false, r.argumentError("FindOrCreateByConditions", "destination cannot be nil")
+	}
+	if len(conds) == 0 {
+		r.metrics.IncrementOperationsFor("FindOrCreateByConditions", false)
+		return false, r.argumentError("FindOrCreateByConditions", "conditions are required")
+	}
+
+	find := func() *gorm.DB {
+		return r.scopeDeleted(ctx, r.db.WithContext(ctx)).Where(conds[0], conds[1:]...)
+	}
+
+	var found bool
+	err := r.retry(ctx, "FindOrCreateByConditions", func() error {
+		var zero T
+		*dest = zero
+		// Find rather than First: a missing entity is expected, not an error to log
+		result := find().Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: clause.PrimaryKey}}).Limit(1).Find(dest)
+		found = result.RowsAffected > 0
+		return result.Error
+	})
+	if err != nil {
+		r.metrics.IncrementOperationsFor("FindOrCreateByConditions", false)
+		return false, r.wrapError(err, "FindOrCreateByConditions", "failed to find entity by conditions")
+	}
+	if found {
+		r.metrics.IncrementOperationsFor("FindOrCreateByConditions", true)
+		return false, nil
+	}
+
+	for _, values := range []interface{}{conds[0], attrs} {
+		if err := r.assignValues(ctx, dest, values); err != nil {
+			r.metrics.IncrementOperationsFor("FindOrCreateByConditions", false)
+			return false, err
+		}
+	}
+	if prepare != nil {
+		if err := prepare(dest); err != nil {
+			r.metrics.IncrementOperationsFor("FindOrCreateByConditions", false)
+			return false, err
+		}
+	}
+	if r.config.EnableValidation {
+		if result, err := r.Validate(ctx, dest); err != nil {
+			r.metrics.IncrementOperationsFor("FindOrCreateByConditions", false)
+			return false, r.wrapError(err, "FindOrCreateByConditions", "validation failed")
+		} else if !result.Valid {
+			r.metrics.IncrementOperationsFor("FindOrCreateByConditions", false)
+			return false, r.validationError(ctx, "FindOrCreateByConditions", result)
+		}
+	}
+
+	var created bool
+	err = r.retry(ctx, "FindOrCreateByConditions", func() error {
+		result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(dest)
+		created = result.Error == nil && result.RowsAffected > 0
+		if result.Error != nil && !isUniqueViolation(result.Error) {
+			return result.Error
+		}
+		return nil
+	})
+	if err != nil {
+		r.metrics.IncrementOperationsFor("FindOrCreateByConditions", false)
+		return false, r.wrapError(err, "FindOrCreateByConditions", "failed to create entity")
+	}
+
+	if !created {
+		// A concurrent caller created the entity first: read theirs
+		var zero T
+		*dest = zero
+		if err := find().First(dest).Error; err != nil {
+			r.metrics.IncrementOperationsFor("FindOrCreateByConditions", false)
+			if stderrors.Is(err, gorm.ErrRecordNotFound) {
+				return false, r.wrapError(gorm.ErrDuplicatedKey, "FindOrCreateByConditions", "failed to create entity: conflicts with a row not matching the conditions")
+			}
+			return false, r.wrapError(err, "FindOrCreateByConditions", "failed to find entity by conditions")
+		}
+		r.metrics.IncrementOperationsFor("FindOrCreateByConditions", true)
+		return false, nil
+	}
+
+	r.deferReferences(ctx, dest)
+	r.cacheWritten(ctx, dest)
+	if err := r.notifyChange(ctx, ChangeCreate, r.getEntityID(dest), nil, dest); err != nil {
+		r.metrics.IncrementOperationsFor("FindOrCreateByConditions", false)
+		return true, err
+	}
+	r.metrics.IncrementOperationsFor("FindOrCreateByConditions", true)
+	return true, nil
+}
+
+// assignValues sets the fields of entity from values, a map keyed by column
+// or field name or a struct of the entity whose non-zero fields are copied.
+// Other values, such as SQL conditions, are ignored.
+func (r *BaseRepository[T]) assignValues(ctx context.Context, entity *T, values interface{}) error {
+	var source reflect.Value
+	switch v := values.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+	case T:
+		source = reflect.ValueOf(&v).Elem()
+	case *T:
+		if v == nil {
+			return nil
+		}
+		source = reflect.ValueOf(v).Elem()
+	default:
+		return nil
+	}
+
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+	target := reflect.ValueOf(entity).Elem()
+
+	if m, ok := values.(map[string]interface{}); ok {
+		for key, value := range m {
+			field := s.LookUpField(key)
+			if field == nil {
+				return r.argumentError("FindOrCreateByConditions", fmt.Sprintf("%s has no column %q", s.Name, key))
+			}
+			if err := field.Set(ctx, target, value); err != nil {
+				return r.wrapError(err, "FindOrCreateByConditions", "failed to set "+field.Name)
+			}
+		}
+		return nil
+	}
+	for _, field := range s.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		if value, zero := field.ValueOf(ctx, source); !zero {
+			if err := field.Set(ctx, target, value); err != nil {
+				return r.wrapError(err, "FindOrCreateByConditions", "failed to set "+field.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation, as
+// raised by dialects without ON CONFLICT DO NOTHING
+func isUniqueViolation(err error) bool {
+	if stderrors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "duplicate") || strings.Contains(message, "unique constraint")
+}
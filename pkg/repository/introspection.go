@@ -0,0 +1,248 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// EntityInfo describes a registered entity for the CLI and debug endpoints
+type EntityInfo struct {
+	Name          string         `json:"name"`
+	Table         string         `json:"table"`
+	Dialect       string         `json:"dialect"`
+	Columns       []ColumnInfo   `json:"columns"`
+	Indexes       []IndexInfo    `json:"indexes"`
+	EstimatedRows int64          `json:"estimated_rows"`
+	ExactRowCount bool           `json:"exact_row_count"`
+	Features      EntityFeatures `json:"features"`
+}
+
+// ColumnInfo describes a mapped column
+type ColumnInfo struct {
+	Name         string `json:"name"`
+	Field        string `json:"field"`
+	DataType     string `json:"data_type"`
+	DatabaseType string `json:"database_type,omitempty"`
+	PrimaryKey   bool   `json:"primary_key"`
+	Nullable     bool   `json:"nullable"`
+}
+
+// IndexInfo describes an index of the entity table
+type IndexInfo struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique"`
+}
+
+// EntityFeatures lists the repository features configured for an entity.
+// Decorating repositories (caches, tenancy wrappers) set their own flags
+// when describing the entity.
+type EntityFeatures struct {
+	SoftDelete   bool `json:"soft_delete"`
+	Validation   bool `json:"validation"`
+	ReadReplicas bool `json:"read_replicas"`
+	Cache        bool `json:"cache"`
+	Tenancy      bool `json:"tenancy"`
+}
+
+// Describer is implemented by repositories that can describe their entity
+type Describer interface {
+	Describe(ctx context.Context) (*EntityInfo, error)
+}
+
+// Registry holds the repositories whose entities are exposed by ListEntities
+type Registry struct {
+	mu         sync.RWMutex
+	describers map[string]Describer
+}
+
+// DefaultRegistry is the registry used by Register and ListEntities
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty entity registry
+func NewRegistry() *Registry {
+	return &Registry{describers: make(map[string]Describer)}
+}
+
+// Register registers d under the entity name, replacing any existing entry
+func (reg *Registry) Register(name string, d Describer) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.describers[name] = d
+}
+
+// Unregister removes the entity name from the registry
+func (reg *Registry) Unregister(name string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	delete(reg.describers, name)
+}
+
+// ListEntities describes every registered entity, ordered by name. Entities
+// that fail to describe are skipped and their errors joined in the returned error.
+func (reg *Registry) ListEntities(ctx context.Context) ([]EntityInfo, error) {
+	reg.mu.RLock()
+	names := make([]string, 0, len(reg.describers))
+	describers := make(map[string]Describer, len(reg.describers))
+	for name, d := range reg.describers {
+		names = append(names, name)
+		describers[name] = d
+	}
+	reg.mu.RUnlock()
+
+	sort.Strings(names)
+
+	var infos []EntityInfo
+	var errs []error
+	for _, name := range names {
+		info, err := describers[name].Describe(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to describe entity %s: %w", name, err))
+			continue
+		}
+		infos = append(infos, *info)
+	}
+	return infos, stderrors.Join(errs...)
+}
+
+// ListEntities describes every entity registered in DefaultRegistry
+func ListEntities(ctx context.Context) ([]EntityInfo, error) {
+	return DefaultRegistry.ListEntities(ctx)
+}
+
+// Register registers the repository in registry (DefaultRegistry when nil)
+// under its entity type name
+func (r *BaseRepository[T]) Register(registry *Registry) *BaseRepository[T] {
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+	registry.Register(r.modelType.Name(), r)
+	return r
+}
+
+// Describe returns the metadata of the repository entity: mapped columns,
+// table indexes, an estimated row count and the configured features
+func (r *BaseRepository[T]) Describe(ctx context.Context) (*EntityInfo, error) {
+	db := r.db.WithContext(ctx)
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, r.wrapError(err, "Describe", "failed to parse entity schema")
+	}
+
+	info := &EntityInfo{
+		Name:    r.modelType.Name(),
+		Table:   stmt.Schema.Table,
+		Dialect: r.dialect.Name(),
+		Features: EntityFeatures{
+			SoftDelete:   hasSoftDelete(stmt.Schema),
+			Validation:   r.config.EnableValidation,
+			ReadReplicas: r.router != nil,
+			Cache:        r.cache != nil,
+		},
+	}
+
+	migrator := db.Migrator()
+	exists := migrator.HasTable(new(T))
+
+	databaseTypes := make(map[string]string)
+	if exists {
+		columnTypes, err := migrator.ColumnTypes(new(T))
+		if err != nil {
+			return nil, r.wrapError(err, "Describe", "failed to inspect columns")
+		}
+		for _, column := range columnTypes {
+			databaseTypes[column.Name()] = column.DatabaseTypeName()
+		}
+	}
+
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		info.Columns = append(info.Columns, ColumnInfo{
+			Name:         field.DBName,
+			Field:        field.Name,
+			DataType:     string(field.DataType),
+			DatabaseType: databaseTypes[field.DBName],
+			PrimaryKey:   field.PrimaryKey,
+			Nullable:     !field.NotNull && !field.PrimaryKey,
+		})
+	}
+
+	info.Indexes = r.describeIndexes(migrator, stmt.Schema, exists)
+
+	if exists {
+		rows, exact, err := r.estimateRows(db, info.Table)
+		if err != nil {
+			return nil, r.wrapError(err, "Describe", "failed to estimate row count")
+		}
+		info.EstimatedRows, info.ExactRowCount = rows, exact
+	}
+
+	return info, nil
+}
+
+// describeIndexes lists the indexes of the table, falling back to the
+// indexes declared in the schema when the table or driver cannot be inspected
+func (r *BaseRepository[T]) describeIndexes(migrator gorm.Migrator, s *schema.Schema, exists bool) []IndexInfo {
+	var indexes []IndexInfo
+	if exists {
+		if dbIndexes, err := migrator.GetIndexes(new(T)); err == nil {
+			for _, index := range dbIndexes {
+				unique, _ := index.Unique()
+				indexes = append(indexes, IndexInfo{Name: index.Name(), Columns: index.Columns(), Unique: unique})
+			}
+			sort.Slice(indexes, func(i, j int) bool { return indexes[i].Name < indexes[j].Name })
+			return indexes
+		}
+	}
+
+	for _, index := range s.ParseIndexes() {
+		columns := make([]string, len(index.Fields))
+		for i, field := range index.Fields {
+			columns[i] = field.DBName
+		}
+		indexes = append(indexes, IndexInfo{Name: index.Name, Columns: columns, Unique: index.Class == "UNIQUE"})
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Name < indexes[j].Name })
+	return indexes
+}
+
+// estimateRows returns the planner row estimate when the dialect exposes one,
+// and an exact count otherwise
+func (r *BaseRepository[T]) estimateRows(db *gorm.DB, table string) (int64, bool, error) {
+	if estimator, ok := r.dialect.(dialect.RowEstimator); ok {
+		var estimate sql.NullInt64
+		if err := db.Raw(estimator.EstimateRows(), table).Row().Scan(&estimate); err == nil && estimate.Valid && estimate.Int64 >= 0 {
+			return estimate.Int64, false, nil
+		}
+	}
+
+	var count int64
+	if err := db.Model(new(T)).Count(&count).Error; err != nil {
+		return 0, false, fmt.Errorf("failed to count rows: %w", err)
+	}
+	return count, true, nil
+}
+
+// hasSoftDelete reports whether the schema has a gorm.DeletedAt field or the
+// deleted_at column of models.BaseModel
+func hasSoftDelete(s *schema.Schema) bool {
+	deletedAt := reflect.TypeOf(gorm.DeletedAt{})
+	for _, field := range s.Fields {
+		if field.FieldType == deletedAt || field.DBName == "deleted_at" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,428 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JSON conditions query JSON columns, such as models.JSONMap, in the syntax
+// of each driver: jsonb operators on Postgres and CockroachDB, JSON_CONTAINS
+// and JSON_EXTRACT on MySQL, json_extract and json_each on SQLite:
+//
+//	cond := repository.And(
+//		repository.JSONContains("attributes", map[string]interface{}{"color": "red"}),
+//		repository.JSONExtractEq("attributes", "size.eu", 42),
+//		repository.JSONKeyExists("attributes", "tags.0"),
+//	)
+//
+// Paths are keys and array indexes separated by dots. Values are encoded as
+// JSON and bound as parameters. Postgres columns must be jsonb. Other
+// drivers fail with errors.ErrUnsupported.
+
+// JSONContains returns a condition matching rows whose JSON column contains
+// value: objects holding its keys with contained values, arrays holding its
+// elements, or a scalar equal to it. On SQLite, objects and arrays inside
+// arrays match when they are equal.
+func JSONContains(column string, value interface{}) Cond {
+	return typedCond(column, jsonCond{kind: jsonContains, column: column, value: value})
+}
+
+// JSONExtractEq returns a condition matching rows whose JSON column holds
+// value at path
+func JSONExtractEq(column, path string, value interface{}) Cond {
+	return typedCond(column, jsonCond{kind: jsonExtract, column: column, path: path, value: value})
+}
+
+// JSONKeyExists returns a condition matching rows whose JSON column has a
+// value, possibly null, at path
+func JSONKeyExists(column, path string) Cond {
+	return typedCond(column, jsonCond{kind: jsonExists, column: column, path: path})
+}
+
+// Kinds of JSON conditions
+const (
+	jsonContains = "contains"
+	jsonExtract  = "extract"
+	jsonExists   = "exists"
+)
+
+// jsonPathKeyRegex matches a key or an index of a JSON path
+var jsonPathKeyRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// jsonCond is a condition on a JSON column, rendered for the driver of the
+// statement it is built into
+type jsonCond struct {
+	kind   string
+	column string
+	path   string
+	value  interface{}
+}
+
+// keys returns the keys and indexes of the path of c
+func (c jsonCond) keys() ([]string, error) {
+	if c.path == "" {
+		if c.kind == jsonContains {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("JSON path of %s is empty", c.column)
+	}
+	keys := strings.Split(c.path, ".")
+	for _, key := range keys {
+		if !jsonPathKeyRegex.MatchString(key) {
+			return nil, fmt.Errorf("invalid JSON path of %s: %q", c.column, c.path)
+		}
+	}
+	return keys, nil
+}
+
+// Build writes the condition in the syntax of the driver of the statement
+func (c jsonCond) Build(builder clause.Builder) {
+	stmt, ok := builder.(*gorm.Statement)
+	if !ok {
+		return
+	}
+	keys, err := c.keys()
+	if err != nil {
+		_ = stmt.AddError(newError(errors.ErrorTypeValidation, stmt.Table, "Conditions", err.Error()))
+		return
+	}
+	var document string
+	if c.kind != jsonExists {
+		data, err := json.Marshal(c.value)
+		if err != nil {
+			_ = stmt.AddError(newError(errors.ErrorTypeValidation, stmt.Table, "Conditions",
+				fmt.Sprintf("cannot encode JSON value of %s: %v", c.column, err)))
+			return
+		}
+		document = string(data)
+	}
+	column := clause.Column{Table: clause.CurrentTable, Name: c.column}
+
+	switch stmt.Dialector.Name() {
+	case "postgres", "cockroachdb":
+		path := "{" + strings.Join(keys, ",") + "}"
+		switch c.kind {
+		case jsonContains:
+			stmt.WriteQuoted(column)
+			_, _ = stmt.WriteString(" @> CAST(")
+			stmt.AddVar(stmt, document)
+			_, _ = stmt.WriteString(" AS jsonb)")
+		case jsonExtract:
+			stmt.WriteQuoted(column)
+			_, _ = stmt.WriteString(" #> CAST(")
+			stmt.AddVar(stmt, path)
+			_, _ = stmt.WriteString(" AS text[]) = CAST(")
+			stmt.AddVar(stmt, document)
+			_, _ = stmt.WriteString(" AS jsonb)")
+		case jsonExists:
+			stmt.WriteQuoted(column)
+			_, _ = stmt.WriteString(" #> CAST(")
+			stmt.AddVar(stmt, path)
+			_, _ = stmt.WriteString(" AS text[]) IS NOT NULL")
+		}
+	case "mysql":
+		switch c.kind {
+		case jsonContains:
+			_, _ = stmt.WriteString("JSON_CONTAINS(")
+			stmt.WriteQuoted(column)
+			_, _ = stmt.WriteString(", ")
+			stmt.AddVar(stmt, document)
+			_, _ = stmt.WriteString(")")
+		case jsonExtract:
+			_, _ = stmt.WriteString("JSON_EXTRACT(")
+			stmt.WriteQuoted(column)
+			_, _ = stmt.WriteString(", ")
+			stmt.AddVar(stmt, jsonPath(keys))
+			_, _ = stmt.WriteString(") = CAST(")
+			stmt.AddVar(stmt, document)
+			_, _ = stmt.WriteString(" AS JSON)")
+		case jsonExists:
+			_, _ = stmt.WriteString("JSON_CONTAINS_PATH(")
+			stmt.WriteQuoted(column)
+			_, _ = stmt.WriteString(", 'one', ")
+			stmt.AddVar(stmt, jsonPath(keys))
+			_, _ = stmt.WriteString(")")
+		}
+	case "sqlite":
+		switch c.kind {
+		case jsonContains:
+			var value interface{}
+			_ = json.Unmarshal([]byte(document), &value)
+			sqliteContains(stmt, column, keys, value, true)
+		case jsonExtract:
+			_, _ = stmt.WriteString("json_extract(")
+			stmt.WriteQuoted(column)
+			_, _ = stmt.WriteString(", ")
+			stmt.AddVar(stmt, jsonPath(keys))
+			_, _ = stmt.WriteString(") = ")
+			sqliteValue(stmt, document)
+		case jsonExists:
+			_, _ = stmt.WriteString("json_type(")
+			stmt.WriteQuoted(column)
+			_, _ = stmt.WriteString(", ")
+			stmt.AddVar(stmt, jsonPath(keys))
+			_, _ = stmt.WriteString(") IS NOT NULL")
+		}
+	default:
+		_ = stmt.AddError(fmt.Errorf("JSON conditions on %s: %w", stmt.Dialector.Name(), stderrors.ErrUnsupported))
+	}
+}
+
+// jsonPath renders keys as a MySQL and SQLite JSON path, e.g. $.tags[0]
+func jsonPath(keys []string) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, key := range keys {
+		if _, err := strconv.Atoi(key); err == nil {
+			b.WriteString("[" + key + "]")
+			continue
+		}
+		b.WriteString("." + key)
+	}
+	return b.String()
+}
+
+// sqliteValue writes a value compared with a json_extract result: JSON text
+// for objects and arrays, the SQL value for scalars
+func sqliteValue(stmt *gorm.Statement, document string) {
+	switch document[0] {
+	case '{', '[':
+		_, _ = stmt.WriteString("json(")
+		stmt.AddVar(stmt, document)
+		_, _ = stmt.WriteString(")")
+	default:
+		var decoded interface{}
+		_ = json.Unmarshal([]byte(document), &decoded)
+		stmt.AddVar(stmt, decoded)
+	}
+}
+
+// sqliteContains writes the containment of value, decoded from JSON, at
+// keys of column. Scalars only match the elements of top level arrays.
+func sqliteContains(stmt *gorm.Statement, column clause.Column, keys []string, value interface{}, top bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			if !jsonPathKeyRegex.MatchString(name) {
+				_ = stmt.AddError(newError(errors.ErrorTypeValidation, stmt.Table, "Conditions",
+					fmt.Sprintf("invalid JSON key of %s: %q", column.Name, name)))
+				return
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		_, _ = stmt.WriteString("(")
+		sqliteType(stmt, column, keys, "object")
+		for _, name := range names {
+			_, _ = stmt.WriteString(" AND ")
+			// Object keys are quoted, so numeric ones are not taken for indexes
+			sqliteContains(stmt, column, append(append([]string(nil), keys...), `"`+name+`"`), v[name], false)
+		}
+		_, _ = stmt.WriteString(")")
+	case []interface{}:
+		_, _ = stmt.WriteString("(")
+		sqliteType(stmt, column, keys, "array")
+		for _, element := range v {
+			_, _ = stmt.WriteString(" AND ")
+			sqliteElement(stmt, column, keys, element)
+		}
+		_, _ = stmt.WriteString(")")
+	case nil:
+		sqliteType(stmt, column, keys, "null")
+	default:
+		if top {
+			_, _ = stmt.WriteString("(")
+			sqliteType(stmt, column, keys, "array")
+			_, _ = stmt.WriteString(" AND ")
+			sqliteElement(stmt, column, keys, value)
+			_, _ = stmt.WriteString(" OR json_extract(")
+		} else {
+			_, _ = stmt.WriteString("(json_extract(")
+		}
+		stmt.WriteQuoted(column)
+		_, _ = stmt.WriteString(", ")
+		stmt.AddVar(stmt, jsonPath(keys))
+		_, _ = stmt.WriteString(") = ")
+		stmt.AddVar(stmt, value)
+		_, _ = stmt.WriteString(")")
+	}
+}
+
+// sqliteType writes the check of the JSON type of the value at keys of column
+func sqliteType(stmt *gorm.Statement, column clause.Column, keys []string, jsonType string) {
+	_, _ = stmt.WriteString("json_type(")
+	stmt.WriteQuoted(column)
+	_, _ = stmt.WriteString(", ")
+	stmt.AddVar(stmt, jsonPath(keys))
+	_, _ = stmt.WriteString(") = '" + jsonType + "'")
+}
+
+// sqliteElement writes the presence of value in the array at keys of column.
+// Objects and arrays must equal an element.
+func sqliteElement(stmt *gorm.Statement, column clause.Column, keys []string, value interface{}) {
+	_, _ = stmt.WriteString("EXISTS (SELECT 1 FROM json_each(")
+	stmt.WriteQuoted(column)
+	_, _ = stmt.WriteString(", ")
+	stmt.AddVar(stmt, jsonPath(keys))
+	_, _ = stmt.WriteString(") WHERE ")
+	switch value.(type) {
+	case nil:
+		_, _ = stmt.WriteString("json_each.type = 'null'")
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(value)
+		_, _ = stmt.WriteString("json_each.value = json(")
+		stmt.AddVar(stmt, string(data))
+		_, _ = stmt.WriteString(")")
+	default:
+		_, _ = stmt.WriteString("json_each.value = ")
+		stmt.AddVar(stmt, value)
+	}
+	_, _ = stmt.WriteString(")")
+}
+
+// json returns the predicate of a JSON condition, evaluated on the decoded
+// document of the column
+func (m memoryMatcher) json(c jsonCond) (memoryPredicate, error) {
+	field := m.schema.LookUpField(c.column)
+	if field == nil {
+		return nil, errors.NewFieldValidationError(m.schema.Table, []errors.FieldError{{
+			Field:   c.column,
+			Rule:    "column",
+			Message: "unknown column of " + m.schema.Table,
+		}})
+	}
+	keys, err := c.keys()
+	if err != nil {
+		return nil, newError(errors.ErrorTypeValidation, m.schema.Table, "Conditions", err.Error())
+	}
+	var expected interface{}
+	if c.kind != jsonExists {
+		if expected, err = decodeJSON(c.value); err != nil {
+			return nil, newError(errors.ErrorTypeValidation, m.schema.Table, "Conditions",
+				fmt.Sprintf("cannot encode JSON value of %s: %v", c.column, err))
+		}
+	}
+
+	return func(entity reflect.Value) bool {
+		value, _ := field.ValueOf(context.Background(), entity)
+		document, ok := jsonDocument(normalizeValue(value))
+		if !ok {
+			return false
+		}
+		at, found := jsonAt(document, keys)
+		switch c.kind {
+		case jsonContains:
+			return found && jsonContainsValue(at, expected, true)
+		case jsonExtract:
+			return found && reflect.DeepEqual(at, expected)
+		}
+		return found
+	}, nil
+}
+
+// decodeJSON returns value as decoded from its JSON encoding
+func decodeJSON(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	err = json.Unmarshal(data, &decoded)
+	return decoded, err
+}
+
+// jsonDocument decodes the JSON document stored in a column value
+func jsonDocument(value interface{}) (interface{}, bool) {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		return nil, false
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		decoded, err := decodeJSON(v)
+		return decoded, err == nil
+	}
+	var decoded interface{}
+	return decoded, json.Unmarshal(data, &decoded) == nil
+}
+
+// jsonAt returns the value of document at keys
+func jsonAt(document interface{}, keys []string) (interface{}, bool) {
+	for _, key := range keys {
+		switch v := document.(type) {
+		case map[string]interface{}:
+			value, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			document = value
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			document = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return document, true
+}
+
+// jsonContainsValue reports whether document contains value as the jsonb @>
+// operator does; a top level array also contains its scalar elements
+func jsonContainsValue(document, value interface{}, top bool) bool {
+	switch want := value.(type) {
+	case map[string]interface{}:
+		have, ok := document.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, v := range want {
+			if actual, ok := have[key]; !ok || !jsonContainsValue(actual, v, false) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		have, ok := document.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, v := range want {
+			if !jsonHasElement(have, v) {
+				return false
+			}
+		}
+		return true
+	}
+	if have, ok := document.([]interface{}); ok && top {
+		return jsonHasElement(have, value)
+	}
+	return reflect.DeepEqual(document, value)
+}
+
+// jsonHasElement reports whether an element of elements contains value
+func jsonHasElement(elements []interface{}, value interface{}) bool {
+	for _, element := range elements {
+		if jsonContainsValue(element, value, false) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"bytes"
+	"cmp"
+	"reflect"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/models"
+)
+
+var (
+	uuidKeyType = reflect.TypeOf(uuid.UUID{})
+	ulidKeyType = reflect.TypeOf(models.ULID{})
+)
+
+// keyTypeOf returns the type of the ID field of T, nil when it has none
+func keyTypeOf[T any]() reflect.Type {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	if field, ok := t.FieldByName("ID"); ok {
+		return field.Type
+	}
+	return nil
+}
+
+// entityIDOf returns the ID of entity, nil when it has no ID field
+func entityIDOf(entity interface{}) models.EntityID {
+	v := reflect.ValueOf(entity)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	if field := v.FieldByName("ID"); field.IsValid() {
+		return field.Interface()
+	}
+	return nil
+}
+
+// setEntityIDOf sets the ID of entity when id has the type of its ID field
+func setEntityIDOf(entity interface{}, id models.EntityID) {
+	v := reflect.ValueOf(entity)
+	if v.Kind() != reflect.Ptr || v.IsNil() || id == nil {
+		return
+	}
+	if field := v.Elem().FieldByName("ID"); field.IsValid() && field.CanSet() && field.Type() == reflect.TypeOf(id) {
+		field.Set(reflect.ValueOf(id))
+	}
+}
+
+// normalizeID converts id to keyType: integers of another size, the text
+// of integers, UUIDs and ULIDs. Other IDs are returned unchanged, so they
+// match no entity.
+func normalizeID(id models.EntityID, keyType reflect.Type) models.EntityID {
+	if id == nil || keyType == nil {
+		return id
+	}
+	v := reflect.ValueOf(id)
+	if v.Type() == keyType {
+		return id
+	}
+
+	if s, ok := id.(string); ok {
+		switch {
+		case keyType == uuidKeyType:
+			if parsed, err := uuid.Parse(s); err == nil {
+				return parsed
+			}
+		case keyType == ulidKeyType:
+			if parsed, err := models.ParseULID(s); err == nil {
+				return parsed
+			}
+		case isIntegerKind(keyType.Kind()):
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return reflect.ValueOf(n).Convert(keyType).Interface()
+			}
+		}
+		return id
+	}
+	if isIntegerKind(v.Kind()) && isIntegerKind(keyType.Kind()) {
+		return v.Convert(keyType).Interface()
+	}
+	return id
+}
+
+// isZeroID reports whether id is unset: nil or the zero value of its type
+func isZeroID(id models.EntityID) bool {
+	return id == nil || reflect.ValueOf(id).IsZero()
+}
+
+// uuidOfID returns id when it is a UUID, uuid.Nil otherwise
+func uuidOfID(id models.EntityID) uuid.UUID {
+	if u, ok := id.(uuid.UUID); ok {
+		return u
+	}
+	return uuid.Nil
+}
+
+// compareIDs orders IDs of the same type: integers by value, UUIDs and
+// ULIDs by their bytes, others by their text
+func compareIDs(a, b models.EntityID) int {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch {
+	case a == nil || b == nil || va.Type() != vb.Type():
+		return bytes.Compare([]byte(idString(a)), []byte(idString(b)))
+	case va.CanInt():
+		return cmp.Compare(va.Int(), vb.Int())
+	case va.CanUint():
+		return cmp.Compare(va.Uint(), vb.Uint())
+	case va.Kind() == reflect.Array && va.Type().Elem().Kind() == reflect.Uint8:
+		x, y := make([]byte, va.Len()), make([]byte, vb.Len())
+		reflect.Copy(reflect.ValueOf(x), va)
+		reflect.Copy(reflect.ValueOf(y), vb)
+		return bytes.Compare(x, y)
+	}
+	return bytes.Compare([]byte(idString(a)), []byte(idString(b)))
+}
+
+// idString returns the text of id, empty for nil
+func idString(id models.EntityID) string {
+	switch v := id.(type) {
+	case nil:
+		return ""
+	case interface{ String() string }:
+		return v.String()
+	case string:
+		return v
+	}
+	v := reflect.ValueOf(id)
+	if v.CanInt() {
+		return strconv.FormatInt(v.Int(), 10)
+	}
+	if v.CanUint() {
+		return strconv.FormatUint(v.Uint(), 10)
+	}
+	return ""
+}
+
+// isIntegerKind reports whether kind is a signed or unsigned integer
+func isIntegerKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
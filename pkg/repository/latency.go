@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Operation categories of the latency breakdown
+const (
+	CategoryCreate = "create"
+	CategoryRead   = "read"
+	CategoryUpdate = "update"
+	CategoryDelete = "delete"
+	CategoryOther  = "other"
+)
+
+// LatencySummary represents the percentiles of a latency distribution,
+// accurate to about 3% of the value
+type LatencySummary struct {
+	Count int64         `json:"count"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// precisionBits is the number of significant bits kept by latency recorders:
+// 32 sub-buckets per power of two
+const precisionBits = 5
+
+// latencyRecorder is a sparse log-linear histogram in the manner of HDR
+// histograms. Durations are kept in nanoseconds with precisionBits
+// significant bits, so quantiles are accurate to the relative width of a
+// sub-bucket whatever the range of the observations.
+type latencyRecorder struct {
+	counts map[int32]int64
+	count  int64
+	sum    time.Duration
+	max    time.Duration
+}
+
+// newLatencyRecorder creates an empty latency recorder
+func newLatencyRecorder() *latencyRecorder {
+	return &latencyRecorder{counts: make(map[int32]int64)}
+}
+
+// observe records a latency observation
+func (l *latencyRecorder) observe(duration time.Duration) {
+	if duration < 0 {
+		duration = 0
+	}
+	l.counts[recorderIndex(uint64(duration))]++
+	l.count++
+	l.sum += duration
+	if duration > l.max {
+		l.max = duration
+	}
+}
+
+// recorderIndex returns the sub-bucket of a value: values below
+// 2^(precisionBits+1) have their own, larger ones share it with the values
+// equal in their precisionBits+1 leading bits
+func recorderIndex(value uint64) int32 {
+	const subBuckets = 1 << precisionBits
+	shift := bits.Len64(value) - (precisionBits + 1)
+	if shift <= 0 {
+		return int32(value)
+	}
+	return int32(shift*subBuckets) + int32(value>>uint(shift))
+}
+
+// recorderValue returns the middle of the values of a sub-bucket
+func recorderValue(index int32) time.Duration {
+	const subBuckets = 1 << precisionBits
+	if index < 2*subBuckets {
+		return time.Duration(index)
+	}
+	shift := uint(index/subBuckets - 1)
+	lower := uint64(index%subBuckets+subBuckets) << shift
+	return time.Duration(lower + (uint64(1)<<shift)/2)
+}
+
+// quantile returns the q-quantile (0..1) of the observations, at most the maximum
+func (l *latencyRecorder) quantile(q float64, indexes []int32) time.Duration {
+	if l.count == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(l.count)))
+	if rank < 1 {
+		rank = 1
+	}
+	var cumulative int64
+	for _, index := range indexes {
+		cumulative += l.counts[index]
+		if cumulative >= rank {
+			if value := recorderValue(index); value < l.max {
+				return value
+			}
+			return l.max
+		}
+	}
+	return l.max
+}
+
+// summary returns the percentiles of the observations
+func (l *latencyRecorder) summary() LatencySummary {
+	if l == nil || l.count == 0 {
+		return LatencySummary{}
+	}
+
+	indexes := make([]int32, 0, len(l.counts))
+	for index := range l.counts {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+	return LatencySummary{
+		Count: l.count,
+		Mean:  l.sum / time.Duration(l.count),
+		P50:   l.quantile(0.5, indexes),
+		P90:   l.quantile(0.9, indexes),
+		P99:   l.quantile(0.99, indexes),
+		Max:   l.max,
+	}
+}
+
+// operationCategory returns the category of a repository operation; upserts
+// and restores count as updates, soft deletes as deletes
+func operationCategory(operation string) string {
+	switch {
+	case strings.HasPrefix(operation, "Create"):
+		return CategoryCreate
+	case strings.HasPrefix(operation, "Update"), strings.HasPrefix(operation, "Upsert"), strings.HasPrefix(operation, "Restore"):
+		return CategoryUpdate
+	case strings.HasPrefix(operation, "Delete"), strings.HasPrefix(operation, "SoftDelete"):
+		return CategoryDelete
+	case strings.HasPrefix(operation, "Find"), strings.HasPrefix(operation, "Count"), strings.HasPrefix(operation, "Exists"),
+		strings.HasPrefix(operation, "Take"), strings.HasPrefix(operation, "Last"), strings.HasPrefix(operation, "Sample"),
+		strings.HasPrefix(operation, "Estimate"), strings.HasPrefix(operation, "Percentile"), strings.HasPrefix(operation, "Histogram"),
+		strings.HasPrefix(operation, "TimeSeries"), strings.HasPrefix(operation, "FirstOrInit"), strings.HasPrefix(operation, "Query."), operation == "TableStats", operation == "Raw":
+		return CategoryRead
+	}
+	return CategoryOther
+}
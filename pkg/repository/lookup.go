@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lookupStatements caches the SELECT statements rendered by FindFirstBy per
+// column and soft delete filtering
+type lookupStatements struct {
+	mu         sync.RWMutex
+	statements map[lookupKey]string
+}
+
+// lookupKey identifies a cached lookup statement
+type lookupKey struct {
+	column         string
+	includeDeleted bool
+}
+
+// statement returns the cached statement for key, rendering it with render on a miss
+func (c *lookupStatements) statement(key lookupKey, render func() (string, error)) (string, error) {
+	c.mu.RLock()
+	sql, ok := c.statements[key]
+	c.mu.RUnlock()
+	if ok {
+		return sql, nil
+	}
+
+	sql, err := render()
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	if c.statements == nil {
+		c.statements = make(map[lookupKey]string)
+	}
+	c.statements[key] = sql
+	c.mu.Unlock()
+	return sql, nil
+}
+
+// FindFirstBy finds the first entity (by primary key) whose column equals
+// value. It is a fast path for hot single-column lookups: the statement is
+// rendered once per column and executed as a cached prepared statement,
+// skipping the clause building of FindFirstByConditions. Reads still honor
+// soft delete filtering, WithDeleted and read replica routing; follower reads
+// fall back to FindFirstByConditions.
+func (r *BaseRepository[T]) FindFirstBy(ctx context.Context, dest *T, column string, value interface{}) error {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "FindFirstBy", time.Since(start))
+	}()
+
+	id, byID := cachedLookupID(column, value, keyTypeOf[T]())
+	if byID {
+		if cached, ok := r.cacheRead(ctx, id); ok {
+			*dest = *cached
+			r.metrics.IncrementOperationsFor("FindFirstBy", true)
+			return nil
+		}
+	}
+
+	err := r.retry(ctx, "FindFirstBy", func() error {
+		return r.findFirstBy(ctx, dest, column, value)
+	})
+	if err != nil {
+		r.metrics.IncrementOperationsFor("FindFirstBy", false)
+		return err
+	}
+	if byID {
+		r.cacheLoaded(ctx, dest)
+	}
+
+	r.metrics.IncrementOperationsFor("FindFirstBy", true)
+	return nil
+}
+
+// findFirstBy runs the FindFirstBy lookup
+func (r *BaseRepository[T]) findFirstBy(ctx context.Context, dest *T, column string, value interface{}) error {
+	if _, ok := followerReadFromContext(ctx); ok {
+		if err := r.readDB(ctx).Where(map[string]interface{}{column: value}).First(dest).Error; err != nil {
+			return r.wrapError(err, "FindFirstBy", "failed to find entity by "+column)
+		}
+		return nil
+	}
+
+	key := lookupKey{column: column, includeDeleted: includeDeleted(ctx)}
+	sql, err := r.lookups.statement(key, func() (string, error) {
+		return r.renderLookup(key)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Prepared statements are cached per connection pool; routed replica
+	// connections keep their own pool (and failover) untouched
+	var query *gorm.DB
+	if r.router != nil && !isTransaction(r.db) {
+		query = r.observe(r.router.ReadDB(ctx))
+	} else {
+		query = r.db.WithContext(ctx).Session(&gorm.Session{PrepareStmt: true})
+	}
+
+	result := query.Raw(sql, value).Find(dest)
+	if result.Error != nil {
+		return r.wrapError(result.Error, "FindFirstBy", "failed to find entity by "+column)
+	}
+	if result.RowsAffected == 0 {
+		return r.wrapError(gorm.ErrRecordNotFound, "FindFirstBy", "failed to find entity by "+column)
+	}
+	return nil
+}
+
+// renderLookup renders the SELECT statement of a single-column lookup
+func (r *BaseRepository[T]) renderLookup(key lookupKey) (string, error) {
+	s, err := r.schema()
+	if err != nil {
+		return "", err
+	}
+	field := s.LookUpField(key.column)
+	if field == nil || field.DBName == "" {
+		return "", r.argumentError("FindFirstBy", fmt.Sprintf("unknown column %q on %s", key.column, s.Name))
+	}
+
+	stmt := &gorm.Statement{DB: r.db}
+	sql := fmt.Sprintf("SELECT * FROM %s WHERE %s = ?", stmt.Quote(s.Table), stmt.Quote(field.DBName))
+
+	if r.softDelete != softDeleteNone && !key.includeDeleted {
+		deletedAt := deletedAtColumn
+		if f := s.LookUpField("DeletedAt"); f != nil && f.DBName != "" {
+			deletedAt = f.DBName
+		}
+		sql += fmt.Sprintf(" AND %s IS NULL", stmt.Quote(deletedAt))
+	}
+
+	if s.PrioritizedPrimaryField != nil {
+		sql += " ORDER BY " + stmt.Quote(s.PrioritizedPrimaryField.DBName)
+	}
+	return sql + " " + r.dialect.Paginate(1, 0), nil
+}
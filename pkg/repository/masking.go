@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// RoleContextKey is the default context key of the role of the caller
+const RoleContextKey = "role"
+
+// WithRole returns a context acting as role under RoleContextKey
+func WithRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, RoleContextKey, role)
+}
+
+// MaskMode selects how a column hidden from a role is read
+type MaskMode string
+
+const (
+	// MaskOmit leaves the column out of the SELECT, so it reads as its zero value
+	MaskOmit MaskMode = "omit"
+	// MaskRedact selects a replacement in place of string columns, and NULL
+	// in place of other columns
+	MaskRedact MaskMode = "redact"
+)
+
+// ColumnRule hides a column of a table from roles
+type ColumnRule struct {
+	// Table is the table of the column
+	Table string `json:"table"`
+	// Column is the column hidden, by column or field name
+	Column string `json:"column"`
+	// Roles are the roles that cannot read the column
+	Roles []string `json:"roles"`
+	// Mode is how the column is hidden (default MaskOmit)
+	Mode MaskMode `json:"mode"`
+	// Replacement is the value read by redacted string columns (default "***")
+	Replacement string `json:"replacement"`
+}
+
+// MaskedRead describes a read of which columns were hidden from its role
+type MaskedRead struct {
+	Table   string
+	Role    string
+	Columns []string
+	// DB is the connection the read ran on: the transaction when the read is
+	// part of one, so records persisted by the hook commit or roll back with it
+	DB *gorm.DB
+}
+
+// MaskingConfig configures a ColumnMasker
+type MaskingConfig struct {
+	// ContextKey is the context key of the role (default RoleContextKey)
+	ContextKey interface{} `json:"-"`
+	// Rules are the columns hidden from roles
+	Rules []ColumnRule `json:"rules"`
+	// OnMasked is called after each read whose columns were hidden, e.g. to
+	// audit it (see audit.Auditor.RecordMaskedRead). An error fails the read.
+	OnMasked func(ctx context.Context, read *MaskedRead) error `json:"-"`
+}
+
+// ColumnMasker is a gorm plugin hiding columns from the roles that cannot
+// read them: queries of a model made with a role in their context select the
+// columns of the model except the hidden ones, which are omitted or redacted
+// as their rule says, whether the query selects every column or names them.
+//
+// Only the columns of the queried model are masked. Raw SQL, expressions
+// naming a hidden column, queries with their own SELECT clause (such as
+// counts) and conditions on hidden columns are not.
+type ColumnMasker struct {
+	config *MaskingConfig
+	rules  map[string][]ColumnRule
+}
+
+// NewColumnMasker creates a column masker
+func NewColumnMasker(config *MaskingConfig) *ColumnMasker {
+	if config == nil {
+		config = &MaskingConfig{}
+	}
+	if config.ContextKey == nil {
+		config.ContextKey = RoleContextKey
+	}
+
+	rules := make(map[string][]ColumnRule)
+	for _, rule := range config.Rules {
+		if rule.Mode == "" {
+			rule.Mode = MaskOmit
+		}
+		if rule.Replacement == "" {
+			rule.Replacement = "***"
+		}
+		rules[rule.Table] = append(rules[rule.Table], rule)
+	}
+	return &ColumnMasker{config: config, rules: rules}
+}
+
+// Name returns the plugin name
+func (m *ColumnMasker) Name() string {
+	return "ormx:column_masker"
+}
+
+// Initialize registers the masker callbacks around the queries of db
+func (m *ColumnMasker) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	err := stderrors.Join(
+		callbacks.Query().Before("gorm:query").Register("ormx:mask_query", m.mask),
+		callbacks.Query().After("gorm:query").Register("ormx:mask_report", m.report),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register column masker callbacks: %w", err)
+	}
+	return nil
+}
+
+// Role returns the role of ctx
+func (m *ColumnMasker) Role(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	role := ctx.Value(m.config.ContextKey)
+	if role == nil {
+		return "", false
+	}
+	name := fmt.Sprint(role)
+	return name, name != ""
+}
+
+// Hidden returns the rule hiding column of table from the role of ctx
+func (m *ColumnMasker) Hidden(ctx context.Context, table, column string) (ColumnRule, bool) {
+	role, ok := m.Role(ctx)
+	if !ok {
+		return ColumnRule{}, false
+	}
+	for _, rule := range m.rules[table] {
+		if rule.Column == column && contains(rule.Roles, role) {
+			return rule, true
+		}
+	}
+	return ColumnRule{}, false
+}
+
+// maskedKey is the statement setting of the columns hidden from a query
+const maskedKey = "ormx:masked_columns"
+
+// mask rewrites the selected columns of a query hiding columns from its role
+func (m *ColumnMasker) mask(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || len(m.rules[stmt.Schema.Table]) == 0 {
+		return
+	}
+	if _, ok := stmt.Clauses["SELECT"]; ok {
+		return
+	}
+
+	selects := stmt.Selects
+	if len(selects) == 0 || contains(selects, "*") {
+		selects = make([]string, 0, len(stmt.Schema.DBNames))
+		selects = append(selects, stmt.Schema.DBNames...)
+	}
+
+	var masked []string
+	columns := make([]string, 0, len(selects))
+	for _, selected := range selects {
+		field := selectedField(stmt.Schema, selected)
+		if field == nil {
+			columns = append(columns, selected)
+			continue
+		}
+		rule, hidden := m.Hidden(stmt.Context, stmt.Schema.Table, field.DBName)
+		if !hidden {
+			rule, hidden = m.Hidden(stmt.Context, stmt.Schema.Table, field.Name)
+		}
+		if !hidden {
+			columns = append(columns, selected)
+			continue
+		}
+
+		masked = append(masked, field.DBName)
+		if rule.Mode == MaskRedact {
+			columns = append(columns, redacted(stmt, field, rule))
+		}
+	}
+	if len(masked) == 0 {
+		return
+	}
+
+	if len(columns) == 0 {
+		// Select the primary key rather than every column
+		columns = append(columns, stmt.Schema.PrioritizedPrimaryField.DBName)
+	}
+	stmt.Selects = columns
+	db.InstanceSet(maskedKey, masked)
+}
+
+// report calls the OnMasked hook after a query whose columns were hidden
+func (m *ColumnMasker) report(db *gorm.DB) {
+	if db.Error != nil || m.config.OnMasked == nil {
+		return
+	}
+	value, ok := db.InstanceGet(maskedKey)
+	if !ok {
+		return
+	}
+	role, _ := m.Role(db.Statement.Context)
+	read := &MaskedRead{
+		Table:   db.Statement.Schema.Table,
+		Role:    role,
+		Columns: value.([]string),
+		DB:      db.Session(&gorm.Session{NewDB: true}),
+	}
+	if err := m.config.OnMasked(db.Statement.Context, read); err != nil {
+		_ = db.AddError(fmt.Errorf("failed to report masked read of %s: %w", read.Table, err))
+	}
+}
+
+// selectedField returns the field of a selected column, nil for expressions
+func selectedField(s *schema.Schema, selected string) *schema.Field {
+	name := strings.TrimSpace(selected)
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Trim(name, "`\"[]")
+	if strings.ContainsAny(name, " ()") {
+		return nil
+	}
+	field := s.LookUpField(name)
+	if field == nil || field.DBName == "" {
+		return nil
+	}
+	return field
+}
+
+// redacted returns the selected expression replacing a redacted column
+func redacted(stmt *gorm.Statement, field *schema.Field, rule ColumnRule) string {
+	value := "NULL"
+	if field.DataType == schema.String {
+		value = "'" + strings.ReplaceAll(rule.Replacement, "'", "''") + "'"
+	}
+	return value + " AS " + stmt.Quote(field.DBName)
+}
@@ -0,0 +1,1882 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// MemoryRepository is a map-backed Repository[T] for the unit tests of
+// services, which then need neither a database nor SQLite:
+//
+//	users := repository.NewMemoryRepository[User](nil)
+//	service := NewSignupService(users)
+//
+// Entities are keyed by their ID field, assigned on create like
+// models.BaseModel, BaseModelULID and auto-incremented integer keys do, and
+// listed in ID order. Creation and update times
+// are set, unique columns and unique indexes are enforced, and soft deletes
+// follow the DeletedAt field and the DeleteMode of the configuration as in
+// BaseRepository. Conditions may be typed conditions (Eq, In, And, ...), maps,
+// structs and SQL comparing columns with parameters or literals joined by AND
+// and OR ("score >= ? AND status IN ?"); other conditions fail with a
+// validation error. LIKE is case-sensitive.
+//
+// Entities are copied by value: pointers, slices and maps they hold are
+// shared with the caller. Transactions work on a snapshot of the entities,
+// applied when they succeed without checking for concurrent writes, and
+// support savepoints and transaction hooks. Begin, Commit and Rollback,
+// which expose a gorm connection, TimeSeriesCount, PercentileBy and
+// HistogramBy fail with an error matching errors.ErrUnsupported; find
+// options, gorm hooks, validation and entity validators are ignored.
+type MemoryRepository[T any] struct {
+	state      *memoryState[T]
+	schema     *schema.Schema
+	matcher    memoryMatcher
+	config     *RepositoryConfig
+	softDelete softDeleteKind
+}
+
+// memoryState holds the entities seen by a repository: the committed ones,
+// or the snapshot of a transaction
+type memoryState[T any] struct {
+	mu   sync.Mutex
+	rows map[models.EntityID]T
+	// dirty records the entities written by a transaction, nil outside
+	dirty         map[models.EntityID]bool
+	savepoints    map[string]memorySavepoint[T]
+	afterCommit   []TxHook
+	afterRollback []TxHook
+}
+
+// memorySavepoint is the state of a transaction at a savepoint
+type memorySavepoint[T any] struct {
+	rows  map[models.EntityID]T
+	dirty map[models.EntityID]bool
+}
+
+// memorySchemas caches the schemas parsed by memory repositories
+var memorySchemas sync.Map
+
+// NewMemoryRepository creates an empty in-memory repository. Only the
+// limits and the delete mode of config are used; nil uses the defaults.
+func NewMemoryRepository[T any](config *RepositoryConfig) *MemoryRepository[T] {
+	if config == nil {
+		config = DefaultRepositoryConfig()
+	}
+
+	s, err := schema.Parse(new(T), &memorySchemas, schema.NamingStrategy{})
+	if err != nil {
+		panic(fmt.Sprintf("repository: cannot parse entity %T: %v", *new(T), err))
+	}
+
+	return &MemoryRepository[T]{
+		state:      &memoryState[T]{rows: make(map[models.EntityID]T)},
+		schema:     s,
+		matcher:    memoryMatcher{schema: s},
+		config:     config,
+		softDelete: detectSoftDelete(s.ModelType),
+	}
+}
+
+// FindFirstByID finds entity by ID
+func (r *MemoryRepository[T]) FindFirstByID(ctx context.Context, id models.EntityID, opts ...FindOption) (*T, error) {
+	id = normalizeID(id, keyTypeOf[T]())
+	entity, ok := r.byID(ctx, id)
+	if !ok {
+		return nil, r.wrapError(gorm.ErrRecordNotFound, "FindFirstByID", "failed to find entity by ID")
+	}
+	return &entity, nil
+}
+
+// FindFirstByKey finds the entity with key, the values of its primary key
+// columns
+func (r *MemoryRepository[T]) FindFirstByKey(ctx context.Context, key map[string]interface{}, opts ...FindOption) (*T, error) {
+	cond, err := r.keyCond("FindFirstByKey", key)
+	if err != nil {
+		return nil, err
+	}
+	var entity T
+	if err := r.first(ctx, "FindFirstByKey", "failed to find entity by key", &entity, []interface{}{cond}, false); err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+// FindAllByIDs finds the entities with ids, keyed by ID
+func (r *MemoryRepository[T]) FindAllByIDs(ctx context.Context, ids []models.EntityID, opts ...FindOption) (map[models.EntityID]*T, error) {
+	found := make(map[models.EntityID]*T, len(ids))
+	keyType := keyTypeOf[T]()
+	for _, id := range ids {
+		id = normalizeID(id, keyType)
+		if entity, ok := r.byID(ctx, id); ok {
+			found[id] = &entity
+		}
+	}
+	return found, nil
+}
+
+// FindFirstByConditions finds the first entity by conditions, in ID order
+func (r *MemoryRepository[T]) FindFirstByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
+	return r.first(ctx, "FindFirstByConditions", "failed to find entity by conditions", dest, conds, false)
+}
+
+// FindFirstBy finds the first entity whose column equals value
+func (r *MemoryRepository[T]) FindFirstBy(ctx context.Context, dest *T, column string, value interface{}) error {
+	if column == "" {
+		return r.argumentError("FindFirstBy", "column cannot be empty")
+	}
+	return r.first(ctx, "FindFirstBy", fmt.Sprintf("failed to find entity by %s", column), dest, []interface{}{Eq(column, value)}, false)
+}
+
+// FirstOrInitByConditions finds the first entity by conditions, or
+// initializes dest with the values of map, struct and equality conditions
+func (r *MemoryRepository[T]) FirstOrInitByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
+	err := r.first(ctx, "FirstOrInitByConditions", "failed to find entity by conditions", dest, conds, false)
+	if !errors.IsNotFound(err) {
+		return err
+	}
+	var zero T
+	*dest = zero
+	if err := r.assign(dest, r.condValues(conds)); err != nil {
+		return r.wrapError(err, "FirstOrInitByConditions", "failed to initialize entity")
+	}
+	return nil
+}
+
+// FindAllWithOffset finds a page of entities
+func (r *MemoryRepository[T]) FindAllWithOffset(ctx context.Context, limit int, offset int, dest *[]T, opts ...FindOption) error {
+	return r.FindAllByConditionsWithOffset(ctx, limit, offset, dest)
+}
+
+// FindAllInBatchesWithOffset finds a page of entities and passes them to fc
+// in batches; fc receives a nil connection
+func (r *MemoryRepository[T]) FindAllInBatchesWithOffset(ctx context.Context, limit int, offset int, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, opts ...FindOption) error {
+	return r.FindAllInBatchesByConditionsWithOffset(ctx, limit, offset, dest, batchSize, fc)
+}
+
+// FindAllByConditionsWithOffset finds a page of entities matching conditions
+func (r *MemoryRepository[T]) FindAllByConditionsWithOffset(ctx context.Context, limit int, offset int, dest *[]T, conds ...interface{}) error {
+	entities, err := r.find(ctx, conds, false)
+	if err != nil {
+		return r.wrapError(err, "FindAllByConditionsWithOffset", "failed to find entities by conditions")
+	}
+	*dest = r.page(entities, limit, offset)
+	return nil
+}
+
+// FindAllInBatchesByConditionsWithOffset finds a page of entities matching
+// conditions and passes them to fc in batches; fc receives a nil connection
+func (r *MemoryRepository[T]) FindAllInBatchesByConditionsWithOffset(ctx context.Context, limit int, offset int, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, conds ...interface{}) error {
+	entities, err := r.find(ctx, conds, false)
+	if err != nil {
+		return r.wrapError(err, "FindAllInBatchesByConditionsWithOffset", "failed to find entities by conditions in batches")
+	}
+	return r.batches(r.page(entities, limit, offset), dest, batchSize, fc, "FindAllInBatchesByConditionsWithOffset")
+}
+
+// FindAllWithCursor finds the entities after (next) or before (prev) the
+// cursor, an entity ID
+func (r *MemoryRepository[T]) FindAllWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, opts ...FindOption) error {
+	return r.FindAllByConditionsWithCursor(ctx, cursor, limit, direction, dest)
+}
+
+// FindAllInBatchesWithCursor finds the entities after or before the cursor
+// and passes them to fc in batches; fc receives a nil connection
+func (r *MemoryRepository[T]) FindAllInBatchesWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, opts ...FindOption) error {
+	return r.FindAllInBatchesByConditionsWithCursor(ctx, cursor, limit, direction, dest, batchSize, fc)
+}
+
+// FindAllByConditionsWithCursor finds the entities matching conditions
+// after or before the cursor
+func (r *MemoryRepository[T]) FindAllByConditionsWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, conds ...interface{}) error {
+	entities, err := r.find(ctx, conds, false)
+	if err != nil {
+		return r.wrapError(err, "FindAllByConditionsWithCursor", "failed to find entities by conditions")
+	}
+	*dest = r.cursorPage(entities, cursor, limit, direction)
+	return nil
+}
+
+// FindAllInBatchesByConditionsWithCursor finds the entities matching
+// conditions after or before the cursor and passes them to fc in batches;
+// fc receives a nil connection
+func (r *MemoryRepository[T]) FindAllInBatchesByConditionsWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, conds ...interface{}) error {
+	entities, err := r.find(ctx, conds, false)
+	if err != nil {
+		return r.wrapError(err, "FindAllInBatchesByConditionsWithCursor", "failed to find entities by conditions in batches")
+	}
+	return r.batches(r.cursorPage(entities, cursor, limit, direction), dest, batchSize, fc, "FindAllInBatchesByConditionsWithCursor")
+}
+
+// FindAllIncludingDeleted finds a page of entities, soft deleted ones included
+func (r *MemoryRepository[T]) FindAllIncludingDeleted(ctx context.Context, limit int, offset int, dest *[]T, opts ...FindOption) error {
+	return r.FindAllWithOffset(WithDeleted(ctx), limit, offset, dest)
+}
+
+// ExistsByID reports whether an entity has the ID
+func (r *MemoryRepository[T]) ExistsByID(ctx context.Context, id models.EntityID) (bool, error) {
+	id = normalizeID(id, keyTypeOf[T]())
+	_, ok := r.byID(ctx, id)
+	return ok, nil
+}
+
+// ExistsByKey reports whether an entity has key
+func (r *MemoryRepository[T]) ExistsByKey(ctx context.Context, key map[string]interface{}) (bool, error) {
+	cond, err := r.keyCond("ExistsByKey", key)
+	if err != nil {
+		return false, err
+	}
+	count, err := r.count(ctx, "ExistsByKey", []interface{}{cond})
+	return count > 0, err
+}
+
+// ExistsByConditions reports whether an entity matches the conditions
+func (r *MemoryRepository[T]) ExistsByConditions(ctx context.Context, conds ...interface{}) (bool, error) {
+	count, err := r.count(ctx, "ExistsByConditions", conds)
+	return count > 0, err
+}
+
+// CountByConditions counts the entities matching the conditions
+func (r *MemoryRepository[T]) CountByConditions(ctx context.Context, conds ...interface{}) (int64, error) {
+	return r.count(ctx, "CountByConditions", conds)
+}
+
+// PluckByConditions loads column of the entities matching the conditions
+// into dest, a pointer to a slice
+func (r *MemoryRepository[T]) PluckByConditions(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	return r.pluck(ctx, "PluckByConditions", column, dest, false, conds)
+}
+
+// DistinctPluckByConditions loads the distinct values of column of the
+// entities matching the conditions into dest, a pointer to a slice
+func (r *MemoryRepository[T]) DistinctPluckByConditions(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	return r.pluck(ctx, "DistinctPluckByConditions", column, dest, true, conds)
+}
+
+// TimeSeriesCount is not supported by the in-memory repository
+func (r *MemoryRepository[T]) TimeSeriesCount(ctx context.Context, timeColumn string, interval dialect.TimeUnit, conds ...interface{}) ([]TimeBucketCount, error) {
+	return nil, r.unsupported("TimeSeriesCount")
+}
+
+// PercentileBy is not supported by the in-memory repository
+func (r *MemoryRepository[T]) PercentileBy(ctx context.Context, column string, percentiles []float64, conds ...interface{}) ([]PercentileValue, error) {
+	return nil, r.unsupported("PercentileBy")
+}
+
+// HistogramBy is not supported by the in-memory repository
+func (r *MemoryRepository[T]) HistogramBy(ctx context.Context, column string, bounds []float64, conds ...interface{}) ([]HistogramBucket, error) {
+	return nil, r.unsupported("HistogramBy")
+}
+
+// SumByConditions sums column over the entities matching the conditions
+func (r *MemoryRepository[T]) SumByConditions(ctx context.Context, column string, conds ...interface{}) (float64, error) {
+	values, err := r.columnValues(ctx, "SumByConditions", column, conds)
+	if err != nil {
+		return 0, err
+	}
+	sum, _ := aggregateValues(AggregateSum, values)
+	total, _ := numeric(sum)
+	return total, nil
+}
+
+// AvgByConditions averages column over the entities matching the conditions
+func (r *MemoryRepository[T]) AvgByConditions(ctx context.Context, column string, conds ...interface{}) (float64, error) {
+	values, err := r.columnValues(ctx, "AvgByConditions", column, conds)
+	if err != nil {
+		return 0, err
+	}
+	avg, _ := aggregateValues(AggregateAvg, values)
+	average, _ := numeric(avg)
+	return average, nil
+}
+
+// MinBy stores the smallest value of column into dest
+func (r *MemoryRepository[T]) MinBy(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	return r.extreme(ctx, "MinBy", AggregateMin, column, dest, conds)
+}
+
+// MaxBy stores the largest value of column into dest
+func (r *MemoryRepository[T]) MaxBy(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	return r.extreme(ctx, "MaxBy", AggregateMax, column, dest, conds)
+}
+
+// GroupBy computes aggregates per group of the entities matching the
+// conditions; sums of integers are int64, other numbers float64
+func (r *MemoryRepository[T]) GroupBy(ctx context.Context, groupCols []string, aggregates []Aggregate, conds ...interface{}) ([]map[string]interface{}, error) {
+	if len(aggregates) == 0 {
+		return nil, r.argumentError("GroupBy", "at least one aggregate is required")
+	}
+	fields := make(map[string]*schema.Field)
+	for _, column := range groupCols {
+		if fields[column] = r.schema.LookUpField(column); fields[column] == nil {
+			return nil, r.argumentError("GroupBy", fmt.Sprintf("invalid group column: %q", column))
+		}
+	}
+	for _, aggregate := range aggregates {
+		if err := aggregate.validate(); err != nil {
+			return nil, r.argumentError("GroupBy", err.Error())
+		}
+		if aggregate.Column != "" {
+			if fields[aggregate.Column] = r.schema.LookUpField(aggregate.Column); fields[aggregate.Column] == nil {
+				return nil, r.argumentError("GroupBy", fmt.Sprintf("invalid column: %q", aggregate.Column))
+			}
+		}
+	}
+	entities, err := r.find(ctx, conds, false)
+	if err != nil {
+		return nil, r.wrapError(err, "GroupBy", "failed to aggregate entities by group")
+	}
+
+	value := func(entity *T, column string) interface{} {
+		v, _ := fields[column].ValueOf(context.Background(), reflect.ValueOf(entity).Elem())
+		return normalizeValue(v)
+	}
+	type group struct {
+		keys     []interface{}
+		entities []*T
+	}
+	var groups []*group
+	index := make(map[string]*group)
+	for i := range entities {
+		keys := make([]interface{}, len(groupCols))
+		for j, column := range groupCols {
+			keys[j] = value(&entities[i], column)
+		}
+		id := fmt.Sprintf("%#v", keys)
+		if index[id] == nil {
+			index[id] = &group{keys: keys}
+			groups = append(groups, index[id])
+		}
+		index[id].entities = append(index[id].entities, &entities[i])
+	}
+	if len(groupCols) == 0 && len(groups) == 0 {
+		groups = append(groups, &group{})
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		for k := range groupCols {
+			a, b := groups[i].keys[k], groups[j].keys[k]
+			if a == nil || b == nil {
+				if (a == nil) != (b == nil) {
+					return a == nil
+				}
+				continue
+			}
+			if c, _ := compareValues(a, b); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	results := make([]map[string]interface{}, len(groups))
+	for i, g := range groups {
+		row := make(map[string]interface{}, len(groupCols)+len(aggregates))
+		for j, column := range groupCols {
+			row[column] = g.keys[j]
+		}
+		for _, aggregate := range aggregates {
+			if aggregate.Column == "" {
+				row[aggregate.key()] = int64(len(g.entities))
+				continue
+			}
+			var values []interface{}
+			for _, entity := range g.entities {
+				if v := value(entity, aggregate.Column); v != nil {
+					values = append(values, v)
+				}
+			}
+			row[aggregate.key()], _ = aggregateValues(aggregate.Func, values)
+		}
+		results[i] = row
+	}
+	return results, nil
+}
+
+// SampleByConditions returns up to n entities matching the conditions,
+// chosen uniformly whatever the strategy
+func (r *MemoryRepository[T]) SampleByConditions(ctx context.Context, n int, strategy SampleStrategy, dest *[]T, conds ...interface{}) error {
+	if n <= 0 {
+		return r.argumentError("SampleByConditions", fmt.Sprintf("sample size must be greater than 0, got %d", n))
+	}
+	entities, err := r.find(ctx, conds, false)
+	if err != nil {
+		return r.wrapError(err, "SampleByConditions", "failed to sample entities")
+	}
+	rand.Shuffle(len(entities), func(i, j int) { entities[i], entities[j] = entities[j], entities[i] })
+	if len(entities) > n {
+		entities = entities[:n]
+	}
+	*dest = entities
+	return nil
+}
+
+// EstimateCount counts the entities matching the conditions exactly
+func (r *MemoryRepository[T]) EstimateCount(ctx context.Context, conds ...interface{}) (int64, error) {
+	if len(conds) == 0 {
+		ctx = WithDeleted(ctx)
+	}
+	return r.count(ctx, "EstimateCount", conds)
+}
+
+// TableStats returns the exact number of stored entities
+func (r *MemoryRepository[T]) TableStats(ctx context.Context) (*TableStats, error) {
+	count, err := r.count(WithDeleted(ctx), "TableStats", nil)
+	if err != nil {
+		return nil, err
+	}
+	return &TableStats{Table: r.schema.Table, Dialect: "memory", EstimatedRows: count, ExactRowCount: true}, nil
+}
+
+// TakeByConditions finds an entity by conditions
+func (r *MemoryRepository[T]) TakeByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
+	return r.first(ctx, "TakeByConditions", "failed to take entity by conditions", dest, conds, false)
+}
+
+// LastByConditions finds the last entity by conditions, in ID order
+func (r *MemoryRepository[T]) LastByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
+	return r.first(ctx, "LastByConditions", "failed to last entity by conditions", dest, conds, true)
+}
+
+// Create stores entity, assigning its ID and creation time when unset
+func (r *MemoryRepository[T]) Create(ctx context.Context, entity *T) error {
+	if entity == nil {
+		return r.argumentError("Create", "entity cannot be nil")
+	}
+	return r.write(func(state *memoryState[T]) error {
+		return r.insert(ctx, state, entity, "Create")
+	})
+}
+
+// CreateInBatches stores entities, all or none, or batchSize at a time with
+// a context from WithBatchProgress
+func (r *MemoryRepository[T]) CreateInBatches(ctx context.Context, entities []T, batchSize int) error {
+	if len(entities) == 0 {
+		return r.argumentError("CreateInBatches", "entities cannot be empty")
+	}
+	if batchSize <= 0 {
+		return r.argumentError("CreateInBatches", fmt.Sprintf("batch size must be greater than 0, got %d", batchSize))
+	}
+	if progress, ok := batchProgressFrom(ctx); ok {
+		return r.createInResumableBatches(ctx, entities, batchSize, progress)
+	}
+	return r.write(func(state *memoryState[T]) error {
+		staged := state.clone()
+		for i := range entities {
+			if err := r.insert(ctx, staged, &entities[i], "CreateInBatches"); err != nil {
+				return err
+			}
+		}
+		state.replace(staged)
+		return nil
+	})
+}
+
+// BulkInsertFast stores entities, all or none
+func (r *MemoryRepository[T]) BulkInsertFast(ctx context.Context, entities []T) (*IngestResult, error) {
+	if len(entities) == 0 {
+		return nil, r.argumentError("BulkInsertFast", "entities cannot be empty")
+	}
+	err := r.write(func(state *memoryState[T]) error {
+		staged := state.clone()
+		for i := range entities {
+			if err := r.insert(ctx, staged, &entities[i], "BulkInsertFast"); err != nil {
+				return err
+			}
+		}
+		state.replace(staged)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &IngestResult{Inserted: int64(len(entities))}, nil
+}
+
+// createInResumableBatches stores entities batchSize at a time, each batch
+// all or none, reporting progress after each and stopping at the first
+// failure or when the context leaves no time for the next batch
+func (r *MemoryRepository[T]) createInResumableBatches(ctx context.Context, entities []T, batchSize int, progress func(BatchProgress)) error {
+	start := time.Now()
+	for offset, batch := 0, 0; offset < len(entities); offset, batch = offset+batchSize, batch+1 {
+		end := offset + batchSize
+		if end > len(entities) {
+			end = len(entities)
+		}
+
+		err := nextBatchErr(ctx, start, batch)
+		size := len(entities) - offset
+		if err != nil {
+			err = r.wrapError(err, "CreateInBatches", fmt.Sprintf("batch %d not started", batch))
+		} else {
+			size = end - offset
+			err = r.write(func(state *memoryState[T]) error {
+				staged := state.clone()
+				for i := offset; i < end; i++ {
+					if err := r.insert(ctx, staged, &entities[i], "CreateInBatches"); err != nil {
+						return err
+					}
+				}
+				state.replace(staged)
+				return nil
+			})
+		}
+		if err != nil {
+			failures := []errors.BatchFailure{{Batch: batch, Offset: offset, Size: size, Err: err}}
+			return errors.NewBatchError("CreateInBatches", r.schema.Table, len(entities), offset, failures)
+		}
+		progress(BatchProgress{Batch: batch, Done: end, Total: len(entities), Elapsed: time.Since(start)})
+	}
+	return nil
+}
+
+// CreateInBatchesIgnoreConflicts stores entities, skipping those
+// conflicting with stored entities on conflictColumns, or on the ID and
+// unique columns when none are given
+func (r *MemoryRepository[T]) CreateInBatchesIgnoreConflicts(ctx context.Context, entities []T, batchSize int, conflictColumns ...string) (*IngestResult, error) {
+	if len(entities) == 0 {
+		return nil, r.argumentError("CreateInBatchesIgnoreConflicts", "entities cannot be empty")
+	}
+	if batchSize <= 0 {
+		return nil, r.argumentError("CreateInBatchesIgnoreConflicts", fmt.Sprintf("batch size must be greater than 0, got %d", batchSize))
+	}
+
+	ingested := &IngestResult{}
+	err := r.write(func(state *memoryState[T]) error {
+		for offset, batch := 0, 0; offset < len(entities); offset, batch = offset+batchSize, batch+1 {
+			result := BatchResult{Batch: batch}
+			for i := offset; i < offset+batchSize && i < len(entities); i++ {
+				r.prepare(state, &entities[i])
+				if _, conflict := r.conflicting(state, &entities[i], conflictColumns); conflict {
+					result.Skipped++
+					continue
+				}
+				if err := r.insert(ctx, state, &entities[i], "CreateInBatchesIgnoreConflicts"); err != nil {
+					return err
+				}
+				result.Inserted++
+			}
+			ingested.Inserted += result.Inserted
+			ingested.Skipped += result.Skipped
+			ingested.Batches = append(ingested.Batches, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return ingested, err
+	}
+	return ingested, nil
+}
+
+// FindOrCreateByConditions finds the first entity matching conditions, or
+// creates it from the values of the conditions and attrs, reporting whether
+// it was created
+func (r *MemoryRepository[T]) FindOrCreateByConditions(ctx context.Context, dest *T, attrs interface{}, conds ...interface{}) (bool, error) {
+	if dest == nil {
+		return false, r.argumentError("FindOrCreateByConditions", "destination cannot be nil")
+	}
+	if len(conds) == 0 {
+		return false, r.argumentError("FindOrCreateByConditions", "conditions are required")
+	}
+
+	created := false
+	err := r.write(func(state *memoryState[T]) error {
+		predicate, err := r.matcher.predicate(conds)
+		if err != nil {
+			return r.wrapError(err, "FindOrCreateByConditions", "failed to find entity by conditions")
+		}
+		if found := r.filter(ctx, state, predicate, false); len(found) > 0 {
+			*dest = found[0]
+			return nil
+		}
+
+		var zero T
+		*dest = zero
+		values := r.condValues(conds)
+		for column, value := range r.condValues([]interface{}{attrs}) {
+			values[column] = value
+		}
+		if err := r.assign(dest, values); err != nil {
+			return r.wrapError(err, "FindOrCreateByConditions", "failed to initialize entity")
+		}
+		if err := r.insert(ctx, state, dest, "FindOrCreateByConditions"); err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	return created, err
+}
+
+// Update stores entity, replacing the entity with its ID
+func (r *MemoryRepository[T]) Update(ctx context.Context, entity *T) error {
+	if entity == nil {
+		return r.argumentError("Update", "entity cannot be nil")
+	}
+	if isZeroID(r.id(entity)) {
+		return r.argumentError("Update", "entity must have a valid ID")
+	}
+	return r.write(func(state *memoryState[T]) error {
+		return r.save(ctx, state, entity, "Update")
+	})
+}
+
+// UpdateByID stores entity, whose ID must be id
+func (r *MemoryRepository[T]) UpdateByID(ctx context.Context, entity *T, id models.EntityID) error {
+	id = normalizeID(id, keyTypeOf[T]())
+	switch {
+	case entity == nil:
+		return r.argumentError("UpdateByID", "entity cannot be nil")
+	case isZeroID(id):
+		return r.argumentError("UpdateByID", "ID cannot be nil")
+	case isZeroID(r.id(entity)):
+		return r.argumentError("UpdateByID", "entity must have a valid ID")
+	case r.id(entity) != id:
+		return r.argumentError("UpdateByID", "entity id must match id")
+	}
+	return r.write(func(state *memoryState[T]) error {
+		return r.save(ctx, state, entity, "UpdateByID")
+	})
+}
+
+// UpdateByConditions sets the non-zero fields of entity on the entities
+// matching conditions, or stores entity without conditions
+func (r *MemoryRepository[T]) UpdateByConditions(ctx context.Context, entity *T, conds ...interface{}) error {
+	if entity == nil {
+		return r.argumentError("UpdateByConditions", "entity cannot be nil")
+	}
+	if len(conds) == 0 {
+		return r.write(func(state *memoryState[T]) error {
+			return r.save(ctx, state, entity, "UpdateByConditions")
+		})
+	}
+
+	values := r.matcher.structValues(reflect.ValueOf(entity).Elem())
+	delete(values, r.idColumn())
+	_, err := r.updateWhere(ctx, "UpdateByConditions", values, conds)
+	return err
+}
+
+// UpdateAllByConditions sets the columns of values on the entities matching
+// the conditions and returns their number
+func (r *MemoryRepository[T]) UpdateAllByConditions(ctx context.Context, values map[string]interface{}, conds ...interface{}) (int64, error) {
+	if len(values) == 0 {
+		return 0, r.argumentError("UpdateAllByConditions", "values cannot be empty")
+	}
+	if len(conds) == 0 {
+		return 0, r.argumentError("UpdateAllByConditions", "WHERE conditions required")
+	}
+	return r.updateWhere(ctx, "UpdateAllByConditions", values, conds)
+}
+
+// UpdateIf sets updates on the entity with id if its columns hold the
+// expected values, reporting whether they did
+func (r *MemoryRepository[T]) UpdateIf(ctx context.Context, id models.EntityID, expected map[string]interface{}, updates map[string]interface{}) (bool, error) {
+	id = normalizeID(id, keyTypeOf[T]())
+	if isZeroID(id) {
+		return false, r.argumentError("UpdateIf", "ID cannot be nil")
+	}
+	if len(updates) == 0 {
+		return false, r.argumentError("UpdateIf", "updates cannot be empty")
+	}
+
+	conds := []interface{}{Eq(r.idColumn(), id)}
+	if len(expected) > 0 {
+		conds = append(conds, Match(expected))
+	}
+	updated, err := r.updateWhere(ctx, "UpdateIf", updates, conds)
+	return updated > 0, err
+}
+
+// Upsert stores entity or resolves its conflict with a stored entity as
+// described by conflict. Conflict conditions (Where) are not supported.
+func (r *MemoryRepository[T]) Upsert(ctx context.Context, entity *T, conflict ConflictOptions) error {
+	if entity == nil {
+		return r.argumentError("Upsert", "entity cannot be nil")
+	}
+	return r.write(func(state *memoryState[T]) error {
+		return r.upsert(ctx, state, entity, conflict, nil, "Upsert")
+	})
+}
+
+// UpsertByID upserts entity with id as its ID
+func (r *MemoryRepository[T]) UpsertByID(ctx context.Context, entity *T, id models.EntityID, conflict ConflictOptions) error {
+	id = normalizeID(id, keyTypeOf[T]())
+	if entity == nil {
+		return r.argumentError("UpsertByID", "entity cannot be nil")
+	}
+	if isZeroID(id) {
+		return r.argumentError("UpsertByID", "ID cannot be nil")
+	}
+	if current := r.id(entity); !isZeroID(current) && current != id {
+		return r.argumentError("UpsertByID", fmt.Sprintf("entity ID %s does not match %s", current, id))
+	}
+	r.setID(entity, id)
+	return r.write(func(state *memoryState[T]) error {
+		return r.upsert(ctx, state, entity, conflict, nil, "UpsertByID")
+	})
+}
+
+// UpsertByConditions upserts entity, only updating a conflicting entity
+// matching conds
+func (r *MemoryRepository[T]) UpsertByConditions(ctx context.Context, entity *T, conflict ConflictOptions, conds ...interface{}) error {
+	if entity == nil {
+		return r.argumentError("UpsertByConditions", "entity cannot be nil")
+	}
+	return r.write(func(state *memoryState[T]) error {
+		return r.upsert(ctx, state, entity, conflict, conds, "UpsertByConditions")
+	})
+}
+
+// UpsertInBatches upserts entities batchSize at a time, each batch all or
+// none, stopping at the first failing batch unless conflict.ContinueOnError
+// is set. Failures are reported by an errors.BatchError.
+func (r *MemoryRepository[T]) UpsertInBatches(ctx context.Context, entities []T, batchSize int, conflict ConflictOptions) error {
+	return r.upsertAll(ctx, entities, batchSize, conflict, nil, "UpsertInBatches")
+}
+
+// UpsertInBatchesByConditions upserts entities, only updating conflicting
+// entities matching conds
+func (r *MemoryRepository[T]) UpsertInBatchesByConditions(ctx context.Context, entities []T, batchSize int, conflict ConflictOptions, conds ...interface{}) error {
+	return r.upsertAll(ctx, entities, batchSize, conflict, conds, "UpsertInBatchesByConditions")
+}
+
+// DeleteByID deletes the entity with id according to the delete mode
+func (r *MemoryRepository[T]) DeleteByID(ctx context.Context, id models.EntityID) error {
+	id = normalizeID(id, keyTypeOf[T]())
+	if isZeroID(id) {
+		return r.argumentError("DeleteByID", "ID cannot be nil")
+	}
+	_, err := r.removeWhere(ctx, "DeleteByID", []interface{}{Eq(r.idColumn(), id)}, r.softDeleteEnabled())
+	return err
+}
+
+// DeleteByKey deletes the entity with key according to the delete mode
+func (r *MemoryRepository[T]) DeleteByKey(ctx context.Context, key map[string]interface{}) error {
+	cond, err := r.keyCond("DeleteByKey", key)
+	if err != nil {
+		return err
+	}
+	_, err = r.removeWhere(ctx, "DeleteByKey", []interface{}{cond}, r.softDeleteEnabled())
+	return err
+}
+
+// DeleteByConditions deletes the entities matching the conditions
+// according to the delete mode
+func (r *MemoryRepository[T]) DeleteByConditions(ctx context.Context, entity *T, conds ...interface{}) error {
+	if entity == nil {
+		return r.argumentError("DeleteByConditions", "entity cannot be nil")
+	}
+	if len(conds) == 0 {
+		return r.argumentError("DeleteByConditions", "WHERE conditions required")
+	}
+	_, err := r.removeWhere(ctx, "DeleteByConditions", conds, r.softDeleteEnabled())
+	return err
+}
+
+// DeleteAllByConditions deletes the entities matching the conditions
+// according to the delete mode and returns their number
+func (r *MemoryRepository[T]) DeleteAllByConditions(ctx context.Context, conds ...interface{}) (int64, error) {
+	if len(conds) == 0 {
+		return 0, r.argumentError("DeleteAllByConditions", "WHERE conditions required")
+	}
+	return r.removeWhere(ctx, "DeleteAllByConditions", conds, r.softDeleteEnabled())
+}
+
+// DeleteInBatches deletes entities by ID according to the delete mode
+func (r *MemoryRepository[T]) DeleteInBatches(ctx context.Context, entities []T, batchSize int) error {
+	return r.DeleteInBatchesByConditions(ctx, entities, batchSize)
+}
+
+// DeleteInBatchesByConditions deletes the entities matching the conditions
+// among entities according to the delete mode
+func (r *MemoryRepository[T]) DeleteInBatchesByConditions(ctx context.Context, entities []T, batchSize int, conds ...interface{}) error {
+	operation := "DeleteInBatches"
+	if len(conds) > 0 {
+		operation = "DeleteInBatchesByConditions"
+	}
+	if batchSize <= 0 {
+		return r.argumentError(operation, fmt.Sprintf("batch size must be greater than 0, got %d", batchSize))
+	}
+	if len(entities) == 0 {
+		return r.argumentError(operation, "entities cannot be empty")
+	}
+
+	ids := make([]interface{}, 0, len(entities))
+	for i := range entities {
+		ids = append(ids, r.id(&entities[i]))
+	}
+	predicate := And(In(r.idColumn(), ids...))
+	if len(conds) > 0 {
+		if cond, ok := conds[0].(Cond); ok && len(conds) == 1 {
+			predicate = predicate.And(cond)
+		} else {
+			return r.deleteAmong(ctx, operation, ids, conds)
+		}
+	}
+	_, err := r.removeWhere(ctx, operation, []interface{}{predicate}, r.softDeleteEnabled())
+	return err
+}
+
+// SoftDeleteByID marks the entity with id as deleted
+func (r *MemoryRepository[T]) SoftDeleteByID(ctx context.Context, id models.EntityID) error {
+	id = normalizeID(id, keyTypeOf[T]())
+	if isZeroID(id) {
+		return r.argumentError("SoftDeleteByID", "ID cannot be nil")
+	}
+	if err := r.checkSoftDelete("SoftDeleteByID"); err != nil {
+		return err
+	}
+	deleted, err := r.removeWhere(ctx, "SoftDeleteByID", []interface{}{Eq(r.idColumn(), id)}, true)
+	if err == nil && deleted == 0 {
+		err = r.wrapError(gorm.ErrRecordNotFound, "SoftDeleteByID", fmt.Sprintf("failed to soft delete entity %s", id))
+	}
+	return err
+}
+
+// SoftDeleteByConditions marks the entities matching conds as deleted
+func (r *MemoryRepository[T]) SoftDeleteByConditions(ctx context.Context, conds ...interface{}) (int64, error) {
+	if len(conds) == 0 {
+		return 0, r.argumentError("SoftDeleteByConditions", "WHERE conditions required")
+	}
+	if err := r.checkSoftDelete("SoftDeleteByConditions"); err != nil {
+		return 0, err
+	}
+	return r.removeWhere(ctx, "SoftDeleteByConditions", conds, true)
+}
+
+// RestoreByID clears the deletion mark of a soft deleted entity
+func (r *MemoryRepository[T]) RestoreByID(ctx context.Context, id models.EntityID) error {
+	id = normalizeID(id, keyTypeOf[T]())
+	if isZeroID(id) {
+		return r.argumentError("RestoreByID", "ID cannot be nil")
+	}
+	if err := r.checkSoftDelete("RestoreByID"); err != nil {
+		return err
+	}
+	return r.write(func(state *memoryState[T]) error {
+		entity, ok := state.rows[id]
+		if !ok || !r.deleted(&entity) {
+			return r.wrapError(gorm.ErrRecordNotFound, "RestoreByID", fmt.Sprintf("failed to restore entity %s", id))
+		}
+		if err := r.set(&entity, deletedAtColumn, nil); err != nil {
+			return r.wrapError(err, "RestoreByID", "failed to restore entity")
+		}
+		state.put(id, entity)
+		return nil
+	})
+}
+
+// Begin is not supported by the in-memory repository, use WithTransaction
+func (r *MemoryRepository[T]) Begin(ctx context.Context) (*gorm.DB, error) {
+	return nil, r.unsupported("Begin")
+}
+
+// Commit is not supported by the in-memory repository, use WithTransaction
+func (r *MemoryRepository[T]) Commit(ctx context.Context) error {
+	return r.unsupported("Commit")
+}
+
+// Rollback is not supported by the in-memory repository, use WithTransaction
+func (r *MemoryRepository[T]) Rollback(ctx context.Context) error {
+	return r.unsupported("Rollback")
+}
+
+// SavePoint records the state of the transaction the repository is bound to
+func (r *MemoryRepository[T]) SavePoint(ctx context.Context, name string) error {
+	if name == "" {
+		return r.argumentError("SavePoint", "savepoint name cannot be empty")
+	}
+	return r.write(func(state *memoryState[T]) error {
+		if state.dirty == nil {
+			return r.newError(errors.ErrorTypeTransaction, "SavePoint", "savepoints require a transaction")
+		}
+		snapshot := state.clone()
+		state.savepoints[name] = memorySavepoint[T]{rows: snapshot.rows, dirty: snapshot.dirty}
+		return nil
+	})
+}
+
+// NewSavePoint records the state of the transaction under a generated name
+// and returns it
+func (r *MemoryRepository[T]) NewSavePoint(ctx context.Context) (string, error) {
+	name := nextSavepointName()
+	if err := r.SavePoint(ctx, name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// RollbackTo restores the state of the transaction at the savepoint
+func (r *MemoryRepository[T]) RollbackTo(ctx context.Context, name string) error {
+	return r.write(func(state *memoryState[T]) error {
+		savepoint, ok := state.savepoints[name]
+		if state.dirty == nil || !ok {
+			return r.newError(errors.ErrorTypeTransaction, "RollbackTo", fmt.Sprintf("unknown savepoint %s", name))
+		}
+		restored := (&memoryState[T]{rows: savepoint.rows, dirty: savepoint.dirty}).clone()
+		state.rows, state.dirty = restored.rows, restored.dirty
+		return nil
+	})
+}
+
+// WithTransaction runs fn on a repository bound to a snapshot of the
+// entities, applied if fn succeeds and discarded if it fails or panics, a
+// panic being returned as a transaction error
+func (r *MemoryRepository[T]) WithTransaction(ctx context.Context, fn func(Repository[T]) error) error {
+	if fn == nil {
+		return r.argumentError("WithTransaction", "transaction function cannot be nil")
+	}
+	return r.transaction(ctx, "WithTransaction", func(ctx context.Context, tx Repository[T]) error {
+		return fn(tx)
+	})
+}
+
+// WithTransactionOpts runs fn in a transaction like WithTransaction; the
+// options are ignored
+func (r *MemoryRepository[T]) WithTransactionOpts(ctx context.Context, opts TxOptions, fn func(ctx context.Context, tx Repository[T]) error) error {
+	if fn == nil {
+		return r.argumentError("WithTransactionOpts", "transaction function cannot be nil")
+	}
+	return r.transaction(ctx, "WithTransactionOpts", fn)
+}
+
+// WithTx returns the repository, which has no ambient transaction
+func (r *MemoryRepository[T]) WithTx(ctx context.Context) Repository[T] {
+	return r
+}
+
+// AfterCommit registers fn to run once the transaction the repository is
+// bound to commits; outside a transaction fn runs immediately
+func (r *MemoryRepository[T]) AfterCommit(ctx context.Context, fn TxHook) {
+	if fn == nil {
+		return
+	}
+	r.state.mu.Lock()
+	if r.state.dirty == nil {
+		r.state.mu.Unlock()
+		runTxHook(ctx, fn, true, nil)
+		return
+	}
+	r.state.afterCommit = append(r.state.afterCommit, fn)
+	r.state.mu.Unlock()
+}
+
+// AfterRollback registers fn to run if the transaction the repository is
+// bound to rolls back; outside a transaction fn never runs
+func (r *MemoryRepository[T]) AfterRollback(ctx context.Context, fn TxHook) {
+	if fn == nil {
+		return
+	}
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+	if r.state.dirty != nil {
+		r.state.afterRollback = append(r.state.afterRollback, fn)
+	}
+}
+
+// transaction runs fn on a repository bound to a copy of the state of r,
+// merging the writes of fn into r when it succeeds
+func (r *MemoryRepository[T]) transaction(ctx context.Context, operation string, fn func(ctx context.Context, tx Repository[T]) error) (err error) {
+	r.state.mu.Lock()
+	state := r.state.clone()
+	r.state.mu.Unlock()
+	state.dirty = make(map[models.EntityID]bool)
+	state.savepoints = make(map[string]memorySavepoint[T])
+
+	tx := *r
+	tx.state = state
+
+	committed := false
+	defer func() {
+		if p := recover(); p != nil {
+			err = r.newError(errors.ErrorTypeTransaction, operation, fmt.Sprintf("transaction function panicked: %v", p))
+		}
+		if committed {
+			return
+		}
+		for _, hook := range state.afterRollback {
+			runTxHook(ctx, hook, false, nil)
+		}
+	}()
+
+	if err := fn(ctx, &tx); err != nil {
+		return r.wrapError(err, operation, "failed to execute function within transaction")
+	}
+
+	r.state.mu.Lock()
+	for id := range state.dirty {
+		if entity, ok := state.rows[id]; ok {
+			r.state.put(id, entity)
+		} else {
+			r.state.remove(id)
+		}
+	}
+	nested := r.state.dirty != nil
+	if nested {
+		// Hooks of a nested transaction wait for the outermost one
+		r.state.afterCommit = append(r.state.afterCommit, state.afterCommit...)
+		r.state.afterRollback = append(r.state.afterRollback, state.afterRollback...)
+	}
+	r.state.mu.Unlock()
+
+	committed = true
+	if !nested {
+		for _, hook := range state.afterCommit {
+			runTxHook(ctx, hook, true, nil)
+		}
+	}
+	return nil
+}
+
+// write runs fn with the state of the repository locked
+func (r *MemoryRepository[T]) write(fn func(state *memoryState[T]) error) error {
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+	return fn(r.state)
+}
+
+// byID returns the visible entity with id
+func (r *MemoryRepository[T]) byID(ctx context.Context, id models.EntityID) (T, bool) {
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+	entity, ok := r.state.rows[id]
+	if !ok || !r.visible(ctx, &entity) {
+		var zero T
+		return zero, false
+	}
+	return entity, true
+}
+
+// find returns the visible entities matching conds in ID order, or in
+// reverse order
+func (r *MemoryRepository[T]) find(ctx context.Context, conds []interface{}, reverse bool) ([]T, error) {
+	conds, _ = splitFindOptions(conds)
+	predicate, err := r.matcher.predicate(conds)
+	if err != nil {
+		return nil, err
+	}
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+	return r.filter(ctx, r.state, predicate, reverse), nil
+}
+
+// filter returns the visible entities of state matching predicate in ID
+// order, or in reverse order
+func (r *MemoryRepository[T]) filter(ctx context.Context, state *memoryState[T], predicate memoryPredicate, reverse bool) []T {
+	ids := state.ids()
+	if reverse {
+		for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+			ids[i], ids[j] = ids[j], ids[i]
+		}
+	}
+
+	var entities []T
+	for _, id := range ids {
+		entity := state.rows[id]
+		if r.visible(ctx, &entity) && predicate(reflect.ValueOf(&entity).Elem()) {
+			entities = append(entities, entity)
+		}
+	}
+	return entities
+}
+
+// first sets dest to the first visible entity matching conds
+func (r *MemoryRepository[T]) first(ctx context.Context, operation, message string, dest *T, conds []interface{}, last bool) error {
+	if dest == nil {
+		return r.argumentError(operation, "destination cannot be nil")
+	}
+	entities, err := r.find(ctx, conds, last)
+	if err == nil && len(entities) == 0 {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		return r.wrapError(err, operation, message)
+	}
+	*dest = entities[0]
+	return nil
+}
+
+// count counts the visible entities matching conds
+func (r *MemoryRepository[T]) count(ctx context.Context, operation string, conds []interface{}) (int64, error) {
+	entities, err := r.find(ctx, conds, false)
+	if err != nil {
+		return 0, r.wrapError(err, operation, "failed to count entities")
+	}
+	return int64(len(entities)), nil
+}
+
+// page returns the page of entities at offset, limit normalized like BaseRepository does
+func (r *MemoryRepository[T]) page(entities []T, limit, offset int) []T {
+	if limit < 1 {
+		limit = r.config.DefaultLimit
+	}
+	if limit > r.config.MaxLimit {
+		limit = r.config.MaxLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(entities) {
+		return []T{}
+	}
+	end := min(offset+limit, len(entities))
+	return entities[offset:end]
+}
+
+// cursorPage returns the entities whose ID follows (next) or precedes
+// (prev) the cursor
+func (r *MemoryRepository[T]) cursorPage(entities []T, cursor string, limit int, direction string) []T {
+	if cursor != "" {
+		after := normalizeID(cursor, keyTypeOf[T]())
+		kept := entities[:0:0]
+		for i := range entities {
+			order := compareIDs(r.id(&entities[i]), after)
+			if (direction == "prev" && order < 0) || (direction != "prev" && order > 0) {
+				kept = append(kept, entities[i])
+			}
+		}
+		entities = kept
+	}
+	return r.page(entities, limit, 0)
+}
+
+// batches passes entities to fc batchSize at a time through dest
+func (r *MemoryRepository[T]) batches(entities []T, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, operation string) error {
+	if batchSize <= 0 {
+		return r.argumentError(operation, fmt.Sprintf("batch size must be greater than 0, got %d", batchSize))
+	}
+	for offset, batch := 0, 1; offset < len(entities); offset, batch = offset+batchSize, batch+1 {
+		*dest = entities[offset:min(offset+batchSize, len(entities))]
+		if fc == nil {
+			continue
+		}
+		if err := fc(nil, batch); err != nil {
+			return r.wrapError(err, operation, fmt.Sprintf("failed to process batch %d", batch))
+		}
+	}
+	return nil
+}
+
+// columnValues returns the non-NULL values of column of the entities
+// matching conds
+func (r *MemoryRepository[T]) columnValues(ctx context.Context, operation, column string, conds []interface{}) ([]interface{}, error) {
+	field := r.schema.LookUpField(column)
+	if field == nil {
+		return nil, r.argumentError(operation, fmt.Sprintf("invalid column: %q", column))
+	}
+	entities, err := r.find(ctx, conds, false)
+	if err != nil {
+		return nil, r.wrapError(err, operation, fmt.Sprintf("failed to aggregate %s", column))
+	}
+	var values []interface{}
+	for i := range entities {
+		v, _ := field.ValueOf(context.Background(), reflect.ValueOf(&entities[i]).Elem())
+		if v := normalizeValue(v); v != nil {
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+// pluck loads column into dest, the distinct values only if distinct is set
+func (r *MemoryRepository[T]) pluck(ctx context.Context, operation, column string, dest interface{}, distinct bool, conds []interface{}) error {
+	target := reflect.ValueOf(dest)
+	if target.Kind() != reflect.Ptr || target.IsNil() || target.Elem().Kind() != reflect.Slice {
+		return r.argumentError(operation, fmt.Sprintf("destination must be a pointer to a slice, got %T", dest))
+	}
+	field := r.schema.LookUpField(column)
+	if field == nil {
+		return r.argumentError(operation, fmt.Sprintf("invalid column: %q", column))
+	}
+	entities, err := r.find(ctx, conds, false)
+	if err != nil {
+		return r.wrapError(err, operation, fmt.Sprintf("failed to pluck %s", column))
+	}
+
+	values := reflect.MakeSlice(target.Elem().Type(), 0, len(entities))
+	elem := values.Type().Elem()
+	seen := make(map[string]bool)
+	for i := range entities {
+		raw, _ := field.ValueOf(context.Background(), reflect.ValueOf(&entities[i]).Elem())
+		normalized := normalizeValue(raw)
+		if distinct {
+			key := fmt.Sprintf("%#v", normalized)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+
+		value := reflect.New(elem)
+		if normalized != nil {
+			if elem.Kind() == reflect.Ptr {
+				value.Elem().Set(reflect.New(elem.Elem()))
+				err = assignAggregate(normalized, value.Elem().Interface())
+			} else {
+				err = assignAggregate(normalized, value.Interface())
+			}
+			if err != nil {
+				return r.wrapError(err, operation, fmt.Sprintf("failed to pluck %s", column))
+			}
+		}
+		values = reflect.Append(values, value.Elem())
+	}
+	if r.config.MaxLimit > 0 && values.Len() > r.config.MaxLimit {
+		return r.newError(errors.ErrorTypeResource, operation,
+			fmt.Sprintf("more than %d values of %s match, narrow the conditions", r.config.MaxLimit, column))
+	}
+	if size := resultSize(values); r.config.MaxResultSize > 0 && size > r.config.MaxResultSize {
+		return r.newError(errors.ErrorTypeResource, operation,
+			fmt.Sprintf("values of %s take %d bytes, more than the maximum result size of %d", column, size, r.config.MaxResultSize))
+	}
+	target.Elem().Set(values)
+	return nil
+}
+
+// extreme stores the smallest or largest value of column into dest
+func (r *MemoryRepository[T]) extreme(ctx context.Context, operation string, fn AggregateFunc, column string, dest interface{}, conds []interface{}) error {
+	if dest == nil || reflect.ValueOf(dest).Kind() != reflect.Ptr || reflect.ValueOf(dest).IsNil() {
+		return r.argumentError(operation, "destination must be a non-nil pointer")
+	}
+	values, err := r.columnValues(ctx, operation, column, conds)
+	if err != nil {
+		return err
+	}
+	value, err := aggregateValues(fn, values)
+	if err == nil && value == nil {
+		err = gorm.ErrRecordNotFound
+	}
+	if err == nil {
+		err = assignAggregate(value, dest)
+	}
+	if err != nil {
+		return r.wrapError(err, operation, fmt.Sprintf("failed to compute %s of %s", strings.ToLower(string(fn)), column))
+	}
+	return nil
+}
+
+// aggregateValues computes fn over non-NULL normalized values, nil for no
+// values except counts
+func aggregateValues(fn AggregateFunc, values []interface{}) (interface{}, error) {
+	if fn == AggregateCount {
+		return int64(len(values)), nil
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	switch fn {
+	case AggregateSum, AggregateAvg:
+		var sum float64
+		var integer int64
+		integers := true
+		for _, v := range values {
+			n, ok := numeric(v)
+			if !ok {
+				return nil, fmt.Errorf("cannot %s %T values", strings.ToLower(string(fn)), v)
+			}
+			i, isInt := v.(int64)
+			integers = integers && isInt
+			sum += n
+			integer += i
+		}
+		if fn == AggregateAvg {
+			return sum / float64(len(values)), nil
+		}
+		if integers {
+			return integer, nil
+		}
+		return sum, nil
+	}
+
+	extreme := values[0]
+	for _, v := range values[1:] {
+		c, ok := compareValues(v, extreme)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare %T and %T values", v, extreme)
+		}
+		if (fn == AggregateMin && c < 0) || (fn == AggregateMax && c > 0) {
+			extreme = v
+		}
+	}
+	return extreme, nil
+}
+
+// insert stores a new entity, assigning its ID and timestamps
+func (r *MemoryRepository[T]) insert(ctx context.Context, state *memoryState[T], entity *T, operation string) error {
+	r.prepare(state, entity)
+	if _, conflict := r.conflicting(state, entity, nil); conflict {
+		return r.wrapError(gorm.ErrDuplicatedKey, operation, "failed to create entity")
+	}
+	state.put(r.id(entity), *entity)
+	return nil
+}
+
+// prepare assigns the ID and the creation and update times of a new entity
+// when unset
+func (r *MemoryRepository[T]) prepare(state *memoryState[T], entity *T) {
+	if isZeroID(r.id(entity)) {
+		r.setID(entity, r.nextID(state))
+	}
+	now := time.Now()
+	v := reflect.ValueOf(entity).Elem()
+	for _, field := range r.schema.Fields {
+		if field.AutoCreateTime == 0 && field.AutoUpdateTime == 0 {
+			continue
+		}
+		if _, zero := field.ValueOf(context.Background(), v); zero {
+			_ = field.Set(context.Background(), v, now)
+		}
+	}
+}
+
+// save stores entity, replacing the entity with its ID, whose creation
+// time it keeps when entity has none
+func (r *MemoryRepository[T]) save(ctx context.Context, state *memoryState[T], entity *T, operation string) error {
+	id := r.id(entity)
+	stored, exists := state.rows[id]
+	if !exists {
+		return r.insert(ctx, state, entity, operation)
+	}
+
+	v := reflect.ValueOf(entity).Elem()
+	now := time.Now()
+	for _, field := range r.schema.Fields {
+		switch {
+		case field.AutoUpdateTime != 0:
+			_ = field.Set(context.Background(), v, now)
+		case field.AutoCreateTime != 0:
+			if _, zero := field.ValueOf(context.Background(), v); zero {
+				created, _ := field.ValueOf(context.Background(), reflect.ValueOf(&stored).Elem())
+				_ = field.Set(context.Background(), v, created)
+			}
+		}
+	}
+	if other, conflict := r.conflicting(state, entity, nil); conflict && other != id {
+		return r.wrapError(gorm.ErrDuplicatedKey, operation, "failed to update entity")
+	}
+	state.put(id, *entity)
+	return nil
+}
+
+// updateWhere sets values on the visible entities matching conds and
+// returns their number
+func (r *MemoryRepository[T]) updateWhere(ctx context.Context, operation string, values map[string]interface{}, conds []interface{}) (int64, error) {
+	predicate, err := r.matcher.predicate(conds)
+	if err != nil {
+		return 0, r.wrapError(err, operation, "failed to update entities by conditions")
+	}
+
+	var updated int64
+	err = r.write(func(state *memoryState[T]) error {
+		staged := state.clone()
+		for _, entity := range r.filter(ctx, staged, predicate, false) {
+			if err := r.assign(&entity, values); err != nil {
+				return r.wrapError(err, operation, "failed to update entities by conditions")
+			}
+			r.touch(&entity, values)
+			id := r.id(&entity)
+			if other, conflict := r.conflicting(staged, &entity, nil); conflict && other != id {
+				return r.wrapError(gorm.ErrDuplicatedKey, operation, "failed to update entities by conditions")
+			}
+			staged.put(id, entity)
+			updated++
+		}
+		state.replace(staged)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
+
+// touch sets the update time of an entity updated with values, unless they set it
+func (r *MemoryRepository[T]) touch(entity *T, values map[string]interface{}) {
+	v := reflect.ValueOf(entity).Elem()
+	for _, field := range r.schema.Fields {
+		if field.AutoUpdateTime == 0 {
+			continue
+		}
+		if _, ok := values[field.DBName]; !ok {
+			_ = field.Set(context.Background(), v, time.Now())
+		}
+	}
+}
+
+// upsert stores entity or resolves its conflict with a stored entity
+func (r *MemoryRepository[T]) upsert(ctx context.Context, state *memoryState[T], entity *T, conflict ConflictOptions, conds []interface{}, operation string) error {
+	if conflict.Where != "" {
+		return r.unsupported(operation + " with a conflict condition")
+	}
+	r.prepare(state, entity)
+	existingID, exists := r.conflicting(state, entity, conflict.Columns)
+	if !exists {
+		return r.insert(ctx, state, entity, operation)
+	}
+	if conflict.DoNothing {
+		return nil
+	}
+
+	existing := state.rows[existingID]
+	if len(conds) > 0 {
+		predicate, err := r.matcher.predicate(conds)
+		if err != nil {
+			return r.wrapError(err, operation, "failed to upsert entity")
+		}
+		if !predicate(reflect.ValueOf(&existing).Elem()) {
+			return nil
+		}
+	}
+
+	columns := conflict.DoUpdates
+	if len(columns) == 0 {
+		for _, field := range r.schema.Fields {
+			if field.DBName != "" && !field.PrimaryKey && field.AutoCreateTime == 0 {
+				columns = append(columns, field.DBName)
+			}
+		}
+	}
+	source := reflect.ValueOf(entity).Elem()
+	values := make(map[string]interface{}, len(columns))
+	for _, column := range columns {
+		field := r.schema.LookUpField(column)
+		if field == nil {
+			return r.argumentError(operation, fmt.Sprintf("unknown column %s", column))
+		}
+		values[field.DBName], _ = field.ValueOf(context.Background(), source)
+	}
+	if err := r.assign(&existing, values); err != nil {
+		return r.wrapError(err, operation, "failed to upsert entity")
+	}
+	r.touch(&existing, values)
+	state.put(existingID, existing)
+	r.setID(entity, existingID)
+	return nil
+}
+
+// upsertAll upserts entities batchSize at a time, staging each batch so it
+// is stored all or none
+func (r *MemoryRepository[T]) upsertAll(ctx context.Context, entities []T, batchSize int, conflict ConflictOptions, conds []interface{}, operation string) error {
+	if batchSize <= 0 {
+		batchSize = r.config.CreateBatchSize
+	}
+	if batchSize <= 0 {
+		return r.argumentError(operation, fmt.Sprintf("batch size must be greater than 0, got %d", batchSize))
+	}
+	if len(entities) == 0 {
+		return r.argumentError(operation, "entities cannot be empty")
+	}
+	return r.write(func(state *memoryState[T]) error {
+		var failures []errors.BatchFailure
+		succeeded := 0
+		for offset, batch := 0, 0; offset < len(entities); offset, batch = offset+batchSize, batch+1 {
+			end := offset + batchSize
+			if end > len(entities) {
+				end = len(entities)
+			}
+
+			staged := state.clone()
+			var err error
+			for i := offset; i < end && err == nil; i++ {
+				err = r.upsert(ctx, staged, &entities[i], conflict, conds, operation)
+			}
+			if err != nil {
+				failures = append(failures, errors.BatchFailure{Batch: batch, Offset: offset, Size: end - offset, Err: err})
+				if !conflict.ContinueOnError {
+					break
+				}
+				continue
+			}
+			state.replace(staged)
+			succeeded += end - offset
+		}
+		if len(failures) > 0 {
+			return errors.NewBatchError(operation, r.schema.Table, len(entities), succeeded, failures)
+		}
+		return nil
+	})
+}
+
+// removeWhere soft deletes the visible entities matching conds, or removes
+// all the entities matching them, and returns their number
+func (r *MemoryRepository[T]) removeWhere(ctx context.Context, operation string, conds []interface{}, soft bool) (int64, error) {
+	predicate, err := r.matcher.predicate(conds)
+	if err != nil {
+		return 0, r.wrapError(err, operation, "failed to delete entities")
+	}
+	if !soft {
+		ctx = WithDeleted(ctx)
+	}
+
+	var removed int64
+	err = r.write(func(state *memoryState[T]) error {
+		for _, entity := range r.filter(ctx, state, predicate, false) {
+			id := r.id(&entity)
+			if soft {
+				if r.deleted(&entity) {
+					continue
+				}
+				if err := r.set(&entity, deletedAtColumn, time.Now()); err != nil {
+					return r.wrapError(err, operation, "failed to soft delete entities")
+				}
+				state.put(id, entity)
+			} else {
+				state.remove(id)
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// deleteAmong deletes the entities with ids matching conds that are not a
+// single composable condition
+func (r *MemoryRepository[T]) deleteAmong(ctx context.Context, operation string, ids []interface{}, conds []interface{}) error {
+	predicate, err := r.matcher.predicate(conds)
+	if err != nil {
+		return r.wrapError(err, operation, "failed to delete entities")
+	}
+	selected := make(map[models.EntityID]bool, len(ids))
+	for _, id := range ids {
+		selected[id] = true
+	}
+
+	var matched []interface{}
+	r.state.mu.Lock()
+	for _, entity := range r.filter(WithDeleted(ctx), r.state, predicate, false) {
+		if id := r.id(&entity); selected[id] {
+			matched = append(matched, id)
+		}
+	}
+	r.state.mu.Unlock()
+	if len(matched) == 0 {
+		return nil
+	}
+	_, err = r.removeWhere(ctx, operation, []interface{}{In(r.idColumn(), matched...)}, r.softDeleteEnabled())
+	return err
+}
+
+// conflicting returns the ID of the stored entity other than entity
+// conflicting with it on columns, or on its ID, unique columns and unique
+// indexes when columns is empty
+func (r *MemoryRepository[T]) conflicting(state *memoryState[T], entity *T, columns []string) (models.EntityID, bool) {
+	id := r.id(entity)
+	keys := [][]string{columns}
+	if len(columns) == 0 {
+		if _, ok := state.rows[id]; ok {
+			return id, true
+		}
+		keys = r.uniqueKeys()
+	}
+
+	v := reflect.ValueOf(entity).Elem()
+	for _, key := range keys {
+		values := make(map[string]interface{}, len(key))
+		for _, column := range key {
+			field := r.schema.LookUpField(column)
+			if field == nil {
+				continue
+			}
+			value, _ := field.ValueOf(context.Background(), v)
+			if normalizeValue(value) == nil {
+				// NULLs never conflict
+				values = nil
+				break
+			}
+			values[field.DBName] = value
+		}
+		if len(values) == 0 {
+			continue
+		}
+		predicate, err := r.matcher.values(values)
+		if err != nil {
+			continue
+		}
+		for otherID, other := range state.rows {
+			if (otherID != id || len(columns) > 0) && predicate(reflect.ValueOf(&other).Elem()) {
+				return otherID, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// uniqueKeys returns the columns of the unique fields and unique indexes
+func (r *MemoryRepository[T]) uniqueKeys() [][]string {
+	var keys [][]string
+	for _, field := range r.schema.Fields {
+		if field.Unique && !field.PrimaryKey {
+			keys = append(keys, []string{field.DBName})
+		}
+	}
+	for _, index := range r.schema.ParseIndexes() {
+		if index.Class != "UNIQUE" {
+			continue
+		}
+		key := make([]string, 0, len(index.Fields))
+		for _, option := range index.Fields {
+			key = append(key, option.DBName)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// condValues returns the values dest is initialized with from conds: the
+// columns of map and struct conditions, and the equalities of composable
+// conditions joined by AND
+func (r *MemoryRepository[T]) condValues(conds []interface{}) map[string]interface{} {
+	values := make(map[string]interface{})
+	if len(conds) == 0 || conds[0] == nil {
+		return values
+	}
+
+	var collect func(c Cond)
+	collect = func(c Cond) {
+		switch c.op {
+		case "AND":
+			for _, cond := range c.conds {
+				collect(cond)
+			}
+		case "":
+			if eq, ok := c.expr.(clause.Eq); ok && !isList(eq.Value) {
+				values[columnName(eq.Column)] = eq.Value
+			}
+		}
+	}
+
+	switch c := conds[0].(type) {
+	case map[string]interface{}:
+		for column, value := range c {
+			if !isList(value) {
+				values[column] = value
+			}
+		}
+	case Cond:
+		for _, cond := range conds {
+			if cond, ok := cond.(Cond); ok {
+				collect(cond)
+			}
+		}
+	default:
+		if v := reflect.Indirect(reflect.ValueOf(c)); v.IsValid() && v.Type() == r.schema.ModelType {
+			values = r.matcher.structValues(v)
+		}
+	}
+	return values
+}
+
+// assign sets the columns of values on entity
+func (r *MemoryRepository[T]) assign(entity *T, values map[string]interface{}) error {
+	for column, value := range values {
+		if err := r.set(entity, column, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// set sets column on entity, converting value to the field type
+func (r *MemoryRepository[T]) set(entity *T, column string, value interface{}) error {
+	field := r.schema.LookUpField(column)
+	if field == nil {
+		return newError(errors.ErrorTypeValidation, r.schema.Table, "Set", fmt.Sprintf("unknown column %s", column))
+	}
+	if _, ok := value.(clause.Expression); ok {
+		return r.unsupported(fmt.Sprintf("SQL expression value of %s", column))
+	}
+	if err := field.Set(context.Background(), reflect.ValueOf(entity).Elem(), value); err != nil {
+		return fmt.Errorf("failed to set %s: %w", column, err)
+	}
+	return nil
+}
+
+// visible reports whether entity is seen by reads with ctx
+func (r *MemoryRepository[T]) visible(ctx context.Context, entity *T) bool {
+	return r.softDelete == softDeleteNone || includeDeleted(ctx) || !r.deleted(entity)
+}
+
+// deleted reports whether entity is soft deleted
+func (r *MemoryRepository[T]) deleted(entity *T) bool {
+	field := r.schema.LookUpField(deletedAtColumn)
+	if field == nil {
+		return false
+	}
+	value, _ := field.ValueOf(context.Background(), reflect.ValueOf(entity).Elem())
+	return normalizeValue(value) != nil
+}
+
+// softDeleteEnabled reports whether Delete* methods soft delete entities
+func (r *MemoryRepository[T]) softDeleteEnabled() bool {
+	switch r.config.DeleteMode {
+	case DeleteModeSoft:
+		return r.softDelete != softDeleteNone
+	case DeleteModeHard:
+		return false
+	}
+	return r.softDelete == softDeleteGorm
+}
+
+// checkSoftDelete returns the error of operation when the entity does not
+// support soft delete
+func (r *MemoryRepository[T]) checkSoftDelete(operation string) error {
+	if r.softDelete == softDeleteNone {
+		return r.argumentError(operation, fmt.Sprintf("entity %s does not support soft delete", r.schema.Name))
+	}
+	return nil
+}
+
+// id returns the ID of entity, or the text of the primary key of entities
+// keyed by several columns or by a column other than ID
+func (r *MemoryRepository[T]) id(entity *T) models.EntityID {
+	keys := r.schema.PrimaryFields
+	if len(keys) == 0 || (len(keys) == 1 && keys[0].Name == "ID") {
+		return entityIDOf(entity)
+	}
+
+	v := reflect.ValueOf(entity).Elem()
+	parts := make([]string, len(keys))
+	for i, field := range keys {
+		value, _ := field.ValueOf(context.Background(), v)
+		parts[i] = fmt.Sprint(normalizeValue(value))
+	}
+	return strings.Join(parts, "\x1f")
+}
+
+// keyCond returns the condition matching the entity with key, or the
+// argument error of operation
+func (r *MemoryRepository[T]) keyCond(operation string, key map[string]interface{}) (Cond, error) {
+	cond, err := keyCond(r.schema, key)
+	if err != nil {
+		return Cond{}, r.argumentError(operation, err.Error())
+	}
+	return cond, nil
+}
+
+// setID sets the ID of entity
+func (r *MemoryRepository[T]) setID(entity *T, id models.EntityID) {
+	setEntityIDOf(entity, id)
+}
+
+// nextID returns the ID of a new entity: a UUIDv7 or a ULID, or the
+// integer following the largest stored one
+func (r *MemoryRepository[T]) nextID(state *memoryState[T]) models.EntityID {
+	keyType := keyTypeOf[T]()
+	switch {
+	case keyType == uuidKeyType:
+		return utils.GenerateUUIDv7()
+	case keyType == ulidKeyType:
+		return models.NewULID()
+	case keyType != nil && isIntegerKind(keyType.Kind()):
+		next := reflect.New(keyType).Elem()
+		for id := range state.rows {
+			if compareIDs(id, next.Interface()) > 0 {
+				next.Set(reflect.ValueOf(id))
+			}
+		}
+		if next.CanInt() {
+			next.SetInt(next.Int() + 1)
+		} else {
+			next.SetUint(next.Uint() + 1)
+		}
+		return next.Interface()
+	}
+	return nil
+}
+
+// idColumn returns the ID column
+func (r *MemoryRepository[T]) idColumn() string {
+	if field := r.schema.LookUpField("ID"); field != nil {
+		return field.DBName
+	}
+	return "id"
+}
+
+// wrapError wraps err, the failure of operation (see operationError)
+func (r *MemoryRepository[T]) wrapError(err error, operation, message string) error {
+	return operationError(err, r.schema.Table, operation, message)
+}
+
+// newError returns an error of errorType reporting the failure of operation
+func (r *MemoryRepository[T]) newError(errorType errors.ErrorType, operation, message string) error {
+	return newError(errorType, r.schema.Table, operation, message)
+}
+
+// argumentError returns the error of an invalid argument of operation
+func (r *MemoryRepository[T]) argumentError(operation, message string) error {
+	return newError(errors.ErrorTypeValidation, r.schema.Table, operation, message)
+}
+
+// unsupported returns the error of an operation the in-memory repository
+// does not support, matching errors.ErrUnsupported
+func (r *MemoryRepository[T]) unsupported(operation string) error {
+	return errors.Wrap(stderrors.ErrUnsupported, errors.ErrorTypeValidation, "not supported by the in-memory repository").
+		WithOperation(operation).WithTable(r.schema.Table)
+}
+
+// clone returns a copy of the entities and writes of the state
+func (s *memoryState[T]) clone() *memoryState[T] {
+	clone := &memoryState[T]{rows: make(map[models.EntityID]T, len(s.rows))}
+	for id, entity := range s.rows {
+		clone.rows[id] = entity
+	}
+	if s.dirty != nil {
+		clone.dirty = make(map[models.EntityID]bool, len(s.dirty))
+		for id := range s.dirty {
+			clone.dirty[id] = true
+		}
+	}
+	clone.savepoints = s.savepoints
+	if clone.savepoints == nil {
+		clone.savepoints = make(map[string]memorySavepoint[T])
+	}
+	return clone
+}
+
+// replace takes the entities and writes of a staged clone
+func (s *memoryState[T]) replace(staged *memoryState[T]) {
+	s.rows, s.dirty = staged.rows, staged.dirty
+}
+
+// put stores entity, recording the write in a transaction
+func (s *memoryState[T]) put(id models.EntityID, entity T) {
+	s.rows[id] = entity
+	if s.dirty != nil {
+		s.dirty[id] = true
+	}
+}
+
+// remove removes the entity with id, recording the write in a transaction
+func (s *memoryState[T]) remove(id models.EntityID) {
+	delete(s.rows, id)
+	if s.dirty != nil {
+		s.dirty[id] = true
+	}
+}
+
+// ids returns the IDs of the stored entities in order
+func (s *memoryState[T]) ids() []models.EntityID {
+	ids := make([]models.EntityID, 0, len(s.rows))
+	for id := range s.rows {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return compareIDs(ids[i], ids[j]) < 0 })
+	return ids
+}
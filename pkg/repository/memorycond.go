@@ -0,0 +1,681 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// memoryPredicate reports whether an entity, a struct value, matches a condition
+type memoryPredicate func(entity reflect.Value) bool
+
+// matchAll matches every entity
+func matchAll(reflect.Value) bool { return true }
+
+// memoryMatcher evaluates the conditions of MemoryRepository against
+// entities: typed conditions and their combinations, map and struct
+// conditions, and SQL comparing columns with parameters or literals
+// ("score >= ?", "name IN ?", "deleted_at IS NULL") joined by AND and OR
+type memoryMatcher struct {
+	schema *schema.Schema
+}
+
+// predicate compiles conds, in the form accepted by the finders of
+// BaseRepository, into a predicate
+func (m memoryMatcher) predicate(conds []interface{}) (memoryPredicate, error) {
+	if len(conds) == 0 {
+		return matchAll, nil
+	}
+
+	switch c := conds[0].(type) {
+	case string:
+		return m.sql(c, conds[1:])
+	case map[string]interface{}:
+		if len(conds) > 1 {
+			return nil, m.unsupported(fmt.Sprintf("%d arguments after a map condition", len(conds)-1))
+		}
+		return m.values(c)
+	case clause.Expression:
+		predicates := make([]memoryPredicate, 0, len(conds))
+		for _, cond := range conds {
+			expr, ok := cond.(clause.Expression)
+			if !ok {
+				return nil, m.unsupported(fmt.Sprintf("%T argument after a condition", cond))
+			}
+			predicate, err := m.expression(expr)
+			if err != nil {
+				return nil, err
+			}
+			predicates = append(predicates, predicate)
+		}
+		return allOf(predicates), nil
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(conds[0]))
+	if v.IsValid() && v.Type() == m.schema.ModelType {
+		if len(conds) > 1 {
+			return nil, m.unsupported(fmt.Sprintf("%d arguments after a struct condition", len(conds)-1))
+		}
+		return m.values(m.structValues(v))
+	}
+	return nil, m.unsupported(fmt.Sprintf("%T condition", conds[0]))
+}
+
+// values returns the predicate of a map condition: equality, or membership
+// for slices
+func (m memoryMatcher) values(values map[string]interface{}) (memoryPredicate, error) {
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	predicates := make([]memoryPredicate, 0, len(columns))
+	for _, column := range columns {
+		op, value := "=", values[column]
+		if isList(value) {
+			op, value = "IN", flattenValues([]interface{}{value})
+		}
+		predicate, err := m.compare(column, op, value)
+		if err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, predicate)
+	}
+	return allOf(predicates), nil
+}
+
+// structValues returns the non-zero columns of a struct condition
+func (m memoryMatcher) structValues(v reflect.Value) map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, field := range m.schema.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		if value, zero := field.ValueOf(context.Background(), v); !zero {
+			values[field.DBName] = value
+		}
+	}
+	return values
+}
+
+// expression returns the predicate of a clause expression
+func (m memoryMatcher) expression(expr clause.Expression) (memoryPredicate, error) {
+	switch e := expr.(type) {
+	case Cond:
+		return m.cond(e)
+	case clause.Eq:
+		if isList(e.Value) {
+			return m.compare(columnName(e.Column), "IN", flattenValues([]interface{}{e.Value}))
+		}
+		return m.compare(columnName(e.Column), "=", e.Value)
+	case clause.Neq:
+		return m.compare(columnName(e.Column), "<>", e.Value)
+	case clause.Gt:
+		return m.compare(columnName(e.Column), ">", e.Value)
+	case clause.Gte:
+		return m.compare(columnName(e.Column), ">=", e.Value)
+	case clause.Lt:
+		return m.compare(columnName(e.Column), "<", e.Value)
+	case clause.Lte:
+		return m.compare(columnName(e.Column), "<=", e.Value)
+	case clause.IN:
+		return m.compare(columnName(e.Column), "IN", e.Values)
+	case clause.Like:
+		return m.compare(columnName(e.Column), "LIKE", e.Value)
+	case jsonCond:
+		return m.json(e)
+	case clause.Expr:
+		return m.sql(e.SQL, e.Vars)
+	case clause.NamedExpr:
+		return m.named(e)
+	case clause.AndConditions:
+		return m.expressions(e.Exprs, allOf)
+	case clause.OrConditions:
+		return m.expressions(e.Exprs, anyOf)
+	case clause.NotConditions:
+		predicate, err := m.expressions(e.Exprs, allOf)
+		if err != nil {
+			return nil, err
+		}
+		return not(predicate), nil
+	}
+	return nil, m.unsupported(fmt.Sprintf("%T condition", expr))
+}
+
+// expressions returns the predicates of exprs joined by join
+func (m memoryMatcher) expressions(exprs []clause.Expression, join func([]memoryPredicate) memoryPredicate) (memoryPredicate, error) {
+	predicates := make([]memoryPredicate, 0, len(exprs))
+	for _, expr := range exprs {
+		predicate, err := m.expression(expr)
+		if err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, predicate)
+	}
+	return join(predicates), nil
+}
+
+// cond returns the predicate of a composable condition
+func (m memoryMatcher) cond(c Cond) (memoryPredicate, error) {
+	switch c.op {
+	case "":
+		if c.expr == nil {
+			return matchAll, nil
+		}
+		return m.expression(c.expr)
+	case "NOT":
+		predicate, err := m.cond(c.conds[0])
+		if err != nil {
+			return nil, err
+		}
+		return not(predicate), nil
+	}
+
+	predicates := make([]memoryPredicate, 0, len(c.conds))
+	for _, cond := range c.conds {
+		predicate, err := m.cond(cond)
+		if err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, predicate)
+	}
+	if c.op == "OR" {
+		return anyOf(predicates), nil
+	}
+	return allOf(predicates), nil
+}
+
+var namedParam = regexp.MustCompile(`@(\w+)`)
+
+// named returns the predicate of SQL with named parameters bound from a map
+func (m memoryMatcher) named(e clause.NamedExpr) (memoryPredicate, error) {
+	var params map[string]interface{}
+	if len(e.Vars) == 1 {
+		params, _ = e.Vars[0].(map[string]interface{})
+	}
+	if params == nil {
+		return nil, m.unsupported("named parameters not bound from a map")
+	}
+
+	var args []interface{}
+	var missing string
+	sql := namedParam.ReplaceAllStringFunc(e.SQL, func(param string) string {
+		value, ok := params[param[1:]]
+		if !ok {
+			missing = param
+		}
+		args = append(args, value)
+		return "?"
+	})
+	if missing != "" {
+		return nil, m.unsupported(fmt.Sprintf("unbound parameter %s", missing))
+	}
+	return m.sql(sql, args)
+}
+
+var (
+	sqlTerm      = regexp.MustCompile("(?i)^(NOT\\s+)?([A-Za-z_][\\w.]*|\"[^\"]+\"|`[^`]+`)\\s*(IS\\s+NOT\\s+NULL|IS\\s+NULL|NOT\\s+IN|IN|NOT\\s+LIKE|LIKE|NOT\\s+BETWEEN|BETWEEN|<=|>=|<>|!=|==|=|<|>)\\s*")
+	sqlValue     = regexp.MustCompile(`(?i)^(\?|'(?:[^']|'')*'|-?\d+(?:\.\d+)?|TRUE\b|FALSE\b|NULL\b)\s*`)
+	sqlAnd       = regexp.MustCompile(`(?i)^AND\s+`)
+	sqlConnector = regexp.MustCompile(`(?i)^(AND|OR)\s+`)
+	sqlSpaces    = regexp.MustCompile(`\s+`)
+)
+
+// sql returns the predicate of SQL comparing columns with parameters or
+// literals, AND binding tighter than OR. Parentheses, functions and
+// expressions other than columns are not supported.
+func (m memoryMatcher) sql(sql string, args []interface{}) (memoryPredicate, error) {
+	rest := strings.TrimSpace(sql)
+	if rest == "" {
+		return nil, m.unsupported("empty SQL condition")
+	}
+	next := 0
+	value := func() (interface{}, error) {
+		match := sqlValue.FindStringSubmatch(rest)
+		if match == nil {
+			return nil, m.unsupported(fmt.Sprintf("SQL condition %q", sql))
+		}
+		rest = rest[len(match[0]):]
+		literal, err := sqlLiteral(match[1], args, &next, sql)
+		if err != nil {
+			return nil, m.unsupported(err.Error())
+		}
+		return literal, nil
+	}
+
+	var groups []memoryPredicate
+	var terms []memoryPredicate
+	for {
+		match := sqlTerm.FindStringSubmatch(rest)
+		if match == nil {
+			return nil, m.unsupported(fmt.Sprintf("SQL condition %q", sql))
+		}
+		rest = rest[len(match[0]):]
+		negate := match[1] != ""
+		column := strings.Trim(match[2], "\"`")
+		op := strings.ToUpper(sqlSpaces.ReplaceAllString(match[3], " "))
+		if strings.HasPrefix(op, "NOT ") {
+			negate, op = !negate, strings.TrimPrefix(op, "NOT ")
+		}
+
+		var predicate memoryPredicate
+		var err error
+		switch op {
+		case "IS NULL":
+			predicate, err = m.compare(column, "=", nil)
+		case "IS NOT NULL":
+			predicate, err = m.compare(column, "<>", nil)
+		case "IN":
+			var values []interface{}
+			if values, err = m.sqlList(&rest, value, sql); err == nil {
+				predicate, err = m.compare(column, "IN", values)
+			}
+		case "BETWEEN":
+			var low, high interface{}
+			if low, err = value(); err != nil {
+				return nil, err
+			}
+			and := sqlAnd.FindString(rest)
+			if and == "" {
+				return nil, m.unsupported(fmt.Sprintf("SQL condition %q", sql))
+			}
+			rest = rest[len(and):]
+			if high, err = value(); err != nil {
+				return nil, err
+			}
+			var lower, upper memoryPredicate
+			if lower, err = m.compare(column, ">=", low); err == nil {
+				upper, err = m.compare(column, "<=", high)
+			}
+			predicate = allOf([]memoryPredicate{lower, upper})
+		default:
+			var operand interface{}
+			if operand, err = value(); err != nil {
+				return nil, err
+			}
+			switch op {
+			case "==":
+				op = "="
+			case "!=":
+				op = "<>"
+			}
+			if operand == nil && (op == "=" || op == "<>") {
+				// Comparisons with NULL match no row
+				predicate = func(reflect.Value) bool { return false }
+				break
+			}
+			predicate, err = m.compare(column, op, operand)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if negate {
+			predicate = not(predicate)
+		}
+		terms = append(terms, predicate)
+
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			break
+		}
+		connector := sqlConnector.FindStringSubmatch(rest)
+		if connector == nil {
+			return nil, m.unsupported(fmt.Sprintf("SQL condition %q", sql))
+		}
+		rest = rest[len(connector[0]):]
+		if strings.EqualFold(connector[1], "OR") {
+			groups = append(groups, allOf(terms))
+			terms = nil
+		}
+	}
+	groups = append(groups, allOf(terms))
+
+	if next != len(args) {
+		return nil, m.unsupported(fmt.Sprintf("SQL condition %q has %d parameters for %d placeholders", sql, len(args), next))
+	}
+	return anyOf(groups), nil
+}
+
+// sqlList reads the operand of IN: a parameter holding a slice, or a
+// parenthesized list of parameters and literals
+func (m memoryMatcher) sqlList(rest *string, value func() (interface{}, error), sql string) ([]interface{}, error) {
+	if !strings.HasPrefix(*rest, "(") {
+		operand, err := value()
+		if err != nil {
+			return nil, err
+		}
+		return flattenValues([]interface{}{operand}), nil
+	}
+
+	*rest = strings.TrimSpace((*rest)[1:])
+	var values []interface{}
+	for {
+		operand, err := value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, flattenValues([]interface{}{operand})...)
+		switch {
+		case strings.HasPrefix(*rest, ","):
+			*rest = strings.TrimSpace((*rest)[1:])
+		case strings.HasPrefix(*rest, ")"):
+			*rest = (*rest)[1:]
+			return values, nil
+		default:
+			return nil, m.unsupported(fmt.Sprintf("SQL condition %q", sql))
+		}
+	}
+}
+
+// sqlLiteral returns the value of a placeholder, consuming the next
+// argument, or of a literal
+func sqlLiteral(token string, args []interface{}, next *int, sql string) (interface{}, error) {
+	switch upper := strings.ToUpper(token); {
+	case token == "?":
+		if *next >= len(args) {
+			return nil, fmt.Errorf("SQL condition %q has %d parameters for more placeholders", sql, len(args))
+		}
+		*next++
+		return args[*next-1], nil
+	case strings.HasPrefix(token, "'"):
+		return strings.ReplaceAll(token[1:len(token)-1], "''", "'"), nil
+	case upper == "TRUE", upper == "FALSE":
+		return upper == "TRUE", nil
+	case upper == "NULL":
+		return nil, nil
+	case strings.Contains(token, "."):
+		return strconv.ParseFloat(token, 64)
+	}
+	return strconv.ParseInt(token, 10, 64)
+}
+
+// compare returns the predicate comparing column with value by op: =, <>,
+// <, <=, >, >=, IN (value a slice) or LIKE. Nil values compare as in SQL:
+// "= nil" and "<> nil" test for NULL, and NULL columns match no comparison.
+func (m memoryMatcher) compare(column, op string, value interface{}) (memoryPredicate, error) {
+	field := m.schema.LookUpField(column)
+	if field == nil {
+		return nil, errors.NewFieldValidationError(m.schema.Table, []errors.FieldError{{
+			Field:   column,
+			Rule:    "column",
+			Message: "unknown column of " + m.schema.Table,
+		}})
+	}
+	actual := func(entity reflect.Value) interface{} {
+		v, _ := field.ValueOf(context.Background(), entity)
+		return normalizeValue(v)
+	}
+
+	expected := normalizeValue(value)
+	switch op {
+	case "=", "<>":
+		if expected == nil {
+			return func(entity reflect.Value) bool { return (actual(entity) == nil) == (op == "=") }, nil
+		}
+		return func(entity reflect.Value) bool {
+			v := actual(entity)
+			if v == nil {
+				return false
+			}
+			c, ok := compareValues(v, expected)
+			return ok && (c == 0) == (op == "=")
+		}, nil
+	case "<", "<=", ">", ">=":
+		return func(entity reflect.Value) bool {
+			c, ok := compareValues(actual(entity), expected)
+			switch {
+			case !ok:
+				return false
+			case op == "<":
+				return c < 0
+			case op == "<=":
+				return c <= 0
+			case op == ">":
+				return c > 0
+			}
+			return c >= 0
+		}, nil
+	case "IN":
+		values, _ := value.([]interface{})
+		normalized := make([]interface{}, len(values))
+		for i, v := range values {
+			normalized[i] = normalizeValue(v)
+		}
+		return func(entity reflect.Value) bool {
+			v := actual(entity)
+			for _, candidate := range normalized {
+				if c, ok := compareValues(v, candidate); ok && c == 0 {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case "LIKE":
+		pattern, ok := expected.(string)
+		if !ok {
+			return nil, m.unsupported(fmt.Sprintf("LIKE pattern %T", value))
+		}
+		like := likePattern(pattern)
+		return func(entity reflect.Value) bool {
+			s, ok := actual(entity).(string)
+			return ok && like.MatchString(s)
+		}, nil
+	}
+	return nil, m.unsupported("operator " + op)
+}
+
+// unsupported returns the error of a condition MemoryRepository cannot evaluate
+func (m memoryMatcher) unsupported(what string) error {
+	return newError(errors.ErrorTypeValidation, m.schema.Table, "Conditions",
+		fmt.Sprintf("unsupported by the in-memory repository: %s", what))
+}
+
+// likePattern returns the regular expression of a LIKE pattern, matched
+// case-sensitively
+func likePattern(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// columnName returns the name of the column of a clause, without its table
+func columnName(column interface{}) string {
+	var name string
+	switch c := column.(type) {
+	case clause.Column:
+		name = c.Name
+	case string:
+		name = c
+	}
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// isList reports whether value is a slice or array of values other than bytes
+func isList(value interface{}) bool {
+	v := reflect.ValueOf(value)
+	return (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8
+}
+
+// normalizeValue reduces a column value or parameter to nil, int64, uint64,
+// float64, string, bool or time.Time so values of different Go types
+// compare as the database would compare them
+func normalizeValue(value interface{}) interface{} {
+	for i := 0; i < 4; i++ {
+		if value == nil {
+			return nil
+		}
+		v := reflect.ValueOf(value)
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil
+			}
+			value = v.Elem().Interface()
+			continue
+		}
+		if _, ok := value.(time.Time); ok {
+			return value
+		}
+		if valuer, ok := value.(driver.Valuer); ok {
+			converted, err := valuer.Value()
+			if err != nil {
+				return value
+			}
+			value = converted
+			continue
+		}
+
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return v.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return v.Uint()
+		case reflect.Float32, reflect.Float64:
+			return v.Float()
+		case reflect.String:
+			return v.String()
+		case reflect.Bool:
+			return v.Bool()
+		case reflect.Slice:
+			if v.Type().Elem().Kind() == reflect.Uint8 {
+				return string(v.Bytes())
+			}
+		}
+		return value
+	}
+	return value
+}
+
+// compareValues orders two normalized values, reporting false when they are
+// not comparable
+func compareValues(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if x, ok := numeric(a); ok {
+		if y, ok := numeric(b); ok {
+			if i, ok := a.(int64); ok {
+				if j, ok := b.(int64); ok {
+					return compareOrdered(i, j), true
+				}
+			}
+			return compareOrdered(x, y), true
+		}
+		return 0, false
+	}
+
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+		if y, ok := b.(time.Time); ok {
+			if t, err := time.Parse(time.RFC3339Nano, x); err == nil {
+				return t.Compare(y), true
+			}
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, true
+			case !x:
+				return -1, true
+			}
+			return 1, true
+		}
+	case time.Time:
+		switch y := b.(type) {
+		case time.Time:
+			return x.Compare(y), true
+		case string:
+			if t, err := time.Parse(time.RFC3339Nano, y); err == nil {
+				return x.Compare(t), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// numeric returns a normalized number as a float64
+func numeric(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// compareOrdered compares two ordered values
+func compareOrdered[V int64 | float64](a, b V) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// allOf returns the predicate matching entities matching all predicates
+func allOf(predicates []memoryPredicate) memoryPredicate {
+	if len(predicates) == 1 {
+		return predicates[0]
+	}
+	return func(entity reflect.Value) bool {
+		for _, predicate := range predicates {
+			if !predicate(entity) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// anyOf returns the predicate matching entities matching any of predicates
+func anyOf(predicates []memoryPredicate) memoryPredicate {
+	if len(predicates) == 1 {
+		return predicates[0]
+	}
+	return func(entity reflect.Value) bool {
+		for _, predicate := range predicates {
+			if predicate(entity) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// not returns the predicate matching the entities predicate does not match
+func not(predicate memoryPredicate) memoryPredicate {
+	return func(entity reflect.Value) bool { return !predicate(entity) }
+}
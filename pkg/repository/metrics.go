@@ -0,0 +1,390 @@
+package repository
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the latency histogram upper bounds used by repository metrics
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// RepositoryMetrics represents repository metrics
+type RepositoryMetrics struct {
+	TotalOperations      int64         `json:"total_operations"`
+	SuccessfulOperations int64         `json:"successful_operations"`
+	FailedOperations     int64         `json:"failed_operations"`
+	AverageQueryTime     time.Duration `json:"average_query_time"`
+	Retries              int64         `json:"retries"`
+	LastReset            time.Time     `json:"last_reset"`
+	mu                   sync.RWMutex
+
+	latency            *latencyHistogram
+	operations         map[string]*operationMetrics
+	categories         map[string]*latencyRecorder
+	tables             map[string]*latencyRecorder
+	validationFailures map[string]int64
+}
+
+// operationMetrics holds the counters of a single repository operation
+type operationMetrics struct {
+	total      int64
+	successful int64
+	failed     int64
+	retries    int64
+	latency    *latencyHistogram
+}
+
+// latencyHistogram counts observations per latency bucket, keeping a
+// latency recorder for precise percentiles
+type latencyHistogram struct {
+	counts   []int64
+	count    int64
+	sum      time.Duration
+	recorder *latencyRecorder
+}
+
+// NewRepositoryMetrics creates new repository metrics
+func NewRepositoryMetrics() *RepositoryMetrics {
+	return &RepositoryMetrics{
+		LastReset:          time.Now(),
+		latency:            newLatencyHistogram(),
+		operations:         make(map[string]*operationMetrics),
+		categories:         make(map[string]*latencyRecorder),
+		tables:             make(map[string]*latencyRecorder),
+		validationFailures: make(map[string]int64),
+	}
+}
+
+// IncrementOperations increments operation counters
+func (rm *RepositoryMetrics) IncrementOperations(success bool) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	rm.incrementOperations(success)
+}
+
+// IncrementOperationsFor increments operation counters, also attributing the result to operation
+func (rm *RepositoryMetrics) IncrementOperationsFor(operation string, success bool) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	rm.incrementOperations(success)
+
+	op := rm.operation(operation)
+	op.total++
+	if success {
+		op.successful++
+	} else {
+		op.failed++
+	}
+}
+
+// RecordQueryTime records query execution time
+func (rm *RepositoryMetrics) RecordQueryTime(duration time.Duration) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	rm.recordQueryTime(duration)
+}
+
+// RecordQueryTimeFor records query execution time, also attributing it to operation
+func (rm *RepositoryMetrics) RecordQueryTimeFor(operation string, duration time.Duration) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	rm.recordQueryTime(duration)
+	rm.operation(operation).latency.observe(duration)
+	rm.recorder(&rm.categories, operationCategory(operation)).observe(duration)
+}
+
+// RecordTableQueryTime records query execution time, also attributing it to
+// operation and to table. Repositories sharing metrics (see
+// BaseRepository.WithMetrics) are broken down by table.
+func (rm *RepositoryMetrics) RecordTableQueryTime(table, operation string, duration time.Duration) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	rm.recordQueryTime(duration)
+	rm.operation(operation).latency.observe(duration)
+	rm.recorder(&rm.categories, operationCategory(operation)).observe(duration)
+	rm.recorder(&rm.tables, table).observe(duration)
+}
+
+// RecordValidationFailure counts a validation failure of field on rule
+func (rm *RepositoryMetrics) RecordValidationFailure(field, rule string) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if rm.validationFailures == nil {
+		rm.validationFailures = make(map[string]int64)
+	}
+	rm.validationFailures[field+":"+rule]++
+}
+
+// RecordRetry counts a retry of operation after a transient error
+func (rm *RepositoryMetrics) RecordRetry(operation string) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	rm.Retries++
+	rm.operation(operation).retries++
+}
+
+// Reset resets all metrics
+func (rm *RepositoryMetrics) Reset() {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	rm.TotalOperations = 0
+	rm.SuccessfulOperations = 0
+	rm.FailedOperations = 0
+	rm.AverageQueryTime = 0
+	rm.Retries = 0
+	rm.LastReset = time.Now()
+	rm.latency = newLatencyHistogram()
+	rm.operations = make(map[string]*operationMetrics)
+	rm.categories = make(map[string]*latencyRecorder)
+	rm.tables = make(map[string]*latencyRecorder)
+	rm.validationFailures = make(map[string]int64)
+}
+
+// GetSuccessRate returns operation success rate
+func (rm *RepositoryMetrics) GetSuccessRate() float64 {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	return successRate(rm.SuccessfulOperations, rm.TotalOperations)
+}
+
+// MetricsSnapshot represents a point-in-time copy of repository metrics
+type MetricsSnapshot struct {
+	TotalOperations      int64                        `json:"total_operations"`
+	SuccessfulOperations int64                        `json:"successful_operations"`
+	FailedOperations     int64                        `json:"failed_operations"`
+	SuccessRate          float64                      `json:"success_rate"`
+	AverageQueryTime     time.Duration                `json:"average_query_time"`
+	Retries              int64                        `json:"retries"`
+	Latency              LatencyHistogram             `json:"latency"`
+	Percentiles          LatencySummary               `json:"percentiles"`
+	Operations           map[string]OperationSnapshot `json:"operations"`
+	Categories           map[string]LatencySummary    `json:"categories"`
+	Tables               map[string]LatencySummary    `json:"tables"`
+	ValidationFailures   map[string]int64             `json:"validation_failures"`
+	LastReset            time.Time                    `json:"last_reset"`
+	Timestamp            time.Time                    `json:"timestamp"`
+}
+
+// OperationSnapshot represents the metrics of a single repository operation
+type OperationSnapshot struct {
+	TotalOperations      int64            `json:"total_operations"`
+	SuccessfulOperations int64            `json:"successful_operations"`
+	FailedOperations     int64            `json:"failed_operations"`
+	SuccessRate          float64          `json:"success_rate"`
+	Retries              int64            `json:"retries"`
+	Latency              LatencyHistogram `json:"latency"`
+	Percentiles          LatencySummary   `json:"percentiles"`
+}
+
+// LatencyHistogram represents a latency distribution in the Prometheus
+// histogram shape: bucket counts are cumulative and Count is the +Inf bucket
+type LatencyHistogram struct {
+	Buckets []LatencyBucket `json:"buckets"`
+	Count   int64           `json:"count"`
+	Sum     time.Duration   `json:"sum"`
+}
+
+// LatencyBucket represents the number of observations at or below UpperBound
+type LatencyBucket struct {
+	UpperBound time.Duration `json:"upper_bound"`
+	Count      int64         `json:"count"`
+}
+
+// Mean returns the mean observed latency
+func (h LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile estimates the q-quantile (0..1) by linear interpolation within the
+// bucket holding it. Observations above the last bound report the last bound.
+func (h LatencyHistogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 || len(h.Buckets) == 0 {
+		return 0
+	}
+
+	rank := q * float64(h.Count)
+	var lowerBound time.Duration
+	var lowerCount int64
+	for _, bucket := range h.Buckets {
+		if float64(bucket.Count) >= rank {
+			inBucket := bucket.Count - lowerCount
+			if inBucket == 0 {
+				return bucket.UpperBound
+			}
+			fraction := (rank - float64(lowerCount)) / float64(inBucket)
+			return lowerBound + time.Duration(fraction*float64(bucket.UpperBound-lowerBound))
+		}
+		lowerBound = bucket.UpperBound
+		lowerCount = bucket.Count
+	}
+	return h.Buckets[len(h.Buckets)-1].UpperBound
+}
+
+// Snapshot returns a consistent copy of the collected metrics
+func (rm *RepositoryMetrics) Snapshot() MetricsSnapshot {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	snapshot := MetricsSnapshot{
+		TotalOperations:      rm.TotalOperations,
+		SuccessfulOperations: rm.SuccessfulOperations,
+		FailedOperations:     rm.FailedOperations,
+		SuccessRate:          successRate(rm.SuccessfulOperations, rm.TotalOperations),
+		AverageQueryTime:     rm.AverageQueryTime,
+		Retries:              rm.Retries,
+		Latency:              rm.latency.snapshot(),
+		Percentiles:          rm.latency.summary(),
+		Operations:           make(map[string]OperationSnapshot, len(rm.operations)),
+		Categories:           make(map[string]LatencySummary, len(rm.categories)),
+		Tables:               make(map[string]LatencySummary, len(rm.tables)),
+		ValidationFailures:   make(map[string]int64, len(rm.validationFailures)),
+		LastReset:            rm.LastReset,
+		Timestamp:            time.Now(),
+	}
+
+	for name, op := range rm.operations {
+		snapshot.Operations[name] = OperationSnapshot{
+			TotalOperations:      op.total,
+			SuccessfulOperations: op.successful,
+			FailedOperations:     op.failed,
+			SuccessRate:          successRate(op.successful, op.total),
+			Retries:              op.retries,
+			Latency:              op.latency.snapshot(),
+			Percentiles:          op.latency.summary(),
+		}
+	}
+	for category, recorder := range rm.categories {
+		snapshot.Categories[category] = recorder.summary()
+	}
+	for table, recorder := range rm.tables {
+		snapshot.Tables[table] = recorder.summary()
+	}
+
+	for key, count := range rm.validationFailures {
+		snapshot.ValidationFailures[key] = count
+	}
+
+	return snapshot
+}
+
+// incrementOperations updates the aggregate counters; callers hold the lock
+func (rm *RepositoryMetrics) incrementOperations(success bool) {
+	rm.TotalOperations++
+	if success {
+		rm.SuccessfulOperations++
+	} else {
+		rm.FailedOperations++
+	}
+}
+
+// recordQueryTime updates the aggregate latency; callers hold the lock
+func (rm *RepositoryMetrics) recordQueryTime(duration time.Duration) {
+	if rm.latency == nil {
+		rm.latency = newLatencyHistogram()
+	}
+	rm.latency.observe(duration)
+	rm.AverageQueryTime = rm.latency.sum / time.Duration(rm.latency.count)
+}
+
+// recorder returns the latency recorder of name in recorders, creating it if
+// needed; callers hold the lock
+func (rm *RepositoryMetrics) recorder(recorders *map[string]*latencyRecorder, name string) *latencyRecorder {
+	if *recorders == nil {
+		*recorders = make(map[string]*latencyRecorder)
+	}
+	recorder, ok := (*recorders)[name]
+	if !ok {
+		recorder = newLatencyRecorder()
+		(*recorders)[name] = recorder
+	}
+	return recorder
+}
+
+// operation returns the counters of an operation, creating them if needed; callers hold the lock
+func (rm *RepositoryMetrics) operation(name string) *operationMetrics {
+	if rm.operations == nil {
+		rm.operations = make(map[string]*operationMetrics)
+	}
+
+	op, ok := rm.operations[name]
+	if !ok {
+		op = &operationMetrics{latency: newLatencyHistogram()}
+		rm.operations[name] = op
+	}
+	return op
+}
+
+// newLatencyHistogram creates a histogram over DefaultLatencyBuckets
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{counts: make([]int64, len(DefaultLatencyBuckets)), recorder: newLatencyRecorder()}
+}
+
+// observe records a latency observation
+func (h *latencyHistogram) observe(duration time.Duration) {
+	h.count++
+	h.sum += duration
+	h.recorder.observe(duration)
+	for i, bound := range DefaultLatencyBuckets {
+		if duration <= bound {
+			h.counts[i]++
+			return
+		}
+	}
+}
+
+// snapshot returns the histogram with cumulative bucket counts
+func (h *latencyHistogram) snapshot() LatencyHistogram {
+	if h == nil {
+		return LatencyHistogram{}
+	}
+
+	buckets := make([]LatencyBucket, len(DefaultLatencyBuckets))
+	var cumulative int64
+	for i, bound := range DefaultLatencyBuckets {
+		cumulative += h.counts[i]
+		buckets[i] = LatencyBucket{UpperBound: bound, Count: cumulative}
+	}
+
+	return LatencyHistogram{Buckets: buckets, Count: h.count, Sum: h.sum}
+}
+
+// summary returns the percentiles of the observations
+func (h *latencyHistogram) summary() LatencySummary {
+	if h == nil {
+		return LatencySummary{}
+	}
+	return h.recorder.summary()
+}
+
+// successRate returns successful/total, or 0 when nothing was recorded
+func successRate(successful, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(successful) / float64(total)
+}
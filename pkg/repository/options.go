@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FindOption configures how a finder loads entities, such as the associations
+// to eager load. Options are trailing arguments of FindFirstByID and of the
+// FindAll* finders without conditions, and may be mixed into the conditions
+// of the condition-based finders:
+//
+//	user, err := repo.FindFirstByID(ctx, id, repository.WithPreload("Orders"))
+//
+//	err = repo.FindAllByConditionsWithOffset(ctx, 20, 0, &users, "active = ?", true,
+//		repository.WithJoins("Company"),
+//		repository.WithPreload("Orders", "status = ?", "open"))
+type FindOption func(*gorm.DB) *gorm.DB
+
+// WithPreload eager loads association with a separate query, optionally
+// filtered by conds. Nested associations are separated by dots ("Orders.Items").
+func WithPreload(association string, conds ...interface{}) FindOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Preload(association, conds...)
+	}
+}
+
+// WithJoins joins the entities with query: a belongs-to or has-one
+// association name loads the association in the same statement, any other
+// query is added as a JOIN clause
+func WithJoins(query string, args ...interface{}) FindOption {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Joins(query, args...)
+	}
+}
+
+// WithSelect restricts the loaded columns. Preloaded associations need the
+// columns they are keyed by to be selected.
+func WithSelect(query interface{}, args ...interface{}) FindOption {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Select(query, args...)
+	}
+}
+
+// orderSetting is the gorm setting carrying the orders of WithOrderBy to findDB
+const orderSetting = "ormx:order_by"
+
+// orderTermRegex matches a term of an order specification: a column and an
+// optional direction
+var orderTermRegex = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)(?:\s+([A-Za-z]+))?$`)
+
+// orderTerm is a column of an order specification
+type orderTerm struct {
+	column string
+	desc   bool
+}
+
+// WithOrderBy sorts the results by spec, comma-separated columns each
+// optionally followed by asc or desc:
+//
+//	err := repo.FindAllWithOffset(ctx, 20, 40, &users, repository.WithOrderBy("name asc, created_at desc"))
+//
+// Columns must be columns of the entity, and among
+// RepositoryConfig.SortableColumns when set, so spec may come from a request
+// parameter. The primary key breaks ties, keeping offset pages stable. The
+// cursor and batch finders, which page by primary key, and LastByConditions
+// reject it.
+func WithOrderBy(spec string) FindOption {
+	terms, err := parseOrder(spec)
+	return func(db *gorm.DB) *gorm.DB {
+		if err != nil {
+			_ = db.AddError(err)
+			return db
+		}
+		previous, _ := db.Get(orderSetting)
+		existing, _ := previous.([]orderTerm)
+		return db.Set(orderSetting, append(append([]orderTerm(nil), existing...), terms...))
+	}
+}
+
+// parseOrder parses an order specification
+func parseOrder(spec string) ([]orderTerm, error) {
+	var terms []orderTerm
+	for _, part := range strings.Split(spec, ",") {
+		match := orderTermRegex.FindStringSubmatch(strings.TrimSpace(part))
+		if match == nil {
+			return nil, errors.New(errors.ErrorTypeValidation, fmt.Sprintf("invalid order term %q", strings.TrimSpace(part))).
+				WithOperation("WithOrderBy")
+		}
+		term := orderTerm{column: match[1]}
+		switch strings.ToLower(match[2]) {
+		case "", "asc":
+		case "desc":
+			term.desc = true
+		default:
+			return nil, errors.New(errors.ErrorTypeValidation, fmt.Sprintf("invalid order direction %q, use asc or desc", match[2])).
+				WithOperation("WithOrderBy")
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+// order sorts query by terms checked against the sortable columns
+func (r *BaseRepository[T]) order(query *gorm.DB, terms []orderTerm) *gorm.DB {
+	s, err := r.schema()
+	if err != nil {
+		_ = query.AddError(err)
+		return query
+	}
+
+	columns := make([]clause.OrderByColumn, 0, len(terms)+1)
+	sorted := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		field := s.LookUpField(term.column)
+		if field == nil || field.DBName == "" {
+			_ = query.AddError(r.argumentError("WithOrderBy", fmt.Sprintf("unknown column %q on %s", term.column, s.Name)))
+			return query
+		}
+		if !r.sortable(field.DBName) {
+			_ = query.AddError(r.argumentError("WithOrderBy", fmt.Sprintf("column %q is not sortable", term.column)))
+			return query
+		}
+		if sorted[field.DBName] {
+			continue
+		}
+		sorted[field.DBName] = true
+		columns = append(columns, clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Desc: term.desc})
+	}
+	if primary := r.primaryKeyColumn(); !sorted[primary] {
+		columns = append(columns, clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: primary}})
+	}
+	return query.Order(clause.OrderBy{Columns: columns})
+}
+
+// sortable reports whether WithOrderBy may sort by column
+func (r *BaseRepository[T]) sortable(column string) bool {
+	if len(r.config.SortableColumns) == 0 {
+		return true
+	}
+	for _, allowed := range r.config.SortableColumns {
+		if allowed == column {
+			return true
+		}
+	}
+	return false
+}
+
+// splitFindOptions separates the find options mixed into conds from the conditions
+func splitFindOptions(conds []interface{}) ([]interface{}, []FindOption) {
+	var opts []FindOption
+	filtered := conds[:0:0]
+	for _, cond := range conds {
+		if opt, ok := cond.(FindOption); ok {
+			opts = append(opts, opt)
+			continue
+		}
+		filtered = append(filtered, cond)
+	}
+	if opts == nil {
+		return conds, nil
+	}
+	return filtered, opts
+}
+
+// findDB returns the read query of a finder configured by opts
+func (r *BaseRepository[T]) findDB(ctx context.Context, opts []FindOption) *gorm.DB {
+	query := r.readDB(ctx)
+	for _, opt := range opts {
+		if opt != nil {
+			query = opt(query)
+		}
+	}
+	if terms, ok := query.Get(orderSetting); ok {
+		query = r.order(query, terms.([]orderTerm))
+	}
+	return query
+}
+
+// findUnorderedDB returns the read query of a finder that pages by primary
+// key, refusing the orders of WithOrderBy
+func (r *BaseRepository[T]) findUnorderedDB(ctx context.Context, opts []FindOption, operation string) *gorm.DB {
+	query := r.findDB(ctx, opts)
+	if _, ok := query.Get(orderSetting); ok {
+		_ = query.AddError(r.argumentError(operation, "WithOrderBy is not supported, results are ordered by primary key"))
+	}
+	return query
+}
+
+// idColumn is the primary key column qualified by the entity table, so it
+// stays unambiguous when associations are joined
+var idColumn = clause.Column{Table: clause.CurrentTable, Name: "id"}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+)
+
+// PluckByConditions loads a single column of the entities matching conds
+// into dest, a pointer to a slice such as *[]string, without loading the
+// entities:
+//
+//	var emails []string
+//	err := repo.PluckByConditions(ctx, "email", &emails, "active = ?", true)
+//
+// Results larger than MaxLimit values or MaxResultSize bytes fail with a
+// resource error instead of being truncated.
+func (r *BaseRepository[T]) PluckByConditions(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	return r.pluck(ctx, "PluckByConditions", column, dest, false, conds)
+}
+
+// DistinctPluckByConditions loads the distinct values of a column of the
+// entities matching conds into dest, within the limits of PluckByConditions
+func (r *BaseRepository[T]) DistinctPluckByConditions(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	return r.pluck(ctx, "DistinctPluckByConditions", column, dest, true, conds)
+}
+
+// pluck loads column into dest, the distinct values only if distinct is set
+func (r *BaseRepository[T]) pluck(ctx context.Context, operation, column string, dest interface{}, distinct bool, conds []interface{}) error {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, operation, time.Since(start))
+	}()
+
+	if !columnNameRegex.MatchString(column) {
+		r.metrics.IncrementOperationsFor(operation, false)
+		return r.argumentError(operation, fmt.Sprintf("invalid column: %q", column))
+	}
+	target := reflect.ValueOf(dest)
+	if target.Kind() != reflect.Ptr || target.IsNil() || target.Elem().Kind() != reflect.Slice {
+		r.metrics.IncrementOperationsFor(operation, false)
+		return r.argumentError(operation, fmt.Sprintf("destination must be a pointer to a slice, got %T", dest))
+	}
+
+	err := r.retry(ctx, operation, func() error {
+		query := r.readDB(ctx).Model(new(T))
+		if distinct {
+			query = query.Distinct()
+		}
+		if len(conds) > 0 {
+			query = query.Where(conds[0], conds[1:]...)
+		}
+		if r.config.MaxLimit > 0 {
+			// One more value tells an exceeded limit from a result at the limit
+			query = query.Limit(r.config.MaxLimit + 1)
+		}
+		return query.Pluck(column, dest).Error
+	})
+	if err != nil {
+		r.metrics.IncrementOperationsFor(operation, false)
+		return r.wrapError(err, operation, fmt.Sprintf("failed to pluck %s", column))
+	}
+
+	values := target.Elem()
+	if r.config.MaxLimit > 0 && values.Len() > r.config.MaxLimit {
+		values.Set(values.Slice(0, 0))
+		r.metrics.IncrementOperationsFor(operation, false)
+		return r.newError(errors.ErrorTypeResource, operation,
+			fmt.Sprintf("more than %d values of %s match, narrow the conditions", r.config.MaxLimit, column))
+	}
+	if size := resultSize(values); r.config.MaxResultSize > 0 && size > r.config.MaxResultSize {
+		values.Set(values.Slice(0, 0))
+		r.metrics.IncrementOperationsFor(operation, false)
+		return r.newError(errors.ErrorTypeResource, operation,
+			fmt.Sprintf("values of %s take %d bytes, more than the maximum result size of %d", column, size, r.config.MaxResultSize))
+	}
+
+	r.metrics.IncrementOperationsFor(operation, true)
+	return nil
+}
+
+// resultSize approximates the bytes taken by the values of a slice: the
+// length of strings and byte slices, the size of other values
+func resultSize(values reflect.Value) int {
+	size := 0
+	for i := 0; i < values.Len(); i++ {
+		value := reflect.Indirect(values.Index(i))
+		switch {
+		case !value.IsValid():
+		case value.Kind() == reflect.String:
+			size += value.Len()
+		case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Uint8:
+			size += value.Len()
+		default:
+			size += int(value.Type().Size())
+		}
+	}
+	return size
+}
@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Propagation selects how WithTransactionOpts relates to a transaction
+// already in progress
+type Propagation string
+
+const (
+	// PropagationRequired joins the transaction in progress, or begins one
+	PropagationRequired Propagation = "required"
+	// PropagationRequiresNew always begins an independent transaction on
+	// another connection, committed or rolled back on its own
+	PropagationRequiresNew Propagation = "requires_new"
+	// PropagationNested runs in a savepoint of the transaction in progress,
+	// rolled back alone on failure, or begins a transaction
+	PropagationNested Propagation = "nested"
+)
+
+// TxOptions represents the options of WithTransactionOpts
+type TxOptions struct {
+	// Propagation relates the transaction to the one in progress (default PropagationRequired)
+	Propagation Propagation `json:"propagation"`
+	// Isolation is the isolation level of a new transaction
+	Isolation sql.IsolationLevel `json:"isolation"`
+	// ReadOnly begins a new transaction read-only where the driver supports it
+	ReadOnly bool `json:"read_only"`
+}
+
+// txScope is a transaction with the callbacks and references collected by
+// its repositories
+type txScope struct {
+	db         *gorm.DB
+	hooks      *txHooks
+	references *deferredReferences
+}
+
+// txScopeKey is the context key of the transaction in progress
+type txScopeKey struct{}
+
+// contextWithTxScope returns a context carrying the transaction in progress
+func contextWithTxScope(ctx context.Context, scope *txScope) context.Context {
+	return context.WithValue(ctx, txScopeKey{}, scope)
+}
+
+// TxFromContext returns the transaction in progress carried by ctx, the
+// context passed to the function of WithTransactionOpts
+func TxFromContext(ctx context.Context) (*gorm.DB, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	scope, ok := ctx.Value(txScopeKey{}).(*txScope)
+	if !ok {
+		return nil, false
+	}
+	return scope.db, true
+}
+
+// scope returns the transaction the repository is bound to, if any
+func (r *BaseRepository[T]) scope() *txScope {
+	if !isTransaction(r.db) {
+		return nil
+	}
+	return &txScope{db: r.db, hooks: r.hooks, references: r.references}
+}
+
+// ambient returns the transaction in progress for the repository: the one
+// it is bound to, else the one carried by ctx
+func (r *BaseRepository[T]) ambient(ctx context.Context) *txScope {
+	if scope := r.scope(); scope != nil {
+		return scope
+	}
+	if ctx != nil {
+		if scope, ok := ctx.Value(txScopeKey{}).(*txScope); ok {
+			return scope
+		}
+	}
+	return nil
+}
+
+// bind returns a repository sharing the settings of r bound to the
+// transaction of scope
+func (r *BaseRepository[T]) bind(scope *txScope) *BaseRepository[T] {
+	txRepo := NewBaseRepository[T](scope.db, r.logger, r.config)
+	txRepo.hooks = scope.hooks
+	txRepo.references = scope.references
+	txRepo.lookups = r.lookups
+	txRepo.cache = r.cache
+	txRepo.changeHooks = r.changeHooks
+	txRepo.validators = r.validators
+	txRepo.batchValidators = r.batchValidators
+	txRepo.dependencies = r.dependencies
+	txRepo.queryCache = r.queryCache
+	txRepo.observer.manager = r.observer.manager
+	return txRepo
+}
+
+// WithTransactionOpts runs fn in a transaction related to the transaction in
+// progress by opts.Propagation. The transaction in progress is the one the
+// repository is bound to, else the one carried by ctx, so repositories of
+// different entities share a transaction by passing on the context fn
+// receives:
+//
+//	err := orders.WithTransactionOpts(ctx, repository.TxOptions{}, func(ctx context.Context, tx repository.Repository[Order]) error {
+//		...
+//		return payments.WithTransactionOpts(ctx, repository.TxOptions{}, ...) // joins the orders transaction
+//	})
+//
+// Isolation and ReadOnly apply to new transactions only, not to joined ones
+// or savepoints. A joined transaction is not committed by fn returning, and
+// an error returned by fn is left to the outer transaction to roll back.
+func (r *BaseRepository[T]) WithTransactionOpts(ctx context.Context, opts TxOptions, fn func(ctx context.Context, tx Repository[T]) error) error {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "WithTransactionOpts", time.Since(start))
+	}()
+
+	if fn == nil {
+		r.metrics.IncrementOperationsFor("WithTransactionOpts", false)
+		return r.argumentError("WithTransactionOpts", "transaction function cannot be nil")
+	}
+
+	run := func(ctx context.Context, tx *BaseRepository[T]) error {
+		return fn(ctx, tx)
+	}
+	sqlOpts := &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly}
+	ambient := r.ambient(ctx)
+
+	var err error
+	panicked := false
+	switch opts.Propagation {
+	case PropagationRequired, "":
+		if ambient != nil {
+			err = r.join(ctx, ambient, run)
+		} else {
+			panicked, err = r.transaction(ctx, r.db, nil, sqlOpts, run)
+		}
+	case PropagationNested:
+		if ambient != nil {
+			panicked, err = r.transaction(ctx, ambient.db, ambient, nil, run)
+		} else {
+			panicked, err = r.transaction(ctx, r.db, nil, sqlOpts, run)
+		}
+	case PropagationRequiresNew:
+		// Begin on the connection pool, not on the transaction in progress
+		root := r.db.Session(&gorm.Session{NewDB: true})
+		root.Statement.ConnPool = root.ConnPool
+		panicked, err = r.transaction(ctx, root, nil, sqlOpts, run)
+	default:
+		err = r.argumentError("WithTransactionOpts", fmt.Sprintf("unknown transaction propagation %q", opts.Propagation))
+	}
+
+	if err != nil {
+		r.metrics.IncrementOperationsFor("WithTransactionOpts", false)
+		if panicked {
+			return err
+		}
+		return r.wrapError(err, "WithTransactionOpts", "failed to execute function within transaction")
+	}
+	r.metrics.IncrementOperationsFor("WithTransactionOpts", true)
+	return nil
+}
+
+// join runs fn in the transaction of scope, which its owner completes.
+// Panics propagate to the owner, which rolls back.
+func (r *BaseRepository[T]) join(ctx context.Context, scope *txScope, fn func(context.Context, *BaseRepository[T]) error) error {
+	return fn(contextWithTxScope(ctx, scope), r.bind(scope))
+}
+
+// transaction runs fn in a transaction begun on db with opts, a savepoint
+// when db is a transaction. The callbacks and references of a nested
+// transaction are handed over to parent when it succeeds. It reports
+// whether fn panicked, the error then describing the panic.
+func (r *BaseRepository[T]) transaction(ctx context.Context, db *gorm.DB, parent *txScope, opts *sql.TxOptions, fn func(context.Context, *BaseRepository[T]) error) (bool, error) {
+	var parentHooks *txHooks
+	var parentReferences *deferredReferences
+	if parent != nil {
+		parentHooks, parentReferences = parent.hooks, parent.references
+	}
+
+	hooks := &txHooks{}
+	var references *deferredReferences
+	if parentReferences != nil || contextFlag(ctx, deferredValidationKey{}) {
+		references = newDeferredReferences()
+	}
+
+	var txOpts []*sql.TxOptions
+	if opts != nil && !isTransaction(db) {
+		txOpts = append(txOpts, opts)
+	}
+
+	ctx, cancel := r.transactionContext(ctx, db)
+	defer cancel()
+
+	var txErr error
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		scope := &txScope{db: tx, hooks: hooks, references: references}
+		txRepo := r.bind(scope)
+
+		// Add panic recovery, rolling the transaction back
+		defer func() {
+			if p := recover(); p != nil {
+				txErr = r.newError(errors.ErrorTypeTransaction, "WithTransactionOpts", fmt.Sprintf("transaction function panicked: %v", p))
+				err = txErr
+			}
+		}()
+
+		if contextFlag(ctx, deferredConstraintsKey{}) {
+			if err := r.deferConstraints(ctx, tx); err != nil {
+				return err
+			}
+		}
+
+		run := func() error {
+			if err := fn(contextWithTxScope(ctx, scope), txRepo); err != nil {
+				return err
+			}
+			// References are validated by the outermost transaction, just before commit
+			if references != nil && parentReferences == nil {
+				return references.validate(tx)
+			}
+			return nil
+		}
+
+		// CockroachDB requires the restart savepoint to be the outermost one
+		if r.dialect.Name() == "cockroachdb" && !isTransaction(db) {
+			return r.runWithRestartSavepoint(ctx, tx, hooks, references, run)
+		}
+
+		return run()
+	}, txOpts...)
+
+	// A nested transaction hands its commit hooks and references to the enclosing one
+	committed := err == nil
+	if committed && parentReferences != nil && references != nil {
+		references.mergeInto(parentReferences)
+	}
+	if committed && parentHooks != nil {
+		hooks.mergeInto(parentHooks)
+	} else {
+		hooks.run(ctx, committed, r.logger)
+	}
+
+	if txErr != nil {
+		return true, txErr
+	}
+	return false, err
+}
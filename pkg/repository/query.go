@@ -0,0 +1,370 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Query is a fluent query over the entities of a repository:
+//
+//	users, err := repo.Query().
+//		Where("age >= ?", 18).
+//		OrderByDesc("created_at").
+//		Preload("Orders").
+//		Limit(20).
+//		Find(ctx)
+//
+// Builder methods return a new Query, so a partially built query can be
+// reused as a base for several others. Column and association names are
+// checked against the entity schema; the first invalid one is reported by the
+// terminal method (Find, First, Count, Exists). Reads go through the
+// repository's read routing and are recorded in its metrics under
+// "Query.<Method>".
+type Query[T any] struct {
+	repo       *BaseRepository[T]
+	conditions []queryCondition
+	orders     []clause.OrderByColumn
+	preloads   []queryPreload
+	limit      int
+	offset     int
+	cursor     string
+	direction  string
+	deleted    bool
+	err        error
+}
+
+// queryCondition represents a WHERE, OR or NOT condition
+type queryCondition struct {
+	kind  string
+	query interface{}
+	args  []interface{}
+}
+
+// queryPreload represents an association to preload
+type queryPreload struct {
+	association string
+	conds       []interface{}
+}
+
+// Query starts a fluent query over the repository entities
+func (r *BaseRepository[T]) Query() *Query[T] {
+	return &Query[T]{repo: r}
+}
+
+// clone returns a copy of the query that can be modified independently
+func (q *Query[T]) clone() *Query[T] {
+	c := *q
+	c.conditions = append([]queryCondition(nil), q.conditions...)
+	c.orders = append([]clause.OrderByColumn(nil), q.orders...)
+	c.preloads = append([]queryPreload(nil), q.preloads...)
+	return &c
+}
+
+// fail returns a copy of the query carrying err, keeping the first error
+func (q *Query[T]) fail(err error) *Query[T] {
+	c := q.clone()
+	if c.err == nil {
+		c.err = err
+	}
+	return c
+}
+
+// Where adds a condition, combined with the previous ones by AND
+func (q *Query[T]) Where(query interface{}, args ...interface{}) *Query[T] {
+	c := q.clone()
+	c.conditions = append(c.conditions, queryCondition{kind: "where", query: query, args: args})
+	return c
+}
+
+// Or adds a condition, combined with the previous ones by OR
+func (q *Query[T]) Or(query interface{}, args ...interface{}) *Query[T] {
+	c := q.clone()
+	c.conditions = append(c.conditions, queryCondition{kind: "or", query: query, args: args})
+	return c
+}
+
+// Not adds a negated condition
+func (q *Query[T]) Not(query interface{}, args ...interface{}) *Query[T] {
+	c := q.clone()
+	c.conditions = append(c.conditions, queryCondition{kind: "not", query: query, args: args})
+	return c
+}
+
+// Scopes adds typed conditions (see package scopes), combined with the
+// previous ones by AND
+func (q *Query[T]) Scopes(scopes ...clause.Expression) *Query[T] {
+	c := q.clone()
+	for _, scope := range scopes {
+		c.conditions = append(c.conditions, queryCondition{kind: "where", query: scope})
+	}
+	return c
+}
+
+// OrderBy sorts the results by column in ascending order
+func (q *Query[T]) OrderBy(column string) *Query[T] {
+	return q.orderBy(column, false)
+}
+
+// OrderByDesc sorts the results by column in descending order
+func (q *Query[T]) OrderByDesc(column string) *Query[T] {
+	return q.orderBy(column, true)
+}
+
+// orderBy adds an ORDER BY column after checking it is mapped by the entity
+func (q *Query[T]) orderBy(column string, desc bool) *Query[T] {
+	field, err := q.lookupField(column)
+	if err != nil {
+		return q.fail(err)
+	}
+
+	c := q.clone()
+	c.orders = append(c.orders, clause.OrderByColumn{Column: clause.Column{Name: field.DBName}, Desc: desc})
+	return c
+}
+
+// Preload loads association with the results, optionally filtered by conds
+func (q *Query[T]) Preload(association string, conds ...interface{}) *Query[T] {
+	s, err := q.repo.schema()
+	if err != nil {
+		return q.fail(err)
+	}
+	if _, ok := s.Relationships.Relations[association]; !ok {
+		return q.fail(q.repo.argumentError("Query.Preload", fmt.Sprintf("unknown association %q on %s", association, s.Name)))
+	}
+
+	c := q.clone()
+	c.preloads = append(c.preloads, queryPreload{association: association, conds: conds})
+	return c
+}
+
+// Limit bounds the number of results. It is normalized like the repository's
+// paginated finders: non-positive values select the default limit and values
+// above the maximum are capped.
+func (q *Query[T]) Limit(limit int) *Query[T] {
+	c := q.clone()
+	c.limit = limit
+	return c
+}
+
+// Offset skips the first offset results
+func (q *Query[T]) Offset(offset int) *Query[T] {
+	c := q.clone()
+	c.offset = offset
+	return c
+}
+
+// After restricts the results to entities whose ID follows cursor, like the
+// "next" direction of the cursor paginated finders
+func (q *Query[T]) After(cursor string) *Query[T] {
+	c := q.clone()
+	c.cursor, c.direction = cursor, "next"
+	return c
+}
+
+// Before restricts the results to entities whose ID precedes cursor, like the
+// "prev" direction of the cursor paginated finders
+func (q *Query[T]) Before(cursor string) *Query[T] {
+	c := q.clone()
+	c.cursor, c.direction = cursor, "prev"
+	return c
+}
+
+// WithDeleted includes soft deleted entities in the results
+func (q *Query[T]) WithDeleted() *Query[T] {
+	c := q.clone()
+	c.deleted = true
+	return c
+}
+
+// Find returns the entities matching the query
+func (q *Query[T]) Find(ctx context.Context) ([]T, error) {
+	start := time.Now()
+	defer func() {
+		q.repo.metrics.RecordTableQueryTime(q.repo.tableName, "Query.Find", time.Since(start))
+	}()
+
+	if q.err != nil {
+		q.repo.metrics.IncrementOperationsFor("Query.Find", false)
+		return nil, q.err
+	}
+
+	var results []T
+	if err := q.build(ctx, true).Find(&results).Error; err != nil {
+		q.repo.metrics.IncrementOperationsFor("Query.Find", false)
+		return nil, q.repo.wrapError(err, "Query.Find", "failed to find entities")
+	}
+
+	q.repo.metrics.IncrementOperationsFor("Query.Find", true)
+	return results, nil
+}
+
+// First returns the first entity matching the query, ordered by primary key
+// unless an order is set. The error matches errors.ErrNotFound when nothing matches.
+func (q *Query[T]) First(ctx context.Context) (*T, error) {
+	start := time.Now()
+	defer func() {
+		q.repo.metrics.RecordTableQueryTime(q.repo.tableName, "Query.First", time.Since(start))
+	}()
+
+	if q.err != nil {
+		q.repo.metrics.IncrementOperationsFor("Query.First", false)
+		return nil, q.err
+	}
+
+	var entity T
+	query := q.build(ctx, false)
+	if q.offset > 0 {
+		query = query.Offset(q.offset)
+	}
+
+	var err error
+	if len(q.orders) > 0 {
+		err = query.Take(&entity).Error
+	} else {
+		err = query.First(&entity).Error
+	}
+	if err != nil {
+		q.repo.metrics.IncrementOperationsFor("Query.First", false)
+		return nil, q.repo.wrapError(err, "Query.First", "failed to find entity")
+	}
+
+	q.repo.metrics.IncrementOperationsFor("Query.First", true)
+	return &entity, nil
+}
+
+// Count returns the number of entities matching the query's conditions,
+// ignoring its order, limit and offset
+func (q *Query[T]) Count(ctx context.Context) (int64, error) {
+	start := time.Now()
+	defer func() {
+		q.repo.metrics.RecordTableQueryTime(q.repo.tableName, "Query.Count", time.Since(start))
+	}()
+
+	if q.err != nil {
+		q.repo.metrics.IncrementOperationsFor("Query.Count", false)
+		return 0, q.err
+	}
+
+	var count int64
+	if err := q.filtered(ctx).Model(new(T)).Count(&count).Error; err != nil {
+		q.repo.metrics.IncrementOperationsFor("Query.Count", false)
+		return 0, q.repo.wrapError(err, "Query.Count", "failed to count entities")
+	}
+
+	q.repo.metrics.IncrementOperationsFor("Query.Count", true)
+	return count, nil
+}
+
+// Exists reports whether any entity matches the query's conditions
+func (q *Query[T]) Exists(ctx context.Context) (bool, error) {
+	start := time.Now()
+	defer func() {
+		q.repo.metrics.RecordTableQueryTime(q.repo.tableName, "Query.Exists", time.Since(start))
+	}()
+
+	if q.err != nil {
+		q.repo.metrics.IncrementOperationsFor("Query.Exists", false)
+		return false, q.err
+	}
+
+	var found []T
+	if err := q.filtered(ctx).Select(q.repo.primaryKeyColumn()).Limit(1).Find(&found).Error; err != nil {
+		q.repo.metrics.IncrementOperationsFor("Query.Exists", false)
+		return false, q.repo.wrapError(err, "Query.Exists", "failed to check entity existence")
+	}
+
+	q.repo.metrics.IncrementOperationsFor("Query.Exists", true)
+	return len(found) > 0, nil
+}
+
+// filtered returns a read session restricted to the query's conditions and cursor
+func (q *Query[T]) filtered(ctx context.Context) *gorm.DB {
+	if q.deleted {
+		ctx = WithDeleted(ctx)
+	}
+
+	query := q.repo.readDB(ctx)
+	for _, cond := range q.conditions {
+		switch cond.kind {
+		case "or":
+			query = query.Or(cond.query, cond.args...)
+		case "not":
+			query = query.Not(cond.query, cond.args...)
+		default:
+			query = query.Where(cond.query, cond.args...)
+		}
+	}
+
+	if q.cursor != "" {
+		if q.direction == "prev" {
+			query = query.Where("id < ?", q.cursor)
+		} else {
+			query = query.Where("id > ?", q.cursor)
+		}
+	}
+	return query
+}
+
+// build returns the filtered session with orders and preloads applied, and
+// the normalized limit and offset when paginate is set
+func (q *Query[T]) build(ctx context.Context, paginate bool) *gorm.DB {
+	query := q.filtered(ctx)
+	for _, order := range q.orders {
+		query = query.Order(order)
+	}
+	for _, preload := range q.preloads {
+		query = query.Preload(preload.association, preload.conds...)
+	}
+
+	if paginate {
+		limit, offset := q.repo.validateOffsetPaginationParams(q.limit, q.offset)
+		query = q.repo.paginate(query, limit, offset)
+	}
+	return query
+}
+
+// lookupField returns the schema field mapped to column (a column or field name)
+func (q *Query[T]) lookupField(column string) (*schema.Field, error) {
+	s, err := q.repo.schema()
+	if err != nil {
+		return nil, err
+	}
+
+	field := s.LookUpField(column)
+	if field == nil || field.DBName == "" {
+		return nil, q.repo.argumentError("Query", fmt.Sprintf("unknown column %q on %s", column, s.Name))
+	}
+	return field, nil
+}
+
+// schema returns the parsed schema of the repository entity
+func (r *BaseRepository[T]) schema() (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, r.wrapError(err, "Schema", "failed to parse entity schema")
+	}
+	return stmt.Schema, nil
+}
+
+// schemaTable returns the table of the entity as named by gorm. tableName,
+// which labels metrics, logs and errors, is the Go type name of models
+// without a TableName method.
+func (r *BaseRepository[T]) schemaTable() string {
+	if s, err := r.schema(); err == nil {
+		return s.Table
+	}
+	return r.tableName
+}
+
+// primaryKeyColumn returns the primary key column of the entity, "id" by default
+func (r *BaseRepository[T]) primaryKeyColumn() string {
+	if s, err := r.schema(); err == nil && s.PrioritizedPrimaryField != nil {
+		return s.PrioritizedPrimaryField.DBName
+	}
+	return "id"
+}
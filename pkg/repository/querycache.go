@@ -0,0 +1,392 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"database/sql/driver"
+	stderrors "errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+)
+
+// QueryCacheConfig configures a QueryCache
+type QueryCacheConfig struct {
+	// TTL is how long results stay cached, zero keeps them until evicted or
+	// invalidated
+	TTL time.Duration `json:"ttl"`
+	// MaxEntries bounds the cached results, the least recently used are
+	// evicted first (10000 when not set)
+	MaxEntries int `json:"max_entries"`
+}
+
+// QueryCacheStats reports the activity of a query cache
+type QueryCacheStats struct {
+	Entries   int   `json:"entries"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	// Invalidations counts the results dropped because a table they read
+	// was written
+	Invalidations int64 `json:"invalidations"`
+}
+
+// HitRate returns the fraction of cache lookups that were hits
+func (s QueryCacheStats) HitRate() float64 {
+	return successRate(s.Hits, s.Hits+s.Misses)
+}
+
+// QueryCache is a gorm plugin caching the results of queries by their SQL
+// and parameters, beyond the by-ID entity cache. A result is dropped when
+// its TTL expires or when a table it read, in its FROM or JOIN clauses, is
+// written by a create, update, delete or raw statement of the database.
+//
+//	cache := repository.NewQueryCache(&repository.QueryCacheConfig{TTL: time.Minute})
+//	db.Use(cache)
+//	orders := repository.NewBaseRepository[Order](db, logger, nil).WithQueryCache(cache)
+//
+// Queries inside transactions, locking reads and queries whose context
+// comes from WithoutQueryCache are neither cached nor served from the cache.
+// Writes inside a transaction invalidate when they run; repositories attached
+// with WithQueryCache invalidate again once the transaction commits, so
+// results read meanwhile from the committed state do not outlive it.
+// Results are cached by value: the slices are copied, but pointers, maps
+// and slices inside the entities are shared with the callers.
+type QueryCache struct {
+	config QueryCacheConfig
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	tables  map[string]map[string]struct{}
+	order   *list.List
+	stats   QueryCacheStats
+	// version counts the invalidations, so results read while a table was
+	// written are not cached
+	version uint64
+}
+
+// queryCacheEntry is a cached result
+type queryCacheEntry struct {
+	key     string
+	tables  []string
+	value   reflect.Value
+	rows    int64
+	expires time.Time
+}
+
+// noQueryCacheKey is the context key of the queries bypassing the cache
+type noQueryCacheKey struct{}
+
+// WithoutQueryCache returns a context whose queries bypass the query cache
+func WithoutQueryCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noQueryCacheKey{}, true)
+}
+
+// NewQueryCache creates a query cache, configured with the defaults when
+// config is nil
+func NewQueryCache(config *QueryCacheConfig) *QueryCache {
+	c := &QueryCache{
+		entries: make(map[string]*list.Element),
+		tables:  make(map[string]map[string]struct{}),
+		order:   list.New(),
+	}
+	if config != nil {
+		c.config = *config
+	}
+	if c.config.MaxEntries <= 0 {
+		c.config.MaxEntries = defaultCacheEntries
+	}
+	return c
+}
+
+// Name returns the plugin name
+func (c *QueryCache) Name() string {
+	return "ormx:query_cache"
+}
+
+// Initialize wraps the query callback of db and registers the callbacks
+// invalidating the tables written by its statements
+func (c *QueryCache) Initialize(db *gorm.DB) error {
+	processor := db.Callback().Query()
+	query := processor.Get("gorm:query")
+	if query == nil {
+		return fmt.Errorf("failed to register query cache: gorm:query callback not found")
+	}
+	err := stderrors.Join(
+		processor.Replace("gorm:query", c.query(query)),
+		db.Callback().Create().After("gorm:create").Register("ormx:query_cache_create", c.written),
+		db.Callback().Update().After("gorm:update").Register("ormx:query_cache_update", c.written),
+		db.Callback().Delete().After("gorm:delete").Register("ormx:query_cache_delete", c.written),
+		db.Callback().Raw().After("gorm:raw").Register("ormx:query_cache_raw", c.written),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register query cache callbacks: %w", err)
+	}
+	return nil
+}
+
+// Stats returns the statistics of the cache
+func (c *QueryCache) Stats() QueryCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	return stats
+}
+
+// InvalidateTables drops the results that read any of tables, e.g. after
+// writes made outside the database the cache is registered on
+func (c *QueryCache) InvalidateTables(tables ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	for _, table := range tables {
+		for key := range c.tables[strings.ToLower(table)] {
+			if element, ok := c.entries[key]; ok {
+				c.removeElement(element)
+				c.stats.Invalidations++
+			}
+		}
+	}
+}
+
+// Clear drops every cached result
+func (c *QueryCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	c.entries = make(map[string]*list.Element)
+	c.tables = make(map[string]map[string]struct{})
+	c.order.Init()
+}
+
+// query returns the query callback serving cacheable queries from the
+// cache and caching the results of next
+func (c *QueryCache) query(next func(*gorm.DB)) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil || !c.cacheable(db) {
+			next(db)
+			return
+		}
+		callbacks.BuildQuerySQL(db)
+		if db.Error != nil || db.DryRun {
+			next(db)
+			return
+		}
+
+		key, ok := queryCacheKey(db)
+		if !ok {
+			next(db)
+			return
+		}
+		version, ok := c.serve(db, key)
+		if ok {
+			return
+		}
+		next(db)
+		if db.Error == nil {
+			c.put(key, queryTables(db), db.Statement.Dest, db.RowsAffected, version)
+		}
+	}
+}
+
+// cacheable reports whether the result of the query may be cached: a query
+// into a pointer, outside transactions and locking reads
+func (c *QueryCache) cacheable(db *gorm.DB) bool {
+	stmt := db.Statement
+	if stmt.Context != nil {
+		if bypass, _ := stmt.Context.Value(noQueryCacheKey{}).(bool); bypass {
+			return false
+		}
+	}
+	if _, locking := stmt.Clauses["FOR"]; locking || isTransaction(db) {
+		return false
+	}
+	dest := reflect.ValueOf(stmt.Dest)
+	return dest.Kind() == reflect.Ptr && !dest.IsNil()
+}
+
+// serve copies the result cached under key into the destination of the
+// query, reporting false with the version of the cache when none is cached
+func (c *QueryCache) serve(db *gorm.DB, key string) (uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if ok {
+		entry := element.Value.(*queryCacheEntry)
+		dest := reflect.ValueOf(db.Statement.Dest).Elem()
+		if (entry.expires.IsZero() || time.Now().Before(entry.expires)) && entry.value.Type() == dest.Type() {
+			c.stats.Hits++
+			c.order.MoveToFront(element)
+			dest.Set(copyResult(entry.value))
+			db.RowsAffected = entry.rows
+			db.Statement.RowsAffected = entry.rows
+			return c.version, true
+		}
+		c.removeElement(element)
+	}
+	c.stats.Misses++
+	return c.version, false
+}
+
+// put caches a copy of the result in dest under key, read from tables,
+// unless the cache was invalidated since version
+func (c *QueryCache) put(key string, tables []string, dest interface{}, rows int64, version uint64) {
+	entry := &queryCacheEntry{
+		key:    key,
+		tables: tables,
+		value:  copyResult(reflect.ValueOf(dest).Elem()),
+		rows:   rows,
+	}
+	if c.config.TTL > 0 {
+		entry.expires = time.Now().Add(c.config.TTL)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.version != version {
+		return
+	}
+	if element, ok := c.entries[key]; ok {
+		c.removeElement(element)
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for _, table := range tables {
+		if c.tables[table] == nil {
+			c.tables[table] = make(map[string]struct{})
+		}
+		c.tables[table][key] = struct{}{}
+	}
+	for c.order.Len() > c.config.MaxEntries {
+		c.removeElement(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// removeElement drops a cached result; callers hold the lock
+func (c *QueryCache) removeElement(element *list.Element) {
+	entry := element.Value.(*queryCacheEntry)
+	c.order.Remove(element)
+	delete(c.entries, entry.key)
+	for _, table := range entry.tables {
+		delete(c.tables[table], entry.key)
+		if len(c.tables[table]) == 0 {
+			delete(c.tables, table)
+		}
+	}
+}
+
+// written invalidates the table written by a successful statement
+func (c *QueryCache) written(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+	table := db.Statement.Table
+	if table == "" {
+		// Raw statements name their table in their SQL only
+		summary, _ := summarizeStatement(db.Statement.SQL.String())
+		if verb, name, ok := strings.Cut(summary, " "); ok && verb != "SELECT" {
+			table = name
+		}
+	}
+	if table != "" {
+		c.InvalidateTables(unqualifiedTable(table))
+	}
+}
+
+// queryCacheKey returns the cache key of a built query: its normalized SQL,
+// parameters and destination type. Parameters are keyed by the values sent
+// to the driver, so pointers by the values they point to and driver.Valuers
+// by their values. It returns false when a parameter has no such value, and
+// the query is not cached.
+func queryCacheKey(db *gorm.DB) (string, bool) {
+	vars := make([]driver.Value, len(db.Statement.Vars))
+	for i, v := range db.Statement.Vars {
+		value, err := driver.DefaultParameterConverter.ConvertValue(v)
+		if err != nil {
+			return "", false
+		}
+		vars[i] = value
+	}
+	return fmt.Sprintf("%T|%s|%#v", db.Statement.Dest, strings.Join(strings.Fields(db.Statement.SQL.String()), " "), vars), true
+}
+
+// queryTableRef matches the tables referenced by the FROM and JOIN clauses
+// of a statement
+var queryTableRef = regexp.MustCompile(`(?i)\b(?:FROM|JOIN)\s+([^\s,()]+)`)
+
+// queryTables returns the lower case tables read by a built query
+func queryTables(db *gorm.DB) []string {
+	seen := make(map[string]bool)
+	var tables []string
+	add := func(table string) {
+		table = unqualifiedTable(table)
+		if table != "" && !seen[table] {
+			seen[table] = true
+			tables = append(tables, table)
+		}
+	}
+	add(db.Statement.Table)
+	for _, match := range queryTableRef.FindAllStringSubmatch(db.Statement.SQL.String(), -1) {
+		add(match[1])
+	}
+	return tables
+}
+
+// unqualifiedTable returns the lower case table name without its quotes and
+// schema
+func unqualifiedTable(table string) string {
+	table = strings.Trim(table, "\"`[];")
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		table = strings.Trim(table[i+1:], "\"`[]")
+	}
+	return strings.ToLower(table)
+}
+
+// copyResult returns a copy of a query result whose slices are not shared
+// with v
+func copyResult(v reflect.Value) reflect.Value {
+	copied := reflect.New(v.Type()).Elem()
+	if v.Kind() == reflect.Slice && !v.IsNil() {
+		copied.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+		reflect.Copy(copied, v)
+		return copied
+	}
+	copied.Set(v)
+	return copied
+}
+
+// WithQueryCache reports the writes of the repository to cache once their
+// transaction commits, on top of the invalidation by the statements
+// themselves (see QueryCache)
+func (r *BaseRepository[T]) WithQueryCache(cache *QueryCache) *BaseRepository[T] {
+	r.queryCache = cache
+	return r
+}
+
+// queryCacheInvalidate drops the cached results reading the table of the
+// repository, and again once the surrounding transaction commits
+func (r *BaseRepository[T]) queryCacheInvalidate(ctx context.Context) {
+	cache := r.queryCache
+	if cache == nil {
+		return
+	}
+	table := unqualifiedTable(r.schemaTable())
+	cache.InvalidateTables(table)
+	if r.hooks != nil && r.hooks.once("query_cache:"+table) {
+		r.AfterCommit(ctx, func(context.Context) {
+			cache.InvalidateTables(table)
+		})
+	}
+}
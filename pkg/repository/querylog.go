@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/observability"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// queryObserver watches the statements run by a repository
+type queryObserver struct {
+	table     string
+	threshold time.Duration
+	logger    logging.Logger
+	manager   *observability.ObservabilityManager
+	// explainer captures the plans of slow statements, nil when disabled
+	explainer *planExplainer
+}
+
+// statementLogger wraps the gorm logger of a repository session, logging
+// the statements slower than the threshold and recording every statement to
+// the observability manager
+type statementLogger struct {
+	gormlogger.Interface
+	observer *queryObserver
+}
+
+// LogMode returns the logger at level, keeping the observer
+func (l *statementLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return &statementLogger{Interface: l.Interface.LogMode(level), observer: l.observer}
+}
+
+// ParamsFilter filters the parameters logged with the statements through
+// the wrapped logger, if it filters them
+func (l *statementLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if filter, ok := l.Interface.(gorm.ParamsFilter); ok {
+		return filter.ParamsFilter(ctx, sql, params...)
+	}
+	return sql, params
+}
+
+// Trace reports a finished statement
+func (l *statementLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	l.Interface.Trace(ctx, begin, fc, err)
+
+	o := l.observer
+	elapsed := time.Since(begin)
+	slow := err == nil && o.threshold > 0 && elapsed > o.threshold && o.logger != nil
+	if !slow && o.manager == nil {
+		return
+	}
+
+	sql, rows := fc()
+	summary, conds := summarizeStatement(sql)
+	if slow {
+		fields := []logging.LogField{
+			logging.String("summary", summary),
+			logging.String("table", o.table),
+			logging.String("conds", conds),
+			logging.String("query", sql),
+			logging.Duration("duration", elapsed),
+			logging.Duration("threshold", o.threshold),
+			logging.Int64("rows", rows),
+		}
+		if plan, truncated, ok := o.explainer.explain(ctx, sql); ok {
+			fields = append(fields, logging.String("plan", plan))
+			o.recordPlan(ctx, summary, sql, plan, truncated, begin, elapsed)
+		}
+		o.logger.Warn(ctx, "Slow query detected", fields...)
+	}
+	if o.manager != nil {
+		// The summary keeps the metric labels bounded, unlike the statement
+		o.manager.RecordQueryMetrics(ctx, summary, elapsed, rows, err == nil || err == gorm.ErrRecordNotFound)
+	}
+}
+
+// statementWhere matches the conditions of a statement
+var statementWhere = regexp.MustCompile(`(?is)\bWHERE\b(.*?)(?:\bGROUP\s+BY\b|\bORDER\s+BY\b|\bLIMIT\b|\bOFFSET\b|\bRETURNING\b|\bFOR\s+UPDATE\b|$)`)
+
+// summarizeStatement returns the verb and table of a statement, e.g.
+// "SELECT users", and its conditions
+func summarizeStatement(sql string) (string, string) {
+	tokens := strings.Fields(sql)
+	if len(tokens) == 0 {
+		return "", ""
+	}
+
+	verb := strings.ToUpper(tokens[0])
+	after := ""
+	switch verb {
+	case "SELECT", "DELETE":
+		after = "FROM"
+	case "INSERT", "MERGE":
+		after = "INTO"
+	case "UPDATE":
+		after = "UPDATE"
+	}
+	summary := verb
+	for i := 0; after != "" && i < len(tokens)-1; i++ {
+		if strings.EqualFold(tokens[i], after) {
+			summary += " " + strings.Trim(tokens[i+1], "\"`[]();")
+			break
+		}
+	}
+
+	var conds string
+	if match := statementWhere.FindStringSubmatch(sql); match != nil {
+		conds = strings.TrimSpace(match[1])
+	}
+	return summary, conds
+}
+
+// observe returns a session of db reporting its statements to the query
+// observer of the repository, with the session options of its configuration
+func (r *BaseRepository[T]) observe(db *gorm.DB) *gorm.DB {
+	if db == nil {
+		return nil
+	}
+	inner := db.Logger
+	if wrapped, ok := inner.(*statementLogger); ok {
+		inner = wrapped.Interface
+	}
+	if inner == nil {
+		inner = gormlogger.Discard
+	}
+	session := r.config.session()
+	session.Logger = &statementLogger{Interface: inner, observer: r.observer}
+	return r.withQueryTimeout(db.Session(session))
+}
+
+// WithObservability records every statement of the repository, summarized
+// by verb and table, to manager (see ObservabilityManager.RecordQueryMetrics)
+func (r *BaseRepository[T]) WithObservability(manager *observability.ObservabilityManager) *BaseRepository[T] {
+	r.observer.manager = manager
+	return r
+}
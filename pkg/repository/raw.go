@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+)
+
+// Raw runs a hand-written query and scans its result into dest, a struct, a
+// slice of structs, a map or a scalar. Parameters are referenced by name
+// (@name) and bound from params:
+//
+//	var names []string
+//	err := repo.Raw(ctx, "SELECT name FROM users WHERE age > @age", map[string]interface{}{"age": 30}, &names)
+//
+// The query runs on a read replica when a router is set, and is recorded in
+// the repository metrics and the query log like the generated statements.
+// Failures are classified (see errors.ErrorClassifier) and retried when
+// transient.
+func (r *BaseRepository[T]) Raw(ctx context.Context, query string, params map[string]interface{}, dest interface{}) error {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "Raw", time.Since(start))
+	}()
+
+	var rows int64
+	err := r.retry(ctx, "Raw", func() error {
+		db := r.db.WithContext(ctx)
+		if r.router != nil && !isTransaction(r.db) {
+			db = r.observe(r.router.ReadDB(ctx))
+		}
+		result := db.Raw(query, namedArgs(params)...).Scan(dest)
+		rows = result.RowsAffected
+		return result.Error
+	})
+	r.logQuery(ctx, query, time.Since(start), rows, err)
+	if err != nil {
+		r.metrics.IncrementOperationsFor("Raw", false)
+		return r.wrapError(r.classify(err, "Raw", query, params), "Raw", "failed to run raw query")
+	}
+
+	r.metrics.IncrementOperationsFor("Raw", true)
+	return nil
+}
+
+// Exec executes a hand-written statement on the primary and returns the number
+// of affected rows. Parameters are referenced by name (@name) and bound from
+// params. Like Raw the statement is recorded in the metrics and the query log
+// and its failures are classified, but it is not retried as it may not be
+// idempotent. The entity cache is cleared, the statement may change any entity.
+func (r *BaseRepository[T]) Exec(ctx context.Context, statement string, params map[string]interface{}) (int64, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "Exec", time.Since(start))
+	}()
+
+	result := r.db.WithContext(ctx).Exec(statement, namedArgs(params)...)
+	r.logQuery(ctx, statement, time.Since(start), result.RowsAffected, result.Error)
+	if result.Error != nil {
+		r.metrics.IncrementOperationsFor("Exec", false)
+		return 0, r.wrapError(r.classify(result.Error, "Exec", statement, params), "Exec", "failed to execute raw statement")
+	}
+
+	r.cacheInvalidate(ctx)
+	r.metrics.IncrementOperationsFor("Exec", true)
+	return result.RowsAffected, nil
+}
+
+// namedArgs returns the arguments binding params by name
+func namedArgs(params map[string]interface{}) []interface{} {
+	if len(params) == 0 {
+		return nil
+	}
+	return []interface{}{params}
+}
+
+// classify wraps err in an ORMError describing the failed statement
+func (r *BaseRepository[T]) classify(err error, operation, statement string, params map[string]interface{}) error {
+	return retryClassifier.ClassifyError(err, operation).
+		WithTable(r.tableName).
+		WithQuery(statement, namedArgs(params)...)
+}
+
+// logQuery logs a failed hand-written statement; slow statements are
+// logged by the query observer like every statement of the repository
+func (r *BaseRepository[T]) logQuery(ctx context.Context, statement string, duration time.Duration, rows int64, err error) {
+	if r.logger == nil || err == nil {
+		return
+	}
+	r.logger.Error(ctx, "Query failed",
+		logging.String("query", statement),
+		logging.String("table", r.tableName),
+		logging.Duration("duration", duration),
+		logging.Int64("rows", rows),
+		logging.ErrorField("error", err))
+}
@@ -0,0 +1,393 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"gorm.io/gorm"
+)
+
+// ReadOnlyRepository decorates a repository so its reads pass through and its
+// writes fail with an ErrorTypeAccessDenied error, for services handed a
+// read-only handle such as reporting paths and replica connections. Writes
+// include Begin and Commit, which expose or persist a writable transaction;
+// WithTransaction and WithTransactionOpts pass read-only repositories on.
+type ReadOnlyRepository[T any] struct {
+	repo Repository[T]
+}
+
+var _ Repository[struct{}] = (*ReadOnlyRepository[struct{}])(nil)
+
+// NewReadOnlyRepository creates a read-only repository over repo
+func NewReadOnlyRepository[T any](repo Repository[T]) *ReadOnlyRepository[T] {
+	if readOnly, ok := repo.(*ReadOnlyRepository[T]); ok {
+		return readOnly
+	}
+	return &ReadOnlyRepository[T]{repo: repo}
+}
+
+// deny returns the error of a write attempted on the repository
+func (r *ReadOnlyRepository[T]) deny(operation string) error {
+	return errors.New(errors.ErrorTypeAccessDenied, fmt.Sprintf("%s is not allowed on a read-only repository", operation)).
+		WithOperation(operation)
+}
+
+// FindFirstByID finds entity by ID, loading it as configured by opts
+func (r *ReadOnlyRepository[T]) FindFirstByID(ctx context.Context, id models.EntityID, opts ...FindOption) (*T, error) {
+	return r.repo.FindFirstByID(ctx, id, opts...)
+}
+
+// FindFirstByKey finds entity by key
+func (r *ReadOnlyRepository[T]) FindFirstByKey(ctx context.Context, key map[string]interface{}, opts ...FindOption) (*T, error) {
+	return r.repo.FindFirstByKey(ctx, key, opts...)
+}
+
+// FindAllByIDs finds the entities with ids, keyed by ID
+func (r *ReadOnlyRepository[T]) FindAllByIDs(ctx context.Context, ids []models.EntityID, opts ...FindOption) (map[models.EntityID]*T, error) {
+	return r.repo.FindAllByIDs(ctx, ids, opts...)
+}
+
+// FindFirstByConditions finds first entity by conditions
+func (r *ReadOnlyRepository[T]) FindFirstByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
+	return r.repo.FindFirstByConditions(ctx, dest, conds...)
+}
+
+// FindFirstBy finds the first entity whose column equals value
+func (r *ReadOnlyRepository[T]) FindFirstBy(ctx context.Context, dest *T, column string, value interface{}) error {
+	return r.repo.FindFirstBy(ctx, dest, column, value)
+}
+
+// FirstOrInitByConditions finds first entity by conditions or initializes a new entity
+func (r *ReadOnlyRepository[T]) FirstOrInitByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
+	return r.repo.FirstOrInitByConditions(ctx, dest, conds...)
+}
+
+// FindAllWithOffset finds all entities
+func (r *ReadOnlyRepository[T]) FindAllWithOffset(ctx context.Context, limit int, offset int, dest *[]T, opts ...FindOption) error {
+	return r.repo.FindAllWithOffset(ctx, limit, offset, dest, opts...)
+}
+
+// FindAllInBatchesWithOffset finds all entities in batches
+func (r *ReadOnlyRepository[T]) FindAllInBatchesWithOffset(ctx context.Context, limit int, offset int, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, opts ...FindOption) error {
+	return r.repo.FindAllInBatchesWithOffset(ctx, limit, offset, dest, batchSize, fc, opts...)
+}
+
+// FindAllByConditionsWithOffset finds all entities by conditions
+func (r *ReadOnlyRepository[T]) FindAllByConditionsWithOffset(ctx context.Context, limit int, offset int, dest *[]T, conds ...interface{}) error {
+	return r.repo.FindAllByConditionsWithOffset(ctx, limit, offset, dest, conds...)
+}
+
+// FindAllInBatchesByConditionsWithOffset finds all entities in batches by conditions
+func (r *ReadOnlyRepository[T]) FindAllInBatchesByConditionsWithOffset(ctx context.Context, limit int, offset int, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, conds ...interface{}) error {
+	return r.repo.FindAllInBatchesByConditionsWithOffset(ctx, limit, offset, dest, batchSize, fc, conds...)
+}
+
+// FindAllWithCursor finds all entities
+func (r *ReadOnlyRepository[T]) FindAllWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, opts ...FindOption) error {
+	return r.repo.FindAllWithCursor(ctx, cursor, limit, direction, dest, opts...)
+}
+
+// FindAllInBatchesWithCursor finds all entities in batches
+func (r *ReadOnlyRepository[T]) FindAllInBatchesWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, opts ...FindOption) error {
+	return r.repo.FindAllInBatchesWithCursor(ctx, cursor, limit, direction, dest, batchSize, fc, opts...)
+}
+
+// FindAllByConditionsWithCursor finds all entities by conditions
+func (r *ReadOnlyRepository[T]) FindAllByConditionsWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, conds ...interface{}) error {
+	return r.repo.FindAllByConditionsWithCursor(ctx, cursor, limit, direction, dest, conds...)
+}
+
+// FindAllInBatchesByConditionsWithCursor finds all entities in batches by conditions
+func (r *ReadOnlyRepository[T]) FindAllInBatchesByConditionsWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, conds ...interface{}) error {
+	return r.repo.FindAllInBatchesByConditionsWithCursor(ctx, cursor, limit, direction, dest, batchSize, fc, conds...)
+}
+
+// FindAllIncludingDeleted finds all entities, soft deleted ones included
+func (r *ReadOnlyRepository[T]) FindAllIncludingDeleted(ctx context.Context, limit int, offset int, dest *[]T, opts ...FindOption) error {
+	return r.repo.FindAllIncludingDeleted(ctx, limit, offset, dest, opts...)
+}
+
+// ExistsByID checks if an entity exists
+func (r *ReadOnlyRepository[T]) ExistsByID(ctx context.Context, id models.EntityID) (bool, error) {
+	return r.repo.ExistsByID(ctx, id)
+}
+
+// ExistsByKey checks if an entity with key exists
+func (r *ReadOnlyRepository[T]) ExistsByKey(ctx context.Context, key map[string]interface{}) (bool, error) {
+	return r.repo.ExistsByKey(ctx, key)
+}
+
+// ExistsByConditions checks if an entity exists by conditions
+func (r *ReadOnlyRepository[T]) ExistsByConditions(ctx context.Context, conds ...interface{}) (bool, error) {
+	return r.repo.ExistsByConditions(ctx, conds...)
+}
+
+// CountByConditions counts entities by conditions
+func (r *ReadOnlyRepository[T]) CountByConditions(ctx context.Context, conds ...interface{}) (int64, error) {
+	return r.repo.CountByConditions(ctx, conds...)
+}
+
+// PluckByConditions loads column of the entities matching conds into dest
+func (r *ReadOnlyRepository[T]) PluckByConditions(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	return r.repo.PluckByConditions(ctx, column, dest, conds...)
+}
+
+// DistinctPluckByConditions loads the distinct values of column into dest
+func (r *ReadOnlyRepository[T]) DistinctPluckByConditions(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	return r.repo.DistinctPluckByConditions(ctx, column, dest, conds...)
+}
+
+// TimeSeriesCount counts entities grouped by timeColumn truncated to interval
+func (r *ReadOnlyRepository[T]) TimeSeriesCount(ctx context.Context, timeColumn string, interval dialect.TimeUnit, conds ...interface{}) ([]TimeBucketCount, error) {
+	return r.repo.TimeSeriesCount(ctx, timeColumn, interval, conds...)
+}
+
+// PercentileBy returns the continuous percentiles (0..1) of a numeric column
+func (r *ReadOnlyRepository[T]) PercentileBy(ctx context.Context, column string, percentiles []float64, conds ...interface{}) ([]PercentileValue, error) {
+	return r.repo.PercentileBy(ctx, column, percentiles, conds...)
+}
+
+// HistogramBy counts entities per bucket of a numeric column
+func (r *ReadOnlyRepository[T]) HistogramBy(ctx context.Context, column string, bounds []float64, conds ...interface{}) ([]HistogramBucket, error) {
+	return r.repo.HistogramBy(ctx, column, bounds, conds...)
+}
+
+// SumByConditions sums column over the entities matching conds
+func (r *ReadOnlyRepository[T]) SumByConditions(ctx context.Context, column string, conds ...interface{}) (float64, error) {
+	return r.repo.SumByConditions(ctx, column, conds...)
+}
+
+// AvgByConditions averages column over the entities matching conds
+func (r *ReadOnlyRepository[T]) AvgByConditions(ctx context.Context, column string, conds ...interface{}) (float64, error) {
+	return r.repo.AvgByConditions(ctx, column, conds...)
+}
+
+// MinBy scans the smallest value of column into dest
+func (r *ReadOnlyRepository[T]) MinBy(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	return r.repo.MinBy(ctx, column, dest, conds...)
+}
+
+// MaxBy scans the largest value of column into dest
+func (r *ReadOnlyRepository[T]) MaxBy(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	return r.repo.MaxBy(ctx, column, dest, conds...)
+}
+
+// GroupBy computes aggregates per group of the entities matching conds
+func (r *ReadOnlyRepository[T]) GroupBy(ctx context.Context, groupCols []string, aggregates []Aggregate, conds ...interface{}) ([]map[string]interface{}, error) {
+	return r.repo.GroupBy(ctx, groupCols, aggregates, conds...)
+}
+
+// SampleByConditions loads a random sample of up to n entities matching conds
+func (r *ReadOnlyRepository[T]) SampleByConditions(ctx context.Context, n int, strategy SampleStrategy, dest *[]T, conds ...interface{}) error {
+	return r.repo.SampleByConditions(ctx, n, strategy, dest, conds...)
+}
+
+// EstimateCount returns an approximate number of entities matching conds
+func (r *ReadOnlyRepository[T]) EstimateCount(ctx context.Context, conds ...interface{}) (int64, error) {
+	return r.repo.EstimateCount(ctx, conds...)
+}
+
+// TableStats returns the statistics of the table
+func (r *ReadOnlyRepository[T]) TableStats(ctx context.Context) (*TableStats, error) {
+	return r.repo.TableStats(ctx)
+}
+
+// TakeByConditions finds first entity by conditions
+func (r *ReadOnlyRepository[T]) TakeByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
+	return r.repo.TakeByConditions(ctx, dest, conds...)
+}
+
+// LastByConditions finds last entity by conditions
+func (r *ReadOnlyRepository[T]) LastByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
+	return r.repo.LastByConditions(ctx, dest, conds...)
+}
+
+// Create is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) Create(ctx context.Context, entity *T) error {
+	return r.deny("Create")
+}
+
+// CreateInBatches is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) CreateInBatches(ctx context.Context, entities []T, batchSize int) error {
+	return r.deny("CreateInBatches")
+}
+
+// CreateInBatchesIgnoreConflicts is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) CreateInBatchesIgnoreConflicts(ctx context.Context, entities []T, batchSize int, conflictColumns ...string) (*IngestResult, error) {
+	return nil, r.deny("CreateInBatchesIgnoreConflicts")
+}
+
+// BulkInsertFast is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) BulkInsertFast(ctx context.Context, entities []T) (*IngestResult, error) {
+	return nil, r.deny("BulkInsertFast")
+}
+
+// FindOrCreateByConditions is denied on read-only repositories, even when the entity exists
+func (r *ReadOnlyRepository[T]) FindOrCreateByConditions(ctx context.Context, dest *T, attrs interface{}, conds ...interface{}) (bool, error) {
+	return false, r.deny("FindOrCreateByConditions")
+}
+
+// Update is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) Update(ctx context.Context, entity *T) error {
+	return r.deny("Update")
+}
+
+// UpdateByID is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) UpdateByID(ctx context.Context, entity *T, id models.EntityID) error {
+	return r.deny("UpdateByID")
+}
+
+// UpdateByConditions is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) UpdateByConditions(ctx context.Context, entity *T, conds ...interface{}) error {
+	return r.deny("UpdateByConditions")
+}
+
+// UpdateAllByConditions is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) UpdateAllByConditions(ctx context.Context, values map[string]interface{}, conds ...interface{}) (int64, error) {
+	return 0, r.deny("UpdateAllByConditions")
+}
+
+// UpdateIf is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) UpdateIf(ctx context.Context, id models.EntityID, expected map[string]interface{}, updates map[string]interface{}) (bool, error) {
+	return false, r.deny("UpdateIf")
+}
+
+// Upsert is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) Upsert(ctx context.Context, entity *T, conflict ConflictOptions) error {
+	return r.deny("Upsert")
+}
+
+// UpsertByID is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) UpsertByID(ctx context.Context, entity *T, id models.EntityID, conflict ConflictOptions) error {
+	return r.deny("UpsertByID")
+}
+
+// UpsertByConditions is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) UpsertByConditions(ctx context.Context, entity *T, conflict ConflictOptions, conds ...interface{}) error {
+	return r.deny("UpsertByConditions")
+}
+
+// UpsertInBatches is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) UpsertInBatches(ctx context.Context, entities []T, batchSize int, conflict ConflictOptions) error {
+	return r.deny("UpsertInBatches")
+}
+
+// UpsertInBatchesByConditions is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) UpsertInBatchesByConditions(ctx context.Context, entities []T, batchSize int, conflict ConflictOptions, conds ...interface{}) error {
+	return r.deny("UpsertInBatchesByConditions")
+}
+
+// DeleteByID is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) DeleteByID(ctx context.Context, id models.EntityID) error {
+	return r.deny("DeleteByID")
+}
+
+// DeleteByKey is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) DeleteByKey(ctx context.Context, key map[string]interface{}) error {
+	return r.deny("DeleteByKey")
+}
+
+// DeleteByConditions is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) DeleteByConditions(ctx context.Context, entity *T, conds ...interface{}) error {
+	return r.deny("DeleteByConditions")
+}
+
+// DeleteAllByConditions is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) DeleteAllByConditions(ctx context.Context, conds ...interface{}) (int64, error) {
+	return 0, r.deny("DeleteAllByConditions")
+}
+
+// DeleteInBatches is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) DeleteInBatches(ctx context.Context, entities []T, batchSize int) error {
+	return r.deny("DeleteInBatches")
+}
+
+// DeleteInBatchesByConditions is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) DeleteInBatchesByConditions(ctx context.Context, entities []T, batchSize int, conds ...interface{}) error {
+	return r.deny("DeleteInBatchesByConditions")
+}
+
+// SoftDeleteByID is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) SoftDeleteByID(ctx context.Context, id models.EntityID) error {
+	return r.deny("SoftDeleteByID")
+}
+
+// SoftDeleteByConditions is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) SoftDeleteByConditions(ctx context.Context, conds ...interface{}) (int64, error) {
+	return 0, r.deny("SoftDeleteByConditions")
+}
+
+// RestoreByID is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) RestoreByID(ctx context.Context, id models.EntityID) error {
+	return r.deny("RestoreByID")
+}
+
+// Begin is denied on read-only repositories, since the transaction it returns can write
+func (r *ReadOnlyRepository[T]) Begin(ctx context.Context) (*gorm.DB, error) {
+	return nil, r.deny("Begin")
+}
+
+// Commit is denied on read-only repositories
+func (r *ReadOnlyRepository[T]) Commit(ctx context.Context) error {
+	return r.deny("Commit")
+}
+
+// Rollback rolls back the transaction the repository is bound to
+func (r *ReadOnlyRepository[T]) Rollback(ctx context.Context) error {
+	return r.repo.Rollback(ctx)
+}
+
+// SavePoint creates a savepoint in the transaction the repository is bound to
+func (r *ReadOnlyRepository[T]) SavePoint(ctx context.Context, name string) error {
+	return r.repo.SavePoint(ctx, name)
+}
+
+// NewSavePoint creates a savepoint with a generated name in the transaction
+// the repository is bound to
+func (r *ReadOnlyRepository[T]) NewSavePoint(ctx context.Context) (string, error) {
+	return r.repo.NewSavePoint(ctx)
+}
+
+// RollbackTo rolls back to a savepoint of the transaction the repository is bound to
+func (r *ReadOnlyRepository[T]) RollbackTo(ctx context.Context, name string) error {
+	return r.repo.RollbackTo(ctx, name)
+}
+
+// WithTransaction runs fn in a transaction with a read-only repository
+func (r *ReadOnlyRepository[T]) WithTransaction(ctx context.Context, fn func(Repository[T]) error) error {
+	if fn == nil {
+		return r.repo.WithTransaction(ctx, nil)
+	}
+	return r.repo.WithTransaction(ctx, func(tx Repository[T]) error {
+		return fn(NewReadOnlyRepository(tx))
+	})
+}
+
+// WithTransactionOpts runs fn in a transaction related to the one in progress
+// by opts.Propagation with a read-only repository
+func (r *ReadOnlyRepository[T]) WithTransactionOpts(ctx context.Context, opts TxOptions, fn func(ctx context.Context, tx Repository[T]) error) error {
+	if fn == nil {
+		return r.repo.WithTransactionOpts(ctx, opts, nil)
+	}
+	return r.repo.WithTransactionOpts(ctx, opts, func(ctx context.Context, tx Repository[T]) error {
+		return fn(ctx, NewReadOnlyRepository(tx))
+	})
+}
+
+// WithTx returns the read-only repository bound to the transaction carried by ctx
+func (r *ReadOnlyRepository[T]) WithTx(ctx context.Context) Repository[T] {
+	return NewReadOnlyRepository(r.repo.WithTx(ctx))
+}
+
+// AfterCommit registers fn to run once the surrounding transaction commits
+func (r *ReadOnlyRepository[T]) AfterCommit(ctx context.Context, fn TxHook) {
+	r.repo.AfterCommit(ctx, fn)
+}
+
+// AfterRollback registers fn to run if the surrounding transaction rolls back
+func (r *ReadOnlyRepository[T]) AfterRollback(ctx context.Context, fn TxHook) {
+	r.repo.AfterRollback(ctx, fn)
+}
@@ -0,0 +1,252 @@
+// Command mockgen generates the Repository mock of repositorymock from the
+// interfaces of the repository package. It is run by go generate.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const repositoryImport = "github.com/seasbee/go-ormx/pkg/repository"
+
+// method is a method of the mocked interface
+type method struct {
+	Name    string
+	Params  []param
+	Results []string
+}
+
+// param is a parameter of a method
+type param struct {
+	Name     string
+	Type     string
+	Variadic bool
+}
+
+func main() {
+	source := flag.String("source", "../base.go", "file declaring the repository interfaces")
+	out := flag.String("out", "repository.go", "generated file")
+	name := flag.String("interface", "Repository", "interface to mock")
+	flag.Parse()
+
+	if err := run(*source, *out, *name); err != nil {
+		fmt.Fprintf(os.Stderr, "mockgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(source, out, name string) error {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, source, nil, parser.SkipObjectResolution)
+	if err != nil {
+		return err
+	}
+
+	interfaces := make(map[string]*ast.InterfaceType)
+	var typeParam string
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			spec := spec.(*ast.TypeSpec)
+			if iface, ok := spec.Type.(*ast.InterfaceType); ok {
+				interfaces[spec.Name.Name] = iface
+				if spec.Name.Name == name && spec.TypeParams != nil {
+					typeParam = spec.TypeParams.List[0].Names[0].Name
+				}
+			}
+		}
+	}
+	if interfaces[name] == nil {
+		return fmt.Errorf("interface %s not found in %s", name, source)
+	}
+
+	methods, err := collect(fset, interfaces, name, typeParam)
+	if err != nil {
+		return err
+	}
+	code, err := render(file, methods, typeParam)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(out, code, 0o644)
+}
+
+// collect returns the methods of the interface, expanding the interfaces it
+// embeds
+func collect(fset *token.FileSet, interfaces map[string]*ast.InterfaceType, name, typeParam string) ([]method, error) {
+	var methods []method
+	for _, field := range interfaces[name].Methods.List {
+		switch t := field.Type.(type) {
+		case *ast.FuncType:
+			m := method{Name: field.Names[0].Name}
+			for _, p := range t.Params.List {
+				typ, variadic := p.Type, false
+				if ellipsis, ok := typ.(*ast.Ellipsis); ok {
+					typ, variadic = ellipsis.Elt, true
+				}
+				for _, n := range p.Names {
+					m.Params = append(m.Params, param{Name: n.Name, Type: qualify(fset, typ, typeParam), Variadic: variadic})
+				}
+			}
+			if t.Results != nil {
+				for _, r := range t.Results.List {
+					for range max(len(r.Names), 1) {
+						m.Results = append(m.Results, qualify(fset, r.Type, typeParam))
+					}
+				}
+			}
+			methods = append(methods, m)
+		case *ast.IndexExpr:
+			embedded, ok := t.X.(*ast.Ident)
+			if !ok || interfaces[embedded.Name] == nil {
+				return nil, fmt.Errorf("cannot expand embedded interface %s", types(fset, t))
+			}
+			expanded, err := collect(fset, interfaces, embedded.Name, typeParam)
+			if err != nil {
+				return nil, err
+			}
+			methods = append(methods, expanded...)
+		default:
+			return nil, fmt.Errorf("unsupported interface element %s", types(fset, t))
+		}
+	}
+	return methods, nil
+}
+
+// exported matches the identifiers declared by the repository package in a
+// type, those not selected from another package
+var exported = regexp.MustCompile(`(^|[^.\w])([A-Z]\w*)`)
+
+// qualify returns the source of a type of the repository package as seen
+// from another package
+func qualify(fset *token.FileSet, expr ast.Expr, typeParam string) string {
+	return exported.ReplaceAllStringFunc(types(fset, expr), func(match string) string {
+		parts := exported.FindStringSubmatch(match)
+		if parts[2] == typeParam {
+			return match
+		}
+		return parts[1] + "repository." + parts[2]
+	})
+}
+
+// types returns the source of a type
+func types(fset *token.FileSet, expr ast.Expr) string {
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, fset, expr)
+	return buf.String()
+}
+
+// standard reports whether path is a package of the standard library
+func standard(path string) bool {
+	return !strings.Contains(strings.Split(path, "/")[0], ".")
+}
+
+// render returns the source of the mock
+func render(file *ast.File, methods []method, typeParam string) ([]byte, error) {
+	var body bytes.Buffer
+	for _, m := range methods {
+		renderMethod(&body, m, typeParam)
+	}
+
+	// Import the packages the methods refer to
+	code := body.String()
+	imports := []string{repositoryImport}
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		name := path[strings.LastIndex(path, "/")+1:]
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if strings.Contains(code, name+".") {
+			imports = append(imports, path)
+		}
+	}
+	// Standard packages first, as goimports groups them
+	sort.Slice(imports, func(i, j int) bool {
+		if std := standard(imports[i]); std != standard(imports[j]) {
+			return std
+		}
+		return imports[i] < imports[j]
+	})
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by mockgen from the repository interfaces. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package repositorymock\n\nimport (\n")
+	for i, path := range imports {
+		if i > 0 && standard(imports[i-1]) && !standard(path) {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "\t%q\n", path)
+	}
+	fmt.Fprintf(&buf, ")\n\n")
+	fmt.Fprintf(&buf, "// Repository is a mock of repository.Repository[%[1]s]\ntype Repository[%[1]s any] struct {\n\tMock\n}\n\n", typeParam)
+	fmt.Fprintf(&buf, "var _ repository.Repository[struct{}] = (*Repository[struct{}])(nil)\n\n")
+	fmt.Fprintf(&buf, "// New returns a mock whose expectations are asserted when the test of t ends\n")
+	fmt.Fprintf(&buf, "func New[%[1]s any](t TestingT) *Repository[%[1]s] {\n\tm := &Repository[%[1]s]{}\n\tm.init(t)\n\treturn m\n}\n", typeParam)
+	buf.WriteString(code)
+
+	return format.Source(buf.Bytes())
+}
+
+// renderMethod writes the mock implementation of m
+func renderMethod(buf *bytes.Buffer, m method, typeParam string) {
+	params := make([]string, len(m.Params))
+	var fixed []string
+	var variadic *param
+	for i, p := range m.Params {
+		if p.Variadic {
+			params[i] = p.Name + " ..." + p.Type
+			variadic = &m.Params[i]
+			continue
+		}
+		params[i] = p.Name + " " + p.Type
+		fixed = append(fixed, p.Name)
+	}
+
+	results := strings.Join(m.Results, ", ")
+	if len(m.Results) > 1 {
+		results = "(" + results + ")"
+	}
+
+	fmt.Fprintf(buf, "\n// %s records the call and returns the values of the matching expectation\n", m.Name)
+	fmt.Fprintf(buf, "func (m *Repository[%s]) %s(%s) %s {\n", typeParam, m.Name, strings.Join(params, ", "), results)
+	call := fmt.Sprintf("m.Called(%q, %s)", m.Name, strings.Join(fixed, ", "))
+	if variadic != nil {
+		fmt.Fprintf(buf, "\targs := []interface{}{%s}\n", strings.Join(fixed, ", "))
+		if variadic.Type == "interface{}" {
+			fmt.Fprintf(buf, "\targs = append(args, %s...)\n", variadic.Name)
+		} else {
+			fmt.Fprintf(buf, "\tfor _, arg := range %s {\n\t\targs = append(args, arg)\n\t}\n", variadic.Name)
+		}
+		call = fmt.Sprintf("m.Called(%q, args...)", m.Name)
+	}
+	if len(m.Results) == 0 {
+		fmt.Fprintf(buf, "\t%s\n}\n", call)
+		return
+	}
+
+	fmt.Fprintf(buf, "\tresults := %s\n", call)
+	values := make([]string, len(m.Results))
+	for i, r := range m.Results {
+		if r == "error" {
+			values[i] = fmt.Sprintf("results.Error(%d)", i)
+		} else {
+			values[i] = fmt.Sprintf("Value[%s](results, %d)", r, i)
+		}
+	}
+	fmt.Fprintf(buf, "\treturn %s\n}\n", strings.Join(values, ", "))
+}
@@ -0,0 +1,229 @@
+// Package repositorymock provides a mock of repository.Repository[T] for the
+// unit tests of services asserting how a repository is called:
+//
+//	users := repositorymock.New[User](t)
+//	users.On("FindFirstByID", repositorymock.Anything, id).Return(&User{ID: id}, nil).Once()
+//	users.On("Update", repositorymock.Anything, repositorymock.MatchedBy(func(u *User) bool {
+//		return u.Email == "new@example.com"
+//	})).Return(nil)
+//
+// Expectations are checked when the test ends. The Repository type is
+// generated from the repository interface by go generate.
+package repositorymock
+
+//go:generate go run ./internal/mockgen -source ../base.go -out repository.go
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// TestingT is the part of testing.TB used by mocks
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Matcher matches an argument of a call in place of a value
+type Matcher func(arg interface{}) bool
+
+// Anything matches any argument
+var Anything Matcher = func(interface{}) bool { return true }
+
+// MatchedBy returns a matcher of the arguments of type A accepted by fn
+func MatchedBy[A any](fn func(A) bool) Matcher {
+	return func(arg interface{}) bool {
+		value, ok := arg.(A)
+		return ok && fn(value)
+	}
+}
+
+// Call is an expected call of a method, registered with Mock.On
+type Call struct {
+	Method string
+	Args   []interface{}
+
+	returns []interface{}
+	run     func(args []interface{})
+	times   int
+	calls   int
+}
+
+// Return sets the values returned by the call
+func (c *Call) Return(values ...interface{}) *Call {
+	c.returns = values
+	return c
+}
+
+// Run sets a function run with the arguments of the call before it returns,
+// e.g. to fill a destination argument
+func (c *Call) Run(fn func(args []interface{})) *Call {
+	c.run = fn
+	return c
+}
+
+// Once expects the call once
+func (c *Call) Once() *Call {
+	return c.Times(1)
+}
+
+// Times expects the call n times; by default it is expected at least once
+// and may repeat
+func (c *Call) Times(n int) *Call {
+	c.times = n
+	return c
+}
+
+// matches reports whether the call expects the arguments
+func (c *Call) matches(method string, args []interface{}) bool {
+	if c.Method != method || len(c.Args) != len(args) || (c.times > 0 && c.calls >= c.times) {
+		return false
+	}
+	for i, expected := range c.Args {
+		if matcher, ok := expected.(Matcher); ok {
+			if !matcher(args[i]) {
+				return false
+			}
+		} else if !reflect.DeepEqual(expected, args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Invocation is a call received by a mock
+type Invocation struct {
+	Method string
+	Args   []interface{}
+}
+
+// Results are the values returned by a call
+type Results struct {
+	values []interface{}
+	err    error
+}
+
+// Error returns the error at index i, or the error of an unexpected call
+func (r Results) Error(i int) error {
+	if r.err != nil {
+		return r.err
+	}
+	if i >= len(r.values) || r.values[i] == nil {
+		return nil
+	}
+	if err, ok := r.values[i].(error); ok {
+		return err
+	}
+	panic(fmt.Sprintf("repositorymock: return value %d is %T, not an error", i, r.values[i]))
+}
+
+// Value returns the value at index i of results, the zero value if missing
+func Value[V any](r Results, i int) V {
+	var zero V
+	if i >= len(r.values) || r.values[i] == nil {
+		return zero
+	}
+	value, ok := r.values[i].(V)
+	if !ok {
+		panic(fmt.Sprintf("repositorymock: return value %d is %T, not %T", i, r.values[i], zero))
+	}
+	return value
+}
+
+// Mock records the expected and received calls of a mock
+type Mock struct {
+	t        TestingT
+	mu       sync.Mutex
+	expected []*Call
+	calls    []Invocation
+}
+
+// On registers an expected call of method with args, values or matchers.
+// Variadic arguments are expected one by one.
+func (m *Mock) On(method string, args ...interface{}) *Call {
+	call := &Call{Method: method, Args: args}
+	m.mu.Lock()
+	m.expected = append(m.expected, call)
+	m.mu.Unlock()
+	return call
+}
+
+// Called records a call of method and returns the values of the first
+// matching expectation. An unexpected call fails the test and returns an
+// error.
+func (m *Mock) Called(method string, args ...interface{}) Results {
+	m.mu.Lock()
+	m.calls = append(m.calls, Invocation{Method: method, Args: args})
+	var call *Call
+	for _, expected := range m.expected {
+		if expected.matches(method, args) {
+			call = expected
+			call.calls++
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	if call == nil {
+		err := fmt.Errorf("repositorymock: unexpected call %s%v", method, args)
+		if m.t != nil {
+			m.t.Helper()
+			m.t.Errorf("%v", err)
+		}
+		return Results{err: err}
+	}
+	if call.run != nil {
+		call.run(args)
+	}
+	return Results{values: call.returns}
+}
+
+// Calls returns the calls received by the mock
+func (m *Mock) Calls() []Invocation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Invocation(nil), m.calls...)
+}
+
+// AssertExpectations fails t for each expected call not received as many
+// times as expected, and reports whether all were
+func (m *Mock) AssertExpectations(t TestingT) bool {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ok := true
+	for _, call := range m.expected {
+		if (call.times == 0 && call.calls == 0) || (call.times > 0 && call.calls != call.times) {
+			expected := "at least once"
+			if call.times > 0 {
+				expected = fmt.Sprintf("%d times", call.times)
+			}
+			t.Errorf("repositorymock: expected %s%v %s, called %d times", call.Method, call.Args, expected, call.calls)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// AssertNotCalled fails t if method was called, and reports whether it was not
+func (m *Mock) AssertNotCalled(t TestingT, method string) bool {
+	t.Helper()
+	for _, call := range m.Calls() {
+		if call.Method == method {
+			t.Errorf("repositorymock: unexpected call %s%v", method, call.Args)
+			return false
+		}
+	}
+	return true
+}
+
+// init binds the mock to t, asserting its expectations when the test ends
+// if t supports cleanups
+func (m *Mock) init(t TestingT) {
+	m.t = t
+	if cleanup, ok := t.(interface{ Cleanup(func()) }); ok {
+		cleanup.Cleanup(func() { m.AssertExpectations(t) })
+	}
+}
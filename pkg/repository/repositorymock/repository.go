@@ -0,0 +1,536 @@
+// Code generated by mockgen from the repository interfaces. DO NOT EDIT.
+
+package repositorymock
+
+import (
+	"context"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"gorm.io/gorm"
+)
+
+// Repository is a mock of repository.Repository[T]
+type Repository[T any] struct {
+	Mock
+}
+
+var _ repository.Repository[struct{}] = (*Repository[struct{}])(nil)
+
+// New returns a mock whose expectations are asserted when the test of t ends
+func New[T any](t TestingT) *Repository[T] {
+	m := &Repository[T]{}
+	m.init(t)
+	return m
+}
+
+// FindFirstByID records the call and returns the values of the matching expectation
+func (m *Repository[T]) FindFirstByID(ctx context.Context, id models.EntityID, opts ...repository.FindOption) (*T, error) {
+	args := []interface{}{ctx, id}
+	for _, arg := range opts {
+		args = append(args, arg)
+	}
+	results := m.Called("FindFirstByID", args...)
+	return Value[*T](results, 0), results.Error(1)
+}
+
+// FindAllByIDs records the call and returns the values of the matching expectation
+func (m *Repository[T]) FindAllByIDs(ctx context.Context, ids []models.EntityID, opts ...repository.FindOption) (map[models.EntityID]*T, error) {
+	args := []interface{}{ctx, ids}
+	for _, arg := range opts {
+		args = append(args, arg)
+	}
+	results := m.Called("FindAllByIDs", args...)
+	return Value[map[models.EntityID]*T](results, 0), results.Error(1)
+}
+
+// FindFirstByKey records the call and returns the values of the matching expectation
+func (m *Repository[T]) FindFirstByKey(ctx context.Context, key map[string]interface{}, opts ...repository.FindOption) (*T, error) {
+	args := []interface{}{ctx, key}
+	for _, arg := range opts {
+		args = append(args, arg)
+	}
+	results := m.Called("FindFirstByKey", args...)
+	return Value[*T](results, 0), results.Error(1)
+}
+
+// FindFirstByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) FindFirstByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
+	args := []interface{}{ctx, dest}
+	args = append(args, conds...)
+	results := m.Called("FindFirstByConditions", args...)
+	return results.Error(0)
+}
+
+// FindFirstBy records the call and returns the values of the matching expectation
+func (m *Repository[T]) FindFirstBy(ctx context.Context, dest *T, column string, value interface{}) error {
+	results := m.Called("FindFirstBy", ctx, dest, column, value)
+	return results.Error(0)
+}
+
+// FirstOrInitByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) FirstOrInitByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
+	args := []interface{}{ctx, dest}
+	args = append(args, conds...)
+	results := m.Called("FirstOrInitByConditions", args...)
+	return results.Error(0)
+}
+
+// FindAllWithOffset records the call and returns the values of the matching expectation
+func (m *Repository[T]) FindAllWithOffset(ctx context.Context, limit int, offset int, dest *[]T, opts ...repository.FindOption) error {
+	args := []interface{}{ctx, limit, offset, dest}
+	for _, arg := range opts {
+		args = append(args, arg)
+	}
+	results := m.Called("FindAllWithOffset", args...)
+	return results.Error(0)
+}
+
+// FindAllInBatchesWithOffset records the call and returns the values of the matching expectation
+func (m *Repository[T]) FindAllInBatchesWithOffset(ctx context.Context, limit int, offset int, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, opts ...repository.FindOption) error {
+	args := []interface{}{ctx, limit, offset, dest, batchSize, fc}
+	for _, arg := range opts {
+		args = append(args, arg)
+	}
+	results := m.Called("FindAllInBatchesWithOffset", args...)
+	return results.Error(0)
+}
+
+// FindAllByConditionsWithOffset records the call and returns the values of the matching expectation
+func (m *Repository[T]) FindAllByConditionsWithOffset(ctx context.Context, limit int, offset int, dest *[]T, conds ...interface{}) error {
+	args := []interface{}{ctx, limit, offset, dest}
+	args = append(args, conds...)
+	results := m.Called("FindAllByConditionsWithOffset", args...)
+	return results.Error(0)
+}
+
+// FindAllInBatchesByConditionsWithOffset records the call and returns the values of the matching expectation
+func (m *Repository[T]) FindAllInBatchesByConditionsWithOffset(ctx context.Context, limit int, offset int, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, conds ...interface{}) error {
+	args := []interface{}{ctx, limit, offset, dest, batchSize, fc}
+	args = append(args, conds...)
+	results := m.Called("FindAllInBatchesByConditionsWithOffset", args...)
+	return results.Error(0)
+}
+
+// FindAllWithCursor records the call and returns the values of the matching expectation
+func (m *Repository[T]) FindAllWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, opts ...repository.FindOption) error {
+	args := []interface{}{ctx, cursor, limit, direction, dest}
+	for _, arg := range opts {
+		args = append(args, arg)
+	}
+	results := m.Called("FindAllWithCursor", args...)
+	return results.Error(0)
+}
+
+// FindAllInBatchesWithCursor records the call and returns the values of the matching expectation
+func (m *Repository[T]) FindAllInBatchesWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, opts ...repository.FindOption) error {
+	args := []interface{}{ctx, cursor, limit, direction, dest, batchSize, fc}
+	for _, arg := range opts {
+		args = append(args, arg)
+	}
+	results := m.Called("FindAllInBatchesWithCursor", args...)
+	return results.Error(0)
+}
+
+// FindAllByConditionsWithCursor records the call and returns the values of the matching expectation
+func (m *Repository[T]) FindAllByConditionsWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, conds ...interface{}) error {
+	args := []interface{}{ctx, cursor, limit, direction, dest}
+	args = append(args, conds...)
+	results := m.Called("FindAllByConditionsWithCursor", args...)
+	return results.Error(0)
+}
+
+// FindAllInBatchesByConditionsWithCursor records the call and returns the values of the matching expectation
+func (m *Repository[T]) FindAllInBatchesByConditionsWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, conds ...interface{}) error {
+	args := []interface{}{ctx, cursor, limit, direction, dest, batchSize, fc}
+	args = append(args, conds...)
+	results := m.Called("FindAllInBatchesByConditionsWithCursor", args...)
+	return results.Error(0)
+}
+
+// FindAllIncludingDeleted records the call and returns the values of the matching expectation
+func (m *Repository[T]) FindAllIncludingDeleted(ctx context.Context, limit int, offset int, dest *[]T, opts ...repository.FindOption) error {
+	args := []interface{}{ctx, limit, offset, dest}
+	for _, arg := range opts {
+		args = append(args, arg)
+	}
+	results := m.Called("FindAllIncludingDeleted", args...)
+	return results.Error(0)
+}
+
+// ExistsByID records the call and returns the values of the matching expectation
+func (m *Repository[T]) ExistsByID(ctx context.Context, id models.EntityID) (bool, error) {
+	results := m.Called("ExistsByID", ctx, id)
+	return Value[bool](results, 0), results.Error(1)
+}
+
+// ExistsByKey records the call and returns the values of the matching expectation
+func (m *Repository[T]) ExistsByKey(ctx context.Context, key map[string]interface{}) (bool, error) {
+	results := m.Called("ExistsByKey", ctx, key)
+	return Value[bool](results, 0), results.Error(1)
+}
+
+// ExistsByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) ExistsByConditions(ctx context.Context, conds ...interface{}) (bool, error) {
+	args := []interface{}{ctx}
+	args = append(args, conds...)
+	results := m.Called("ExistsByConditions", args...)
+	return Value[bool](results, 0), results.Error(1)
+}
+
+// CountByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) CountByConditions(ctx context.Context, conds ...interface{}) (int64, error) {
+	args := []interface{}{ctx}
+	args = append(args, conds...)
+	results := m.Called("CountByConditions", args...)
+	return Value[int64](results, 0), results.Error(1)
+}
+
+// PluckByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) PluckByConditions(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	args := []interface{}{ctx, column, dest}
+	args = append(args, conds...)
+	results := m.Called("PluckByConditions", args...)
+	return results.Error(0)
+}
+
+// DistinctPluckByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) DistinctPluckByConditions(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	args := []interface{}{ctx, column, dest}
+	args = append(args, conds...)
+	results := m.Called("DistinctPluckByConditions", args...)
+	return results.Error(0)
+}
+
+// TimeSeriesCount records the call and returns the values of the matching expectation
+func (m *Repository[T]) TimeSeriesCount(ctx context.Context, timeColumn string, interval dialect.TimeUnit, conds ...interface{}) ([]repository.TimeBucketCount, error) {
+	args := []interface{}{ctx, timeColumn, interval}
+	args = append(args, conds...)
+	results := m.Called("TimeSeriesCount", args...)
+	return Value[[]repository.TimeBucketCount](results, 0), results.Error(1)
+}
+
+// PercentileBy records the call and returns the values of the matching expectation
+func (m *Repository[T]) PercentileBy(ctx context.Context, column string, percentiles []float64, conds ...interface{}) ([]repository.PercentileValue, error) {
+	args := []interface{}{ctx, column, percentiles}
+	args = append(args, conds...)
+	results := m.Called("PercentileBy", args...)
+	return Value[[]repository.PercentileValue](results, 0), results.Error(1)
+}
+
+// HistogramBy records the call and returns the values of the matching expectation
+func (m *Repository[T]) HistogramBy(ctx context.Context, column string, bounds []float64, conds ...interface{}) ([]repository.HistogramBucket, error) {
+	args := []interface{}{ctx, column, bounds}
+	args = append(args, conds...)
+	results := m.Called("HistogramBy", args...)
+	return Value[[]repository.HistogramBucket](results, 0), results.Error(1)
+}
+
+// SumByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) SumByConditions(ctx context.Context, column string, conds ...interface{}) (float64, error) {
+	args := []interface{}{ctx, column}
+	args = append(args, conds...)
+	results := m.Called("SumByConditions", args...)
+	return Value[float64](results, 0), results.Error(1)
+}
+
+// AvgByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) AvgByConditions(ctx context.Context, column string, conds ...interface{}) (float64, error) {
+	args := []interface{}{ctx, column}
+	args = append(args, conds...)
+	results := m.Called("AvgByConditions", args...)
+	return Value[float64](results, 0), results.Error(1)
+}
+
+// MinBy records the call and returns the values of the matching expectation
+func (m *Repository[T]) MinBy(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	args := []interface{}{ctx, column, dest}
+	args = append(args, conds...)
+	results := m.Called("MinBy", args...)
+	return results.Error(0)
+}
+
+// MaxBy records the call and returns the values of the matching expectation
+func (m *Repository[T]) MaxBy(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	args := []interface{}{ctx, column, dest}
+	args = append(args, conds...)
+	results := m.Called("MaxBy", args...)
+	return results.Error(0)
+}
+
+// GroupBy records the call and returns the values of the matching expectation
+func (m *Repository[T]) GroupBy(ctx context.Context, groupCols []string, aggregates []repository.Aggregate, conds ...interface{}) ([]map[string]interface{}, error) {
+	args := []interface{}{ctx, groupCols, aggregates}
+	args = append(args, conds...)
+	results := m.Called("GroupBy", args...)
+	return Value[[]map[string]interface{}](results, 0), results.Error(1)
+}
+
+// SampleByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) SampleByConditions(ctx context.Context, n int, strategy repository.SampleStrategy, dest *[]T, conds ...interface{}) error {
+	args := []interface{}{ctx, n, strategy, dest}
+	args = append(args, conds...)
+	results := m.Called("SampleByConditions", args...)
+	return results.Error(0)
+}
+
+// EstimateCount records the call and returns the values of the matching expectation
+func (m *Repository[T]) EstimateCount(ctx context.Context, conds ...interface{}) (int64, error) {
+	args := []interface{}{ctx}
+	args = append(args, conds...)
+	results := m.Called("EstimateCount", args...)
+	return Value[int64](results, 0), results.Error(1)
+}
+
+// TableStats records the call and returns the values of the matching expectation
+func (m *Repository[T]) TableStats(ctx context.Context) (*repository.TableStats, error) {
+	results := m.Called("TableStats", ctx)
+	return Value[*repository.TableStats](results, 0), results.Error(1)
+}
+
+// TakeByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) TakeByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
+	args := []interface{}{ctx, dest}
+	args = append(args, conds...)
+	results := m.Called("TakeByConditions", args...)
+	return results.Error(0)
+}
+
+// LastByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) LastByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
+	args := []interface{}{ctx, dest}
+	args = append(args, conds...)
+	results := m.Called("LastByConditions", args...)
+	return results.Error(0)
+}
+
+// Create records the call and returns the values of the matching expectation
+func (m *Repository[T]) Create(ctx context.Context, entity *T) error {
+	results := m.Called("Create", ctx, entity)
+	return results.Error(0)
+}
+
+// CreateInBatches records the call and returns the values of the matching expectation
+func (m *Repository[T]) CreateInBatches(ctx context.Context, entities []T, batchSize int) error {
+	results := m.Called("CreateInBatches", ctx, entities, batchSize)
+	return results.Error(0)
+}
+
+// CreateInBatchesIgnoreConflicts records the call and returns the values of the matching expectation
+func (m *Repository[T]) CreateInBatchesIgnoreConflicts(ctx context.Context, entities []T, batchSize int, conflictColumns ...string) (*repository.IngestResult, error) {
+	args := []interface{}{ctx, entities, batchSize}
+	for _, arg := range conflictColumns {
+		args = append(args, arg)
+	}
+	results := m.Called("CreateInBatchesIgnoreConflicts", args...)
+	return Value[*repository.IngestResult](results, 0), results.Error(1)
+}
+
+// BulkInsertFast records the call and returns the values of the matching expectation
+func (m *Repository[T]) BulkInsertFast(ctx context.Context, entities []T) (*repository.IngestResult, error) {
+	results := m.Called("BulkInsertFast", ctx, entities)
+	return Value[*repository.IngestResult](results, 0), results.Error(1)
+}
+
+// FindOrCreateByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) FindOrCreateByConditions(ctx context.Context, dest *T, attrs interface{}, conds ...interface{}) (bool, error) {
+	args := []interface{}{ctx, dest, attrs}
+	args = append(args, conds...)
+	results := m.Called("FindOrCreateByConditions", args...)
+	return Value[bool](results, 0), results.Error(1)
+}
+
+// Update records the call and returns the values of the matching expectation
+func (m *Repository[T]) Update(ctx context.Context, entity *T) error {
+	results := m.Called("Update", ctx, entity)
+	return results.Error(0)
+}
+
+// UpdateByID records the call and returns the values of the matching expectation
+func (m *Repository[T]) UpdateByID(ctx context.Context, entity *T, id models.EntityID) error {
+	results := m.Called("UpdateByID", ctx, entity, id)
+	return results.Error(0)
+}
+
+// UpdateByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) UpdateByConditions(ctx context.Context, entity *T, conds ...interface{}) error {
+	args := []interface{}{ctx, entity}
+	args = append(args, conds...)
+	results := m.Called("UpdateByConditions", args...)
+	return results.Error(0)
+}
+
+// UpdateAllByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) UpdateAllByConditions(ctx context.Context, values map[string]interface{}, conds ...interface{}) (int64, error) {
+	args := []interface{}{ctx, values}
+	args = append(args, conds...)
+	results := m.Called("UpdateAllByConditions", args...)
+	return Value[int64](results, 0), results.Error(1)
+}
+
+// UpdateIf records the call and returns the values of the matching expectation
+func (m *Repository[T]) UpdateIf(ctx context.Context, id models.EntityID, expected map[string]interface{}, updates map[string]interface{}) (bool, error) {
+	results := m.Called("UpdateIf", ctx, id, expected, updates)
+	return Value[bool](results, 0), results.Error(1)
+}
+
+// Upsert records the call and returns the values of the matching expectation
+func (m *Repository[T]) Upsert(ctx context.Context, entity *T, conflict repository.ConflictOptions) error {
+	results := m.Called("Upsert", ctx, entity, conflict)
+	return results.Error(0)
+}
+
+// UpsertByID records the call and returns the values of the matching expectation
+func (m *Repository[T]) UpsertByID(ctx context.Context, entity *T, id models.EntityID, conflict repository.ConflictOptions) error {
+	results := m.Called("UpsertByID", ctx, entity, id, conflict)
+	return results.Error(0)
+}
+
+// UpsertByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) UpsertByConditions(ctx context.Context, entity *T, conflict repository.ConflictOptions, conds ...interface{}) error {
+	args := []interface{}{ctx, entity, conflict}
+	args = append(args, conds...)
+	results := m.Called("UpsertByConditions", args...)
+	return results.Error(0)
+}
+
+// UpsertInBatches records the call and returns the values of the matching expectation
+func (m *Repository[T]) UpsertInBatches(ctx context.Context, entities []T, batchSize int, conflict repository.ConflictOptions) error {
+	results := m.Called("UpsertInBatches", ctx, entities, batchSize, conflict)
+	return results.Error(0)
+}
+
+// UpsertInBatchesByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) UpsertInBatchesByConditions(ctx context.Context, entities []T, batchSize int, conflict repository.ConflictOptions, conds ...interface{}) error {
+	args := []interface{}{ctx, entities, batchSize, conflict}
+	args = append(args, conds...)
+	results := m.Called("UpsertInBatchesByConditions", args...)
+	return results.Error(0)
+}
+
+// DeleteByID records the call and returns the values of the matching expectation
+func (m *Repository[T]) DeleteByID(ctx context.Context, id models.EntityID) error {
+	results := m.Called("DeleteByID", ctx, id)
+	return results.Error(0)
+}
+
+// DeleteByKey records the call and returns the values of the matching expectation
+func (m *Repository[T]) DeleteByKey(ctx context.Context, key map[string]interface{}) error {
+	results := m.Called("DeleteByKey", ctx, key)
+	return results.Error(0)
+}
+
+// DeleteByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) DeleteByConditions(ctx context.Context, entity *T, conds ...interface{}) error {
+	args := []interface{}{ctx, entity}
+	args = append(args, conds...)
+	results := m.Called("DeleteByConditions", args...)
+	return results.Error(0)
+}
+
+// DeleteAllByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) DeleteAllByConditions(ctx context.Context, conds ...interface{}) (int64, error) {
+	args := []interface{}{ctx}
+	args = append(args, conds...)
+	results := m.Called("DeleteAllByConditions", args...)
+	return Value[int64](results, 0), results.Error(1)
+}
+
+// DeleteInBatches records the call and returns the values of the matching expectation
+func (m *Repository[T]) DeleteInBatches(ctx context.Context, entities []T, batchSize int) error {
+	results := m.Called("DeleteInBatches", ctx, entities, batchSize)
+	return results.Error(0)
+}
+
+// DeleteInBatchesByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) DeleteInBatchesByConditions(ctx context.Context, entities []T, batchSize int, conds ...interface{}) error {
+	args := []interface{}{ctx, entities, batchSize}
+	args = append(args, conds...)
+	results := m.Called("DeleteInBatchesByConditions", args...)
+	return results.Error(0)
+}
+
+// SoftDeleteByID records the call and returns the values of the matching expectation
+func (m *Repository[T]) SoftDeleteByID(ctx context.Context, id models.EntityID) error {
+	results := m.Called("SoftDeleteByID", ctx, id)
+	return results.Error(0)
+}
+
+// SoftDeleteByConditions records the call and returns the values of the matching expectation
+func (m *Repository[T]) SoftDeleteByConditions(ctx context.Context, conds ...interface{}) (int64, error) {
+	args := []interface{}{ctx}
+	args = append(args, conds...)
+	results := m.Called("SoftDeleteByConditions", args...)
+	return Value[int64](results, 0), results.Error(1)
+}
+
+// RestoreByID records the call and returns the values of the matching expectation
+func (m *Repository[T]) RestoreByID(ctx context.Context, id models.EntityID) error {
+	results := m.Called("RestoreByID", ctx, id)
+	return results.Error(0)
+}
+
+// Begin records the call and returns the values of the matching expectation
+func (m *Repository[T]) Begin(ctx context.Context) (*gorm.DB, error) {
+	results := m.Called("Begin", ctx)
+	return Value[*gorm.DB](results, 0), results.Error(1)
+}
+
+// Commit records the call and returns the values of the matching expectation
+func (m *Repository[T]) Commit(ctx context.Context) error {
+	results := m.Called("Commit", ctx)
+	return results.Error(0)
+}
+
+// Rollback records the call and returns the values of the matching expectation
+func (m *Repository[T]) Rollback(ctx context.Context) error {
+	results := m.Called("Rollback", ctx)
+	return results.Error(0)
+}
+
+// SavePoint records the call and returns the values of the matching expectation
+func (m *Repository[T]) SavePoint(ctx context.Context, name string) error {
+	results := m.Called("SavePoint", ctx, name)
+	return results.Error(0)
+}
+
+// NewSavePoint records the call and returns the values of the matching expectation
+func (m *Repository[T]) NewSavePoint(ctx context.Context) (string, error) {
+	results := m.Called("NewSavePoint", ctx)
+	return Value[string](results, 0), results.Error(1)
+}
+
+// RollbackTo records the call and returns the values of the matching expectation
+func (m *Repository[T]) RollbackTo(ctx context.Context, name string) error {
+	results := m.Called("RollbackTo", ctx, name)
+	return results.Error(0)
+}
+
+// WithTransaction records the call and returns the values of the matching expectation
+func (m *Repository[T]) WithTransaction(ctx context.Context, fn func(repository.Repository[T]) error) error {
+	results := m.Called("WithTransaction", ctx, fn)
+	return results.Error(0)
+}
+
+// WithTransactionOpts records the call and returns the values of the matching expectation
+func (m *Repository[T]) WithTransactionOpts(ctx context.Context, opts repository.TxOptions, fn func(ctx context.Context, tx repository.Repository[T]) error) error {
+	results := m.Called("WithTransactionOpts", ctx, opts, fn)
+	return results.Error(0)
+}
+
+// WithTx records the call and returns the values of the matching expectation
+func (m *Repository[T]) WithTx(ctx context.Context) repository.Repository[T] {
+	results := m.Called("WithTx", ctx)
+	return Value[repository.Repository[T]](results, 0)
+}
+
+// AfterCommit records the call and returns the values of the matching expectation
+func (m *Repository[T]) AfterCommit(ctx context.Context, fn repository.TxHook) {
+	m.Called("AfterCommit", ctx, fn)
+}
+
+// AfterRollback records the call and returns the values of the matching expectation
+func (m *Repository[T]) AfterRollback(ctx context.Context, fn repository.TxHook) {
+	m.Called("AfterRollback", ctx, fn)
+}
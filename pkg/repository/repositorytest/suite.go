@@ -0,0 +1,312 @@
+// Package repositorytest provides a conformance suite for Repository[T]
+// implementations. Custom implementations (mocks, cache decorators, sharded
+// wrappers) run it to verify they behave like the base implementation:
+//
+//	func TestMyRepository(t *testing.T) {
+//		repositorytest.Run(t, func(t *testing.T) repository.Repository[repositorytest.Entity] {
+//			return NewCachingRepository(newBaseRepository(t))
+//		})
+//	}
+package repositorytest
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"gorm.io/gorm"
+)
+
+// Entity is the model the conformance suite stores through the repository under test
+type Entity struct {
+	models.BaseModel
+	Name  string `gorm:"not null" json:"name"`
+	Score int    `gorm:"not null" json:"score"`
+}
+
+// TableName returns the table used by the conformance suite
+func (Entity) TableName() string {
+	return "repositorytest_entities"
+}
+
+// Factory returns a repository backed by an empty store. It is called once per
+// check so checks do not observe each other's data.
+type Factory func(t *testing.T) repository.Repository[Entity]
+
+// NewBaseFactory returns a Factory building base repositories on the
+// connections returned by newDB, migrating the Entity table first
+func NewBaseFactory(newDB func(t *testing.T) *gorm.DB) Factory {
+	return func(t *testing.T) repository.Repository[Entity] {
+		t.Helper()
+
+		db := newDB(t)
+		if err := db.AutoMigrate(&Entity{}); err != nil {
+			t.Fatalf("failed to migrate conformance table: %v", err)
+		}
+
+		logger := logging.NewLogger(logging.LogLevelError, io.Discard, &logging.TextFormatter{})
+		return repository.NewBaseRepository[Entity](db, logger, repository.DefaultRepositoryConfig())
+	}
+}
+
+// Run runs the conformance suite against repositories built by factory
+func Run(t *testing.T, factory Factory) {
+	checks := []struct {
+		name string
+		fn   func(t *testing.T, repo repository.Repository[Entity])
+	}{
+		{"CreateAssignsID", testCreateAssignsID},
+		{"FindByIDNotFound", testFindByIDNotFound},
+		{"FindByConditionsNotFound", testFindByConditionsNotFound},
+		{"FindBy", testFindBy},
+		{"Exists", testExists},
+		{"Count", testCount},
+		{"CreateInBatchesRules", testCreateInBatchesRules},
+		{"UpdateByID", testUpdateByID},
+		{"DeleteRules", testDeleteRules},
+		{"OffsetPagination", testOffsetPagination},
+		{"TransactionCommit", testTransactionCommit},
+		{"TransactionRollback", testTransactionRollback},
+	}
+
+	for _, check := range checks {
+		t.Run(check.name, func(t *testing.T) {
+			check.fn(t, factory(t))
+		})
+	}
+}
+
+func testCreateAssignsID(t *testing.T, repo repository.Repository[Entity]) {
+	ctx := context.Background()
+
+	entity := &Entity{Name: "created", Score: 1}
+	mustNoError(t, repo.Create(ctx, entity), "Create")
+	if entity.ID == uuid.Nil {
+		t.Fatalf("Create must assign an ID")
+	}
+
+	found, err := repo.FindFirstByID(ctx, entity.ID)
+	mustNoError(t, err, "FindFirstByID")
+	if found.Name != "created" || found.Score != 1 {
+		t.Errorf("FindFirstByID returned %+v, want the created entity", found)
+	}
+}
+
+func testFindByIDNotFound(t *testing.T, repo repository.Repository[Entity]) {
+	found, err := repo.FindFirstByID(context.Background(), uuid.New())
+	if !stderrors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("FindFirstByID of a missing ID must wrap gorm.ErrRecordNotFound, got %v", err)
+	}
+	if found != nil {
+		t.Errorf("FindFirstByID of a missing ID must return a nil entity, got %+v", found)
+	}
+}
+
+func testFindByConditionsNotFound(t *testing.T, repo repository.Repository[Entity]) {
+	var dest Entity
+	err := repo.FindFirstByConditions(context.Background(), &dest, "name = ?", "missing")
+	if !stderrors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("FindFirstByConditions without matches must wrap gorm.ErrRecordNotFound, got %v", err)
+	}
+}
+
+func testFindBy(t *testing.T, repo repository.Repository[Entity]) {
+	ctx := context.Background()
+
+	entity := &Entity{Name: "lookup", Score: 7}
+	mustNoError(t, repo.Create(ctx, entity), "Create")
+
+	var dest Entity
+	mustNoError(t, repo.FindFirstBy(ctx, &dest, "name", "lookup"), "FindFirstBy")
+	if dest.ID != entity.ID || dest.Score != 7 {
+		t.Errorf("FindFirstBy returned %+v, want the created entity", dest)
+	}
+
+	err := repo.FindFirstBy(ctx, &Entity{}, "name", "missing")
+	if !stderrors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("FindFirstBy without matches must wrap gorm.ErrRecordNotFound, got %v", err)
+	}
+}
+
+func testExists(t *testing.T, repo repository.Repository[Entity]) {
+	ctx := context.Background()
+
+	exists, err := repo.ExistsByID(ctx, uuid.New())
+	mustNoError(t, err, "ExistsByID of a missing ID")
+	if exists {
+		t.Errorf("ExistsByID of a missing ID must be false")
+	}
+
+	entity := &Entity{Name: "exists", Score: 1}
+	mustNoError(t, repo.Create(ctx, entity), "Create")
+
+	exists, err = repo.ExistsByID(ctx, entity.ID)
+	mustNoError(t, err, "ExistsByID")
+	if !exists {
+		t.Errorf("ExistsByID of a created entity must be true")
+	}
+
+	exists, err = repo.ExistsByConditions(ctx, "name = ?", "missing")
+	mustNoError(t, err, "ExistsByConditions")
+	if exists {
+		t.Errorf("ExistsByConditions without matches must be false")
+	}
+}
+
+func testCount(t *testing.T, repo repository.Repository[Entity]) {
+	ctx := context.Background()
+	createEntities(t, repo, 5)
+
+	count, err := repo.CountByConditions(ctx)
+	mustNoError(t, err, "CountByConditions")
+	if count != 5 {
+		t.Errorf("CountByConditions without conditions = %d, want 5", count)
+	}
+
+	count, err = repo.CountByConditions(ctx, "score >= ?", 3)
+	mustNoError(t, err, "CountByConditions")
+	if count != 2 {
+		t.Errorf("CountByConditions(score >= 3) = %d, want 2", count)
+	}
+}
+
+func testCreateInBatchesRules(t *testing.T, repo repository.Repository[Entity]) {
+	ctx := context.Background()
+
+	if err := repo.CreateInBatches(ctx, []Entity{}, 10); err == nil {
+		t.Errorf("CreateInBatches with no entities must fail")
+	}
+	if err := repo.CreateInBatches(ctx, []Entity{{Name: "a"}}, 0); err == nil {
+		t.Errorf("CreateInBatches with a non-positive batch size must fail")
+	}
+
+	entities := make([]Entity, 5)
+	for i := range entities {
+		entities[i] = Entity{Name: fmt.Sprintf("batch-%d", i), Score: i}
+	}
+	mustNoError(t, repo.CreateInBatches(ctx, entities, 2), "CreateInBatches")
+
+	count, err := repo.CountByConditions(ctx)
+	mustNoError(t, err, "CountByConditions")
+	if count != 5 {
+		t.Errorf("CreateInBatches stored %d entities, want 5", count)
+	}
+}
+
+func testUpdateByID(t *testing.T, repo repository.Repository[Entity]) {
+	ctx := context.Background()
+
+	entity := &Entity{Name: "before", Score: 1}
+	mustNoError(t, repo.Create(ctx, entity), "Create")
+
+	if err := repo.UpdateByID(ctx, entity, uuid.New()); err == nil {
+		t.Errorf("UpdateByID with a mismatching ID must fail")
+	}
+
+	entity.Name = "after"
+	mustNoError(t, repo.UpdateByID(ctx, entity, entity.ID), "UpdateByID")
+
+	found, err := repo.FindFirstByID(ctx, entity.ID)
+	mustNoError(t, err, "FindFirstByID")
+	if found.Name != "after" {
+		t.Errorf("UpdateByID did not persist the change, name = %q", found.Name)
+	}
+}
+
+func testDeleteRules(t *testing.T, repo repository.Repository[Entity]) {
+	ctx := context.Background()
+
+	if err := repo.DeleteByID(ctx, uuid.Nil); err == nil {
+		t.Errorf("DeleteByID with a nil ID must fail")
+	}
+	if err := repo.DeleteByConditions(ctx, &Entity{}); err == nil {
+		t.Errorf("DeleteByConditions without conditions must fail")
+	}
+
+	entity := &Entity{Name: "deleted", Score: 1}
+	mustNoError(t, repo.Create(ctx, entity), "Create")
+	mustNoError(t, repo.DeleteByID(ctx, entity.ID), "DeleteByID")
+
+	if _, err := repo.FindFirstByID(ctx, entity.ID); !stderrors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("FindFirstByID after DeleteByID must wrap gorm.ErrRecordNotFound, got %v", err)
+	}
+}
+
+func testOffsetPagination(t *testing.T, repo repository.Repository[Entity]) {
+	ctx := context.Background()
+	createEntities(t, repo, 5)
+
+	var page []Entity
+	mustNoError(t, repo.FindAllWithOffset(ctx, 2, 0, &page), "FindAllWithOffset")
+	if len(page) != 2 {
+		t.Errorf("FindAllWithOffset(limit 2) returned %d entities", len(page))
+	}
+
+	var past []Entity
+	mustNoError(t, repo.FindAllWithOffset(ctx, 2, 10, &past), "FindAllWithOffset past the end")
+	if len(past) != 0 {
+		t.Errorf("FindAllWithOffset past the end returned %d entities, want 0", len(past))
+	}
+}
+
+func testTransactionCommit(t *testing.T, repo repository.Repository[Entity]) {
+	ctx := context.Background()
+
+	if err := repo.WithTransaction(ctx, nil); err == nil {
+		t.Errorf("WithTransaction with a nil function must fail")
+	}
+
+	err := repo.WithTransaction(ctx, func(tx repository.Repository[Entity]) error {
+		return tx.Create(ctx, &Entity{Name: "committed", Score: 1})
+	})
+	mustNoError(t, err, "WithTransaction")
+
+	exists, err := repo.ExistsByConditions(ctx, "name = ?", "committed")
+	mustNoError(t, err, "ExistsByConditions")
+	if !exists {
+		t.Errorf("writes of a successful transaction must be visible after it returns")
+	}
+}
+
+func testTransactionRollback(t *testing.T, repo repository.Repository[Entity]) {
+	ctx := context.Background()
+	sentinel := stderrors.New("rollback requested")
+
+	err := repo.WithTransaction(ctx, func(tx repository.Repository[Entity]) error {
+		if err := tx.Create(ctx, &Entity{Name: "rolled-back", Score: 1}); err != nil {
+			return err
+		}
+		return sentinel
+	})
+	if !stderrors.Is(err, sentinel) {
+		t.Errorf("WithTransaction must return the function's error, got %v", err)
+	}
+
+	exists, err := repo.ExistsByConditions(ctx, "name = ?", "rolled-back")
+	mustNoError(t, err, "ExistsByConditions")
+	if exists {
+		t.Errorf("writes of a failed transaction must be rolled back")
+	}
+}
+
+// createEntities creates n entities with scores 0..n-1
+func createEntities(t *testing.T, repo repository.Repository[Entity], n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		mustNoError(t, repo.Create(context.Background(), &Entity{Name: fmt.Sprintf("entity-%d", i), Score: i}), "Create")
+	}
+}
+
+// mustNoError stops the check when err is not nil
+func mustNoError(t *testing.T, err error, operation string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s failed: %v", operation, err)
+	}
+}
@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// QueryStats reports the statements run for a request: their number, the
+// time spent in the database, the slowest of them and the rows they
+// returned. Statements counts the statements by verb and table, e.g.
+// "SELECT orders", so a summary repeated once per loaded entity gives away
+// an N+1 query.
+type QueryStats struct {
+	Queries      int64            `json:"queries"`
+	Errors       int64            `json:"errors"`
+	Duration     time.Duration    `json:"duration"`
+	Rows         int64            `json:"rows"`
+	RowsAffected int64            `json:"rows_affected"`
+	Slowest      time.Duration    `json:"slowest"`
+	SlowestQuery string           `json:"slowest_query,omitempty"`
+	Statements   map[string]int64 `json:"statements"`
+}
+
+// requestStatsKey is the context key of the statistics of a request
+type requestStatsKey struct{}
+
+// requestStats accumulates the statistics of the statements of a request,
+// which may run concurrently
+type requestStats struct {
+	mu    sync.Mutex
+	stats QueryStats
+}
+
+// StartRequestStats returns a context collecting the statistics of the
+// statements run with it, on databases using a RequestStatsCollector, until
+// CollectRequestStats reads them:
+//
+//	ctx = repository.StartRequestStats(r.Context())
+//	next.ServeHTTP(w, r.WithContext(ctx))
+//	if stats := repository.CollectRequestStats(ctx); stats.Queries > 20 {
+//		logger.Warn(ctx, "Chatty request", logging.Int64("queries", stats.Queries))
+//	}
+//
+// Starting again from a collecting context starts afresh.
+func StartRequestStats(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestStatsKey{}, &requestStats{
+		stats: QueryStats{Statements: make(map[string]int64)},
+	})
+}
+
+// CollectRequestStats returns the statistics collected so far for the
+// context returned by StartRequestStats, or zero statistics for a context
+// collecting none
+func CollectRequestStats(ctx context.Context) QueryStats {
+	collector := requestStatsFrom(ctx)
+	if collector == nil {
+		return QueryStats{Statements: map[string]int64{}}
+	}
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+
+	stats := collector.stats
+	stats.Statements = make(map[string]int64, len(collector.stats.Statements))
+	for summary, count := range collector.stats.Statements {
+		stats.Statements[summary] = count
+	}
+	return stats
+}
+
+// requestStatsFrom returns the statistics collected for ctx, nil when none
+func requestStatsFrom(ctx context.Context) *requestStats {
+	if ctx == nil {
+		return nil
+	}
+	collector, _ := ctx.Value(requestStatsKey{}).(*requestStats)
+	return collector
+}
+
+// record adds a finished statement to the statistics
+func (s *requestStats) record(sql string, elapsed time.Duration, rows int64, query, failed bool) {
+	summary, _ := summarizeStatement(sql)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Queries++
+	s.stats.Duration += elapsed
+	if failed {
+		s.stats.Errors++
+	}
+	if query {
+		s.stats.Rows += rows
+	} else {
+		s.stats.RowsAffected += rows
+	}
+	if elapsed > s.stats.Slowest || s.stats.SlowestQuery == "" {
+		s.stats.Slowest = elapsed
+		s.stats.SlowestQuery = sql
+	}
+	if summary != "" {
+		s.stats.Statements[summary]++
+	}
+}
+
+// requestStatsStartKey is the statement instance key of the time a
+// statement started
+const requestStatsStartKey = "ormx:request_stats_start"
+
+// RequestStatsCollector is the gorm plugin feeding the statistics of the
+// requests started with StartRequestStats. It times every create, query,
+// update, delete, row and raw statement whose context collects statistics,
+// and leaves the others alone.
+//
+//	db.Use(repository.NewRequestStatsCollector())
+//
+// The rows of Row and Rows statements are read by the caller after the
+// statement returned and are not counted.
+type RequestStatsCollector struct{}
+
+// NewRequestStatsCollector creates a request statistics collector
+func NewRequestStatsCollector() *RequestStatsCollector {
+	return &RequestStatsCollector{}
+}
+
+// Name returns the plugin name
+func (c *RequestStatsCollector) Name() string {
+	return "ormx:request_stats"
+}
+
+// Initialize registers the collector callbacks around every statement of db
+func (c *RequestStatsCollector) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	err := stderrors.Join(
+		callbacks.Create().Before("*").Register("ormx:request_stats_start_create", c.start),
+		callbacks.Create().After("*").Register("ormx:request_stats_create", c.finish(false)),
+		callbacks.Query().Before("*").Register("ormx:request_stats_start_query", c.start),
+		callbacks.Query().After("*").Register("ormx:request_stats_query", c.finish(true)),
+		callbacks.Update().Before("*").Register("ormx:request_stats_start_update", c.start),
+		callbacks.Update().After("*").Register("ormx:request_stats_update", c.finish(false)),
+		callbacks.Delete().Before("*").Register("ormx:request_stats_start_delete", c.start),
+		callbacks.Delete().After("*").Register("ormx:request_stats_delete", c.finish(false)),
+		callbacks.Row().Before("*").Register("ormx:request_stats_start_row", c.start),
+		callbacks.Row().After("*").Register("ormx:request_stats_row", c.finish(true)),
+		callbacks.Raw().Before("*").Register("ormx:request_stats_start_raw", c.start),
+		callbacks.Raw().After("*").Register("ormx:request_stats_raw", c.finish(false)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register request stats callbacks: %w", err)
+	}
+	return nil
+}
+
+// start records the start of the statements of collecting contexts
+func (c *RequestStatsCollector) start(db *gorm.DB) {
+	if requestStatsFrom(db.Statement.Context) == nil {
+		return
+	}
+	db.InstanceSet(requestStatsStartKey, time.Now())
+}
+
+// finish returns the callback recording the statements of collecting
+// contexts, whose rows are returned by queries and affected otherwise
+func (c *RequestStatsCollector) finish(query bool) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		collector := requestStatsFrom(db.Statement.Context)
+		if collector == nil {
+			return
+		}
+		value, ok := db.InstanceGet(requestStatsStartKey)
+		if !ok {
+			return
+		}
+		started, _ := value.(time.Time)
+		// Statements failing before reaching the database have no SQL
+		sql := db.Statement.SQL.String()
+		if sql == "" {
+			return
+		}
+		collector.record(sql, time.Since(started), db.Statement.RowsAffected, query, db.Error != nil && db.Error != gorm.ErrRecordNotFound)
+	}
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/config"
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"gorm.io/gorm"
+)
+
+// retryClassifier classifies the errors of retried and hand-written statements
+var retryClassifier = errors.NewErrorClassifier()
+
+// retry runs fn, retrying it with exponential backoff while it fails with a
+// transient error, as configured by RepositoryConfig.Retry. Statements inside
+// transactions are not retried: a failed statement aborts the transaction on
+// most databases, so the whole transaction has to be retried instead.
+func (r *BaseRepository[T]) retry(ctx context.Context, operation string, fn func() error) error {
+	retry := r.config.Retry
+	if retry == nil || !retry.Enabled || retry.MaxAttempts <= 1 || isTransaction(r.db) {
+		return fn()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= retry.MaxAttempts || !r.retryable(ctx, operation, err) {
+			return err
+		}
+
+		delay := retryDelay(retry, attempt)
+		r.metrics.RecordRetry(operation)
+		r.logger.Warn(ctx, "Retrying operation after transient error",
+			logging.String("table", r.tableName),
+			logging.String("operation", operation),
+			logging.Int("attempt", attempt),
+			logging.Duration("delay", delay),
+			logging.ErrorField("error", err))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable reports whether a failed statement may be retried. The configured
+// NonRetryableErrors and RetryableErrors patterns take precedence over the
+// error classifier; not found errors, cancelled contexts and shed
+// statements are final.
+func (r *BaseRepository[T]) retryable(ctx context.Context, operation string, err error) bool {
+	if ctx.Err() != nil || stderrors.Is(err, gorm.ErrRecordNotFound) || stderrors.Is(err, ErrLoadShed) ||
+		stderrors.Is(err, context.Canceled) || stderrors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, pattern := range r.config.Retry.NonRetryableErrors {
+		if strings.Contains(message, strings.ToLower(pattern)) {
+			return false
+		}
+	}
+	for _, pattern := range r.config.Retry.RetryableErrors {
+		if strings.Contains(message, strings.ToLower(pattern)) {
+			return true
+		}
+	}
+
+	return errors.IsSerializationFailure(err) || retryClassifier.ClassifyError(err, operation).IsRetryable()
+}
+
+// retryDelay returns the backoff before retrying after attempt failed:
+// InitialDelay grown by BackoffMultiplier per attempt and capped at MaxDelay.
+// With Jitter the delay is drawn uniformly from its upper half, so clients
+// failing together do not retry in lockstep.
+func retryDelay(retry *config.RetryConfig, attempt int) time.Duration {
+	multiplier := retry.BackoffMultiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(retry.InitialDelay) * math.Pow(multiplier, float64(attempt-1))
+	if retry.MaxDelay > 0 && delay > float64(retry.MaxDelay) {
+		delay = float64(retry.MaxDelay)
+	}
+	if retry.Jitter && delay > 0 {
+		delay = delay/2 + rand.Float64()*delay/2
+	}
+	return time.Duration(delay)
+}
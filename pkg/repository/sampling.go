@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"gorm.io/gorm"
+)
+
+// SampleStrategy represents how SampleByConditions selects rows
+type SampleStrategy string
+
+const (
+	// SampleAuto uses TABLESAMPLE when the dialect supports it and random ordering otherwise
+	SampleAuto SampleStrategy = ""
+	// SampleRandom orders matching rows randomly; refused above MaxSampleScanRows matches
+	SampleRandom SampleStrategy = "random"
+	// SampleTableSample samples table pages with TABLESAMPLE, falling back to SampleRandom.
+	// The result is approximate and may hold fewer than n rows.
+	SampleTableSample SampleStrategy = "tablesample"
+	// SampleReservoir streams matching rows and keeps a uniform reservoir of n;
+	// refused above MaxSampleScanRows matches
+	SampleReservoir SampleStrategy = "reservoir"
+)
+
+// SampleByConditions loads a random sample of up to n entities matching conds
+// into dest, for QA spot checks, training extracts and anonymized previews
+func (r *BaseRepository[T]) SampleByConditions(ctx context.Context, n int, strategy SampleStrategy, dest *[]T, conds ...interface{}) error {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "SampleByConditions", time.Since(start))
+	}()
+
+	n = r.validateLimit(n)
+
+	var err error
+	switch strategy {
+	case SampleAuto, SampleTableSample:
+		err = r.sampleTable(ctx, n, dest, conds)
+	case SampleRandom:
+		err = r.sampleRandom(ctx, n, dest, conds)
+	case SampleReservoir:
+		err = r.sampleReservoir(ctx, n, dest, conds)
+	default:
+		err = r.argumentError("SampleByConditions", fmt.Sprintf("unsupported sample strategy: %q", strategy))
+	}
+
+	if err != nil {
+		r.metrics.IncrementOperationsFor("SampleByConditions", false)
+		return r.wrapError(err, "SampleByConditions", "failed to sample entities")
+	}
+
+	r.metrics.IncrementOperationsFor("SampleByConditions", true)
+	return nil
+}
+
+// sampleQuery returns a read query over T restricted to conds
+func (r *BaseRepository[T]) sampleQuery(ctx context.Context, conds []interface{}) *gorm.DB {
+	query := r.readDB(ctx).Model(new(T))
+	if len(conds) > 0 {
+		query = query.Where(conds[0], conds[1:]...)
+	}
+	return query
+}
+
+// sampleRandom orders matching rows randomly, refusing to sort more than MaxSampleScanRows rows
+func (r *BaseRepository[T]) sampleRandom(ctx context.Context, n int, dest *[]T, conds []interface{}) error {
+	if r.config.MaxSampleScanRows > 0 {
+		var total int64
+		if err := r.sampleQuery(ctx, conds).Count(&total).Error; err != nil {
+			return err
+		}
+		if total > int64(r.config.MaxSampleScanRows) {
+			return fmt.Errorf("refusing to sort %d rows randomly (max %d): narrow the conditions or use the %s strategy",
+				total, r.config.MaxSampleScanRows, SampleTableSample)
+		}
+	}
+
+	return r.sampleQuery(ctx, conds).Order(r.dialect.Random()).Limit(n).Find(dest).Error
+}
+
+// sampleTable samples with TABLESAMPLE, oversampling the matching fraction so
+// that about n rows survive the conditions
+func (r *BaseRepository[T]) sampleTable(ctx context.Context, n int, dest *[]T, conds []interface{}) error {
+	sampler, ok := r.dialect.(dialect.TableSampler)
+	if !ok {
+		return r.sampleRandom(ctx, n, dest, conds)
+	}
+	if _, supported := sampler.TableSample(100); !supported {
+		return r.sampleRandom(ctx, n, dest, conds)
+	}
+
+	var total int64
+	if err := r.sampleQuery(ctx, conds).Count(&total).Error; err != nil {
+		return err
+	}
+	if total == 0 {
+		*dest = (*dest)[:0]
+		return nil
+	}
+
+	percent := float64(n) / float64(total) * 100 * 2
+	if percent >= 100 {
+		// Small tables are cheaper to sort than to sample
+		return r.sampleRandom(ctx, n, dest, conds)
+	}
+
+	clause, _ := sampler.TableSample(percent)
+	query := r.sampleQuery(ctx, conds).Table(fmt.Sprintf("%s %s", r.schemaTable(), clause))
+	return query.Limit(n).Find(dest).Error
+}
+
+// sampleReservoir streams matching rows and keeps a uniform sample of n using
+// reservoir sampling (algorithm R), refusing to scan more than
+// MaxSampleScanRows rows rather than sampling only the first ones
+func (r *BaseRepository[T]) sampleReservoir(ctx context.Context, n int, dest *[]T, conds []interface{}) error {
+	if r.config.MaxSampleScanRows > 0 {
+		var total int64
+		if err := r.sampleQuery(ctx, conds).Count(&total).Error; err != nil {
+			return err
+		}
+		if total > int64(r.config.MaxSampleScanRows) {
+			return fmt.Errorf("refusing to scan %d rows (max %d): narrow the conditions or use the %s strategy",
+				total, r.config.MaxSampleScanRows, SampleTableSample)
+		}
+	}
+
+	query := r.sampleQuery(ctx, conds)
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	reservoir := make([]T, 0, n)
+	seen := 0
+	for rows.Next() {
+		var item T
+		if err := query.ScanRows(rows, &item); err != nil {
+			return err
+		}
+		seen++
+
+		if len(reservoir) < n {
+			reservoir = append(reservoir, item)
+		} else if j := rand.IntN(seen); j < n {
+			reservoir[j] = item
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	*dest = reservoir
+	return nil
+}
@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"gorm.io/gorm"
+)
+
+// ErrNoTransaction is returned by operations that need the repository to be
+// bound to a transaction, such as savepoints
+var ErrNoTransaction = stderrors.New("repository is not bound to a transaction")
+
+// ErrSavepointsUnsupported is returned by savepoint operations when the gorm
+// driver of the transaction cannot set savepoints
+var ErrSavepointsUnsupported = stderrors.New("driver does not support savepoints")
+
+// savepointName matches the savepoint names accepted by every dialect
+var savepointName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
+// savepointSeq numbers the savepoints named by NewSavePoint
+var savepointSeq atomic.Uint64
+
+// SupportsSavepoints reports whether the gorm driver of db can set and roll
+// back to savepoints
+func SupportsSavepoints(db *gorm.DB) bool {
+	if db == nil || db.Dialector == nil {
+		return false
+	}
+	_, ok := db.Dialector.(gorm.SavePointerDialectorInterface)
+	return ok
+}
+
+// nextSavepointName returns a savepoint name unique within the process
+func nextSavepointName() string {
+	return fmt.Sprintf("ormx_sp_%d", savepointSeq.Add(1))
+}
+
+// savepointMark remembers the callbacks registered when a savepoint was taken
+type savepointMark struct {
+	name          string
+	afterCommit   int
+	afterRollback int
+	registered    map[string]bool
+}
+
+// mark records a savepoint, replacing an earlier one of the same name
+func (h *txHooks) mark(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.forget(name)
+	registered := make(map[string]bool, len(h.registered))
+	for key := range h.registered {
+		registered[key] = true
+	}
+	h.savepoints = append(h.savepoints, savepointMark{
+		name:          name,
+		afterCommit:   len(h.afterCommit),
+		afterRollback: len(h.afterRollback),
+		registered:    registered,
+	})
+}
+
+// rewind drops the callbacks registered since the savepoint, and the later
+// savepoints, returning the after rollback callbacks to run. It reports
+// false when the savepoint is unknown.
+func (h *txHooks) rewind(name string) ([]TxHook, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := len(h.savepoints) - 1; i >= 0; i-- {
+		mark := h.savepoints[i]
+		if mark.name != name {
+			continue
+		}
+		rolledBack := append([]TxHook(nil), h.afterRollback[mark.afterRollback:]...)
+		h.afterCommit = h.afterCommit[:mark.afterCommit]
+		h.afterRollback = h.afterRollback[:mark.afterRollback]
+		h.registered = mark.registered
+		// The savepoint itself survives, like in SQL
+		h.savepoints = h.savepoints[:i+1]
+		return rolledBack, true
+	}
+	return nil, false
+}
+
+// forget drops the savepoint named name; callers hold the lock
+func (h *txHooks) forget(name string) {
+	for i, mark := range h.savepoints {
+		if mark.name == name {
+			h.savepoints = append(h.savepoints[:i], h.savepoints[i+1:]...)
+			return
+		}
+	}
+}
+
+// setSavepoint sets the savepoint name in tx, remembering the transaction
+// callbacks registered so far in hooks
+func setSavepoint(ctx context.Context, tx *gorm.DB, hooks *txHooks, name string) error {
+	if err := tx.WithContext(ctx).SavePoint(name).Error; err != nil {
+		return err
+	}
+	if hooks != nil {
+		hooks.mark(name)
+	}
+	return nil
+}
+
+// rollbackToSavepoint rolls tx back to the savepoint name, dropping the
+// callbacks registered in hooks since and running its after rollback ones
+func rollbackToSavepoint(ctx context.Context, tx *gorm.DB, hooks *txHooks, name string, logger logging.Logger) error {
+	if err := tx.WithContext(ctx).RollbackTo(name).Error; err != nil {
+		return err
+	}
+	if hooks != nil {
+		if rolledBack, ok := hooks.rewind(name); ok {
+			for _, fn := range rolledBack {
+				runTxHook(ctx, fn, false, logger)
+			}
+		}
+	}
+	return nil
+}
+
+// checkSavepoint checks tx is a transaction whose driver supports savepoints
+// and name is a valid savepoint name, for operation on table
+func checkSavepoint(tx *gorm.DB, table, operation, name string) error {
+	if !isTransaction(tx) {
+		return ErrNoTransaction
+	}
+	if !SupportsSavepoints(tx) {
+		return ErrSavepointsUnsupported
+	}
+	if !savepointName.MatchString(name) {
+		return newError(errors.ErrorTypeValidation, table, operation, fmt.Sprintf("invalid savepoint name %q", name))
+	}
+	if name == cockroachRestartSavepoint {
+		return newError(errors.ErrorTypeValidation, table, operation, fmt.Sprintf("savepoint name %q is reserved", name))
+	}
+	return nil
+}
+
+// SavePoint sets a named savepoint in the transaction the repository is bound
+// to, the repository passed to WithTransaction's function. Rolling back to it
+// with RollbackTo undoes the work done since, so a failed sub-step of a
+// business transaction can be retried without abandoning the whole unit of
+// work. Names are SQL identifiers; reusing a name moves the savepoint. Drivers
+// without savepoints return ErrSavepointsUnsupported.
+func (r *BaseRepository[T]) SavePoint(ctx context.Context, name string) error {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "SavePoint", time.Since(start))
+	}()
+
+	if err := r.checkSavepoint("SavePoint", name); err != nil {
+		r.metrics.IncrementOperationsFor("SavePoint", false)
+		return err
+	}
+	if err := setSavepoint(ctx, r.db, r.hooks, name); err != nil {
+		r.metrics.IncrementOperationsFor("SavePoint", false)
+		return r.wrapError(err, "SavePoint", fmt.Sprintf("failed to set savepoint %s", name))
+	}
+
+	r.metrics.IncrementOperationsFor("SavePoint", true)
+	return nil
+}
+
+// NewSavePoint sets a savepoint with a generated name, unique within the
+// process, and returns the name to pass to RollbackTo
+func (r *BaseRepository[T]) NewSavePoint(ctx context.Context) (string, error) {
+	name := nextSavepointName()
+	if err := r.SavePoint(ctx, name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// RollbackTo rolls the transaction back to the savepoint named name, which
+// stays set for another attempt. The after commit callbacks registered since
+// the savepoint are dropped and its after rollback callbacks run, as for a
+// rolled back nested transaction.
+func (r *BaseRepository[T]) RollbackTo(ctx context.Context, name string) error {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "RollbackTo", time.Since(start))
+	}()
+
+	if err := r.checkSavepoint("RollbackTo", name); err != nil {
+		r.metrics.IncrementOperationsFor("RollbackTo", false)
+		return err
+	}
+	if err := rollbackToSavepoint(ctx, r.db, r.hooks, name, r.logger); err != nil {
+		r.metrics.IncrementOperationsFor("RollbackTo", false)
+		return r.wrapError(err, "RollbackTo", fmt.Sprintf("failed to roll back to savepoint %s", name))
+	}
+
+	r.metrics.IncrementOperationsFor("RollbackTo", true)
+	return nil
+}
+
+// checkSavepoint checks the repository is in a transaction supporting
+// savepoints and name is a valid savepoint name, for operation
+func (r *BaseRepository[T]) checkSavepoint(operation, name string) error {
+	return checkSavepoint(r.db, r.tableName, operation, name)
+}
@@ -0,0 +1,362 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// ErrLoadShed is the cause of the errors returned for statements rejected
+// by a LoadShedder
+var ErrLoadShed = stderrors.New("load shed")
+
+// Priority ranks operations for load shedding: the lowest priorities are
+// rejected first
+type Priority int
+
+// Operation priorities
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+	// PriorityCritical operations are never shed
+	PriorityCritical
+)
+
+// String returns the name of the priority
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	case PriorityCritical:
+		return "critical"
+	}
+	return fmt.Sprintf("priority(%d)", int(p))
+}
+
+// priorityKey is the context key of the priority of operations
+type priorityKey struct{}
+
+// WithPriority returns a context whose statements run with priority p
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFrom returns the priority of ctx, PriorityNormal by default
+func PriorityFrom(ctx context.Context) Priority {
+	if ctx != nil {
+		if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+			return p
+		}
+	}
+	return PriorityNormal
+}
+
+// ShedConfig configures a LoadShedder
+type ShedConfig struct {
+	// Window is the period over which error rates and latencies are
+	// measured; the shedding level is revised at the end of each (default 10s)
+	Window time.Duration `json:"window"`
+	// MinRequests is the number of statements of a window below which its
+	// signals are not trusted (default 20)
+	MinRequests int `json:"min_requests"`
+	// MaxErrorRate is the share of statements failing with connection,
+	// timeout, deadlock or resource errors above which shedding escalates
+	// (default 0.5)
+	MaxErrorRate float64 `json:"max_error_rate"`
+	// MaxLatency is the statement latency at LatencyPercentile above which
+	// shedding escalates; zero disables the latency signal
+	MaxLatency time.Duration `json:"max_latency"`
+	// LatencyPercentile is the percentile compared with MaxLatency (default 0.9)
+	LatencyPercentile float64 `json:"latency_percentile"`
+	// MaxShedPriority is the highest priority shed at the top level (default
+	// PriorityNormal); PriorityCritical is never shed
+	MaxShedPriority Priority `json:"max_shed_priority"`
+}
+
+// DefaultShedConfig returns default load shedder configuration
+func DefaultShedConfig() *ShedConfig {
+	return &ShedConfig{
+		Window:            10 * time.Second,
+		MinRequests:       20,
+		MaxErrorRate:      0.5,
+		LatencyPercentile: 0.9,
+		MaxShedPriority:   PriorityNormal,
+	}
+}
+
+// ShedStats represents the state of a load shedder, for tuning and dashboards
+type ShedStats struct {
+	// Level is the number of priorities shed, from the lowest: 0 sheds nothing
+	Level int `json:"level"`
+	// Requests, Errors, ErrorRate and Latency describe the current window
+	Requests  int64         `json:"requests"`
+	Errors    int64         `json:"errors"`
+	ErrorRate float64       `json:"error_rate"`
+	Latency   time.Duration `json:"latency"`
+	// Shed counts the statements rejected by priority since creation
+	Shed map[string]int64 `json:"shed"`
+}
+
+// LoadShedder is a gorm plugin protecting the database during brownouts.
+// It measures the rate of statements failing with connection, timeout,
+// deadlock or resource errors, and their latency, over successive windows.
+// When a signal breaches its threshold, the shedding level rises by one and
+// the statements of the lowest priorities are rejected before reaching the
+// database, with a retryable errors.ErrorTypeResource error wrapping
+// ErrLoadShed whose RetryDelay is the time left until the level is revised.
+// Each healthy window lowers the level by one, so traffic comes back
+// gradually.
+//
+//	shedder := repository.NewLoadShedder(nil)
+//	db.Use(shedder)
+//	ctx = repository.WithPriority(ctx, repository.PriorityLow) // reports, exports...
+//
+// Statements inside transactions are measured but never shed, so work in
+// progress is not abandoned halfway.
+type LoadShedder struct {
+	config *ShedConfig
+
+	mu          sync.Mutex
+	level       int
+	windowStart time.Time
+	requests    int64
+	errors      int64
+	latencies   *latencyRecorder
+	escalated   bool
+	shed        map[Priority]int64
+}
+
+// NewLoadShedder creates a load shedder
+func NewLoadShedder(config *ShedConfig) *LoadShedder {
+	defaults := DefaultShedConfig()
+	if config == nil {
+		config = defaults
+	}
+	if config.Window <= 0 {
+		config.Window = defaults.Window
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = defaults.MinRequests
+	}
+	if config.MaxErrorRate <= 0 {
+		config.MaxErrorRate = defaults.MaxErrorRate
+	}
+	if config.LatencyPercentile <= 0 || config.LatencyPercentile > 1 {
+		config.LatencyPercentile = defaults.LatencyPercentile
+	}
+	if config.MaxShedPriority >= PriorityCritical {
+		config.MaxShedPriority = PriorityHigh
+	}
+
+	return &LoadShedder{
+		config:      config,
+		windowStart: time.Now(),
+		latencies:   newLatencyRecorder(),
+		shed:        make(map[Priority]int64),
+	}
+}
+
+// Name returns the plugin name
+func (s *LoadShedder) Name() string {
+	return "ormx:load_shedder"
+}
+
+// Initialize registers the shedder callbacks around every statement of db
+func (s *LoadShedder) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	err := stderrors.Join(
+		callbacks.Create().Before("*").Register("ormx:shed_create", s.admit("create")),
+		callbacks.Create().After("*").Register("ormx:shed_record_create", s.record),
+		callbacks.Query().Before("*").Register("ormx:shed_query", s.admit("query")),
+		callbacks.Query().After("*").Register("ormx:shed_record_query", s.record),
+		callbacks.Update().Before("*").Register("ormx:shed_update", s.admit("update")),
+		callbacks.Update().After("*").Register("ormx:shed_record_update", s.record),
+		callbacks.Delete().Before("*").Register("ormx:shed_delete", s.admit("delete")),
+		callbacks.Delete().After("*").Register("ormx:shed_record_delete", s.record),
+		callbacks.Row().Before("*").Register("ormx:shed_row", s.admit("row")),
+		callbacks.Row().After("*").Register("ormx:shed_record_row", s.record),
+		callbacks.Raw().Before("*").Register("ormx:shed_raw", s.admit("raw")),
+		callbacks.Raw().After("*").Register("ormx:shed_record_raw", s.record),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register load shedder callbacks: %w", err)
+	}
+	return nil
+}
+
+// Stats returns the state of the shedder
+func (s *LoadShedder) Stats() ShedStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roll(time.Now())
+
+	stats := ShedStats{
+		Level:    s.level,
+		Requests: s.requests,
+		Errors:   s.errors,
+		Latency:  s.latency(),
+		Shed:     make(map[string]int64, len(s.shed)),
+	}
+	if s.requests > 0 {
+		stats.ErrorRate = float64(s.errors) / float64(s.requests)
+	}
+	for p, count := range s.shed {
+		stats.Shed[p.String()] = count
+	}
+	return stats
+}
+
+// Allow reports whether an operation of priority p would be admitted now
+func (s *LoadShedder) Allow(p Priority) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roll(time.Now())
+	return s.allows(p)
+}
+
+// shedStartKey is the statement setting of the time a statement was admitted
+const shedStartKey = "ormx:shed_start"
+
+// admit returns the callback rejecting the statements of shed priorities
+func (s *LoadShedder) admit(kind string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil {
+			return
+		}
+		p := PriorityFrom(db.Statement.Context)
+		now := time.Now()
+
+		s.mu.Lock()
+		s.roll(now)
+		allowed := s.allows(p) || isTransaction(db)
+		retryAfter := s.windowStart.Add(s.config.Window).Sub(now)
+		if !allowed {
+			s.shed[p]++
+		}
+		s.mu.Unlock()
+
+		if !allowed {
+			err := errors.New(errors.ErrorTypeResource, fmt.Sprintf("%s of priority %s shed under database load", kind, p)).
+				WithOperation(kind).
+				WithRetry(true, 0, max(retryAfter, 0))
+			err.Cause = ErrLoadShed
+			if db.Statement.Schema != nil {
+				err = err.WithTable(db.Statement.Schema.Table)
+			}
+			_ = db.AddError(err)
+			return
+		}
+		db.InstanceSet(shedStartKey, now)
+	}
+}
+
+// record measures an admitted statement
+func (s *LoadShedder) record(db *gorm.DB) {
+	value, ok := db.InstanceGet(shedStartKey)
+	if !ok {
+		return
+	}
+	start := value.(time.Time)
+	now := time.Now()
+	failed := distressed(db.Error)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roll(now)
+	s.requests++
+	if failed {
+		s.errors++
+	}
+	s.latencies.observe(now.Sub(start))
+
+	if !s.escalated && s.requests >= int64(s.config.MinRequests) && s.breached() {
+		s.escalate()
+		s.escalated = true
+	}
+}
+
+// allows reports whether priority p is admitted at the current level
+func (s *LoadShedder) allows(p Priority) bool {
+	return p >= PriorityCritical || int(p) >= s.level
+}
+
+// roll revises the level at the end of each window elapsed and starts a new
+// one: a breaching window not escalated yet raises the level, a healthy or
+// quiet one lowers it
+func (s *LoadShedder) roll(now time.Time) {
+	elapsed := int(now.Sub(s.windowStart) / s.config.Window)
+	if elapsed < 1 {
+		return
+	}
+	if !s.escalated {
+		if s.requests >= int64(s.config.MinRequests) && s.breached() {
+			s.escalate()
+		} else if s.level > 0 {
+			s.level--
+		}
+	}
+	// The windows elapsed without statements since were quiet
+	s.level = max(s.level-(elapsed-1), 0)
+
+	s.windowStart = s.windowStart.Add(time.Duration(elapsed) * s.config.Window)
+	s.requests, s.errors, s.escalated = 0, 0, false
+	s.latencies = newLatencyRecorder()
+}
+
+// escalate raises the level by one, up to shedding MaxShedPriority
+func (s *LoadShedder) escalate() {
+	if s.level <= int(s.config.MaxShedPriority) {
+		s.level++
+	}
+}
+
+// breached reports whether the signals of the current window breach their thresholds
+func (s *LoadShedder) breached() bool {
+	if s.requests == 0 {
+		return false
+	}
+	if float64(s.errors)/float64(s.requests) > s.config.MaxErrorRate {
+		return true
+	}
+	return s.config.MaxLatency > 0 && s.latency() > s.config.MaxLatency
+}
+
+// latency returns the latency of the current window at LatencyPercentile
+func (s *LoadShedder) latency() time.Duration {
+	if s.latencies.count == 0 {
+		return 0
+	}
+	indexes := make([]int32, 0, len(s.latencies.counts))
+	for index := range s.latencies.counts {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	return s.latencies.quantile(s.config.LatencyPercentile, indexes)
+}
+
+// distressed reports whether err signals database distress rather than a
+// problem of the statement itself
+func distressed(err error) bool {
+	if err == nil || stderrors.Is(err, gorm.ErrRecordNotFound) || stderrors.Is(err, ErrLoadShed) {
+		return false
+	}
+	if stderrors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	switch retryClassifier.ClassifyError(err, "").Type {
+	case errors.ErrorTypeConnection, errors.ErrorTypeTimeout, errors.ErrorTypeDeadlock, errors.ErrorTypeResource:
+		return true
+	}
+	return false
+}
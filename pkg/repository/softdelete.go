@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Delete modes selecting how Delete* methods remove entities
+const (
+	// DeleteModeAuto soft deletes entities with a gorm.DeletedAt field (as gorm
+	// does) and hard deletes all others
+	DeleteModeAuto = ""
+	// DeleteModeSoft sets deleted_at instead of removing rows
+	DeleteModeSoft = "soft"
+	// DeleteModeHard always removes rows
+	DeleteModeHard = "hard"
+)
+
+// softDeleteKind describes how an entity type supports soft delete
+type softDeleteKind int
+
+const (
+	// softDeleteNone means the entity has no deleted_at column
+	softDeleteNone softDeleteKind = iota
+	// softDeleteColumn means a nullable deleted_at column managed by the repository (models.BaseModel)
+	softDeleteColumn
+	// softDeleteGorm means a gorm.DeletedAt field, filtered by gorm itself
+	softDeleteGorm
+)
+
+// deletedAtColumn is the soft delete column of models.BaseModel
+const deletedAtColumn = "deleted_at"
+
+// includeDeletedKey is the context key for reads including soft deleted entities
+type includeDeletedKey struct{}
+
+// WithDeleted makes read operations executed with the returned context include
+// soft deleted entities
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, includeDeletedKey{}, true)
+}
+
+// includeDeleted reports whether ctx asks for soft deleted entities
+func includeDeleted(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	include, _ := ctx.Value(includeDeletedKey{}).(bool)
+	return include
+}
+
+// detectSoftDelete inspects the DeletedAt field of the entity type
+func detectSoftDelete(modelType reflect.Type) softDeleteKind {
+	if modelType == nil || modelType.Kind() != reflect.Struct {
+		return softDeleteNone
+	}
+
+	field, ok := modelType.FieldByName("DeletedAt")
+	if !ok {
+		return softDeleteNone
+	}
+	switch field.Type {
+	case reflect.TypeOf(gorm.DeletedAt{}):
+		return softDeleteGorm
+	case reflect.TypeOf(&time.Time{}):
+		return softDeleteColumn
+	}
+	return softDeleteNone
+}
+
+// scopeDeleted excludes soft deleted entities from a read query unless ctx includes them
+func (r *BaseRepository[T]) scopeDeleted(ctx context.Context, query *gorm.DB) *gorm.DB {
+	switch r.softDelete {
+	case softDeleteColumn:
+		if !includeDeleted(ctx) {
+			query = query.Where(clause.Expr{SQL: "? IS NULL", Vars: []interface{}{clause.Column{Table: clause.CurrentTable, Name: deletedAtColumn}}})
+		}
+	case softDeleteGorm:
+		if includeDeleted(ctx) {
+			query = query.Unscoped()
+		}
+	}
+	return query
+}
+
+// softDeleteEnabled reports whether Delete* methods soft delete entities
+func (r *BaseRepository[T]) softDeleteEnabled() bool {
+	switch r.config.DeleteMode {
+	case DeleteModeSoft:
+		return r.softDelete != softDeleteNone
+	case DeleteModeHard:
+		return false
+	default:
+		return r.softDelete == softDeleteGorm
+	}
+}
+
+// remove deletes value (optionally restricted by conds) according to the delete mode
+func (r *BaseRepository[T]) remove(query *gorm.DB, value interface{}, conds ...interface{}) error {
+	return r.removeRows(query, value, conds...).Error
+}
+
+// removeRows is remove returning the statement result, for callers reporting
+// the number of affected rows
+func (r *BaseRepository[T]) removeRows(query *gorm.DB, value interface{}, conds ...interface{}) *gorm.DB {
+	if !r.softDeleteEnabled() {
+		return query.Unscoped().Delete(value, conds...)
+	}
+	if r.softDelete == softDeleteGorm {
+		return query.Delete(value, conds...)
+	}
+
+	if len(conds) > 0 {
+		query = query.Where(conds[0], conds[1:]...)
+	}
+	return query.Model(value).Where(deletedAtColumn+" IS NULL").UpdateColumn(deletedAtColumn, time.Now())
+}
+
+// SoftDeleteByID marks the entity as deleted without removing its row,
+// regardless of the repository delete mode
+func (r *BaseRepository[T]) SoftDeleteByID(ctx context.Context, id models.EntityID) error {
+	id = normalizeID(id, keyTypeOf[T]())
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "SoftDeleteByID", time.Since(start))
+	}()
+
+	if isZeroID(id) {
+		r.metrics.IncrementOperationsFor("SoftDeleteByID", false)
+		return r.argumentError("SoftDeleteByID", "ID cannot be nil")
+	}
+
+	before := r.snapshot(ctx, id)
+	affected, err := r.softDeleteWhere(ctx, "SoftDeleteByID", "id = ?", id)
+	if err != nil {
+		r.metrics.IncrementOperationsFor("SoftDeleteByID", false)
+		return err
+	}
+	if affected == 0 {
+		r.metrics.IncrementOperationsFor("SoftDeleteByID", false)
+		return r.wrapError(gorm.ErrRecordNotFound, "SoftDeleteByID", fmt.Sprintf("failed to soft delete entity %s", id))
+	}
+
+	r.cacheInvalidate(ctx, id)
+	if err := r.notifyChange(ctx, ChangeDelete, id, before, nil); err != nil {
+		r.metrics.IncrementOperationsFor("SoftDeleteByID", false)
+		return err
+	}
+	r.metrics.IncrementOperationsFor("SoftDeleteByID", true)
+	return nil
+}
+
+// SoftDeleteByConditions marks the entities matching conds as deleted,
+// regardless of the repository delete mode. Conditions are required.
+func (r *BaseRepository[T]) SoftDeleteByConditions(ctx context.Context, conds ...interface{}) (int64, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "SoftDeleteByConditions", time.Since(start))
+	}()
+
+	if len(conds) == 0 {
+		r.metrics.IncrementOperationsFor("SoftDeleteByConditions", false)
+		return 0, r.argumentError("SoftDeleteByConditions", "WHERE conditions required")
+	}
+
+	affected, err := r.softDeleteWhere(ctx, "SoftDeleteByConditions", conds[0], conds[1:]...)
+	if err != nil {
+		r.metrics.IncrementOperationsFor("SoftDeleteByConditions", false)
+		return 0, err
+	}
+
+	r.cacheInvalidate(ctx)
+	r.metrics.IncrementOperationsFor("SoftDeleteByConditions", true)
+	return affected, nil
+}
+
+// RestoreByID clears the deletion mark of a soft deleted entity
+func (r *BaseRepository[T]) RestoreByID(ctx context.Context, id models.EntityID) error {
+	id = normalizeID(id, keyTypeOf[T]())
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "RestoreByID", time.Since(start))
+	}()
+
+	if isZeroID(id) {
+		r.metrics.IncrementOperationsFor("RestoreByID", false)
+		return r.argumentError("RestoreByID", "ID cannot be nil")
+	}
+	if r.softDelete == softDeleteNone {
+		r.metrics.IncrementOperationsFor("RestoreByID", false)
+		return r.argumentError("RestoreByID", fmt.Sprintf("entity %s does not support soft delete", r.modelType.Name()))
+	}
+
+	before := r.snapshot(ctx, id)
+	result := r.db.WithContext(ctx).Unscoped().Model(new(T)).
+		Where("id = ? AND "+deletedAtColumn+" IS NOT NULL", id).
+		UpdateColumn(deletedAtColumn, nil)
+	if result.Error != nil {
+		r.metrics.IncrementOperationsFor("RestoreByID", false)
+		return r.wrapError(result.Error, "RestoreByID", "failed to restore entity")
+	}
+	if result.RowsAffected == 0 {
+		r.metrics.IncrementOperationsFor("RestoreByID", false)
+		return r.wrapError(gorm.ErrRecordNotFound, "RestoreByID", fmt.Sprintf("failed to restore entity %s", id))
+	}
+
+	r.cacheInvalidate(ctx, id)
+	if err := r.notifyChange(ctx, ChangeRestore, id, before, r.snapshot(ctx, id)); err != nil {
+		r.metrics.IncrementOperationsFor("RestoreByID", false)
+		return err
+	}
+	r.metrics.IncrementOperationsFor("RestoreByID", true)
+	return nil
+}
+
+// FindAllIncludingDeleted finds all entities, soft deleted ones included
+func (r *BaseRepository[T]) FindAllIncludingDeleted(ctx context.Context, limit int, offset int, dest *[]T, opts ...FindOption) error {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "FindAllIncludingDeleted", time.Since(start))
+	}()
+
+	limit, offset = r.validateOffsetPaginationParams(limit, offset)
+
+	if err := r.paginate(r.findDB(WithDeleted(ctx), opts), limit, offset).Find(dest).Error; err != nil {
+		r.metrics.IncrementOperationsFor("FindAllIncludingDeleted", false)
+		return r.wrapError(err, "FindAllIncludingDeleted", "failed to find all entities including deleted")
+	}
+
+	r.metrics.IncrementOperationsFor("FindAllIncludingDeleted", true)
+	return nil
+}
+
+// softDeleteWhere sets deleted_at on the live entities matching the
+// condition, for operation
+func (r *BaseRepository[T]) softDeleteWhere(ctx context.Context, operation string, query interface{}, args ...interface{}) (int64, error) {
+	if r.softDelete == softDeleteNone {
+		return 0, r.argumentError(operation, fmt.Sprintf("entity %s does not support soft delete", r.modelType.Name()))
+	}
+
+	result := r.db.WithContext(ctx).Model(new(T)).
+		Where(query, args...).
+		Where(deletedAtColumn+" IS NULL").
+		UpdateColumn(deletedAtColumn, time.Now())
+	if result.Error != nil {
+		return 0, r.wrapError(result.Error, operation, "failed to soft delete entities")
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"gorm.io/gorm"
+)
+
+// TableStats describes the size and maintenance state of the entity table
+type TableStats struct {
+	Table         string `json:"table"`
+	Dialect       string `json:"dialect"`
+	EstimatedRows int64  `json:"estimated_rows"`
+	ExactRowCount bool   `json:"exact_row_count"`
+	// Detailed reports whether the dialect provided the size and maintenance
+	// statistics below; otherwise only the row count is set
+	Detailed    bool         `json:"detailed"`
+	TableBytes  int64        `json:"table_bytes"`
+	IndexBytes  int64        `json:"index_bytes"`
+	TotalBytes  int64        `json:"total_bytes"`
+	Indexes     []IndexStats `json:"indexes,omitempty"`
+	DeadTuples  *int64       `json:"dead_tuples,omitempty"`
+	LastVacuum  *time.Time   `json:"last_vacuum,omitempty"`
+	LastAnalyze *time.Time   `json:"last_analyze,omitempty"`
+}
+
+// IndexStats describes the size of an index of the entity table
+type IndexStats struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+}
+
+// EstimateCount returns an approximate number of entities matching the
+// conditions without scanning the table. Without conditions the table
+// statistics are used, which also count soft deleted rows; with conditions
+// the planner row estimate of the query (EXPLAIN) is used. Dialects exposing
+// neither fall back to an exact count.
+func (r *BaseRepository[T]) EstimateCount(ctx context.Context, conds ...interface{}) (int64, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "EstimateCount", time.Since(start))
+	}()
+
+	var count int64
+	var err error
+	if len(conds) == 0 {
+		count, _, err = r.estimateRows(r.readDB(ctx).Session(&gorm.Session{}), r.schemaTable())
+	} else {
+		count, err = r.estimatePlanRows(ctx, conds)
+	}
+
+	if err != nil {
+		r.metrics.IncrementOperationsFor("EstimateCount", false)
+		return 0, r.wrapError(err, "EstimateCount", "failed to estimate entity count")
+	}
+
+	r.metrics.IncrementOperationsFor("EstimateCount", true)
+	return count, nil
+}
+
+// estimatePlanRows returns the planner row estimate of the query selecting
+// the entities matching conds, and an exact count when there is none
+func (r *BaseRepository[T]) estimatePlanRows(ctx context.Context, conds []interface{}) (int64, error) {
+	query := r.readDB(ctx).Model(new(T)).Where(conds[0], conds[1:]...)
+
+	if estimator, ok := r.dialect.(dialect.PlanEstimator); ok {
+		stmt := query.Session(&gorm.Session{DryRun: true}).Find(new([]T)).Statement
+		if stmt.Error == nil {
+			plan, err := explain(ctx, stmt.ConnPool, estimator.ExplainRows(stmt.SQL.String()), stmt.Vars)
+			if err == nil {
+				if rows, ok := estimator.PlanRows(plan); ok && rows >= 0 {
+					return rows, nil
+				}
+			}
+			r.logger.Debug(ctx, "No planner row estimate, counting rows",
+				logging.String("table", r.tableName),
+				logging.ErrorField("error", err))
+		}
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// explain runs an EXPLAIN statement and returns its rows keyed by column.
+// The statement runs on the pool directly since its bind variables are
+// already rendered for the dialect.
+func explain(ctx context.Context, pool gorm.ConnPool, statement string, vars []interface{}) ([]map[string]interface{}, error) {
+	rows, err := pool.QueryContext(ctx, statement, vars...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var plan []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			row[column] = values[i]
+		}
+		plan = append(plan, row)
+	}
+	return plan, rows.Err()
+}
+
+// TableStats returns the row estimate, the table and index sizes, the dead
+// tuples and the last vacuum and analyze times of the entity table, as far as
+// the dialect exposes them (see dialect.TableStatsReader)
+func (r *BaseRepository[T]) TableStats(ctx context.Context) (*TableStats, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordTableQueryTime(r.tableName, "TableStats", time.Since(start))
+	}()
+
+	db := r.db.WithContext(ctx)
+	stats := &TableStats{Table: r.schemaTable(), Dialect: r.dialect.Name()}
+
+	if err := r.readTableStats(ctx, db, stats); err != nil {
+		r.metrics.IncrementOperationsFor("TableStats", false)
+		return nil, err
+	}
+
+	if !stats.Detailed || stats.EstimatedRows < 0 {
+		if !db.Migrator().HasTable(stats.Table) {
+			r.metrics.IncrementOperationsFor("TableStats", false)
+			return nil, r.newError(errors.ErrorTypeNotFound, "TableStats", fmt.Sprintf("table %s does not exist", stats.Table))
+		}
+		rows, exact, err := r.estimateRows(db, stats.Table)
+		if err != nil {
+			r.metrics.IncrementOperationsFor("TableStats", false)
+			return nil, r.wrapError(err, "TableStats", "failed to estimate row count")
+		}
+		stats.EstimatedRows, stats.ExactRowCount = rows, exact
+	}
+
+	r.metrics.IncrementOperationsFor("TableStats", true)
+	return stats, nil
+}
+
+// readTableStats fills the statistics exposed by the dialect. Index sizes
+// are best effort, reading them may require additional privileges.
+func (r *BaseRepository[T]) readTableStats(ctx context.Context, db *gorm.DB, stats *TableStats) error {
+	reader, ok := r.dialect.(dialect.TableStatsReader)
+	if !ok {
+		return nil
+	}
+	query, ok := reader.TableStats()
+	if !ok {
+		return nil
+	}
+
+	var rows, tableBytes, indexBytes, deadTuples sql.NullInt64
+	var lastVacuum, lastAnalyze sql.NullTime
+	err := db.Raw(query, stats.Table).Row().Scan(&rows, &tableBytes, &indexBytes, &deadTuples, &lastVacuum, &lastAnalyze)
+	if stderrors.Is(err, sql.ErrNoRows) || (err == nil && !rows.Valid && !tableBytes.Valid) {
+		return r.newError(errors.ErrorTypeNotFound, "TableStats", fmt.Sprintf("table %s does not exist", stats.Table))
+	}
+	if err != nil {
+		return r.wrapError(err, "TableStats", "failed to read table statistics")
+	}
+
+	stats.Detailed = true
+	stats.EstimatedRows = -1
+	if rows.Valid {
+		stats.EstimatedRows = rows.Int64
+	}
+	stats.TableBytes, stats.IndexBytes = tableBytes.Int64, indexBytes.Int64
+	stats.TotalBytes = stats.TableBytes + stats.IndexBytes
+	if deadTuples.Valid {
+		stats.DeadTuples = &deadTuples.Int64
+	}
+	if lastVacuum.Valid {
+		stats.LastVacuum = &lastVacuum.Time
+	}
+	if lastAnalyze.Valid {
+		stats.LastAnalyze = &lastAnalyze.Time
+	}
+
+	indexRows, err := db.Raw(reader.IndexStats(), stats.Table).Rows()
+	if err != nil {
+		r.logger.Debug(ctx, "Index statistics unavailable",
+			logging.String("table", r.tableName),
+			logging.ErrorField("error", err))
+		return nil
+	}
+	defer indexRows.Close()
+
+	for indexRows.Next() {
+		var index IndexStats
+		var bytes sql.NullInt64
+		if err := indexRows.Scan(&index.Name, &bytes); err != nil {
+			return r.wrapError(err, "TableStats", "failed to read index statistics")
+		}
+		index.Bytes = bytes.Int64
+		stats.Indexes = append(stats.Indexes, index)
+	}
+	return indexRows.Err()
+}
@@ -0,0 +1,1139 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"reflect"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// TenantContextKey is the default context key of the current tenant, also
+// read by the logger to tag log entries with tenant_id
+const TenantContextKey = "tenant_id"
+
+// ErrTenantRequired is returned by strict tenant-scoped repositories when the
+// context carries no tenant
+var ErrTenantRequired = stderrors.New("tenant required in context")
+
+// ErrCrossTenant is returned when an operation would write an entity of
+// another tenant
+var ErrCrossTenant = stderrors.New("entity belongs to another tenant")
+
+// TenancyConfig configures a TenantScopedRepository
+type TenancyConfig struct {
+	// Column is the column holding the tenant of each row (default "tenant_id")
+	Column string `json:"column"`
+	// ContextKey is the context key of the current tenant (default TenantContextKey)
+	ContextKey interface{} `json:"-"`
+	// Strict refuses operations whose context carries no tenant; otherwise
+	// they run unscoped, e.g. for maintenance jobs
+	Strict bool `json:"strict"`
+}
+
+// DefaultTenancyConfig returns default tenancy configuration
+func DefaultTenancyConfig() *TenancyConfig {
+	return &TenancyConfig{
+		Column:     "tenant_id",
+		ContextKey: TenantContextKey,
+		Strict:     true,
+	}
+}
+
+// WithTenant returns a context scoped to tenantID under TenantContextKey
+func WithTenant(ctx context.Context, tenantID interface{}) context.Context {
+	return context.WithValue(ctx, TenantContextKey, tenantID)
+}
+
+// TenantScopedRepository decorates a BaseRepository so every operation is
+// confined to the tenant of its context: reads, counts and writes by
+// conditions match only the tenant's rows, created entities get the tenant
+// assigned, and writes by entity or ID refuse rows of another tenant with
+// ErrCrossTenant. Reads bypass the entity cache, which is not tenant-aware.
+type TenantScopedRepository[T any] struct {
+	repo   *BaseRepository[T]
+	config *TenancyConfig
+	schema *schema.Schema
+	field  *schema.Field
+}
+
+var _ Repository[struct{}] = (*TenantScopedRepository[struct{}])(nil)
+
+// NewTenantScopedRepository creates a tenant-scoped repository over repo. T
+// must have a field mapped to the configured tenant column.
+func NewTenantScopedRepository[T any](repo *BaseRepository[T], config *TenancyConfig) (*TenantScopedRepository[T], error) {
+	if repo == nil {
+		return nil, newError(errors.ErrorTypeValidation, "", "NewTenantScopedRepository", "repository cannot be nil")
+	}
+	if config == nil {
+		config = DefaultTenancyConfig()
+	}
+	if config.Column == "" {
+		config.Column = DefaultTenancyConfig().Column
+	}
+	if config.ContextKey == nil {
+		config.ContextKey = TenantContextKey
+	}
+
+	s, err := repo.schema()
+	if err != nil {
+		return nil, err
+	}
+	field := s.LookUpField(config.Column)
+	if field == nil || field.DBName == "" {
+		return nil, newError(errors.ErrorTypeValidation, repo.tableName, "NewTenantScopedRepository", fmt.Sprintf("%s has no tenant column %q", s.Name, config.Column))
+	}
+
+	return &TenantScopedRepository[T]{repo: repo, config: config, schema: s, field: field}, nil
+}
+
+// Unscoped returns the underlying repository, which is not tenant-scoped
+func (r *TenantScopedRepository[T]) Unscoped() *BaseRepository[T] {
+	return r.repo
+}
+
+// Tenant returns the tenant of ctx
+func (r *TenantScopedRepository[T]) Tenant(ctx context.Context) (interface{}, bool) {
+	tenant := ctx.Value(r.config.ContextKey)
+	if tenant == nil {
+		return nil, false
+	}
+	if value := reflect.ValueOf(tenant); value.IsZero() {
+		return nil, false
+	}
+	return tenant, true
+}
+
+// tenant returns the tenant of ctx; without one, strict repositories fail and
+// the others run the operation unscoped
+func (r *TenantScopedRepository[T]) tenant(ctx context.Context) (interface{}, bool, error) {
+	tenant, ok := r.Tenant(ctx)
+	if !ok && r.config.Strict {
+		return nil, false, ErrTenantRequired
+	}
+	return tenant, ok, nil
+}
+
+// column returns the tenant column qualified with the queried table
+func (r *TenantScopedRepository[T]) column() clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: r.field.DBName}
+}
+
+// option returns the find option restricting a read to tenant
+func (r *TenantScopedRepository[T]) option(tenant interface{}) FindOption {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.Eq{Column: r.column(), Value: tenant})
+	}
+}
+
+// scope returns conds restricted to tenant as a single condition, followed
+// by the find options mixed into conds
+func (r *TenantScopedRepository[T]) scope(tenant interface{}, conds []interface{}) ([]interface{}, error) {
+	conds, opts := splitFindOptions(conds)
+
+	exprs := []clause.Expression{clause.Eq{Column: r.column(), Value: tenant}}
+	if len(conds) > 0 {
+		stmt := &gorm.Statement{
+			DB:      r.repo.db.Session(&gorm.Session{NewDB: true}),
+			Schema:  r.schema,
+			Table:   r.schema.Table,
+			Clauses: map[string]clause.Clause{},
+		}
+		exprs = append(exprs, stmt.BuildCondition(conds[0], conds[1:]...)...)
+		if err := stmt.DB.Error; err != nil {
+			return nil, errors.Wrap(err, errors.ErrorTypeValidation, "invalid conditions").WithOperation("TenantScope").WithTable(r.repo.tableName)
+		}
+	}
+
+	scoped := []interface{}{clause.And(exprs...)}
+	for _, opt := range opts {
+		scoped = append(scoped, opt)
+	}
+	return scoped, nil
+}
+
+// scopeConds scopes conds to the tenant of ctx, leaving them untouched
+// without tenant in non-strict mode
+func (r *TenantScopedRepository[T]) scopeConds(ctx context.Context, conds []interface{}) ([]interface{}, error) {
+	tenant, ok, err := r.tenant(ctx)
+	if err != nil || !ok {
+		return conds, err
+	}
+	return r.scope(tenant, conds)
+}
+
+// scopeOpts adds the tenant restriction of ctx to opts
+func (r *TenantScopedRepository[T]) scopeOpts(ctx context.Context, opts []FindOption) ([]FindOption, error) {
+	tenant, ok, err := r.tenant(ctx)
+	if err != nil || !ok {
+		return opts, err
+	}
+	return append(append([]FindOption{}, opts...), r.option(tenant)), nil
+}
+
+// assign sets the tenant of ctx on entities without one and refuses entities
+// of another tenant
+func (r *TenantScopedRepository[T]) assign(ctx context.Context, tenant interface{}, entities ...*T) error {
+	for _, entity := range entities {
+		if entity == nil {
+			continue
+		}
+		value := reflect.ValueOf(entity).Elem()
+		current, zero := r.field.ValueOf(ctx, value)
+		if zero {
+			if err := r.field.Set(ctx, value, tenant); err != nil {
+				return operationError(err, r.repo.tableName, "TenantScope", "failed to set tenant")
+			}
+			continue
+		}
+		if !sameTenant(current, tenant) {
+			return r.crossTenant(fmt.Sprintf("%s %s", r.schema.Name, r.entityID(entity)))
+		}
+	}
+	return nil
+}
+
+// own refuses ids of rows belonging to another tenant, soft deleted or not.
+// Rows without tenant belong to no tenant and are refused as well.
+func (r *TenantScopedRepository[T]) own(ctx context.Context, tenant interface{}, ids ...models.EntityID) error {
+	values := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		if !isZeroID(id) {
+			values = append(values, id)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	var foreign []string
+	err := r.repo.db.WithContext(ctx).Unscoped().Model(new(T)).
+		Where(clause.IN{Column: idColumn, Values: values}).
+		Where(clause.Or(clause.Neq{Column: r.column(), Value: tenant}, clause.Eq{Column: r.column(), Value: nil})).
+		Limit(1).Pluck("id", &foreign).Error
+	if err != nil {
+		return operationError(err, r.repo.tableName, "TenantScope", "failed to check tenant")
+	}
+	if len(foreign) > 0 {
+		return r.crossTenant(fmt.Sprintf("%s %s", r.schema.Name, foreign[0]))
+	}
+	return nil
+}
+
+// writable assigns the tenant of ctx to entities and checks their IDs do not
+// belong to another tenant; the tenant is nil when the write runs unscoped
+func (r *TenantScopedRepository[T]) writable(ctx context.Context, entities ...*T) error {
+	tenant, ok, err := r.tenant(ctx)
+	if err != nil || !ok {
+		return err
+	}
+	if err := r.assign(ctx, tenant, entities...); err != nil {
+		return err
+	}
+
+	ids := make([]models.EntityID, 0, len(entities))
+	for _, entity := range entities {
+		if entity != nil {
+			ids = append(ids, r.repo.getEntityID(entity))
+		}
+	}
+	return r.own(ctx, tenant, ids...)
+}
+
+// writableIDs checks ids do not belong to another tenant
+func (r *TenantScopedRepository[T]) writableIDs(ctx context.Context, ids ...models.EntityID) error {
+	tenant, ok, err := r.tenant(ctx)
+	if err != nil || !ok {
+		return err
+	}
+	return r.own(ctx, tenant, ids...)
+}
+
+// writableKey checks the key does not belong to another tenant, soft
+// deleted or not
+func (r *TenantScopedRepository[T]) writableKey(ctx context.Context, key map[string]interface{}) error {
+	tenant, ok, err := r.tenant(ctx)
+	if err != nil || !ok {
+		return err
+	}
+	cond, err := r.repo.keyCond("TenantScope", key)
+	if err != nil {
+		return err
+	}
+
+	var foreign int64
+	err = r.repo.db.WithContext(ctx).Unscoped().Model(new(T)).
+		Where(cond).
+		Where(clause.Or(clause.Neq{Column: r.column(), Value: tenant}, clause.Eq{Column: r.column(), Value: nil})).
+		Count(&foreign).Error
+	if err != nil {
+		return operationError(err, r.repo.tableName, "TenantScope", "failed to check tenant")
+	}
+	if foreign > 0 {
+		return r.crossTenant(fmt.Sprintf("%s %v", r.schema.Name, key))
+	}
+	return nil
+}
+
+// pointers returns pointers to the elements of entities
+func pointers[T any](entities []T) []*T {
+	ptrs := make([]*T, len(entities))
+	for i := range entities {
+		ptrs[i] = &entities[i]
+	}
+	return ptrs
+}
+
+// entityID returns the ID of entity for error messages
+func (r *TenantScopedRepository[T]) entityID(entity *T) string {
+	return idString(r.repo.getEntityID(entity))
+}
+
+// sameTenant reports whether two tenant values are equal, e.g. a uuid.UUID
+// and its string form
+func sameTenant(a, b interface{}) bool {
+	return tenantString(a) == tenantString(b)
+}
+
+// tenantString returns the string form of a tenant value
+func tenantString(tenant interface{}) string {
+	value := reflect.ValueOf(tenant)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+	if stringer, ok := value.Interface().(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprint(value.Interface())
+}
+
+// Create creates an entity owned by the tenant of ctx
+func (r *TenantScopedRepository[T]) Create(ctx context.Context, entity *T) error {
+	if err := r.writable(ctx, entity); err != nil {
+		return err
+	}
+	return r.repo.Create(ctx, entity)
+}
+
+// CreateInBatches creates entities owned by the tenant of ctx
+func (r *TenantScopedRepository[T]) CreateInBatches(ctx context.Context, entities []T, batchSize int) error {
+	if err := r.writable(ctx, pointers(entities)...); err != nil {
+		return err
+	}
+	return r.repo.CreateInBatches(ctx, entities, batchSize)
+}
+
+// CreateInBatchesIgnoreConflicts creates entities owned by the tenant of ctx,
+// skipping conflicting rows
+func (r *TenantScopedRepository[T]) CreateInBatchesIgnoreConflicts(ctx context.Context, entities []T, batchSize int, conflictColumns ...string) (*IngestResult, error) {
+	if err := r.writable(ctx, pointers(entities)...); err != nil {
+		return nil, err
+	}
+	return r.repo.CreateInBatchesIgnoreConflicts(ctx, entities, batchSize, conflictColumns...)
+}
+
+// BulkInsertFast bulk inserts entities owned by the tenant of ctx
+func (r *TenantScopedRepository[T]) BulkInsertFast(ctx context.Context, entities []T) (*IngestResult, error) {
+	if err := r.writable(ctx, pointers(entities)...); err != nil {
+		return nil, err
+	}
+	return r.repo.BulkInsertFast(ctx, entities)
+}
+
+// FindOrCreateByConditions finds the first entity of the tenant matching
+// conds or creates it owned by the tenant
+func (r *TenantScopedRepository[T]) FindOrCreateByConditions(ctx context.Context, dest *T, attrs interface{}, conds ...interface{}) (bool, error) {
+	tenant, ok, err := r.tenant(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !ok || len(conds) == 0 {
+		return r.repo.FindOrCreateByConditions(ctx, dest, attrs, conds...)
+	}
+
+	scoped, err := r.scope(tenant, conds)
+	if err != nil {
+		return false, err
+	}
+	// Scoped conditions are SQL: the entity is initialized from the original ones
+	return r.repo.findOrCreate(ctx, dest, attrs, scoped, func(entity *T) error {
+		for _, values := range []interface{}{conds[0], attrs} {
+			if err := r.repo.assignValues(ctx, entity, values); err != nil {
+				return err
+			}
+		}
+		return r.assign(ctx, tenant, entity)
+	})
+}
+
+// FindFirstByID finds an entity of the tenant by ID
+func (r *TenantScopedRepository[T]) FindFirstByID(ctx context.Context, id models.EntityID, opts ...FindOption) (*T, error) {
+	opts, err := r.scopeOpts(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return r.repo.FindFirstByID(ctx, id, opts...)
+}
+
+// FindFirstByKey finds an entity of the tenant by key
+func (r *TenantScopedRepository[T]) FindFirstByKey(ctx context.Context, key map[string]interface{}, opts ...FindOption) (*T, error) {
+	opts, err := r.scopeOpts(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return r.repo.FindFirstByKey(ctx, key, opts...)
+}
+
+// FindAllByIDs finds the entities of the tenant with ids, keyed by ID
+func (r *TenantScopedRepository[T]) FindAllByIDs(ctx context.Context, ids []models.EntityID, opts ...FindOption) (map[models.EntityID]*T, error) {
+	opts, err := r.scopeOpts(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return r.repo.FindAllByIDs(ctx, ids, opts...)
+}
+
+// FindFirstByConditions finds the first entity of the tenant matching conds
+func (r *TenantScopedRepository[T]) FindFirstByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return err
+	}
+	return r.repo.FindFirstByConditions(ctx, dest, conds...)
+}
+
+// FindFirstBy finds the first entity of the tenant whose column equals value
+func (r *TenantScopedRepository[T]) FindFirstBy(ctx context.Context, dest *T, column string, value interface{}) error {
+	tenant, ok, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return r.repo.FindFirstBy(ctx, dest, column, value)
+	}
+
+	field := r.schema.LookUpField(column)
+	if field == nil || field.DBName == "" {
+		return newError(errors.ErrorTypeValidation, r.repo.tableName, "FindFirstBy", fmt.Sprintf("unknown column %q on %s", column, r.schema.Name))
+	}
+	conds, err := r.scope(tenant, []interface{}{clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: value}})
+	if err != nil {
+		return err
+	}
+	return r.repo.FindFirstByConditions(ctx, dest, conds...)
+}
+
+// FirstOrInitByConditions finds the first entity of the tenant matching
+// conds, or initializes dest owned by the tenant
+func (r *TenantScopedRepository[T]) FirstOrInitByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
+	tenant, ok, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return r.repo.FirstOrInitByConditions(ctx, dest, conds...)
+	}
+
+	scoped, err := r.scope(tenant, conds)
+	if err != nil {
+		return err
+	}
+	if err := r.repo.FirstOrInitByConditions(ctx, dest, scoped...); err != nil {
+		return err
+	}
+	return r.assign(ctx, tenant, dest)
+}
+
+// FindAllWithOffset finds the entities of the tenant with offset pagination
+func (r *TenantScopedRepository[T]) FindAllWithOffset(ctx context.Context, limit int, offset int, dest *[]T, opts ...FindOption) error {
+	opts, err := r.scopeOpts(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return r.repo.FindAllWithOffset(ctx, limit, offset, dest, opts...)
+}
+
+// FindAllInBatchesWithOffset finds the entities of the tenant in batches
+func (r *TenantScopedRepository[T]) FindAllInBatchesWithOffset(ctx context.Context, limit int, offset int, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, opts ...FindOption) error {
+	opts, err := r.scopeOpts(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return r.repo.FindAllInBatchesWithOffset(ctx, limit, offset, dest, batchSize, fc, opts...)
+}
+
+// FindAllByConditionsWithOffset finds the entities of the tenant matching conds
+func (r *TenantScopedRepository[T]) FindAllByConditionsWithOffset(ctx context.Context, limit int, offset int, dest *[]T, conds ...interface{}) error {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return err
+	}
+	return r.repo.FindAllByConditionsWithOffset(ctx, limit, offset, dest, conds...)
+}
+
+// FindAllInBatchesByConditionsWithOffset finds the entities of the tenant
+// matching conds in batches
+func (r *TenantScopedRepository[T]) FindAllInBatchesByConditionsWithOffset(ctx context.Context, limit int, offset int, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, conds ...interface{}) error {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return err
+	}
+	return r.repo.FindAllInBatchesByConditionsWithOffset(ctx, limit, offset, dest, batchSize, fc, conds...)
+}
+
+// FindAllWithCursor finds the entities of the tenant with cursor pagination
+func (r *TenantScopedRepository[T]) FindAllWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, opts ...FindOption) error {
+	opts, err := r.scopeOpts(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return r.repo.FindAllWithCursor(ctx, cursor, limit, direction, dest, opts...)
+}
+
+// FindAllInBatchesWithCursor finds the entities of the tenant in batches
+// with cursor pagination
+func (r *TenantScopedRepository[T]) FindAllInBatchesWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, opts ...FindOption) error {
+	opts, err := r.scopeOpts(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return r.repo.FindAllInBatchesWithCursor(ctx, cursor, limit, direction, dest, batchSize, fc, opts...)
+}
+
+// FindAllByConditionsWithCursor finds the entities of the tenant matching
+// conds with cursor pagination
+func (r *TenantScopedRepository[T]) FindAllByConditionsWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, conds ...interface{}) error {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return err
+	}
+	return r.repo.FindAllByConditionsWithCursor(ctx, cursor, limit, direction, dest, conds...)
+}
+
+// FindAllInBatchesByConditionsWithCursor finds the entities of the tenant
+// matching conds in batches with cursor pagination
+func (r *TenantScopedRepository[T]) FindAllInBatchesByConditionsWithCursor(ctx context.Context, cursor string, limit int, direction string, dest *[]T, batchSize int, fc func(tx *gorm.DB, batch int) error, conds ...interface{}) error {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return err
+	}
+	return r.repo.FindAllInBatchesByConditionsWithCursor(ctx, cursor, limit, direction, dest, batchSize, fc, conds...)
+}
+
+// Update updates an entity of the tenant
+func (r *TenantScopedRepository[T]) Update(ctx context.Context, entity *T) error {
+	if err := r.writable(ctx, entity); err != nil {
+		return err
+	}
+	return r.repo.Update(ctx, entity)
+}
+
+// UpdateByID updates an entity of the tenant by ID
+func (r *TenantScopedRepository[T]) UpdateByID(ctx context.Context, entity *T, id models.EntityID) error {
+	if err := r.writable(ctx, entity); err != nil {
+		return err
+	}
+	if err := r.writableIDs(ctx, id); err != nil {
+		return err
+	}
+	return r.repo.UpdateByID(ctx, entity, id)
+}
+
+// UpdateByConditions updates the entities of the tenant matching conds, or
+// saves entity without conditions
+func (r *TenantScopedRepository[T]) UpdateByConditions(ctx context.Context, entity *T, conds ...interface{}) error {
+	if err := r.writable(ctx, entity); err != nil {
+		return err
+	}
+	if len(conds) == 0 {
+		return r.repo.UpdateByConditions(ctx, entity)
+	}
+
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return err
+	}
+	return r.repo.UpdateByConditions(ctx, entity, conds...)
+}
+
+// UpdateAllByConditions sets values on the entities of the tenant matching
+// conds. Values may not move entities to another tenant.
+func (r *TenantScopedRepository[T]) UpdateAllByConditions(ctx context.Context, values map[string]interface{}, conds ...interface{}) (int64, error) {
+	if err := r.checkValues(ctx, values); err != nil {
+		return 0, err
+	}
+	if len(conds) == 0 {
+		return r.repo.UpdateAllByConditions(ctx, values)
+	}
+
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return 0, err
+	}
+	return r.repo.UpdateAllByConditions(ctx, values, conds...)
+}
+
+// UpdateIf sets updates on the entity of the tenant with id if it holds the
+// expected values; entities of another tenant never match
+func (r *TenantScopedRepository[T]) UpdateIf(ctx context.Context, id models.EntityID, expected map[string]interface{}, updates map[string]interface{}) (bool, error) {
+	if err := r.checkValues(ctx, updates); err != nil {
+		return false, err
+	}
+	tenant, ok, err := r.tenant(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return r.repo.UpdateIf(ctx, id, expected, updates)
+	}
+
+	scoped := make(map[string]interface{}, len(expected)+1)
+	for column, value := range expected {
+		scoped[column] = value
+	}
+	scoped[r.field.DBName] = tenant
+	return r.repo.UpdateIf(ctx, id, scoped, updates)
+}
+
+// crossTenant returns the security error wrapping ErrCrossTenant of the
+// refused access described by message
+func (r *TenantScopedRepository[T]) crossTenant(message string) error {
+	return errors.Wrap(ErrCrossTenant, errors.ErrorTypeSecurity, message).
+		WithOperation("TenantScope").
+		WithTable(r.repo.tableName)
+}
+
+// checkValues refuses column values setting another tenant
+func (r *TenantScopedRepository[T]) checkValues(ctx context.Context, values map[string]interface{}) error {
+	tenant, ok, err := r.tenant(ctx)
+	if err != nil || !ok {
+		return err
+	}
+	for column, value := range values {
+		if (column == r.field.DBName || column == r.field.Name) && !sameTenant(value, tenant) {
+			return r.crossTenant(fmt.Sprintf("cannot set %s to %v", r.field.DBName, value))
+		}
+	}
+	return nil
+}
+
+// conflictScope restricts the rows updated by upserts conflicting on other
+// columns than the primary key to the tenant, which MySQL, SQL Server and
+// Oracle do not support
+func (r *TenantScopedRepository[T]) conflictScope(ctx context.Context, conflict ConflictOptions) (ConflictOptions, error) {
+	if conflict.DoNothing || r.repo.byPrimaryKey(conflict) {
+		return conflict, nil
+	}
+	tenant, ok, err := r.tenant(ctx)
+	if err != nil || !ok {
+		return conflict, err
+	}
+
+	condition := fmt.Sprintf("%s.%s = ?", r.repo.dialect.Quote(r.schema.Table), r.repo.dialect.Quote(r.field.DBName))
+	if conflict.Where != "" {
+		condition = "(" + conflict.Where + ") AND " + condition
+	}
+	conflict.Where = condition
+	conflict.WhereArgs = append(append([]interface{}{}, conflict.WhereArgs...), tenant)
+	return conflict, nil
+}
+
+// merged checks the row an upsert conflicting on other columns than the
+// primary key merged entity into belongs to the tenant. The conflicting row
+// of another tenant was left untouched: entity keeps its ID and
+// ErrCrossTenant is returned.
+func (r *TenantScopedRepository[T]) merged(ctx context.Context, entity *T, id models.EntityID, conflict ConflictOptions) error {
+	if r.repo.byPrimaryKey(conflict) && !conflict.DoNothing {
+		return nil
+	}
+	if err := r.writableIDs(ctx, r.repo.getEntityID(entity)); err != nil {
+		r.repo.setEntityID(entity, id)
+		return err
+	}
+	return nil
+}
+
+// Upsert inserts or updates an entity of the tenant
+func (r *TenantScopedRepository[T]) Upsert(ctx context.Context, entity *T, conflict ConflictOptions) error {
+	if err := r.writable(ctx, entity); err != nil {
+		return err
+	}
+	scoped, err := r.conflictScope(ctx, conflict)
+	if err != nil {
+		return err
+	}
+	id := r.repo.getEntityID(entity)
+	if err := r.repo.Upsert(ctx, entity, scoped); err != nil {
+		return err
+	}
+	return r.merged(ctx, entity, id, conflict)
+}
+
+// UpsertByID inserts or updates an entity of the tenant by ID
+func (r *TenantScopedRepository[T]) UpsertByID(ctx context.Context, entity *T, id models.EntityID, conflict ConflictOptions) error {
+	if err := r.writable(ctx, entity); err != nil {
+		return err
+	}
+	if err := r.writableIDs(ctx, id); err != nil {
+		return err
+	}
+	scoped, err := r.conflictScope(ctx, conflict)
+	if err != nil {
+		return err
+	}
+	if err := r.repo.UpsertByID(ctx, entity, id, scoped); err != nil {
+		return err
+	}
+	return r.merged(ctx, entity, id, conflict)
+}
+
+// UpsertByConditions inserts or updates an entity of the tenant
+func (r *TenantScopedRepository[T]) UpsertByConditions(ctx context.Context, entity *T, conflict ConflictOptions, conds ...interface{}) error {
+	if err := r.writable(ctx, entity); err != nil {
+		return err
+	}
+	scoped, err := r.conflictScope(ctx, conflict)
+	if err != nil {
+		return err
+	}
+	if len(conds) > 0 {
+		if conds, err = r.scopeConds(ctx, conds); err != nil {
+			return err
+		}
+	}
+	id := r.repo.getEntityID(entity)
+	if err := r.repo.UpsertByConditions(ctx, entity, scoped, conds...); err != nil {
+		return err
+	}
+	return r.merged(ctx, entity, id, conflict)
+}
+
+// UpsertInBatches inserts or updates entities of the tenant
+func (r *TenantScopedRepository[T]) UpsertInBatches(ctx context.Context, entities []T, batchSize int, conflict ConflictOptions) error {
+	if err := r.writable(ctx, pointers(entities)...); err != nil {
+		return err
+	}
+	conflict, err := r.conflictScope(ctx, conflict)
+	if err != nil {
+		return err
+	}
+	return r.repo.UpsertInBatches(ctx, entities, batchSize, conflict)
+}
+
+// UpsertInBatchesByConditions inserts or updates entities of the tenant
+func (r *TenantScopedRepository[T]) UpsertInBatchesByConditions(ctx context.Context, entities []T, batchSize int, conflict ConflictOptions, conds ...interface{}) error {
+	if err := r.writable(ctx, pointers(entities)...); err != nil {
+		return err
+	}
+	conflict, err := r.conflictScope(ctx, conflict)
+	if err != nil {
+		return err
+	}
+	if len(conds) > 0 {
+		if conds, err = r.scopeConds(ctx, conds); err != nil {
+			return err
+		}
+	}
+	return r.repo.UpsertInBatchesByConditions(ctx, entities, batchSize, conflict, conds...)
+}
+
+// Delete deletes an entity of the tenant
+func (r *TenantScopedRepository[T]) Delete(ctx context.Context, entity *T) error {
+	if entity != nil {
+		if err := r.writableIDs(ctx, r.repo.getEntityID(entity)); err != nil {
+			return err
+		}
+	}
+	return r.repo.Delete(ctx, entity)
+}
+
+// DeleteByID deletes an entity of the tenant by ID
+func (r *TenantScopedRepository[T]) DeleteByID(ctx context.Context, id models.EntityID) error {
+	if err := r.writableIDs(ctx, id); err != nil {
+		return err
+	}
+	return r.repo.DeleteByID(ctx, id)
+}
+
+// DeleteByKey deletes an entity of the tenant by key
+func (r *TenantScopedRepository[T]) DeleteByKey(ctx context.Context, key map[string]interface{}) error {
+	if err := r.writableKey(ctx, key); err != nil {
+		return err
+	}
+	return r.repo.DeleteByKey(ctx, key)
+}
+
+// DeleteByConditions deletes the entities of the tenant matching conds
+func (r *TenantScopedRepository[T]) DeleteByConditions(ctx context.Context, entity *T, conds ...interface{}) error {
+	if entity != nil {
+		if err := r.writableIDs(ctx, r.repo.getEntityID(entity)); err != nil {
+			return err
+		}
+	}
+	if len(conds) == 0 {
+		return r.repo.DeleteByConditions(ctx, entity)
+	}
+
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return err
+	}
+	return r.repo.DeleteByConditions(ctx, entity, conds...)
+}
+
+// DeleteAllByConditions deletes the entities of the tenant matching conds
+func (r *TenantScopedRepository[T]) DeleteAllByConditions(ctx context.Context, conds ...interface{}) (int64, error) {
+	if len(conds) == 0 {
+		return r.repo.DeleteAllByConditions(ctx)
+	}
+
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return 0, err
+	}
+	return r.repo.DeleteAllByConditions(ctx, conds...)
+}
+
+// DeleteInBatches deletes entities of the tenant
+func (r *TenantScopedRepository[T]) DeleteInBatches(ctx context.Context, entities []T, batchSize int) error {
+	if err := r.writableIDs(ctx, r.ids(entities)...); err != nil {
+		return err
+	}
+	return r.repo.DeleteInBatches(ctx, entities, batchSize)
+}
+
+// DeleteInBatchesByConditions deletes entities of the tenant matching conds
+func (r *TenantScopedRepository[T]) DeleteInBatchesByConditions(ctx context.Context, entities []T, batchSize int, conds ...interface{}) error {
+	if err := r.writableIDs(ctx, r.ids(entities)...); err != nil {
+		return err
+	}
+	if len(conds) > 0 {
+		var err error
+		if conds, err = r.scopeConds(ctx, conds); err != nil {
+			return err
+		}
+	}
+	return r.repo.DeleteInBatchesByConditions(ctx, entities, batchSize, conds...)
+}
+
+// ids returns the IDs of entities
+func (r *TenantScopedRepository[T]) ids(entities []T) []models.EntityID {
+	ids := make([]models.EntityID, len(entities))
+	for i := range entities {
+		ids[i] = r.repo.getEntityID(&entities[i])
+	}
+	return ids
+}
+
+// SoftDeleteByID soft deletes an entity of the tenant by ID
+func (r *TenantScopedRepository[T]) SoftDeleteByID(ctx context.Context, id models.EntityID) error {
+	if err := r.writableIDs(ctx, id); err != nil {
+		return err
+	}
+	return r.repo.SoftDeleteByID(ctx, id)
+}
+
+// SoftDeleteByConditions soft deletes the entities of the tenant matching conds
+func (r *TenantScopedRepository[T]) SoftDeleteByConditions(ctx context.Context, conds ...interface{}) (int64, error) {
+	if len(conds) == 0 {
+		return r.repo.SoftDeleteByConditions(ctx)
+	}
+
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return 0, err
+	}
+	return r.repo.SoftDeleteByConditions(ctx, conds...)
+}
+
+// RestoreByID restores a soft deleted entity of the tenant
+func (r *TenantScopedRepository[T]) RestoreByID(ctx context.Context, id models.EntityID) error {
+	if err := r.writableIDs(ctx, id); err != nil {
+		return err
+	}
+	return r.repo.RestoreByID(ctx, id)
+}
+
+// FindAllIncludingDeleted finds the entities of the tenant, soft deleted included
+func (r *TenantScopedRepository[T]) FindAllIncludingDeleted(ctx context.Context, limit int, offset int, dest *[]T, opts ...FindOption) error {
+	opts, err := r.scopeOpts(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return r.repo.FindAllIncludingDeleted(ctx, limit, offset, dest, opts...)
+}
+
+// ExistsByID reports whether an entity of the tenant has id
+func (r *TenantScopedRepository[T]) ExistsByID(ctx context.Context, id models.EntityID) (bool, error) {
+	tenant, ok, err := r.tenant(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return r.repo.ExistsByID(ctx, id)
+	}
+	if isZeroID(id) {
+		return false, newError(errors.ErrorTypeValidation, r.repo.tableName, "ExistsByID", "ID cannot be nil")
+	}
+
+	conds, err := r.scope(tenant, []interface{}{clause.Eq{Column: idColumn, Value: id}})
+	if err != nil {
+		return false, err
+	}
+	return r.repo.ExistsByConditions(ctx, conds...)
+}
+
+// ExistsByKey reports whether an entity of the tenant has key
+func (r *TenantScopedRepository[T]) ExistsByKey(ctx context.Context, key map[string]interface{}) (bool, error) {
+	tenant, ok, err := r.tenant(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return r.repo.ExistsByKey(ctx, key)
+	}
+
+	cond, err := r.repo.keyCond("ExistsByKey", key)
+	if err != nil {
+		return false, err
+	}
+	conds, err := r.scope(tenant, []interface{}{cond})
+	if err != nil {
+		return false, err
+	}
+	return r.repo.ExistsByConditions(ctx, conds...)
+}
+
+// ExistsByConditions reports whether an entity of the tenant matches conds
+func (r *TenantScopedRepository[T]) ExistsByConditions(ctx context.Context, conds ...interface{}) (bool, error) {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return false, err
+	}
+	return r.repo.ExistsByConditions(ctx, conds...)
+}
+
+// CountByConditions counts the entities of the tenant matching conds
+func (r *TenantScopedRepository[T]) CountByConditions(ctx context.Context, conds ...interface{}) (int64, error) {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return 0, err
+	}
+	return r.repo.CountByConditions(ctx, conds...)
+}
+
+// PluckByConditions loads column of the entities of the tenant into dest
+func (r *TenantScopedRepository[T]) PluckByConditions(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return err
+	}
+	return r.repo.PluckByConditions(ctx, column, dest, conds...)
+}
+
+// DistinctPluckByConditions loads the distinct values of column of the
+// entities of the tenant into dest
+func (r *TenantScopedRepository[T]) DistinctPluckByConditions(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return err
+	}
+	return r.repo.DistinctPluckByConditions(ctx, column, dest, conds...)
+}
+
+// TimeSeriesCount counts the entities of the tenant per time bucket
+func (r *TenantScopedRepository[T]) TimeSeriesCount(ctx context.Context, timeColumn string, interval dialect.TimeUnit, conds ...interface{}) ([]TimeBucketCount, error) {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return nil, err
+	}
+	return r.repo.TimeSeriesCount(ctx, timeColumn, interval, conds...)
+}
+
+// PercentileBy computes percentiles of column over the entities of the tenant
+func (r *TenantScopedRepository[T]) PercentileBy(ctx context.Context, column string, percentiles []float64, conds ...interface{}) ([]PercentileValue, error) {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return nil, err
+	}
+	return r.repo.PercentileBy(ctx, column, percentiles, conds...)
+}
+
+// HistogramBy buckets column over the entities of the tenant
+func (r *TenantScopedRepository[T]) HistogramBy(ctx context.Context, column string, bounds []float64, conds ...interface{}) ([]HistogramBucket, error) {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return nil, err
+	}
+	return r.repo.HistogramBy(ctx, column, bounds, conds...)
+}
+
+// SumByConditions sums column over the entities of the tenant
+func (r *TenantScopedRepository[T]) SumByConditions(ctx context.Context, column string, conds ...interface{}) (float64, error) {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return 0, err
+	}
+	return r.repo.SumByConditions(ctx, column, conds...)
+}
+
+// AvgByConditions averages column over the entities of the tenant
+func (r *TenantScopedRepository[T]) AvgByConditions(ctx context.Context, column string, conds ...interface{}) (float64, error) {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return 0, err
+	}
+	return r.repo.AvgByConditions(ctx, column, conds...)
+}
+
+// MinBy scans the smallest value of column over the entities of the tenant
+func (r *TenantScopedRepository[T]) MinBy(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return err
+	}
+	return r.repo.MinBy(ctx, column, dest, conds...)
+}
+
+// MaxBy scans the largest value of column over the entities of the tenant
+func (r *TenantScopedRepository[T]) MaxBy(ctx context.Context, column string, dest interface{}, conds ...interface{}) error {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return err
+	}
+	return r.repo.MaxBy(ctx, column, dest, conds...)
+}
+
+// GroupBy aggregates the entities of the tenant per group
+func (r *TenantScopedRepository[T]) GroupBy(ctx context.Context, groupCols []string, aggregates []Aggregate, conds ...interface{}) ([]map[string]interface{}, error) {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return nil, err
+	}
+	return r.repo.GroupBy(ctx, groupCols, aggregates, conds...)
+}
+
+// SampleByConditions samples the entities of the tenant matching conds
+func (r *TenantScopedRepository[T]) SampleByConditions(ctx context.Context, n int, strategy SampleStrategy, dest *[]T, conds ...interface{}) error {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return err
+	}
+	return r.repo.SampleByConditions(ctx, n, strategy, dest, conds...)
+}
+
+// EstimateCount estimates the entities of the tenant matching conds; scoped
+// counts cannot use table statistics and are exact
+func (r *TenantScopedRepository[T]) EstimateCount(ctx context.Context, conds ...interface{}) (int64, error) {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return 0, err
+	}
+	return r.repo.EstimateCount(ctx, conds...)
+}
+
+// TableStats returns the statistics of the whole table, all tenants included
+func (r *TenantScopedRepository[T]) TableStats(ctx context.Context) (*TableStats, error) {
+	return r.repo.TableStats(ctx)
+}
+
+// TakeByConditions takes an entity of the tenant matching conds
+func (r *TenantScopedRepository[T]) TakeByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return err
+	}
+	return r.repo.TakeByConditions(ctx, dest, conds...)
+}
+
+// LastByConditions finds the last entity of the tenant matching conds
+func (r *TenantScopedRepository[T]) LastByConditions(ctx context.Context, dest *T, conds ...interface{}) error {
+	conds, err := r.scopeConds(ctx, conds)
+	if err != nil {
+		return err
+	}
+	return r.repo.LastByConditions(ctx, dest, conds...)
+}
+
+// Begin begins a transaction
+func (r *TenantScopedRepository[T]) Begin(ctx context.Context) (*gorm.DB, error) {
+	return r.repo.Begin(ctx)
+}
+
+// Commit commits the transaction
+func (r *TenantScopedRepository[T]) Commit(ctx context.Context) error {
+	return r.repo.Commit(ctx)
+}
+
+// Rollback rolls back the transaction
+func (r *TenantScopedRepository[T]) Rollback(ctx context.Context) error {
+	return r.repo.Rollback(ctx)
+}
+
+// SavePoint sets a named savepoint in the transaction
+func (r *TenantScopedRepository[T]) SavePoint(ctx context.Context, name string) error {
+	return r.repo.SavePoint(ctx, name)
+}
+
+// NewSavePoint sets a savepoint with a generated name in the transaction
+func (r *TenantScopedRepository[T]) NewSavePoint(ctx context.Context) (string, error) {
+	return r.repo.NewSavePoint(ctx)
+}
+
+// RollbackTo rolls the transaction back to a named savepoint
+func (r *TenantScopedRepository[T]) RollbackTo(ctx context.Context, name string) error {
+	return r.repo.RollbackTo(ctx, name)
+}
+
+// WithTransaction runs fn in a transaction with a repository scoped to the
+// same tenant
+func (r *TenantScopedRepository[T]) WithTransaction(ctx context.Context, fn func(Repository[T]) error) error {
+	if fn == nil {
+		return r.repo.WithTransaction(ctx, nil)
+	}
+	return r.repo.WithTransaction(ctx, func(tx Repository[T]) error {
+		scoped := *r
+		scoped.repo = tx.(*BaseRepository[T])
+		return fn(&scoped)
+	})
+}
+
+// WithTransactionOpts runs fn in a transaction related to the one in progress
+// by opts.Propagation with a repository scoped to the same tenant
+func (r *TenantScopedRepository[T]) WithTransactionOpts(ctx context.Context, opts TxOptions, fn func(ctx context.Context, tx Repository[T]) error) error {
+	if fn == nil {
+		return r.repo.WithTransactionOpts(ctx, opts, nil)
+	}
+	return r.repo.WithTransactionOpts(ctx, opts, func(ctx context.Context, tx Repository[T]) error {
+		scoped := *r
+		scoped.repo = tx.(*BaseRepository[T])
+		return fn(ctx, &scoped)
+	})
+}
+
+// WithTx returns the repository bound to the transaction carried by ctx,
+// scoped to the same tenant
+func (r *TenantScopedRepository[T]) WithTx(ctx context.Context) Repository[T] {
+	scoped := *r
+	scoped.repo = r.repo.WithTx(ctx).(*BaseRepository[T])
+	return &scoped
+}
+
+// AfterCommit registers fn to run once the surrounding transaction commits
+func (r *TenantScopedRepository[T]) AfterCommit(ctx context.Context, fn TxHook) {
+	r.repo.AfterCommit(ctx, fn)
+}
+
+// AfterRollback registers fn to run if the surrounding transaction rolls back
+func (r *TenantScopedRepository[T]) AfterRollback(ctx context.Context, fn TxHook) {
+	r.repo.AfterRollback(ctx, fn)
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"gorm.io/gorm"
+)
+
+const (
+	// queryTimeoutKey is the statement setting of the query timeout of the
+	// statements of a repository
+	queryTimeoutKey = "ormx:query_timeout"
+	// queryBoundKey is the statement instance key of the bound of a statement
+	queryBoundKey = "ormx:query_bound"
+)
+
+// queryTimeouts is the gorm plugin bounding the statements carrying a query
+// timeout setting. Repositories configured with RepositoryConfig.QueryTimeout
+// register it on their databases.
+//
+// Statements run through Row and Rows hand their rows to the caller, which
+// reads them after the callbacks returned, and are only bounded by their
+// context.
+type queryTimeouts struct{}
+
+// queryTimeoutsMu serializes the registrations of the plugin
+var queryTimeoutsMu sync.Mutex
+
+// queryTimeoutsUsed holds the callbacks of the databases the plugin is
+// registered on, shared by their sessions
+var queryTimeoutsUsed sync.Map
+
+// useQueryTimeouts registers the plugin on db unless it already is
+func useQueryTimeouts(db *gorm.DB) error {
+	if _, ok := queryTimeoutsUsed.Load(db.Callback()); ok {
+		return nil
+	}
+	queryTimeoutsMu.Lock()
+	defer queryTimeoutsMu.Unlock()
+
+	if _, ok := db.Config.Plugins[queryTimeouts{}.Name()]; !ok {
+		if err := db.Use(queryTimeouts{}); err != nil {
+			return err
+		}
+	}
+	queryTimeoutsUsed.Store(db.Callback(), struct{}{})
+	return nil
+}
+
+// Name returns the plugin name
+func (queryTimeouts) Name() string {
+	return "ormx:query_timeouts"
+}
+
+// Initialize registers the callbacks bounding the create, query, update,
+// delete and raw statements of db, default transaction included
+func (p queryTimeouts) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	err := stderrors.Join(
+		callbacks.Create().Before("*").Register("ormx:timeout_bound_create", p.bound),
+		callbacks.Create().After("*").Register("ormx:timeout_release_create", p.release),
+		callbacks.Query().Before("*").Register("ormx:timeout_bound_query", p.bound),
+		callbacks.Query().After("*").Register("ormx:timeout_release_query", p.release),
+		callbacks.Update().Before("*").Register("ormx:timeout_bound_update", p.bound),
+		callbacks.Update().After("*").Register("ormx:timeout_release_update", p.release),
+		callbacks.Delete().Before("*").Register("ormx:timeout_bound_delete", p.bound),
+		callbacks.Delete().After("*").Register("ormx:timeout_release_delete", p.release),
+		callbacks.Raw().Before("*").Register("ormx:timeout_bound_raw", p.bound),
+		callbacks.Raw().After("*").Register("ormx:timeout_release_raw", p.release),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register query timeout callbacks: %w", err)
+	}
+	return nil
+}
+
+// queryBound is the context of a statement before it was bounded, and the
+// cancellation of the bound
+type queryBound struct {
+	parent context.Context
+	cancel context.CancelFunc
+}
+
+// bound gives the statement a context expiring after its query timeout,
+// unless its context expires sooner
+func (queryTimeouts) bound(db *gorm.DB) {
+	value, ok := db.Get(queryTimeoutKey)
+	if !ok || db.Error != nil {
+		return
+	}
+	timeout, _ := value.(time.Duration)
+	parent := db.Statement.Context
+	if timeout <= 0 || parent == nil {
+		return
+	}
+	if deadline, ok := parent.Deadline(); ok && time.Until(deadline) <= timeout {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	db.Statement.Context = ctx
+	db.InstanceSet(queryBoundKey, &queryBound{parent: parent, cancel: cancel})
+}
+
+// release cancels the bound of the statement and restores its context, so
+// sessions reusing the statement do not inherit the expired deadline
+func (queryTimeouts) release(db *gorm.DB) {
+	value, ok := db.InstanceGet(queryBoundKey)
+	if !ok {
+		return
+	}
+	bound, _ := value.(*queryBound)
+	if bound == nil {
+		return
+	}
+	bound.cancel()
+	db.Statement.Context = bound.parent
+	db.InstanceSet(queryBoundKey, (*queryBound)(nil))
+}
+
+// withQueryTimeout returns db with the query timeout of the repository set
+// on its statements, registering the plugin enforcing it on db
+func (r *BaseRepository[T]) withQueryTimeout(db *gorm.DB) *gorm.DB {
+	if r.config.QueryTimeout <= 0 {
+		return db
+	}
+	if err := useQueryTimeouts(db); err != nil {
+		r.logger.Warn(context.Background(), "Query timeout not enforced",
+			logging.String("table", r.tableName),
+			logging.ErrorField("error", err))
+		return db
+	}
+	return db.Set(queryTimeoutKey, r.config.QueryTimeout).Session(&gorm.Session{})
+}
+
+// transactionContext returns ctx bounded by the transaction timeout of the
+// repository for a transaction begun on db; transactions nested in one in
+// progress share its bound
+func (r *BaseRepository[T]) transactionContext(ctx context.Context, db *gorm.DB) (context.Context, context.CancelFunc) {
+	if r.config.TransactionTimeout <= 0 || isTransaction(db) {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, r.config.TransactionTimeout)
+}
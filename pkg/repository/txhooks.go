@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+)
+
+// TxHook is a callback run after a transaction completes
+type TxHook func(ctx context.Context)
+
+// txHooks collects the callbacks registered during a transaction
+type txHooks struct {
+	mu            sync.Mutex
+	afterCommit   []TxHook
+	afterRollback []TxHook
+	registered    map[string]bool
+	savepoints    []savepointMark
+}
+
+// add registers fn to run after commit or after rollback
+func (h *txHooks) add(commit bool, fn TxHook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if commit {
+		h.afterCommit = append(h.afterCommit, fn)
+	} else {
+		h.afterRollback = append(h.afterRollback, fn)
+	}
+}
+
+// once reports whether key is seen for the first time in the transaction,
+// for callbacks registered once however many writes ask for them
+func (h *txHooks) once(key string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.registered[key] {
+		return false
+	}
+	if h.registered == nil {
+		h.registered = make(map[string]bool)
+	}
+	h.registered[key] = true
+	return true
+}
+
+// mergeInto hands the callbacks of a nested transaction over to its parent,
+// which decides whether they run
+func (h *txHooks) mergeInto(parent *txHooks) {
+	h.mu.Lock()
+	afterCommit, afterRollback := h.afterCommit, h.afterRollback
+	h.afterCommit, h.afterRollback = nil, nil
+	h.mu.Unlock()
+
+	parent.mu.Lock()
+	defer parent.mu.Unlock()
+	parent.afterCommit = append(parent.afterCommit, afterCommit...)
+	parent.afterRollback = append(parent.afterRollback, afterRollback...)
+}
+
+// run runs the after commit or after rollback callbacks in registration order.
+// A panicking callback is logged and does not prevent the others from running.
+func (h *txHooks) run(ctx context.Context, committed bool, logger logging.Logger) {
+	h.mu.Lock()
+	hooks := h.afterRollback
+	if committed {
+		hooks = h.afterCommit
+	}
+	h.afterCommit, h.afterRollback = nil, nil
+	h.mu.Unlock()
+
+	for _, fn := range hooks {
+		runTxHook(ctx, fn, committed, logger)
+	}
+}
+
+// runTxHook runs fn, recovering from panics
+func runTxHook(ctx context.Context, fn TxHook, committed bool, logger logging.Logger) {
+	defer func() {
+		if p := recover(); p != nil && logger != nil {
+			logger.Error(ctx, "Transaction hook panicked",
+				logging.Bool("committed", committed),
+				logging.ErrorField("error", fmt.Errorf("%v", p)))
+		}
+	}()
+	fn(ctx)
+}
+
+// AfterCommit registers fn to run once the transaction the repository is bound
+// to (the repository passed to WithTransaction's function) commits, e.g. to
+// invalidate caches or publish events only for persisted work. Callbacks of a
+// nested transaction wait for the outermost commit. Outside a transaction fn
+// runs immediately.
+func (r *BaseRepository[T]) AfterCommit(ctx context.Context, fn TxHook) {
+	if fn == nil {
+		return
+	}
+	if r.hooks == nil {
+		runTxHook(ctx, fn, true, r.logger)
+		return
+	}
+	r.hooks.add(true, fn)
+}
+
+// AfterRollback registers fn to run if the transaction the repository is bound
+// to rolls back, including a nested transaction rolled back to its savepoint
+// and a parent transaction rolling back after the nested one succeeded.
+// Outside a transaction fn is never run.
+func (r *BaseRepository[T]) AfterRollback(ctx context.Context, fn TxHook) {
+	if fn == nil || r.hooks == nil {
+		return
+	}
+	r.hooks.add(false, fn)
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"gorm.io/gorm"
+)
+
+// ContextWithTx returns a context carrying tx, a transaction begun and
+// completed by the caller, so repositories join it through WithTx and
+// WithTransactionOpts. The caller owns the commit: AfterCommit callbacks of
+// repositories joining tx run immediately, and AfterRollback callbacks never.
+func ContextWithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	if tx == nil {
+		return ctx
+	}
+	return contextWithTxScope(ctx, &txScope{db: tx})
+}
+
+// WithTx returns the repository bound to the transaction carried by ctx (see
+// UnitOfWork and ContextWithTx), or the repository itself when ctx carries no
+// transaction or the repository is already bound to one
+func (r *BaseRepository[T]) WithTx(ctx context.Context) Repository[T] {
+	if scope := r.ambient(ctx); scope != nil && !isTransaction(r.db) {
+		return r.bind(scope)
+	}
+	return r
+}
+
+// UnitOfWork runs the operations of repositories of different entities in
+// one transaction, committed when the unit succeeds and rolled back when it
+// fails:
+//
+//	uow := repository.NewUnitOfWork(db, logger)
+//	err := uow.Do(ctx, func(ctx context.Context) error {
+//		if err := users.WithTx(ctx).Create(ctx, user); err != nil {
+//			return err
+//		}
+//		return orders.WithTx(ctx).Create(ctx, order)
+//	})
+type UnitOfWork struct {
+	db     *gorm.DB
+	logger logging.Logger
+	opts   TxOptions
+}
+
+// NewUnitOfWork creates a unit of work beginning its transactions on db
+func NewUnitOfWork(db *gorm.DB, logger logging.Logger) *UnitOfWork {
+	return &UnitOfWork{db: db, logger: logger}
+}
+
+// WithOptions returns a unit of work beginning its transactions with the
+// isolation level and read-only mode of opts. Propagation is ignored: a unit
+// of work joins the transaction in progress.
+func (u *UnitOfWork) WithOptions(opts TxOptions) *UnitOfWork {
+	clone := *u
+	clone.opts = opts
+	return &clone
+}
+
+// Do runs fn in a transaction carried by the context fn receives. The
+// transaction is committed when fn returns nil and rolled back when it
+// returns an error or panics; the AfterCommit and AfterRollback callbacks of
+// the repositories bound to it run afterwards. When ctx already carries a
+// transaction, fn joins it and its owner completes it.
+func (u *UnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if fn == nil {
+		return newError(errors.ErrorTypeValidation, "", "UnitOfWork.Do", "unit of work function cannot be nil")
+	}
+	if u.db == nil {
+		return newError(errors.ErrorTypeValidation, "", "UnitOfWork.Do", "unit of work database cannot be nil")
+	}
+	if ctx != nil {
+		if _, ok := ctx.Value(txScopeKey{}).(*txScope); ok {
+			return fn(ctx)
+		}
+	}
+
+	hooks := &txHooks{}
+	var references *deferredReferences
+	if contextFlag(ctx, deferredValidationKey{}) {
+		references = newDeferredReferences()
+	}
+
+	var panicErr error
+	err := u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		// Add panic recovery, rolling the transaction back
+		defer func() {
+			if p := recover(); p != nil {
+				panicErr = newError(errors.ErrorTypeTransaction, "", "UnitOfWork.Do", fmt.Sprintf("unit of work panicked: %v", p))
+				err = panicErr
+			}
+		}()
+
+		scope := &txScope{db: tx, hooks: hooks, references: references}
+		if err := fn(contextWithTxScope(ctx, scope)); err != nil {
+			return err
+		}
+		if references != nil {
+			return references.validate(tx)
+		}
+		return nil
+	}, &sql.TxOptions{Isolation: u.opts.Isolation, ReadOnly: u.opts.ReadOnly})
+
+	hooks.run(ctx, err == nil, u.logger)
+
+	if panicErr != nil {
+		return panicErr
+	}
+	if err != nil {
+		return operationError(err, "", "UnitOfWork.Do", "failed to execute unit of work")
+	}
+	return nil
+}
+
+// SavePoint sets a named savepoint in the transaction carried by ctx, the
+// context passed to Do's function, like the SavePoint of the repositories
+// bound to it. Outside a unit of work it returns ErrNoTransaction.
+func (u *UnitOfWork) SavePoint(ctx context.Context, name string) error {
+	scope := u.scope(ctx)
+	if scope == nil {
+		return ErrNoTransaction
+	}
+	if err := checkSavepoint(scope.db, "", "UnitOfWork.SavePoint", name); err != nil {
+		return err
+	}
+	if err := setSavepoint(ctx, scope.db, scope.hooks, name); err != nil {
+		return operationError(err, "", "UnitOfWork.SavePoint", fmt.Sprintf("failed to set savepoint %s", name))
+	}
+	return nil
+}
+
+// NewSavePoint sets a savepoint with a generated name in the transaction
+// carried by ctx and returns the name to pass to RollbackTo
+func (u *UnitOfWork) NewSavePoint(ctx context.Context) (string, error) {
+	name := nextSavepointName()
+	if err := u.SavePoint(ctx, name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// RollbackTo rolls the transaction carried by ctx back to the savepoint
+// named name, dropping the after commit callbacks registered since and
+// running its after rollback callbacks
+func (u *UnitOfWork) RollbackTo(ctx context.Context, name string) error {
+	scope := u.scope(ctx)
+	if scope == nil {
+		return ErrNoTransaction
+	}
+	if err := checkSavepoint(scope.db, "", "UnitOfWork.RollbackTo", name); err != nil {
+		return err
+	}
+	if err := rollbackToSavepoint(ctx, scope.db, scope.hooks, name, u.logger); err != nil {
+		return operationError(err, "", "UnitOfWork.RollbackTo", fmt.Sprintf("failed to roll back to savepoint %s", name))
+	}
+	return nil
+}
+
+// Step runs fn, a sub-step of the unit of work carried by ctx, behind a
+// savepoint. When fn fails, only its work is rolled back and its error
+// returned, so the unit of work may go on or try another way:
+//
+//	err := uow.Do(ctx, func(ctx context.Context) error {
+//		if err := uow.Step(ctx, chargeCard); err != nil {
+//			return uow.Step(ctx, invoiceLater)
+//		}
+//		return nil
+//	})
+func (u *UnitOfWork) Step(ctx context.Context, fn func(ctx context.Context) error) error {
+	if fn == nil {
+		return newError(errors.ErrorTypeValidation, "", "UnitOfWork.Step", "step function cannot be nil")
+	}
+	name, err := u.NewSavePoint(ctx)
+	if err != nil {
+		return err
+	}
+	if err := fn(ctx); err != nil {
+		if rbErr := u.RollbackTo(ctx, name); rbErr != nil {
+			return rbErr
+		}
+		return err
+	}
+	return nil
+}
+
+// scope returns the transaction of the unit of work carried by ctx, if any
+func (u *UnitOfWork) scope(ctx context.Context) *txScope {
+	if ctx == nil {
+		return nil
+	}
+	scope, _ := ctx.Value(txScopeKey{}).(*txScope)
+	return scope
+}
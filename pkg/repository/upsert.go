@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/seasbee/go-ormx/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ConflictOptions describes how an upsert resolves a conflict with an existing
+// row. The zero value updates every column of the row with the same primary
+// key.
+type ConflictOptions struct {
+	// Columns is the conflict target, unique columns identifying the existing
+	// row: the primary key when empty. MySQL resolves conflicts on any unique
+	// key and ignores it.
+	Columns []string
+	// DoNothing keeps the existing row, any unique key conflicting when
+	// Columns is empty, but for SQL Server and Oracle, which match the
+	// primary key
+	DoNothing bool
+	// DoUpdates lists the columns overwritten with the values of the upserted
+	// entity, all of them but the primary key and creation time when empty
+	DoUpdates []string
+	// Where only updates existing rows matching the condition, which may
+	// refer to the existing row by table name and to the upserted values as
+	// excluded, e.g. "users.version < excluded.version". Not supported by
+	// MySQL, SQL Server and Oracle.
+	Where     string
+	WhereArgs []interface{}
+	// ContinueOnError makes UpsertInBatches and UpsertInBatchesByConditions
+	// upsert the batches following a failing one instead of stopping at it.
+	// Within a transaction, Postgres refuses the statements following a
+	// failure anyway.
+	ContinueOnError bool
+}
+
+// OnConflict returns the options updating every column of the row
+// conflicting on columns
+func OnConflict(columns ...string) ConflictOptions {
+	return ConflictOptions{Columns: columns}
+}
+
+// OnConflictDoNothing returns the options keeping the row conflicting on
+// columns, on any unique key when no columns are given
+func OnConflictDoNothing(columns ...string) ConflictOptions {
+	return ConflictOptions{Columns: columns, DoNothing: true}
+}
+
+// onConflict renders the conflict options, restricting updates to the rows
+// matching conds
+func (r *BaseRepository[T]) onConflict(conflict ConflictOptions, conds []interface{}) (clause.OnConflict, error) {
+	conditional := conflict.Where != "" || len(conds) > 0
+	if conflict.DoNothing && (len(conflict.DoUpdates) > 0 || conditional) {
+		return clause.OnConflict{}, r.argumentError("Upsert", "conflict options cannot both do nothing and update")
+	}
+	if name := r.dialect.Name(); conditional && (name == "mysql" || r.mergesUpserts()) {
+		return clause.OnConflict{}, r.argumentError("Upsert", "conditional upserts are not supported by "+name)
+	}
+
+	onConflict := clause.OnConflict{DoNothing: conflict.DoNothing}
+	for _, column := range conflict.Columns {
+		if column == "" {
+			return clause.OnConflict{}, r.argumentError("Upsert", "conflict column cannot be empty")
+		}
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: column})
+	}
+	if len(onConflict.Columns) == 0 && !conflict.DoNothing {
+		onConflict.Columns = []clause.Column{{Name: r.primaryKeyColumn()}}
+	}
+	if conflict.DoNothing {
+		return onConflict, nil
+	}
+
+	if len(conflict.DoUpdates) > 0 {
+		onConflict.DoUpdates = clause.AssignmentColumns(conflict.DoUpdates)
+	} else {
+		onConflict.UpdateAll = true
+	}
+	if conflict.Where != "" {
+		onConflict.Where.Exprs = append(onConflict.Where.Exprs, clause.Expr{SQL: conflict.Where, Vars: conflict.WhereArgs})
+	}
+	if len(conds) > 0 {
+		stmt := r.db.Session(&gorm.Session{NewDB: true}).Statement
+		onConflict.Where.Exprs = append(onConflict.Where.Exprs, stmt.BuildCondition(conds[0], conds[1:]...)...)
+	}
+	return onConflict, nil
+}
+
+// byPrimaryKey reports whether the conflict target is the primary key
+func (r *BaseRepository[T]) byPrimaryKey(conflict ConflictOptions) bool {
+	return len(conflict.Columns) == 0 || (len(conflict.Columns) == 1 && conflict.Columns[0] == r.primaryKeyColumn())
+}
+
+// conflicting returns the stored row holding the values of entity on the
+// conflict columns, nil when there is none
+func (r *BaseRepository[T]) conflicting(ctx context.Context, entity *T, columns []string) *T {
+	s, err := r.schema()
+	if err != nil {
+		return nil
+	}
+
+	value := reflect.ValueOf(entity).Elem()
+	query := r.db.WithContext(ctx).Unscoped()
+	for _, column := range columns {
+		field := s.LookUpField(column)
+		if field == nil {
+			return nil
+		}
+		fieldValue, _ := field.ValueOf(ctx, value)
+		query = query.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: fieldValue})
+	}
+
+	var existing T
+	if err := query.Take(&existing).Error; err != nil {
+		return nil
+	}
+	return &existing
+}
+
+// upsertOne inserts entity or resolves its conflict with an existing row.
+// When the conflict target is not the primary key the entity takes the ID of
+// the row it was merged into. It returns the row before and after the write,
+// as reported to change hooks.
+func (r *BaseRepository[T]) upsertOne(ctx context.Context, entity *T, conflict ConflictOptions, conds []interface{}) (*T, *T, error) {
+	onConflict, err := r.onConflict(conflict, conds)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	before := r.upsertBefore(ctx, entity, conflict)
+
+	if r.mergesUpserts() {
+		err = r.mergeRows(ctx, []*T{entity}, conflict)
+	} else {
+		err = r.db.WithContext(ctx).Clauses(onConflict).Create(entity).Error
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	after := r.upserted(ctx, entity, conflict, conds)
+	if after != entity {
+		r.setEntityID(entity, r.getEntityID(after))
+	}
+	return before, after, nil
+}
+
+// upsertBefore returns the stored row entity conflicts with when change hooks
+// are registered, nil otherwise or when there is none
+func (r *BaseRepository[T]) upsertBefore(ctx context.Context, entity *T, conflict ConflictOptions) *T {
+	if len(r.changeHooks) == 0 {
+		return nil
+	}
+	if r.byPrimaryKey(conflict) {
+		return r.snapshot(ctx, r.getEntityID(entity))
+	}
+	return r.conflicting(ctx, entity, conflict.Columns)
+}
+
+// upserted returns the row entity was upserted into: the stored row when it
+// may differ from entity, entity otherwise
+func (r *BaseRepository[T]) upserted(ctx context.Context, entity *T, conflict ConflictOptions, conds []interface{}) *T {
+	if !r.byPrimaryKey(conflict) {
+		if stored := r.conflicting(ctx, entity, conflict.Columns); stored != nil {
+			return stored
+		}
+	} else if conflict.DoNothing || conflict.Where != "" || len(conds) > 0 || len(conflict.DoUpdates) > 0 {
+		// The stored row may differ from the entity
+		if stored := r.snapshot(ctx, r.getEntityID(entity)); stored != nil {
+			return stored
+		}
+	}
+	return entity
+}
+
+// mergesUpserts reports whether upserts run the MERGE statements of the
+// dialect instead of gorm's ON CONFLICT clause: SQL Server, whose gorm
+// dialector merges without HOLDLOCK so that concurrent upserts of a new key
+// may both insert, and Oracle, whose dialectors have no upserts
+func (r *BaseRepository[T]) mergesUpserts() bool {
+	name := r.dialect.Name()
+	return name == "sqlserver" || name == "oracle"
+}
+
+// mergeRows upserts rows with the MERGE statement of the dialect, one per
+// row, in a transaction unless there is one already. The hooks and creation
+// times are handled as by BulkInsertFast, and IDs assigned by the database
+// are not read back.
+func (r *BaseRepository[T]) mergeRows(ctx context.Context, rows []*T, conflict ConflictOptions) error {
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+
+	merge := func(db *gorm.DB) error {
+		columns, values, err := bulkRows(db, s, rows)
+		if err != nil {
+			return err
+		}
+		statement := r.dialect.Upsert(s.Table, columns, r.conflictColumns(conflict), mergeUpdates(s, columns, conflict))
+		for i, row := range values {
+			if _, err := db.Statement.ConnPool.ExecContext(ctx, statement, row...); err != nil {
+				return err
+			}
+			if err := afterCreateHooks(db, rows[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	db := r.db.WithContext(ctx)
+	if len(rows) == 1 || isTransaction(db) {
+		return merge(db)
+	}
+	return db.Transaction(merge)
+}
+
+// conflictColumns returns the conflict target of conflict, the primary key
+// when it names none
+func (r *BaseRepository[T]) conflictColumns(conflict ConflictOptions) []string {
+	if len(conflict.Columns) > 0 {
+		return conflict.Columns
+	}
+	return []string{r.primaryKeyColumn()}
+}
+
+// mergeUpdates returns the columns a MERGE overwrites: none when doing
+// nothing, conflict.DoUpdates when given, and otherwise every inserted column
+// but the conflict target, the primary key and the creation time
+func mergeUpdates(s *schema.Schema, columns []string, conflict ConflictOptions) []string {
+	if conflict.DoNothing {
+		return nil
+	}
+	if len(conflict.DoUpdates) > 0 {
+		return conflict.DoUpdates
+	}
+
+	target := make(map[string]bool, len(conflict.Columns))
+	for _, column := range conflict.Columns {
+		target[column] = true
+	}
+	var updates []string
+	for _, column := range columns {
+		field := s.LookUpField(column)
+		if target[column] || field == nil || field.PrimaryKey || field.AutoCreateTime != 0 {
+			continue
+		}
+		updates = append(updates, column)
+	}
+	return updates
+}
+
+// setEntityID sets the ID of entity
+func (r *BaseRepository[T]) setEntityID(entity *T, id models.EntityID) {
+	if entity == nil {
+		return
+	}
+	setEntityIDOf(entity, id)
+}
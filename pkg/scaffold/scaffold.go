@@ -0,0 +1,150 @@
+// Package scaffold generates a runnable example service wired with the
+// subsystems of go-ormx: a configuration file loaded into a
+// config.DatabaseConfig, a connection pool with health checks, SQL migrations
+// applied on boot, a model with its repository, and HTTP endpoints serving
+// the model, the health status and Prometheus metrics.
+//
+//	files, err := scaffold.Generate("orders", scaffold.Options{Module: "example.com/orders"})
+//
+// The generated service is a starting point for adopters, meant to be read
+// and changed; `ormx new` is its command line front end.
+package scaffold
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"go/format"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// Options configures a generated service
+type Options struct {
+	// Module is the module path of the service (default the base name of its directory)
+	Module string
+	// Driver is the database driver: sqlite (default), postgres or mysql
+	Driver string
+	// ORMXPath is a local go-ormx checkout the service replaces the module
+	// with, to try unreleased changes; empty uses the published module
+	ORMXPath string
+}
+
+// driverDefaults are the settings of each supported driver
+var driverDefaults = map[string]struct {
+	Port     int
+	Database string
+	Username string
+	UUIDType string
+	TimeType string
+	TextType string
+}{
+	"sqlite":   {Port: 5432, Database: "app.db", Username: "app", UUIDType: "TEXT", TimeType: "DATETIME", TextType: "TEXT"},
+	"postgres": {Port: 5432, Database: "app", Username: "postgres", UUIDType: "UUID", TimeType: "TIMESTAMPTZ", TextType: "VARCHAR(255)"},
+	"mysql":    {Port: 3306, Database: "app", Username: "root", UUIDType: "CHAR(36)", TimeType: "DATETIME(3)", TextType: "VARCHAR(255)"},
+}
+
+// Generate writes the service to dir, creating it when needed, and returns
+// the paths of the files written relative to dir. It refuses to write into a
+// directory that is not empty.
+func Generate(dir string, opts Options) ([]string, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("directory is required")
+	}
+	if opts.Driver == "" {
+		opts.Driver = "sqlite"
+	}
+	driver, ok := driverDefaults[opts.Driver]
+	if !ok {
+		return nil, fmt.Errorf("unsupported driver %q: use sqlite, postgres or mysql", opts.Driver)
+	}
+	name := filepath.Base(filepath.Clean(dir))
+	if opts.Module == "" {
+		opts.Module = name
+	}
+	if opts.ORMXPath != "" {
+		abs, err := filepath.Abs(opts.ORMXPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve go-ormx path: %w", err)
+		}
+		opts.ORMXPath = filepath.ToSlash(abs)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	if len(entries) > 0 {
+		return nil, fmt.Errorf("directory %s is not empty", dir)
+	}
+
+	data := map[string]interface{}{
+		"Name":     name,
+		"Module":   opts.Module,
+		"Driver":   opts.Driver,
+		"ORMXPath": opts.ORMXPath,
+		"DB":       driver,
+	}
+
+	var sources []string
+	err = fs.WalkDir(templates, "templates", func(source string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			sources = append(sources, source)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates: %w", err)
+	}
+
+	files := make([]string, 0, len(sources))
+	for _, source := range sources {
+		file, content, err := render(source, data)
+		if err != nil {
+			return nil, err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create directory for %s: %w", file, err)
+		}
+		if err := os.WriteFile(target, content, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", file, err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// render executes the template source and returns the path of the file it
+// generates, relative to the service directory, and its content; Go files
+// are formatted
+func render(source string, data map[string]interface{}) (string, []byte, error) {
+	text, err := templates.ReadFile(source)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read template %s: %w", source, err)
+	}
+	tmpl, err := template.New(source).Parse(string(text))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse template %s: %w", source, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", nil, fmt.Errorf("failed to render template %s: %w", source, err)
+	}
+
+	file := strings.TrimSuffix(strings.TrimPrefix(source, "templates/"), ".tmpl")
+	content := buf.Bytes()
+	if strings.HasSuffix(file, ".go") {
+		content, err = format.Source(content)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to format %s: %w", file, err)
+		}
+	}
+	return file, content, nil
+}
@@ -0,0 +1,90 @@
+// Package scopes provides reusable, composable filters for repository reads.
+// A scope is a clause.Expression, so it can be passed wherever the repository
+// accepts conditions:
+//
+//	repo.FindAllByConditionsWithOffset(ctx, 20, 0, &users,
+//		scopes.ActiveOnly(), scopes.CreatedBetween(from, to))
+//
+//	repo.Query().Scopes(scopes.ByTenant(tenantID), scopes.UpdatedSince(since)).Find(ctx)
+//
+// Columns follow the models.BaseModel naming and are qualified with the
+// queried table, so scopes stay unambiguous in joins.
+package scopes
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// Column names used by the scopes
+const (
+	CreatedAtColumn = "created_at"
+	UpdatedAtColumn = "updated_at"
+	TenantIDColumn  = "tenant_id"
+	IsActiveColumn  = "is_active"
+)
+
+// column returns column qualified with the queried table
+func column(name string) clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: name}
+}
+
+// CreatedBetween matches entities created in [from, to). A zero bound leaves
+// that side open.
+func CreatedBetween(from, to time.Time) clause.Expression {
+	return Between(CreatedAtColumn, from, to)
+}
+
+// UpdatedSince matches entities updated at or after since
+func UpdatedSince(since time.Time) clause.Expression {
+	return clause.Gte{Column: column(UpdatedAtColumn), Value: since}
+}
+
+// Between matches entities whose time column is in [from, to). A zero bound
+// leaves that side open.
+func Between(name string, from, to time.Time) clause.Expression {
+	var exprs []clause.Expression
+	if !from.IsZero() {
+		exprs = append(exprs, clause.Gte{Column: column(name), Value: from})
+	}
+	if !to.IsZero() {
+		exprs = append(exprs, clause.Lt{Column: column(name), Value: to})
+	}
+	return clause.And(exprs...)
+}
+
+// ByTenant matches entities owned by tenantID
+func ByTenant(tenantID interface{}) clause.Expression {
+	return clause.Eq{Column: column(TenantIDColumn), Value: tenantID}
+}
+
+// ActiveOnly matches entities whose is_active flag is set
+func ActiveOnly() clause.Expression {
+	return clause.Eq{Column: column(IsActiveColumn), Value: true}
+}
+
+// IDIn matches entities whose primary key is one of ids. No ids matches nothing.
+func IDIn(ids ...uuid.UUID) clause.Expression {
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = id
+	}
+	return clause.IN{Column: clause.PrimaryColumn, Values: values}
+}
+
+// All matches entities matching every scope
+func All(scopes ...clause.Expression) clause.Expression {
+	return clause.And(scopes...)
+}
+
+// Any matches entities matching at least one scope
+func Any(scopes ...clause.Expression) clause.Expression {
+	return clause.Or(scopes...)
+}
+
+// Not matches entities not matching scope
+func Not(scope clause.Expression) clause.Expression {
+	return clause.Not(scope)
+}
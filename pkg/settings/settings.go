@@ -0,0 +1,446 @@
+// Package settings provides a namespaced key/value settings store built on
+// the repository package. Values are stored JSON encoded in the ormx_settings
+// table, read through a BaseRepository (and so its metrics and read routing),
+// cached for a configurable TTL and versioned for optimistic updates.
+// Subscribers are notified of every change made through the store.
+package settings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrSettingNotFound is returned when a setting does not exist
+	ErrSettingNotFound = errors.New("setting not found")
+	// ErrVersionConflict is returned by CompareAndSet when the setting was
+	// changed since the expected version was read
+	ErrVersionConflict = errors.New("setting version conflict")
+)
+
+// Setting represents a stored setting. Value holds the JSON encoded value and
+// Version is incremented on every change.
+type Setting struct {
+	Namespace string    `gorm:"primaryKey;size:191" json:"namespace"`
+	Key       string    `gorm:"primaryKey;size:191" json:"key"`
+	Value     string    `gorm:"type:text;not null" json:"value"`
+	Version   int64     `gorm:"not null;default:1" json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName returns the table used to persist settings
+func (Setting) TableName() string {
+	return "ormx_settings"
+}
+
+// Decode unmarshals the setting value into dest
+func (s *Setting) Decode(dest interface{}) error {
+	if err := json.Unmarshal([]byte(s.Value), dest); err != nil {
+		return fmt.Errorf("failed to decode setting %s/%s: %w", s.Namespace, s.Key, err)
+	}
+	return nil
+}
+
+// Change describes a change made through the store
+type Change struct {
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+	Value     string `json:"value,omitempty"`
+	Version   int64  `json:"version"`
+	Deleted   bool   `json:"deleted"`
+}
+
+// SettingsConfig represents settings store configuration
+type SettingsConfig struct {
+	// CacheTTL is how long values (and missing keys) are cached; changes made
+	// by other processes become visible once it elapses. Zero disables caching.
+	CacheTTL time.Duration `json:"cache_ttl"`
+}
+
+// DefaultSettingsConfig returns default settings store configuration
+func DefaultSettingsConfig() *SettingsConfig {
+	return &SettingsConfig{
+		CacheTTL: 30 * time.Second,
+	}
+}
+
+// cacheEntry represents a cached setting, nil for a missing key
+type cacheEntry struct {
+	setting   *Setting
+	expiresAt time.Time
+}
+
+// SettingsStore stores namespaced settings
+type SettingsStore struct {
+	db     *gorm.DB
+	repo   *repository.BaseRepository[Setting]
+	logger logging.Logger
+	config *SettingsConfig
+
+	cacheMu sync.RWMutex
+	cache   map[string]cacheEntry
+
+	subscribersMu    sync.RWMutex
+	subscribers      map[int]func(Change)
+	nextSubscriberID int
+}
+
+// NewSettingsStore creates a settings store, creating the table if needed
+func NewSettingsStore(db *gorm.DB, logger logging.Logger, config *SettingsConfig) (*SettingsStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+	if config == nil {
+		config = DefaultSettingsConfig()
+	}
+
+	if err := db.AutoMigrate(&Setting{}); err != nil {
+		return nil, fmt.Errorf("failed to create settings table: %w", err)
+	}
+
+	repoConfig := repository.DefaultRepositoryConfig()
+	repoConfig.EnableValidation = false
+	repoConfig.DeleteMode = repository.DeleteModeHard
+
+	return &SettingsStore{
+		db:          db,
+		repo:        repository.NewBaseRepository[Setting](db, logger, repoConfig),
+		logger:      logger,
+		config:      config,
+		cache:       make(map[string]cacheEntry),
+		subscribers: make(map[int]func(Change)),
+	}, nil
+}
+
+// Repository returns the repository reading the settings table
+func (s *SettingsStore) Repository() *repository.BaseRepository[Setting] {
+	return s.repo
+}
+
+// Get returns the setting namespace/key. The error wraps ErrSettingNotFound
+// when it does not exist.
+func (s *SettingsStore) Get(ctx context.Context, namespace, key string) (*Setting, error) {
+	if setting, ok := s.cached(namespace, key); ok {
+		if setting == nil {
+			return nil, fmt.Errorf("%w: %s/%s", ErrSettingNotFound, namespace, key)
+		}
+		return setting, nil
+	}
+
+	var setting Setting
+	err := s.repo.FindFirstByConditions(ctx, &setting, settingCondition(namespace, key))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		s.store(namespace, key, nil)
+		return nil, fmt.Errorf("%w: %s/%s", ErrSettingNotFound, namespace, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get setting %s/%s: %w", namespace, key, err)
+	}
+
+	s.store(namespace, key, &setting)
+	return &setting, nil
+}
+
+// GetJSON decodes the setting namespace/key into dest. The error wraps
+// ErrSettingNotFound when it does not exist.
+func (s *SettingsStore) GetJSON(ctx context.Context, namespace, key string, dest interface{}) error {
+	setting, err := s.Get(ctx, namespace, key)
+	if err != nil {
+		return err
+	}
+	return setting.Decode(dest)
+}
+
+// GetString returns the string setting namespace/key, or fallback when it does not exist
+func (s *SettingsStore) GetString(ctx context.Context, namespace, key string, fallback string) (string, error) {
+	value := fallback
+	err := s.getTyped(ctx, namespace, key, &value)
+	if err != nil {
+		return fallback, err
+	}
+	return value, nil
+}
+
+// GetInt returns the integer setting namespace/key, or fallback when it does not exist
+func (s *SettingsStore) GetInt(ctx context.Context, namespace, key string, fallback int64) (int64, error) {
+	value := fallback
+	err := s.getTyped(ctx, namespace, key, &value)
+	if err != nil {
+		return fallback, err
+	}
+	return value, nil
+}
+
+// GetFloat returns the numeric setting namespace/key, or fallback when it does not exist
+func (s *SettingsStore) GetFloat(ctx context.Context, namespace, key string, fallback float64) (float64, error) {
+	value := fallback
+	err := s.getTyped(ctx, namespace, key, &value)
+	if err != nil {
+		return fallback, err
+	}
+	return value, nil
+}
+
+// GetBool returns the boolean setting namespace/key, or fallback when it does not exist
+func (s *SettingsStore) GetBool(ctx context.Context, namespace, key string, fallback bool) (bool, error) {
+	value := fallback
+	err := s.getTyped(ctx, namespace, key, &value)
+	if err != nil {
+		return fallback, err
+	}
+	return value, nil
+}
+
+// GetDuration returns the duration setting namespace/key, or fallback when it
+// does not exist. Durations may be stored as nanoseconds (as encoded by Set)
+// or as strings such as "1m30s".
+func (s *SettingsStore) GetDuration(ctx context.Context, namespace, key string, fallback time.Duration) (time.Duration, error) {
+	var raw json.RawMessage
+	if err := s.getTyped(ctx, namespace, key, &raw); err != nil || raw == nil {
+		return fallback, err
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		value, err := time.ParseDuration(text)
+		if err != nil {
+			return fallback, fmt.Errorf("failed to decode setting %s/%s: %w", namespace, key, err)
+		}
+		return value, nil
+	}
+
+	value, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return fallback, fmt.Errorf("failed to decode setting %s/%s: %w", namespace, key, err)
+	}
+	return time.Duration(value), nil
+}
+
+// getTyped decodes the setting into dest, leaving dest untouched when it does not exist
+func (s *SettingsStore) getTyped(ctx context.Context, namespace, key string, dest interface{}) error {
+	err := s.GetJSON(ctx, namespace, key, dest)
+	if errors.Is(err, ErrSettingNotFound) {
+		return nil
+	}
+	return err
+}
+
+// List returns the settings of namespace ordered by key, bypassing the cache
+func (s *SettingsStore) List(ctx context.Context, namespace string) ([]Setting, error) {
+	var settings []Setting
+	err := s.db.WithContext(ctx).
+		Where(clause.Eq{Column: clause.Column{Name: "namespace"}, Value: namespace}).
+		Order(clause.OrderByColumn{Column: clause.Column{Name: "key"}}).
+		Find(&settings).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list settings of %s: %w", namespace, err)
+	}
+	return settings, nil
+}
+
+// Set creates or replaces the setting namespace/key with the JSON encoding of value
+func (s *SettingsStore) Set(ctx context.Context, namespace, key string, value interface{}) (*Setting, error) {
+	encoded, err := encode(namespace, key, value)
+	if err != nil {
+		return nil, err
+	}
+
+	var setting Setting
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "namespace"}, {Name: "key"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"value":      encoded,
+				"updated_at": now,
+				"version":    gorm.Expr("? + 1", clause.Column{Table: Setting{}.TableName(), Name: "version"}),
+			}),
+		}).Create(&Setting{Namespace: namespace, Key: key, Value: encoded, Version: 1, UpdatedAt: now}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where(settingCondition(namespace, key)).Take(&setting).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set setting %s/%s: %w", namespace, key, err)
+	}
+
+	s.changed(ctx, Change{Namespace: namespace, Key: key, Value: setting.Value, Version: setting.Version})
+	return &setting, nil
+}
+
+// CompareAndSet replaces the setting namespace/key only if its version is
+// still expectedVersion; an expectedVersion of 0 creates the setting only if
+// it does not exist. The error wraps ErrVersionConflict otherwise.
+func (s *SettingsStore) CompareAndSet(ctx context.Context, namespace, key string, value interface{}, expectedVersion int64) (*Setting, error) {
+	encoded, err := encode(namespace, key, value)
+	if err != nil {
+		return nil, err
+	}
+
+	setting := Setting{Namespace: namespace, Key: key, Value: encoded, Version: expectedVersion + 1, UpdatedAt: time.Now()}
+
+	var result *gorm.DB
+	if expectedVersion == 0 {
+		result = s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&setting)
+	} else {
+		result = s.db.WithContext(ctx).Model(&Setting{}).
+			Where(settingCondition(namespace, key)).
+			Where(clause.Eq{Column: clause.Column{Name: "version"}, Value: expectedVersion}).
+			Updates(map[string]interface{}{
+				"value":      setting.Value,
+				"version":    setting.Version,
+				"updated_at": setting.UpdatedAt,
+			})
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to set setting %s/%s: %w", namespace, key, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		s.invalidate(namespace, key)
+		return nil, fmt.Errorf("%w: %s/%s is no longer at version %d", ErrVersionConflict, namespace, key, expectedVersion)
+	}
+
+	s.changed(ctx, Change{Namespace: namespace, Key: key, Value: setting.Value, Version: setting.Version})
+	return &setting, nil
+}
+
+// Delete removes the setting namespace/key. Deleting a missing setting is not an error.
+func (s *SettingsStore) Delete(ctx context.Context, namespace, key string) error {
+	result := s.db.WithContext(ctx).Where(settingCondition(namespace, key)).Delete(&Setting{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete setting %s/%s: %w", namespace, key, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		s.invalidate(namespace, key)
+		return nil
+	}
+
+	s.changed(ctx, Change{Namespace: namespace, Key: key, Deleted: true})
+	return nil
+}
+
+// Subscribe registers fn to be called after every change made through the
+// store, and returns a function removing it. Subscribers run synchronously
+// and must not block.
+func (s *SettingsStore) Subscribe(fn func(Change)) func() {
+	s.subscribersMu.Lock()
+	defer s.subscribersMu.Unlock()
+
+	id := s.nextSubscriberID
+	s.nextSubscriberID++
+	s.subscribers[id] = fn
+
+	return func() {
+		s.subscribersMu.Lock()
+		defer s.subscribersMu.Unlock()
+		delete(s.subscribers, id)
+	}
+}
+
+// ClearCache drops every cached setting
+func (s *SettingsStore) ClearCache() {
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+	s.cache = make(map[string]cacheEntry)
+}
+
+// changed invalidates the cached setting and notifies subscribers
+func (s *SettingsStore) changed(ctx context.Context, change Change) {
+	s.invalidate(change.Namespace, change.Key)
+
+	if s.logger != nil {
+		s.logger.Debug(ctx, "Setting changed",
+			logging.String("namespace", change.Namespace),
+			logging.String("key", change.Key),
+			logging.Int64("version", change.Version),
+			logging.Bool("deleted", change.Deleted))
+	}
+
+	s.subscribersMu.RLock()
+	subscribers := make([]func(Change), 0, len(s.subscribers))
+	for _, fn := range s.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	s.subscribersMu.RUnlock()
+
+	for _, fn := range subscribers {
+		fn(change)
+	}
+}
+
+// cached returns the cached setting; ok is false on a cache miss and the
+// setting is nil for a cached missing key
+func (s *SettingsStore) cached(namespace, key string) (*Setting, bool) {
+	if s.config.CacheTTL <= 0 {
+		return nil, false
+	}
+
+	s.cacheMu.RLock()
+	entry, ok := s.cache[cacheKey(namespace, key)]
+	s.cacheMu.RUnlock()
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	if entry.setting == nil {
+		return nil, true
+	}
+	copied := *entry.setting
+	return &copied, true
+}
+
+// store caches setting, nil for a missing key
+func (s *SettingsStore) store(namespace, key string, setting *Setting) {
+	if s.config.CacheTTL <= 0 {
+		return
+	}
+
+	var copied *Setting
+	if setting != nil {
+		value := *setting
+		copied = &value
+	}
+
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+	s.cache[cacheKey(namespace, key)] = cacheEntry{setting: copied, expiresAt: time.Now().Add(s.config.CacheTTL)}
+}
+
+// invalidate drops the cached setting
+func (s *SettingsStore) invalidate(namespace, key string) {
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+	delete(s.cache, cacheKey(namespace, key))
+}
+
+// cacheKey returns the cache key of namespace/key
+func cacheKey(namespace, key string) string {
+	return namespace + "\x00" + key
+}
+
+// settingCondition matches the setting namespace/key
+func settingCondition(namespace, key string) clause.Expression {
+	return clause.And(
+		clause.Eq{Column: clause.Column{Name: "namespace"}, Value: namespace},
+		clause.Eq{Column: clause.Column{Name: "key"}, Value: key},
+	)
+}
+
+// encode returns the JSON encoding of value
+func encode(namespace, key string, value interface{}) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode setting %s/%s: %w", namespace, key, err)
+	}
+	return string(encoded), nil
+}
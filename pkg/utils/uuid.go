@@ -62,6 +62,27 @@ func GenerateUUIDv7WithTime(t time.Time) uuid.UUID {
 	return id
 }
 
+// UUIDv7FromV4 derives a UUIDv7 key for a row keyed by a random (v4) UUID,
+// taking the timestamp from created, usually the row's creation time, and the
+// random bits from the old key. The result only depends on its arguments, so
+// a migration rewriting primary and foreign keys can map each old key
+// independently and be rerun after a failure.
+func UUIDv7FromV4(old uuid.UUID, created time.Time) uuid.UUID {
+	// Version 4 and 7 share the layout after the first 48 bits, so the 74
+	// random bits of the old key stay in place
+	id := old
+	timestamp := uint64(created.UnixMilli())
+	id[0] = byte(timestamp >> 40)
+	id[1] = byte(timestamp >> 32)
+	id[2] = byte(timestamp >> 24)
+	id[3] = byte(timestamp >> 16)
+	id[4] = byte(timestamp >> 8)
+	id[5] = byte(timestamp)
+	id[6] = 0x70 | (id[6] & 0x0F)
+	id[8] = 0x80 | (id[8] & 0x3F)
+	return id
+}
+
 // ParseUUIDv7Time extracts timestamp from UUIDv7 according to RFC 9562
 // Returns the timestamp embedded in the first 48 bits
 func ParseUUIDv7Time(id uuid.UUID) (time.Time, error) {
@@ -1,6 +1,7 @@
 package integration
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -10,7 +11,9 @@ import (
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 
+	"github.com/seasbee/go-ormx/pkg/logging"
 	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
 )
 
 // TestEntity represents a test entity for integration testing
@@ -382,7 +385,31 @@ func TestAggregationQueries(t *testing.T) {
 
 // TestSoftDelete tests soft delete functionality
 func TestSoftDelete(t *testing.T) {
-	t.Skip("Soft delete functionality needs to be implemented with proper GORM v2 hooks")
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	config := repository.DefaultRepositoryConfig()
+	config.DeleteMode = repository.DeleteModeSoft
+	repo := repository.NewBaseRepository[TestEntity](db, logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{}), config)
+	ctx := context.Background()
+
+	entity := createTestEntity()
+	require.NoError(t, repo.Create(ctx, entity))
+	require.NoError(t, repo.DeleteByID(ctx, entity.ID))
+
+	// The row remains with deleted_at set
+	var softDeletedCount int64
+	db.Model(&TestEntity{}).Where("deleted_at IS NOT NULL").Count(&softDeletedCount)
+	assert.Equal(t, int64(1), softDeletedCount)
+
+	exists, err := repo.ExistsByID(ctx, entity.ID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	require.NoError(t, repo.RestoreByID(ctx, entity.ID))
+	exists, err = repo.ExistsByID(ctx, entity.ID)
+	require.NoError(t, err)
+	assert.True(t, exists)
 }
 
 // TestBulkOperations tests bulk operations
@@ -0,0 +1,128 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type actorNote struct {
+	models.BaseModel
+	Title string `gorm:"not null"`
+}
+
+// actorTag has no actor columns
+type actorTag struct {
+	ID   uint `gorm:"primaryKey"`
+	Name string
+}
+
+func setupActorRepository(t *testing.T, config *repository.ActorConfig) (*repository.BaseRepository[actorNote], *gorm.DB) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&actorNote{}, &actorTag{}))
+	require.NoError(t, db.Use(repository.NewActorStamper(config)))
+
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	return repository.NewBaseRepository[actorNote](db, logger, repository.DefaultRepositoryConfig()), db
+}
+
+func TestActorStamper(t *testing.T) {
+	repo, db := setupActorRepository(t, nil)
+	alice, bob := uuid.New(), uuid.New()
+	asAlice := repository.WithActor(context.Background(), alice)
+	asBob := repository.WithActor(context.Background(), bob.String())
+
+	t.Run("create", func(t *testing.T) {
+		note := &actorNote{Title: "draft"}
+		require.NoError(t, repo.Create(asAlice, note))
+		require.NotNil(t, note.CreatedBy)
+		assert.Equal(t, alice, *note.CreatedBy)
+		assert.Equal(t, alice, note.GetUpdatedBy())
+
+		stored, err := repo.FindFirstByID(context.Background(), note.ID)
+		require.NoError(t, err)
+		assert.Equal(t, alice, stored.GetCreatedBy())
+		assert.Equal(t, alice, stored.GetUpdatedBy())
+	})
+
+	t.Run("create keeps set actors", func(t *testing.T) {
+		note := &actorNote{Title: "imported"}
+		note.CreatedBy = &bob
+		require.NoError(t, repo.Create(asAlice, note))
+		assert.Equal(t, bob, note.GetCreatedBy())
+		assert.Equal(t, alice, note.GetUpdatedBy())
+	})
+
+	t.Run("batches", func(t *testing.T) {
+		notes := []actorNote{{Title: "a"}, {Title: "b"}}
+		require.NoError(t, repo.CreateInBatches(asAlice, notes, 1))
+		for _, note := range notes {
+			assert.Equal(t, alice, note.GetCreatedBy())
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		note := &actorNote{Title: "draft"}
+		require.NoError(t, repo.Create(asAlice, note))
+
+		note.Title = "final"
+		require.NoError(t, repo.Update(asBob, note))
+		stored, err := repo.FindFirstByID(context.Background(), note.ID)
+		require.NoError(t, err)
+		assert.Equal(t, alice, stored.GetCreatedBy())
+		assert.Equal(t, bob, stored.GetUpdatedBy())
+	})
+
+	t.Run("bulk update", func(t *testing.T) {
+		require.NoError(t, repo.Create(asAlice, &actorNote{Title: "bulk"}))
+
+		updated, err := repo.UpdateAllByConditions(asBob, map[string]interface{}{"title": "bulked"}, "title = ?", "bulk")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), updated)
+
+		var stored actorNote
+		require.NoError(t, db.First(&stored, "title = ?", "bulked").Error)
+		assert.Equal(t, alice, stored.GetCreatedBy())
+		assert.Equal(t, bob, stored.GetUpdatedBy())
+	})
+
+	t.Run("without actor", func(t *testing.T) {
+		note := &actorNote{Title: "anonymous"}
+		require.NoError(t, repo.Create(context.Background(), note))
+		assert.Nil(t, note.CreatedBy)
+		assert.Nil(t, note.UpdatedBy)
+	})
+
+	t.Run("invalid actor", func(t *testing.T) {
+		ctx := repository.WithActor(context.Background(), "not-a-uuid")
+		err := repo.Create(ctx, &actorNote{Title: "invalid"})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "not a UUID")
+	})
+}
+
+func TestActorStamper_Strict(t *testing.T) {
+	repo, db := setupActorRepository(t, &repository.ActorConfig{Strict: true})
+	ctx := context.Background()
+
+	err := repo.Create(ctx, &actorNote{Title: "anonymous"})
+	assert.ErrorIs(t, err, repository.ErrActorRequired)
+
+	_, err = repo.UpdateAllByConditions(ctx, map[string]interface{}{"title": "x"}, "title = ?", "anonymous")
+	assert.ErrorIs(t, err, repository.ErrActorRequired)
+
+	// Models without actor columns are not audited
+	require.NoError(t, db.WithContext(ctx).Create(&actorTag{Name: "go"}).Error)
+
+	actor := uuid.New()
+	note := &actorNote{Title: "signed"}
+	require.NoError(t, repo.Create(repository.WithActor(ctx, actor), note))
+	assert.Equal(t, actor, note.GetCreatedBy())
+}
@@ -0,0 +1,205 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialect_TruncateTime(t *testing.T) {
+	assert.Equal(t, `date_trunc('week', "created_at")`, dialect.Postgres{}.TruncateTime(`"created_at"`, dialect.TimeUnitWeek))
+	assert.Equal(t, "DATE_FORMAT(`created_at`, '%Y-%m-%d %H:00:00')", dialect.MySQL{}.TruncateTime("`created_at`", dialect.TimeUnitHour))
+	assert.Equal(t, "strftime('%Y-%m-01 00:00:00', `created_at`)", dialect.SQLite{}.TruncateTime("`created_at`", dialect.TimeUnitMonth))
+	assert.Equal(t, "DATEADD(day, DATEDIFF(day, 0, [created_at]), 0)", dialect.SQLServer{}.TruncateTime("[created_at]", dialect.TimeUnitDay))
+	assert.Equal(t, `TRUNC("created_at", 'IW')`, dialect.Oracle{}.TruncateTime(`"created_at"`, dialect.TimeUnitWeek))
+
+	assert.True(t, dialect.TimeUnitDay.Valid())
+	assert.False(t, dialect.TimeUnit("minute").Valid())
+}
+
+func TestBaseRepository_TimeSeriesCount(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	ctx := context.Background()
+
+	// Wednesday 2024-06-05
+	base := time.Date(2024, 6, 5, 10, 15, 0, 0, time.UTC)
+	times := []time.Time{
+		base,
+		base.Add(30 * time.Minute),
+		base.Add(2 * time.Hour),
+		base.Add(24 * time.Hour),
+		base.Add(7 * 24 * time.Hour),
+	}
+	for i, createdAt := range times {
+		entity := &TestEntity{Name: fmt.Sprintf("ts-%d", i), Age: 20 + i}
+		require.NoError(t, repo.Create(ctx, entity))
+		require.NoError(t, db.Model(&TestEntity{}).Where("id = ?", entity.ID).Update("created_at", createdAt).Error)
+	}
+
+	hourly, err := repo.TimeSeriesCount(ctx, "created_at", dialect.TimeUnitHour)
+	require.NoError(t, err)
+	require.Len(t, hourly, 4)
+	assert.Equal(t, time.Date(2024, 6, 5, 10, 0, 0, 0, time.UTC), hourly[0].Bucket)
+	assert.Equal(t, int64(2), hourly[0].Count)
+
+	weekly, err := repo.TimeSeriesCount(ctx, "created_at", dialect.TimeUnitWeek)
+	require.NoError(t, err)
+	require.Len(t, weekly, 2)
+	assert.Equal(t, time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC), weekly[0].Bucket)
+	assert.Equal(t, int64(4), weekly[0].Count)
+	assert.Equal(t, int64(1), weekly[1].Count)
+
+	daily, err := repo.TimeSeriesCount(ctx, "created_at", dialect.TimeUnitDay, "age > ?", 21)
+	require.NoError(t, err)
+	require.Len(t, daily, 3)
+	assert.Equal(t, int64(1), daily[0].Count)
+
+	_, err = repo.TimeSeriesCount(ctx, "created_at; DROP TABLE test_entities", dialect.TimeUnitDay)
+	assert.Error(t, err)
+
+	_, err = repo.TimeSeriesCount(ctx, "created_at", dialect.TimeUnit("minute"))
+	assert.Error(t, err)
+}
+
+func TestDialect_PercentileCont(t *testing.T) {
+	aggregator, ok := dialect.Dialect(dialect.Postgres{}).(dialect.PercentileAggregator)
+	require.True(t, ok)
+	assert.Equal(t, `percentile_cont(0.95) WITHIN GROUP (ORDER BY "latency")`, aggregator.PercentileCont(`"latency"`, 0.95))
+
+	_, ok = dialect.Dialect(dialect.CockroachDB{}).(dialect.PercentileAggregator)
+	assert.True(t, ok)
+	_, ok = dialect.Dialect(dialect.SQLite{}).(dialect.PercentileAggregator)
+	assert.False(t, ok)
+}
+
+func TestBaseRepository_PercentileBy(t *testing.T) {
+	repo, _ := setupTestRepository(t)
+	ctx := context.Background()
+
+	empty, err := repo.PercentileBy(ctx, "age", []float64{0.5})
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	for i := 1; i <= 10; i++ {
+		require.NoError(t, repo.Create(ctx, &TestEntity{Name: fmt.Sprintf("p-%d", i), Age: i * 10}))
+	}
+
+	values, err := repo.PercentileBy(ctx, "age", []float64{0, 0.5, 0.9, 1})
+	require.NoError(t, err)
+	require.Len(t, values, 4)
+	assert.InDelta(t, 10, values[0].Value, 0.001)
+	assert.InDelta(t, 55, values[1].Value, 0.001)
+	assert.InDelta(t, 91, values[2].Value, 0.001)
+	assert.InDelta(t, 100, values[3].Value, 0.001)
+	assert.Equal(t, 0.9, values[2].Percentile)
+
+	filtered, err := repo.PercentileBy(ctx, "age", []float64{0.5}, "age <= ?", 30)
+	require.NoError(t, err)
+	require.Len(t, filtered, 1)
+	assert.InDelta(t, 20, filtered[0].Value, 0.001)
+
+	_, err = repo.PercentileBy(ctx, "age", []float64{1.5})
+	assert.Error(t, err)
+	_, err = repo.PercentileBy(ctx, "age)--", []float64{0.5})
+	assert.Error(t, err)
+}
+
+func TestBaseRepository_HistogramBy(t *testing.T) {
+	repo, _ := setupTestRepository(t)
+	ctx := context.Background()
+
+	for i, age := range []int{5, 15, 18, 25, 40, 65} {
+		require.NoError(t, repo.Create(ctx, &TestEntity{Name: fmt.Sprintf("h-%d", i), Age: age}))
+	}
+
+	buckets, err := repo.HistogramBy(ctx, "age", []float64{18, 30, 65})
+	require.NoError(t, err)
+	require.Len(t, buckets, 4)
+
+	assert.Nil(t, buckets[0].Lower)
+	assert.Equal(t, 18.0, *buckets[0].Upper)
+	assert.Equal(t, int64(2), buckets[0].Count)
+	assert.Equal(t, int64(2), buckets[1].Count)
+	assert.Equal(t, int64(1), buckets[2].Count)
+	assert.Equal(t, 65.0, *buckets[3].Lower)
+	assert.Nil(t, buckets[3].Upper)
+	assert.Equal(t, int64(1), buckets[3].Count)
+
+	filtered, err := repo.HistogramBy(ctx, "age", []float64{18}, "name <> ?", "h-0")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), filtered[0].Count)
+	assert.Equal(t, int64(4), filtered[1].Count)
+
+	_, err = repo.HistogramBy(ctx, "age", nil)
+	assert.Error(t, err)
+	_, err = repo.HistogramBy(ctx, "age", []float64{30, 18})
+	assert.Error(t, err)
+}
+
+func TestRepository_Aggregations(t *testing.T) {
+	base, _ := setupTestRepository(t)
+	for name, repo := range map[string]repository.Repository[TestEntity]{
+		"base":   base,
+		"memory": repository.NewMemoryRepository[TestEntity](nil),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, entity := range []TestEntity{
+				{Name: "ann", Age: 20}, {Name: "ann", Age: 30}, {Name: "bob", Age: 41}, {Name: "cid", Age: 9},
+			} {
+				require.NoError(t, repo.Create(ctx, &entity))
+			}
+
+			sum, err := repo.SumByConditions(ctx, "age")
+			require.NoError(t, err)
+			assert.Equal(t, 100.0, sum)
+			avg, err := repo.AvgByConditions(ctx, "age", "name = ?", "ann")
+			require.NoError(t, err)
+			assert.Equal(t, 25.0, avg)
+			sum, err = repo.SumByConditions(ctx, "age", "name = ?", "nobody")
+			require.NoError(t, err)
+			assert.Zero(t, sum)
+
+			var youngest int
+			require.NoError(t, repo.MinBy(ctx, "age", &youngest))
+			assert.Equal(t, 9, youngest)
+			var last string
+			require.NoError(t, repo.MaxBy(ctx, "name", &last, "age > ?", 10))
+			assert.Equal(t, "bob", last)
+			var created time.Time
+			require.NoError(t, repo.MaxBy(ctx, "created_at", &created))
+			assert.WithinDuration(t, time.Now(), created, time.Minute)
+			err = repo.MinBy(ctx, "age", &youngest, "name = ?", "nobody")
+			assert.True(t, errors.IsNotFound(err))
+
+			rows, err := repo.GroupBy(ctx, []string{"name"},
+				[]repository.Aggregate{repository.CountAll(), repository.SumOf("age").As("total"), repository.MaxOf("age")})
+			require.NoError(t, err)
+			require.Len(t, rows, 3)
+			assert.Equal(t, "ann", rows[0]["name"])
+			assert.EqualValues(t, 2, rows[0]["count"])
+			assert.EqualValues(t, 50, rows[0]["total"])
+			assert.EqualValues(t, 30, rows[0]["max_age"])
+			assert.Equal(t, "cid", rows[2]["name"])
+
+			rows, err = repo.GroupBy(ctx, nil, []repository.Aggregate{repository.AvgOf("age")}, "age >= ?", 20)
+			require.NoError(t, err)
+			require.Len(t, rows, 1)
+			assert.EqualValues(t, 91.0/3, rows[0]["avg_age"])
+
+			_, err = repo.SumByConditions(ctx, "age; DROP TABLE test_entities")
+			assert.Error(t, err)
+			_, err = repo.GroupBy(ctx, []string{"name"}, nil)
+			assert.Error(t, err)
+			_, err = repo.GroupBy(ctx, nil, []repository.Aggregate{{Func: "MEDIAN", Column: "age"}})
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package unit
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/audit"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// setupAuditedRepository creates a test repository whose changes are audited
+func setupAuditedRepository(t *testing.T) (*repository.BaseRepository[TestEntity], *audit.Auditor) {
+	db := setupTestDB(t)
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+
+	auditor, err := audit.NewAuditor(db, logger, nil)
+	require.NoError(t, err)
+	repo := audit.Track(auditor, repository.NewBaseRepository[TestEntity](db, logger, nil))
+	return repo, auditor
+}
+
+func TestAuditor_History(t *testing.T) {
+	repo, auditor := setupAuditedRepository(t)
+	ctx := context.WithValue(context.Background(), "user_id", "alice")
+	ctx = context.WithValue(ctx, "trace_id", "trace-1")
+	ctx = repository.WithTenant(ctx, "acme")
+
+	entity := &TestEntity{Name: "Alice", Age: 30}
+	require.NoError(t, repo.Create(ctx, entity))
+
+	entity.Age = 31
+	require.NoError(t, repo.Update(ctx, entity))
+
+	// Updates changing nothing but the update time are not recorded
+	require.NoError(t, repo.Update(ctx, entity))
+
+	require.NoError(t, repo.DeleteByID(ctx, entity.ID))
+
+	history, err := auditor.History(context.Background(), entity.ID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+
+	created, updated, deleted := history[0], history[1], history[2]
+	assert.Equal(t, repository.ChangeCreate, created.Operation)
+	assert.Equal(t, "test_entities", created.EntityType)
+	assert.Equal(t, "alice", created.Actor)
+	assert.Equal(t, "acme", created.TenantID)
+	assert.Equal(t, "trace-1", created.TraceID)
+	assert.Empty(t, created.Before)
+	var after TestEntity
+	require.NoError(t, created.DecodeAfter(&after))
+	assert.Equal(t, "Alice", after.Name)
+
+	assert.Equal(t, repository.ChangeUpdate, updated.Operation)
+	patch, err := updated.Patch()
+	require.NoError(t, err)
+	require.Len(t, patch, 1)
+	assert.Equal(t, models.ChangeReplace, patch[0].Op)
+	assert.Equal(t, "/Age", patch[0].Path)
+	var before TestEntity
+	require.NoError(t, updated.DecodeBefore(&before))
+	assert.Equal(t, 30, before.Age)
+
+	assert.Equal(t, repository.ChangeDelete, deleted.Operation)
+	assert.NotEmpty(t, deleted.Before)
+	assert.Empty(t, deleted.After)
+
+	// Pagination
+	page, err := auditor.History(context.Background(), entity.ID, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, updated.ID, page[0].ID)
+
+	_, err = auditor.History(context.Background(), uuid.Nil, 10, 0)
+	assert.Error(t, err)
+}
+
+func TestAuditor_Transactions(t *testing.T) {
+	repo, auditor := setupAuditedRepository(t)
+	ctx := context.Background()
+
+	// Audit logs written in a rolled back transaction are discarded with it
+	var id uuid.UUID
+	err := repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		entity := &TestEntity{Name: "Ghost", Age: 1}
+		if err := tx.Create(ctx, entity); err != nil {
+			return err
+		}
+		id = entity.ID
+		return stderrors.New("abort")
+	})
+	require.Error(t, err)
+
+	history, err := auditor.History(ctx, id, 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	// A failing change hook fails the write and rolls its transaction back
+	repo.OnChange(func(ctx context.Context, change *repository.EntityChange[TestEntity]) error {
+		return stderrors.New("audit sink unavailable")
+	})
+	err = repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		return tx.Create(ctx, &TestEntity{Name: "Bob", Age: 2})
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "audit sink unavailable")
+
+	count, err := repo.CountByConditions(ctx, "name = ?", "Bob")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestRepository_OnChange(t *testing.T) {
+	repo, _ := setupTestRepository(t)
+	ctx := context.Background()
+
+	var changes []repository.EntityChange[TestEntity]
+	repo.OnChange(func(ctx context.Context, change *repository.EntityChange[TestEntity]) error {
+		changes = append(changes, *change)
+		return nil
+	})
+
+	entity := &TestEntity{Name: "Alice", Age: 30}
+	require.NoError(t, repo.Create(ctx, entity))
+	entity.Name = "Alicia"
+	require.NoError(t, repo.UpdateByID(ctx, entity, entity.ID))
+	require.NoError(t, repo.SoftDeleteByID(ctx, entity.ID))
+	require.NoError(t, repo.RestoreByID(ctx, entity.ID))
+
+	// Deleting a missing entity reports no change
+	require.NoError(t, repo.DeleteByID(ctx, uuid.New()))
+
+	require.Len(t, changes, 4)
+	assert.Equal(t, repository.ChangeCreate, changes[0].Operation)
+	assert.Nil(t, changes[0].Before)
+	assert.Equal(t, "Alice", changes[0].After.Name)
+
+	assert.Equal(t, repository.ChangeUpdate, changes[1].Operation)
+	assert.Equal(t, "Alice", changes[1].Before.Name)
+	assert.Equal(t, "Alicia", changes[1].After.Name)
+
+	assert.Equal(t, repository.ChangeDelete, changes[2].Operation)
+	assert.Nil(t, changes[2].After)
+
+	assert.Equal(t, repository.ChangeRestore, changes[3].Operation)
+	assert.NotNil(t, changes[3].Before.DeletedAt)
+	assert.Nil(t, changes[3].After.DeletedAt)
+
+	// The written entity is copied, later changes by the caller are not seen
+	entity.Name = "Changed"
+	assert.Equal(t, "Alicia", changes[1].After.Name)
+	assert.IsType(t, &gorm.DB{}, changes[0].DB)
+}
+
+func TestRepository_OnChangeBatches(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&ingestEvent{}, &upsertProduct{}))
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	ctx := context.Background()
+
+	events := repository.NewBaseRepository[ingestEvent](db, logger, nil)
+	var ingested []repository.EntityChange[ingestEvent]
+	events.OnChange(func(ctx context.Context, change *repository.EntityChange[ingestEvent]) error {
+		ingested = append(ingested, *change)
+		return nil
+	})
+	existing := &ingestEvent{ExternalID: "e1", Payload: "original"}
+	require.NoError(t, events.Create(ctx, existing))
+	ingested = nil
+
+	// Only the rows actually inserted are reported
+	batch := []ingestEvent{
+		{ExternalID: "e1", Payload: "duplicate"},
+		{ExternalID: "e2", Payload: "new"},
+		{ExternalID: "e3", Payload: "new"},
+		{ExternalID: "e3", Payload: "duplicate in batch"},
+	}
+	batch[0].ID = existing.ID
+	result, err := events.CreateInBatchesIgnoreConflicts(ctx, batch, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.Inserted)
+	require.Len(t, ingested, 2)
+	for _, change := range ingested {
+		assert.Equal(t, repository.ChangeCreate, change.Operation)
+		assert.Nil(t, change.Before)
+		assert.Equal(t, "new", change.After.Payload)
+	}
+	assert.Equal(t, batch[1].ID, ingested[0].Key)
+	assert.Equal(t, batch[2].ID, ingested[1].Key)
+
+	products := repository.NewBaseRepository[upsertProduct](db, logger, nil)
+	var upserted []repository.EntityChange[upsertProduct]
+	products.OnChange(func(ctx context.Context, change *repository.EntityChange[upsertProduct]) error {
+		upserted = append(upserted, *change)
+		return nil
+	})
+	anvil := &upsertProduct{SKU: "A-1", Name: "Anvil", Stock: 5}
+	require.NoError(t, products.Create(ctx, anvil))
+	upserted = nil
+
+	// Batch upserts report creates and updates of the stored rows
+	rows := []upsertProduct{
+		{SKU: "A-1", Name: "Anvil", Stock: 8},
+		{SKU: "B-2", Name: "Bucket", Stock: 2},
+	}
+	require.NoError(t, products.UpsertInBatches(ctx, rows, 1, repository.OnConflict("sku")))
+	require.Len(t, upserted, 2)
+	assert.Equal(t, repository.ChangeUpdate, upserted[0].Operation)
+	assert.Equal(t, anvil.ID, upserted[0].Key)
+	assert.Equal(t, 5, upserted[0].Before.Stock)
+	assert.Equal(t, 8, upserted[0].After.Stock)
+	assert.Equal(t, repository.ChangeCreate, upserted[1].Operation)
+	assert.Nil(t, upserted[1].Before)
+	assert.Equal(t, "B-2", upserted[1].After.SKU)
+
+	// A failing hook fails the batch upsert
+	products.OnChange(func(ctx context.Context, change *repository.EntityChange[upsertProduct]) error {
+		return stderrors.New("audit sink unavailable")
+	})
+	err = products.UpsertInBatchesByConditions(ctx, []upsertProduct{{SKU: "C-3", Name: "Crate"}}, 10,
+		repository.OnConflict("sku"), "sku = ?", "C-3")
+	assert.ErrorContains(t, err, "audit sink unavailable")
+}
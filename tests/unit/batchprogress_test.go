@@ -0,0 +1,153 @@
+package unit
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// progressRepositories returns a base and a memory repository of products
+func progressRepositories(t *testing.T) map[string]repository.Repository[upsertProduct] {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&upsertProduct{}))
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	return map[string]repository.Repository[upsertProduct]{
+		"base":   repository.NewBaseRepository[upsertProduct](db, logger, repository.DefaultRepositoryConfig()),
+		"memory": repository.NewMemoryRepository[upsertProduct](repository.DefaultRepositoryConfig()),
+	}
+}
+
+// progressProducts returns n products with distinct SKUs
+func progressProducts(kind string, n int) []upsertProduct {
+	products := make([]upsertProduct, n)
+	for i := range products {
+		products[i] = upsertProduct{SKU: fmt.Sprintf("%s-%d", kind, i), Name: "Product", Stock: i}
+	}
+	return products
+}
+
+func TestCreateInBatches_ProgressAndResume(t *testing.T) {
+	for kind, repo := range progressRepositories(t) {
+		t.Run(kind, func(t *testing.T) {
+			var reports []repository.BatchProgress
+			ctx := repository.WithBatchProgress(context.Background(), func(p repository.BatchProgress) {
+				reports = append(reports, p)
+			})
+
+			// The third batch duplicates the SKU of the first product
+			products := progressProducts(kind, 6)
+			products[4].SKU = products[0].SKU
+
+			err := repo.CreateInBatches(ctx, products, 2)
+			require.Error(t, err)
+			batchErr, ok := errors.AsBatchError(err)
+			require.True(t, ok)
+			assert.Equal(t, 4, batchErr.ResumeIndex())
+			assert.Equal(t, 4, batchErr.Succeeded)
+			assert.Equal(t, 6, batchErr.Total)
+			failures := batchErr.Failures()
+			require.Len(t, failures, 1)
+			assert.Equal(t, 2, failures[0].Batch)
+
+			require.Len(t, reports, 2)
+			assert.Equal(t, 0, reports[0].Batch)
+			assert.Equal(t, 2, reports[0].Done)
+			assert.Equal(t, 1, reports[1].Batch)
+			assert.Equal(t, 4, reports[1].Done)
+			assert.Equal(t, 6, reports[1].Total)
+			assert.LessOrEqual(t, reports[0].Elapsed, reports[1].Elapsed)
+
+			// The batches written before the failure are kept
+			count, err := repo.CountByConditions(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, int64(4), count)
+
+			// Resuming from the first unpersisted row writes the rest
+			products[4].SKU = kind + "-fixed"
+			reports = nil
+			require.NoError(t, repo.CreateInBatches(ctx, products[batchErr.ResumeIndex():], 2))
+			require.Len(t, reports, 1)
+			assert.Equal(t, 2, reports[0].Done)
+			assert.Equal(t, 2, reports[0].Total)
+
+			count, err = repo.CountByConditions(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, int64(6), count)
+		})
+	}
+}
+
+func TestCreateInBatches_StopsBetweenBatchesWhenCanceled(t *testing.T) {
+	for kind, repo := range progressRepositories(t) {
+		t.Run(kind, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			ctx = repository.WithBatchProgress(ctx, func(p repository.BatchProgress) {
+				if p.Done >= 3 {
+					cancel()
+				}
+			})
+
+			err := repo.CreateInBatches(ctx, progressProducts(kind, 10), 3)
+			require.Error(t, err)
+			assert.True(t, stderrors.Is(err, context.Canceled), "%v", err)
+			batchErr, ok := errors.AsBatchError(err)
+			require.True(t, ok)
+			assert.Equal(t, 3, batchErr.ResumeIndex())
+			assert.Equal(t, 7, batchErr.Failed())
+
+			count, err := repo.CountByConditions(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), count)
+		})
+	}
+}
+
+func TestCreateInBatches_StopsBeforeDeadline(t *testing.T) {
+	for kind, repo := range progressRepositories(t) {
+		t.Run(kind, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			// The first batch takes most of the time left, leaving too
+			// little for another
+			ctx = repository.WithBatchProgress(ctx, func(repository.BatchProgress) {
+				time.Sleep(600 * time.Millisecond)
+			})
+
+			err := repo.CreateInBatches(ctx, progressProducts(kind, 4), 2)
+			require.Error(t, err)
+			assert.True(t, errors.IsType(err, errors.ErrorTypeTimeout), "%v", err)
+			batchErr, ok := errors.AsBatchError(err)
+			require.True(t, ok)
+			assert.Equal(t, 2, batchErr.ResumeIndex())
+			assert.NoError(t, ctx.Err(), "the write stops before the deadline")
+		})
+	}
+}
+
+func TestCreateInBatches_AllOrNoneWithoutProgress(t *testing.T) {
+	for kind, repo := range progressRepositories(t) {
+		t.Run(kind, func(t *testing.T) {
+			products := progressProducts(kind, 6)
+			products[4].SKU = products[0].SKU
+
+			err := repo.CreateInBatches(context.Background(), products, 2)
+			require.Error(t, err)
+			_, ok := errors.AsBatchError(err)
+			assert.False(t, ok)
+
+			count, err := repo.CountByConditions(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), count)
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package unit
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaseRepository_BatchValidators(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	repo.AddBatchValidator(repository.UniqueInBatch[TestEntity]("Name")).
+		AddBatchValidator(repository.SumInBatch[TestEntity]("Age", 100))
+	ctx := context.Background()
+
+	entities := []TestEntity{
+		{Name: "Alice", Age: 30},
+		{Name: "Bob", Age: 20},
+		{Name: "Alice", Age: 40},
+		{Name: "Carol", Age: 50},
+	}
+	err := repo.CreateInBatches(ctx, entities, 2)
+	require.Error(t, err)
+	batchErr, ok := errors.AsBatchValidationError(err)
+	require.True(t, ok)
+	assert.Equal(t, errors.ErrorTypeValidation, batchErr.Type)
+	violations := batchErr.Violations()
+	require.Len(t, violations, 2)
+	assert.Equal(t, "unique_in_batch", violations[0].Rule)
+	assert.Equal(t, "Name", violations[0].Field)
+	assert.Equal(t, []int{0, 2}, violations[0].Rows)
+	assert.Equal(t, "sum_in_batch", violations[1].Rule)
+	assert.Equal(t, []int{0, 1, 2, 3}, violations[1].Rows)
+	assert.Equal(t, []int{0, 1, 2, 3}, batchErr.Rows())
+	assert.Contains(t, err.Error(), "Name: duplicate value within the batch (rows 0, 2)")
+
+	// Nothing was written, not even the first batch
+	assert.Equal(t, int64(0), countRows(t, db, &TestEntity{}))
+
+	_, err = repo.CreateInBatchesIgnoreConflicts(ctx, entities, 2)
+	_, ok = errors.AsBatchValidationError(err)
+	assert.True(t, ok)
+	err = repo.UpsertInBatches(ctx, entities, 2, repository.ConflictOptions{})
+	_, ok = errors.AsBatchValidationError(err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), countRows(t, db, &TestEntity{}))
+
+	require.NoError(t, repo.CreateInBatches(ctx, entities[:2], 2))
+	assert.Equal(t, int64(2), countRows(t, db, &TestEntity{}))
+}
+
+func TestUniqueInBatch(t *testing.T) {
+	validate := repository.UniqueInBatch[TestEntity]("Name", "Age")
+	ctx := context.Background()
+
+	assert.Empty(t, validate(ctx, []TestEntity{{Name: "Alice", Age: 30}, {Name: "Alice", Age: 31}}))
+	// Entities with every field zero are not compared
+	assert.Empty(t, validate(ctx, []TestEntity{{}, {}}))
+
+	violations := validate(ctx, []TestEntity{{Name: "Alice", Age: 30}, {Name: "Bob"}, {Name: "Alice", Age: 30}, {Name: "Bob"}})
+	require.Len(t, violations, 2)
+	assert.Equal(t, "Name,Age", violations[0].Field)
+	assert.Equal(t, []int{0, 2}, violations[0].Rows)
+	assert.Equal(t, []int{1, 3}, violations[1].Rows)
+
+	violations = repository.UniqueInBatch[TestEntity]("Email")(ctx, []TestEntity{{Name: "Alice"}})
+	require.Len(t, violations, 1)
+	assert.Equal(t, "unknown field", violations[0].Message)
+}
+
+func TestBaseRepository_ValidateBatch(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&validatedEntity{}))
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	repo := repository.NewBaseRepository[validatedEntity](db, logger, nil).
+		AddValidator(func(ctx context.Context, entity *validatedEntity) []errors.FieldError {
+			if strings.HasSuffix(entity.Email, "@example.org") {
+				return []errors.FieldError{{Field: "Email", Rule: "domain", Message: "example.org addresses are not accepted"}}
+			}
+			return nil
+		}).
+		AddBatchValidator(repository.UniqueInBatch[validatedEntity]("Email"))
+	ctx := context.Background()
+
+	// Entity validators run wherever entities are validated
+	err := repo.Create(ctx, &validatedEntity{Email: "bob@example.org", Age: 20})
+	verr, ok := errors.AsValidationError(err)
+	require.True(t, ok)
+	assert.Equal(t, []errors.FieldError{{Field: "Email", Rule: "domain", Message: "example.org addresses are not accepted"}}, verr.Fields())
+
+	entities := []validatedEntity{
+		{Email: "alice@example.com", Age: 20},
+		{Email: "not-an-email", Age: 20},
+		{Email: "alice@example.com", Age: 12},
+		{Email: "bob@example.org", Age: 30},
+	}
+	require.NoError(t, repo.ValidateBatch(ctx, entities[:1]))
+
+	err = repo.ValidateBatch(ctx, entities)
+	batchErr, ok := errors.AsBatchValidationError(err)
+	require.True(t, ok)
+	entityErrs := batchErr.EntityErrors()
+	require.Len(t, entityErrs, 3)
+	assert.Equal(t, 1, entityErrs[0].Index)
+	assert.Contains(t, entityErrs[0].FieldMap(), "Email")
+	assert.Equal(t, 2, entityErrs[1].Index)
+	assert.Contains(t, entityErrs[1].FieldMap(), "Age")
+	assert.Equal(t, 3, entityErrs[2].Index)
+	require.Len(t, batchErr.Violations(), 1)
+	assert.Equal(t, []int{0, 2}, batchErr.Violations()[0].Rows)
+	assert.Equal(t, []int{0, 1, 2, 3}, batchErr.Rows())
+
+	// CreateInBatches reports every invalid entity and writes none
+	err = repo.CreateInBatches(ctx, entities, 2)
+	batchErr, ok = errors.AsBatchValidationError(err)
+	require.True(t, ok)
+	assert.Len(t, batchErr.EntityErrors(), 3)
+	assert.Contains(t, err.Error(), "entity 2: Age:")
+	assert.Equal(t, int64(0), countRows(t, db, &validatedEntity{}))
+}
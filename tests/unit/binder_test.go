@@ -0,0 +1,110 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bindOrder struct {
+	models.BaseModel
+	CustomerID uuid.UUID `gorm:"type:uuid;not null"`
+	Status     string    `gorm:"not null" validate:"oneof=open paid cancelled"`
+	Total      int       `gorm:"not null"`
+	Rush       bool
+}
+
+func setupBinderRepository(t *testing.T) (*repository.BaseRepository[bindOrder], uuid.UUID) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&bindOrder{}))
+	require.NoError(t, db.Use(repository.NewConditionBinder()))
+
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	repo := repository.NewBaseRepository[bindOrder](db, logger, repository.DefaultRepositoryConfig())
+
+	customer := uuid.New()
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, &bindOrder{CustomerID: customer, Status: "open", Total: 10}))
+	require.NoError(t, repo.Create(ctx, &bindOrder{CustomerID: customer, Status: "paid", Total: 25, Rush: true}))
+	require.NoError(t, repo.Create(ctx, &bindOrder{CustomerID: uuid.New(), Status: "paid", Total: 40}))
+	return repo, customer
+}
+
+func TestConditionBinder_Coercion(t *testing.T) {
+	repo, customer := setupBinderRepository(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		conds []interface{}
+		count int64
+	}{
+		{"uuid string", []interface{}{"customer_id = ?", customer.String()}, 2},
+		{"uuid list", []interface{}{"customer_id IN ?", []string{customer.String()}}, 2},
+		{"numeric string", []interface{}{"total >= ? AND status = ?", "20", "paid"}, 2},
+		{"between", []interface{}{"total BETWEEN ? AND ?", "5", "30"}, 2},
+		{"boolean string", []interface{}{"rush = ?", "true"}, 1},
+		{"timestamp", []interface{}{"created_at <= ?", time.Now().Add(time.Minute).Format(time.RFC3339)}, 3},
+		{"map", []interface{}{map[string]interface{}{"customer_id": customer.String(), "status": "open"}}, 1},
+		{"like is not an enum", []interface{}{"status LIKE ?", "pa%"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := repo.CountByConditions(ctx, tt.conds...)
+			require.NoError(t, err)
+			assert.Equal(t, tt.count, count)
+		})
+	}
+
+	orders, err := repo.Query().Where("status IN (?, ?)", "open", "cancelled").Find(ctx)
+	require.NoError(t, err)
+	assert.Len(t, orders, 1)
+}
+
+func TestConditionBinder_Validation(t *testing.T) {
+	repo, customer := setupBinderRepository(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		conds []interface{}
+		field string
+		rule  string
+	}{
+		{"uuid", []interface{}{"status = ? AND customer_id = ?", "open", "42"}, "customer_id", "uuid"},
+		{"uuid list", []interface{}{"customer_id IN ?", []string{customer.String(), "nope"}}, "customer_id", "uuid"},
+		{"timestamp", []interface{}{"created_at > ?", "yesterday"}, "created_at", "datetime"},
+		{"enum", []interface{}{"status = ?", "shipped"}, "status", "oneof"},
+		{"integer", []interface{}{"total > ?", "ten"}, "total", "number"},
+		{"map", []interface{}{map[string]interface{}{"customer_id": 42}}, "customer_id", "uuid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := repo.CountByConditions(ctx, tt.conds...)
+			require.Error(t, err)
+
+			validationErr, ok := errors.AsValidationError(err)
+			require.True(t, ok, "got %v", err)
+			assert.Equal(t, errors.ErrorTypeValidation, validationErr.GetType())
+			require.Len(t, validationErr.Fields(), 1)
+			assert.Equal(t, tt.field, validationErr.Fields()[0].Field)
+			assert.Equal(t, tt.rule, validationErr.Fields()[0].Rule)
+		})
+	}
+
+	_, err := repo.DeleteAllByConditions(ctx, "customer_id = ?", "not-a-uuid")
+	_, ok := errors.AsValidationError(err)
+	assert.True(t, ok)
+
+	err = repo.FindFirstByConditions(ctx, &bindOrder{}, "status = ? AND customer_id = ?", "open", "42")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "parameter 2")
+}
@@ -0,0 +1,84 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkInsertFast_FallsBackOnUnsupportedDrivers(t *testing.T) {
+	// SQLite has no bulk load protocol: the entities are created in batches
+	for kind, repo := range progressRepositories(t) {
+		t.Run(kind, func(t *testing.T) {
+			ctx := context.Background()
+			products := progressProducts(kind, 5)
+			result, err := repo.BulkInsertFast(ctx, products)
+			require.NoError(t, err)
+			assert.Equal(t, int64(5), result.Inserted)
+			assert.Zero(t, result.Skipped)
+
+			for _, product := range products {
+				assert.NotEqual(t, uuid.Nil, product.ID)
+				assert.False(t, product.CreatedAt.IsZero())
+			}
+			count, err := repo.CountByConditions(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, int64(5), count)
+
+			found, err := repo.FindFirstByID(ctx, products[3].ID)
+			require.NoError(t, err)
+			assert.Equal(t, products[3].SKU, found.SKU)
+		})
+	}
+}
+
+func TestBulkInsertFast_AllOrNone(t *testing.T) {
+	for kind, repo := range progressRepositories(t) {
+		t.Run(kind, func(t *testing.T) {
+			ctx := context.Background()
+			products := progressProducts(kind, 4)
+			products[3].SKU = products[0].SKU
+
+			_, err := repo.BulkInsertFast(ctx, products)
+			require.Error(t, err)
+			count, err := repo.CountByConditions(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), count)
+		})
+	}
+}
+
+func TestBulkInsertFast_RejectsEmptyInput(t *testing.T) {
+	for kind, repo := range progressRepositories(t) {
+		t.Run(kind, func(t *testing.T) {
+			_, err := repo.BulkInsertFast(context.Background(), nil)
+			require.Error(t, err)
+			assert.True(t, errors.IsType(err, errors.ErrorTypeValidation), "%v", err)
+		})
+	}
+}
+
+func TestBulkInsertFast_TenantScoped(t *testing.T) {
+	repo, _ := setupTenantRepository(t, nil)
+	acme := repository.WithTenant(context.Background(), "acme")
+
+	notes := []tenantNote{{Title: "a"}, {Title: "b"}}
+	_, err := repo.BulkInsertFast(acme, notes)
+	require.NoError(t, err)
+	for _, note := range notes {
+		assert.Equal(t, "acme", note.TenantID)
+	}
+
+	// Entities of other tenants are refused
+	globex := repository.WithTenant(context.Background(), "globex")
+	_, err = repo.BulkInsertFast(globex, []tenantNote{{Title: "c", TenantID: "acme"}})
+	require.Error(t, err)
+	count, err := repo.Unscoped().CountByConditions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
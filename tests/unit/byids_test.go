@@ -0,0 +1,95 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestBaseRepository_FindAllByIDs(t *testing.T) {
+	db := setupTestDB(t)
+	config := repository.DefaultRepositoryConfig()
+	config.MaxIDsPerQuery = 2
+	repo := repository.NewBaseRepository[TestEntity](db, logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{}), config)
+	ctx := context.Background()
+
+	var ids []models.EntityID
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		entity := &TestEntity{Name: name, Age: 1}
+		require.NoError(t, repo.Create(ctx, entity))
+		ids = append(ids, entity.ID)
+	}
+
+	queries := 0
+	require.NoError(t, db.Callback().Query().After("gorm:query").Register("test:count_by_ids", func(*gorm.DB) { queries++ }))
+
+	// IDs are queried in chunks, duplicates once, missing IDs are left out
+	missing := uuid.New()
+	requested := []models.EntityID{ids[4], ids[0], missing, ids[2], ids[0], ids[3], ids[1]}
+	found, err := repo.FindAllByIDs(ctx, requested)
+	require.NoError(t, err)
+	assert.Equal(t, 3, queries)
+	require.Len(t, found, 5)
+	assert.Equal(t, "c", found[ids[2]].Name)
+	assert.NotContains(t, found, missing)
+
+	var names []string
+	for _, entity := range repository.InIDOrder(requested, found) {
+		names = append(names, entity.Name)
+	}
+	assert.Equal(t, []string{"e", "a", "c", "a", "d", "b"}, names)
+
+	// Soft deleted entities are found only with WithDeleted
+	require.NoError(t, repo.SoftDeleteByID(ctx, ids[1]))
+	found, err = repo.FindAllByIDs(ctx, ids)
+	require.NoError(t, err)
+	assert.Len(t, found, 4)
+	found, err = repo.FindAllByIDs(repository.WithDeleted(ctx), ids)
+	require.NoError(t, err)
+	assert.Len(t, found, 5)
+
+	queries = 0
+	found, err = repo.FindAllByIDs(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+	assert.Zero(t, queries)
+}
+
+func TestBaseRepository_FindAllByIDsIntegerKeys(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&counterEntity{}))
+	repo := repository.NewBaseRepository[counterEntity](db, logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{}), nil)
+	ctx := context.Background()
+
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, repo.Create(ctx, &counterEntity{Name: name}))
+	}
+
+	// Each entity has its own key, IDs of other integer types and text are converted
+	requested := []models.EntityID{3, int64(1), "2", int64(9)}
+	found, err := repo.FindAllByIDs(ctx, requested)
+	require.NoError(t, err)
+	require.Len(t, found, 3)
+	assert.Equal(t, "a", found[int64(1)].Name)
+	assert.Equal(t, "c", found[int64(3)].Name)
+
+	var names []string
+	for _, entity := range repository.InIDOrder(requested, found) {
+		names = append(names, entity.Name)
+	}
+	assert.Equal(t, []string{"c", "a", "b"}, names)
+
+	memory := repository.NewMemoryRepository[counterEntity](nil)
+	require.NoError(t, memory.Create(ctx, &counterEntity{Name: "a"}))
+	found, err = memory.FindAllByIDs(ctx, []models.EntityID{1, "1", 2})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "a", found[int64(1)].Name)
+}
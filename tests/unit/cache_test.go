@@ -0,0 +1,191 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// setupCachedRepository creates a test repository with an entity cache
+func setupCachedRepository(t *testing.T, cache *repository.CacheConfig) (*repository.BaseRepository[TestEntity], *gorm.DB) {
+	db := setupTestDB(t)
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	config := repository.DefaultRepositoryConfig()
+	config.Cache = cache
+	return repository.NewBaseRepository[TestEntity](db, logger, config), db
+}
+
+func TestEntityCache_PrimeOnWrite(t *testing.T) {
+	repo, db := setupCachedRepository(t, &repository.CacheConfig{PrimeOnWrite: true})
+	ctx := context.Background()
+
+	entity := &TestEntity{Name: "Alice", Age: 30}
+	require.NoError(t, repo.Create(ctx, entity))
+	assert.Equal(t, int64(1), repo.CacheStats().Primed)
+
+	// Changes bypassing the repository are not seen while cached
+	require.NoError(t, db.Model(&TestEntity{}).Where("id = ?", entity.ID).Update("name", "Changed").Error)
+	found, err := repo.FindFirstByID(ctx, entity.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", found.Name)
+
+	// Cached copies are not shared with the callers
+	found.Name = "Modified"
+	entity.Name = "Modified"
+	var byColumn TestEntity
+	require.NoError(t, repo.FindFirstBy(ctx, &byColumn, "id", entity.ID.String()))
+	assert.Equal(t, "Alice", byColumn.Name)
+
+	exists, err := repo.ExistsByID(ctx, entity.ID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	entity.Name = "Bob"
+	require.NoError(t, repo.Update(ctx, entity))
+	found, err = repo.FindFirstByID(ctx, entity.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Bob", found.Name)
+
+	stats := repo.CacheStats()
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, int64(4), stats.Hits)
+	assert.Equal(t, int64(0), stats.Misses)
+	assert.Equal(t, int64(2), stats.Primed)
+	assert.Equal(t, int64(4), stats.PrimedHits)
+	assert.Equal(t, int64(2), stats.PrimedUsed)
+	assert.Equal(t, 1.0, stats.PrimedHitRate())
+	assert.Equal(t, 1.0, stats.HitRate())
+
+	// Find options bypass the cache
+	found, err = repo.FindFirstByID(ctx, entity.ID, repository.WithSelect("id", "name"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, found.Age)
+	assert.Equal(t, int64(4), repo.CacheStats().Hits)
+}
+
+func TestEntityCache_Invalidation(t *testing.T) {
+	repo, _ := setupCachedRepository(t, &repository.CacheConfig{PrimeOnWrite: true})
+	ctx := context.Background()
+
+	alice := &TestEntity{Name: "Alice", Age: 30}
+	bob := &TestEntity{Name: "Bob", Age: 40}
+	require.NoError(t, repo.Create(ctx, alice))
+	require.NoError(t, repo.Create(ctx, bob))
+	assert.Equal(t, 2, repo.CacheStats().Entries)
+
+	// Writes by conditions clear the cache
+	_, err := repo.UpdateAllByConditions(ctx, map[string]interface{}{"age": 50}, "age > ?", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, repo.CacheStats().Entries)
+
+	found, err := repo.FindFirstByID(ctx, alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 50, found.Age)
+	assert.Equal(t, int64(1), repo.CacheStats().Misses)
+	// Entities read from the database are cached too
+	_, err = repo.FindFirstByID(ctx, alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), repo.CacheStats().Hits)
+
+	require.NoError(t, repo.SoftDeleteByID(ctx, alice.ID))
+	_, err = repo.FindFirstByID(ctx, alice.ID)
+	assert.Error(t, err)
+
+	require.NoError(t, repo.RestoreByID(ctx, alice.ID))
+	_, err = repo.FindFirstByID(ctx, alice.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.DeleteByID(ctx, bob.ID))
+	exists, err := repo.ExistsByID(ctx, bob.ID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// Soft deleted entities read with WithDeleted are not cached
+	require.NoError(t, repo.SoftDeleteByID(ctx, alice.ID))
+	_, err = repo.FindFirstByID(repository.WithDeleted(ctx), alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, repo.CacheStats().Entries)
+}
+
+func TestEntityCache_Transactions(t *testing.T) {
+	repo, _ := setupCachedRepository(t, &repository.CacheConfig{PrimeOnWrite: true})
+	ctx := context.Background()
+
+	committed := &TestEntity{Name: "Alice", Age: 30}
+	require.NoError(t, repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		require.NoError(t, tx.Create(ctx, committed))
+		// Entities are primed only once the transaction commits
+		assert.Equal(t, 0, repo.CacheStats().Entries)
+		return nil
+	}))
+	assert.Equal(t, 1, repo.CacheStats().Entries)
+
+	rolledBack := &TestEntity{Name: "Bob", Age: 40}
+	err := repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		require.NoError(t, tx.Create(ctx, rolledBack))
+		committed.Name = "Changed"
+		require.NoError(t, tx.Update(ctx, committed))
+		return errors.New("rollback")
+	})
+	require.Error(t, err)
+
+	stats := repo.CacheStats()
+	assert.Equal(t, 0, stats.Entries)
+	assert.Equal(t, int64(1), stats.Primed)
+
+	found, err := repo.FindFirstByID(ctx, committed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", found.Name)
+	_, err = repo.FindFirstByID(ctx, rolledBack.ID)
+	assert.Error(t, err)
+}
+
+func TestEntityCache_Limits(t *testing.T) {
+	repo, _ := setupCachedRepository(t, &repository.CacheConfig{PrimeOnWrite: true, MaxEntries: 2, TTL: 50 * time.Millisecond})
+	ctx := context.Background()
+
+	entities := []*TestEntity{{Name: "A", Age: 1}, {Name: "B", Age: 2}, {Name: "C", Age: 3}}
+	for _, entity := range entities {
+		require.NoError(t, repo.Create(ctx, entity))
+	}
+	stats := repo.CacheStats()
+	assert.Equal(t, 2, stats.Entries)
+	assert.Equal(t, int64(1), stats.Evictions)
+	// The primed entity was evicted before it was read
+	assert.Equal(t, 0.0, stats.PrimedHitRate())
+
+	_, err := repo.FindFirstByID(ctx, entities[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), repo.CacheStats().Misses)
+
+	time.Sleep(60 * time.Millisecond)
+	_, err = repo.FindFirstByID(ctx, entities[2].ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), repo.CacheStats().Misses)
+}
+
+func TestEntityCache_Disabled(t *testing.T) {
+	repo, _ := setupCachedRepository(t, &repository.CacheConfig{})
+	ctx := context.Background()
+
+	entity := &TestEntity{Name: "Alice", Age: 30}
+	require.NoError(t, repo.Create(ctx, entity))
+	assert.Equal(t, repository.CacheStats{}, repo.CacheStats())
+
+	_, err := repo.FindFirstByID(ctx, entity.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), repo.CacheStats().Misses)
+
+	uncached, _ := setupTestRepository(t)
+	require.NoError(t, uncached.Create(ctx, entity))
+	_, err = uncached.FindFirstByID(ctx, entity.ID)
+	require.NoError(t, err)
+	assert.Equal(t, repository.CacheStats{}, uncached.CacheStats())
+}
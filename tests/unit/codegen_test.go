@@ -0,0 +1,83 @@
+package unit
+
+import (
+	"context"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/codegen"
+	"github.com/seasbee/go-ormx/pkg/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodegen_Generate(t *testing.T) {
+	db, err := database.OpenDSN("sqlite", filepath.Join(t.TempDir(), "gen.db"))
+	require.NoError(t, err)
+	for _, statement := range []string{
+		`CREATE TABLE users (id uuid PRIMARY KEY, created_at datetime NOT NULL, updated_at datetime NOT NULL,
+			deleted_at datetime, created_by uuid, updated_by uuid, deleted_by uuid,
+			email varchar(255) NOT NULL, age integer, api_key text, avatar blob)`,
+		`CREATE TABLE order_items (id integer PRIMARY KEY, sku varchar(32) NOT NULL, quantity bigint NOT NULL,
+			price real NOT NULL, shipped_on datetime, gift boolean NOT NULL)`,
+		`CREATE TABLE schema_migrations (version integer PRIMARY KEY)`,
+		`CREATE TABLE ormx_settings (key text PRIMARY KEY)`,
+	} {
+		require.NoError(t, db.Exec(statement).Error)
+	}
+	ctx := context.Background()
+
+	// Tables maintained by go-ormx are skipped
+	tables, err := codegen.Inspect(ctx, db)
+	require.NoError(t, err)
+	require.Len(t, tables, 2)
+	assert.Equal(t, "order_items", tables[0].Name)
+	assert.Equal(t, "users", tables[1].Name)
+	_, err = codegen.Inspect(ctx, db, "missing")
+	require.Error(t, err)
+
+	dir := filepath.Join(t.TempDir(), "entities")
+	files, err := codegen.Generate(dir, tables, codegen.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"order_items.go", "users.go"}, files)
+	for _, file := range files {
+		parsed, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, file), nil, parser.AllErrors)
+		require.NoError(t, err, file)
+		assert.Equal(t, "entities", parsed.Name.Name)
+	}
+
+	// Tables with the columns of models.BaseModel embed it
+	users, err := os.ReadFile(filepath.Join(dir, "users.go"))
+	require.NoError(t, err)
+	source := string(users)
+	assert.Contains(t, source, "type User struct {\n\tmodels.BaseModel\n")
+	assert.NotContains(t, source, "\tCreatedAt ")
+	assert.Contains(t, source, "Email  string  `gorm:\"column:email;type:varchar(255);not null\" json:\"email\"`")
+	assert.Contains(t, source, "APIKey *string `gorm:\"column:api_key;type:text\" json:\"api_key,omitempty\"`")
+	assert.Contains(t, source, "Avatar []byte")
+	assert.Contains(t, source, "func (User) TableName() string {\n\treturn \"users\"\n}")
+	assert.Contains(t, source, "UserColumnCreatedAt = \"created_at\"")
+	assert.Contains(t, source, "func NewUserRepository(db *gorm.DB, logger logging.Logger, config *repository.RepositoryConfig) *repository.BaseRepository[User] {")
+
+	// Other tables map every column
+	items, err := os.ReadFile(filepath.Join(dir, "order_items.go"))
+	require.NoError(t, err)
+	source = string(items)
+	assert.NotContains(t, source, "models.BaseModel")
+	assert.Contains(t, source, "ID        int        `gorm:\"column:id;type:integer;primaryKey\" json:\"id\"`")
+	assert.Contains(t, source, "SKU       string")
+	assert.Contains(t, source, "Quantity  int64")
+	assert.Contains(t, source, "Price     float32")
+	assert.Contains(t, source, "ShippedOn *time.Time")
+	assert.Contains(t, source, "Gift      bool")
+	assert.Contains(t, source, "OrderItemColumnShippedOn = \"shipped_on\"")
+
+	// Existing files are kept unless overwriting
+	_, err = codegen.Generate(dir, tables, codegen.Options{})
+	require.Error(t, err)
+	_, err = codegen.Generate(dir, tables, codegen.Options{Overwrite: true})
+	require.NoError(t, err)
+}
@@ -0,0 +1,159 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// articleStatus is the enum of the statuses of an article
+type articleStatus string
+
+func (articleStatus) Values() []articleStatus {
+	return []articleStatus{"draft", "published"}
+}
+
+// typedArticle has array and enum columns
+type typedArticle struct {
+	models.BaseModel
+	Title  string
+	Tags   models.StringArray
+	Scores models.Int64Array
+	Status models.Enum[articleStatus]
+}
+
+func TestArrayColumns_Scan(t *testing.T) {
+	var tags models.StringArray
+	require.NoError(t, tags.Scan(`{go,"a \"quoted\" tag","x,y",NULL}`))
+	assert.Equal(t, models.StringArray{"go", `a "quoted" tag`, "x,y", ""}, tags)
+	require.NoError(t, tags.Scan([]byte(`["json","array"]`)))
+	assert.Equal(t, models.StringArray{"json", "array"}, tags)
+	require.NoError(t, tags.Scan("{}"))
+	assert.Equal(t, models.StringArray{}, tags)
+	require.NoError(t, tags.Scan(nil))
+	assert.Nil(t, tags)
+	assert.Error(t, tags.Scan(`{"unterminated}`))
+	assert.Error(t, tags.Scan(`{{nested}}`))
+	assert.Error(t, tags.Scan(42))
+
+	var scores models.Int64Array
+	require.NoError(t, scores.Scan("{1,-2,30}"))
+	assert.Equal(t, models.Int64Array{1, -2, 30}, scores)
+	require.NoError(t, scores.Scan("[4,5]"))
+	assert.Equal(t, models.Int64Array{4, 5}, scores)
+	assert.Error(t, scores.Scan("{1,NULL}"))
+	assert.Error(t, scores.Scan("{one}"))
+
+	value, err := models.StringArray{"a", "b"}.Value()
+	require.NoError(t, err)
+	assert.Equal(t, `["a","b"]`, value)
+	value, err = models.Int64Array(nil).Value()
+	require.NoError(t, err)
+	assert.Nil(t, value)
+}
+
+func TestArrayColumns_Postgres(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	require.NoError(t, err)
+
+	article := typedArticle{
+		Title:  "arrays",
+		Tags:   models.StringArray{"go", `say "hi"`},
+		Scores: models.Int64Array{1, 2},
+	}
+	stmt := db.Create(&article).Statement
+	require.NoError(t, stmt.Error)
+	assert.Contains(t, stmt.Vars, `{"go","say \"hi\""}`)
+	assert.Contains(t, stmt.Vars, "{1,2}")
+
+	assert.Equal(t, "text[]", db.Migrator().FullDataTypeOf(stmt.Schema.LookUpField("Tags")).SQL)
+	assert.Equal(t, "bigint[]", db.Migrator().FullDataTypeOf(stmt.Schema.LookUpField("Scores")).SQL)
+}
+
+func TestEnum(t *testing.T) {
+	status, err := models.NewEnum(articleStatus("draft"))
+	require.NoError(t, err)
+	assert.Equal(t, articleStatus("draft"), status.Get())
+	assert.Equal(t, "draft", status.String())
+
+	_, err = models.NewEnum(articleStatus("archived"))
+	assert.ErrorContains(t, err, "must be one of")
+	assert.Error(t, status.Set("archived"))
+	assert.Equal(t, articleStatus("draft"), status.Get())
+
+	require.NoError(t, status.Scan([]byte("published")))
+	assert.Equal(t, articleStatus("published"), status.Get())
+	assert.Error(t, status.Scan("archived"))
+	require.NoError(t, status.Scan(nil))
+	assert.True(t, status.IsZero())
+
+	value, err := status.Value()
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	data, err := json.Marshal(struct {
+		Status models.Enum[articleStatus] `json:"status"`
+	}{})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status":null}`, string(data))
+	require.NoError(t, json.Unmarshal([]byte(`"published"`), &status))
+	assert.Equal(t, articleStatus("published"), status.Get())
+	assert.Error(t, json.Unmarshal([]byte(`"archived"`), &status))
+}
+
+func TestColumnTypes_Repository(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&typedArticle{}))
+	logger := logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{})
+	repos := map[string]repository.Repository[typedArticle]{
+		"base":   repository.NewBaseRepository[typedArticle](db, logger, repository.DefaultRepositoryConfig()),
+		"memory": repository.NewMemoryRepository[typedArticle](repository.DefaultRepositoryConfig()),
+	}
+	ctx := context.Background()
+
+	for kind, repo := range repos {
+		t.Run(kind, func(t *testing.T) {
+			published, err := models.NewEnum(articleStatus("published"))
+			require.NoError(t, err)
+			article := typedArticle{
+				Title:  "types",
+				Tags:   models.StringArray{"go", "orm"},
+				Scores: models.Int64Array{3, 5, 8},
+				Status: published,
+			}
+			require.NoError(t, repo.Create(ctx, &article))
+
+			found, err := repo.FindFirstByID(ctx, article.ID)
+			require.NoError(t, err)
+			assert.Equal(t, article.Tags, found.Tags)
+			assert.Equal(t, article.Scores, found.Scores)
+			assert.Equal(t, articleStatus("published"), found.Status.Get())
+
+			count, err := repo.CountByConditions(ctx, repository.Eq("status", published))
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), count)
+
+			var untyped typedArticle
+			require.NoError(t, repo.Create(ctx, &untyped))
+			found, err = repo.FindFirstByID(ctx, untyped.ID)
+			require.NoError(t, err)
+			assert.Nil(t, found.Tags)
+			assert.True(t, found.Status.IsZero())
+		})
+	}
+
+	// Invalid values stored outside the enum fail to load
+	require.NoError(t, db.Exec("UPDATE typed_articles SET status = ?", "archived").Error)
+	err = repos["base"].FindAllWithOffset(ctx, 10, 0, &[]typedArticle{})
+	assert.ErrorContains(t, err, "must be one of")
+}
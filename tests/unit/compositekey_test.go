@@ -0,0 +1,90 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// teamMembership is keyed by its team and user
+type teamMembership struct {
+	TeamID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Role   string
+}
+
+func TestKeyOf(t *testing.T) {
+	key, err := repository.KeyOf[teamMembership]()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team_id", "user_id"}, key.Columns())
+
+	membership := teamMembership{TeamID: uuid.New(), UserID: uuid.New(), Role: "owner"}
+	assert.Equal(t, map[string]interface{}{"team_id": membership.TeamID, "user_id": membership.UserID}, key.Of(&membership))
+
+	_, err = repository.KeyOf[struct{ Name string }]()
+	require.Error(t, err)
+	assert.True(t, errors.IsType(err, errors.ErrorTypeConfig))
+}
+
+func TestCompositeKeys(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&teamMembership{}))
+	logger := logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{})
+	repos := map[string]repository.Repository[teamMembership]{
+		"base":   repository.NewBaseRepository[teamMembership](db, logger, repository.DefaultRepositoryConfig()),
+		"memory": repository.NewMemoryRepository[teamMembership](repository.DefaultRepositoryConfig()),
+	}
+	key, err := repository.KeyOf[teamMembership]()
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	for kind, repo := range repos {
+		t.Run(kind, func(t *testing.T) {
+			team := uuid.New()
+			owner := teamMembership{TeamID: team, UserID: uuid.New(), Role: "owner"}
+			member := teamMembership{TeamID: team, UserID: uuid.New(), Role: "member"}
+			require.NoError(t, repo.Create(ctx, &owner))
+			require.NoError(t, repo.Create(ctx, &member))
+			assert.Error(t, repo.Create(ctx, &teamMembership{TeamID: team, UserID: owner.UserID}))
+
+			found, err := repo.FindFirstByKey(ctx, key.Of(&member))
+			require.NoError(t, err)
+			assert.Equal(t, "member", found.Role)
+
+			// Keys may name fields instead of columns
+			found, err = repo.FindFirstByKey(ctx, map[string]interface{}{"TeamID": team, "UserID": owner.UserID})
+			require.NoError(t, err)
+			assert.Equal(t, "owner", found.Role)
+
+			_, err = repo.FindFirstByKey(ctx, map[string]interface{}{"team_id": team, "user_id": uuid.New()})
+			assert.Error(t, err)
+
+			for _, invalid := range []map[string]interface{}{
+				{"team_id": team},
+				{"team_id": team, "user_id": owner.UserID, "role": "owner"},
+				{"team_id": team, "user_id": nil},
+			} {
+				_, err := repo.ExistsByKey(ctx, invalid)
+				require.Error(t, err)
+				assert.True(t, errors.IsType(err, errors.ErrorTypeValidation), "%v", invalid)
+			}
+
+			require.NoError(t, repo.DeleteByKey(ctx, key.Of(&owner)))
+			exists, err := repo.ExistsByKey(ctx, key.Of(&owner))
+			require.NoError(t, err)
+			assert.False(t, exists)
+			exists, err = repo.ExistsByKey(ctx, key.Of(&member))
+			require.NoError(t, err)
+			assert.True(t, exists)
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupCondRepository(t *testing.T) (*repository.BaseRepository[TestEntity], *gorm.DB) {
+	repo, db := setupTestRepository(t)
+	ctx := context.Background()
+	for _, e := range []TestEntity{{Name: "Alice", Age: 17}, {Name: "Bob", Age: 30}, {Name: "Carol", Age: 45}, {Name: "Dave", Age: 62}} {
+		entity := e
+		require.NoError(t, repo.Create(ctx, &entity))
+	}
+	return repo, db
+}
+
+func TestCond_Nesting(t *testing.T) {
+	repo, _ := setupCondRepository(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		cond  repository.Cond
+		names []string
+	}{
+		{"expr", repository.Expr("age > ?", 40), []string{"Carol", "Dave"}},
+		{"or group", repository.Expr("age >= ?", 18).And(repository.Or(
+			repository.Expr("name = ?", "Bob"),
+			repository.Expr("age > ?", 60),
+		)), []string{"Bob", "Dave"}},
+		{"nested", repository.Or(
+			repository.Expr("name = ?", "Alice"),
+			repository.And(repository.Expr("age > ?", 20), repository.Not(repository.Expr("name IN ?", []string{"Bob", "Dave"}))),
+		), []string{"Alice", "Carol"}},
+		{"named", repository.Expr("age BETWEEN @min AND @max OR name = @name", map[string]interface{}{"min": 20, "max": 40, "name": "Dave"}), []string{"Bob", "Dave"}},
+		{"match", repository.Match(map[string]interface{}{"name": []string{"Alice", "Carol"}, "age": 45}), []string{"Carol"}},
+		{"not or", repository.Not(repository.Expr("age < ?", 20).Or(repository.Expr("age > ?", 60))), []string{"Bob", "Carol"}},
+		{"zero", repository.And(repository.Cond{}, repository.Or()), []string{"Alice", "Bob", "Carol", "Dave"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var entities []TestEntity
+			require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &entities, tt.cond, repository.Expr("1 = 1")))
+			var names []string
+			for _, e := range entities {
+				names = append(names, e.Name)
+			}
+			assert.ElementsMatch(t, tt.names, names)
+
+			count, err := repo.CountByConditions(ctx, tt.cond)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(tt.names)), count)
+		})
+	}
+}
+
+func TestCond_SoftDelete(t *testing.T) {
+	repo, db := setupCondRepository(t)
+	ctx := context.Background()
+
+	var dave TestEntity
+	require.NoError(t, repo.FindFirstByConditions(ctx, &dave, "name = ?", "Dave"))
+	require.NoError(t, repo.DeleteByID(ctx, dave.ID))
+
+	// The OR group stays inside its parentheses, next to the soft delete filter
+	cond := repository.Expr("name = ?", "Alice").Or(repository.Expr("name = ?", "Dave"))
+	count, err := repo.CountByConditions(ctx, cond)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&TestEntity{}).Where(cond).Where("age > ?", 20).Find(&[]TestEntity{})
+	})
+	assert.Contains(t, sql, "WHERE ((name = \"Alice\") OR (name = \"Dave\")) AND age > 20")
+
+	updated, err := repo.UpdateAllByConditions(ctx, map[string]interface{}{"age": 18}, repository.Match(map[string]interface{}{"name": "Alice"}))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), updated)
+}
+
+func TestCond_Binding(t *testing.T) {
+	repo, _ := setupBinderRepository(t)
+	ctx := context.Background()
+
+	count, err := repo.CountByConditions(ctx, repository.Or(
+		repository.Expr("total >= ?", "30"),
+		repository.Expr("rush = ?", "true"),
+	))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	_, err = repo.CountByConditions(ctx, repository.Expr("total > ?", 0).And(repository.Not(repository.Expr("status = ?", "shipped"))))
+	validationErr, ok := errors.AsValidationError(err)
+	require.True(t, ok, "got %v", err)
+	assert.Equal(t, "status", validationErr.Fields()[0].Field)
+}
+
+func TestCond_Typed(t *testing.T) {
+	repo, db := setupCondRepository(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		cond  repository.Cond
+		names []string
+	}{
+		{"eq", repository.Eq("name", "Bob"), []string{"Bob"}},
+		{"neq", repository.Neq("name", "Bob"), []string{"Alice", "Carol", "Dave"}},
+		{"gt lte", repository.And(repository.Gt("age", 17), repository.Lte("age", 45)), []string{"Bob", "Carol"}},
+		{"gte or lt", repository.Or(repository.Gte("age", 62), repository.Lt("age", 18)), []string{"Alice", "Dave"}},
+		{"in args", repository.In("name", "Alice", "Dave"), []string{"Alice", "Dave"}},
+		{"in slice", repository.In("age", []int{30, 45}), []string{"Bob", "Carol"}},
+		{"in empty", repository.In("age"), nil},
+		{"not in", repository.NotIn("name", []string{"Alice", "Dave"}), []string{"Bob", "Carol"}},
+		{"like", repository.Like("name", "%o%"), []string{"Bob", "Carol"}},
+		{"between", repository.Between("age", 30, 45), []string{"Bob", "Carol"}},
+		{"null", repository.IsNull("deleted_at"), []string{"Alice", "Bob", "Carol", "Dave"}},
+		{"not null", repository.IsNotNull("deleted_at"), nil},
+		{"mixed", repository.Eq("name", "Carol").Or(repository.Expr("age < ?", 18)), []string{"Alice", "Carol"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var entities []TestEntity
+			require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &entities, tt.cond))
+			var names []string
+			for _, e := range entities {
+				names = append(names, e.Name)
+			}
+			assert.ElementsMatch(t, tt.names, names)
+		})
+	}
+
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Where(repository.Gt("age", 20).And(repository.Like("name", "A%"))).Find(&[]TestEntity{})
+	})
+	assert.Contains(t, sql, "WHERE (`test_entities`.`age` > 20 AND `test_entities`.`name` LIKE \"A%\")")
+}
+
+func TestCond_Columns(t *testing.T) {
+	repo, db := setupCondRepository(t)
+	require.NoError(t, db.Use(repository.NewConditionBinder()))
+	ctx := context.Background()
+
+	cond := repository.And(repository.Eq("name", "Bob"), repository.Or(repository.Gt("agee", 1), repository.Eq("users.name", "x")))
+	assert.Equal(t, []string{"name", "agee"}, cond.Columns())
+
+	err := repo.CheckConditions(repository.Eq("name", "Bob"), "age > ?", repository.In("Age", 1))
+	require.NoError(t, err)
+	err = repo.CheckConditions(cond)
+	require.Error(t, err)
+	validationErr, ok := errors.AsValidationError(err)
+	require.True(t, ok)
+	assert.Equal(t, "agee", validationErr.Field)
+
+	// The condition binder rejects them before the query runs
+	_, err = repo.CountByConditions(ctx, repository.Gt("agee", 1))
+	require.Error(t, err)
+	_, ok = errors.AsValidationError(err)
+	assert.True(t, ok)
+}
@@ -2,14 +2,20 @@ package unit
 
 import (
 	"context"
+	stderrors "errors"
+	"fmt"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/seasbee/go-ormx/pkg/config"
 	"github.com/seasbee/go-ormx/pkg/database"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
@@ -485,7 +491,7 @@ func TestHealthCheckResult_Structure(t *testing.T) {
 }
 
 func TestConnectionManager_ReadReplicaInitialization(t *testing.T) {
-	// Test that read replica initialization doesn't fail
+	// Test that enabled read replicas are opened, inheriting unset settings from the primary
 	cfg := createValidTestConfig()
 	cfg.ReadReplicas = []config.ReadReplicaConfig{
 		{
@@ -493,20 +499,24 @@ func TestConnectionManager_ReadReplicaInitialization(t *testing.T) {
 			Port:    5432,
 			Enabled: true,
 		},
+		{
+			Host:    "replica2",
+			Port:    5432,
+			Enabled: false,
+		},
 	}
 
-	// Should not fail even with read replica config
 	cm, err := database.NewConnectionManager(cfg)
 	require.NoError(t, err)
 	defer cm.Close()
 
-	// Read replicas should not be initialized (current implementation)
+	// Only the enabled replica is initialized
 	readDBs := cm.GetAllReadDBs()
-	assert.Empty(t, readDBs)
+	require.Len(t, readDBs, 1)
 
-	// GetReadDB should fall back to primary
+	// GetReadDB should route to the replica
 	readDB := cm.GetReadDB()
-	assert.Equal(t, cm.GetPrimaryDB(), readDB)
+	assert.Same(t, readDBs[0], readDB)
 }
 
 // Add missing test scenarios
@@ -692,3 +702,142 @@ func TestConnectionManager_MemoryPressure(t *testing.T) {
 	// Should handle large size configurations gracefully
 	assert.NotNil(t, cm.GetPrimaryDB())
 }
+
+type startupWidget struct {
+	ID   uint   `gorm:"primaryKey"`
+	Name string `gorm:"not null"`
+}
+
+func (startupWidget) TableName() string { return "startup_widgets" }
+
+type startupWidgetV2 struct {
+	ID    uint   `gorm:"primaryKey"`
+	Name  string `gorm:"not null"`
+	Color string `gorm:"index:idx_startup_widgets_color"`
+}
+
+func (startupWidgetV2) TableName() string { return "startup_widgets" }
+
+func TestRunStartupMigration(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// Disabled configuration is a no-op
+	report, err := database.RunStartupMigration(ctx, db, &config.StartupMigrationConfig{}, nil, &startupWidget{})
+	require.NoError(t, err)
+	assert.Nil(t, report)
+	assert.False(t, db.Migrator().HasTable(&startupWidget{}))
+
+	cfg := &config.StartupMigrationConfig{
+		Enabled:     true,
+		Environment: "development",
+		Entities:    []string{"startupWidget"},
+	}
+
+	// Only allowlisted entities are migrated
+	report, err = database.RunStartupMigration(ctx, db, cfg, nil, &startupWidget{}, &TestEntity{})
+	require.NoError(t, err)
+	require.Len(t, report.Changes, 1)
+	assert.True(t, report.Changes[0].CreatedTable)
+	assert.Equal(t, "startup_widgets", report.Changes[0].Table)
+	assert.False(t, db.Migrator().HasTable(&TestEntity{}))
+
+	// The diff lists added columns and indexes
+	cfg.Entities = []string{"startup_widgets"}
+	report, err = database.RunStartupMigration(ctx, db, cfg, nil, &startupWidgetV2{})
+	require.NoError(t, err)
+	require.Len(t, report.Changes, 1)
+	change := report.Changes[0]
+	assert.False(t, change.CreatedTable)
+	assert.Equal(t, []string{"color"}, change.AddedColumns)
+	assert.Equal(t, []string{"idx_startup_widgets_color"}, change.AddedIndexes)
+
+	report, err = database.RunStartupMigration(ctx, db, cfg, nil, &startupWidgetV2{})
+	require.NoError(t, err)
+	assert.False(t, report.Changes[0].Changed())
+
+	// Allowlisted entities must be registered
+	cfg.Entities = []string{"missing"}
+	_, err = database.RunStartupMigration(ctx, db, cfg, nil, &startupWidgetV2{})
+	assert.Error(t, err)
+
+	// Production is refused unless forced
+	cfg.Entities = []string{"startup_widgets"}
+	cfg.Environment = "Production"
+	_, err = database.RunStartupMigration(ctx, db, cfg, nil, &startupWidgetV2{})
+	assert.True(t, stderrors.Is(err, database.ErrProductionMigration))
+
+	cfg.Force = true
+	_, err = database.RunStartupMigration(ctx, db, cfg, nil, &startupWidgetV2{})
+	assert.NoError(t, err)
+}
+
+// seedNode creates a sqlite database file holding rows test entities
+func seedNode(t *testing.T, path string, rows int) {
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&TestEntity{}))
+	for i := 0; i < rows; i++ {
+		require.NoError(t, db.Create(&TestEntity{Name: fmt.Sprintf("row-%d", i), Age: 1}).Error)
+	}
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+}
+
+func TestConnectionManager_ReadReplicaRouting(t *testing.T) {
+	dir := t.TempDir()
+	primaryPath := filepath.Join(dir, "primary.db")
+	heavyPath := filepath.Join(dir, "heavy.db")
+	lightPath := filepath.Join(dir, "light.db")
+
+	// Row counts identify the node that served a read
+	seedNode(t, primaryPath, 10)
+	seedNode(t, heavyPath, 2)
+	seedNode(t, lightPath, 3)
+
+	cfg := createValidTestConfig()
+	cfg.Database = primaryPath
+	cfg.ReadReplicas = []config.ReadReplicaConfig{
+		{Host: "localhost", Port: 5432, Database: heavyPath, Weight: 3, Enabled: true},
+		{Host: "localhost", Port: 5432, Database: lightPath, Weight: 1, Enabled: true},
+		{Host: "localhost", Port: 5432, Database: filepath.Join(dir, "disabled.db"), Enabled: false},
+	}
+
+	cm, err := database.NewConnectionManager(cfg)
+	require.NoError(t, err)
+	defer cm.Close()
+	require.Len(t, cm.GetAllReadDBs(), 2)
+
+	logger := logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{})
+	repo := repository.NewBaseRepository[TestEntity](cm.GetPrimaryDB(), logger, nil).WithReadRouter(cm)
+	ctx := context.Background()
+
+	served := map[int64]int{}
+	for i := 0; i < 8; i++ {
+		count, err := repo.CountByConditions(ctx)
+		require.NoError(t, err)
+		served[count]++
+	}
+	assert.Equal(t, map[int64]int{2: 6, 3: 2}, served)
+
+	// Writes go to the primary
+	require.NoError(t, repo.Create(ctx, &TestEntity{Name: "written", Age: 1}))
+	var primaryCount int64
+	require.NoError(t, cm.GetPrimaryDB().Model(&TestEntity{}).Count(&primaryCount).Error)
+	assert.Equal(t, int64(11), primaryCount)
+
+	// A failing replica falls back to the primary and leaves the rotation
+	heavy, err := cm.GetAllReadDBs()[0].DB()
+	require.NoError(t, err)
+	require.NoError(t, heavy.Close())
+
+	served = map[int64]int{}
+	for i := 0; i < 8; i++ {
+		count, err := repo.CountByConditions(ctx)
+		require.NoError(t, err)
+		served[count]++
+	}
+	assert.Equal(t, map[int64]int{11: 1, 3: 7}, served)
+}
@@ -1161,3 +1161,21 @@ func TestDatabaseConfig_ConnectionPoolEdgeCases(t *testing.T) {
 	err = cfg.Validate()
 	assert.Error(t, err)
 }
+
+func TestStartupMigrationConfig_IsProduction(t *testing.T) {
+	for env, want := range map[string]bool{
+		"production":  true,
+		" PROD ":      true,
+		"live":        true,
+		"staging":     false,
+		"development": false,
+		"":            false,
+	} {
+		cfg := &config.StartupMigrationConfig{Environment: env}
+		assert.Equal(t, want, cfg.IsProduction(), env)
+	}
+
+	defaults := config.DefaultDatabaseConfig()
+	assert.False(t, defaults.StartupMigration.Enabled)
+	assert.Equal(t, "development", defaults.StartupMigration.Environment)
+}
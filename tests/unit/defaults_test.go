@@ -0,0 +1,135 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/migrations"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type defaultedOrder struct {
+	models.BaseModel
+	Status   string    `gorm:"not null;default:pending"`
+	Priority int       `gorm:"not null;default:3"`
+	PlacedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	Token    string    `gorm:"not null;default:(lower(hex(randomblob(8))))"`
+	Number   string    `gorm:"not null;uniqueIndex;defaultFunc:order_number"`
+}
+
+func setupDefaultsRepository(t *testing.T) (*repository.BaseRepository[defaultedOrder], *gorm.DB) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&defaultedOrder{}))
+
+	sequence := 0
+	require.NoError(t, db.Use(repository.NewDefaultValues().WithFunc("order_number", func(context.Context) (interface{}, error) {
+		sequence++
+		return fmt.Sprintf("ORD-%d", sequence), nil
+	})))
+
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	return repository.NewBaseRepository[defaultedOrder](db, logger, repository.DefaultRepositoryConfig()), db
+}
+
+func TestDefaultValues_Create(t *testing.T) {
+	repo, db := setupDefaultsRepository(t)
+	ctx := context.Background()
+
+	order := defaultedOrder{}
+	require.NoError(t, repo.Create(ctx, &order))
+	assert.Equal(t, "pending", order.Status)
+	assert.Equal(t, 3, order.Priority)
+	assert.WithinDuration(t, time.Now(), order.PlacedAt, time.Minute)
+	assert.Len(t, order.Token, 16)
+	assert.Equal(t, "ORD-1", order.Number)
+
+	// The entity holds the stored values
+	var stored defaultedOrder
+	require.NoError(t, db.First(&stored, "id = ?", order.ID).Error)
+	assert.Equal(t, order.Token, stored.Token)
+	assert.Equal(t, order.Number, stored.Number)
+
+	// Values set by the caller are kept
+	placed := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	explicit := defaultedOrder{Status: "paid", PlacedAt: placed, Token: "mine", Number: "EXT-1"}
+	require.NoError(t, repo.Create(ctx, &explicit))
+	assert.Equal(t, "paid", explicit.Status)
+	assert.True(t, placed.Equal(explicit.PlacedAt))
+	assert.Equal(t, "mine", explicit.Token)
+	assert.Equal(t, "EXT-1", explicit.Number)
+}
+
+func TestDefaultValues_Batches(t *testing.T) {
+	repo, db := setupDefaultsRepository(t)
+	ctx := context.Background()
+
+	// Mixed rows: defaulted columns set on some rows only
+	orders := []defaultedOrder{{Status: "paid", Token: "set"}, {}, {Number: "EXT-9"}, {}}
+	require.NoError(t, repo.CreateInBatches(ctx, orders, 2))
+
+	tokens := make(map[string]bool)
+	for _, order := range orders {
+		assert.NotEmpty(t, order.Number)
+		assert.False(t, order.PlacedAt.IsZero())
+		tokens[order.Token] = true
+	}
+	assert.Len(t, tokens, 4, "volatile expressions are evaluated per row")
+	assert.Equal(t, []string{"paid", "pending", "pending", "pending"}, []string{orders[0].Status, orders[1].Status, orders[2].Status, orders[3].Status})
+	assert.Equal(t, "EXT-9", orders[2].Number)
+
+	var statuses []string
+	require.NoError(t, db.Model(&defaultedOrder{}).Order("number").Pluck("status", &statuses).Error)
+	assert.Len(t, statuses, 4)
+
+	upserted := []defaultedOrder{{Number: "EXT-9", Status: "cancelled"}, {}}
+	require.NoError(t, repo.UpsertInBatches(ctx, upserted, 10, repository.OnConflict("number")))
+	assert.NotEmpty(t, upserted[1].Number)
+	assert.Equal(t, "pending", upserted[1].Status)
+	assert.Len(t, upserted[1].Token, 16)
+
+	count, err := repo.CountByConditions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), count)
+}
+
+func TestDefaultValues_UnknownFunc(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&defaultedOrder{}))
+	require.NoError(t, db.Use(repository.NewDefaultValues()))
+
+	err := db.Create(&defaultedOrder{}).Error
+	assert.ErrorContains(t, err, `unknown default function "order_number"`)
+}
+
+func TestCheckDefaults(t *testing.T) {
+	_, db := setupDefaultsRepository(t)
+	ctx := context.Background()
+
+	drifts, err := migrations.CheckDefaults(ctx, db, &defaultedOrder{})
+	require.NoError(t, err)
+	assert.Empty(t, drifts, "AutoMigrate creates the declared defaults")
+
+	require.NoError(t, db.Exec(`CREATE TABLE drifted_orders (id TEXT PRIMARY KEY, status TEXT DEFAULT 'open', priority INTEGER, placed_at DATETIME DEFAULT (CURRENT_TIMESTAMP))`).Error)
+	drifts, err = migrations.CheckDefaults(ctx, db, &driftedOrder{})
+	require.NoError(t, err)
+	require.Len(t, drifts, 2)
+	assert.Equal(t, migrations.DefaultDrift{Table: "drifted_orders", Column: "status", Expected: "pending", Actual: "'open'"}, drifts[0])
+	assert.Equal(t, "priority", drifts[1].Column)
+	assert.Contains(t, drifts[1].String(), "default missing")
+}
+
+type driftedOrder struct {
+	ID       string    `gorm:"primaryKey"`
+	Status   string    `gorm:"default:pending"`
+	Priority int       `gorm:"default:3"`
+	PlacedAt time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+func (driftedOrder) TableName() string { return "drifted_orders" }
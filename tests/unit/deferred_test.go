@@ -0,0 +1,123 @@
+package unit
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type deferredCategory struct {
+	models.BaseModel
+	Name     string            `gorm:"not null"`
+	ParentID *uuid.UUID        `gorm:"type:uuid"`
+	Parent   *deferredCategory `gorm:"foreignKey:ParentID"`
+}
+
+func setupDeferredRepository(t *testing.T) *repository.BaseRepository[deferredCategory] {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&deferredCategory{}))
+
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	config := repository.DefaultRepositoryConfig()
+	config.EnableValidation = false
+	return repository.NewBaseRepository[deferredCategory](db, logger, config)
+}
+
+func TestBaseRepository_DeferredValidation(t *testing.T) {
+	repo := setupDeferredRepository(t)
+	ctx := repository.WithDeferredValidation(context.Background())
+
+	t.Run("Out of order inserts", func(t *testing.T) {
+		parentID, childID := uuid.New(), uuid.New()
+		err := repo.WithTransaction(ctx, func(txRepo repository.Repository[deferredCategory]) error {
+			// The child references a parent inserted later in the transaction
+			if err := txRepo.Create(ctx, &deferredCategory{BaseModel: models.BaseModel{ID: childID}, Name: "child", ParentID: &parentID}); err != nil {
+				return err
+			}
+			return txRepo.Create(ctx, &deferredCategory{BaseModel: models.BaseModel{ID: parentID}, Name: "parent"})
+		})
+		require.NoError(t, err)
+
+		count, err := repo.CountAll(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("Missing reference rolls back", func(t *testing.T) {
+		missing := uuid.New()
+		committed := false
+		err := repo.WithTransaction(ctx, func(txRepo repository.Repository[deferredCategory]) error {
+			txRepo.AfterCommit(ctx, func(ctx context.Context) { committed = true })
+			return txRepo.CreateInBatches(ctx, []deferredCategory{
+				{Name: "orphan-1", ParentID: &missing},
+				{Name: "orphan-2", ParentID: &missing},
+			}, 10)
+		})
+		require.Error(t, err)
+		assert.True(t, stderrors.Is(err, repository.ErrMissingReference))
+
+		var missingErr *repository.MissingReferenceError
+		require.True(t, stderrors.As(err, &missingErr))
+		assert.Equal(t, "deferred_categories", missingErr.Table)
+		assert.Equal(t, "id", missingErr.Column)
+		assert.Len(t, missingErr.Values, 1)
+		assert.False(t, committed)
+
+		exists, err := repo.ExistsByConditions(ctx, "name = ?", "orphan-1")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("Nested transactions validate at the outermost commit", func(t *testing.T) {
+		parentID := uuid.New()
+		err := repo.WithTransaction(ctx, func(txRepo repository.Repository[deferredCategory]) error {
+			err := txRepo.WithTransaction(ctx, func(inner repository.Repository[deferredCategory]) error {
+				return inner.Create(ctx, &deferredCategory{Name: "nested child", ParentID: &parentID})
+			})
+			if err != nil {
+				return err
+			}
+			return txRepo.Create(ctx, &deferredCategory{BaseModel: models.BaseModel{ID: parentID}, Name: "nested parent"})
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("Without deferred validation references are not checked", func(t *testing.T) {
+		missing := uuid.New()
+		err := repo.WithTransaction(context.Background(), func(txRepo repository.Repository[deferredCategory]) error {
+			return txRepo.Create(context.Background(), &deferredCategory{Name: "unchecked", ParentID: &missing})
+		})
+		require.NoError(t, err)
+	})
+}
+
+func TestBaseRepository_DeferredConstraints(t *testing.T) {
+	repo := setupDeferredRepository(t)
+
+	// SQLite cannot defer constraints, the transaction runs unchanged
+	ctx := repository.WithDeferredConstraints(context.Background())
+	err := repo.WithTransaction(ctx, func(txRepo repository.Repository[deferredCategory]) error {
+		return txRepo.Create(ctx, &deferredCategory{Name: "root"})
+	})
+	require.NoError(t, err)
+
+	statement, ok := dialect.Postgres{}.DeferConstraints()
+	assert.True(t, ok)
+	assert.Equal(t, "SET CONSTRAINTS ALL DEFERRED", statement)
+
+	_, ok = dialect.CockroachDB{}.DeferConstraints()
+	assert.False(t, ok)
+	_, ok = dialect.Dialect(dialect.MySQL{}).(dialect.ConstraintDeferrer)
+	assert.False(t, ok)
+}
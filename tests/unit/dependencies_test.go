@@ -0,0 +1,141 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependencyGraph_Register(t *testing.T) {
+	graph := repository.NewDependencyGraph(nil)
+	noop := func(context.Context) error { return nil }
+
+	require.NoError(t, graph.Register(repository.ReadModel{Name: "leaderboard", DependsOn: []string{"scores", "players"}, Invalidate: noop}))
+	require.NoError(t, graph.Register(repository.ReadModel{Name: "homepage", DependsOn: []string{"leaderboard", "news"}, Invalidate: noop}))
+
+	assert.Error(t, graph.Register(repository.ReadModel{Name: "leaderboard", DependsOn: []string{"scores"}, Invalidate: noop}))
+	assert.Error(t, graph.Register(repository.ReadModel{Name: "", DependsOn: []string{"scores"}, Invalidate: noop}))
+	assert.Error(t, graph.Register(repository.ReadModel{Name: "orphan", Invalidate: noop}))
+	assert.Error(t, graph.Register(repository.ReadModel{Name: "lazy", DependsOn: []string{"scores"}}))
+	assert.Error(t, graph.Register(repository.ReadModel{Name: "self", DependsOn: []string{"self"}, Invalidate: noop}))
+
+	// Read models may declare dependencies registered later, but not cycles
+	require.NoError(t, graph.Register(repository.ReadModel{Name: "digest", DependsOn: []string{"summary"}, Invalidate: noop}))
+	assert.Error(t, graph.Register(repository.ReadModel{Name: "summary", DependsOn: []string{"digest"}, Invalidate: noop}))
+
+	assert.Equal(t, []string{"leaderboard", "homepage"}, graph.Dependents("scores"))
+	assert.Equal(t, []string{"homepage"}, graph.Dependents("news"))
+	assert.Equal(t, []string{"leaderboard", "homepage"}, graph.Dependents("news", "players"))
+	assert.Empty(t, graph.Dependents("unrelated"))
+}
+
+func TestDependencyGraph_TablesWritten(t *testing.T) {
+	graph := repository.NewDependencyGraph(nil)
+
+	var invalidated []string
+	record := func(name string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			invalidated = append(invalidated, name)
+			return err
+		}
+	}
+	require.NoError(t, graph.Register(repository.ReadModel{Name: "totals", DependsOn: []string{"orders"}, Invalidate: record("totals", errors.New("refresh failed"))}))
+	require.NoError(t, graph.Register(repository.ReadModel{Name: "report", DependsOn: []string{"totals", "orders"}, Invalidate: record("report", nil)}))
+
+	// Changes captured elsewhere are reported by table, failures do not stop the others
+	err := graph.TablesWritten(context.Background(), "orders")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "read model totals")
+	assert.Equal(t, []string{"totals", "report"}, invalidated)
+}
+
+func TestBaseRepository_WithDependencies(t *testing.T) {
+	repo, _ := setupTestRepository(t)
+	ctx := context.Background()
+	graph := repository.NewDependencyGraph(nil)
+	repo.WithDependencies(graph)
+
+	loads := 0
+	adults, err := repository.NewCachedQuery(graph, "adults", []string{"test_entities"}, 0, func(ctx context.Context) (int64, error) {
+		loads++
+		return repo.CountByConditions(ctx, "age >= ?", 18)
+	})
+	require.NoError(t, err)
+	invalidations := 0
+	require.NoError(t, graph.Register(repository.ReadModel{Name: "counter", DependsOn: []string{"test_entities"}, Invalidate: func(context.Context) error {
+		invalidations++
+		return nil
+	}}))
+
+	count, err := adults.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	// Writes to the table invalidate the cached query
+	entity := &TestEntity{Name: "Alice", Age: 30}
+	require.NoError(t, repo.Create(ctx, entity))
+	count, err = adults.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	count, err = adults.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	assert.Equal(t, 2, loads)
+
+	// Within a transaction dependents are invalidated once, after commit
+	invalidations = 0
+	require.NoError(t, repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		for _, name := range []string{"Bob", "Carol"} {
+			if err := tx.Create(ctx, &TestEntity{Name: name, Age: 40}); err != nil {
+				return err
+			}
+		}
+		assert.Zero(t, invalidations)
+		return nil
+	}))
+	assert.Equal(t, 1, invalidations)
+	count, err = adults.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	// Rolled back writes invalidate nothing
+	invalidations = 0
+	require.Error(t, repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		if err := tx.DeleteByID(ctx, entity.ID); err != nil {
+			return err
+		}
+		return errors.New("abort")
+	}))
+	assert.Zero(t, invalidations)
+
+	_, err = repo.UpdateAllByConditions(ctx, map[string]interface{}{"age": 10}, "name = ?", "Bob")
+	require.NoError(t, err)
+	assert.Equal(t, 1, invalidations)
+	count, err = adults.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestBaseRepository_DependenciesOfSchemaTable(t *testing.T) {
+	_, db := setupTestRepository(t)
+	ctx := context.Background()
+	require.NoError(t, db.AutoMigrate(&plainWidget{}))
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	graph := repository.NewDependencyGraph(nil)
+	widgets := repository.NewBaseRepository[plainWidget](db, logger, nil).WithDependencies(graph)
+
+	// Read models name the table, not the Go type of a model without TableName
+	invalidations := 0
+	require.NoError(t, graph.Register(repository.ReadModel{Name: "catalog", DependsOn: []string{"plain_widgets"}, Invalidate: func(context.Context) error {
+		invalidations++
+		return nil
+	}}))
+
+	require.NoError(t, widgets.Create(ctx, &plainWidget{Name: "gear"}))
+	assert.Equal(t, 1, invalidations)
+}
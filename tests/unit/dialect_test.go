@@ -0,0 +1,434 @@
+package unit
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/database"
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestDialect_Registry(t *testing.T) {
+	for _, name := range []string{"postgres", "mysql", "sqlite", "sqlserver", "oracle"} {
+		d, ok := dialect.Get(name)
+		require.True(t, ok, name)
+		assert.Equal(t, name, d.Name())
+	}
+
+	_, ok := dialect.Get("unknown")
+	assert.False(t, ok)
+
+	// For falls back to Postgres when the connection is unknown
+	assert.Equal(t, "postgres", dialect.For(nil).Name())
+	assert.Equal(t, "sqlite", dialect.For(setupTestDB(t)).Name())
+}
+
+func TestDialect_Paginate(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  dialect.Dialect
+		limit    int
+		offset   int
+		expected string
+	}{
+		{"postgres", dialect.Postgres{}, 10, 20, "LIMIT 10 OFFSET 20"},
+		{"mysql without offset", dialect.MySQL{}, 10, 0, "LIMIT 10"},
+		{"sqlite offset only", dialect.SQLite{}, 0, 5, "LIMIT -1 OFFSET 5"},
+		{"sqlserver", dialect.SQLServer{}, 10, 20, "OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY"},
+		{"oracle offset only", dialect.Oracle{}, 0, 20, "OFFSET 20 ROWS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.dialect.Paginate(tt.limit, tt.offset))
+		})
+	}
+}
+
+func TestDialect_ForUpdate(t *testing.T) {
+	assert.Equal(t, dialect.Locking{Suffix: "FOR UPDATE"}, dialect.Postgres{}.ForUpdate())
+	assert.Equal(t, dialect.Locking{TableHint: "WITH (UPDLOCK, ROWLOCK)"}, dialect.SQLServer{}.ForUpdate())
+	assert.Equal(t, dialect.Locking{Suffix: "FOR UPDATE"}, dialect.Oracle{}.ForUpdate())
+	assert.Equal(t, dialect.Locking{}, dialect.SQLite{}.ForUpdate())
+}
+
+func TestDialect_ForUpdateSkipLocked(t *testing.T) {
+	var locker dialect.SkipLocker = dialect.Postgres{}
+	assert.Equal(t, dialect.Locking{Suffix: "FOR UPDATE SKIP LOCKED"}, locker.ForUpdateSkipLocked())
+
+	locker = dialect.SQLServer{}
+	assert.Equal(t, dialect.Locking{TableHint: "WITH (UPDLOCK, ROWLOCK, READPAST)"}, locker.ForUpdateSkipLocked())
+
+	_, ok := dialect.Dialect(dialect.CockroachDB{}).(dialect.SkipLocker)
+	assert.True(t, ok)
+	_, ok = dialect.Dialect(dialect.SQLite{}).(dialect.SkipLocker)
+	assert.False(t, ok)
+}
+
+func TestDialect_Upsert(t *testing.T) {
+	columns := []string{"id", "name", "age"}
+	conflict := []string{"id"}
+	updates := []string{"name", "age"}
+
+	assert.Equal(t,
+		`INSERT INTO "users" ("id", "name", "age") VALUES ($1, $2, $3) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name", "age" = EXCLUDED."age"`,
+		dialect.Postgres{}.Upsert("users", columns, conflict, updates))
+
+	assert.Equal(t,
+		"INSERT INTO `users` (`id`, `name`, `age`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`), `age` = VALUES(`age`)",
+		dialect.MySQL{}.Upsert("users", columns, conflict, updates))
+
+	assert.Equal(t,
+		"INSERT IGNORE INTO `users` (`id`, `name`, `age`) VALUES (?, ?, ?)",
+		dialect.MySQL{}.Upsert("users", columns, conflict, nil))
+
+	assert.Equal(t,
+		"INSERT INTO `users` (`id`, `name`, `age`) VALUES (?, ?, ?) ON CONFLICT (`id`) DO NOTHING",
+		dialect.SQLite{}.Upsert("users", columns, conflict, nil))
+
+	assert.Equal(t,
+		"MERGE INTO [users] WITH (HOLDLOCK) AS target USING (VALUES (@p1, @p2, @p3)) AS source ([id], [name], [age]) ON (target.[id] = source.[id])"+
+			" WHEN MATCHED THEN UPDATE SET target.[name] = source.[name], target.[age] = source.[age]"+
+			" WHEN NOT MATCHED THEN INSERT ([id], [name], [age]) VALUES (source.[id], source.[name], source.[age]);",
+		dialect.SQLServer{}.Upsert("users", columns, conflict, updates))
+
+	assert.Equal(t,
+		`MERGE INTO "users" target USING (SELECT :1 AS "id", :2 AS "name", :3 AS "age" FROM dual) source ON (target."id" = source."id")`+
+			` WHEN NOT MATCHED THEN INSERT ("id", "name", "age") VALUES (source."id", source."name", source."age")`,
+		dialect.Oracle{}.Upsert("users", columns, conflict, nil))
+}
+
+func TestDialect_Quote(t *testing.T) {
+	assert.Equal(t, `"public"."users"`, dialect.Postgres{}.Quote("public.users"))
+	assert.Equal(t, "`we``ird`", dialect.MySQL{}.Quote("we`ird"))
+	assert.Equal(t, "[dbo].[users]", dialect.SQLServer{}.Quote("dbo.users"))
+	assert.Equal(t, `"users".*`, dialect.Oracle{}.Quote("users.*"))
+}
+
+func TestDatabase_RegisterDriver(t *testing.T) {
+	database.RegisterDriver("sqlite_registered", sqlite.Open)
+	assert.Contains(t, database.RegisteredDrivers(), "sqlite_registered")
+	assert.Contains(t, database.RegisteredDrivers(), "postgres")
+
+	cfg := createValidTestConfig()
+	cfg.Driver = "sqlite_registered"
+
+	cm, err := database.NewConnectionManager(cfg)
+	require.NoError(t, err)
+	defer cm.Close()
+
+	assert.NotNil(t, cm.GetPrimaryDB())
+}
+
+func TestBaseRepository_FindFirstByIDForUpdate(t *testing.T) {
+	repo, _ := setupTestRepository(t)
+	ctx := context.Background()
+
+	assert.Equal(t, "sqlite", repo.Dialect().Name())
+
+	entity := &TestEntity{Name: "Locked", Age: 40}
+	require.NoError(t, repo.Create(ctx, entity))
+
+	found, err := repo.FindFirstByIDForUpdate(ctx, entity.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Locked", found.Name)
+
+	// Soft deleted rows are neither locked nor returned, as by FindFirstByID
+	require.NoError(t, repo.SoftDeleteByID(ctx, entity.ID))
+	_, err = repo.FindFirstByIDForUpdate(ctx, entity.ID)
+	assert.True(t, stderrors.Is(err, gorm.ErrRecordNotFound), "%v", err)
+
+	found, err = repo.FindFirstByIDForUpdate(repository.WithDeleted(ctx), entity.ID)
+	require.NoError(t, err)
+	assert.True(t, found.IsDeleted())
+}
+
+func TestBaseRepository_PaginatesInDialect(t *testing.T) {
+	db := setupTestDB(t)
+
+	var captured string
+	require.NoError(t, db.Callback().Query().After("gorm:query").Register("test:capture_sql", func(tx *gorm.DB) {
+		captured = tx.Statement.SQL.String()
+	}))
+
+	// Like the dialectors of SQL Server and Oracle without a LIMIT clause
+	// builder, leaving the clause to gorm
+	delete(db.ClauseBuilders, "LIMIT")
+
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	ctx := context.Background()
+	var entities []TestEntity
+
+	// SQLite takes gorm's LIMIT/OFFSET
+	repo := repository.NewBaseRepository[TestEntity](db.Session(&gorm.Session{DryRun: true}), logger, repository.DefaultRepositoryConfig())
+	require.NoError(t, repo.FindAllWithOffset(ctx, 10, 20, &entities))
+	assert.True(t, strings.HasSuffix(captured, "LIMIT ? OFFSET ?"), captured)
+
+	// OFFSET/FETCH requires an order, the primary key by default
+	for _, name := range []string{"sqlserver", "oracle"} {
+		config := repository.DefaultRepositoryConfig()
+		config.Dialect = name
+		repo := repository.NewBaseRepository[TestEntity](db.Session(&gorm.Session{DryRun: true}), logger, config)
+
+		require.NoError(t, repo.FindAllWithOffset(ctx, 10, 20, &entities))
+		assert.True(t, strings.HasSuffix(captured, "ORDER BY `test_entities`.`id` OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY"), captured)
+
+		require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 5, 0, &entities, "age > ?", 18, repository.WithOrderBy("name desc")))
+		assert.True(t, strings.HasSuffix(captured, "ORDER BY `test_entities`.`name` DESC,`test_entities`.`id` OFFSET 0 ROWS FETCH NEXT 5 ROWS ONLY"), captured)
+	}
+}
+
+// mergeRecorder records the statements executed on a connection instead of
+// running them
+type mergeRecorder struct {
+	gorm.ConnPool
+	statements []string
+	args       [][]interface{}
+}
+
+func (m *mergeRecorder) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	m.statements = append(m.statements, query)
+	m.args = append(m.args, args)
+	return driver.RowsAffected(1), nil
+}
+
+func TestBaseRepository_UpsertsWithMerge(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&upsertProduct{}))
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	ctx := context.Background()
+
+	recorder := &mergeRecorder{ConnPool: db.ConnPool}
+	session := db.Session(&gorm.Session{})
+	session.Statement.ConnPool = recorder
+
+	config := repository.DefaultRepositoryConfig()
+	config.Dialect = "sqlserver"
+	repo := repository.NewBaseRepository[upsertProduct](session, logger, config)
+
+	product := &upsertProduct{SKU: "A-1", Name: "Anvil", Stock: 5, Version: 1}
+	require.NoError(t, repo.Upsert(ctx, product, repository.OnConflict("sku")))
+	require.Len(t, recorder.statements, 1)
+	statement := recorder.statements[0]
+	assert.True(t, strings.HasPrefix(statement, "MERGE INTO [upsert_products] WITH (HOLDLOCK) AS target USING (VALUES (@p1, "), statement)
+	assert.Contains(t, statement, "ON (target.[sku] = source.[sku]) WHEN MATCHED THEN UPDATE SET ")
+	assert.Contains(t, statement, "target.[name] = source.[name]")
+	// Neither the key nor the creation time are overwritten
+	assert.NotContains(t, statement, "target.[id] = source.[id]")
+	assert.NotContains(t, statement, "target.[created_at] = source.[created_at]")
+	assert.Contains(t, statement, "source.[created_at]")
+	assert.Equal(t, strings.Count(statement, "@p"), len(recorder.args[0]))
+	assert.Contains(t, recorder.args[0], "Anvil")
+
+	// Batches run one MERGE per row
+	recorder.statements = nil
+	products := []upsertProduct{{SKU: "B-1", Name: "Bolt", Stock: 1}, {SKU: "B-2", Name: "Bracket", Stock: 2}}
+	require.NoError(t, repo.UpsertInBatches(ctx, products, 1, repository.OnConflictDoNothing("sku")))
+	require.Len(t, recorder.statements, 2)
+	assert.NotContains(t, recorder.statements[0], "WHEN MATCHED")
+
+	// Conditional merges are refused
+	err := repo.Upsert(ctx, product, repository.ConflictOptions{Columns: []string{"sku"}, Where: "upsert_products.version < 2"})
+	assert.Error(t, err)
+
+	config.Dialect = "oracle"
+	recorder.statements = nil
+	repo = repository.NewBaseRepository[upsertProduct](session, logger, config)
+	require.NoError(t, repo.Upsert(ctx, &upsertProduct{SKU: "C-1", Name: "Clamp"}, repository.ConflictOptions{Columns: []string{"sku"}, DoUpdates: []string{"name"}}))
+	require.Len(t, recorder.statements, 1)
+	assert.True(t, strings.HasPrefix(recorder.statements[0], `MERGE INTO "upsert_products" target USING (SELECT :1 AS `), recorder.statements[0])
+	assert.Contains(t, recorder.statements[0], `WHEN MATCHED THEN UPDATE SET target."name" = source."name" WHEN NOT MATCHED`)
+}
+
+func TestDialect_CockroachDB(t *testing.T) {
+	d, ok := dialect.Get("cockroachdb")
+	require.True(t, ok)
+
+	assert.Equal(t,
+		`UPSERT INTO "users" ("id", "name") VALUES ($1, $2)`,
+		d.Upsert("users", []string{"id", "name"}, nil, []string{"name"}))
+	assert.Equal(t,
+		`INSERT INTO "users" ("id", "email") VALUES ($1, $2) ON CONFLICT ("email") DO UPDATE SET "id" = EXCLUDED."id"`,
+		d.Upsert("users", []string{"id", "email"}, []string{"email"}, []string{"id"}))
+
+	follower, ok := d.(dialect.FollowerReader)
+	require.True(t, ok)
+	assert.Equal(t, "AS OF SYSTEM TIME follower_read_timestamp()", follower.AsOfSystemTime(0))
+	assert.Equal(t, "AS OF SYSTEM TIME '-10s'", follower.AsOfSystemTime(10*time.Second))
+
+	_, isFollower := dialect.Dialect(dialect.Postgres{}).(dialect.FollowerReader)
+	assert.False(t, isFollower)
+}
+
+func TestBaseRepository_FollowerRead(t *testing.T) {
+	db := setupTestDB(t)
+
+	var captured string
+	require.NoError(t, db.Callback().Query().After("gorm:query").Register("test:capture_sql", func(tx *gorm.DB) {
+		captured = tx.Statement.SQL.String()
+	}))
+
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	config := repository.DefaultRepositoryConfig()
+	config.Dialect = "cockroachdb"
+
+	repo := repository.NewBaseRepository[TestEntity](db.Session(&gorm.Session{DryRun: true}), logger, config)
+	assert.Equal(t, "cockroachdb", repo.Dialect().Name())
+
+	ctx := repository.WithFollowerRead(context.Background(), 0)
+	_, _ = repo.CountByConditions(ctx, "age > ?", 18)
+	assert.Contains(t, captured, "test_entities AS OF SYSTEM TIME follower_read_timestamp()")
+
+	// Without the context option reads are not rewritten
+	_, _ = repo.CountByConditions(context.Background(), "age > ?", 18)
+	assert.NotContains(t, captured, "AS OF SYSTEM TIME")
+
+	// The table is named as by gorm for models without TableName
+	widgets := repository.NewBaseRepository[plainWidget](db.Session(&gorm.Session{DryRun: true}), logger, config)
+	_, _ = widgets.CountByConditions(ctx, "name = ?", "gear")
+	assert.Contains(t, captured, "FROM plain_widgets AS OF SYSTEM TIME follower_read_timestamp()")
+	assert.Contains(t, captured, "`plain_widgets`.`deleted_at` IS NULL")
+}
+
+func TestBaseRepository_CockroachTransactionRetry(t *testing.T) {
+	db := setupTestDB(t)
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	config := repository.DefaultRepositoryConfig()
+	config.Dialect = "cockroachdb"
+	config.MaxTransactionRetries = 2
+
+	repo := repository.NewBaseRepository[TestEntity](db, logger, config)
+	ctx := context.Background()
+
+	attempts := 0
+	err := repo.WithTransaction(ctx, func(txRepo repository.Repository[TestEntity]) error {
+		attempts++
+		if err := txRepo.Create(ctx, &TestEntity{Name: fmt.Sprintf("attempt-%d", attempts), Age: 20}); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			return &sqlStateError{code: "40001"}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+
+	// Only the successful attempt is committed
+	count, err := repo.CountAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// Callbacks of the attempts rolled back are dropped
+	attempts = 0
+	var commits, rollbacks int
+	err = repo.WithTransaction(ctx, func(txRepo repository.Repository[TestEntity]) error {
+		attempts++
+		txRepo.AfterCommit(ctx, func(context.Context) { commits++ })
+		txRepo.AfterRollback(ctx, func(context.Context) { rollbacks++ })
+		if attempts == 1 {
+			return &sqlStateError{code: "40001"}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, 1, commits)
+	assert.Equal(t, 1, rollbacks)
+
+	// Nested transactions leave the retries to the outermost one
+	attempts = 0
+	inner := 0
+	err = repo.WithTransaction(ctx, func(txRepo repository.Repository[TestEntity]) error {
+		attempts++
+		return txRepo.WithTransactionOpts(ctx, repository.TxOptions{Propagation: repository.PropagationNested}, func(ctx context.Context, nested repository.Repository[TestEntity]) error {
+			inner++
+			if inner == 1 {
+				return &sqlStateError{code: "40001"}
+			}
+			return nil
+		})
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, 2, inner)
+
+	// Retries are bounded
+	attempts = 0
+	err = repo.WithTransaction(ctx, func(txRepo repository.Repository[TestEntity]) error {
+		attempts++
+		return &sqlStateError{code: "40001"}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+// plainWidget has no TableName method, so gorm names its table plain_widgets
+type plainWidget struct {
+	models.BaseModel
+	Name string
+}
+
+func TestBaseRepository_LocksTableOfSchema(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&plainWidget{}))
+
+	var captured string
+	require.NoError(t, db.Callback().Query().After("gorm:query").Register("test:capture_sql", func(tx *gorm.DB) {
+		captured = tx.Statement.SQL.String()
+	}))
+
+	logger := logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{})
+	config := repository.DefaultRepositoryConfig()
+	config.Dialect = "sqlserver"
+	repo := repository.NewBaseRepository[plainWidget](db.Session(&gorm.Session{DryRun: true}), logger, config)
+
+	_, _ = repo.FindFirstByIDForUpdate(context.Background(), uuid.New())
+	assert.Contains(t, captured, "FROM plain_widgets WITH (UPDLOCK, ROWLOCK)")
+}
+
+func TestBaseRepository_CockroachRetryDropsReferences(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&deferredCategory{}))
+	logger := logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{})
+	config := repository.DefaultRepositoryConfig()
+	config.EnableValidation = false
+	config.Dialect = "cockroachdb"
+	repo := repository.NewBaseRepository[deferredCategory](db, logger, config)
+	ctx := repository.WithDeferredValidation(context.Background())
+
+	// The orphan of the attempt rolled back is not validated at commit
+	missing := uuid.New()
+	attempts := 0
+	err = repo.WithTransaction(ctx, func(txRepo repository.Repository[deferredCategory]) error {
+		attempts++
+		if attempts == 1 {
+			if err := txRepo.Create(ctx, &deferredCategory{Name: "orphan", ParentID: &missing}); err != nil {
+				return err
+			}
+			return &sqlStateError{code: "40001"}
+		}
+		return txRepo.Create(ctx, &deferredCategory{Name: "root"})
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+
+	count, err := repo.CountAll(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
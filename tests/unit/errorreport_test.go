@@ -0,0 +1,107 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/observability"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func duplicateEmail(email string) error {
+	return fmt.Errorf("create failed: %w", errors.New(errors.ErrorTypeDuplicate,
+		fmt.Sprintf("duplicate key value violates unique constraint on email '%s'", email)).
+		WithTable("users").WithOperation("Create"))
+}
+
+func TestErrorFingerprint(t *testing.T) {
+	assert.Equal(t, "row ? of ? failed for ? at ?",
+		observability.NormalizeErrorMessage(`row 12 of 400 failed for "bob" at 0x1f`))
+	assert.Equal(t, "entity ? not found",
+		observability.NormalizeErrorMessage("entity 0190a3c4-5d6e-7f80-9abc-def012345678 not found"))
+
+	assert.Equal(t, observability.ErrorFingerprint(duplicateEmail("a@example.com")),
+		observability.ErrorFingerprint(duplicateEmail("b@example.com")))
+
+	other := errors.New(errors.ErrorTypeDuplicate, "duplicate key value violates unique constraint on email 'a@example.com'").
+		WithTable("accounts").WithOperation("Create")
+	assert.NotEqual(t, observability.ErrorFingerprint(duplicateEmail("a@example.com")), observability.ErrorFingerprint(other))
+	assert.NotEqual(t, observability.ErrorFingerprint(fmt.Errorf("timeout after 5s")),
+		observability.ErrorFingerprint(fmt.Errorf("connection refused")))
+}
+
+func TestErrorReporter(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	logger := logging.NewLogger(logging.LogLevelInfo, &buf, &logging.TextFormatter{})
+	manager := observability.NewObservabilityManager(observability.DefaultObservabilityConfig(), logging.NewLogger(logging.LogLevelError, nil, nil))
+	reporter := observability.NewErrorReporter(logger, manager, &observability.ErrorReporterConfig{Interval: time.Hour, MaxGroups: 2})
+	defer reporter.Close()
+
+	fingerprint := reporter.Report(ctx, duplicateEmail("a@example.com"))
+	assert.Equal(t, fingerprint, reporter.Report(ctx, duplicateEmail("b@example.com")))
+	reporter.Report(ctx, duplicateEmail("c@example.com"))
+	reporter.Report(ctx, fmt.Errorf("connection refused"))
+	// Over the group limit
+	reporter.Report(ctx, fmt.Errorf("disk full"))
+	assert.Empty(t, reporter.Report(ctx, nil))
+	assert.Empty(t, buf.String())
+
+	groups := reporter.Groups()
+	require.Len(t, groups, 2)
+	assert.Equal(t, fingerprint, groups[0].Fingerprint)
+	assert.Equal(t, errors.ErrorTypeDuplicate, groups[0].Type)
+	assert.Equal(t, "users", groups[0].Table)
+	assert.Equal(t, "Create", groups[0].Operation)
+	assert.Equal(t, int64(3), groups[0].Count)
+	assert.Equal(t, int64(3), groups[0].Recent)
+	assert.Contains(t, groups[0].Example, "c@example.com")
+	assert.False(t, groups[0].FirstSeen.After(groups[0].LastSeen))
+	assert.Equal(t, errors.ErrorTypeUnknown, groups[1].Type)
+	assert.Equal(t, int64(1), reporter.Dropped())
+
+	reporter.Flush(ctx)
+	summary := buf.String()
+	assert.Equal(t, 2, strings.Count(summary, "Error summary"))
+	assert.Contains(t, summary, "fingerprint="+fingerprint)
+	assert.Contains(t, summary, "count=3, total=3")
+	assert.Contains(t, summary, "Error groups limit reached | max_groups=2, dropped=1")
+
+	metrics, err := manager.GetMetrics().GetMetricsByLabels(map[string]string{"fingerprint": fingerprint})
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "orm_error_groups_total", metrics[0].Name)
+	assert.Equal(t, float64(3), metrics[0].Value)
+
+	// Only the groups seen since the last summary are logged again
+	buf.Reset()
+	reporter.Report(ctx, duplicateEmail("d@example.com"))
+	reporter.Flush(ctx)
+	assert.Equal(t, 1, strings.Count(buf.String(), "Error summary"))
+	assert.Contains(t, buf.String(), "count=1, total=4")
+	assert.Equal(t, int64(0), reporter.Groups()[0].Recent)
+}
+
+func TestErrorReporter_PeriodicSummary(t *testing.T) {
+	output := &gatedWriter{ready: make(chan struct{})}
+	close(output.ready)
+	logger := logging.NewLogger(logging.LogLevelInfo, output, nil)
+	reporter := observability.NewErrorReporter(logger, nil, &observability.ErrorReporterConfig{Interval: 20 * time.Millisecond})
+	defer reporter.Close()
+
+	for i := 0; i < 5; i++ {
+		reporter.Report(context.Background(), fmt.Errorf("lock wait timeout on row %d", i))
+	}
+	assert.Eventually(t, func() bool {
+		output.mu.Lock()
+		defer output.mu.Unlock()
+		return strings.Contains(output.buf.String(), "message=lock wait timeout on row ?, example=lock wait timeout on row 4, count=5")
+	}, time.Second, 10*time.Millisecond)
+}
@@ -2,13 +2,16 @@ package unit
 
 import (
 	"context"
+	"database/sql"
 	stderrors "errors"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/seasbee/go-ormx/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestORMError_Error(t *testing.T) {
@@ -909,3 +912,79 @@ func TestErrorRetry_EdgeCases(t *testing.T) {
 	assert.Equal(t, 24*365*time.Hour, result.RetryDelay)
 	assert.True(t, result.Retryable)
 }
+
+// sqlStateError mimics driver errors exposing a SQLSTATE code
+type sqlStateError struct {
+	code string
+}
+
+func (e *sqlStateError) Error() string    { return "driver error" }
+func (e *sqlStateError) SQLState() string { return e.code }
+
+func TestIsSerializationFailure(t *testing.T) {
+	assert.False(t, errors.IsSerializationFailure(nil))
+	assert.True(t, errors.IsSerializationFailure(&sqlStateError{code: "40001"}))
+	assert.False(t, errors.IsSerializationFailure(&sqlStateError{code: "23505"}))
+	assert.True(t, errors.IsSerializationFailure(fmt.Errorf("wrapped: %w", &sqlStateError{code: "40001"})))
+	assert.True(t, errors.IsSerializationFailure(stderrors.New("ERROR: restart transaction: TransactionRetryWithProtoRefreshError (SQLSTATE 40001)")))
+	assert.False(t, errors.IsSerializationFailure(stderrors.New("syntax error")))
+}
+
+func TestValidationError_Fields(t *testing.T) {
+	verr := errors.NewFieldValidationError("users", []errors.FieldError{
+		{Field: "Email", Rule: "email", Message: "invalid email"},
+		{Field: "Email", Rule: "max", Message: "too long"},
+		{Field: "Name", Rule: "required", Message: "is required"},
+	})
+
+	assert.Equal(t, "validation: validation failed: Email: invalid email; Email: too long; Name: is required", verr.Error())
+	assert.Len(t, verr.Fields(), 3)
+	assert.Equal(t, map[string][]string{
+		"Email": {"invalid email", "too long"},
+		"Name":  {"is required"},
+	}, verr.FieldMap())
+
+	verr.WithIndex(2)
+	assert.Contains(t, verr.Error(), "validation failed for entity 2")
+
+	// The typed error is also an ORMError of type validation
+	wrapped := fmt.Errorf("create: %w", verr)
+	var ormErr *errors.ORMError
+	require.True(t, stderrors.As(wrapped, &ormErr))
+	assert.Equal(t, errors.ErrorTypeValidation, ormErr.Type)
+	assert.Equal(t, "users", ormErr.Table)
+
+	found, ok := errors.AsValidationError(wrapped)
+	require.True(t, ok)
+	assert.Same(t, verr, found)
+
+	_, ok = errors.AsValidationError(stderrors.New("other"))
+	assert.False(t, ok)
+}
+
+func TestErrorPredicates(t *testing.T) {
+	notFound := errors.NewNotFoundError("user not found").WithTable("users")
+	wrapped := fmt.Errorf("lookup: %w", errors.Wrap(notFound, errors.ErrorTypeQuery, "query failed"))
+	assert.True(t, errors.IsType(wrapped, errors.ErrorTypeQuery))
+	assert.True(t, errors.IsType(wrapped, errors.ErrorTypeNotFound), "types of the wrapped errors match")
+	assert.True(t, errors.IsNotFound(wrapped))
+	assert.True(t, errors.IsNotFound(fmt.Errorf("scan: %w", sql.ErrNoRows)))
+	assert.ErrorIs(t, wrapped, errors.ErrNotFound)
+	assert.NotErrorIs(t, errors.NewQueryError("syntax error"), errors.ErrNotFound)
+	assert.True(t, errors.IsNotFound(errors.NewErrorClassifier().ClassifyError(
+		stderrors.New("no rows in result set"), "Find")))
+	assert.False(t, errors.IsDuplicate(wrapped))
+	assert.False(t, errors.IsNotFound(nil))
+
+	assert.True(t, errors.IsDuplicate(errors.NewDuplicateError("email taken")))
+	assert.True(t, errors.IsDuplicate(errors.NewErrorClassifier().ClassifyError(
+		stderrors.New("UNIQUE constraint failed: users.email"), "Create")))
+
+	assert.True(t, errors.IsTimeout(errors.NewTimeoutError("statement timed out")))
+	assert.True(t, errors.IsTimeout(fmt.Errorf("count: %w", context.DeadlineExceeded)))
+	assert.False(t, errors.IsTimeout(context.Canceled))
+
+	// Validation errors match their type
+	verr := errors.NewFieldValidationError("users", []errors.FieldError{{Field: "Name", Rule: "required", Message: "is required"}})
+	assert.True(t, errors.IsType(fmt.Errorf("create: %w", verr), errors.ErrorTypeValidation))
+}
@@ -0,0 +1,152 @@
+//go:build !ormx_minimal
+
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/observability"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheusHandler(t *testing.T) {
+	config := observability.DefaultObservabilityConfig()
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	manager := observability.NewObservabilityManager(config, logger)
+	ctx := context.Background()
+
+	manager.RecordQueryMetrics(ctx, "SELECT \"name\" FROM users", 250*time.Millisecond, 3, true)
+	manager.RecordTransactionMetrics(ctx, "commit", time.Second, true)
+	manager.RecordConnectionMetrics(ctx, 4, 6, 20)
+	manager.RecordCacheMetrics(ctx, 30, 10, 1, 100, 1000)
+	manager.RecordErrorMetrics(ctx, "timeout", "E42", nil)
+
+	recorder := httptest.NewRecorder()
+	observability.PrometheusHandler(manager.GetMetrics()).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", recorder.Header().Get("Content-Type"))
+
+	body := recorder.Body.String()
+	assert.Contains(t, body, "# HELP orm_connections_active Number of active connections\n# TYPE orm_connections_active gauge\norm_connections_active{pool=\"database\"} 4\n")
+	assert.Contains(t, body, "# TYPE orm_cache_hit_rate_percent gauge\norm_cache_hit_rate_percent{cache=\"orm\"} 75\n")
+	assert.Contains(t, body, "# TYPE orm_errors_total counter\norm_errors_total{error_code=\"E42\",error_type=\"timeout\"} 1\n")
+	assert.Contains(t, body, "orm_query_duration_seconds{query=\"SELECT \\\"name\\\" FROM users\",success=\"true\"} 0.25\n")
+	assert.Contains(t, body, "orm_transaction_total{operation=\"commit\",success=\"true\"} 1\n")
+
+	// Metric families are sorted by name
+	assert.Less(t, strings.Index(body, "orm_cache_hits_total"), strings.Index(body, "orm_connections_active"))
+}
+
+func TestPrometheusExporter(t *testing.T) {
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	exporter := observability.NewPrometheusExporter(logger)
+	ctx := context.Background()
+
+	recorder := httptest.NewRecorder()
+	exporter.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Empty(t, recorder.Body.String())
+
+	require.NoError(t, exporter.Export(ctx, []observability.Metric{
+		{Name: "jobs.queue-depth", Type: observability.MetricTypeGauge, Value: math.Inf(1), Labels: map[string]string{"queue-name": "a\nb"}},
+		{Name: "orm_restarts_total", Type: observability.MetricTypeCounter, Value: 2, Description: "Restarts\\total"},
+	}))
+
+	var buf strings.Builder
+	_, err := exporter.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, "# TYPE jobs_queue_depth gauge\n"+
+		"jobs_queue_depth{queue_name=\"a\\nb\"} +Inf\n"+
+		"# HELP orm_restarts_total Restarts\\\\total\n"+
+		"# TYPE orm_restarts_total counter\n"+
+		"orm_restarts_total 2\n", buf.String())
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var (
+		received map[string]interface{}
+		header   string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		header = r.Header.Get("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	config := observability.DefaultObservabilityConfig()
+	config.ExportInterval = time.Hour
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	manager := observability.NewObservabilityManager(config, logger)
+	manager.AddTraceExporter(observability.NewOTLPExporter(logger, &observability.OTLPConfig{
+		Endpoint:    server.URL + "/v1/traces",
+		Headers:     map[string]string{"Authorization": "Bearer token"},
+		ServiceName: "orders",
+		Timeout:     time.Second,
+	}))
+
+	ctx := context.Background()
+	require.NoError(t, manager.Start(ctx))
+
+	txCtx, txSpan := manager.StartTransactionSpan(ctx, "commit")
+	_, querySpan := manager.StartQuerySpan(txCtx, "INSERT INTO orders", "insert")
+	manager.EndSpan(querySpan, errors.New("constraint violated"))
+	manager.EndSpan(txSpan, nil)
+
+	// Stopping flushes the ended spans
+	require.NoError(t, manager.Stop(ctx))
+	require.NotNil(t, received)
+	assert.Equal(t, "Bearer token", header)
+
+	resourceSpans := received["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	assert.Contains(t, resourceSpans["resource"].(map[string]interface{})["attributes"],
+		map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "orders"}})
+
+	scopeSpans := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})
+	spans := scopeSpans["spans"].([]interface{})
+	require.Len(t, spans, 2)
+
+	query := spans[0].(map[string]interface{})
+	assert.Equal(t, "orm.insert", query["name"])
+	assert.Equal(t, string(querySpan.TraceID), query["traceId"])
+	assert.Equal(t, string(querySpan.SpanID), query["spanId"])
+	assert.Equal(t, string(txSpan.SpanID), query["parentSpanId"])
+	assert.Equal(t, float64(1), query["kind"])
+	assert.Equal(t, map[string]interface{}{"code": float64(2), "message": "constraint violated"}, query["status"])
+	assert.Contains(t, query["attributes"],
+		map[string]interface{}{"key": "query", "value": map[string]interface{}{"stringValue": "INSERT INTO orders"}})
+
+	tx := spans[1].(map[string]interface{})
+	assert.NotContains(t, tx, "parentSpanId")
+	assert.Equal(t, map[string]interface{}{"code": float64(1)}, tx["status"])
+}
+
+func TestOTLPExporter_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	exporter := observability.NewOTLPExporter(logger, &observability.OTLPConfig{Endpoint: server.URL})
+	ctx := context.Background()
+
+	// Nothing to export is not an error
+	assert.NoError(t, exporter.Export(ctx, nil))
+
+	err := exporter.ExportSpan(ctx, &observability.Span{TraceID: "legacy", SpanID: "span", Name: "orm.select", StartTime: time.Now()})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "429")
+	assert.Contains(t, err.Error(), "quota exceeded")
+}
@@ -0,0 +1,112 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/filters"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// filterAccount names its fields in JSON and hides one of them
+type filterAccount struct {
+	ID       int    `json:"id"`
+	Email    string `json:"email_address"`
+	Plan     string `json:"plan" validate:"oneof=free pro"`
+	Active   bool   `json:"active"`
+	Password string `json:"-"`
+}
+
+func TestFilters_Parse(t *testing.T) {
+	db := setupTestDB(t)
+	base := repository.NewBaseRepository[TestEntity](db, logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{}), repository.DefaultRepositoryConfig())
+	repos := map[string]repository.Repository[TestEntity]{
+		"base":   base,
+		"memory": repository.NewMemoryRepository[TestEntity](repository.DefaultRepositoryConfig()),
+	}
+	ctx := context.Background()
+
+	for kind, repo := range repos {
+		t.Run(kind, func(t *testing.T) {
+			for _, entity := range []TestEntity{{Name: "Alice", Age: 34}, {Name: "Albert", Age: 25}, {Name: "Bob", Age: 41}, {Name: "Carol O'Neil", Age: 30}} {
+				require.NoError(t, repo.Create(ctx, &entity))
+			}
+
+			for query, expected := range map[string][]string{
+				"":                   {"Albert", "Alice", "Bob", "Carol O'Neil"},
+				"age>=30;name==Al*":  {"Alice"},
+				"age=lt=30,name==B*": {"Albert", "Bob"},
+				"age > 30 and (name==Bob or name==Alice)": {"Alice", "Bob"},
+				"Name=in=(Bob,'Carol O\\'Neil')":          {"Bob", "Carol O'Neil"},
+				"age=out=(25,34,41)":                      {"Carol O'Neil"},
+				"name!=Al*;deleted_at==null":              {"Bob", "Carol O'Neil"},
+				"name=='Al*'":                             nil,
+			} {
+				cond, err := filters.Parse[TestEntity](query)
+				require.NoError(t, err, query)
+				var found []TestEntity
+				require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &found, cond), query)
+				var names []string
+				for _, entity := range found {
+					names = append(names, entity.Name)
+				}
+				assert.ElementsMatch(t, expected, names, query)
+			}
+		})
+	}
+}
+
+func TestFilters_Errors(t *testing.T) {
+	for query, message := range map[string]string{
+		"age>=":            "position 6: expected a value",
+		"age~30":           "position 4: expected an operator after age",
+		"(age==1":          "position 8: expected )",
+		"age=in=1":         "position 8: expected ( starting a list",
+		"name=='open":      "position 7: unterminated quoted value",
+		"age==1)":          "position 7: unexpected ')'",
+		"((((((((((((a==1": "more than 10 nested groups",
+	} {
+		_, err := filters.Parse[TestEntity](query)
+		require.Error(t, err, query)
+		assert.True(t, errors.IsType(err, errors.ErrorTypeValidation), query)
+		assert.Contains(t, err.Error(), message, query)
+	}
+
+	_, err := filters.Parse[TestEntity]("age==1;age==2;age==3", filters.MaxComparisons(2))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "more than 2 comparisons")
+
+	// Values are converted to the type of the field
+	for query, rule := range map[string]string{
+		"age==old":                "number",
+		"id==42":                  "uuid",
+		"created_at=gt=yesterday": "datetime",
+		"age==3*":                 "wildcard",
+	} {
+		_, err := filters.Parse[TestEntity](query)
+		require.Error(t, err, query)
+		var validation *errors.ValidationError
+		require.ErrorAs(t, err, &validation, query)
+		assert.Equal(t, rule, validation.Fields()[0].Rule, query)
+	}
+
+	// Fields are named by JSON name, column or Go name; hidden and
+	// disallowed fields cannot be filtered
+	_, err = filters.Parse[filterAccount]("email_address==a@example.com;Email==b*;active==true;plan=in=(free,pro)")
+	require.NoError(t, err)
+	for query, options := range map[string][]filters.Option{
+		"password==secret": nil,
+		"plan==team":       nil,
+		"active==true":     {filters.Fields("email_address", "plan")},
+	} {
+		_, err := filters.Parse[filterAccount](query, options...)
+		var validation *errors.ValidationError
+		require.ErrorAs(t, err, &validation, query)
+	}
+	_, err = filters.Parse[filterAccount]("plan==free", filters.Fields("missing"))
+	assert.True(t, errors.IsType(err, errors.ErrorTypeConfig))
+}
@@ -0,0 +1,122 @@
+package unit
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type account struct {
+	models.BaseModel
+	Email string `gorm:"not null;uniqueIndex"`
+	Plan  string `gorm:"not null"`
+}
+
+func setupAccounts(t *testing.T, db *gorm.DB) *repository.BaseRepository[account] {
+	require.NoError(t, db.AutoMigrate(&account{}))
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	return repository.NewBaseRepository[account](db, logger, repository.DefaultRepositoryConfig())
+}
+
+func TestFindOrCreateByConditions(t *testing.T) {
+	repo := setupAccounts(t, setupTestDB(t))
+	ctx := context.Background()
+
+	var created account
+	ok, err := repo.FindOrCreateByConditions(ctx, &created, map[string]interface{}{"plan": "free"}, map[string]interface{}{"email": "alice@example.com"})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "alice@example.com", created.Email, "initialized from the conditions")
+	assert.Equal(t, "free", created.Plan, "initialized from attrs")
+
+	// Attrs only apply to created entities
+	var found account
+	ok, err = repo.FindOrCreateByConditions(ctx, &found, account{Plan: "pro"}, "email = ?", "alice@example.com")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, created.ID, found.ID)
+	assert.Equal(t, "free", found.Plan)
+
+	ok, err = repo.FindOrCreateByConditions(ctx, &found, nil, account{Email: "bob@example.com", Plan: "pro"})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "pro", found.Plan)
+
+	// A conflict with a row the conditions do not match is an error
+	_, err = repo.FindOrCreateByConditions(ctx, &found, map[string]interface{}{"email": "bob@example.com"}, "plan = ?", "enterprise")
+	assert.ErrorContains(t, err, "conflicts with a row not matching the conditions")
+
+	_, err = repo.FindOrCreateByConditions(ctx, &found, nil)
+	assert.Error(t, err)
+
+	count, err := repo.CountByConditions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestFindOrCreateByConditions_Concurrent(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "accounts.db")+"?_busy_timeout=5000"), &gorm.Config{})
+	require.NoError(t, err)
+	repo := setupAccounts(t, db)
+	ctx := context.Background()
+
+	const callers = 8
+	var wg sync.WaitGroup
+	results := make([]account, callers)
+	created := make([]bool, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			created[i], errs[i] = repo.FindOrCreateByConditions(ctx, &results[i], map[string]interface{}{"plan": "free"}, map[string]interface{}{"email": "race@example.com"})
+		}(i)
+	}
+	wg.Wait()
+
+	creators := 0
+	for i := 0; i < callers; i++ {
+		require.NoError(t, errs[i])
+		assert.Equal(t, results[0].ID, results[i].ID, "every caller ends with the same entity")
+		if created[i] {
+			creators++
+		}
+	}
+	assert.Equal(t, 1, creators)
+	assert.Equal(t, int64(1), countRows(t, db, &account{}))
+}
+
+func TestTenantScopedRepository_FindOrCreate(t *testing.T) {
+	repo, _ := setupTenantRepository(t, nil)
+	acme := repository.WithTenant(context.Background(), "acme")
+	globex := repository.WithTenant(context.Background(), "globex")
+
+	var note tenantNote
+	created, err := repo.FindOrCreateByConditions(acme, &note, nil, map[string]interface{}{"title": "welcome"})
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "acme", note.TenantID)
+	assert.Equal(t, "welcome", note.Title)
+
+	// Another tenant's entity is not found
+	var other tenantNote
+	created, err = repo.FindOrCreateByConditions(globex, &other, nil, map[string]interface{}{"title": "welcome"})
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "globex", other.TenantID)
+	assert.NotEqual(t, note.ID, other.ID)
+
+	created, err = repo.FindOrCreateByConditions(acme, &other, nil, "title = ?", "welcome")
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, note.ID, other.ID)
+}
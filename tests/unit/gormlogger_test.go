@@ -0,0 +1,105 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type gormLoggerAccount struct {
+	ID       uint `gorm:"primaryKey"`
+	Email    string
+	Password string
+}
+
+func openGormLoggerDB(t *testing.T, level logging.LogLevel, config *logging.GormLoggerConfig) (*gorm.DB, *bytes.Buffer) {
+	var buf bytes.Buffer
+	logger := logging.NewLogger(level, &buf, &logging.TextFormatter{})
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logging.NewGormLogger(logger, config)})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&gormLoggerAccount{}))
+	buf.Reset()
+	return db, &buf
+}
+
+func TestGormLogger_Levels(t *testing.T) {
+	db, buf := openGormLoggerDB(t, logging.LogLevelDebug, nil)
+	require.NoError(t, db.Create(&gormLoggerAccount{Email: "a@example.com"}).Error)
+	assert.Contains(t, buf.String(), "Query executed")
+	assert.Contains(t, buf.String(), "a@example.com")
+
+	db, buf = openGormLoggerDB(t, logging.LogLevelError, nil)
+	require.NoError(t, db.Create(&gormLoggerAccount{Email: "a@example.com"}).Error)
+	assert.Empty(t, buf.String())
+
+	assert.Error(t, db.Exec("SELECT * FROM missing_table").Error)
+	assert.Contains(t, buf.String(), "Query failed")
+
+	buf.Reset()
+	err := db.First(&gormLoggerAccount{}, 42).Error
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.Contains(t, buf.String(), "Query failed")
+
+	db, buf = openGormLoggerDB(t, logging.LogLevelError, &logging.GormLoggerConfig{IgnoreRecordNotFound: true})
+	assert.ErrorIs(t, db.First(&gormLoggerAccount{}, 42).Error, gorm.ErrRecordNotFound)
+	assert.Empty(t, buf.String())
+
+	// LogMode overrides the level of the logger
+	db = db.Session(&gorm.Session{Logger: db.Logger.LogMode(gormlogger.Silent)})
+	assert.Error(t, db.Exec("SELECT * FROM missing_table").Error)
+	assert.Empty(t, buf.String())
+}
+
+func TestGormLogger_SlowQueries(t *testing.T) {
+	db, buf := openGormLoggerDB(t, logging.LogLevelWarn, &logging.GormLoggerConfig{SlowThreshold: time.Nanosecond})
+	require.NoError(t, db.Find(&[]gormLoggerAccount{}).Error)
+	assert.Contains(t, buf.String(), "Slow query detected")
+	assert.Contains(t, buf.String(), "threshold")
+
+	db, buf = openGormLoggerDB(t, logging.LogLevelWarn, &logging.GormLoggerConfig{SlowThreshold: -1})
+	require.NoError(t, db.Find(&[]gormLoggerAccount{}).Error)
+	assert.Empty(t, buf.String())
+}
+
+func TestGormLogger_ParameterizedQueries(t *testing.T) {
+	db, buf := openGormLoggerDB(t, logging.LogLevelDebug, &logging.GormLoggerConfig{ParameterizedQueries: true})
+	require.NoError(t, db.Where("email = ?", "a@example.com").Find(&[]gormLoggerAccount{}).Error)
+	assert.Contains(t, buf.String(), "email = ?")
+	assert.NotContains(t, buf.String(), "a@example.com")
+}
+
+func TestGormLogger_SensitiveColumns(t *testing.T) {
+	db, buf := openGormLoggerDB(t, logging.LogLevelDebug, &logging.GormLoggerConfig{SensitiveColumns: []string{"Password"}})
+
+	require.NoError(t, db.Create(&[]gormLoggerAccount{
+		{Email: "a@example.com", Password: "hunter2"},
+		{Email: "b@example.com", Password: "swordfish"},
+	}).Error)
+	require.NoError(t, db.Where("password = ? AND email = ?", "hunter2", "a@example.com").Find(&[]gormLoggerAccount{}).Error)
+	require.NoError(t, db.Where("gorm_logger_accounts.password IN ?", []string{"hunter2", "swordfish"}).Find(&[]gormLoggerAccount{}).Error)
+	require.NoError(t, db.Model(&gormLoggerAccount{}).Where("id = ?", 1).Update("password", "letmein").Error)
+
+	logged := buf.String()
+	assert.NotContains(t, logged, "hunter2")
+	assert.NotContains(t, logged, "swordfish")
+	assert.NotContains(t, logged, "letmein")
+	assert.Contains(t, logged, "***")
+	assert.Contains(t, logged, "a@example.com")
+	assert.Contains(t, logged, "b@example.com")
+
+	filter := logging.NewGormLogger(logging.NewLogger(logging.LogLevelDebug, &bytes.Buffer{}, nil), &logging.GormLoggerConfig{
+		SensitiveColumns: []string{"password"},
+		Mask:             "[redacted]",
+	})
+	_, params := filter.ParamsFilter(context.Background(),
+		`UPDATE "accounts" SET "password"=$2 WHERE "email" = $1`, "a@example.com", "hunter2")
+	assert.Equal(t, []interface{}{"a@example.com", "[redacted]"}, params)
+}
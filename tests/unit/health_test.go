@@ -0,0 +1,141 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/config"
+	"github.com/seasbee/go-ormx/pkg/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type healthProbe struct {
+	ID int
+}
+
+// healthTestChecker checks the health_probes table, failing while it does not exist
+func healthTestChecker(t *testing.T, recovery time.Duration) (*database.HealthChecker, *gorm.DB) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&healthProbe{}))
+	// Every connection to :memory: opens its own database
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	checker := database.NewHealthChecker("primary", db, config.HealthCheckConfig{
+		Enabled:      true,
+		Interval:     5 * time.Millisecond,
+		Timeout:      time.Second,
+		Query:        "SELECT COUNT(*) FROM health_probes",
+		MaxFailures:  2,
+		RecoveryTime: recovery,
+	})
+	return checker, db
+}
+
+func TestHealthChecker_StateMachine(t *testing.T) {
+	checker, db := healthTestChecker(t, 30*time.Millisecond)
+	ctx := context.Background()
+
+	var transitions []string
+	checker.OnStateChange(func(tr database.HealthTransition) {
+		transitions = append(transitions, string(tr.From)+"->"+string(tr.To))
+	})
+
+	status := checker.RunCheck(ctx)
+	assert.Equal(t, database.HealthStateHealthy, status.State)
+	assert.Equal(t, "primary", status.Name)
+
+	require.NoError(t, db.Migrator().DropTable(&healthProbe{}))
+	status = checker.RunCheck(ctx)
+	assert.Equal(t, database.HealthStateDegraded, status.State)
+	assert.Equal(t, 1, status.ConsecutiveFailures)
+	assert.Contains(t, status.LastError, "health_probes")
+	assert.True(t, status.Healthy())
+
+	status = checker.RunCheck(ctx)
+	assert.Equal(t, database.HealthStateDown, status.State)
+	assert.Equal(t, http.StatusServiceUnavailable, status.HTTPStatus())
+	status = checker.RunCheck(ctx)
+	assert.Equal(t, database.HealthStateDown, status.State)
+	assert.Equal(t, 3, status.ConsecutiveFailures)
+
+	_, err := checker.Check(ctx)
+	assert.True(t, errors.Is(err, database.ErrDatabaseDown))
+
+	require.NoError(t, db.AutoMigrate(&healthProbe{}))
+	status = checker.RunCheck(ctx)
+	assert.Equal(t, database.HealthStateRecovered, status.State)
+	assert.Equal(t, 0, status.ConsecutiveFailures)
+	assert.Empty(t, status.LastError)
+	assert.Equal(t, http.StatusOK, status.HTTPStatus())
+
+	// Recovered until checks kept succeeding for the recovery time
+	assert.Equal(t, database.HealthStateRecovered, checker.RunCheck(ctx).State)
+	time.Sleep(40 * time.Millisecond)
+	assert.Equal(t, database.HealthStateHealthy, checker.RunCheck(ctx).State)
+
+	assert.Equal(t, []string{"healthy->degraded", "degraded->down", "down->recovered", "recovered->healthy"}, transitions)
+
+	// A single failure followed by a success does not go down
+	require.NoError(t, db.Migrator().DropTable(&healthProbe{}))
+	assert.Equal(t, database.HealthStateDegraded, checker.RunCheck(ctx).State)
+	require.NoError(t, db.AutoMigrate(&healthProbe{}))
+	assert.Equal(t, database.HealthStateHealthy, checker.RunCheck(ctx).State)
+}
+
+func TestHealthChecker_Background(t *testing.T) {
+	checker, db := healthTestChecker(t, 0)
+
+	// Check runs the first check itself
+	status, err := checker.Check(context.Background())
+	require.NoError(t, err)
+	assert.False(t, status.LastCheck.IsZero())
+
+	var mu sync.Mutex
+	var states []database.HealthState
+	checker.OnStateChange(func(tr database.HealthTransition) {
+		mu.Lock()
+		defer mu.Unlock()
+		states = append(states, tr.To)
+	})
+
+	require.NoError(t, checker.Start())
+	assert.Error(t, checker.Start())
+	defer checker.Stop()
+
+	require.NoError(t, db.Migrator().DropTable(&healthProbe{}))
+	assert.Eventually(t, func() bool {
+		return checker.Status().State == database.HealthStateDown
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, db.AutoMigrate(&healthProbe{}))
+	assert.Eventually(t, func() bool {
+		return checker.Status().State == database.HealthStateHealthy
+	}, time.Second, 5*time.Millisecond)
+
+	checker.Stop()
+	checker.Stop()
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []database.HealthState{
+		database.HealthStateDegraded, database.HealthStateDown, database.HealthStateRecovered, database.HealthStateHealthy,
+	}, states)
+}
+
+func TestHealthChecker_Disabled(t *testing.T) {
+	db := setupTestDB(t)
+	checker := database.NewHealthChecker("primary", db, config.HealthCheckConfig{})
+	assert.Error(t, checker.Start())
+
+	// The default query still serves on-demand checks
+	status, err := checker.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, database.HealthStateHealthy, status.State)
+}
@@ -0,0 +1,101 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// inspectedAccount is the model compared with drifted tables
+type inspectedAccount struct {
+	ID       uint   `gorm:"primaryKey"`
+	Email    string `gorm:"size:120;not null;index"`
+	Name     string
+	Age      int
+	Active   bool `gorm:"default:true"`
+	JoinedAt time.Time
+}
+
+// inspectedInvoice is a model whose table is never created
+type inspectedInvoice struct {
+	ID    uint `gorm:"primaryKey"`
+	Total int64
+}
+
+func TestSchemaInspector_MatchesMigratedModels(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&inspectedAccount{}, &TestEntity{}, &indexedOrder{}))
+
+	inspector, err := migrations.NewSchemaInspector(db, nil, &migrations.SchemaInspectorConfig{FailOnDrift: true, ReportExtraColumns: true})
+	require.NoError(t, err)
+	diff, err := inspector.Inspect(context.Background(), &inspectedAccount{}, &TestEntity{}, &indexedOrder{})
+	require.NoError(t, err)
+	assert.True(t, diff.Empty(), diff.String())
+}
+
+func TestSchemaInspector_ReportsDrift(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Exec(`CREATE TABLE inspected_accounts (
+		id integer PRIMARY KEY,
+		email varchar(80),
+		age text,
+		active numeric DEFAULT false,
+		joined_at datetime,
+		legacy text
+	)`).Error)
+	ctx := context.Background()
+
+	var output bytes.Buffer
+	logger := logging.NewLogger(logging.LogLevelDebug, &output, &logging.TextFormatter{})
+	inspector, err := migrations.NewSchemaInspector(db, logger, &migrations.SchemaInspectorConfig{ReportExtraColumns: true})
+	require.NoError(t, err)
+
+	diff, err := inspector.Inspect(ctx, &inspectedAccount{}, &inspectedInvoice{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"inspected_invoices"}, diff.MissingTables)
+	assert.Equal(t, []migrations.ColumnDrift{
+		// SQLite stores every string as text
+		{Table: "inspected_accounts", Column: "email", Problem: migrations.DriftType, Expected: "text", Actual: "varchar(80)"},
+		{Table: "inspected_accounts", Column: "email", Problem: migrations.DriftNullable, Expected: "NOT NULL", Actual: "NULL"},
+		{Table: "inspected_accounts", Column: "name", Problem: migrations.DriftMissing, Expected: "text"},
+		{Table: "inspected_accounts", Column: "age", Problem: migrations.DriftType, Expected: "integer", Actual: "text"},
+		{Table: "inspected_accounts", Column: "legacy", Problem: migrations.DriftExtra, Actual: "text"},
+	}, diff.Columns)
+	require.Len(t, diff.Indexes, 1)
+	assert.Equal(t, "idx_inspected_accounts_email", diff.Indexes[0].Index)
+	assert.Equal(t, migrations.DriftMissing, diff.Indexes[0].Problem)
+	require.Len(t, diff.Defaults, 1)
+	assert.Equal(t, "active", diff.Defaults[0].Column)
+	assert.Equal(t, 8, diff.Count())
+	assert.Contains(t, diff.String(), "inspected_invoices: missing table")
+	assert.Contains(t, diff.String(), "inspected_accounts.legacy: not declared by the model (actual text)")
+	assert.Equal(t, 8, strings.Count(output.String(), "Database schema drift detected"))
+
+	// Failing on drift returns the diff with the error
+	inspector, err = migrations.NewSchemaInspector(db, nil, &migrations.SchemaInspectorConfig{FailOnDrift: true})
+	require.NoError(t, err)
+	diff, err = inspector.Inspect(ctx, &inspectedAccount{})
+	require.Error(t, err)
+	assert.True(t, stderrors.Is(err, migrations.ErrSchemaDrift))
+	require.NotNil(t, diff)
+	assert.Len(t, diff.Columns, 4, "extra columns are not reported by default")
+	assert.Contains(t, err.Error(), "inspected_accounts.age: type differs")
+
+	// Migrating the model fixes the additive drift
+	require.NoError(t, db.AutoMigrate(&inspectedAccount{}))
+	diff, err = inspector.Inspect(ctx, &inspectedAccount{})
+	require.NoError(t, err)
+	assert.True(t, diff.Empty(), diff.String())
+}
@@ -0,0 +1,87 @@
+package unit
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingDescriber is a Describer that always fails
+type failingDescriber struct{}
+
+func (failingDescriber) Describe(ctx context.Context) (*repository.EntityInfo, error) {
+	return nil, stderrors.New("connection lost")
+}
+
+func TestRegistry_ListEntities(t *testing.T) {
+	repo, _ := setupTestRepository(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, repo.Create(ctx, &TestEntity{Name: name, Age: 1}))
+	}
+
+	registry := repository.NewRegistry()
+	repo.Register(registry)
+
+	entities, err := registry.ListEntities(ctx)
+	require.NoError(t, err)
+	require.Len(t, entities, 1)
+
+	info := entities[0]
+	assert.Equal(t, "TestEntity", info.Name)
+	assert.Equal(t, "test_entities", info.Table)
+	assert.Equal(t, "sqlite", info.Dialect)
+	assert.Equal(t, int64(3), info.EstimatedRows)
+	assert.True(t, info.ExactRowCount)
+	assert.True(t, info.Features.SoftDelete)
+	assert.True(t, info.Features.Validation)
+	assert.False(t, info.Features.ReadReplicas)
+
+	columns := map[string]repository.ColumnInfo{}
+	for _, column := range info.Columns {
+		columns[column.Name] = column
+	}
+	require.Contains(t, columns, "id")
+	assert.True(t, columns["id"].PrimaryKey)
+	require.Contains(t, columns, "name")
+	assert.False(t, columns["name"].Nullable)
+	assert.NotEmpty(t, columns["name"].DatabaseType)
+
+	var indexNames []string
+	for _, index := range info.Indexes {
+		indexNames = append(indexNames, index.Name)
+	}
+	assert.Contains(t, indexNames, "idx_test_entities_deleted_at")
+
+	// A failing entity does not hide the others
+	registry.Register("Broken", failingDescriber{})
+	entities, err = registry.ListEntities(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Broken")
+	require.Len(t, entities, 1)
+	assert.Equal(t, "TestEntity", entities[0].Name)
+
+	registry.Unregister("Broken")
+	_, err = registry.ListEntities(ctx)
+	assert.NoError(t, err)
+}
+
+func TestDialect_RowEstimator(t *testing.T) {
+	for _, name := range []string{"postgres", "cockroachdb", "mysql", "sqlserver", "oracle"} {
+		d, ok := dialect.Get(name)
+		require.True(t, ok, name)
+		estimator, ok := d.(dialect.RowEstimator)
+		require.True(t, ok, name)
+		assert.Contains(t, estimator.EstimateRows(), "?", name)
+	}
+
+	sqlite, _ := dialect.Get("sqlite")
+	_, ok := sqlite.(dialect.RowEstimator)
+	assert.False(t, ok)
+}
@@ -0,0 +1,393 @@
+package unit
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/jobs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestDBCheckpointStore(t *testing.T) {
+	store, err := jobs.NewDBCheckpointStore(setupTestDB(t))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	cp, err := store.Load(ctx, "backfill")
+	require.NoError(t, err)
+	assert.Nil(t, cp)
+
+	require.NoError(t, store.Save(ctx, &jobs.Checkpoint{Job: "backfill", LastKey: "k1", Processed: 10}))
+	require.NoError(t, store.Save(ctx, &jobs.Checkpoint{Job: "backfill", LastKey: "k2", Processed: 20}))
+
+	cp, err = store.Load(ctx, "backfill")
+	require.NoError(t, err)
+	require.NotNil(t, cp)
+	assert.Equal(t, "k2", cp.LastKey)
+	assert.Equal(t, int64(20), cp.Processed)
+
+	require.NoError(t, store.Delete(ctx, "backfill"))
+	cp, err = store.Load(ctx, "backfill")
+	require.NoError(t, err)
+	assert.Nil(t, cp)
+
+	_, err = jobs.NewDBCheckpointStore(nil)
+	assert.Error(t, err)
+}
+
+func TestBatchRunner_ResumesFromCheckpoint(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	ctx := context.Background()
+
+	for i := 0; i < 25; i++ {
+		require.NoError(t, repo.Create(ctx, &TestEntity{Name: fmt.Sprintf("user-%02d", i), Age: 1}))
+	}
+
+	store := jobs.NewMemoryCheckpointStore()
+	runner := jobs.NewBatchRunner(store, nil, &jobs.BatchConfig{BatchSize: 10})
+
+	failAfter := 2
+	batches := 0
+	fn := jobs.KeysetBatches(db, "name", func(e *TestEntity) string { return e.Name },
+		func(ctx context.Context, tx *gorm.DB, batch []TestEntity) error {
+			batches++
+			if batches > failAfter {
+				return stderrors.New("deploy in progress")
+			}
+			for _, e := range batch {
+				if err := tx.Model(&TestEntity{}).Where("id = ?", e.ID).Update("age", 2).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	cp, err := runner.Run(ctx, "bump-age", fn)
+	require.Error(t, err)
+	assert.Equal(t, "user-19", cp.LastKey)
+	assert.Equal(t, int64(20), cp.Processed)
+	assert.False(t, cp.Completed)
+
+	// Resume processes only the remaining rows
+	failAfter = 100
+	cp, err = runner.Run(ctx, "bump-age", fn)
+	require.NoError(t, err)
+	assert.True(t, cp.Completed)
+	assert.Equal(t, int64(25), cp.Processed)
+
+	count, err := repo.CountByConditions(ctx, "age = ?", 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(25), count)
+
+	// Completed jobs are not run again
+	calls := 0
+	_, err = runner.Run(ctx, "bump-age", func(ctx context.Context, afterKey string, limit int) (string, int, error) {
+		calls++
+		return "", 0, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, calls)
+
+	require.NoError(t, runner.Reset(ctx, "bump-age"))
+	cp, err = store.Load(ctx, "bump-age")
+	require.NoError(t, err)
+	assert.Nil(t, cp)
+}
+
+func TestBatchRunner_DeadlineSplitsBatches(t *testing.T) {
+	store := jobs.NewMemoryCheckpointStore()
+	runner := jobs.NewBatchRunner(store, nil, &jobs.BatchConfig{
+		BatchSize:    100,
+		MinBatchSize: 5,
+		SafetyMargin: 10 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+
+	var limits []int
+	next := 0
+	fn := func(ctx context.Context, afterKey string, limit int) (string, int, error) {
+		limits = append(limits, limit)
+		// Each item takes one millisecond
+		time.Sleep(time.Duration(limit) * time.Millisecond)
+		next += limit
+		return fmt.Sprintf("%06d", next), limit, nil
+	}
+
+	cp, err := runner.Run(ctx, "slow", fn)
+	require.Error(t, err)
+	assert.True(t, stderrors.Is(err, jobs.ErrJobInterrupted))
+	assert.False(t, cp.Completed)
+
+	// The first batch runs at full size, later ones shrink to fit the deadline
+	require.GreaterOrEqual(t, len(limits), 2)
+	assert.Equal(t, 100, limits[0])
+	assert.Less(t, limits[len(limits)-1], 100)
+
+	saved, err := store.Load(context.Background(), "slow")
+	require.NoError(t, err)
+	assert.Equal(t, cp.LastKey, saved.LastKey)
+	assert.Equal(t, int64(next), saved.Processed)
+}
+
+func TestBatchRunner_CancelledContext(t *testing.T) {
+	runner := jobs.NewBatchRunner(jobs.NewMemoryCheckpointStore(), nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := runner.Run(ctx, "cancelled", func(ctx context.Context, afterKey string, limit int) (string, int, error) {
+		t.Fatal("batch should not run with a cancelled context")
+		return "", 0, nil
+	})
+	assert.True(t, stderrors.Is(err, jobs.ErrJobInterrupted))
+	assert.True(t, stderrors.Is(err, context.Canceled))
+}
+
+func TestMaintenanceWindow_Contains(t *testing.T) {
+	// Saturday 2024-06-01
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 6, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	daytime := jobs.MaintenanceWindow{Start: 2 * time.Hour, End: 4 * time.Hour}
+	assert.True(t, daytime.Contains(at(3, 0)))
+	assert.False(t, daytime.Contains(at(4, 0)))
+	assert.False(t, daytime.Contains(at(1, 59)))
+
+	weekdays := jobs.MaintenanceWindow{Start: 2 * time.Hour, End: 4 * time.Hour, Days: []time.Weekday{time.Monday}}
+	assert.False(t, weekdays.Contains(at(3, 0)))
+
+	// Friday 22:00 to Saturday 02:00
+	overnight := jobs.MaintenanceWindow{Start: 22 * time.Hour, End: 2 * time.Hour, Days: []time.Weekday{time.Friday}}
+	assert.True(t, overnight.Contains(at(1, 30)))
+	assert.False(t, overnight.Contains(at(23, 0)))
+}
+
+func TestLoadGuard_Check(t *testing.T) {
+	stats := &jobs.LoadStats{ActiveConnections: 10, ActiveQueries: 5}
+	probe := func(ctx context.Context) (*jobs.LoadStats, error) { return stats, nil }
+
+	guard := jobs.NewLoadGuardWithProbe(probe, nil, &jobs.LoadGuardConfig{
+		MaxActiveQueries:  20,
+		MaxReplicationLag: time.Second,
+	})
+	ctx := context.Background()
+
+	allowed, _, err := guard.Check(ctx)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	stats.ReplicationLag = 5 * time.Second
+	allowed, reason, err := guard.Check(ctx)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Contains(t, reason, "replication lag")
+
+	stats.ReplicationLag = 0
+	stats.ActiveQueries = 25
+	allowed, reason, _ = guard.Check(ctx)
+	assert.False(t, allowed)
+	assert.Contains(t, reason, "active queries")
+
+	// A window that never opens keeps jobs paused regardless of load
+	closed := jobs.NewLoadGuardWithProbe(nil, nil, &jobs.LoadGuardConfig{
+		Windows: []jobs.MaintenanceWindow{{Start: time.Hour, End: time.Hour}},
+	})
+	allowed, reason, _ = closed.Check(ctx)
+	assert.False(t, allowed)
+	assert.Equal(t, "outside maintenance window", reason)
+
+	// The pool probe works on any driver
+	poolGuard := jobs.NewLoadGuard(setupTestDB(t), nil, nil)
+	allowed, _, err = poolGuard.Check(ctx)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
+
+func TestBatchRunner_PausesUnderLoad(t *testing.T) {
+	overloaded := true
+	probe := func(ctx context.Context) (*jobs.LoadStats, error) {
+		if overloaded {
+			return &jobs.LoadStats{ActiveQueries: 100}, nil
+		}
+		return &jobs.LoadStats{}, nil
+	}
+	guard := jobs.NewLoadGuardWithProbe(probe, nil, &jobs.LoadGuardConfig{
+		MaxActiveQueries: 10,
+		PollInterval:     5 * time.Millisecond,
+	})
+
+	runner := jobs.NewBatchRunner(jobs.NewMemoryCheckpointStore(), nil, &jobs.BatchConfig{BatchSize: 10}).WithLoadGuard(guard)
+
+	// While overloaded the job waits and is interrupted at its deadline without running a batch
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	batches := 0
+	fn := func(ctx context.Context, afterKey string, limit int) (string, int, error) {
+		batches++
+		return "done", 0, nil
+	}
+
+	_, err := runner.Run(ctx, "guarded", fn)
+	assert.True(t, stderrors.Is(err, jobs.ErrJobInterrupted))
+	assert.Equal(t, 0, batches)
+
+	overloaded = false
+	cp, err := runner.Run(context.Background(), "guarded", fn)
+	require.NoError(t, err)
+	assert.True(t, cp.Completed)
+	assert.Equal(t, 1, batches)
+}
+
+// setupBackfillRepository creates a repository of count entities aged 1,
+// on a single connection so concurrent batches share the in-memory database
+func setupBackfillRepository(t *testing.T, count int) *gorm.DB {
+	repo, db := setupTestRepository(t)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	for i := 0; i < count; i++ {
+		require.NoError(t, repo.Create(context.Background(), &TestEntity{Name: fmt.Sprintf("user-%02d", i), Age: 1}))
+	}
+	return db
+}
+
+// newAgeBackfill creates a backfill setting the age of entities aged 1 to 2
+func newAgeBackfill(db *gorm.DB, store jobs.CheckpointStore, config *jobs.BackfillConfig, fail func([]TestEntity) error) *jobs.Backfill[TestEntity] {
+	update := func(ctx context.Context, tx *gorm.DB, batch []TestEntity) error {
+		if fail != nil {
+			if err := fail(batch); err != nil {
+				return err
+			}
+		}
+		names := make([]string, len(batch))
+		for i, entity := range batch {
+			names[i] = entity.Name
+		}
+		return tx.Model(&TestEntity{}).Where("name IN ?", names).Update("age", 2).Error
+	}
+
+	return jobs.NewBackfill("age", db, store, nil, config, update).
+		WithScope(func(db *gorm.DB) *gorm.DB { return db.Where("age = ?", 1) }).
+		WithKey(func(e *TestEntity) string { return e.Name })
+}
+
+func TestBackfill_ResumesAfterFailure(t *testing.T) {
+	db := setupBackfillRepository(t, 25)
+	store := jobs.NewMemoryCheckpointStore()
+	config := &jobs.BackfillConfig{BatchSize: 10, Concurrency: 2, KeyColumn: "name", CountTotal: true}
+
+	var mu sync.Mutex
+	failed := false
+	backfill := newAgeBackfill(db, store, config, func(batch []TestEntity) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if batch[0].Name == "user-10" && !failed {
+			failed = true
+			return stderrors.New("deadlock detected")
+		}
+		return nil
+	})
+
+	// The second batch of the first round fails: the checkpoint stays after the first
+	cp, err := backfill.Run(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "deadlock detected")
+	assert.Equal(t, "user-09", cp.LastKey)
+	assert.Equal(t, int64(10), cp.Processed)
+	assert.False(t, cp.Completed)
+
+	progress := backfill.Progress()
+	assert.False(t, progress.Running)
+	assert.Equal(t, int64(1), progress.Batches)
+	assert.Equal(t, int64(1), progress.Failed)
+	assert.Equal(t, int64(25), progress.Total)
+	assert.InDelta(t, 0.4, progress.Ratio(), 0.001)
+
+	// Resuming backfills the rest
+	cp, err = backfill.Run(context.Background())
+	require.NoError(t, err)
+	assert.True(t, cp.Completed)
+	assert.Equal(t, "user-24", cp.LastKey)
+	assert.Equal(t, int64(25), cp.Processed)
+
+	var remaining int64
+	require.NoError(t, db.Model(&TestEntity{}).Where("age = ?", 1).Count(&remaining).Error)
+	assert.Equal(t, int64(0), remaining)
+
+	progress = backfill.Progress()
+	assert.True(t, progress.Completed)
+	assert.Equal(t, int64(2), progress.Batches)
+	assert.Equal(t, 1.0, progress.Ratio())
+
+	// A completed backfill does not run again until reset
+	cp, err = backfill.Run(context.Background())
+	require.NoError(t, err)
+	assert.True(t, cp.Completed)
+
+	require.NoError(t, backfill.Reset(context.Background()))
+	cp, err = backfill.Run(context.Background())
+	require.NoError(t, err)
+	assert.True(t, cp.Completed)
+	assert.Equal(t, int64(0), cp.Processed)
+}
+
+func TestBackfill_RateLimit(t *testing.T) {
+	db := setupBackfillRepository(t, 20)
+	backfill := newAgeBackfill(db, jobs.NewMemoryCheckpointStore(),
+		&jobs.BackfillConfig{BatchSize: 5, Concurrency: 2, KeyColumn: "name", RowsPerSecond: 200}, nil)
+
+	// 20 rows at 200 rows per second: the last batch of 5 starts 75ms after the first
+	start := time.Now()
+	cp, err := backfill.Run(context.Background())
+	require.NoError(t, err)
+	assert.True(t, cp.Completed)
+	assert.Equal(t, int64(20), cp.Processed)
+	assert.GreaterOrEqual(t, time.Since(start), 75*time.Millisecond)
+	assert.Greater(t, backfill.Progress().RowsPerSecond, 0.0)
+}
+
+func TestBackfill_PauseResume(t *testing.T) {
+	db := setupBackfillRepository(t, 10)
+	store := jobs.NewMemoryCheckpointStore()
+	backfill := newAgeBackfill(db, store, &jobs.BackfillConfig{BatchSize: 5, KeyColumn: "name"}, nil)
+
+	// A paused backfill does not start batches and is interrupted by its context
+	backfill.Pause()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	cp, err := backfill.Run(ctx)
+	assert.True(t, stderrors.Is(err, jobs.ErrJobInterrupted))
+	assert.Equal(t, int64(0), cp.Processed)
+	assert.True(t, backfill.Progress().Paused)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := backfill.Run(context.Background())
+		done <- err
+	}()
+
+	require.Eventually(t, func() bool { return backfill.Progress().Running }, time.Second, time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("paused backfill returned: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	backfill.Resume()
+	require.NoError(t, <-done)
+	progress := backfill.Progress()
+	assert.False(t, progress.Paused)
+	assert.True(t, progress.Completed)
+	assert.Equal(t, int64(10), progress.Processed)
+}
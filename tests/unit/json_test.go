@@ -0,0 +1,148 @@
+package unit
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// jsonProduct has a JSON attributes column
+type jsonProduct struct {
+	models.BaseModel
+	Name       string
+	Attributes models.JSONMap
+}
+
+func TestJSONMap(t *testing.T) {
+	value, err := models.JSONMap{"color": "red", "size": 42}.Value()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"color":"red","size":42}`, value.(string))
+
+	value, err = models.JSONMap(nil).Value()
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	var m models.JSONMap
+	require.NoError(t, m.Scan([]byte(`{"tags":["a","b"]}`)))
+	assert.Equal(t, models.JSONMap{"tags": []interface{}{"a", "b"}}, m)
+	require.NoError(t, m.Scan(nil))
+	assert.Nil(t, m)
+	assert.Error(t, m.Scan(`[1, 2]`))
+	assert.Error(t, m.Scan(42))
+}
+
+func TestJSONConditions(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&jsonProduct{}))
+	logger := logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{})
+	repos := map[string]repository.Repository[jsonProduct]{
+		"base":   repository.NewBaseRepository[jsonProduct](db, logger, repository.DefaultRepositoryConfig()),
+		"memory": repository.NewMemoryRepository[jsonProduct](repository.DefaultRepositoryConfig()),
+	}
+	ctx := context.Background()
+
+	for kind, repo := range repos {
+		t.Run(kind, func(t *testing.T) {
+			for _, product := range []jsonProduct{
+				{Name: "shirt", Attributes: models.JSONMap{"color": "red", "size": map[string]interface{}{"eu": 42}, "tags": []interface{}{"sale", "cotton"}}},
+				{Name: "shoe", Attributes: models.JSONMap{"color": "blue", "size": map[string]interface{}{"eu": 44}, "tags": []interface{}{"leather"}, "discontinued": nil}},
+				{Name: "hat", Attributes: models.JSONMap{"color": "red", "tags": "sale"}},
+				{Name: "bare"},
+			} {
+				require.NoError(t, repo.Create(ctx, &product))
+			}
+
+			for name, cond := range map[string]repository.Cond{
+				"contains object":     repository.JSONContains("attributes", map[string]interface{}{"color": "red"}),
+				"contains nested":     repository.JSONContains("attributes", map[string]interface{}{"size": map[string]interface{}{"eu": 44}}),
+				"contains array":      repository.JSONContains("attributes", map[string]interface{}{"tags": []string{"sale"}}),
+				"contains null":       repository.JSONContains("attributes", map[string]interface{}{"discontinued": nil}),
+				"extract string":      repository.JSONExtractEq("attributes", "color", "blue"),
+				"extract number":      repository.JSONExtractEq("attributes", "size.eu", 42),
+				"extract array":       repository.JSONExtractEq("attributes", "tags", []string{"leather"}),
+				"extract index":       repository.JSONExtractEq("attributes", "tags.1", "cotton"),
+				"key exists":          repository.JSONKeyExists("attributes", "size.eu"),
+				"null key exists":     repository.JSONKeyExists("attributes", "discontinued"),
+				"combined with typed": repository.And(repository.JSONKeyExists("attributes", "tags"), repository.Neq("name", "hat")),
+			} {
+				var found []jsonProduct
+				require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &found, cond), name)
+				var names []string
+				for _, product := range found {
+					names = append(names, product.Name)
+				}
+				expected := map[string][]string{
+					"contains object":     {"shirt", "hat"},
+					"contains nested":     {"shoe"},
+					"contains array":      {"shirt"},
+					"contains null":       {"shoe"},
+					"extract string":      {"shoe"},
+					"extract number":      {"shirt"},
+					"extract array":       {"shoe"},
+					"extract index":       {"shirt"},
+					"key exists":          {"shirt", "shoe"},
+					"null key exists":     {"shoe"},
+					"combined with typed": {"shirt", "shoe"},
+				}[name]
+				assert.ElementsMatch(t, expected, names, name)
+			}
+
+			// Paths are keys and indexes only
+			_, err := repo.CountByConditions(ctx, repository.JSONKeyExists("attributes", "size') OR 1=1 --"))
+			require.Error(t, err)
+			assert.True(t, errors.IsType(err, errors.ErrorTypeValidation))
+		})
+	}
+}
+
+func TestJSONConditions_Drivers(t *testing.T) {
+	cond := repository.And(
+		repository.JSONContains("attributes", map[string]interface{}{"color": "red"}),
+		repository.JSONExtractEq("attributes", "size.eu", 42),
+		repository.JSONKeyExists("attributes", "tags.0"),
+	)
+
+	for name, dialector := range map[string]gorm.Dialector{
+		"postgres": postgres.New(postgres.Config{DSN: "host=localhost"}),
+		"mysql":    mysql.New(mysql.Config{DSN: "user@tcp(localhost)/db", SkipInitializeWithVersion: true}),
+	} {
+		db, err := gorm.Open(dialector, &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+		require.NoError(t, err, name)
+		stmt := db.Model(&jsonProduct{}).Where(cond).Find(&[]jsonProduct{}).Statement
+		require.NoError(t, stmt.Error, name)
+
+		switch name {
+		case "postgres":
+			assert.Contains(t, stmt.SQL.String(), `("json_products"."attributes" @> CAST($1 AS jsonb) AND "json_products"."attributes" #> CAST($2 AS text[]) = CAST($3 AS jsonb) AND "json_products"."attributes" #> CAST($4 AS text[]) IS NOT NULL)`)
+			assert.Equal(t, []interface{}{`{"color":"red"}`, "{size,eu}", "42", "{tags,0}"}, stmt.Vars)
+		case "mysql":
+			assert.Contains(t, stmt.SQL.String(), "(JSON_CONTAINS(`json_products`.`attributes`, ?) AND JSON_EXTRACT(`json_products`.`attributes`, ?) = CAST(? AS JSON) AND JSON_CONTAINS_PATH(`json_products`.`attributes`, 'one', ?))")
+			assert.Equal(t, []interface{}{`{"color":"red"}`, "$.size.eu", "42", "$.tags[0]"}, stmt.Vars)
+		}
+	}
+
+	// Other drivers say they are unsupported
+	db := setupTestDB(t)
+	db.Dialector = unsupportedDialector{db.Dialector}
+	err := db.Session(&gorm.Session{DryRun: true}).Model(&TestEntity{}).Where(repository.JSONKeyExists("name", "a")).Find(&[]TestEntity{}).Error
+	assert.True(t, stderrors.Is(err, stderrors.ErrUnsupported))
+}
+
+// unsupportedDialector renames a dialector to a driver without JSON conditions
+type unsupportedDialector struct {
+	gorm.Dialector
+}
+
+func (unsupportedDialector) Name() string { return "sqlserver" }
@@ -0,0 +1,172 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// counterEntity is keyed by an auto-incremented integer
+type counterEntity struct {
+	models.BaseModelInt64
+	Name string
+}
+
+// ulidEntity is keyed by a ULID
+type ulidEntity struct {
+	models.BaseModelULID
+	Name string
+}
+
+func TestULID(t *testing.T) {
+	first := models.NewULID()
+	time.Sleep(2 * time.Millisecond)
+	second := models.NewULID()
+
+	assert.Len(t, first.String(), 26)
+	assert.Less(t, first.String(), second.String())
+	assert.WithinDuration(t, time.Now(), first.Time(), time.Second)
+	assert.False(t, first.IsZero())
+	assert.True(t, models.ULID{}.IsZero())
+
+	parsed, err := models.ParseULID(first.String())
+	require.NoError(t, err)
+	assert.Equal(t, first, parsed)
+	parsed, err = models.ParseULID(strings.ToLower(second.String()))
+	require.NoError(t, err)
+	assert.Equal(t, second, parsed)
+
+	known, err := models.ParseULID("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	require.NoError(t, err)
+	assert.Equal(t, "01ARZ3NDEKTSV4RRFFQ69G5FAV", known.String())
+	assert.Equal(t, int64(1469922850259), known.Time().UnixMilli())
+	assert.Equal(t, "7ZZZZZZZZZZZZZZZZZZZZZZZZZ", models.ULID{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}.String())
+
+	for _, invalid := range []string{"", "01ARZ3NDEK", "81ARZ3NDEKTSV4RRFFQ69G5FAV", "01ARZ3NDEKTSV4RRFFQ69G5FAU"} {
+		_, err := models.ParseULID(invalid)
+		assert.Error(t, err, invalid)
+	}
+
+	var scanned models.ULID
+	require.NoError(t, scanned.Scan(first.String()))
+	assert.Equal(t, first, scanned)
+	value, err := scanned.Value()
+	require.NoError(t, err)
+	assert.Equal(t, first.String(), value)
+	require.NoError(t, scanned.Scan(nil))
+	assert.True(t, scanned.IsZero())
+
+	data, err := json.Marshal(map[string]models.ULID{"id": known})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"01ARZ3NDEKTSV4RRFFQ69G5FAV"}`, string(data))
+	var decoded map[string]models.ULID
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, known, decoded["id"])
+}
+
+func TestEntityKeys_Int64(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&counterEntity{}))
+	logger := logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{})
+
+	var changes []*repository.EntityChange[counterEntity]
+	base := repository.NewBaseRepository[counterEntity](db, logger, repository.DefaultRepositoryConfig()).
+		OnChange(func(ctx context.Context, change *repository.EntityChange[counterEntity]) error {
+			changes = append(changes, change)
+			return nil
+		})
+	repos := map[string]repository.Repository[counterEntity]{
+		"base":   base,
+		"memory": repository.NewMemoryRepository[counterEntity](repository.DefaultRepositoryConfig()),
+	}
+	ctx := context.Background()
+
+	for kind, repo := range repos {
+		t.Run(kind, func(t *testing.T) {
+			first := counterEntity{Name: "first"}
+			second := counterEntity{Name: "second"}
+			require.NoError(t, repo.Create(ctx, &first))
+			require.NoError(t, repo.Create(ctx, &second))
+			assert.Equal(t, int64(1), first.ID)
+			assert.Equal(t, int64(2), second.ID)
+
+			found, err := repo.FindFirstByID(ctx, second.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "second", found.Name)
+			found, err = repo.FindFirstByID(ctx, 1)
+			require.NoError(t, err)
+			assert.Equal(t, "first", found.Name)
+			found, err = repo.FindFirstByID(ctx, "2")
+			require.NoError(t, err)
+			assert.Equal(t, "second", found.Name)
+
+			second.Name = "renamed"
+			require.NoError(t, repo.UpdateByID(ctx, &second, 2))
+			exists, err := repo.ExistsByID(ctx, int32(2))
+			require.NoError(t, err)
+			assert.True(t, exists)
+
+			assert.Error(t, repo.DeleteByID(ctx, 0))
+			require.NoError(t, repo.DeleteByID(ctx, first.ID))
+			exists, err = repo.ExistsByID(ctx, first.ID)
+			require.NoError(t, err)
+			assert.False(t, exists)
+
+			var all []counterEntity
+			require.NoError(t, repo.FindAllWithOffset(ctx, 10, 0, &all))
+			require.Len(t, all, 1)
+			assert.Equal(t, "renamed", all[0].Name)
+		})
+	}
+
+	require.NotEmpty(t, changes)
+	assert.Equal(t, int64(1), changes[0].Key)
+	assert.Equal(t, uuid.Nil, changes[0].ID)
+}
+
+func TestEntityKeys_ULID(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&ulidEntity{}))
+	logger := logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{})
+	repos := map[string]repository.Repository[ulidEntity]{
+		"base":   repository.NewBaseRepository[ulidEntity](db, logger, repository.DefaultRepositoryConfig()),
+		"memory": repository.NewMemoryRepository[ulidEntity](repository.DefaultRepositoryConfig()),
+	}
+	ctx := context.Background()
+
+	for kind, repo := range repos {
+		t.Run(kind, func(t *testing.T) {
+			entity := ulidEntity{Name: "sortable"}
+			require.NoError(t, repo.Create(ctx, &entity))
+			assert.False(t, entity.ID.IsZero())
+
+			found, err := repo.FindFirstByID(ctx, entity.ID)
+			require.NoError(t, err)
+			assert.Equal(t, entity.ID, found.ID)
+			found, err = repo.FindFirstByID(ctx, entity.ID.String())
+			require.NoError(t, err)
+			assert.Equal(t, "sortable", found.Name)
+
+			_, err = repo.FindFirstByID(ctx, models.NewULID())
+			assert.Error(t, err)
+
+			require.NoError(t, repo.DeleteByID(ctx, entity.ID))
+			exists, err := repo.ExistsByID(ctx, entity.ID)
+			require.NoError(t, err)
+			assert.False(t, exists)
+		})
+	}
+}
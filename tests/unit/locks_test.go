@@ -0,0 +1,119 @@
+package unit
+
+import (
+	"context"
+	stderrors "errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/locks"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupLockers(t *testing.T) (*locks.Locker, *locks.Locker, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "locks.db")), &gorm.Config{})
+	require.NoError(t, err)
+
+	logger := logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{})
+	config := func(owner string) *locks.LockerConfig {
+		return &locks.LockerConfig{Owner: owner, TTL: time.Minute, RetryInterval: 10 * time.Millisecond}
+	}
+	first, err := locks.NewLocker(db, logger, config("first"))
+	require.NoError(t, err)
+	second, err := locks.NewLocker(db, logger, config("second"))
+	require.NoError(t, err)
+	return first, second, db
+}
+
+func TestLocker_TableLocks(t *testing.T) {
+	first, second, db := setupLockers(t)
+	ctx := context.Background()
+
+	lock, err := first.TryAcquire(ctx, "reports", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "reports", lock.Key())
+
+	_, err = second.TryAcquire(ctx, "reports", time.Minute)
+	assert.ErrorIs(t, err, locks.ErrNotAcquired)
+
+	// Other keys are independent
+	other, err := second.TryAcquire(ctx, "invoices", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, other.Release(ctx))
+
+	// Waiting gives up with the context
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = second.AcquireAdvisoryLock(waitCtx, "reports", time.Minute)
+	assert.ErrorIs(t, err, locks.ErrNotAcquired)
+
+	require.NoError(t, lock.Refresh(ctx))
+	require.NoError(t, lock.Release(ctx))
+	require.NoError(t, lock.Release(ctx), "releasing twice is a no-op")
+
+	lock, err = second.AcquireAdvisoryLock(ctx, "reports", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, lock.Release(ctx))
+
+	var count int64
+	require.NoError(t, db.Model(&locks.LockRecord{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
+
+func TestLocker_ExpiredLease(t *testing.T) {
+	first, second, db := setupLockers(t)
+	ctx := context.Background()
+
+	lock, err := first.TryAcquire(ctx, "reports", time.Minute)
+	require.NoError(t, err)
+
+	// Expire the lease, as if its owner had crashed
+	require.NoError(t, db.Model(&locks.LockRecord{}).Where("lock_key = ?", "reports").
+		Update("expires_at", time.Now().UTC().Add(-time.Second)).Error)
+
+	takeover, err := second.TryAcquire(ctx, "reports", time.Minute)
+	require.NoError(t, err)
+
+	var record locks.LockRecord
+	require.NoError(t, db.First(&record, "lock_key = ?", "reports").Error)
+	assert.Equal(t, "second", record.Owner)
+
+	assert.ErrorIs(t, lock.Refresh(ctx), locks.ErrLockLost)
+	assert.ErrorIs(t, lock.Release(ctx), locks.ErrLockLost)
+
+	// The stale release left the new owner's lease alone
+	_, err = first.TryAcquire(ctx, "reports", time.Minute)
+	assert.ErrorIs(t, err, locks.ErrNotAcquired)
+	require.NoError(t, takeover.Release(ctx))
+}
+
+func TestLocker_WithLock(t *testing.T) {
+	first, second, _ := setupLockers(t)
+	ctx := context.Background()
+
+	ran := false
+	err := first.WithLock(ctx, "reports", func(ctx context.Context) error {
+		ran = true
+		_, err := second.TryAcquire(ctx, "reports", time.Minute)
+		assert.ErrorIs(t, err, locks.ErrNotAcquired)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, ran)
+
+	// The lock is released after fn, also when it fails
+	failure := stderrors.New("report failed")
+	err = second.WithLock(ctx, "reports", func(ctx context.Context) error {
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+
+	lock, err := first.TryAcquire(ctx, "reports", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, lock.Release(ctx))
+}
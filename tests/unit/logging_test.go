@@ -2,14 +2,24 @@ package unit
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/seasbee/go-ormx/pkg/logging"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLogLevel_String(t *testing.T) {
@@ -204,7 +214,7 @@ func TestJSONFormatter_Format(t *testing.T) {
 	assert.Contains(t, jsonStr, `"level":"info"`)
 	assert.Contains(t, jsonStr, `"message":"test message"`)
 	assert.Contains(t, jsonStr, `"key":"value"`)
-	assert.Contains(t, jsonStr, `"number":"42"`)
+	assert.Contains(t, jsonStr, `"number":42`)
 }
 
 func TestTextFormatter_Format(t *testing.T) {
@@ -429,6 +439,67 @@ func TestContextFieldsExtraction(t *testing.T) {
 	assert.Contains(t, logOutput, "tenant_id=tenant-def")
 }
 
+type sessionContextKey struct{}
+
+func TestTypedContextFields(t *testing.T) {
+	ctx := logging.ContextWithRequestID(context.Background(), "req-123")
+	ctx = logging.ContextWithTraceID(ctx, "trace-456")
+	ctx = logging.ContextWithSpanID(ctx, "span-789")
+	ctx = logging.ContextWithUserID(ctx, "user-abc")
+	ctx = logging.ContextWithTenantID(ctx, "tenant-def")
+	// The typed key wins over the legacy string key
+	ctx = context.WithValue(ctx, "request_id", "legacy")
+
+	requestID, ok := logging.RequestIDFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "req-123", requestID)
+	tenantID, ok := logging.TenantIDFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "tenant-def", tenantID)
+	_, ok = logging.UserIDFromContext(context.Background())
+	assert.False(t, ok)
+
+	buf := &bytes.Buffer{}
+	logger := logging.NewLogger(logging.LogLevelInfo, buf, &logging.TextFormatter{})
+	logger.Info(ctx, "test message")
+
+	assert.Contains(t, buf.String(), "test message | request_id=req-123, trace_id=trace-456, span_id=span-789, user_id=user-abc, tenant_id=tenant-def\n")
+}
+
+func TestContextFieldExtractor(t *testing.T) {
+	logging.RegisterContextFieldExtractor("session", func(ctx context.Context) []logging.LogField {
+		if session, ok := ctx.Value(sessionContextKey{}).(string); ok {
+			return []logging.LogField{logging.String("session_id", session)}
+		}
+		return nil
+	})
+	defer logging.UnregisterContextFieldExtractor("session")
+	logging.RegisterContextFieldExtractor("region", func(ctx context.Context) []logging.LogField {
+		return []logging.LogField{logging.String("region", "eu")}
+	})
+	defer logging.UnregisterContextFieldExtractor("region")
+
+	buf := &bytes.Buffer{}
+	logger := logging.NewLogger(logging.LogLevelInfo, buf, &logging.TextFormatter{})
+
+	ctx := context.WithValue(logging.ContextWithRequestID(context.Background(), "req-1"), sessionContextKey{}, "s-42")
+	logger.Info(ctx, "with session", logging.Int("n", 1))
+	assert.Contains(t, buf.String(), "with session | request_id=req-1, session_id=s-42, region=eu, n=1\n")
+
+	buf.Reset()
+	logger.Info(context.Background(), "without session")
+	assert.Contains(t, buf.String(), "without session | region=eu\n")
+
+	// Registering under the same name replaces the extractor
+	logging.RegisterContextFieldExtractor("region", func(ctx context.Context) []logging.LogField {
+		return []logging.LogField{logging.String("region", "us")}
+	})
+	logging.UnregisterContextFieldExtractor("session")
+	buf.Reset()
+	logger.Info(ctx, "replaced")
+	assert.Contains(t, buf.String(), "replaced | request_id=req-1, region=us\n")
+}
+
 func TestNilContextHandling(t *testing.T) {
 	buf := &bytes.Buffer{}
 	logger := logging.NewLogger(logging.LogLevelInfo, buf, &logging.TextFormatter{})
@@ -646,7 +717,9 @@ func TestJSONFormatter_EdgeCases(t *testing.T) {
 	}
 	result, err = formatter.Format(entryWithSpecialMessage)
 	assert.NoError(t, err)
-	assert.Contains(t, string(result), specialMessage)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(result, &decoded))
+	assert.Equal(t, specialMessage, decoded["message"])
 }
 
 func TestTextFormatter_EdgeCases(t *testing.T) {
@@ -952,3 +1025,464 @@ func TestContextFields_EdgeCases(t *testing.T) {
 	logOutput = buf.String()
 	assert.Contains(t, logOutput, "test message") // Context values are not automatically extracted
 }
+
+// gatedWriter holds its writes until released
+type gatedWriter struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	ready chan struct{}
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	<-w.ready
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *gatedWriter) lines() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return strings.Count(w.buf.String(), "\n")
+}
+
+func TestBaseLogger_ConcurrentWrites(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.NewLogger(logging.LogLevelInfo, &buf, &logging.TextFormatter{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			derived := logger.WithFields(logging.Int("worker", i))
+			for j := 0; j < 50; j++ {
+				derived.Info(context.Background(), "entry")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1000, strings.Count(buf.String(), "\n"))
+}
+
+func TestAsyncLogger(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("flush and close", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := logging.NewAsyncLogger(logging.LogLevelInfo, &buf, &logging.TextFormatter{}, &logging.AsyncConfig{FlushInterval: time.Hour})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					logger.WithFields(logging.Int("j", j)).Info(ctx, "buffered")
+				}
+			}()
+		}
+		wg.Wait()
+		require.NoError(t, logger.Flush())
+		assert.Equal(t, 100, strings.Count(buf.String(), "buffered"))
+
+		logger.Info(ctx, "last")
+		require.NoError(t, logger.Close())
+		assert.Contains(t, buf.String(), "last")
+
+		// Entries logged after Close are written synchronously
+		logger.Info(ctx, "after close")
+		assert.Contains(t, buf.String(), "after close")
+		assert.Zero(t, logger.Dropped())
+	})
+
+	t.Run("drop when full", func(t *testing.T) {
+		output := &gatedWriter{ready: make(chan struct{})}
+		logger := logging.NewAsyncLogger(logging.LogLevelInfo, output, nil, &logging.AsyncConfig{BufferSize: 2, Overflow: logging.OverflowDrop})
+
+		for i := 0; i < 10; i++ {
+			logger.Info(ctx, "entry")
+		}
+		assert.Positive(t, logger.Dropped())
+
+		close(output.ready)
+		require.NoError(t, logger.Close())
+		assert.Equal(t, int64(10), int64(output.lines())+logger.Dropped())
+	})
+
+	t.Run("block when full", func(t *testing.T) {
+		output := &gatedWriter{ready: make(chan struct{})}
+		logger := logging.NewAsyncLogger(logging.LogLevelInfo, output, nil, &logging.AsyncConfig{BufferSize: 2, Overflow: logging.OverflowBlock})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 10; i++ {
+				logger.Info(ctx, "entry")
+			}
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("logging should block while the buffer is full")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(output.ready)
+		<-done
+		require.NoError(t, logger.Close())
+		assert.Equal(t, 10, output.lines())
+		assert.Zero(t, logger.Dropped())
+	})
+}
+
+func TestSampledLogger(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	base := logging.NewLogger(logging.LogLevelInfo, &buf, &logging.TextFormatter{})
+	logger := logging.NewSampledLogger(base, &logging.SamplingConfig{
+		PerSecond:      3,
+		KeyFields:      []string{"table"},
+		ReportInterval: time.Hour,
+	})
+
+	for i := 0; i < 10; i++ {
+		logger.Info(ctx, "Entity created successfully", logging.String("table", "users"), logging.Int("i", i))
+	}
+	orders := logger.WithFields(logging.String("table", "orders"))
+	for i := 0; i < 5; i++ {
+		orders.Info(ctx, "Entity created successfully")
+	}
+	for i := 0; i < 5; i++ {
+		logger.Error(ctx, "Failed to create entity", logging.String("table", "users"))
+		logger.Debug(ctx, "Below the level")
+	}
+
+	logged := buf.String()
+	assert.Equal(t, 3, strings.Count(logged, "Entity created successfully | table=users"))
+	assert.Equal(t, 3, strings.Count(logged, "Entity created successfully | table=orders"))
+	assert.Equal(t, 5, strings.Count(logged, "Failed to create entity"))
+	assert.NotContains(t, logged, "suppressed")
+
+	require.NoError(t, logger.Close())
+	logged = buf.String()
+	assert.Contains(t, logged, "Log entries suppressed | message=Entity created successfully, table=orders, suppressed=2")
+	assert.Contains(t, logged, "Log entries suppressed | message=Entity created successfully, table=users, suppressed=7")
+}
+
+func TestSampledLogger_PeriodicReports(t *testing.T) {
+	ctx := context.Background()
+	output := &gatedWriter{ready: make(chan struct{})}
+	close(output.ready)
+	logger := logging.NewSampledLogger(logging.NewLogger(logging.LogLevelInfo, output, nil), &logging.SamplingConfig{
+		PerSecond:      1,
+		ReportInterval: 20 * time.Millisecond,
+		SampleErrors:   true,
+	})
+	defer logger.Close()
+
+	for i := 0; i < 4; i++ {
+		logger.Error(ctx, "Connection lost")
+	}
+	assert.Eventually(t, func() bool {
+		output.mu.Lock()
+		defer output.mu.Unlock()
+		return strings.Contains(output.buf.String(), "error: Log entries suppressed | message=Connection lost, suppressed=3")
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2, output.lines())
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs", "orm.log")
+	file, err := logging.NewRotatingFile(path, logging.RotationConfig{MaxSize: 20, MaxBackups: 2})
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		_, err := fmt.Fprintf(file, "entry %d of the log\n", i)
+		require.NoError(t, err)
+	}
+	backups, err := file.Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "entry 4 of the log\n", string(content))
+	content, err = os.ReadFile(backups[1])
+	require.NoError(t, err)
+	assert.Equal(t, "entry 3 of the log\n", string(content))
+	content, err = os.ReadFile(backups[0])
+	require.NoError(t, err)
+	assert.Equal(t, "entry 2 of the log\n", string(content))
+
+	require.NoError(t, file.Close())
+	_, err = file.Write([]byte("closed\n"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
+
+func TestRotatingFile_AgeAndCompression(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orm.log")
+	file, err := logging.NewRotatingFile(path, logging.RotationConfig{MaxAge: 20 * time.Millisecond, Compress: true})
+	require.NoError(t, err)
+	defer file.Close()
+
+	_, err = file.Write([]byte("first\n"))
+	require.NoError(t, err)
+	time.Sleep(30 * time.Millisecond)
+	_, err = file.Write([]byte("second\n"))
+	require.NoError(t, err)
+
+	backups, err := file.Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.True(t, strings.HasSuffix(backups[0], ".log.gz"))
+
+	compressed, err := os.Open(backups[0])
+	require.NoError(t, err)
+	defer compressed.Close()
+	reader, err := gzip.NewReader(compressed)
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "first\n", string(content))
+
+	require.NoError(t, file.Rotate())
+	backups, err = file.Backups()
+	require.NoError(t, err)
+	assert.Len(t, backups, 2)
+}
+
+func TestNewLoggerFromConfig_Outputs(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("rotated file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "orm.log")
+		logger, err := logging.NewLoggerFromConfig(logging.LoggerConfig{
+			Level:    logging.LogLevelInfo,
+			Format:   "text",
+			Output:   path,
+			Rotation: &logging.RotationConfig{MaxSize: 1024, MaxBackups: 3},
+		})
+		require.NoError(t, err)
+		for i := 0; i < 50; i++ {
+			logger.Info(ctx, "Entity created successfully", logging.Int("i", i))
+		}
+		require.NoError(t, logger.Close())
+
+		matches, err := filepath.Glob(filepath.Join(filepath.Dir(path), "orm-*.log"))
+		require.NoError(t, err)
+		assert.Len(t, matches, 3)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "i=49")
+	})
+
+	t.Run("syslog", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer conn.Close()
+
+		logger, err := logging.NewLoggerFromConfig(logging.LoggerConfig{
+			Level:     logging.LogLevelInfo,
+			Output:    "syslog://" + conn.LocalAddr().String(),
+			SyslogTag: "orders",
+		})
+		require.NoError(t, err)
+		defer logger.Close()
+
+		logger.Error(ctx, "Failed to create entity", logging.String("table", "orders"))
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		packet := make([]byte, 1024)
+		n, _, err := conn.ReadFrom(packet)
+		require.NoError(t, err)
+
+		message := string(packet[:n])
+		// LOG_USER facility, err severity
+		assert.True(t, strings.HasPrefix(message, "<11>"), message)
+		assert.Contains(t, message, "orders")
+		assert.Contains(t, message, "Failed to create entity | table=orders")
+	})
+
+	t.Run("unreachable syslog", func(t *testing.T) {
+		logger, err := logging.NewLoggerFromConfig(logging.LoggerConfig{Output: "syslog+tcp://127.0.0.1:1"})
+		assert.Error(t, err)
+		assert.Nil(t, logger)
+	})
+}
+
+type jsonFieldPayload struct {
+	ID   int      `json:"id"`
+	Tags []string `json:"tags"`
+}
+
+func TestJSONFormatter_TypedValues(t *testing.T) {
+	formatter := &logging.JSONFormatter{}
+	entry := logging.LogEntry{
+		Level:   logging.LogLevelWarn,
+		Message: "typed <values>",
+		Time:    time.Date(2020, 1, 1, 12, 0, 0, 123456789, time.UTC),
+		Caller:  "repository/base.go:42",
+		Fields: []logging.LogField{
+			logging.Int("number", 42),
+			logging.Int64("rows", 7),
+			logging.Float64("ratio", 0.5),
+			logging.Bool("ok", true),
+			logging.Duration("duration", 1500*time.Millisecond),
+			logging.ErrorField("error", fmt.Errorf("boom")),
+			logging.Any("payload", jsonFieldPayload{ID: 1, Tags: []string{"a", "b"}}),
+			logging.Any("counts", map[string]int{"x": 1}),
+			logging.Any("nothing", nil),
+			logging.Any("func", func() {}),
+			logging.String("number", "overridden"),
+		},
+	}
+
+	result, err := formatter.Format(entry)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(result), "}\n"))
+	assert.Contains(t, string(result), `"message":"typed <values>"`)
+
+	var decoded struct {
+		Level   string                 `json:"level"`
+		Message string                 `json:"message"`
+		Time    string                 `json:"time"`
+		Caller  string                 `json:"caller"`
+		Fields  map[string]interface{} `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal(result, &decoded))
+	assert.Equal(t, "warn", decoded.Level)
+	assert.Equal(t, "2020-01-01T12:00:00.123456789Z", decoded.Time)
+	assert.Equal(t, "repository/base.go:42", decoded.Caller)
+	assert.Equal(t, "overridden", decoded.Fields["number"])
+	assert.Equal(t, float64(7), decoded.Fields["rows"])
+	assert.Equal(t, 0.5, decoded.Fields["ratio"])
+	assert.Equal(t, true, decoded.Fields["ok"])
+	assert.Equal(t, "1.5s", decoded.Fields["duration"])
+	assert.Equal(t, "boom", decoded.Fields["error"])
+	assert.Equal(t, map[string]interface{}{"id": float64(1), "tags": []interface{}{"a", "b"}}, decoded.Fields["payload"])
+	assert.Equal(t, map[string]interface{}{"x": float64(1)}, decoded.Fields["counts"])
+	assert.Nil(t, decoded.Fields["nothing"])
+	assert.IsType(t, "", decoded.Fields["func"])
+}
+
+func TestLogger_Caller(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	logger := logging.NewLogger(logging.LogLevelInfo, &buf, &logging.JSONFormatter{})
+	logger.Info(ctx, "without caller")
+	assert.NotContains(t, buf.String(), `"caller"`)
+
+	logger.SetCaller(true)
+	logger.WithFields(logging.String("table", "users")).Info(ctx, "with caller")
+	logging.NewQueryLogger(logger, time.Second).LogQuery(ctx, "SELECT 1", time.Millisecond, 1, nil)
+	logger.SetLevel(logging.LogLevelDebug)
+	logging.NewQueryLogger(logger, time.Second).LogQuery(ctx, "SELECT 1", time.Millisecond, 1, nil)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	for _, line := range lines[1:] {
+		var decoded map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &decoded))
+		assert.Regexp(t, `^unit/logging_test\.go:\d+$`, decoded["caller"])
+	}
+
+	path := filepath.Join(t.TempDir(), "orm.log")
+	configured, err := logging.NewLoggerFromConfig(logging.LoggerConfig{Level: logging.LogLevelInfo, Format: "text", Output: path, EnableCaller: true})
+	require.NoError(t, err)
+	configured.Info(ctx, "configured")
+	require.NoError(t, configured.Close())
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Regexp(t, `\] info unit/logging_test\.go:\d+: configured\n`, string(content))
+}
+
+func TestLevelController(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	base := logging.NewLogger(logging.LogLevelInfo, &buf, &logging.TextFormatter{})
+	levels := logging.NewLevelController()
+	defer levels.Close()
+
+	repo := levels.Component("repository", base)
+	migrations := levels.Component("migrations", base)
+
+	require.NoError(t, levels.SetLevel("repository", logging.LogLevelDebug, 50*time.Millisecond))
+	repo.Debug(ctx, "repository debug")
+	migrations.Debug(ctx, "migrations debug")
+	base.Debug(ctx, "base debug")
+	assert.Contains(t, buf.String(), "repository debug | component=repository")
+	assert.NotContains(t, buf.String(), "migrations debug")
+	assert.NotContains(t, buf.String(), "base debug")
+
+	status := levels.Levels()
+	require.Len(t, status, 2)
+	assert.Equal(t, "migrations", status[0].Component)
+	assert.Nil(t, status[0].Expires)
+	assert.Equal(t, "repository", status[1].Component)
+	assert.Equal(t, "debug", status[1].Level)
+	assert.Equal(t, "info", status[1].Default)
+	require.NotNil(t, status[1].Expires)
+
+	// The temporary level reverts once its TTL elapses
+	assert.Eventually(t, func() bool {
+		return repo.GetLevel() == logging.LogLevelInfo
+	}, time.Second, 10*time.Millisecond)
+	assert.Nil(t, levels.Levels()[1].Expires)
+
+	// A level without TTL becomes the default
+	require.NoError(t, levels.SetLevel("*", logging.LogLevelWarn, 0))
+	require.NoError(t, levels.SetLevel("migrations", logging.LogLevelDebug, time.Hour))
+	require.NoError(t, levels.Reset("migrations"))
+	assert.Equal(t, logging.LogLevelWarn, migrations.GetLevel())
+	assert.Equal(t, logging.LogLevelWarn, repo.GetLevel())
+	assert.Equal(t, logging.LogLevelInfo, base.GetLevel())
+
+	assert.Error(t, levels.SetLevel("health", logging.LogLevelDebug, 0))
+	assert.Error(t, levels.Reset("health"))
+}
+
+func TestLevelController_Handler(t *testing.T) {
+	base := logging.NewLogger(logging.LogLevelInfo, &bytes.Buffer{}, nil)
+	levels := logging.NewLevelController()
+	defer levels.Close()
+	repo := levels.Component("repository", base)
+	levels.Component("health", base)
+
+	serve := func(method, target, body string) (*httptest.ResponseRecorder, []logging.ComponentLevel) {
+		recorder := httptest.NewRecorder()
+		levels.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+		var status []logging.ComponentLevel
+		if recorder.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &status))
+		}
+		return recorder, status
+	}
+
+	recorder, status := serve(http.MethodGet, "/log-levels", "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Len(t, status, 2)
+
+	recorder, status = serve(http.MethodPut, "/log-levels", `{"component":"repository","level":"debug","ttl":"15m"}`)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, logging.LogLevelDebug, repo.GetLevel())
+	assert.Equal(t, "debug", status[1].Level)
+	require.NotNil(t, status[1].Expires)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), *status[1].Expires, time.Minute)
+
+	recorder, _ = serve(http.MethodDelete, "/log-levels?component=repository", "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, logging.LogLevelInfo, repo.GetLevel())
+
+	recorder, _ = serve(http.MethodPost, "/log-levels", `{"component":"repository","level":"verbose"}`)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "unknown log level")
+	recorder, _ = serve(http.MethodPost, "/log-levels", `{"component":"repository","level":"debug","ttl":"soon"}`)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	recorder, _ = serve(http.MethodPost, "/log-levels", `{"component":"jobs","level":"debug"}`)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	recorder, _ = serve(http.MethodPatch, "/log-levels", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+	assert.Equal(t, logging.LogLevelInfo, repo.GetLevel())
+}
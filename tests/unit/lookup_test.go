@@ -0,0 +1,110 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestBaseRepository_FindFirstBy(t *testing.T) {
+	repo, _ := setupTestRepository(t)
+	ctx := context.Background()
+
+	first := &TestEntity{Name: "Alice", Age: 30}
+	require.NoError(t, repo.Create(ctx, first))
+	require.NoError(t, repo.Create(ctx, &TestEntity{Name: "Bob", Age: 30}))
+
+	var found TestEntity
+	require.NoError(t, repo.FindFirstBy(ctx, &found, "name", "Bob"))
+	assert.Equal(t, 30, found.Age)
+
+	// Field names resolve to their column
+	var byField TestEntity
+	require.NoError(t, repo.FindFirstBy(ctx, &byField, "Name", "Alice"))
+	assert.Equal(t, first.ID, byField.ID)
+
+	// Several matches return the first by primary key, as FindFirstByConditions does
+	var expected, byAge TestEntity
+	require.NoError(t, repo.FindFirstByConditions(ctx, &expected, "age = ?", 30))
+	require.NoError(t, repo.FindFirstBy(ctx, &byAge, "age", 30))
+	assert.Equal(t, expected.ID, byAge.ID)
+
+	err := repo.FindFirstBy(ctx, &TestEntity{}, "name", "Nobody")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	err = repo.FindFirstBy(ctx, &TestEntity{}, "name; DROP TABLE test_entities", "x")
+	assert.ErrorContains(t, err, "unknown column")
+
+	// Soft deleted entities are skipped unless the context includes them
+	require.NoError(t, repo.SoftDeleteByID(ctx, first.ID))
+	err = repo.FindFirstBy(ctx, &TestEntity{}, "name", "Alice")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	var deleted TestEntity
+	require.NoError(t, repo.FindFirstBy(repository.WithDeleted(ctx), &deleted, "name", "Alice"))
+	assert.NotNil(t, deleted.DeletedAt)
+
+	// Transactions see their own writes
+	err = repo.WithTransaction(ctx, func(txRepo repository.Repository[TestEntity]) error {
+		if err := txRepo.Create(ctx, &TestEntity{Name: "Carol", Age: 41}); err != nil {
+			return err
+		}
+		var carol TestEntity
+		return txRepo.FindFirstBy(ctx, &carol, "name", "Carol")
+	})
+	assert.NoError(t, err)
+
+	metrics := repo.GetMetrics()
+	assert.Contains(t, metrics.Operations, "FindFirstBy")
+}
+
+// benchmarkLookupRepository creates a repository holding n entities and
+// returns the ID of one of them
+func benchmarkLookupRepository(b *testing.B, n int) (*repository.BaseRepository[TestEntity], uuid.UUID) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(b, err)
+	require.NoError(b, db.AutoMigrate(&TestEntity{}))
+
+	logger := logging.NewLogger(logging.LogLevelError, io.Discard, &logging.TextFormatter{})
+	repo := repository.NewBaseRepository[TestEntity](db, logger, repository.DefaultRepositoryConfig())
+	entities := make([]TestEntity, n)
+	for i := range entities {
+		entities[i] = TestEntity{Name: fmt.Sprintf("user-%d", i), Age: i % 90}
+	}
+	require.NoError(b, repo.CreateInBatches(context.Background(), entities, 100))
+	return repo, entities[n/2].ID
+}
+
+func BenchmarkFindFirstByConditions(b *testing.B) {
+	repo, id := benchmarkLookupRepository(b, 1000)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var dest TestEntity
+		if err := repo.FindFirstByConditions(ctx, &dest, "id = ?", id); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFindFirstBy(b *testing.B) {
+	repo, id := benchmarkLookupRepository(b, 1000)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var dest TestEntity
+		if err := repo.FindFirstBy(ctx, &dest, "id", id); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
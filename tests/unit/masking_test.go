@@ -0,0 +1,121 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/audit"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// setupMaskedRepository creates a test repository whose name column is
+// redacted and age column omitted for the support role
+func setupMaskedRepository(t *testing.T, onMasked func(context.Context, *repository.MaskedRead) error) (*repository.BaseRepository[TestEntity], *gorm.DB, *TestEntity) {
+	db := setupTestDB(t)
+	masker := repository.NewColumnMasker(&repository.MaskingConfig{
+		Rules: []repository.ColumnRule{
+			{Table: "test_entities", Column: "name", Roles: []string{"support"}, Mode: repository.MaskRedact},
+			{Table: "test_entities", Column: "Age", Roles: []string{"support", "intern"}},
+		},
+		OnMasked: onMasked,
+	})
+	require.NoError(t, db.Use(masker))
+
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	repo := repository.NewBaseRepository[TestEntity](db, logger, nil)
+	entity := &TestEntity{Name: "Alice", Age: 30}
+	require.NoError(t, repo.Create(context.Background(), entity))
+	return repo, db, entity
+}
+
+func TestColumnMasker_Reads(t *testing.T) {
+	var reads []*repository.MaskedRead
+	repo, db, entity := setupMaskedRepository(t, func(ctx context.Context, read *repository.MaskedRead) error {
+		reads = append(reads, read)
+		return nil
+	})
+
+	// Without a role, or with a role no rule names, nothing is hidden
+	found, err := repo.FindFirstByID(context.Background(), entity.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", found.Name)
+	assert.Equal(t, 30, found.Age)
+	found, err = repo.FindFirstByID(repository.WithRole(context.Background(), "admin"), entity.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", found.Name)
+	assert.Empty(t, reads)
+
+	ctx := repository.WithRole(context.Background(), "support")
+	found, err = repo.FindFirstByID(ctx, entity.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.ID, found.ID)
+	assert.Equal(t, "***", found.Name)
+	assert.Zero(t, found.Age)
+	require.Len(t, reads, 1)
+	assert.Equal(t, "test_entities", reads[0].Table)
+	assert.Equal(t, "support", reads[0].Role)
+	assert.Equal(t, []string{"name", "age"}, reads[0].Columns)
+
+	// Columns named by the query are masked too
+	var entities []TestEntity
+	require.NoError(t, db.WithContext(ctx).Select("id", "name", "age").Find(&entities).Error)
+	require.Len(t, entities, 1)
+	assert.Equal(t, "***", entities[0].Name)
+	assert.Zero(t, entities[0].Age)
+
+	intern := repository.WithRole(context.Background(), "intern")
+	found, err = repo.FindFirstByID(intern, entity.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", found.Name)
+	assert.Zero(t, found.Age)
+	assert.Equal(t, []string{"age"}, reads[len(reads)-1].Columns)
+
+	// Queries with their own SELECT clause, such as counts, are left alone
+	count, err := repo.CountByConditions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	var name string
+	require.NoError(t, db.WithContext(ctx).Model(&TestEntity{}).
+		Clauses(clause.Select{Columns: []clause.Column{{Name: "name"}}}).Scan(&name).Error)
+	assert.Equal(t, "Alice", name)
+}
+
+func TestColumnMasker_Audit(t *testing.T) {
+	db := setupTestDB(t)
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	auditor, err := audit.NewAuditor(db, logger, nil)
+	require.NoError(t, err)
+
+	masker := repository.NewColumnMasker(&repository.MaskingConfig{
+		Rules:    []repository.ColumnRule{{Table: "test_entities", Column: "name", Roles: []string{"support"}}},
+		OnMasked: auditor.RecordMaskedRead,
+	})
+	require.NoError(t, db.Use(masker))
+	repo := repository.NewBaseRepository[TestEntity](db, logger, nil)
+	entity := &TestEntity{Name: "Alice", Age: 30}
+	require.NoError(t, repo.Create(context.Background(), entity))
+
+	ctx := context.WithValue(repository.WithRole(context.Background(), "support"), "user_id", "bob")
+	found, err := repo.FindFirstByID(ctx, entity.ID)
+	require.NoError(t, err)
+	assert.Empty(t, found.Name)
+	assert.Equal(t, 30, found.Age)
+
+	var logs []audit.AuditLog
+	require.NoError(t, db.Where("operation = ?", audit.OperationMaskedRead).Find(&logs).Error)
+	require.Len(t, logs, 1)
+	assert.Equal(t, "test_entities", logs[0].EntityType)
+	assert.Equal(t, "bob", logs[0].Actor)
+	var after struct {
+		Role    string   `json:"role"`
+		Columns []string `json:"columns"`
+	}
+	require.NoError(t, logs[0].DecodeAfter(&after))
+	assert.Equal(t, "support", after.Role)
+	assert.Equal(t, []string{"name"}, after.Columns)
+}
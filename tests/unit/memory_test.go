@@ -0,0 +1,236 @@
+package unit
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/seasbee/go-ormx/pkg/repository/repositorytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryAccount has a unique column and a native soft delete field
+type memoryAccount struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Email     string    `gorm:"uniqueIndex"`
+	Plan      string
+	Balance   int
+	Nickname  *string
+	DeletedAt gorm.DeletedAt
+}
+
+func TestMemoryRepository_Conformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repository[repositorytest.Entity] {
+		return repository.NewMemoryRepository[repositorytest.Entity](nil)
+	})
+}
+
+func TestMemoryRepository_Conditions(t *testing.T) {
+	repo := repository.NewMemoryRepository[memoryAccount](nil)
+	ctx := context.Background()
+
+	nickname := "bob"
+	for _, account := range []memoryAccount{
+		{Email: "ann@example.com", Plan: "free", Balance: 10},
+		{Email: "bob@example.com", Plan: "pro", Balance: 50, Nickname: &nickname},
+		{Email: "cid@example.com", Plan: "pro", Balance: 90},
+	} {
+		require.NoError(t, repo.Create(ctx, &account))
+		assert.NotEqual(t, uuid.Nil, account.ID)
+	}
+
+	count := func(conds ...interface{}) int64 {
+		t.Helper()
+		n, err := repo.CountByConditions(ctx, conds...)
+		require.NoError(t, err)
+		return n
+	}
+	assert.Equal(t, int64(3), count())
+	assert.Equal(t, int64(2), count("plan = ?", "pro"))
+	assert.Equal(t, int64(1), count("plan = ? AND balance > ?", "pro", 60))
+	assert.Equal(t, int64(2), count("balance < 20 OR nickname IS NOT NULL"))
+	assert.Equal(t, int64(1), count("plan IN ?", []string{"free", "team"}))
+	assert.Equal(t, int64(1), count("email LIKE ?", "c%"))
+	assert.Equal(t, int64(2), count("balance BETWEEN ? AND ?", 10, 50))
+	assert.Equal(t, int64(1), count(map[string]interface{}{"plan": "pro", "balance": 90}))
+	assert.Equal(t, int64(2), count(&memoryAccount{Plan: "pro"}))
+	assert.Equal(t, int64(2), count(repository.Or(repository.Eq("plan", "free"), repository.Gte("balance", 90))))
+	assert.Equal(t, int64(1), count(repository.Not(repository.In("plan", "pro"))))
+
+	var first memoryAccount
+	require.NoError(t, repo.FindFirstBy(ctx, &first, "nickname", "bob"))
+	assert.Equal(t, "bob@example.com", first.Email)
+
+	// Conditions outside the supported subset fail instead of matching wrongly
+	_, err := repo.CountByConditions(ctx, "lower(email) = ?", "ann@example.com")
+	require.Error(t, err)
+	assert.True(t, errors.IsType(err, errors.ErrorTypeValidation))
+	_, err = repo.CountByConditions(ctx, "missing = ?", 1)
+	require.Error(t, err)
+}
+
+func TestMemoryRepository_Writes(t *testing.T) {
+	repo := repository.NewMemoryRepository[memoryAccount](nil)
+	ctx := context.Background()
+
+	account := &memoryAccount{Email: "ann@example.com", Plan: "free"}
+	require.NoError(t, repo.Create(ctx, account))
+
+	// Unique indexes are enforced
+	err := repo.Create(ctx, &memoryAccount{Email: "ann@example.com"})
+	require.Error(t, err)
+	assert.True(t, errors.IsDuplicate(err))
+
+	// Stored entities are copies
+	account.Plan = "changed"
+	found, err := repo.FindFirstByID(ctx, account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "free", found.Plan)
+
+	updated, err := repo.UpdateAllByConditions(ctx, map[string]interface{}{"plan": "pro"}, "email = ?", "ann@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), updated)
+
+	ok, err := repo.UpdateIf(ctx, account.ID, map[string]interface{}{"plan": "free"}, map[string]interface{}{"balance": 5})
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = repo.UpdateIf(ctx, account.ID, map[string]interface{}{"plan": "pro"}, map[string]interface{}{"balance": 5})
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	var created memoryAccount
+	isNew, err := repo.FindOrCreateByConditions(ctx, &created, map[string]interface{}{"plan": "team"}, repository.Eq("email", "bob@example.com"))
+	require.NoError(t, err)
+	assert.True(t, isNew)
+	assert.Equal(t, "bob@example.com", created.Email)
+	assert.Equal(t, "team", created.Plan)
+
+	err = repo.Upsert(ctx, &memoryAccount{Email: "bob@example.com", Plan: "pro", Balance: 7},
+		repository.ConflictOptions{Columns: []string{"email"}, DoUpdates: []string{"balance"}})
+	require.NoError(t, err)
+	found, err = repo.FindFirstByID(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "team", found.Plan)
+	assert.Equal(t, 7, found.Balance)
+
+	// gorm.DeletedAt entities are soft deleted and can be restored
+	require.NoError(t, repo.DeleteByID(ctx, account.ID))
+	_, err = repo.FindFirstByID(ctx, account.ID)
+	assert.True(t, stderrors.Is(err, gorm.ErrRecordNotFound))
+	exists, err := repo.ExistsByID(repository.WithDeleted(ctx), account.ID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	require.NoError(t, repo.RestoreByID(ctx, account.ID))
+	_, err = repo.FindFirstByID(ctx, account.ID)
+	require.NoError(t, err)
+
+	// Unsupported operations say so
+	_, err = repo.Begin(ctx)
+	assert.True(t, stderrors.Is(err, stderrors.ErrUnsupported))
+}
+
+func TestMemoryRepository_SoftDeleteColumn(t *testing.T) {
+	repo := repository.NewMemoryRepository[TestEntity](&repository.RepositoryConfig{
+		DefaultLimit: 10, MaxLimit: 100, DeleteMode: repository.DeleteModeSoft,
+	})
+	ctx := context.Background()
+
+	entity := &TestEntity{Name: "soft", Age: 1}
+	require.NoError(t, repo.Create(ctx, entity))
+	assert.False(t, entity.CreatedAt.IsZero())
+
+	require.NoError(t, repo.DeleteByID(ctx, entity.ID))
+	var all []TestEntity
+	require.NoError(t, repo.FindAllIncludingDeleted(ctx, 10, 0, &all))
+	require.Len(t, all, 1)
+	assert.NotNil(t, all[0].DeletedAt)
+	count, err := repo.CountByConditions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	// Hard deletes remove soft deleted entities too
+	hard := repository.NewMemoryRepository[TestEntity](nil)
+	require.NoError(t, hard.Create(ctx, entity))
+	require.NoError(t, hard.DeleteByID(ctx, entity.ID))
+	count, err = hard.CountByConditions(repository.WithDeleted(ctx))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestMemoryRepository_Transactions(t *testing.T) {
+	repo := repository.NewMemoryRepository[TestEntity](nil)
+	ctx := context.Background()
+
+	var committed, rolledBack int
+	err := repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		tx.AfterCommit(ctx, func(context.Context) { committed++ })
+		require.NoError(t, tx.Create(ctx, &TestEntity{Name: "kept", Age: 1}))
+
+		require.NoError(t, tx.SavePoint(ctx, "before"))
+		require.NoError(t, tx.Create(ctx, &TestEntity{Name: "undone", Age: 1}))
+		require.NoError(t, tx.RollbackTo(ctx, "before"))
+
+		// Writes are not visible outside the transaction until it commits
+		count, err := repo.CountByConditions(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), count)
+
+		// A failed nested transaction leaves the outer one unchanged
+		err = tx.WithTransaction(ctx, func(nested repository.Repository[TestEntity]) error {
+			nested.AfterRollback(ctx, func(context.Context) { rolledBack++ })
+			require.NoError(t, nested.Create(ctx, &TestEntity{Name: "nested", Age: 1}))
+			return stderrors.New("nested failure")
+		})
+		require.Error(t, err)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, committed)
+	assert.Equal(t, 1, rolledBack)
+
+	var names []TestEntity
+	require.NoError(t, repo.FindAllWithOffset(ctx, 10, 0, &names))
+	require.Len(t, names, 1)
+	assert.Equal(t, "kept", names[0].Name)
+
+	// Panics roll back and are returned as transaction errors
+	err = repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		require.NoError(t, tx.Create(ctx, &TestEntity{Name: "panic", Age: 1}))
+		panic("boom")
+	})
+	require.Error(t, err)
+	assert.True(t, errors.IsType(err, errors.ErrorTypeTransaction))
+	count, err := repo.CountByConditions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	require.Error(t, repo.SavePoint(ctx, "outside"))
+}
+
+func TestMemoryRepository_Concurrency(t *testing.T) {
+	repo := repository.NewMemoryRepository[TestEntity](nil)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+				return tx.Create(ctx, &TestEntity{Name: "concurrent", Age: 1})
+			})
+			_, _ = repo.CountByConditions(ctx, "name = ?", "concurrent")
+		}()
+	}
+	wg.Wait()
+
+	count, err := repo.CountByConditions(ctx, "name = ?", "concurrent")
+	require.NoError(t, err)
+	assert.Equal(t, int64(20), count)
+}
@@ -0,0 +1,159 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type indexedOrder struct {
+	ID         uint   `gorm:"primaryKey"`
+	CustomerID uint   `gorm:"index:idx_orders_customer_status,priority:1;index:idx_orders_open,where:closed_at IS NULL,include:Total status"`
+	Status     string `gorm:"index:idx_orders_customer_status,priority:2"`
+	Email      string `gorm:"uniqueIndex:idx_orders_email_open,where:closed_at IS NULL"`
+	Total      int64
+	ClosedAt   *time.Time
+}
+
+func TestMigrations_ModelIndexes(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	indexes, err := migrations.ModelIndexes(db, &indexedOrder{})
+	require.NoError(t, err)
+
+	byName := map[string]dialect.IndexDefinition{}
+	for _, index := range indexes {
+		byName[index.Name] = index
+	}
+	require.Len(t, byName, 3)
+
+	assert.Equal(t, []string{"customer_id", "status"}, byName["idx_orders_customer_status"].KeyColumns())
+	assert.Equal(t, dialect.IndexDefinition{
+		Name:    "idx_orders_open",
+		Table:   "indexed_orders",
+		Columns: []dialect.IndexColumn{{Name: "customer_id"}},
+		Where:   "closed_at IS NULL",
+		Include: []string{"total", "status"},
+	}, byName["idx_orders_open"])
+	assert.True(t, byName["idx_orders_email_open"].Unique)
+
+	type badInclude struct {
+		ID   uint `gorm:"primaryKey"`
+		Name string
+		Code string `gorm:"index:idx_bad,include:missing"`
+	}
+	_, err = migrations.ModelIndexes(db, &badInclude{})
+	assert.ErrorContains(t, err, `unknown column "missing"`)
+}
+
+func TestMigrations_IndexGenerationAndDrift(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// Without a table every declared index is missing
+	drifts, err := migrations.CheckIndexes(ctx, db, &indexedOrder{})
+	require.NoError(t, err)
+	assert.Len(t, drifts, 3)
+	assert.Equal(t, migrations.DriftMissing, drifts[0].Problem)
+
+	require.NoError(t, db.Exec("CREATE TABLE indexed_orders (id integer PRIMARY KEY, customer_id integer, status text, email text, total integer, closed_at datetime)").Error)
+
+	statements, err := migrations.GenerateIndexes(ctx, db, &indexedOrder{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"CREATE INDEX `idx_orders_customer_status` ON `indexed_orders` (`customer_id`, `status`)",
+		// SQLite has no INCLUDE; the covered columns become trailing key columns
+		"CREATE INDEX `idx_orders_open` ON `indexed_orders` (`customer_id`, `total`, `status`) WHERE closed_at IS NULL",
+		"CREATE UNIQUE INDEX `idx_orders_email_open` ON `indexed_orders` (`email`) WHERE closed_at IS NULL",
+	}, statements)
+
+	created, err := migrations.CreateIndexes(ctx, db, &indexedOrder{})
+	require.NoError(t, err)
+	assert.Len(t, created, 3)
+
+	drifts, err = migrations.CheckIndexes(ctx, db, &indexedOrder{})
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+
+	statements, err = migrations.GenerateIndexes(ctx, db, &indexedOrder{})
+	require.NoError(t, err)
+	assert.Empty(t, statements)
+
+	// Recreate indexes without their predicate and covered columns
+	require.NoError(t, db.Exec("DROP INDEX idx_orders_email_open").Error)
+	require.NoError(t, db.Exec("CREATE INDEX idx_orders_email_open ON indexed_orders (email)").Error)
+	require.NoError(t, db.Exec("DROP INDEX idx_orders_open").Error)
+	require.NoError(t, db.Exec("CREATE INDEX idx_orders_open ON indexed_orders (customer_id) WHERE closed_at IS NULL").Error)
+
+	drifts, err = migrations.CheckIndexes(ctx, db, &indexedOrder{})
+	require.NoError(t, err)
+	problems := map[string]string{}
+	for _, drift := range drifts {
+		problems[drift.Index+" "+drift.Problem] = drift.Expected
+	}
+	assert.Equal(t, map[string]string{
+		"idx_orders_email_open unique": "true",
+		"idx_orders_email_open where":  "closed_at IS NULL",
+	}, problems)
+
+	// The bare key columns are accepted for the covering index
+	for _, drift := range drifts {
+		assert.NotEqual(t, "idx_orders_open", drift.Index, drift.String())
+	}
+}
+
+func TestDialect_CreateIndex(t *testing.T) {
+	covering := dialect.IndexDefinition{
+		Name:    "idx_orders_open",
+		Table:   "orders",
+		Columns: []dialect.IndexColumn{{Name: "customer_id"}, {Name: "created_at", Sort: "desc"}},
+		Where:   "closed_at IS NULL",
+		Include: []string{"total"},
+	}
+
+	statement, err := dialect.CreateIndex(dialect.Postgres{}, covering)
+	require.NoError(t, err)
+	assert.Equal(t, `CREATE INDEX "idx_orders_open" ON "orders" ("customer_id", "created_at" DESC) INCLUDE ("total") WHERE closed_at IS NULL`, statement)
+
+	statement, err = dialect.CreateIndex(dialect.CockroachDB{}, covering)
+	require.NoError(t, err)
+	assert.Equal(t, `CREATE INDEX "idx_orders_open" ON "orders" ("customer_id", "created_at" DESC) STORING ("total") WHERE closed_at IS NULL`, statement)
+
+	statement, err = dialect.CreateIndex(dialect.SQLServer{}, covering)
+	require.NoError(t, err)
+	assert.Equal(t, `CREATE INDEX [idx_orders_open] ON [orders] ([customer_id], [created_at] DESC) INCLUDE ([total]) WHERE closed_at IS NULL`, statement)
+
+	// MySQL has no partial indexes
+	_, err = dialect.CreateIndex(dialect.MySQL{}, covering)
+	assert.ErrorIs(t, err, dialect.ErrUnsupportedIndex)
+
+	covering.Where = ""
+	statement, err = dialect.CreateIndex(dialect.MySQL{}, covering)
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE INDEX `idx_orders_open` ON `orders` (`customer_id`, `created_at` DESC, `total`)", statement)
+
+	// A unique index cannot be widened with the covered columns
+	covering.Unique = true
+	_, err = dialect.CreateIndex(dialect.Oracle{}, covering)
+	assert.ErrorIs(t, err, dialect.ErrUnsupportedIndex)
+
+	expression := dialect.IndexDefinition{
+		Name:    "idx_users_lower_email",
+		Table:   "users",
+		Unique:  true,
+		Columns: []dialect.IndexColumn{{Expression: "lower(email)"}},
+	}
+	statement, err = dialect.CreateIndex(dialect.Postgres{}, expression)
+	require.NoError(t, err)
+	assert.Equal(t, `CREATE UNIQUE INDEX "idx_users_lower_email" ON "users" (lower(email))`, statement)
+	assert.Nil(t, expression.KeyColumns())
+}
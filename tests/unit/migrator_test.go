@@ -0,0 +1,429 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/config"
+	"github.com/seasbee/go-ormx/pkg/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupMigrator(t *testing.T, cfg *migrations.MigratorConfig) (*gorm.DB, *migrations.Migrator) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "migrations.db")), &gorm.Config{})
+	require.NoError(t, err)
+
+	migrator, err := migrations.NewMigrator(db, nil, cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, migrator.AddFS(fstest.MapFS{
+		"sql/0001_create_accounts.up.sql":   {Data: []byte("CREATE TABLE accounts (id integer PRIMARY KEY, name text)")},
+		"sql/0001_create_accounts.down.sql": {Data: []byte("DROP TABLE accounts")},
+		"sql/0003_add_email.up.sql":         {Data: []byte("ALTER TABLE accounts ADD COLUMN email text")},
+		"sql/README.md":                     {Data: []byte("ignored")},
+	}, "sql"))
+	require.NoError(t, migrator.Add(migrations.Migration{
+		Version: 2,
+		Name:    "seed_accounts",
+		Up: func(ctx context.Context, tx *gorm.DB) error {
+			return tx.Exec("INSERT INTO accounts (id, name) VALUES (1, 'root')").Error
+		},
+		Down: func(ctx context.Context, tx *gorm.DB) error {
+			return tx.Exec("DELETE FROM accounts WHERE id = 1").Error
+		},
+	}))
+	return db, migrator
+}
+
+func TestMigrator_UpDown(t *testing.T) {
+	db, migrator := setupMigrator(t, nil)
+	ctx := context.Background()
+
+	assert.Error(t, migrator.Add(migrations.Migration{Version: 2, Name: "duplicate", UpSQL: "SELECT 1"}))
+	assert.Error(t, migrator.Add(migrations.Migration{Version: 4, Name: "empty"}))
+
+	report, err := migrator.UpTo(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, "create_accounts", report.Results[0].Name)
+	assert.Equal(t, int64(2), report.Results[1].Version)
+
+	report, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Len(t, report.Results, 1)
+	assert.True(t, db.Migrator().HasColumn("accounts", "email"))
+
+	// Nothing left to apply
+	report, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, report.Results)
+
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), version)
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+	for _, status := range statuses {
+		assert.True(t, status.Applied)
+		assert.NotNil(t, status.AppliedAt)
+	}
+
+	// Version 3 has no down step
+	_, err = migrator.Down(ctx, 1)
+	assert.ErrorIs(t, err, migrations.ErrIrreversible)
+
+	require.NoError(t, migrator.Add(migrations.Migration{Version: 4, Name: "broken", UpSQL: "ALTER TABLE missing ADD COLUMN x text"}))
+	_, err = migrator.Up(ctx)
+	assert.ErrorContains(t, err, "migration 4 (broken) up failed")
+	version, err = migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), version, "a failed migration is not recorded")
+
+	// The lock is released after every run, including failed ones
+	var locks int64
+	require.NoError(t, db.Table("schema_migrations_lock").Count(&locks).Error)
+	assert.Zero(t, locks)
+}
+
+func TestMigrator_Down(t *testing.T) {
+	db, migrator := setupMigrator(t, nil)
+	ctx := context.Background()
+
+	_, err := migrator.UpTo(ctx, 2)
+	require.NoError(t, err)
+
+	report, err := migrator.Down(ctx, 5)
+	require.NoError(t, err)
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, int64(2), report.Results[0].Version)
+	assert.Equal(t, int64(1), report.Results[1].Version)
+	assert.False(t, db.Migrator().HasTable("accounts"))
+
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, version)
+}
+
+func TestMigrator_DryRun(t *testing.T) {
+	db, migrator := setupMigrator(t, &migrations.MigratorConfig{DryRun: true})
+	ctx := context.Background()
+
+	report, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Len(t, report.Results, 3)
+
+	// Nothing was executed, not even the tracking tables
+	assert.False(t, db.Migrator().HasTable("accounts"))
+	assert.False(t, db.Migrator().HasTable("schema_migrations"))
+}
+
+func TestMigrator_Locking(t *testing.T) {
+	db, migrator := setupMigrator(t, &migrations.MigratorConfig{
+		Owner:          "deploy-a",
+		LockTimeout:    300 * time.Millisecond,
+		StaleLockAfter: time.Hour,
+	})
+	ctx := context.Background()
+
+	// Create the tracking tables, then simulate another deploy holding the lock
+	_, err := migrator.UpTo(ctx, 1)
+	require.NoError(t, err)
+	require.NoError(t, db.Exec("INSERT INTO schema_migrations_lock (id, locked_by, locked_at) VALUES (1, 'deploy-b', ?)", time.Now().UTC()).Error)
+
+	_, err = migrator.Up(ctx)
+	assert.True(t, errors.Is(err, migrations.ErrLocked), "unexpected error: %v", err)
+	assert.ErrorContains(t, err, "deploy-b")
+
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), version)
+
+	// A lock left by a crashed deploy is taken over once stale
+	require.NoError(t, db.Exec("UPDATE schema_migrations_lock SET locked_at = ?", time.Now().Add(-2*time.Hour).UTC()).Error)
+	report, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, report.Results, 2)
+}
+
+func TestMigrator_LockHeartbeat(t *testing.T) {
+	// Shared cache locks tables, not the database, so the heartbeat can
+	// write while the migration transaction is open
+	db, err := gorm.Open(sqlite.Open("file:migrator_heartbeat?mode=memory&cache=shared&_busy_timeout=5000"), &gorm.Config{})
+	require.NoError(t, err)
+	migrator, err := migrations.NewMigrator(db, nil, &migrations.MigratorConfig{
+		Owner:          "deploy-a",
+		StaleLockAfter: 150 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	// A migration outliving StaleLockAfter keeps its lock
+	require.NoError(t, migrator.Add(migrations.Migration{
+		Version: 1,
+		Name:    "slow",
+		Up: func(ctx context.Context, tx *gorm.DB) error {
+			time.Sleep(400 * time.Millisecond)
+			var lock struct{ LockedAt time.Time }
+			if err := tx.Table("schema_migrations_lock").Take(&lock).Error; err != nil {
+				return err
+			}
+			if time.Since(lock.LockedAt) > 150*time.Millisecond {
+				return errors.New("lock went stale")
+			}
+			return nil
+		},
+	}))
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+
+	// Another deploy takes the lock over: the running migration is cancelled
+	require.NoError(t, migrator.Add(migrations.Migration{
+		Version: 2,
+		Name:    "taken_over",
+		UpSQL:   "UPDATE schema_migrations_lock SET locked_by = 'deploy-b'",
+	}, migrations.Migration{
+		Version: 3,
+		Name:    "waits",
+		Up: func(ctx context.Context, tx *gorm.DB) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(5 * time.Second):
+				return nil
+			}
+		},
+	}))
+	_, err = migrator.Up(context.Background())
+	assert.ErrorIs(t, err, migrations.ErrLockLost)
+
+	version, err := migrator.Version(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), version)
+
+	// The lock of the other deploy is left in place
+	var owner string
+	require.NoError(t, db.Table("schema_migrations_lock").Select("locked_by").Scan(&owner).Error)
+	assert.Equal(t, "deploy-b", owner)
+}
+
+func TestMigrator_SkipsMigrationsRunElsewhere(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "migrations.db")), &gorm.Config{})
+	require.NoError(t, err)
+	migrator, err := migrations.NewMigrator(db, nil, nil)
+	require.NoError(t, err)
+
+	// Version 2 is recorded by another deploy after this run read the applied versions
+	require.NoError(t, migrator.Add(migrations.Migration{
+		Version: 1,
+		Name:    "first",
+		Up: func(ctx context.Context, tx *gorm.DB) error {
+			return tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (2, 'second', ?)", time.Now().UTC()).Error
+		},
+	}, migrations.Migration{
+		Version: 2,
+		Name:    "second",
+		UpSQL:   "ALTER TABLE missing ADD COLUMN x text",
+	}))
+
+	report, err := migrator.Up(context.Background())
+	require.NoError(t, err)
+	require.Len(t, report.Results, 1)
+	assert.Equal(t, int64(1), report.Results[0].Version)
+
+	version, err := migrator.Version(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), version)
+}
+
+func TestMigrator_FromConfig(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "20240101120000_create_notes.up.sql"), []byte("CREATE TABLE notes (id integer PRIMARY KEY)"), 0o600))
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "config.db")), &gorm.Config{})
+	require.NoError(t, err)
+
+	cfg := config.DefaultDatabaseConfig().Migrations
+	cfg.Dir = dir
+	cfg.Table = "app_migrations"
+	migrator, err := migrations.NewMigratorFromConfig(db, cfg, nil)
+	require.NoError(t, err)
+	require.Len(t, migrator.Migrations(), 1)
+
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+	assert.True(t, db.Migrator().HasTable("notes"))
+	assert.True(t, db.Migrator().HasTable("app_migrations"))
+
+	// A missing directory only disables SQL file migrations
+	cfg.Dir = filepath.Join(dir, "missing")
+	migrator, err = migrations.NewMigratorFromConfig(db, cfg, nil)
+	require.NoError(t, err)
+	assert.Empty(t, migrator.Migrations())
+}
+
+func TestSplitScript(t *testing.T) {
+	statements, err := migrations.SplitScript(`-- accounts
+CREATE TABLE accounts (id integer PRIMARY KEY, name text);
+INSERT INTO accounts VALUES (1, 'semi;colon ''quoted''');;
+/* trailing comment */`, "sqlite")
+	require.NoError(t, err)
+	require.Len(t, statements, 2)
+	assert.Equal(t, migrations.ScriptStatement{Index: 1, Line: 2, SQL: "CREATE TABLE accounts (id integer PRIMARY KEY, name text)"}, statements[0])
+	assert.Equal(t, "INSERT INTO accounts VALUES (1, 'semi;colon ''quoted''')", statements[1].SQL)
+	assert.Equal(t, 3, statements[1].Line)
+
+	// Dollar-quoted function bodies
+	statements, err = migrations.SplitScript(`CREATE FUNCTION touch() RETURNS trigger AS $body$
+BEGIN
+  NEW.updated_at := now();
+  RETURN NEW;
+END;
+$body$ LANGUAGE plpgsql;
+SELECT $1, $$a;b$$`, "postgres")
+	require.NoError(t, err)
+	require.Len(t, statements, 2)
+	assert.Contains(t, statements[0].SQL, "RETURN NEW;")
+	assert.Equal(t, 7, statements[1].Line)
+	assert.Equal(t, "SELECT $1, $$a;b$$", statements[1].SQL)
+
+	// DELIMITER directives of the mysql client
+	statements, err = migrations.SplitScript(`DELIMITER //
+CREATE PROCEDURE touch() BEGIN UPDATE accounts SET name = 'it\'s'; END//
+DELIMITER ;
+SELECT 1;`, "mysql")
+	require.NoError(t, err)
+	require.Len(t, statements, 2)
+	assert.Equal(t, "CREATE PROCEDURE touch() BEGIN UPDATE accounts SET name = 'it\\'s'; END", statements[0].SQL)
+	assert.Equal(t, "SELECT 1", statements[1].SQL)
+
+	// GO batch separators
+	statements, err = migrations.SplitScript("SELECT 1\nGO\nSELECT [a;b]\ngo\n", "sqlserver")
+	require.NoError(t, err)
+	require.Len(t, statements, 2)
+	assert.Equal(t, "SELECT [a;b]", statements[1].SQL)
+
+	_, err = migrations.SplitScript("SELECT 'unterminated;", "sqlite")
+	assert.Error(t, err)
+	_, err = migrations.SplitScript("SELECT $$ unterminated;", "postgres")
+	assert.Error(t, err)
+}
+
+func TestExecScript(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "script.db")), &gorm.Config{})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	script := `CREATE TABLE accounts (id integer PRIMARY KEY, name text, updated integer DEFAULT 0);
+CREATE TRIGGER accounts_touch AFTER UPDATE OF name ON accounts
+BEGIN
+  UPDATE accounts SET updated = CASE WHEN NEW.id > 0 THEN 1 ELSE 0 END WHERE id = NEW.id;
+END;
+INSERT INTO accounts (id, name) VALUES (1, 'a'), (2, 'b');
+UPDATE accounts SET name = 'c' WHERE id = 1;`
+
+	// Dry runs only report the statements
+	report, err := migrations.ExecScript(ctx, db, script, &migrations.ScriptConfig{DryRun: true})
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+	require.Len(t, report.Statements, 4)
+	assert.Equal(t, 2, report.Statements[1].Line)
+	assert.False(t, db.Migrator().HasTable("accounts"))
+
+	report, err = migrations.ExecScript(ctx, db, script, nil)
+	require.NoError(t, err)
+	require.Len(t, report.Statements, 4)
+	assert.Equal(t, int64(2), report.Statements[2].RowsAffected)
+	assert.Zero(t, report.Failed)
+
+	var updated int
+	require.NoError(t, db.Raw("SELECT updated FROM accounts WHERE id = 1").Scan(&updated).Error)
+	assert.Equal(t, 1, updated)
+
+	// Failures report the statement and stop the script unless told otherwise
+	failing := "INSERT INTO accounts (id, name) VALUES (3, 'd');\nINSERT INTO missing VALUES (1);\nINSERT INTO accounts (id, name) VALUES (4, 'e');"
+	report, err = migrations.ExecScript(ctx, db, failing, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "statement 2 at line 2")
+	require.Len(t, report.Statements, 2)
+	assert.NotEmpty(t, report.Statements[1].Error)
+	assert.Equal(t, 1, report.Failed)
+
+	report, err = migrations.ExecScript(ctx, db, strings.Replace(failing, "(3, 'd')", "(5, 'f')", 1), &migrations.ScriptConfig{ContinueOnError: true})
+	require.Error(t, err)
+	require.Len(t, report.Statements, 3)
+	assert.Equal(t, 1, report.Failed)
+
+	var count int64
+	require.NoError(t, db.Table("accounts").Count(&count).Error)
+	assert.Equal(t, int64(5), count)
+}
+
+func TestSchemaGuard(t *testing.T) {
+	db, migrator := setupMigrator(t, nil)
+	ctx := context.Background()
+	assert.Equal(t, int64(3), migrator.ExpectedVersion())
+
+	_, err := migrations.NewSchemaGuard(migrator, "lenient", nil)
+	assert.Error(t, err)
+
+	// A partial deploy leaves the schema behind the binary
+	_, err = migrator.UpTo(ctx, 2)
+	require.NoError(t, err)
+	guard, err := migrations.NewSchemaGuard(migrator, migrations.GuardRefuse, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Use(guard))
+
+	check, err := guard.Check(ctx)
+	require.ErrorIs(t, err, migrations.ErrSchemaBehind)
+	assert.Equal(t, int64(2), check.Actual)
+	assert.Equal(t, []int64{3}, check.Pending)
+	var count int64
+	assert.ErrorIs(t, db.Table("accounts").Count(&count).Error, migrations.ErrSchemaBehind)
+
+	// The migrator still brings the schema up to date, which lifts the guard
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	_, err = guard.Check(ctx)
+	require.NoError(t, err)
+	assert.NoError(t, db.Table("accounts").Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestSchemaGuard_ReadOnly(t *testing.T) {
+	db, migrator := setupMigrator(t, nil)
+	ctx := context.Background()
+	_, err := migrator.Up(ctx)
+	require.NoError(t, err)
+
+	// An older binary does not know the latest migration
+	older, err := migrations.NewMigrator(db, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, older.Add(migrator.Migrations()[:2]...))
+
+	guard, err := migrations.NewSchemaGuard(older, migrations.GuardReadOnly, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Use(guard))
+
+	check, err := guard.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3}, check.Unknown)
+	assert.ErrorIs(t, guard.Err(), migrations.ErrSchemaAhead)
+	assert.True(t, guard.ReadOnly())
+
+	var count int64
+	require.NoError(t, db.Table("accounts").Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+	assert.ErrorIs(t, db.Exec("INSERT INTO accounts (id, name) VALUES (2, 'guest')").Error, migrations.ErrSchemaReadOnly)
+	assert.ErrorIs(t, db.Table("accounts").Where("id = ?", 1).Update("name", "admin").Error, migrations.ErrSchemaReadOnly)
+}
@@ -732,3 +732,79 @@ func TestModelFactory_EdgeCases(t *testing.T) {
 	assert.NotNil(t, model)
 	assert.NotEqual(t, uuid.Nil, model.GetID())
 }
+
+type diffAddress struct {
+	City string `json:"city"`
+	Zip  string `json:"zip"`
+}
+
+type diffCustomer struct {
+	models.BaseModel
+	Name     string            `json:"name"`
+	Age      int64             `json:"age"`
+	Nickname *string           `json:"nickname"`
+	Address  diffAddress       `json:"address"`
+	Tags     []string          `json:"tags"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Secret   string            `json:"-"`
+}
+
+func TestDiff(t *testing.T) {
+	id := uuid.New()
+	nickname := "Al"
+	old := &diffCustomer{
+		BaseModel: models.BaseModel{ID: id},
+		Name:      "Alice",
+		Age:       30,
+		Nickname:  &nickname,
+		Address:   diffAddress{City: "Berlin", Zip: "10115"},
+		Tags:      []string{"a", "b"},
+		Labels:    map[string]string{"tier/level": "gold"},
+		Secret:    "x",
+	}
+	updated := *old
+	updated.Age = 9007199254740993
+	updated.Nickname = nil
+	updated.Address.City = "Munich"
+	updated.Tags = []string{"a", "c"}
+	updated.Labels = nil
+	updated.Secret = "y"
+
+	diff, err := models.Diff(old, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/address/city", "/age", "/labels", "/nickname", "/tags/1"}, diff.Paths())
+	assert.True(t, diff.Changed("/address"))
+	assert.False(t, diff.Changed("/name"))
+	assert.Equal(t, models.FieldChange{Op: models.ChangeReplace, Path: "/address/city", Old: "Berlin", New: "Munich"}, diff.Changes[0])
+	assert.Equal(t, models.ChangeRemove, diff.Changes[2].Op)
+
+	patch, err := diff.JSONPatch()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"op": "replace", "path": "/address/city", "value": "Munich"},
+		{"op": "replace", "path": "/age", "value": 9007199254740993},
+		{"op": "remove", "path": "/labels"},
+		{"op": "replace", "path": "/nickname", "value": null},
+		{"op": "replace", "path": "/tags/1", "value": "c"}
+	]`, string(patch))
+
+	// Slices changing length are replaced, keys are escaped
+	updated = *old
+	updated.Tags = []string{"a"}
+	updated.Labels = map[string]string{"tier/level": "gold", "a~b": "c"}
+	diff, err = models.Diff(old, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.PatchOperation{
+		{Op: models.ChangeAdd, Path: "/labels/a~0b", Value: "c"},
+		{Op: models.ChangeReplace, Path: "/tags", Value: []interface{}{"a"}},
+	}, diff.Patch())
+
+	diff, err = models.Diff(old, old)
+	assert.NoError(t, err)
+	assert.True(t, diff.Empty())
+
+	diff, err = models.Diff(nil, old)
+	assert.NoError(t, err)
+	assert.True(t, diff.Changed("/name"))
+	assert.Equal(t, models.ChangeAdd, diff.Changes[0].Op)
+}
@@ -1,14 +1,19 @@
 package unit
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/seasbee/go-ormx/pkg/database"
 	"github.com/seasbee/go-ormx/pkg/logging"
 	"github.com/seasbee/go-ormx/pkg/observability"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDefaultObservabilityConfig(t *testing.T) {
@@ -921,3 +926,126 @@ func TestObservabilityManager_ExportIntervalEdgeCases(t *testing.T) {
 	assert.NoError(t, err)
 	defer manager.Stop(ctx)
 }
+
+func TestTracer_TraceparentPropagation(t *testing.T) {
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	tracer := observability.NewORMTracer(logger, true)
+
+	ctx, span := tracer.StartQuerySpan(context.Background(), "SELECT 1", "select")
+	require.NotNil(t, span)
+	assert.Len(t, string(span.TraceID), 32)
+	assert.Len(t, string(span.SpanID), 16)
+
+	carrier := map[string]string{}
+	tracer.InjectTraceContext(ctx, carrier)
+	assert.Equal(t, map[string]string{
+		"traceparent": "00-" + string(span.TraceID) + "-" + string(span.SpanID) + "-01",
+	}, carrier)
+
+	// A span started from the extracted context continues the remote trace
+	remote := tracer.ExtractTraceContext(map[string]string{"Traceparent": carrier["traceparent"]})
+	_, child := tracer.StartTransactionSpan(remote, "commit")
+	require.NotNil(t, child)
+	assert.Equal(t, span.TraceID, child.TraceID)
+	assert.Equal(t, span.SpanID, child.ParentSpanID)
+
+	// Legacy keys are still accepted
+	legacy := tracer.ExtractTraceContext(map[string]string{"trace_id": "trace-123", "span_id": "span-456"})
+	assert.Equal(t, observability.TraceID("trace-123"), tracer.GetTraceID(legacy))
+	assert.Equal(t, observability.SpanID("span-456"), tracer.GetSpanID(legacy))
+}
+
+func TestParseTraceparent(t *testing.T) {
+	traceID, spanID, ok := observability.ParseTraceparent("00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01")
+	assert.True(t, ok)
+	assert.Equal(t, observability.TraceID("4bf92f3577b34da6a3ce929d0e0e4736"), traceID)
+	assert.Equal(t, observability.SpanID("00f067aa0ba902b7"), spanID)
+
+	_, _, ok = observability.ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	assert.True(t, ok, "future versions may append fields")
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bz-01",
+	} {
+		_, _, ok := observability.ParseTraceparent(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
+
+// poolStats is a connection pool reporting fixed statistics
+type poolStats struct {
+	stats atomic.Value
+}
+
+func (p *poolStats) Stats() sql.DBStats {
+	return p.stats.Load().(sql.DBStats)
+}
+
+func TestObservabilityManager_CollectConnectionStats(t *testing.T) {
+	config := observability.DefaultObservabilityConfig()
+	config.ConnectionStatsInterval = 5 * time.Millisecond
+	manager := observability.NewObservabilityManager(config, logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{}))
+	ctx := context.Background()
+
+	pool := &poolStats{}
+	pool.stats.Store(sql.DBStats{MaxOpenConnections: 10, OpenConnections: 6, InUse: 4, Idle: 2, WaitCount: 3, WaitDuration: 1500 * time.Millisecond})
+	manager.CollectConnectionStats("orders", pool)
+	require.NoError(t, manager.Start(ctx))
+	defer manager.Stop(ctx)
+
+	value := func(name string) float64 {
+		metrics, err := manager.GetMetrics().GetMetricsByLabels(map[string]string{"pool": "orders"})
+		require.NoError(t, err)
+		for _, metric := range metrics {
+			if metric.Name == name {
+				return metric.Value
+			}
+		}
+		return -1
+	}
+	require.Eventually(t, func() bool { return value("orm_connections_open") == 6 }, time.Second, time.Millisecond)
+	assert.Equal(t, float64(4), value("orm_connections_active"))
+	assert.Equal(t, float64(2), value("orm_connections_idle"))
+	assert.Equal(t, float64(10), value("orm_connections_max"))
+	assert.Equal(t, float64(40), value("orm_connections_utilization_percent"))
+	assert.Equal(t, float64(3), value("orm_connections_wait_total"))
+	assert.Equal(t, 1.5, value("orm_connections_wait_seconds_total"))
+
+	// Later samples replace the earlier ones
+	pool.stats.Store(sql.DBStats{MaxOpenConnections: 10, OpenConnections: 9, InUse: 9})
+	require.Eventually(t, func() bool { return value("orm_connections_active") == 9 }, time.Second, time.Millisecond)
+
+	// Samples are logged
+	buf := &bytes.Buffer{}
+	logged := observability.NewObservabilityManager(observability.DefaultObservabilityConfig(), logging.NewLogger(logging.LogLevelInfo, buf, &logging.TextFormatter{}))
+	logged.RecordConnectionStats(ctx, "orders", pool.Stats())
+	assert.Contains(t, buf.String(), "Connection pool statistics")
+	assert.Contains(t, buf.String(), "in_use=9")
+}
+
+func TestConnectionManager_CollectConnectionStats(t *testing.T) {
+	cm, err := database.NewConnectionManager(createValidTestConfig())
+	require.NoError(t, err)
+	defer cm.Close()
+
+	config := observability.DefaultObservabilityConfig()
+	config.ConnectionStatsInterval = 5 * time.Millisecond
+	manager := observability.NewObservabilityManager(config, logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{}))
+	ctx := context.Background()
+
+	require.NoError(t, cm.CollectConnectionStats(manager))
+	require.NoError(t, manager.Start(ctx))
+	defer manager.Stop(ctx)
+
+	require.Eventually(t, func() bool {
+		metrics, err := manager.GetMetrics().GetMetricsByLabels(map[string]string{"pool": "primary"})
+		return err == nil && len(metrics) > 0
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,206 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type optionCompany struct {
+	models.BaseModel
+	Name string `gorm:"not null"`
+}
+
+type optionOrder struct {
+	models.BaseModel
+	OptionCustomerID uuid.UUID `gorm:"type:uuid;not null"`
+	Status           string    `gorm:"not null"`
+}
+
+type optionCustomer struct {
+	models.BaseModel
+	Name      string    `gorm:"not null"`
+	CompanyID uuid.UUID `gorm:"type:uuid"`
+	Company   optionCompany
+	Orders    []optionOrder
+}
+
+// setupOptionCustomers creates two customers of the same company with two orders each
+func setupOptionCustomers(t *testing.T) (*repository.BaseRepository[optionCustomer], *gorm.DB) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&optionCompany{}, &optionCustomer{}, &optionOrder{}))
+
+	company := optionCompany{Name: "Acme"}
+	require.NoError(t, db.Create(&company).Error)
+	for _, name := range []string{"Alice", "Bob"} {
+		customer := optionCustomer{Name: name, CompanyID: company.ID}
+		require.NoError(t, db.Omit("Company").Create(&customer).Error)
+		require.NoError(t, db.Create(&[]optionOrder{
+			{OptionCustomerID: customer.ID, Status: "open"},
+			{OptionCustomerID: customer.ID, Status: "closed"},
+		}).Error)
+	}
+
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	return repository.NewBaseRepository[optionCustomer](db, logger, repository.DefaultRepositoryConfig()), db
+}
+
+func TestFindOptions_FindFirstByID(t *testing.T) {
+	repo, db := setupOptionCustomers(t)
+	ctx := context.Background()
+
+	var alice optionCustomer
+	require.NoError(t, db.Where("name = ?", "Alice").First(&alice).Error)
+
+	// Associations are not loaded by default
+	found, err := repo.FindFirstByID(ctx, alice.ID)
+	require.NoError(t, err)
+	assert.Empty(t, found.Orders)
+	assert.Empty(t, found.Company.Name)
+
+	found, err = repo.FindFirstByID(ctx, alice.ID,
+		repository.WithPreload("Orders", "status = ?", "open"),
+		repository.WithJoins("Company"))
+	require.NoError(t, err)
+	require.Len(t, found.Orders, 1)
+	assert.Equal(t, "open", found.Orders[0].Status)
+	assert.Equal(t, "Acme", found.Company.Name)
+
+	found, err = repo.FindFirstByID(ctx, alice.ID, repository.WithSelect("id", "name"))
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", found.Name)
+	assert.Equal(t, uuid.Nil, found.CompanyID)
+
+	_, err = repo.FindFirstByID(ctx, alice.ID, repository.WithPreload("Invoices"))
+	assert.Error(t, err)
+}
+
+func TestFindOptions_ConditionFinders(t *testing.T) {
+	repo, _ := setupOptionCustomers(t)
+	ctx := context.Background()
+
+	var customer optionCustomer
+	require.NoError(t, repo.FindFirstByConditions(ctx, &customer, "option_customers.name = ?", "Bob",
+		repository.WithPreload("Orders"), repository.WithJoins("Company")))
+	assert.Equal(t, "Bob", customer.Name)
+	assert.Len(t, customer.Orders, 2)
+	assert.Equal(t, "Acme", customer.Company.Name)
+
+	// Options alone are not conditions
+	var first optionCustomer
+	require.NoError(t, repo.TakeByConditions(ctx, &first, repository.WithPreload("Orders")))
+	assert.Len(t, first.Orders, 2)
+
+	var customers []optionCustomer
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &customers, "name <> ?", "Nobody",
+		repository.WithPreload("Orders", "status = ?", "closed")))
+	require.Len(t, customers, 2)
+	for _, c := range customers {
+		require.Len(t, c.Orders, 1)
+		assert.Equal(t, "closed", c.Orders[0].Status)
+	}
+
+	customers = nil
+	require.NoError(t, repo.FindAllByConditionsWithCursor(ctx, "", 10, "next", &customers,
+		repository.WithJoins("Company"), repository.WithPreload("Orders")))
+	require.Len(t, customers, 2)
+	assert.Equal(t, "Acme", customers[1].Company.Name)
+	assert.Len(t, customers[1].Orders, 2)
+
+	// The cursor compares the customer IDs, not the joined company ones
+	customers = nil
+	require.NoError(t, repo.FindAllWithCursor(ctx, first.ID.String(), 10, "next", &customers, repository.WithJoins("Company")))
+	for _, c := range customers {
+		assert.NotEqual(t, first.ID, c.ID)
+		assert.Equal(t, "Acme", c.Company.Name)
+	}
+}
+
+func TestFindOptions_FindAll(t *testing.T) {
+	repo, _ := setupOptionCustomers(t)
+	ctx := context.Background()
+
+	var customers []optionCustomer
+	require.NoError(t, repo.FindAllWithOffset(ctx, 10, 0, &customers,
+		repository.WithPreload("Orders"), repository.WithPreload("Company")))
+	require.Len(t, customers, 2)
+	for _, c := range customers {
+		assert.Len(t, c.Orders, 2)
+		assert.Equal(t, "Acme", c.Company.Name)
+	}
+
+	batches := 0
+	var batch []optionCustomer
+	require.NoError(t, repo.FindAllInBatchesWithOffset(ctx, 10, 0, &batch, 1, func(tx *gorm.DB, _ int) error {
+		batches++
+		require.Len(t, batch, 1)
+		assert.Len(t, batch[0].Orders, 2)
+		return nil
+	}, repository.WithPreload("Orders")))
+	assert.Equal(t, 2, batches)
+
+	// Soft deleted customers keep their associations loadable
+	require.NoError(t, repo.SoftDeleteByID(ctx, customers[0].ID))
+	customers = nil
+	require.NoError(t, repo.FindAllIncludingDeleted(ctx, 10, 0, &customers, repository.WithJoins("Company")))
+	require.Len(t, customers, 2)
+	assert.Equal(t, "Acme", customers[0].Company.Name)
+}
+
+func TestFindOptions_OrderBy(t *testing.T) {
+	db := setupTestDB(t)
+	config := repository.DefaultRepositoryConfig()
+	repo := repository.NewBaseRepository[TestEntity](db, logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{}), config)
+	ctx := context.Background()
+
+	for _, entity := range []TestEntity{{Name: "bob", Age: 30}, {Name: "ann", Age: 30}, {Name: "cid", Age: 20}, {Name: "ann", Age: 40}} {
+		require.NoError(t, repo.Create(ctx, &entity))
+	}
+	names := func(entities []TestEntity) []string {
+		var result []string
+		for _, e := range entities {
+			result = append(result, e.Name)
+		}
+		return result
+	}
+
+	var page []TestEntity
+	require.NoError(t, repo.FindAllWithOffset(ctx, 10, 0, &page, repository.WithOrderBy("age DESC, name")))
+	assert.Equal(t, []string{"ann", "ann", "bob", "cid"}, names(page))
+	assert.Equal(t, 40, page[0].Age)
+
+	// Ties are broken by primary key, so pages don't overlap
+	var first, second []TestEntity
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 2, 0, &first, "age >= ?", 20, repository.WithOrderBy("name")))
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 2, 2, &second, "age >= ?", 20, repository.WithOrderBy("name")))
+	assert.Equal(t, []string{"ann", "ann", "bob", "cid"}, append(names(first), names(second)...))
+	assert.NotEqual(t, first[0].ID, first[1].ID)
+
+	var youngest TestEntity
+	require.NoError(t, repo.FindFirstByConditions(ctx, &youngest, repository.WithOrderBy("age asc")))
+	assert.Equal(t, "cid", youngest.Name)
+
+	// Unknown columns, directions and injections are rejected
+	for _, spec := range []string{"missing", "name sideways", "name; DROP TABLE test_entities", "name asc, (select 1)", ""} {
+		err := repo.FindAllWithOffset(ctx, 10, 0, &page, repository.WithOrderBy(spec))
+		assert.True(t, errors.IsType(err, errors.ErrorTypeValidation), spec)
+	}
+
+	// Finders paging by primary key refuse other orders
+	err := repo.FindAllWithCursor(ctx, "", 10, "next", &page, repository.WithOrderBy("name"))
+	assert.True(t, errors.IsType(err, errors.ErrorTypeValidation))
+
+	// The allow-list restricts the sortable columns
+	config.SortableColumns = []string{"name"}
+	require.NoError(t, repo.FindAllWithOffset(ctx, 10, 0, &page, repository.WithOrderBy("name")))
+	err = repo.FindAllWithOffset(ctx, 10, 0, &page, repository.WithOrderBy("age"))
+	assert.True(t, errors.IsType(err, errors.ErrorTypeValidation))
+}
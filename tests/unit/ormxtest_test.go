@@ -0,0 +1,51 @@
+package unit
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/ormxtest"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestWithTx(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "ormxtest.db")), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&TestEntity{}))
+	ctx := context.Background()
+
+	count := func(db *gorm.DB) int64 {
+		var n int64
+		require.NoError(t, db.Model(&TestEntity{}).Count(&n).Error)
+		return n
+	}
+
+	t.Run("outer", func(t *testing.T) {
+		tx := ormxtest.WithTx(t, db)
+		repo := ormxtest.Repository[TestEntity](tx)
+		require.NoError(t, repo.Create(ctx, &TestEntity{Name: "Alice", Age: 30}))
+
+		t.Run("nested", func(t *testing.T) {
+			nested := tx.Nested(t)
+			repo := ormxtest.Repository[TestEntity](nested)
+			require.NoError(t, repo.Create(ctx, &TestEntity{Name: "Bob", Age: 40}))
+
+			// Repository transactions nest within the test transaction
+			require.NoError(t, repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+				return tx.Create(ctx, &TestEntity{Name: "Carol", Age: 50})
+			}))
+			assert.Equal(t, int64(3), count(nested.DB))
+		})
+
+		// The nested writes are rolled back after the subtest
+		assert.Equal(t, int64(1), count(tx.DB))
+	})
+
+	// Nothing outlives the test
+	assert.Equal(t, int64(0), count(db))
+}
@@ -0,0 +1,174 @@
+package unit
+
+import (
+	"context"
+	stderrors "errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/observability"
+	"github.com/seasbee/go-ormx/pkg/outbox"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// recordingPublisher records the events delivered to it, failing while fail is set
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []outbox.Event
+	fail   error
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event *outbox.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fail != nil {
+		return p.fail
+	}
+	p.events = append(p.events, *event)
+	return nil
+}
+
+func setupOutbox(t *testing.T, db *gorm.DB) (*outbox.OutboxPublisher, *repository.BaseRepository[TestEntity]) {
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	events, err := outbox.NewOutboxPublisher(db, logger, nil)
+	require.NoError(t, err)
+	repo := repository.NewBaseRepository[TestEntity](db, logger, repository.DefaultRepositoryConfig())
+	return events, outbox.Track(events, repo)
+}
+
+func TestOutbox_Track(t *testing.T) {
+	db := setupTestDB(t)
+	events, repo := setupOutbox(t, db)
+	ctx := context.Background()
+
+	// Events roll back with the transaction of the write
+	failed := stderrors.New("abort")
+	err := repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		require.NoError(t, tx.Create(ctx, &TestEntity{Name: "Ghost", Age: 1}))
+		return failed
+	})
+	require.ErrorIs(t, err, failed)
+	pending, err := events.Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), pending)
+
+	// Committed writes record their events with the trace context of the write
+	traced := context.WithValue(ctx, "trace_id", observability.TraceID("4bf92f3577b34da6a3ce929d0e0e4736"))
+	traced = context.WithValue(traced, "span_id", observability.SpanID("00f067aa0ba902b7"))
+	entity := &TestEntity{Name: "Alice", Age: 30}
+	require.NoError(t, repo.Create(traced, entity))
+	require.NoError(t, repo.DeleteByID(ctx, entity.ID))
+
+	var recorded []outbox.Event
+	require.NoError(t, db.Order("id").Find(&recorded).Error)
+	require.Len(t, recorded, 2)
+	assert.Equal(t, "test_entities", recorded[0].EntityType)
+	assert.Equal(t, entity.ID, recorded[0].EntityID)
+	assert.Equal(t, repository.ChangeCreate, recorded[0].Operation)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", recorded[0].Carrier()[observability.TraceparentHeader])
+	var payload TestEntity
+	require.NoError(t, recorded[0].Decode(&payload))
+	assert.Equal(t, "Alice", payload.Name)
+
+	assert.Equal(t, repository.ChangeDelete, recorded[1].Operation)
+	assert.Empty(t, recorded[1].Carrier())
+	require.NoError(t, recorded[1].Decode(&payload))
+	assert.Equal(t, entity.ID, payload.ID, "deletes carry the deleted entity")
+
+	// Events are validated
+	require.Error(t, events.Publish(ctx, nil, &outbox.Event{Operation: "custom"}))
+}
+
+func TestOutbox_Relay(t *testing.T) {
+	db := setupTestDB(t)
+	events, repo := setupOutbox(t, db)
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, &TestEntity{Name: "Alice", Age: 30}))
+	require.NoError(t, repo.Create(ctx, &TestEntity{Name: "Bob", Age: 40}))
+	require.NoError(t, events.Publish(ctx, nil, &outbox.Event{EntityType: "invoices", Operation: "sent", Payload: `{"number":1}`}))
+
+	publisher := &recordingPublisher{fail: stderrors.New("broker unavailable")}
+	relay := outbox.NewRelay(events, publisher, nil, &outbox.RelayConfig{RetryDelay: time.Hour})
+
+	// Failed deliveries stay in the outbox until their retry is due
+	published, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, published)
+	var failed []outbox.Event
+	require.NoError(t, db.Find(&failed).Error)
+	require.Len(t, failed, 3)
+	for _, event := range failed {
+		assert.Equal(t, 1, event.Attempts)
+		assert.Equal(t, "broker unavailable", event.LastError)
+		assert.Nil(t, event.PublishedAt)
+		assert.Empty(t, event.ClaimedBy, "claims are released")
+		assert.True(t, event.NextAttemptAt.After(time.Now().Add(59*time.Minute)))
+	}
+	published, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, published)
+
+	// Due events are delivered in order, once
+	publisher.fail = nil
+	require.NoError(t, db.Model(&outbox.Event{}).Where("1 = 1").Update("next_attempt_at", time.Now().Add(-time.Second)).Error)
+	published, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, published)
+	require.Len(t, publisher.events, 3)
+	assert.Equal(t, "create", publisher.events[0].Operation)
+	assert.Equal(t, "sent", publisher.events[2].Operation)
+	published, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, published)
+
+	pending, err := events.Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), pending)
+	purged, err := events.Purge(ctx, -time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
+}
+
+func TestOutbox_RelayClaims(t *testing.T) {
+	// Relays run on separate connections, which would each open their own
+	// in-memory database
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "outbox.db")), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&TestEntity{}))
+	events, repo := setupOutbox(t, db)
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, &TestEntity{Name: "Alice", Age: 30}))
+
+	// Events claimed by a relay are not delivered by another
+	other := outbox.NewRelay(events, &recordingPublisher{}, nil, &outbox.RelayConfig{Worker: "other"})
+	var concurrent int
+	relay := outbox.NewRelay(events, outbox.PublisherFunc(func(ctx context.Context, event *outbox.Event) error {
+		var err error
+		concurrent, err = other.RelayOnce(ctx)
+		return err
+	}), nil, &outbox.RelayConfig{Worker: "relay"})
+	published, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, 0, concurrent)
+
+	// Expired claims, left by a relay stopped mid-delivery, are delivered again
+	require.NoError(t, repo.Create(ctx, &TestEntity{Name: "Bob", Age: 40}))
+	require.NoError(t, db.Model(&outbox.Event{}).Where("published_at IS NULL").
+		Updates(map[string]interface{}{"claimed_by": "crashed", "claimed_until": time.Now().Add(time.Minute)}).Error)
+	published, err = other.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, published)
+	require.NoError(t, db.Model(&outbox.Event{}).Where("published_at IS NULL").
+		Update("claimed_until", time.Now().Add(-time.Second)).Error)
+	published, err = other.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+}
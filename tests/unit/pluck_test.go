@@ -0,0 +1,58 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_Pluck(t *testing.T) {
+	config := repository.DefaultRepositoryConfig()
+	config.MaxLimit = 5
+	config.MaxResultSize = 40
+	base := repository.NewBaseRepository[TestEntity](setupTestDB(t), logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{}), config)
+
+	for name, repo := range map[string]repository.Repository[TestEntity]{
+		"base":   base,
+		"memory": repository.NewMemoryRepository[TestEntity](config),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for i, name := range []string{"ann", "bob", "ann", "cid"} {
+				require.NoError(t, repo.Create(ctx, &TestEntity{Name: name, Age: 20 + i}))
+			}
+
+			var ages []int
+			require.NoError(t, repo.PluckByConditions(ctx, "age", &ages, "name = ?", "ann"))
+			assert.ElementsMatch(t, []int{20, 22}, ages)
+
+			var names []string
+			require.NoError(t, repo.DistinctPluckByConditions(ctx, "name", &names))
+			assert.ElementsMatch(t, []string{"ann", "bob", "cid"}, names)
+
+			// Results above MaxLimit fail instead of being truncated
+			for i := 0; i < 2; i++ {
+				require.NoError(t, repo.Create(ctx, &TestEntity{Name: fmt.Sprintf("extra-%d", i), Age: 1}))
+			}
+			err := repo.PluckByConditions(ctx, "age", &ages)
+			require.Error(t, err)
+			assert.True(t, errors.IsType(err, errors.ErrorTypeResource))
+			require.NoError(t, repo.DistinctPluckByConditions(ctx, "age", &ages, "age > ?", 1))
+
+			// So do results above MaxResultSize
+			var ids []string
+			err = repo.PluckByConditions(ctx, "id", &ids, "age > ?", 20)
+			require.Error(t, err)
+			assert.True(t, errors.IsType(err, errors.ErrorTypeResource))
+
+			assert.Error(t, repo.PluckByConditions(ctx, "name; DROP TABLE test_entities", &names))
+			assert.Error(t, repo.PluckByConditions(ctx, "name", names))
+		})
+	}
+}
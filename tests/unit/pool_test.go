@@ -0,0 +1,219 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/config"
+	"github.com/seasbee/go-ormx/pkg/database"
+	ormerrors "github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/observability"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// poolTestConfig returns a valid configuration of a SQLite database file
+func poolTestConfig(t *testing.T) *config.DatabaseConfig {
+	cfg := createValidTestConfig()
+	cfg.Database = filepath.Join(t.TempDir(), "pool.db")
+	return cfg
+}
+
+// openTestPool opens a pool migrated for test entities, closed with the test
+func openTestPool(t *testing.T, cfg *config.DatabaseConfig) *database.Pool {
+	pool, err := database.Open(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = pool.Close() })
+	require.NoError(t, pool.DB().AutoMigrate(&TestEntity{}))
+	return pool
+}
+
+func TestOpen_AppliesPoolSettings(t *testing.T) {
+	cfg := poolTestConfig(t)
+	cfg.MaxConnections = 8
+	cfg.MinConnections = 3
+	cfg.MaxIdleConnections = 4
+
+	pool, err := database.Open(cfg)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	stats := pool.Stats()
+	assert.Equal(t, 8, stats.MaxOpenConnections)
+	assert.Equal(t, 3, stats.MinConnections)
+	// The minimum connections are established up front
+	assert.Equal(t, 3, stats.OpenConnections)
+	assert.Equal(t, 3, stats.Idle)
+
+	// The pooled connection supports repositories and transactions
+	require.NoError(t, pool.DB().AutoMigrate(&TestEntity{}))
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	repo := repository.NewBaseRepository[TestEntity](pool.DB(), logger, repository.DefaultRepositoryConfig())
+	ctx := context.Background()
+
+	require.NoError(t, repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		return tx.Create(ctx, &TestEntity{Name: "Alice", Age: 30})
+	}))
+	assert.Error(t, repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		require.NoError(t, tx.Create(ctx, &TestEntity{Name: "Bob", Age: 40}))
+		return errors.New("rollback")
+	}))
+
+	count, err := repo.CountByConditions(ctx, "age > ?", 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	// Query connections return to the pool once their rows are closed
+	assert.Eventually(t, func() bool { return pool.Stats().InUse == 0 }, time.Second, time.Millisecond)
+
+	sqlDB, err := pool.DB().DB()
+	require.NoError(t, err)
+	assert.NoError(t, sqlDB.Ping())
+}
+
+func TestOpen_InvalidConfig(t *testing.T) {
+	_, err := database.Open(nil)
+	assert.Error(t, err)
+
+	cfg := poolTestConfig(t)
+	cfg.MinConnections = cfg.MaxConnections + 1
+	_, err = database.Open(cfg)
+	assert.Error(t, err)
+}
+
+func TestPool_AcquireTimeout(t *testing.T) {
+	cfg := poolTestConfig(t)
+	cfg.MaxConnections = 1
+	cfg.MinConnections = 0
+	cfg.MaxIdleConnections = 1
+	cfg.AcquireTimeout = 50 * time.Millisecond
+	pool := openTestPool(t, cfg)
+
+	tx := pool.DB().Begin()
+	require.NoError(t, tx.Error)
+
+	var entities []TestEntity
+	start := time.Now()
+	err := pool.DB().Find(&entities).Error
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, database.ErrAcquireTimeout))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int64(1), pool.Stats().AcquireTimeouts)
+
+	// A context expiring first is reported as such
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = pool.DB().WithContext(ctx).Find(&entities).Error
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, int64(1), pool.Stats().AcquireTimeouts)
+
+	// Ending the transaction returns its connection
+	require.NoError(t, tx.Rollback().Error)
+	require.NoError(t, pool.DB().Find(&entities).Error)
+}
+
+func TestPool_Exhausted(t *testing.T) {
+	cfg := poolTestConfig(t)
+	cfg.MaxConnections = 1
+	cfg.MinConnections = 0
+	cfg.MaxIdleConnections = 1
+	cfg.AcquireTimeout = 30 * time.Millisecond
+	pool := openTestPool(t, cfg)
+
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	manager := observability.NewObservabilityManager(observability.DefaultObservabilityConfig(), logger)
+	require.NoError(t, pool.Observe(manager, time.Hour))
+
+	var reported []*ormerrors.ORMError
+	pool.OnExhausted(func(ctx context.Context, err *ormerrors.ORMError) {
+		reported = append(reported, err)
+	})
+
+	tx := pool.DB().Begin()
+	require.NoError(t, tx.Error)
+	defer tx.Rollback()
+
+	var entities []TestEntity
+	err := pool.DB().Find(&entities).Error
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, database.ErrAcquireTimeout))
+
+	// The error is a resource error carrying the pool statistics
+	var ormErr *ormerrors.ORMError
+	require.True(t, errors.As(err, &ormErr))
+	assert.Equal(t, ormerrors.ErrorTypeResource, ormErr.Type)
+	assert.Equal(t, ormerrors.ErrorSeverityHigh, ormErr.Severity)
+	assert.Equal(t, "query", ormErr.Operation)
+	assert.Equal(t, cfg.Database, ormErr.Context["pool"])
+	assert.Equal(t, 1, ormErr.Context["max_open"])
+	assert.Equal(t, 1, ormErr.Context["in_use"])
+	assert.Equal(t, int64(1), ormErr.Context["acquire_timeouts"])
+
+	// The classifier tells it apart from connection failures
+	classified := ormerrors.NewErrorClassifier().ClassifyError(errors.Unwrap(ormErr), "find")
+	assert.Equal(t, ormerrors.ErrorTypeResource, classified.Type)
+
+	require.Len(t, reported, 1)
+	assert.Same(t, ormErr, reported[0])
+	assert.Equal(t, 1.0, timeoutSeries(t, manager, "orm_pool_exhausted_total", map[string]string{"pool": cfg.Database, "operation": "query"}))
+}
+
+func TestPool_LeakDetection(t *testing.T) {
+	cfg := poolTestConfig(t)
+	cfg.LeakDetection = true
+	cfg.LeakTimeout = 30 * time.Millisecond
+	pool := openTestPool(t, cfg)
+
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	manager := observability.NewObservabilityManager(observability.DefaultObservabilityConfig(), logger)
+
+	// Statements returning their connection are not leaks
+	require.NoError(t, pool.DB().Create(&TestEntity{Name: "Alice", Age: 30}).Error)
+
+	rows, err := pool.DB().Model(&TestEntity{}).Rows()
+	require.NoError(t, err)
+	time.Sleep(60 * time.Millisecond)
+
+	leaks := pool.Leaks()
+	require.Len(t, leaks, 1)
+	assert.Equal(t, "query", leaks[0].Operation)
+	assert.GreaterOrEqual(t, leaks[0].Held, 30*time.Millisecond)
+	assert.Contains(t, leaks[0].Stack, "TestPool_LeakDetection")
+
+	pool.RecordStats(context.Background(), manager)
+	pool.RecordStats(context.Background(), manager)
+	pool.RecordStats(context.Background(), manager)
+	assert.Equal(t, 1.0, timeoutSeries(t, manager, "orm_pool_leaks_total", map[string]string{"operation": "query"}))
+	assert.Equal(t, 1.0, timeoutSeries(t, manager, "orm_pool_connections_leaked", nil))
+	assert.Equal(t, int64(1), pool.Stats().LeaksDetected)
+
+	require.NoError(t, rows.Close())
+	assert.Eventually(t, func() bool { return len(pool.Leaks()) == 0 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 0, pool.Stats().LeakedConnections)
+	assert.Equal(t, int64(1), pool.Stats().LeaksDetected)
+}
+
+func TestPool_Observe(t *testing.T) {
+	cfg := poolTestConfig(t)
+	pool := openTestPool(t, cfg)
+
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	manager := observability.NewObservabilityManager(observability.DefaultObservabilityConfig(), logger)
+
+	assert.Error(t, pool.Observe(nil, time.Millisecond))
+	require.NoError(t, pool.Observe(manager, 5*time.Millisecond))
+	assert.Error(t, pool.Observe(manager, 5*time.Millisecond))
+
+	assert.Eventually(t, func() bool {
+		return timeoutSeries(t, manager, "orm_pool_connections_max", map[string]string{"pool": cfg.Database}) == float64(cfg.MaxConnections)
+	}, time.Second, 5*time.Millisecond)
+	assert.GreaterOrEqual(t, timeoutSeries(t, manager, "orm_pool_connections_open", nil), float64(cfg.MinConnections))
+
+	require.NoError(t, pool.Close())
+	require.NoError(t, pool.Close())
+	assert.Error(t, pool.Observe(manager, time.Millisecond))
+}
@@ -0,0 +1,136 @@
+package unit
+
+import (
+	"context"
+	stderrors "errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type propagationEvent struct {
+	models.BaseModel
+	Kind string `gorm:"not null"`
+}
+
+// setupPropagation returns repositories of two entities sharing a database
+// file, so independent transactions use separate connections
+func setupPropagation(t *testing.T) (*repository.BaseRepository[TestEntity], *repository.BaseRepository[propagationEvent], *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "tx.db")), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&TestEntity{}, &propagationEvent{}))
+
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	config := repository.DefaultRepositoryConfig()
+	return repository.NewBaseRepository[TestEntity](db, logger, config),
+		repository.NewBaseRepository[propagationEvent](db, logger, config), db
+}
+
+func countRows(t *testing.T, db *gorm.DB, model interface{}) int64 {
+	var count int64
+	require.NoError(t, db.Model(model).Count(&count).Error)
+	return count
+}
+
+func TestWithTransactionOpts_Required(t *testing.T) {
+	entities, events, db := setupPropagation(t)
+	ctx := context.Background()
+	failure := stderrors.New("checkout failed")
+
+	err := entities.WithTransactionOpts(ctx, repository.TxOptions{}, func(ctx context.Context, tx repository.Repository[TestEntity]) error {
+		outer, ok := repository.TxFromContext(ctx)
+		require.True(t, ok)
+		require.NoError(t, tx.Create(ctx, &TestEntity{Name: "Alice", Age: 30}))
+
+		return events.WithTransactionOpts(ctx, repository.TxOptions{Propagation: repository.PropagationRequired}, func(ctx context.Context, etx repository.Repository[propagationEvent]) error {
+			inner, _ := repository.TxFromContext(ctx)
+			assert.Same(t, outer, inner, "joins the transaction in progress")
+			require.NoError(t, etx.Create(ctx, &propagationEvent{Kind: "created"}))
+			return failure
+		})
+	})
+	assert.ErrorIs(t, err, failure)
+	assert.Zero(t, countRows(t, db, &TestEntity{}), "the joined failure rolls back the whole transaction")
+	assert.Zero(t, countRows(t, db, &propagationEvent{}))
+
+	// Repositories bound to a transaction join it too
+	err = entities.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		require.NoError(t, tx.Create(ctx, &TestEntity{Name: "Bob", Age: 40}))
+		return tx.WithTransactionOpts(ctx, repository.TxOptions{}, func(ctx context.Context, inner repository.Repository[TestEntity]) error {
+			return inner.Create(ctx, &TestEntity{Name: "Carol", Age: 50})
+		})
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), countRows(t, db, &TestEntity{}))
+
+	// Without a transaction in progress one is begun
+	_, ok := repository.TxFromContext(ctx)
+	assert.False(t, ok)
+	require.NoError(t, events.WithTransactionOpts(ctx, repository.TxOptions{}, func(ctx context.Context, tx repository.Repository[propagationEvent]) error {
+		return tx.Create(ctx, &propagationEvent{Kind: "standalone"})
+	}))
+	assert.Equal(t, int64(1), countRows(t, db, &propagationEvent{}))
+}
+
+func TestWithTransactionOpts_Nested(t *testing.T) {
+	entities, events, db := setupPropagation(t)
+	ctx := context.Background()
+
+	var order []string
+	err := entities.WithTransactionOpts(ctx, repository.TxOptions{}, func(ctx context.Context, tx repository.Repository[TestEntity]) error {
+		require.NoError(t, tx.Create(ctx, &TestEntity{Name: "Alice", Age: 30}))
+
+		err := events.WithTransactionOpts(ctx, repository.TxOptions{Propagation: repository.PropagationNested}, func(ctx context.Context, etx repository.Repository[propagationEvent]) error {
+			require.NoError(t, etx.Create(ctx, &propagationEvent{Kind: "discarded"}))
+			etx.AfterRollback(ctx, func(context.Context) { order = append(order, "nested rolled back") })
+			return stderrors.New("step failed")
+		})
+		assert.Error(t, err)
+
+		return events.WithTransactionOpts(ctx, repository.TxOptions{Propagation: repository.PropagationNested}, func(ctx context.Context, etx repository.Repository[propagationEvent]) error {
+			etx.AfterCommit(ctx, func(context.Context) { order = append(order, "committed") })
+			return etx.Create(ctx, &propagationEvent{Kind: "kept"})
+		})
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1), countRows(t, db, &TestEntity{}))
+	var kinds []string
+	require.NoError(t, db.Model(&propagationEvent{}).Pluck("kind", &kinds).Error)
+	assert.Equal(t, []string{"kept"}, kinds)
+	assert.Equal(t, []string{"nested rolled back", "committed"}, order, "nested commit hooks wait for the outer commit")
+}
+
+func TestWithTransactionOpts_RequiresNew(t *testing.T) {
+	entities, events, db := setupPropagation(t)
+	ctx := context.Background()
+
+	err := entities.WithTransactionOpts(ctx, repository.TxOptions{}, func(ctx context.Context, tx repository.Repository[TestEntity]) error {
+		outer, _ := repository.TxFromContext(ctx)
+		err := events.WithTransactionOpts(ctx, repository.TxOptions{Propagation: repository.PropagationRequiresNew}, func(ctx context.Context, etx repository.Repository[propagationEvent]) error {
+			inner, _ := repository.TxFromContext(ctx)
+			assert.NotSame(t, outer, inner)
+			return etx.Create(ctx, &propagationEvent{Kind: "attempted"})
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, tx.Create(ctx, &TestEntity{Name: "Alice", Age: 30}))
+		return stderrors.New("payment declined")
+	})
+	assert.Error(t, err)
+
+	assert.Zero(t, countRows(t, db, &TestEntity{}))
+	assert.Equal(t, int64(1), countRows(t, db, &propagationEvent{}), "the independent transaction committed")
+
+	err = events.WithTransactionOpts(ctx, repository.TxOptions{Propagation: "mandatory"}, func(context.Context, repository.Repository[propagationEvent]) error {
+		return nil
+	})
+	assert.Error(t, err)
+}
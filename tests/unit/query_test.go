@@ -0,0 +1,112 @@
+package unit
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type queryAuthor struct {
+	ID    uint   `gorm:"primaryKey"`
+	Name  string `gorm:"not null"`
+	Books []queryBook
+}
+
+type queryBook struct {
+	ID            uint   `gorm:"primaryKey"`
+	QueryAuthorID uint   `gorm:"not null"`
+	Title         string `gorm:"not null"`
+}
+
+func TestQuery_Find(t *testing.T) {
+	repo, _ := setupTestRepository(t)
+	ctx := context.Background()
+
+	for i := 0; i < 6; i++ {
+		require.NoError(t, repo.Create(ctx, &TestEntity{Name: fmt.Sprintf("user-%d", i), Age: 20 + i}))
+	}
+
+	adults := repo.Query().Where("age >= ?", 22)
+
+	found, err := adults.OrderByDesc("age").Limit(2).Find(ctx)
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+	assert.Equal(t, 25, found[0].Age)
+	assert.Equal(t, 24, found[1].Age)
+
+	// The base query is not modified by derived ones
+	found, err = adults.OrderBy("Age").Offset(1).Find(ctx)
+	require.NoError(t, err)
+	require.Len(t, found, 3)
+	assert.Equal(t, 23, found[0].Age)
+
+	count, err := adults.Or("name = ?", "user-0").Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), count)
+
+	count, err = adults.Not("name = ?", "user-5").Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	first, err := adults.OrderBy("age").First(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 22, first.Age)
+
+	_, err = repo.Query().Where("age > ?", 100).First(ctx)
+	assert.True(t, stderrors.Is(err, gorm.ErrRecordNotFound))
+
+	exists, err := adults.Where("name = ?", "user-1").Exists(ctx)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	exists, err = adults.Exists(ctx)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// Limits are normalized by the repository pagination settings
+	all, err := repo.Query().Limit(10000).Find(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 6)
+
+	snapshot := repo.GetMetrics()
+	assert.Equal(t, int64(3), snapshot.Operations["Query.Find"].SuccessfulOperations)
+	assert.Contains(t, snapshot.Operations, "Query.Count")
+}
+
+func TestQuery_InvalidNames(t *testing.T) {
+	repo, _ := setupTestRepository(t)
+	ctx := context.Background()
+
+	_, err := repo.Query().OrderBy("age; DROP TABLE test_entities").Find(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown column")
+
+	// The first invalid name is reported, later valid calls keep the error
+	_, err = repo.Query().Preload("Orders").OrderBy("age").Count(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown association")
+}
+
+func TestQuery_Preload(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&queryAuthor{}, &queryBook{}))
+
+	authors := repository.NewBaseRepository[queryAuthor](db, logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{}), nil)
+	ctx := context.Background()
+
+	author := &queryAuthor{Name: "author", Books: []queryBook{{Title: "first"}, {Title: "second"}}}
+	require.NoError(t, db.Create(author).Error)
+
+	found, err := authors.Query().Preload("Books", "title = ?", "second").Find(ctx)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	require.Len(t, found[0].Books, 1)
+	assert.Equal(t, "second", found[0].Books[0].Title)
+}
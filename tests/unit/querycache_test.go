@@ -0,0 +1,180 @@
+package unit
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// cachedTag is a tag of a TestEntity, joined by the cached queries
+type cachedTag struct {
+	ID       uuid.UUID `gorm:"type:uuid;primaryKey"`
+	EntityID uuid.UUID `gorm:"type:uuid"`
+	Label    string
+}
+
+func TestQueryCache(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	require.NoError(t, db.AutoMigrate(&cachedTag{}))
+	cache := repository.NewQueryCache(nil)
+	require.NoError(t, db.Use(cache))
+	repo.WithQueryCache(cache)
+	ctx := context.Background()
+
+	adult := &TestEntity{Name: "Adult", Age: 40}
+	require.NoError(t, repo.Create(ctx, adult))
+	require.NoError(t, repo.Create(ctx, &TestEntity{Name: "Child", Age: 8}))
+
+	var first, second []TestEntity
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &first, "age > ?", 18))
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &second, "age > ?", 18))
+	require.Len(t, second, 1)
+	stats := cache.Stats()
+	assert.Equal(t, int64(1), stats.Hits)
+	assert.Equal(t, int64(1), stats.Misses)
+	assert.Equal(t, 1, stats.Entries)
+
+	// Results are copied, so callers cannot alter the cached ones
+	second[0].Name = "Altered"
+	var third []TestEntity
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &third, "age > ?", 18))
+	assert.Equal(t, "Adult", third[0].Name)
+
+	// Other parameters are another query
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &third, "age > ?", 1))
+	assert.Len(t, third, 2)
+	assert.Equal(t, int64(2), cache.Stats().Misses)
+
+	// A write through the repository evicts the queries of its table
+	require.NoError(t, repo.Create(ctx, &TestEntity{Name: "Senior", Age: 70}))
+	assert.Zero(t, cache.Stats().Entries)
+	assert.Equal(t, int64(2), cache.Stats().Invalidations)
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &third, "age > ?", 18))
+	assert.Len(t, third, 2)
+
+	// Joined tables invalidate the queries reading them, even when written
+	// with plain gorm
+	require.NoError(t, db.Create(&cachedTag{ID: uuid.New(), EntityID: adult.ID, Label: "vip"}).Error)
+	var labels []string
+	query := func() {
+		require.NoError(t, db.Table("test_entities").Joins("JOIN cached_tags ON cached_tags.entity_id = test_entities.id").
+			Where("test_entities.age > ?", 18).Pluck("cached_tags.label", &labels).Error)
+	}
+	query()
+	query()
+	assert.Equal(t, []string{"vip"}, labels)
+	hits := cache.Stats().Hits
+	require.NoError(t, db.Exec("UPDATE cached_tags SET label = ?", "gold").Error)
+	query()
+	assert.Equal(t, []string{"gold"}, labels)
+	assert.Equal(t, hits, cache.Stats().Hits)
+
+	// Transactions and bypassing contexts do not use the cache
+	misses := cache.Stats().Misses
+	require.NoError(t, repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		var inTx []TestEntity
+		return tx.FindAllByConditionsWithOffset(ctx, 10, 0, &inTx, "age > ?", 18)
+	}))
+	require.NoError(t, repo.FindAllByConditionsWithOffset(repository.WithoutQueryCache(ctx), 10, 0, &third, "age > ?", 18))
+	assert.Equal(t, misses, cache.Stats().Misses)
+	assert.Equal(t, hits, cache.Stats().Hits)
+}
+
+func TestQueryCache_Eviction(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	cache := repository.NewQueryCache(&repository.QueryCacheConfig{MaxEntries: 1})
+	require.NoError(t, db.Use(cache))
+	ctx := context.Background()
+
+	count, err := repo.CountByConditions(ctx, "age > ?", 1)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+	_, err = repo.CountByConditions(ctx, "age > ?", 2)
+	require.NoError(t, err)
+
+	stats := cache.Stats()
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, int64(1), stats.Evictions)
+
+	cache.Clear()
+	assert.Zero(t, cache.Stats().Entries)
+}
+
+func TestQueryCache_PointerParameters(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	cache := repository.NewQueryCache(nil)
+	require.NoError(t, db.Use(cache))
+	ctx := context.Background()
+
+	adult := &TestEntity{Name: "Adult", Age: 40}
+	require.NoError(t, repo.Create(ctx, adult))
+	require.NoError(t, repo.Create(ctx, &TestEntity{Name: "Child", Age: 8}))
+
+	// Reusing a pointer with a new value is another query
+	minAge := 18
+	var found []TestEntity
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &found, "age > ?", &minAge))
+	assert.Len(t, found, 1)
+	minAge = 1
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &found, "age > ?", &minAge))
+	assert.Len(t, found, 2)
+	assert.Zero(t, cache.Stats().Hits)
+
+	// Pointers to the same value, and the value itself, are the same query
+	same := 1
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &found, "age > ?", &same))
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &found, "age > ?", 1))
+	assert.Len(t, found, 2)
+	assert.Equal(t, int64(2), cache.Stats().Hits)
+
+	// Valuers are keyed by their value
+	id := adult.ID
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &found, "id = ?", &id))
+	require.Len(t, found, 1)
+	id = uuid.New()
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &found, "id = ?", &id))
+	assert.Empty(t, found)
+	assert.Equal(t, int64(2), cache.Stats().Hits)
+}
+
+func TestQueryCache_InvalidatesSchemaTableOnCommit(t *testing.T) {
+	// A file database, so the read outside the transaction runs while it is open
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "widgets.db")), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&plainWidget{}))
+	cache := repository.NewQueryCache(nil)
+	require.NoError(t, db.Use(cache))
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	widgets := repository.NewBaseRepository[plainWidget](db, logger, nil).WithQueryCache(cache)
+	ctx := context.Background()
+
+	widget := &plainWidget{Name: "gear"}
+	require.NoError(t, widgets.Create(ctx, widget))
+
+	var invalidations int64
+	require.NoError(t, widgets.WithTransaction(ctx, func(tx repository.Repository[plainWidget]) error {
+		widget.Name = "cog"
+		if err := tx.Update(ctx, widget); err != nil {
+			return err
+		}
+		// Caches the committed row while the update is pending
+		found, err := widgets.FindFirstByID(ctx, widget.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "gear", found.Name)
+		invalidations = cache.Stats().Invalidations
+		return nil
+	}))
+	assert.Greater(t, cache.Stats().Invalidations, invalidations, "the commit invalidates plain_widgets")
+
+	found, err := widgets.FindFirstByID(ctx, widget.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "cog", found.Name)
+}
@@ -0,0 +1,122 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/observability"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaseRepository_SlowQueryLog(t *testing.T) {
+	db := setupTestDB(t)
+	buf := &bytes.Buffer{}
+	logger := logging.NewLogger(logging.LogLevelInfo, buf, &logging.TextFormatter{})
+	ctx := context.Background()
+
+	repo := repository.NewBaseRepository[TestEntity](db, logger, nil)
+	require.NoError(t, repo.Create(ctx, &TestEntity{Name: "Alice", Age: 30}))
+	assert.NotContains(t, buf.String(), "Slow query detected")
+
+	config := repository.DefaultRepositoryConfig()
+	config.SlowQueryThreshold = time.Nanosecond
+	repo = repository.NewBaseRepository[TestEntity](db, logger, config)
+
+	var entities []TestEntity
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &entities, "age > ?", 20))
+	assert.Contains(t, buf.String(), "Slow query detected")
+	assert.Contains(t, buf.String(), "summary=SELECT test_entities")
+	assert.Contains(t, buf.String(), "table=test_entities")
+	assert.Contains(t, buf.String(), "age > 20, query=SELECT")
+
+	// Statements within transactions are observed too
+	buf.Reset()
+	require.NoError(t, repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		return tx.Create(ctx, &TestEntity{Name: "Bob", Age: 40})
+	}))
+	assert.Contains(t, buf.String(), "summary=INSERT test_entities")
+	assert.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("summary=INSERT")))
+}
+
+func TestBaseRepository_WithObservability(t *testing.T) {
+	db := setupTestDB(t)
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	manager := observability.NewObservabilityManager(observability.DefaultObservabilityConfig(), logger)
+	repo := repository.NewBaseRepository[TestEntity](db, logger, nil).WithObservability(manager)
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, &TestEntity{Name: "Alice", Age: 30}))
+	_, err := repo.CountByConditions(ctx, "age > ?", 20)
+	require.NoError(t, err)
+
+	// Statements are labeled by verb and table, not by their text
+	metrics, err := manager.GetMetrics().GetMetricsByLabels(map[string]string{"query": "INSERT test_entities"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, metrics)
+	metrics, err = manager.GetMetrics().GetMetricsByLabels(map[string]string{"query": "SELECT test_entities", "success": "true"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, metrics)
+}
+
+func TestBaseRepository_ExplainSlowQueries(t *testing.T) {
+	db := setupTestDB(t)
+	buf := &bytes.Buffer{}
+	logger := logging.NewLogger(logging.LogLevelInfo, buf, &logging.TextFormatter{})
+	manager := observability.NewObservabilityManager(observability.DefaultObservabilityConfig(), logger)
+	ctx := context.Background()
+
+	config := repository.DefaultRepositoryConfig()
+	config.SlowQueryThreshold = time.Nanosecond
+	config.ExplainSlowQueries = &repository.ExplainConfig{SampleRate: 1}
+	repo := repository.NewBaseRepository[TestEntity](db, logger, config).WithObservability(manager)
+
+	// Only SELECT statements are explained
+	require.NoError(t, repo.Create(ctx, &TestEntity{Name: "Alice", Age: 30}))
+	assert.NotContains(t, buf.String(), "plan=")
+
+	var entities []TestEntity
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &entities, "age > ?", 20))
+	assert.Contains(t, buf.String(), "plan=")
+	assert.Contains(t, buf.String(), "detail=SEARCH test_entities")
+
+	span := slowQuerySpan(t, manager)
+	assert.Contains(t, span.Attributes["plan"], "SEARCH test_entities")
+	assert.Equal(t, "false", span.Attributes["plan.truncated"])
+	metrics, err := manager.GetMetrics().GetMetricsByLabels(map[string]string{"query": "SELECT test_entities"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, metrics)
+
+	// Plans are cut to the maximum plan size
+	config.ExplainSlowQueries = &repository.ExplainConfig{SampleRate: 1, MaxPlanSize: 8}
+	repo = repository.NewBaseRepository[TestEntity](db, logger, config).WithObservability(manager)
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &entities, "age > ?", 20))
+	span = slowQuerySpan(t, manager)
+	assert.Len(t, span.Attributes["plan"], 12)
+	assert.Equal(t, "true", span.Attributes["plan.truncated"])
+
+	// Unsampled statements are not explained
+	buf.Reset()
+	config.ExplainSlowQueries = &repository.ExplainConfig{SampleRate: 0}
+	repo = repository.NewBaseRepository[TestEntity](db, logger, config)
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &entities, "age > ?", 20))
+	assert.Contains(t, buf.String(), "Slow query detected")
+	assert.NotContains(t, buf.String(), "plan=")
+}
+
+// slowQuerySpan returns the last slow query span finished by manager
+func slowQuerySpan(t *testing.T, manager *observability.ObservabilityManager) *observability.Span {
+	t.Helper()
+	var found *observability.Span
+	for _, span := range manager.GetTracer().FinishedSpans() {
+		if span.Name == "orm.slow_query" {
+			found = span
+		}
+	}
+	require.NotNil(t, found)
+	return found
+}
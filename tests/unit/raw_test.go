@@ -0,0 +1,80 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ormerrors "github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaseRepository_Raw(t *testing.T) {
+	db := setupTestDB(t)
+	buf := &bytes.Buffer{}
+	logger := logging.NewLogger(logging.LogLevelInfo, buf, &logging.TextFormatter{})
+	repo := repository.NewBaseRepository[TestEntity](db, logger, nil)
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, &TestEntity{Name: "Alice", Age: 30}))
+	require.NoError(t, repo.Create(ctx, &TestEntity{Name: "Bob", Age: 40}))
+
+	var names []string
+	require.NoError(t, repo.Raw(ctx, "SELECT name FROM test_entities WHERE age > @age ORDER BY name",
+		map[string]interface{}{"age": 20}, &names))
+	assert.Equal(t, []string{"Alice", "Bob"}, names)
+
+	var entity TestEntity
+	require.NoError(t, repo.Raw(ctx, "SELECT * FROM test_entities WHERE name = @name",
+		map[string]interface{}{"name": "Bob"}, &entity))
+	assert.Equal(t, 40, entity.Age)
+
+	var total int
+	require.NoError(t, repo.Raw(ctx, "SELECT SUM(age) FROM test_entities", nil, &total))
+	assert.Equal(t, 70, total)
+
+	affected, err := repo.Exec(ctx, "UPDATE test_entities SET age = age + @delta WHERE name = @name",
+		map[string]interface{}{"delta": 1, "name": "Alice"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	// Hand-written statements are measured like the generated ones
+	metrics := repo.GetMetrics()
+	assert.Equal(t, int64(3), metrics.Operations["Raw"].SuccessfulOperations)
+	assert.Equal(t, int64(1), metrics.Operations["Exec"].SuccessfulOperations)
+
+	// Failures are classified and keep the statement
+	_, err = repo.Exec(ctx, "UPDATE missing_table SET age = @age", map[string]interface{}{"age": 1})
+	require.Error(t, err)
+	var ormErr *ormerrors.ORMError
+	require.True(t, errors.As(err, &ormErr))
+	assert.Equal(t, "Exec", ormErr.Operation)
+	assert.Equal(t, "UPDATE missing_table SET age = @age", ormErr.Query)
+	assert.Equal(t, "test_entities", ormErr.Table)
+	assert.Equal(t, int64(1), repo.GetMetrics().Operations["Exec"].FailedOperations)
+	assert.Contains(t, buf.String(), "Query failed")
+}
+
+func TestBaseRepository_RawSlowQuery(t *testing.T) {
+	db := setupTestDB(t)
+	buf := &bytes.Buffer{}
+	logger := logging.NewLogger(logging.LogLevelInfo, buf, &logging.TextFormatter{})
+	ctx := context.Background()
+
+	repo := repository.NewBaseRepository[TestEntity](db, logger, nil)
+	var count int
+	require.NoError(t, repo.Raw(ctx, "SELECT COUNT(*) FROM test_entities", nil, &count))
+	assert.NotContains(t, buf.String(), "Slow query detected")
+
+	config := repository.DefaultRepositoryConfig()
+	config.SlowQueryThreshold = time.Nanosecond
+	repo = repository.NewBaseRepository[TestEntity](db, logger, config)
+	require.NoError(t, repo.Raw(ctx, "SELECT COUNT(*) FROM test_entities", nil, &count))
+	assert.Contains(t, buf.String(), "Slow query detected")
+	assert.Contains(t, buf.String(), "SELECT COUNT(*) FROM test_entities")
+}
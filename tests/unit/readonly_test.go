@@ -0,0 +1,69 @@
+package unit
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countActive reads through the read side of a repository only
+func countActive(ctx context.Context, repo repository.ReadRepository[TestEntity]) (int64, error) {
+	return repo.CountByConditions(ctx, "age >= ?", 18)
+}
+
+func assertAccessDenied(t *testing.T, err error, operation string) {
+	t.Helper()
+	var ormErr *errors.ORMError
+	require.True(t, stderrors.As(err, &ormErr), "got %v", err)
+	assert.Equal(t, errors.ErrorTypeAccessDenied, ormErr.GetType())
+	assert.Equal(t, operation, ormErr.Operation)
+}
+
+func TestReadOnlyRepository(t *testing.T) {
+	base, _ := setupTestRepository(t)
+	ctx := context.Background()
+	entity := &TestEntity{Name: "Alice", Age: 30}
+	require.NoError(t, base.Create(ctx, entity))
+
+	repo := repository.NewReadOnlyRepository[TestEntity](base)
+	assert.Same(t, repo, repository.NewReadOnlyRepository[TestEntity](repo))
+
+	found, err := repo.FindFirstByID(ctx, entity.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", found.Name)
+	count, err := countActive(ctx, repo)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	assertAccessDenied(t, repo.Create(ctx, &TestEntity{Name: "Bob"}), "Create")
+	assertAccessDenied(t, repo.Update(ctx, entity), "Update")
+	assertAccessDenied(t, repo.DeleteByID(ctx, entity.ID), "DeleteByID")
+	_, err = repo.UpdateAllByConditions(ctx, map[string]interface{}{"age": 1}, "id = ?", entity.ID)
+	assertAccessDenied(t, err, "UpdateAllByConditions")
+	_, err = repo.UpdateIf(ctx, uuid.New(), nil, nil)
+	assertAccessDenied(t, err, "UpdateIf")
+	assertAccessDenied(t, repo.Upsert(ctx, entity, repository.OnConflict()), "Upsert")
+	_, err = repo.BulkInsertFast(ctx, []TestEntity{{Name: "Bob"}})
+	assertAccessDenied(t, err, "BulkInsertFast")
+	_, err = repo.Begin(ctx)
+	assertAccessDenied(t, err, "Begin")
+
+	// Transactions hand read-only repositories on
+	err = repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		exists, err := tx.ExistsByID(ctx, entity.ID)
+		require.NoError(t, err)
+		assert.True(t, exists)
+		return tx.SoftDeleteByID(ctx, entity.ID)
+	})
+	assertAccessDenied(t, err, "SoftDeleteByID")
+
+	count, err = base.CountByConditions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count, "nothing was written")
+}
@@ -0,0 +1,213 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// declaredShipment declares an index by tag and two in code
+type declaredShipment struct {
+	ID          uint   `gorm:"primaryKey"`
+	Carrier     string `gorm:"index:idx_shipments_carrier"`
+	Tracking    string
+	Destination string
+	DeliveredAt *time.Time
+}
+
+func (declaredShipment) DeclareIndexes() []dialect.IndexDefinition {
+	return []dialect.IndexDefinition{
+		{
+			Name:    "idx_shipments_tracking",
+			Unique:  true,
+			Columns: []dialect.IndexColumn{{Name: "carrier"}, {Name: "tracking"}},
+		},
+		{
+			Name:    "idx_shipments_pending",
+			Columns: []dialect.IndexColumn{{Expression: "lower(destination)"}},
+			Where:   "delivered_at IS NULL",
+		},
+	}
+}
+
+// searchableShipment declares a GIN index, which SQLite cannot build
+type searchableShipment struct {
+	ID      uint `gorm:"primaryKey"`
+	Carrier string
+	Labels  string `gorm:"index:idx_searchable_shipments_labels,type:gin"`
+}
+
+// duplicateShipment declares in code an index already declared by tag
+type duplicateShipment struct {
+	ID      uint   `gorm:"primaryKey"`
+	Carrier string `gorm:"index:idx_duplicate_carrier"`
+}
+
+func (duplicateShipment) DeclareIndexes() []dialect.IndexDefinition {
+	return []dialect.IndexDefinition{{Name: "idx_duplicate_carrier", Columns: []dialect.IndexColumn{{Name: "carrier"}}}}
+}
+
+func TestDialect_CreateIndexMethod(t *testing.T) {
+	tags := dialect.IndexDefinition{
+		Name:    "idx_products_tags",
+		Table:   "products",
+		Columns: []dialect.IndexColumn{{Name: "tags"}},
+		Method:  "GIN",
+	}
+
+	statement, err := dialect.CreateIndex(dialect.Postgres{}, tags)
+	require.NoError(t, err)
+	assert.Equal(t, `CREATE INDEX "idx_products_tags" ON "products" USING gin ("tags")`, statement)
+
+	statement, err = dialect.CreateIndexConcurrently(dialect.Postgres{}, tags)
+	require.NoError(t, err)
+	assert.Equal(t, `CREATE INDEX CONCURRENTLY "idx_products_tags" ON "products" USING gin ("tags")`, statement)
+
+	statement, err = dialect.CreateIndex(dialect.CockroachDB{}, tags)
+	require.NoError(t, err)
+	assert.Equal(t, `CREATE INDEX "idx_products_tags" ON "products" USING gin ("tags")`, statement)
+
+	// Only Postgres and CockroachDB have GIN indexes
+	_, err = dialect.CreateIndex(dialect.SQLite{}, tags)
+	assert.ErrorIs(t, err, dialect.ErrUnsupportedIndex)
+	_, err = dialect.CreateIndex(dialect.MySQL{}, tags)
+	assert.ErrorIs(t, err, dialect.ErrUnsupportedIndex)
+
+	// USING HASH declares hash-sharded indexes on CockroachDB
+	tags.Method = "hash"
+	_, err = dialect.CreateIndex(dialect.CockroachDB{}, tags)
+	assert.ErrorIs(t, err, dialect.ErrUnsupportedIndex)
+
+	// Method names are not interpolated unchecked
+	tags.Method = "gin (tags); DROP TABLE products; --"
+	_, err = dialect.CreateIndex(dialect.Postgres{}, tags)
+	assert.ErrorIs(t, err, dialect.ErrUnsupportedIndex)
+
+	// B-tree is the default of every engine
+	tags.Method = "btree"
+	statement, err = dialect.CreateIndex(dialect.SQLite{}, tags)
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE INDEX `idx_products_tags` ON `products` (`tags`)", statement)
+
+	_, err = dialect.CreateIndexConcurrently(dialect.SQLite{}, tags)
+	assert.ErrorIs(t, err, dialect.ErrUnsupportedIndex)
+}
+
+func TestMigrations_DeclaredIndexes(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	indexes, err := migrations.ModelIndexes(db, &declaredShipment{})
+	require.NoError(t, err)
+	require.Len(t, indexes, 3)
+
+	assert.Equal(t, "idx_shipments_carrier", indexes[0].Name)
+	assert.Empty(t, indexes[0].Method)
+
+	// Declared indexes default to the table of the model
+	assert.Equal(t, "idx_shipments_tracking", indexes[1].Name)
+	assert.Equal(t, "declared_shipments", indexes[1].Table)
+	assert.True(t, indexes[1].Unique)
+	assert.Equal(t, []string{"carrier", "tracking"}, indexes[1].KeyColumns())
+	assert.Equal(t, "delivered_at IS NULL", indexes[2].Where)
+
+	indexes, err = migrations.ModelIndexes(db, &searchableShipment{})
+	require.NoError(t, err)
+	require.Len(t, indexes, 1)
+	assert.Equal(t, "gin", indexes[0].Method)
+
+	_, err = migrations.ModelIndexes(db, &duplicateShipment{})
+	assert.ErrorContains(t, err, "declared twice")
+}
+
+func TestIndexReconciler_CreatesMissingIndexes(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// AutoMigrate creates the tagged index but not the declared ones
+	require.NoError(t, db.AutoMigrate(&declaredShipment{}, &indexedOrder{}))
+	drifts, err := migrations.CheckIndexes(ctx, db, &declaredShipment{})
+	require.NoError(t, err)
+	assert.Len(t, drifts, 2)
+
+	var progress []migrations.IndexProgress
+	reconciler, err := migrations.NewIndexReconciler(db, nil, &migrations.IndexReconcilerConfig{
+		Progress: func(p migrations.IndexProgress) { progress = append(progress, p) },
+	})
+	require.NoError(t, err)
+
+	report, err := reconciler.Reconcile(ctx, &declaredShipment{}, &indexedOrder{})
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Existing)
+	require.Len(t, report.Created, 2)
+	assert.Empty(t, report.Pending)
+	assert.Equal(t, "idx_shipments_tracking", report.Created[0].Index)
+	assert.Equal(t, "CREATE INDEX `idx_shipments_pending` ON `declared_shipments` (lower(destination)) WHERE delivered_at IS NULL", report.Created[1].Statement)
+	// SQLite has no concurrent index builds
+	assert.False(t, report.Created[0].Concurrently)
+	assert.GreaterOrEqual(t, report.Duration, report.Created[0].Duration+report.Created[1].Duration)
+
+	require.Len(t, progress, 2)
+	assert.Equal(t, 1, progress[0].Done)
+	assert.Equal(t, 2, progress[1].Done)
+	assert.Equal(t, 2, progress[1].Total)
+	assert.Equal(t, "idx_shipments_pending", progress[1].Index)
+	assert.LessOrEqual(t, progress[0].Elapsed, progress[1].Elapsed)
+
+	drifts, err = migrations.CheckIndexes(ctx, db, &declaredShipment{})
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+
+	// Reconciling again finds every index
+	report, err = reconciler.Reconcile(ctx, &declaredShipment{})
+	require.NoError(t, err)
+	assert.Empty(t, report.Created)
+	assert.Equal(t, 3, report.Existing)
+}
+
+func TestIndexReconciler_FailsBeforeBuilding(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	reconciler, err := migrations.NewIndexReconciler(db, nil, nil)
+	require.NoError(t, err)
+
+	_, err = reconciler.Reconcile(ctx, &declaredShipment{})
+	assert.ErrorContains(t, err, "does not exist")
+
+	require.NoError(t, db.Exec("CREATE TABLE declared_shipments (id integer PRIMARY KEY, carrier text, tracking text, destination text, delivered_at datetime)").Error)
+	require.NoError(t, db.Exec("CREATE TABLE searchable_shipments (id integer PRIMARY KEY, carrier text, labels text)").Error)
+
+	// The GIN index fails the reconciliation before any index is built
+	_, err = reconciler.Reconcile(ctx, &declaredShipment{}, &searchableShipment{})
+	assert.ErrorIs(t, err, dialect.ErrUnsupportedIndex)
+	drifts, err := migrations.CheckIndexes(ctx, db, &declaredShipment{})
+	require.NoError(t, err)
+	assert.Len(t, drifts, 3)
+
+	// A canceled context stops the reconciliation between indexes
+	canceled, cancel := context.WithCancel(ctx)
+	defer cancel()
+	reconciler, err = migrations.NewIndexReconciler(db, nil, &migrations.IndexReconcilerConfig{
+		Progress: func(migrations.IndexProgress) { cancel() },
+	})
+	require.NoError(t, err)
+	report, err := reconciler.Reconcile(canceled, &declaredShipment{})
+	assert.ErrorIs(t, err, context.Canceled)
+	require.NotNil(t, report)
+	require.Len(t, report.Created, 1)
+	assert.Equal(t, "idx_shipments_carrier", report.Created[0].Index)
+	assert.Equal(t, []string{"idx_shipments_tracking", "idx_shipments_pending"}, report.Pending)
+
+	_, err = migrations.NewIndexReconciler(nil, nil, nil)
+	assert.Error(t, err)
+}
@@ -1,12 +1,14 @@
 package unit
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/errors"
 	"github.com/seasbee/go-ormx/pkg/logging"
 	"github.com/seasbee/go-ormx/pkg/models"
 	"github.com/seasbee/go-ormx/pkg/repository"
@@ -338,7 +340,7 @@ func TestBaseRepository_Upsert(t *testing.T) {
 
 	// Upsert the same entity
 	entity.Age = 31
-	err = repo.Upsert(ctx, entity, "id")
+	err = repo.Upsert(ctx, entity, repository.OnConflict("id"))
 	assert.NoError(t, err)
 
 	// Verify upsert
@@ -396,6 +398,253 @@ func TestBaseRepository_DeleteByConditions(t *testing.T) {
 	assert.Len(t, found, 0)
 }
 
+type ingestEvent struct {
+	models.BaseModel
+	ExternalID string `gorm:"uniqueIndex;not null"`
+	Payload    string
+}
+
+func TestBaseRepository_CreateInBatchesIgnoreConflicts(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&ingestEvent{}))
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	repo := repository.NewBaseRepository[ingestEvent](db, logger, repository.DefaultRepositoryConfig())
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, &ingestEvent{ExternalID: "e2", Payload: "original"}))
+
+	t.Run("skips duplicates by the conflict columns", func(t *testing.T) {
+		events := []ingestEvent{
+			{ExternalID: "e1", Payload: "new"},
+			{ExternalID: "e2", Payload: "duplicate"},
+			{ExternalID: "e3", Payload: "new"},
+			{ExternalID: "e3", Payload: "duplicate in batch"},
+			{ExternalID: "e4", Payload: "new"},
+		}
+		result, err := repo.CreateInBatchesIgnoreConflicts(ctx, events, 2, "external_id")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), result.Inserted)
+		assert.Equal(t, int64(2), result.Skipped)
+		assert.Equal(t, []repository.BatchResult{
+			{Batch: 0, Inserted: 1, Skipped: 1},
+			{Batch: 1, Inserted: 1, Skipped: 1},
+			{Batch: 2, Inserted: 1, Skipped: 0},
+		}, result.Batches)
+
+		var existing ingestEvent
+		require.NoError(t, repo.FindFirstByConditions(ctx, &existing, "external_id = ?", "e2"))
+		assert.Equal(t, "original", existing.Payload)
+
+		count, err := repo.CountByConditions(ctx, "external_id <> ?", "")
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), count)
+	})
+
+	t.Run("re-ingesting is a no-op", func(t *testing.T) {
+		events := []ingestEvent{{ExternalID: "e1"}, {ExternalID: "e4"}, {ExternalID: "e5"}}
+		result, err := repo.CreateInBatchesIgnoreConflicts(ctx, events, 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), result.Inserted)
+		assert.Equal(t, int64(2), result.Skipped)
+		assert.Len(t, result.Batches, 1)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		_, err := repo.CreateInBatchesIgnoreConflicts(ctx, nil, 10)
+		assert.Error(t, err)
+
+		_, err = repo.CreateInBatchesIgnoreConflicts(ctx, []ingestEvent{{ExternalID: "e6"}}, 0)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "batch size must be greater than 0")
+	})
+}
+
+func TestBaseRepository_UpdateAllByConditions(t *testing.T) {
+	repo, _ := setupTestRepository(t)
+	ctx := context.Background()
+
+	entities := []TestEntity{
+		{Name: "Alice", Age: 25},
+		{Name: "Bob", Age: 30},
+		{Name: "Charlie", Age: 35},
+		{Name: "Dave", Age: 40},
+	}
+	for i := range entities {
+		require.NoError(t, repo.Create(ctx, &entities[i]))
+	}
+
+	t.Run("returns affected rows", func(t *testing.T) {
+		affected, err := repo.UpdateAllByConditions(ctx, map[string]interface{}{"name": "Senior"}, "age >= ?", 30)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), affected)
+
+		count, err := repo.CountByConditions(ctx, "name = ?", "Senior")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+	})
+
+	t.Run("sets zero values", func(t *testing.T) {
+		affected, err := repo.UpdateAllByConditions(ctx, map[string]interface{}{"age": 0}, "name = ?", "Alice")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), affected)
+
+		found, err := repo.FindFirstByID(ctx, entities[0].GetID())
+		require.NoError(t, err)
+		assert.Equal(t, 0, found.Age)
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		affected, err := repo.UpdateAllByConditions(ctx, map[string]interface{}{"age": 1}, "name = ?", "Nobody")
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), affected)
+	})
+
+	t.Run("skips soft deleted entities", func(t *testing.T) {
+		require.NoError(t, repo.SoftDeleteByID(ctx, entities[3].GetID()))
+
+		affected, err := repo.UpdateAllByConditions(ctx, map[string]interface{}{"age": 50}, "name = ?", "Senior")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), affected)
+
+		affected, err = repo.UpdateAllByConditions(repository.WithDeleted(ctx), map[string]interface{}{"age": 50}, "name = ?", "Senior")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), affected)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		_, err := repo.UpdateAllByConditions(ctx, map[string]interface{}{"age": 1})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "WHERE conditions required")
+
+		_, err = repo.UpdateAllByConditions(ctx, nil, "age > ?", 0)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "values cannot be empty")
+	})
+}
+
+func TestBaseRepository_UpdateIf(t *testing.T) {
+	repo, _ := setupTestRepository(t)
+	ctx := context.Background()
+
+	entity := &TestEntity{Name: "pending", Age: 30}
+	require.NoError(t, repo.Create(ctx, entity))
+
+	t.Run("claims when the precondition matches", func(t *testing.T) {
+		matched, err := repo.UpdateIf(ctx, entity.ID, map[string]interface{}{"name": "pending"}, map[string]interface{}{"name": "claimed", "age": 31})
+		require.NoError(t, err)
+		assert.True(t, matched)
+
+		found, err := repo.FindFirstByID(ctx, entity.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "claimed", found.Name)
+		assert.Equal(t, 31, found.Age)
+	})
+
+	t.Run("leaves the entity when the precondition fails", func(t *testing.T) {
+		matched, err := repo.UpdateIf(ctx, entity.ID, map[string]interface{}{"name": "pending"}, map[string]interface{}{"name": "stolen"})
+		require.NoError(t, err)
+		assert.False(t, matched)
+
+		matched, err = repo.UpdateIf(ctx, entity.ID, map[string]interface{}{"name": "claimed", "age": 30}, map[string]interface{}{"name": "stolen"})
+		require.NoError(t, err)
+		assert.False(t, matched)
+
+		found, err := repo.FindFirstByID(ctx, entity.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "claimed", found.Name)
+	})
+
+	t.Run("matches NULL and unconditional updates", func(t *testing.T) {
+		matched, err := repo.UpdateIf(ctx, entity.ID, map[string]interface{}{"deleted_by": nil}, map[string]interface{}{"age": 32})
+		require.NoError(t, err)
+		assert.True(t, matched)
+
+		matched, err = repo.UpdateIf(ctx, entity.ID, nil, map[string]interface{}{"age": 33})
+		require.NoError(t, err)
+		assert.True(t, matched)
+
+		matched, err = repo.UpdateIf(ctx, uuid.New(), nil, map[string]interface{}{"age": 1})
+		require.NoError(t, err)
+		assert.False(t, matched)
+	})
+
+	t.Run("skips soft deleted entities", func(t *testing.T) {
+		require.NoError(t, repo.SoftDeleteByID(ctx, entity.ID))
+
+		matched, err := repo.UpdateIf(ctx, entity.ID, map[string]interface{}{"age": 33}, map[string]interface{}{"age": 34})
+		require.NoError(t, err)
+		assert.False(t, matched)
+
+		matched, err = repo.UpdateIf(repository.WithDeleted(ctx), entity.ID, map[string]interface{}{"age": 33}, map[string]interface{}{"age": 34})
+		require.NoError(t, err)
+		assert.True(t, matched)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		_, err := repo.UpdateIf(ctx, uuid.Nil, nil, map[string]interface{}{"age": 1})
+		assert.Error(t, err)
+
+		_, err = repo.UpdateIf(ctx, entity.ID, map[string]interface{}{"age": 33}, nil)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "updates cannot be empty")
+	})
+}
+
+func TestBaseRepository_DeleteAllByConditions(t *testing.T) {
+	repo, _ := setupTestRepository(t)
+	ctx := context.Background()
+
+	entities := []TestEntity{
+		{Name: "Alice", Age: 25},
+		{Name: "Bob", Age: 30},
+		{Name: "Charlie", Age: 35},
+		{Name: "Dave", Age: 40},
+	}
+	for i := range entities {
+		require.NoError(t, repo.Create(ctx, &entities[i]))
+	}
+
+	affected, err := repo.DeleteAllByConditions(ctx, "age > ?", 30)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+
+	count, err := repo.CountByConditions(ctx, "age > ?", 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	affected, err = repo.DeleteAllByConditions(ctx, "age > ?", 30)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), affected)
+
+	_, err = repo.DeleteAllByConditions(ctx)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "WHERE conditions required")
+
+	t.Run("soft delete mode", func(t *testing.T) {
+		db := setupTestDB(t)
+		config := repository.DefaultRepositoryConfig()
+		config.DeleteMode = repository.DeleteModeSoft
+		softRepo := repository.NewBaseRepository[TestEntity](db, logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{}), config)
+
+		for _, entity := range []TestEntity{{Name: "Alice", Age: 25}, {Name: "Bob", Age: 30}} {
+			require.NoError(t, softRepo.Create(ctx, &entity))
+		}
+
+		affected, err := softRepo.DeleteAllByConditions(ctx, "age > ?", 0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), affected)
+
+		// Already deleted rows are not counted again
+		affected, err = softRepo.DeleteAllByConditions(ctx, "age > ?", 0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), affected)
+
+		var all []TestEntity
+		require.NoError(t, softRepo.FindAllIncludingDeleted(ctx, 10, 0, &all))
+		assert.Len(t, all, 2)
+	})
+}
+
 func TestBaseRepository_ExistsByID(t *testing.T) {
 	repo, _ := setupTestRepository(t)
 	ctx := context.Background()
@@ -640,9 +889,35 @@ func TestBaseRepository_Metrics(t *testing.T) {
 	err := repo.Create(ctx, entity)
 	assert.NoError(t, err)
 
-	// Check that operation was successful
-	// Note: GetMetrics method is not available on BaseRepository
-	// Metrics are collected internally but not exposed via public API
+	_, err = repo.FindFirstByID(ctx, entity.ID)
+	assert.NoError(t, err)
+
+	_, err = repo.FindFirstByID(ctx, uuid.New())
+	assert.Error(t, err)
+
+	// Check that operations were recorded per operation
+	metrics := repo.GetMetrics()
+	assert.Equal(t, int64(3), metrics.TotalOperations)
+	assert.Equal(t, int64(1), metrics.FailedOperations)
+	assert.InDelta(t, 2.0/3.0, metrics.SuccessRate, 0.0001)
+	assert.Equal(t, int64(3), metrics.Latency.Count)
+
+	create := metrics.Operations["Create"]
+	assert.Equal(t, int64(1), create.TotalOperations)
+	assert.Equal(t, 1.0, create.SuccessRate)
+	assert.Equal(t, int64(1), create.Latency.Count)
+
+	find := metrics.Operations["FindFirstByID"]
+	assert.Equal(t, int64(2), find.TotalOperations)
+	assert.Equal(t, int64(1), find.FailedOperations)
+	assert.Equal(t, 0.5, find.SuccessRate)
+	require.Len(t, find.Latency.Buckets, len(repository.DefaultLatencyBuckets))
+	assert.Equal(t, int64(2), find.Latency.Buckets[len(find.Latency.Buckets)-1].Count)
+
+	repo.ResetMetrics()
+	metrics = repo.GetMetrics()
+	assert.Equal(t, int64(0), metrics.TotalOperations)
+	assert.Empty(t, metrics.Operations)
 }
 
 func TestBaseRepository_ConcurrentAccess(t *testing.T) {
@@ -754,6 +1029,170 @@ func TestRepositoryMetrics(t *testing.T) {
 	assert.Equal(t, time.Duration(0), metrics.AverageQueryTime)
 }
 
+func TestRepositoryMetrics_Snapshot(t *testing.T) {
+	metrics := repository.NewRepositoryMetrics()
+
+	for _, d := range []time.Duration{2 * time.Millisecond, 3 * time.Millisecond, 4 * time.Millisecond, 40 * time.Millisecond} {
+		metrics.RecordQueryTimeFor("FindAll", d)
+		metrics.IncrementOperationsFor("FindAll", true)
+	}
+	metrics.RecordQueryTimeFor("Create", 20*time.Second)
+	metrics.IncrementOperationsFor("Create", false)
+
+	snapshot := metrics.Snapshot()
+	assert.Equal(t, int64(5), snapshot.TotalOperations)
+	assert.Equal(t, 0.8, snapshot.SuccessRate)
+	assert.Len(t, snapshot.Operations, 2)
+
+	findAll := snapshot.Operations["FindAll"].Latency
+	assert.Equal(t, int64(4), findAll.Count)
+	assert.Equal(t, 12250*time.Microsecond, findAll.Mean())
+	assert.Equal(t, int64(3), latencyBucketCount(findAll, 5*time.Millisecond))
+	assert.Equal(t, int64(4), latencyBucketCount(findAll, 50*time.Millisecond))
+
+	// The median falls in the (1ms, 5ms] bucket, p99 in (25ms, 50ms]
+	assert.Greater(t, findAll.Quantile(0.5), time.Millisecond)
+	assert.LessOrEqual(t, findAll.Quantile(0.5), 5*time.Millisecond)
+	assert.Greater(t, findAll.Quantile(0.99), 25*time.Millisecond)
+	assert.LessOrEqual(t, findAll.Quantile(0.99), 50*time.Millisecond)
+
+	// Observations above the last bucket only show in Count and Sum
+	create := snapshot.Operations["Create"]
+	assert.Equal(t, 0.0, create.SuccessRate)
+	assert.Equal(t, int64(1), create.Latency.Count)
+	assert.Equal(t, int64(0), create.Latency.Buckets[len(create.Latency.Buckets)-1].Count)
+	assert.Equal(t, 10*time.Second, create.Latency.Quantile(0.5))
+}
+
+func TestRepositoryMetrics_Percentiles(t *testing.T) {
+	metrics := repository.NewRepositoryMetrics()
+
+	// The mean is exact however the observations are ordered
+	metrics.RecordQueryTime(10 * time.Millisecond)
+	metrics.RecordQueryTime(10 * time.Millisecond)
+	metrics.RecordQueryTime(100 * time.Millisecond)
+	assert.Equal(t, 40*time.Millisecond, metrics.AverageQueryTime)
+	metrics.Reset()
+
+	for i := 1; i <= 1000; i++ {
+		metrics.RecordTableQueryTime("orders", "FindFirstByID", time.Duration(i)*time.Millisecond)
+	}
+	metrics.RecordTableQueryTime("orders", "Create", 3*time.Millisecond)
+	metrics.RecordTableQueryTime("customers", "UpsertByID", 7*time.Second)
+	metrics.RecordTableQueryTime("customers", "SoftDeleteByID", time.Millisecond)
+
+	snapshot := metrics.Snapshot()
+	find := snapshot.Operations["FindFirstByID"].Percentiles
+	assert.Equal(t, int64(1000), find.Count)
+	assert.InEpsilon(t, float64(500*time.Millisecond), float64(find.P50), 0.03)
+	assert.InEpsilon(t, float64(900*time.Millisecond), float64(find.P90), 0.03)
+	assert.InEpsilon(t, float64(990*time.Millisecond), float64(find.P99), 0.03)
+	assert.Equal(t, time.Second, find.Max)
+
+	// Observations beyond the histogram buckets keep their value
+	assert.Equal(t, 7*time.Second, snapshot.Percentiles.Max)
+	assert.Equal(t, 7*time.Second, snapshot.Categories[repository.CategoryUpdate].P50)
+
+	assert.Equal(t, int64(1000), snapshot.Categories[repository.CategoryRead].Count)
+	assert.Equal(t, int64(1), snapshot.Categories[repository.CategoryCreate].Count)
+	assert.Equal(t, int64(1), snapshot.Categories[repository.CategoryDelete].Count)
+	assert.Equal(t, int64(1001), snapshot.Tables["orders"].Count)
+	assert.Equal(t, int64(2), snapshot.Tables["customers"].Count)
+}
+
+func TestBaseRepository_WithMetrics(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&tenantNote{}))
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	metrics := repository.NewRepositoryMetrics()
+	entities := repository.NewBaseRepository[TestEntity](db, logger, nil).WithMetrics(metrics)
+	notes := repository.NewBaseRepository[tenantNote](db, logger, nil).WithMetrics(metrics)
+	ctx := context.Background()
+
+	require.NoError(t, entities.Create(ctx, &TestEntity{Name: "Alice", Age: 30}))
+	require.NoError(t, notes.Create(ctx, &tenantNote{Title: "first"}))
+	_, err := notes.CountByConditions(ctx)
+	require.NoError(t, err)
+
+	snapshot := metrics.Snapshot()
+	assert.Equal(t, int64(1), snapshot.Tables["test_entities"].Count)
+	assert.Equal(t, int64(2), snapshot.Tables["tenantNote"].Count)
+	assert.Equal(t, int64(3), snapshot.Percentiles.Count)
+}
+
+func TestBaseRepository_SessionOptions(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	var statements []string
+	var inTransaction []bool
+	record := func(tx *gorm.DB) {
+		_, ok := tx.Statement.ConnPool.(gorm.TxCommitter)
+		statements = append(statements, tx.Statement.SQL.String())
+		inTransaction = append(inTransaction, ok)
+	}
+	require.NoError(t, db.Callback().Create().After("gorm:create").Before("gorm:commit_or_rollback_transaction").Register("test:record_create", record))
+	require.NoError(t, db.Callback().Query().After("gorm:query").Register("test:record_query", record))
+
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	config := repository.DefaultRepositoryConfig()
+	config.SkipDefaultTransaction = true
+	config.QueryFields = true
+	config.CreateBatchSize = 2
+	repo := repository.NewBaseRepository[TestEntity](db, logger, config)
+
+	entities := make([]TestEntity, 5)
+	for i := range entities {
+		entities[i] = TestEntity{Name: fmt.Sprintf("Entity %d", i), Age: 20 + i}
+	}
+	require.NoError(t, repo.CreateInBatches(ctx, entities, 0))
+	assert.Len(t, statements, 3, "zero batch size uses CreateBatchSize")
+	assert.Equal(t, []bool{true, true, true}, inTransaction, "batches keep their transaction")
+
+	// A failing batch rolls back the batches before it
+	failing := make([]TestEntity, 5)
+	for i := range failing {
+		failing[i] = TestEntity{Name: fmt.Sprintf("Failing %d", i), Age: 30 + i}
+	}
+	failing[4].ID = entities[0].ID
+	require.Error(t, repo.CreateInBatches(ctx, failing, 0))
+	var count int64
+	require.NoError(t, db.Model(&TestEntity{}).Count(&count).Error)
+	assert.Equal(t, int64(5), count)
+
+	statements = nil
+	_, err := repo.FindFirstByID(ctx, entities[0].ID)
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+	assert.NotContains(t, statements[0], "SELECT *")
+	assert.Contains(t, statements[0], "`test_entities`.`name`")
+
+	// Transaction repositories keep the options
+	statements, inTransaction = nil, nil
+	require.NoError(t, repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		return tx.CreateInBatches(ctx, []TestEntity{{Name: "A", Age: 1}, {Name: "B", Age: 2}, {Name: "C", Age: 3}}, 0)
+	}))
+	assert.Len(t, statements, 2)
+	assert.Equal(t, []bool{true, true}, inTransaction)
+
+	// Without the options creates run in their own transaction
+	statements, inTransaction = nil, nil
+	plain := repository.NewBaseRepository[TestEntity](db, logger, repository.DefaultRepositoryConfig())
+	require.NoError(t, plain.Create(ctx, &TestEntity{Name: "D", Age: 4}))
+	assert.Equal(t, []bool{true}, inTransaction)
+	assert.Error(t, plain.CreateInBatches(ctx, []TestEntity{{Name: "E", Age: 5}}, 0))
+}
+
+// latencyBucketCount returns the cumulative count of the bucket bounded by upper
+func latencyBucketCount(h repository.LatencyHistogram, upper time.Duration) int64 {
+	for _, bucket := range h.Buckets {
+		if bucket.UpperBound == upper {
+			return bucket.Count
+		}
+	}
+	return -1
+}
+
 // Add missing test scenarios
 func TestBaseRepository_ErrorHandling(t *testing.T) {
 	repo, _ := setupTestRepository(t)
@@ -1055,15 +1494,19 @@ func TestBaseRepository_UpsertEdgeCases(t *testing.T) {
 	ctx := context.Background()
 
 	// Test upsert with nil entity
-	err := repo.Upsert(ctx, nil, "id")
+	err := repo.Upsert(ctx, nil, repository.OnConflict("id"))
 	assert.Error(t, err)
 
 	// Test upsert with empty unique field
-	err = repo.Upsert(ctx, &TestEntity{Name: "Test", Age: 30}, "")
+	err = repo.Upsert(ctx, &TestEntity{Name: "Test", Age: 30}, repository.OnConflict(""))
+	assert.Error(t, err)
+
+	// Test upsert with contradictory conflict options
+	err = repo.Upsert(ctx, &TestEntity{Name: "Test", Age: 30}, repository.ConflictOptions{DoNothing: true, DoUpdates: []string{"age"}})
 	assert.Error(t, err)
 
 	// Test upsert with invalid unique field - this might not fail until runtime in GORM
-	_ = repo.Upsert(ctx, &TestEntity{Name: "Test", Age: 30}, "nonexistent_field")
+	_ = repo.Upsert(ctx, &TestEntity{Name: "Test", Age: 30}, repository.OnConflict("nonexistent_field"))
 	// Note: GORM doesn't validate field existence at compile time, so this might not error
 	// The error would occur at runtime when the database operation is attempted
 }
@@ -1623,3 +2066,131 @@ func TestBaseRepository_CursorPaginationEdgeCases(t *testing.T) {
 	assert.Equal(t, 1, batchCount) // One batch with the single entity
 	assert.Len(t, batchResult, 1)
 }
+
+// validatedEntity is a test entity with validation rules
+type validatedEntity struct {
+	models.BaseModel
+	Email string `validate:"required,email"`
+	Age   int    `validate:"min=18"`
+}
+
+func (validatedEntity) TableName() string {
+	return "validated_entities"
+}
+
+func TestBaseRepository_ValidationError(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&validatedEntity{}))
+
+	var logs bytes.Buffer
+	logger := logging.NewLogger(logging.LogLevelInfo, &logs, &logging.JSONFormatter{})
+	repo := repository.NewBaseRepository[validatedEntity](db, logger, nil)
+	ctx := context.Background()
+
+	err := repo.Create(ctx, &validatedEntity{Email: "secret-not-an-email", Age: 10})
+	require.Error(t, err)
+
+	verr, ok := errors.AsValidationError(err)
+	require.True(t, ok, "expected a typed validation error, got %v", err)
+	assert.Equal(t, "validated_entities", verr.Entity)
+
+	fieldMap := verr.FieldMap()
+	assert.Contains(t, fieldMap, "Email")
+	assert.Contains(t, fieldMap, "Age")
+
+	// Failures are logged per field without the offending value
+	assert.Contains(t, logs.String(), "Entity validation failed")
+	assert.Contains(t, logs.String(), `"field":"Email"`)
+	assert.NotContains(t, logs.String(), "secret-not-an-email")
+
+	metrics := repo.GetMetrics()
+	assert.NotEmpty(t, metrics.ValidationFailures)
+
+	err = repo.CreateInBatches(ctx, []validatedEntity{{Email: "a@example.com", Age: 20}, {Email: "bad", Age: 20}}, 10)
+	verr, ok = errors.AsValidationError(err)
+	require.True(t, ok)
+	assert.Equal(t, 1, verr.Index)
+	assert.Equal(t, []string{"Email"}, keys(verr.FieldMap()))
+}
+
+// keys returns the keys of m
+func keys(m map[string][]string) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	return result
+}
+
+func TestBaseRepository_StructuredErrors(t *testing.T) {
+	repo, _ := setupTestRepository(t)
+	ctx := context.Background()
+
+	// Missing entities are not found errors still matching gorm.ErrRecordNotFound
+	_, err := repo.FindFirstByID(ctx, uuid.New())
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	var ormErr *errors.ORMError
+	require.ErrorAs(t, err, &ormErr)
+	assert.Equal(t, errors.ErrorTypeNotFound, ormErr.Type)
+	assert.Equal(t, "FindFirstByID", ormErr.Operation)
+	assert.Equal(t, "test_entities", ormErr.Table)
+	assert.Contains(t, err.Error(), "failed to find entity by ID")
+
+	// Invalid arguments are validation errors
+	err = repo.CreateInBatches(ctx, []TestEntity{{Name: "Alice"}}, 0)
+	require.ErrorAs(t, err, &ormErr)
+	assert.Equal(t, errors.ErrorTypeValidation, ormErr.Type)
+	assert.Equal(t, "CreateInBatches", ormErr.Operation)
+	assert.False(t, errors.IsNotFound(err))
+
+	// Unique violations are duplicate errors
+	accounts := setupAccounts(t, setupTestDB(t))
+	require.NoError(t, accounts.Create(ctx, &account{Email: "alice@example.com", Plan: "free"}))
+	err = accounts.Create(ctx, &account{Email: "alice@example.com", Plan: "pro"})
+	require.Error(t, err)
+	assert.True(t, errors.IsDuplicate(err))
+	require.ErrorAs(t, err, &ormErr)
+	assert.Equal(t, "Create", ormErr.Operation)
+
+	// Expired contexts are timeout errors
+	expired, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer cancel()
+	_, err = repo.CountAll(expired)
+	require.Error(t, err)
+	assert.True(t, errors.IsTimeout(err))
+}
+
+func TestBaseRepository_FindersNotFound(t *testing.T) {
+	repo, _ := setupTestRepository(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, &TestEntity{Name: "Alice", Age: 30}))
+
+	var entity TestEntity
+	_, byID := repo.FindFirstByID(ctx, uuid.New())
+	_, forUpdate := repo.FindFirstByIDForUpdate(ctx, uuid.New())
+	_, first := repo.Query().Where("name = ?", "Bob").First(ctx)
+	finders := map[string]error{
+		"FindFirstByID":          byID,
+		"FindFirstByIDForUpdate": forUpdate,
+		"FindFirstByConditions":  repo.FindFirstByConditions(ctx, &entity, "name = ?", "Bob"),
+		"FindFirstBy":            repo.FindFirstBy(ctx, &entity, "name", "Bob"),
+		"TakeByConditions":       repo.TakeByConditions(ctx, &entity, "name = ?", "Bob"),
+		"LastByConditions":       repo.LastByConditions(ctx, &entity, "name = ?", "Bob"),
+		"Query.First":            first,
+	}
+	for operation, err := range finders {
+		require.Error(t, err, operation)
+		assert.ErrorIs(t, err, errors.ErrNotFound, operation)
+		assert.True(t, errors.IsNotFound(err), operation)
+		var ormErr *errors.ORMError
+		require.ErrorAs(t, err, &ormErr, operation)
+		assert.Equal(t, errors.ErrorTypeNotFound, ormErr.Type, operation)
+		assert.Equal(t, operation, ormErr.Operation)
+	}
+
+	// Other failures do not match
+	err := repo.CreateInBatches(ctx, nil, 10)
+	assert.NotErrorIs(t, err, errors.ErrNotFound)
+}
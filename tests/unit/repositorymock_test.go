@@ -0,0 +1,73 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/seasbee/go-ormx/pkg/repository/repositorymock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingT records the failures reported to a mock
+type recordingT struct {
+	failures []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestRepositoryMock(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.New()
+
+	repo := repositorymock.New[TestEntity](t)
+	var _ repository.Repository[TestEntity] = repo
+
+	repo.On("FindFirstByID", repositorymock.Anything, id).Return(&TestEntity{Name: "found"}, nil).Once()
+	repo.On("FindFirstByConditions", ctx, repositorymock.Anything, "name = ?", "ann").
+		Run(func(args []interface{}) { args[1].(*TestEntity).Age = 42 }).
+		Return(nil)
+	repo.On("Update", ctx, repositorymock.MatchedBy(func(e *TestEntity) bool { return e.Name == "ann" })).Return(nil).Times(2)
+
+	found, err := repo.FindFirstByID(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "found", found.Name)
+
+	var dest TestEntity
+	require.NoError(t, repo.FindFirstByConditions(ctx, &dest, "name = ?", "ann"))
+	assert.Equal(t, 42, dest.Age)
+
+	require.NoError(t, repo.Update(ctx, &TestEntity{Name: "ann"}))
+	require.NoError(t, repo.Update(ctx, &TestEntity{Name: "ann"}))
+	assert.Len(t, repo.Calls(), 4)
+	repo.AssertNotCalled(t, "DeleteByID")
+}
+
+func TestRepositoryMock_Failures(t *testing.T) {
+	ctx := context.Background()
+	recorder := &recordingT{}
+	repo := repositorymock.New[TestEntity](recorder)
+
+	// Unexpected calls fail the test and return an error
+	repo.On("FindFirstByID", ctx, uuid.Nil).Return(nil, nil).Once()
+	_, err := repo.FindFirstByID(ctx, uuid.New())
+	require.Error(t, err)
+	require.Len(t, recorder.failures, 1)
+
+	// Expectations called too few times are reported
+	assert.False(t, repo.AssertExpectations(recorder))
+	assert.Len(t, recorder.failures, 2)
+
+	// Calls beyond the expected times are unexpected
+	_, err = repo.FindFirstByID(ctx, uuid.Nil)
+	require.NoError(t, err)
+	_, err = repo.FindFirstByID(ctx, uuid.Nil)
+	require.Error(t, err)
+}
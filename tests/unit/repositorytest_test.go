@@ -0,0 +1,18 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/repository/repositorytest"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestBaseRepository_Conformance(t *testing.T) {
+	repositorytest.Run(t, repositorytest.NewBaseFactory(func(t *testing.T) *gorm.DB {
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		require.NoError(t, err)
+		return db
+	}))
+}
@@ -0,0 +1,59 @@
+package unit
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestStats(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	require.NoError(t, db.Use(repository.NewRequestStatsCollector()))
+	background := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, repo.Create(background, &TestEntity{Name: "Entity", Age: i}))
+	}
+	assert.Zero(t, repository.CollectRequestStats(background).Queries, "contexts not collecting are ignored")
+
+	ctx := repository.StartRequestStats(background)
+	var all []TestEntity
+	require.NoError(t, repo.FindAllWithOffset(ctx, 10, 0, &all))
+	require.Len(t, all, 3)
+
+	// One lookup per entity, as an N+1 loop would
+	for _, entity := range all {
+		_, err := repo.FindFirstByID(ctx, entity.ID)
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.Create(ctx, &TestEntity{Name: "Created", Age: 4}))
+	require.Error(t, repo.FindFirstByConditions(ctx, &TestEntity{}, "name = ?", "missing"))
+
+	stats := repository.CollectRequestStats(ctx)
+	assert.Equal(t, int64(6), stats.Queries)
+	assert.Equal(t, int64(6), stats.Rows)
+	assert.Equal(t, int64(1), stats.RowsAffected)
+	assert.Zero(t, stats.Errors, "missing records are not errors")
+	assert.Equal(t, int64(5), stats.Statements["SELECT test_entities"])
+	assert.Equal(t, int64(1), stats.Statements["INSERT test_entities"])
+	assert.Positive(t, stats.Duration)
+	assert.LessOrEqual(t, stats.Slowest, stats.Duration)
+	assert.NotEmpty(t, stats.SlowestQuery)
+
+	// Statements of concurrent goroutines sharing the context are all counted
+	ctx = repository.StartRequestStats(background)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = repo.CountByConditions(ctx)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(4), repository.CollectRequestStats(ctx).Queries)
+}
@@ -0,0 +1,162 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/config"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// failingStatements makes the next statements of db fail with the queued errors
+type failingStatements struct {
+	errs     []error
+	attempts int
+}
+
+// register installs the failing callback before the create, query, update and delete callbacks
+func (f *failingStatements) register(t *testing.T, db *gorm.DB) {
+	fail := func(tx *gorm.DB) {
+		f.attempts++
+		if len(f.errs) > 0 {
+			_ = tx.AddError(f.errs[0])
+			f.errs = f.errs[1:]
+		}
+	}
+	require.NoError(t, db.Callback().Create().Before("gorm:create").Register("test:fail_create", fail))
+	require.NoError(t, db.Callback().Query().Before("gorm:query").Register("test:fail_query", fail))
+	require.NoError(t, db.Callback().Update().Before("gorm:update").Register("test:fail_update", fail))
+	require.NoError(t, db.Callback().Delete().Before("gorm:delete").Register("test:fail_delete", fail))
+}
+
+// testRetryConfig retries three times without waiting long
+func testRetryConfig() *config.RetryConfig {
+	return &config.RetryConfig{
+		Enabled:            true,
+		MaxAttempts:        3,
+		InitialDelay:       time.Millisecond,
+		MaxDelay:           5 * time.Millisecond,
+		BackoffMultiplier:  2,
+		Jitter:             true,
+		RetryableErrors:    []string{"server is restarting"},
+		NonRetryableErrors: []string{"connection reset by policy"},
+	}
+}
+
+// setupRetryRepository creates a test repository retrying as configured by retry
+func setupRetryRepository(t *testing.T, retry *config.RetryConfig) (*repository.BaseRepository[TestEntity], *failingStatements) {
+	db := setupTestDB(t)
+	failing := &failingStatements{}
+	failing.register(t, db)
+
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	repoConfig := repository.DefaultRepositoryConfig()
+	repoConfig.Retry = retry
+	return repository.NewBaseRepository[TestEntity](db, logger, repoConfig), failing
+}
+
+func TestRetry_TransientErrors(t *testing.T) {
+	repo, failing := setupRetryRepository(t, testRetryConfig())
+	ctx := context.Background()
+
+	// Classified as retryable
+	failing.errs = []error{errors.New("connection reset by peer"), errors.New("deadlock detected")}
+	entity := &TestEntity{Name: "Alice", Age: 30}
+	require.NoError(t, repo.Create(ctx, entity))
+	assert.Equal(t, 3, failing.attempts)
+
+	// Retryable by the configured patterns
+	failing.attempts = 0
+	failing.errs = []error{errors.New("Server is restarting")}
+	found, err := repo.FindFirstByID(ctx, entity.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", found.Name)
+	assert.Equal(t, 2, failing.attempts)
+
+	failing.attempts = 0
+	failing.errs = []error{errors.New("lock wait timeout exceeded")}
+	entity.Age = 31
+	require.NoError(t, repo.Update(ctx, entity))
+	assert.Equal(t, 2, failing.attempts)
+
+	failing.attempts = 0
+	failing.errs = []error{errors.New("try again later")}
+	deleted, err := repo.DeleteAllByConditions(ctx, "age = ?", 31)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	assert.Equal(t, 2, failing.attempts)
+
+	snapshot := repo.GetMetrics()
+	assert.Equal(t, int64(5), snapshot.Retries)
+	assert.Equal(t, int64(2), snapshot.Operations["Create"].Retries)
+	assert.Equal(t, int64(1), snapshot.Operations["FindFirstByID"].Retries)
+}
+
+func TestRetry_GivesUp(t *testing.T) {
+	repo, failing := setupRetryRepository(t, testRetryConfig())
+	ctx := context.Background()
+
+	// Attempts are bounded by MaxAttempts
+	failing.errs = []error{errors.New("connection refused"), errors.New("connection refused"), errors.New("connection refused"), errors.New("connection refused")}
+	err := repo.Create(ctx, &TestEntity{Name: "Alice", Age: 30})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection refused")
+	assert.Equal(t, 3, failing.attempts)
+	failing.errs = nil
+
+	// Permanent errors are returned right away
+	for _, permanent := range []error{errors.New("UNIQUE constraint failed"), errors.New("connection reset by policy")} {
+		failing.attempts = 0
+		failing.errs = []error{permanent}
+		require.Error(t, repo.Create(ctx, &TestEntity{Name: "Bob", Age: 40}))
+		assert.Equal(t, 1, failing.attempts)
+	}
+
+	failing.attempts = 0
+	_, err = repo.FindFirstByID(ctx, uuid.New())
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+	assert.Equal(t, 1, failing.attempts)
+	assert.Equal(t, int64(2), repo.GetMetrics().Retries)
+}
+
+func TestRetry_ContextAndTransactions(t *testing.T) {
+	retry := testRetryConfig()
+	retry.InitialDelay = time.Second
+	retry.MaxDelay = time.Second
+	repo, failing := setupRetryRepository(t, retry)
+
+	// Backoff stops when the context ends
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	failing.errs = []error{errors.New("connection refused")}
+	start := time.Now()
+	err := repo.Create(ctx, &TestEntity{Name: "Alice", Age: 30})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection refused")
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, 1, failing.attempts)
+
+	// Statements inside transactions are not retried
+	failing.attempts = 0
+	failing.errs = []error{errors.New("connection refused")}
+	err = repo.WithTransaction(context.Background(), func(tx repository.Repository[TestEntity]) error {
+		return tx.Create(context.Background(), &TestEntity{Name: "Bob", Age: 40})
+	})
+	require.Error(t, err)
+	assert.Equal(t, 1, failing.attempts)
+
+	// Disabled retries run statements once
+	disabled := testRetryConfig()
+	disabled.Enabled = false
+	repo, failing = setupRetryRepository(t, disabled)
+	failing.errs = []error{errors.New("connection refused")}
+	require.Error(t, repo.Create(context.Background(), &TestEntity{Name: "Carol", Age: 50}))
+	assert.Equal(t, 1, failing.attempts)
+}
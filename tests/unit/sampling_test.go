@@ -0,0 +1,104 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestDialect_TableSample(t *testing.T) {
+	clause, ok := dialect.Postgres{}.TableSample(2.5)
+	assert.True(t, ok)
+	assert.Equal(t, "TABLESAMPLE BERNOULLI (2.5)", clause)
+
+	clause, ok = dialect.SQLServer{}.TableSample(150)
+	assert.True(t, ok)
+	assert.Equal(t, "TABLESAMPLE (100 PERCENT)", clause)
+
+	_, ok = dialect.CockroachDB{}.TableSample(10)
+	assert.False(t, ok)
+
+	_, ok = dialect.Dialect(dialect.SQLite{}).(dialect.TableSampler)
+	assert.False(t, ok)
+
+	assert.Equal(t, "NEWID()", dialect.SQLServer{}.Random())
+	assert.Equal(t, "RAND()", dialect.MySQL{}.Random())
+}
+
+func TestBaseRepository_SampleByConditions(t *testing.T) {
+	db := setupTestDB(t)
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	config := repository.DefaultRepositoryConfig()
+	config.MaxSampleScanRows = 40
+	repo := repository.NewBaseRepository[TestEntity](db, logger, config)
+	ctx := context.Background()
+
+	for i := 0; i < 30; i++ {
+		require.NoError(t, repo.Create(ctx, &TestEntity{Name: fmt.Sprintf("s-%d", i), Age: i}))
+	}
+
+	for _, strategy := range []repository.SampleStrategy{repository.SampleAuto, repository.SampleRandom, repository.SampleTableSample, repository.SampleReservoir} {
+		t.Run(string(strategy), func(t *testing.T) {
+			var sample []TestEntity
+			require.NoError(t, repo.SampleByConditions(ctx, 5, strategy, &sample, "age >= ?", 10))
+			require.Len(t, sample, 5)
+
+			seen := make(map[string]bool)
+			for _, e := range sample {
+				assert.GreaterOrEqual(t, e.Age, 10)
+				assert.False(t, seen[e.Name], "duplicate row in sample")
+				seen[e.Name] = true
+			}
+		})
+	}
+
+	// Asking for more rows than match returns every match
+	var all []TestEntity
+	require.NoError(t, repo.SampleByConditions(ctx, 100, repository.SampleReservoir, &all, "age < ?", 3))
+	assert.Len(t, all, 3)
+
+	// Random ordering and reservoirs are refused once too many rows match,
+	// rather than sampling the first rows scanned
+	for i := 30; i < 50; i++ {
+		require.NoError(t, repo.Create(ctx, &TestEntity{Name: fmt.Sprintf("s-%d", i), Age: i}))
+	}
+	var sample []TestEntity
+	assert.Error(t, repo.SampleByConditions(ctx, 5, repository.SampleRandom, &sample))
+	assert.ErrorContains(t, repo.SampleByConditions(ctx, 5, repository.SampleReservoir, &sample), "refusing to scan 50 rows")
+	require.NoError(t, repo.SampleByConditions(ctx, 5, repository.SampleReservoir, &sample, "age >= ?", 20))
+	assert.Len(t, sample, 5)
+
+	assert.Error(t, repo.SampleByConditions(ctx, 5, repository.SampleStrategy("systematic"), &sample))
+}
+
+func TestBaseRepository_SamplesTableOfSchema(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&plainWidget{}))
+	logger := logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{})
+	ctx := context.Background()
+
+	widgets := repository.NewBaseRepository[plainWidget](db, logger, nil)
+	for i := 0; i < 20; i++ {
+		require.NoError(t, widgets.Create(ctx, &plainWidget{Name: fmt.Sprintf("w-%d", i)}))
+	}
+
+	var captured string
+	require.NoError(t, db.Callback().Query().After("gorm:query").Register("test:capture_sql", func(tx *gorm.DB) {
+		captured = tx.Statement.SQL.String()
+	}))
+
+	// SQLite has no TABLESAMPLE, so the statement built for Postgres fails
+	config := repository.DefaultRepositoryConfig()
+	config.Dialect = "postgres"
+	sampled := repository.NewBaseRepository[plainWidget](db, logger, config)
+	var sample []plainWidget
+	_ = sampled.SampleByConditions(ctx, 2, repository.SampleTableSample, &sample)
+	assert.Contains(t, captured, "FROM plain_widgets TABLESAMPLE BERNOULLI (20)")
+}
@@ -0,0 +1,127 @@
+package unit
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// plainDialector hides the savepoint support of the dialector it wraps
+type plainDialector struct {
+	gorm.Dialector
+}
+
+func TestBaseRepository_SavePoint(t *testing.T) {
+	repo, _ := setupTestRepository(t)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, repo.SavePoint(ctx, "step"), repository.ErrNoTransaction)
+	assert.ErrorIs(t, repo.RollbackTo(ctx, "step"), repository.ErrNoTransaction)
+
+	var committed, rolledBack []string
+	require.NoError(t, repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		assert.Error(t, tx.SavePoint(ctx, "step; DROP TABLE test_entities"))
+
+		require.NoError(t, tx.Create(ctx, &TestEntity{Name: "Order", Age: 1}))
+		tx.AfterCommit(ctx, func(context.Context) { committed = append(committed, "order") })
+
+		// A failing sub-step is retried from its savepoint
+		require.NoError(t, tx.SavePoint(ctx, "payment"))
+		for attempt := 1; ; attempt++ {
+			require.NoError(t, tx.Create(ctx, &TestEntity{Name: "Payment", Age: attempt}))
+			tx.AfterCommit(ctx, func(context.Context) { committed = append(committed, "payment") })
+			tx.AfterRollback(ctx, func(context.Context) { rolledBack = append(rolledBack, "payment") })
+			if attempt == 2 {
+				break
+			}
+			require.NoError(t, tx.RollbackTo(ctx, "payment"))
+		}
+		assert.Equal(t, []string{"payment"}, rolledBack)
+		return nil
+	}))
+
+	assert.Equal(t, []string{"order", "payment"}, committed)
+	var payments []TestEntity
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &payments, "name = ?", "Payment"))
+	require.Len(t, payments, 1)
+	assert.Equal(t, 2, payments[0].Age)
+}
+
+func TestBaseRepository_NewSavePoint(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	ctx := context.Background()
+	assert.True(t, repository.SupportsSavepoints(db))
+
+	require.NoError(t, repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		first, err := tx.NewSavePoint(ctx)
+		require.NoError(t, err)
+		require.NoError(t, tx.Create(ctx, &TestEntity{Name: "Kept", Age: 1}))
+		second, err := tx.NewSavePoint(ctx)
+		require.NoError(t, err)
+		assert.NotEqual(t, first, second)
+		require.NoError(t, tx.Create(ctx, &TestEntity{Name: "Dropped", Age: 2}))
+		require.NoError(t, tx.RollbackTo(ctx, second))
+
+		// CockroachDB's retry savepoint cannot be taken over
+		assert.Error(t, tx.SavePoint(ctx, "cockroach_restart"))
+		return nil
+	}))
+	count, err := repo.CountByConditions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	plain, err := gorm.Open(plainDialector{sqlite.Open(":memory:")}, &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, plain.AutoMigrate(&TestEntity{}))
+	assert.False(t, repository.SupportsSavepoints(plain))
+	unsupported := repository.NewBaseRepository[TestEntity](plain, logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{}), repository.DefaultRepositoryConfig())
+	require.NoError(t, unsupported.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		_, err := tx.NewSavePoint(ctx)
+		assert.ErrorIs(t, err, repository.ErrSavepointsUnsupported)
+		return nil
+	}))
+}
+
+func TestUnitOfWork_SavePoints(t *testing.T) {
+	entities, _, db := setupPropagation(t)
+	ctx := context.Background()
+	uow := repository.NewUnitOfWork(db, logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{}))
+
+	assert.ErrorIs(t, uow.SavePoint(ctx, "step"), repository.ErrNoTransaction)
+	assert.ErrorIs(t, uow.Step(ctx, func(context.Context) error { return nil }), repository.ErrNoTransaction)
+
+	declined := stderrors.New("card declined")
+	var rolledBack []string
+	require.NoError(t, uow.Do(ctx, func(ctx context.Context) error {
+		require.NoError(t, entities.WithTx(ctx).Create(ctx, &TestEntity{Name: "Order", Age: 1}))
+
+		// A failed step only rolls back its own work and callbacks
+		err := uow.Step(ctx, func(ctx context.Context) error {
+			require.NoError(t, entities.WithTx(ctx).Create(ctx, &TestEntity{Name: "Charge", Age: 2}))
+			entities.WithTx(ctx).AfterRollback(ctx, func(context.Context) { rolledBack = append(rolledBack, "charge") })
+			return declined
+		})
+		assert.ErrorIs(t, err, declined)
+		assert.Equal(t, []string{"charge"}, rolledBack)
+
+		require.NoError(t, uow.Step(ctx, func(ctx context.Context) error {
+			return entities.WithTx(ctx).Create(ctx, &TestEntity{Name: "Invoice", Age: 3})
+		}))
+
+		name, err := uow.NewSavePoint(ctx)
+		require.NoError(t, err)
+		require.NoError(t, entities.WithTx(ctx).Create(ctx, &TestEntity{Name: "Draft", Age: 4}))
+		return uow.RollbackTo(ctx, name)
+	}))
+
+	var names []string
+	require.NoError(t, db.Model(&TestEntity{}).Order("age").Pluck("name", &names).Error)
+	assert.Equal(t, []string{"Order", "Invoice"}, names)
+}
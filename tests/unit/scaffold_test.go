@@ -0,0 +1,97 @@
+package unit
+
+import (
+	"context"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/migrations"
+	"github.com/seasbee/go-ormx/pkg/scaffold"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScaffold_Generate(t *testing.T) {
+	for _, driver := range []string{"sqlite", "postgres", "mysql"} {
+		t.Run(driver, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "orders")
+			files, err := scaffold.Generate(dir, scaffold.Options{Module: "example.com/orders", Driver: driver})
+			require.NoError(t, err)
+			assert.Equal(t, []string{
+				"README.md",
+				"config.yaml",
+				"go.mod",
+				"handlers.go",
+				"main.go",
+				"migrations/0001_create_users.down.sql",
+				"migrations/0001_create_users.up.sql",
+				"models/user.go",
+				"repositories/user_repository.go",
+			}, files)
+
+			for _, file := range files {
+				if strings.HasSuffix(file, ".go") {
+					_, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, file), nil, parser.AllErrors)
+					assert.NoError(t, err, file)
+				}
+			}
+
+			goMod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(string(goMod), "module example.com/orders\n"))
+			assert.NotContains(t, string(goMod), "replace")
+
+			repo, err := os.ReadFile(filepath.Join(dir, "repositories", "user_repository.go"))
+			require.NoError(t, err)
+			assert.Contains(t, string(repo), `"example.com/orders/models"`)
+
+			config, err := os.ReadFile(filepath.Join(dir, "config.yaml"))
+			require.NoError(t, err)
+			assert.Contains(t, string(config), "driver: "+driver+"\n")
+		})
+	}
+}
+
+func TestScaffold_Migrations(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "service")
+	_, err := scaffold.Generate(dir, scaffold.Options{ORMXPath: "."})
+	require.NoError(t, err)
+
+	goMod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	require.NoError(t, err)
+	assert.Contains(t, string(goMod), "module service\n")
+	assert.Contains(t, string(goMod), "replace github.com/seasbee/go-ormx => /")
+
+	// The generated migrations apply and roll back
+	db := setupTestDB(t)
+	migrator, err := migrations.NewMigrator(db, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, migrator.AddFS(os.DirFS(dir), "migrations"))
+	ctx := context.Background()
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.True(t, db.Migrator().HasTable("users"))
+	assert.True(t, db.Migrator().HasIndex("users", "idx_users_email"))
+	_, err = migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	assert.False(t, db.Migrator().HasTable("users"))
+}
+
+func TestScaffold_Errors(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o644))
+
+	_, err := scaffold.Generate(dir, scaffold.Options{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not empty")
+
+	_, err = scaffold.Generate(filepath.Join(dir, "other"), scaffold.Options{Driver: "oracle"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported driver")
+	_, err = os.Stat(filepath.Join(dir, "other"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,201 @@
+package unit
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/jobs"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupSchedulerStore(t *testing.T, worker string) (*jobs.SchedulerStore, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	store, err := jobs.NewSchedulerStore(db, logger, &jobs.SchedulerConfig{Worker: worker})
+	require.NoError(t, err)
+	return store, db
+}
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2024, 3, 15, 10, 7, 30, 0, time.UTC) // Friday
+
+	tests := []struct {
+		spec string
+		next time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 3, 15, 10, 15, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2024, 3, 15, 11, 0, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2024, 3, 16, 2, 30, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2024, 3, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 13 * 5", time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)},
+		{"5,10 8 * * *", time.Date(2024, 3, 16, 8, 5, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", from.Add(90 * time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := jobs.ParseSchedule(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.next, schedule.Next(from))
+		})
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "@every 1ms", "@every soon"} {
+		_, err := jobs.ParseSchedule(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestSchedulerStore_ClaimAndComplete(t *testing.T) {
+	store, _ := setupSchedulerStore(t, "worker-a")
+	ctx := context.Background()
+
+	require.NoError(t, store.Define(ctx, &jobs.ScheduledJob{
+		Name:      "report",
+		Schedule:  "@every 1h",
+		Payload:   `{"kind":"daily"}`,
+		NextRunAt: time.Now().Add(-time.Second),
+	}))
+	require.NoError(t, store.Define(ctx, &jobs.ScheduledJob{Name: "later", Schedule: "@every 1h"}))
+	require.NoError(t, store.Define(ctx, &jobs.ScheduledJob{
+		Name:      "paused",
+		Schedule:  "@every 1h",
+		Paused:    true,
+		NextRunAt: time.Now().Add(-time.Second),
+	}))
+
+	claimed, err := store.Claim(ctx)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, "report", claimed[0].Job.Name)
+	assert.Equal(t, "worker-a", claimed[0].Run.Worker)
+	assert.Equal(t, jobs.RunStatusRunning, claimed[0].Run.Status)
+	assert.True(t, claimed[0].Job.NextRunAt.After(time.Now().Add(59*time.Minute)))
+
+	// A claimed job is not claimed again
+	again, err := store.Claim(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, again)
+
+	require.NoError(t, store.Complete(ctx, &claimed[0], stderrors.New("upstream unavailable")))
+
+	job, err := store.Get(ctx, "report")
+	require.NoError(t, err)
+	assert.Empty(t, job.ClaimedBy)
+	assert.Nil(t, job.ClaimedUntil)
+	assert.NotNil(t, job.LastRunAt)
+
+	history, err := store.History(ctx, "report", 10)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, jobs.RunStatusFailed, history[0].Status)
+	assert.Equal(t, "upstream unavailable", history[0].Error)
+	assert.NotNil(t, history[0].FinishedAt)
+
+	require.NoError(t, store.Remove(ctx, "report"))
+	_, err = store.Get(ctx, "report")
+	assert.True(t, stderrors.Is(err, jobs.ErrScheduledJobNotFound))
+	history, err = store.History(ctx, "report", 10)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	assert.Error(t, store.Define(ctx, &jobs.ScheduledJob{Name: "bad", Schedule: "@every 1h", MisfirePolicy: "sometimes"}))
+	assert.Error(t, store.Define(ctx, &jobs.ScheduledJob{Name: "bad", Schedule: "never"}))
+}
+
+func TestSchedulerStore_MisfirePolicies(t *testing.T) {
+	store, _ := setupSchedulerStore(t, "worker-a")
+	ctx := context.Background()
+
+	missed := time.Now().Add(-3*time.Hour - 30*time.Minute)
+	for name, policy := range map[string]string{"once": jobs.MisfireRunOnce, "skip": jobs.MisfireSkip, "catch": jobs.MisfireCatchUp} {
+		require.NoError(t, store.Define(ctx, &jobs.ScheduledJob{Name: name, Schedule: "@every 1h", MisfirePolicy: policy, NextRunAt: missed}))
+	}
+
+	runs := map[string]int{}
+	for i := 0; i < 10; i++ {
+		executed, err := store.RunDue(ctx, func(ctx context.Context, job *jobs.ScheduledJob) error {
+			runs[job.Name]++
+			return nil
+		})
+		require.NoError(t, err)
+		if executed == 0 {
+			break
+		}
+	}
+
+	assert.Equal(t, map[string]int{"once": 1, "catch": 4}, runs)
+
+	history, err := store.History(ctx, "skip", 10)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, jobs.RunStatusSkipped, history[0].Status)
+
+	history, err = store.History(ctx, "catch", 10)
+	require.NoError(t, err)
+	require.Len(t, history, 4)
+	for _, run := range history {
+		assert.Equal(t, jobs.RunStatusSucceeded, run.Status)
+	}
+	assert.True(t, history[0].ScheduledAt.After(history[3].ScheduledAt))
+
+	for _, name := range []string{"once", "skip", "catch"} {
+		job, err := store.Get(ctx, name)
+		require.NoError(t, err)
+		assert.True(t, job.NextRunAt.After(time.Now()), name)
+	}
+}
+
+func TestSchedulerStore_ConcurrentWorkers(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:scheduler_workers?mode=memory&cache=shared&_busy_timeout=5000"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	var stores []*jobs.SchedulerStore
+	for _, worker := range []string{"worker-a", "worker-b", "worker-c"} {
+		store, err := jobs.NewSchedulerStore(db, nil, &jobs.SchedulerConfig{Worker: worker, ClaimBatchSize: 2})
+		require.NoError(t, err)
+		stores = append(stores, store)
+	}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, stores[0].Define(ctx, &jobs.ScheduledJob{Name: name, Schedule: "@every 1h", NextRunAt: time.Now().Add(-time.Second)}))
+	}
+
+	var mu sync.Mutex
+	runs := map[string]int{}
+	var wg sync.WaitGroup
+	for _, store := range stores {
+		wg.Add(1)
+		go func(store *jobs.SchedulerStore) {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				_, err := store.RunDue(ctx, func(ctx context.Context, job *jobs.ScheduledJob) error {
+					mu.Lock()
+					defer mu.Unlock()
+					runs[job.Name]++
+					return nil
+				})
+				assert.NoError(t, err)
+			}
+		}(store)
+	}
+	wg.Wait()
+
+	assert.Equal(t, map[string]int{"a": 1, "b": 1, "c": 1, "d": 1, "e": 1}, runs)
+}
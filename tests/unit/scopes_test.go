@@ -0,0 +1,105 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/seasbee/go-ormx/pkg/scopes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type scopedEntity struct {
+	models.BaseModel
+	TenantID string `gorm:"index;not null"`
+	IsActive bool   `gorm:"not null"`
+	Name     string `gorm:"not null"`
+}
+
+func TestScopes(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&scopedEntity{}))
+
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	repo := repository.NewBaseRepository[scopedEntity](db, logger, nil)
+	ctx := context.Background()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	entities := []*scopedEntity{
+		{TenantID: "acme", IsActive: true, Name: "a"},
+		{TenantID: "acme", IsActive: false, Name: "b"},
+		{TenantID: "globex", IsActive: true, Name: "c"},
+		{TenantID: "acme", IsActive: true, Name: "d"},
+	}
+	for i, entity := range entities {
+		require.NoError(t, repo.Create(ctx, entity))
+		stamp := base.Add(time.Duration(i) * 24 * time.Hour)
+		require.NoError(t, db.Model(entity).UpdateColumns(map[string]interface{}{"created_at": stamp, "updated_at": stamp}).Error)
+	}
+
+	names := func(found []scopedEntity) []string {
+		result := make([]string, len(found))
+		for i, entity := range found {
+			result[i] = entity.Name
+		}
+		return result
+	}
+
+	t.Run("FindAll conditions", func(t *testing.T) {
+		var found []scopedEntity
+		err := repo.FindAllByConditionsWithOffset(ctx, 10, 0, &found, scopes.ByTenant("acme"), scopes.ActiveOnly())
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"a", "d"}, names(found))
+
+		count, err := repo.CountByConditions(ctx, scopes.CreatedBetween(base.Add(24*time.Hour), base.Add(3*24*time.Hour)))
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("Query builder", func(t *testing.T) {
+		found, err := repo.Query().Scopes(scopes.UpdatedSince(base.Add(2 * 24 * time.Hour))).OrderBy("name").Find(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"c", "d"}, names(found))
+
+		found, err = repo.Query().Where(scopes.IDIn(entities[0].ID, entities[2].ID)).OrderBy("name").Find(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "c"}, names(found))
+
+		found, err = repo.Query().Scopes(scopes.IDIn()).Find(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, found)
+	})
+
+	t.Run("Composition", func(t *testing.T) {
+		found, err := repo.Query().Scopes(
+			scopes.Any(scopes.ByTenant("globex"), scopes.Not(scopes.ActiveOnly())),
+		).OrderBy("name").Find(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b", "c"}, names(found))
+
+		// Open bounds
+		count, err := repo.CountByConditions(ctx, scopes.All(scopes.CreatedBetween(time.Time{}, base.Add(time.Hour)), scopes.ByTenant("acme")))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		count, err = repo.CountByConditions(ctx, scopes.CreatedBetween(time.Time{}, time.Time{}))
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), count)
+	})
+
+	t.Run("Soft deleted entities stay excluded", func(t *testing.T) {
+		require.NoError(t, repo.SoftDeleteByID(ctx, entities[3].ID))
+
+		count, err := repo.Query().Scopes(scopes.ByTenant("acme")).Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+
+	})
+}
@@ -0,0 +1,175 @@
+package unit
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupSettingsStore(t *testing.T, config *settings.SettingsConfig) (*settings.SettingsStore, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	store, err := settings.NewSettingsStore(db, logger, config)
+	require.NoError(t, err)
+	return store, db
+}
+
+func TestSettingsStore_TypedGetters(t *testing.T) {
+	store, _ := setupSettingsStore(t, nil)
+	ctx := context.Background()
+
+	_, err := store.Set(ctx, "billing", "currency", "EUR")
+	require.NoError(t, err)
+	_, err = store.Set(ctx, "billing", "max_retries", 5)
+	require.NoError(t, err)
+	_, err = store.Set(ctx, "billing", "tax_rate", 0.21)
+	require.NoError(t, err)
+	_, err = store.Set(ctx, "billing", "enabled", true)
+	require.NoError(t, err)
+	_, err = store.Set(ctx, "billing", "timeout", 90*time.Second)
+	require.NoError(t, err)
+	_, err = store.Set(ctx, "billing", "grace", "1h")
+	require.NoError(t, err)
+
+	currency, err := store.GetString(ctx, "billing", "currency", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, "EUR", currency)
+
+	retries, err := store.GetInt(ctx, "billing", "max_retries", 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), retries)
+
+	rate, err := store.GetFloat(ctx, "billing", "tax_rate", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0.21, rate)
+
+	enabled, err := store.GetBool(ctx, "billing", "enabled", false)
+	require.NoError(t, err)
+	assert.True(t, enabled)
+
+	timeout, err := store.GetDuration(ctx, "billing", "timeout", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Second, timeout)
+
+	grace, err := store.GetDuration(ctx, "billing", "grace", 0)
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, grace)
+
+	// Missing keys and other namespaces return the fallback
+	currency, err = store.GetString(ctx, "shipping", "currency", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, "USD", currency)
+
+	_, err = store.Get(ctx, "shipping", "currency")
+	assert.True(t, stderrors.Is(err, settings.ErrSettingNotFound))
+
+	// Type mismatches are reported with the fallback
+	retries, err = store.GetInt(ctx, "billing", "currency", 3)
+	assert.Error(t, err)
+	assert.Equal(t, int64(3), retries)
+
+	var limits struct {
+		Daily int `json:"daily"`
+	}
+	_, err = store.Set(ctx, "billing", "limits", map[string]int{"daily": 100})
+	require.NoError(t, err)
+	require.NoError(t, store.GetJSON(ctx, "billing", "limits", &limits))
+	assert.Equal(t, 100, limits.Daily)
+
+	list, err := store.List(ctx, "billing")
+	require.NoError(t, err)
+	require.Len(t, list, 7)
+	assert.Equal(t, "currency", list[0].Key)
+}
+
+func TestSettingsStore_Versions(t *testing.T) {
+	store, _ := setupSettingsStore(t, nil)
+	ctx := context.Background()
+
+	created, err := store.CompareAndSet(ctx, "app", "mode", "blue", 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), created.Version)
+
+	_, err = store.CompareAndSet(ctx, "app", "mode", "green", 0)
+	assert.True(t, stderrors.Is(err, settings.ErrVersionConflict))
+
+	updated, err := store.CompareAndSet(ctx, "app", "mode", "green", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Version)
+
+	// A writer holding a stale version loses
+	_, err = store.CompareAndSet(ctx, "app", "mode", "red", 1)
+	assert.True(t, stderrors.Is(err, settings.ErrVersionConflict))
+
+	replaced, err := store.Set(ctx, "app", "mode", "red")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), replaced.Version)
+
+	mode, err := store.GetString(ctx, "app", "mode", "")
+	require.NoError(t, err)
+	assert.Equal(t, "red", mode)
+
+	require.NoError(t, store.Delete(ctx, "app", "mode"))
+	require.NoError(t, store.Delete(ctx, "app", "mode"))
+	_, err = store.Get(ctx, "app", "mode")
+	assert.True(t, stderrors.Is(err, settings.ErrSettingNotFound))
+}
+
+func TestSettingsStore_CacheAndNotifications(t *testing.T) {
+	store, db := setupSettingsStore(t, &settings.SettingsConfig{CacheTTL: time.Hour})
+	ctx := context.Background()
+
+	var changes []settings.Change
+	unsubscribe := store.Subscribe(func(change settings.Change) {
+		changes = append(changes, change)
+	})
+
+	_, err := store.Set(ctx, "flags", "beta", false)
+	require.NoError(t, err)
+
+	beta, err := store.GetBool(ctx, "flags", "beta", true)
+	require.NoError(t, err)
+	assert.False(t, beta)
+
+	// Writes bypassing the store are only seen once the cache is cleared
+	require.NoError(t, db.Model(&settings.Setting{}).Where("namespace = ?", "flags").Update("value", "true").Error)
+	beta, err = store.GetBool(ctx, "flags", "beta", false)
+	require.NoError(t, err)
+	assert.False(t, beta)
+
+	store.ClearCache()
+	beta, err = store.GetBool(ctx, "flags", "beta", false)
+	require.NoError(t, err)
+	assert.True(t, beta)
+
+	// Writes through the store invalidate the cache
+	_, err = store.Set(ctx, "flags", "beta", false)
+	require.NoError(t, err)
+	beta, err = store.GetBool(ctx, "flags", "beta", true)
+	require.NoError(t, err)
+	assert.False(t, beta)
+
+	require.NoError(t, store.Delete(ctx, "flags", "beta"))
+
+	unsubscribe()
+	_, err = store.Set(ctx, "flags", "beta", true)
+	require.NoError(t, err)
+
+	require.Len(t, changes, 3)
+	assert.Equal(t, settings.Change{Namespace: "flags", Key: "beta", Value: "false", Version: 1}, changes[0])
+	assert.Equal(t, int64(2), changes[1].Version)
+	assert.True(t, changes[2].Deleted)
+
+	snapshot := store.Repository().GetMetrics()
+	assert.Contains(t, snapshot.Operations, "FindFirstByConditions")
+}
@@ -0,0 +1,116 @@
+package unit
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// failStatements runs n statements failing with a timeout
+func failStatements(t *testing.T, repo *repository.BaseRepository[TestEntity], n int) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	for i := 0; i < n; i++ {
+		_, err := repo.CountByConditions(ctx)
+		require.Error(t, err)
+	}
+}
+
+func TestLoadShedder_ErrorRate(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	shedder := repository.NewLoadShedder(&repository.ShedConfig{
+		Window:          150 * time.Millisecond,
+		MinRequests:     5,
+		MaxErrorRate:    0.5,
+		MaxShedPriority: repository.PriorityNormal,
+	})
+	require.NoError(t, db.Use(shedder))
+
+	low := repository.WithPriority(context.Background(), repository.PriorityLow)
+	high := repository.WithPriority(context.Background(), repository.PriorityHigh)
+
+	_, err := repo.CountByConditions(low)
+	require.NoError(t, err)
+
+	// A brownout sheds the low priority statements first
+	failStatements(t, repo, 5)
+	stats := shedder.Stats()
+	assert.Equal(t, 1, stats.Level)
+	assert.Equal(t, int64(5), stats.Errors)
+
+	_, err = repo.CountByConditions(low)
+	require.Error(t, err)
+	assert.True(t, stderrors.Is(err, repository.ErrLoadShed))
+	var ormErr *errors.ORMError
+	require.True(t, stderrors.As(err, &ormErr))
+	assert.Equal(t, errors.ErrorTypeResource, ormErr.Type)
+	assert.True(t, ormErr.Retryable)
+	assert.Positive(t, ormErr.RetryDelay)
+	assert.LessOrEqual(t, ormErr.RetryDelay, 150*time.Millisecond)
+	assert.Equal(t, int64(1), shedder.Stats().Shed["low"])
+
+	_, err = repo.CountByConditions(context.Background())
+	require.NoError(t, err)
+
+	// Statements of transactions are never shed
+	require.NoError(t, db.WithContext(low).Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&TestEntity{Name: "Alice", Age: 30}).Error
+	}))
+
+	// A breach in the next window escalates further, up to MaxShedPriority
+	time.Sleep(160 * time.Millisecond)
+	failStatements(t, repo, 5)
+	assert.Equal(t, 2, shedder.Stats().Level)
+	assert.False(t, shedder.Allow(repository.PriorityNormal))
+	_, err = repo.CountByConditions(context.Background())
+	assert.True(t, stderrors.Is(err, repository.ErrLoadShed))
+	_, err = repo.CountByConditions(high)
+	require.NoError(t, err)
+
+	failStatements(t, repo, 5)
+	time.Sleep(160 * time.Millisecond)
+	assert.Equal(t, 2, shedder.Stats().Level)
+
+	// Healthy windows bring the priorities back one at a time
+	time.Sleep(160 * time.Millisecond)
+	assert.Equal(t, 1, shedder.Stats().Level)
+	assert.True(t, shedder.Allow(repository.PriorityNormal))
+	assert.False(t, shedder.Allow(repository.PriorityLow))
+	time.Sleep(160 * time.Millisecond)
+	assert.Equal(t, 0, shedder.Stats().Level)
+	_, err = repo.CountByConditions(low)
+	require.NoError(t, err)
+}
+
+func TestLoadShedder_Latency(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	shedder := repository.NewLoadShedder(&repository.ShedConfig{
+		Window:      time.Minute,
+		MinRequests: 3,
+		MaxLatency:  time.Nanosecond,
+	})
+	require.NoError(t, db.Use(shedder))
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := repo.CountByConditions(ctx)
+		require.NoError(t, err)
+	}
+	stats := shedder.Stats()
+	assert.Equal(t, 1, stats.Level)
+	assert.Zero(t, stats.ErrorRate)
+	assert.Positive(t, stats.Latency)
+
+	// Critical statements are never shed
+	assert.False(t, shedder.Allow(repository.PriorityLow))
+	assert.True(t, shedder.Allow(repository.PriorityNormal))
+	_, err := repo.CountByConditions(repository.WithPriority(ctx, repository.PriorityCritical))
+	require.NoError(t, err)
+}
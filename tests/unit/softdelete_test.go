@@ -0,0 +1,117 @@
+package unit
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// gormSoftEntity uses gorm's native soft delete field
+type gormSoftEntity struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Name      string
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func TestBaseRepository_SoftDelete(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	ctx := context.Background()
+
+	alive := &TestEntity{Name: "alive", Age: 1}
+	deleted := &TestEntity{Name: "deleted", Age: 1}
+	require.NoError(t, repo.Create(ctx, alive))
+	require.NoError(t, repo.Create(ctx, deleted))
+
+	require.NoError(t, repo.SoftDeleteByID(ctx, deleted.ID))
+
+	// The row is kept but hidden from reads
+	var rows int64
+	require.NoError(t, db.Model(&TestEntity{}).Count(&rows).Error)
+	assert.Equal(t, int64(2), rows)
+
+	_, err := repo.FindFirstByID(ctx, deleted.ID)
+	assert.True(t, stderrors.Is(err, gorm.ErrRecordNotFound))
+
+	count, err := repo.CountByConditions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	var all []TestEntity
+	require.NoError(t, repo.FindAllIncludingDeleted(ctx, 10, 0, &all))
+	assert.Len(t, all, 2)
+
+	found, err := repo.FindFirstByID(repository.WithDeleted(ctx), deleted.ID)
+	require.NoError(t, err)
+	assert.True(t, found.IsDeleted())
+
+	withDeleted, err := repo.Query().WithDeleted().Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), withDeleted)
+
+	// Deleting twice reports the entity as missing
+	err = repo.SoftDeleteByID(ctx, deleted.ID)
+	assert.True(t, stderrors.Is(err, gorm.ErrRecordNotFound))
+
+	require.NoError(t, repo.RestoreByID(ctx, deleted.ID))
+	_, err = repo.FindFirstByID(ctx, deleted.ID)
+	require.NoError(t, err)
+	err = repo.RestoreByID(ctx, deleted.ID)
+	assert.True(t, stderrors.Is(err, gorm.ErrRecordNotFound))
+
+	affected, err := repo.SoftDeleteByConditions(ctx, "name IN ?", []string{"alive", "deleted"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+
+	_, err = repo.SoftDeleteByConditions(ctx)
+	assert.Error(t, err)
+}
+
+func TestBaseRepository_DeleteMode(t *testing.T) {
+	db := setupTestDB(t)
+	logger := logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{})
+	ctx := context.Background()
+
+	config := repository.DefaultRepositoryConfig()
+	config.DeleteMode = repository.DeleteModeSoft
+	soft := repository.NewBaseRepository[TestEntity](db, logger, config)
+
+	entity := &TestEntity{Name: "soft", Age: 1}
+	require.NoError(t, soft.Create(ctx, entity))
+	require.NoError(t, soft.DeleteByID(ctx, entity.ID))
+
+	var rows int64
+	require.NoError(t, db.Model(&TestEntity{}).Where("deleted_at IS NOT NULL").Count(&rows).Error)
+	assert.Equal(t, int64(1), rows)
+
+	// The default mode hard deletes models without gorm.DeletedAt
+	hard := repository.NewBaseRepository[TestEntity](db, logger, nil)
+	other := &TestEntity{Name: "hard", Age: 1}
+	require.NoError(t, hard.Create(ctx, other))
+	require.NoError(t, hard.DeleteByConditions(ctx, &TestEntity{}, "name = ?", "hard"))
+	require.NoError(t, db.Model(&TestEntity{}).Where("name = ?", "hard").Count(&rows).Error)
+	assert.Equal(t, int64(0), rows)
+
+	// gorm.DeletedAt models are soft deleted by default and hard deleted in hard mode
+	require.NoError(t, db.AutoMigrate(&gormSoftEntity{}))
+	native := repository.NewBaseRepository[gormSoftEntity](db, logger, nil)
+	id := uuid.New()
+	require.NoError(t, db.Create(&gormSoftEntity{ID: id, Name: "native"}).Error)
+	require.NoError(t, native.DeleteByID(ctx, id))
+	require.NoError(t, db.Unscoped().Model(&gormSoftEntity{}).Count(&rows).Error)
+	assert.Equal(t, int64(1), rows)
+	require.NoError(t, native.RestoreByID(ctx, id))
+
+	config = repository.DefaultRepositoryConfig()
+	config.DeleteMode = repository.DeleteModeHard
+	nativeHard := repository.NewBaseRepository[gormSoftEntity](db, logger, config)
+	require.NoError(t, nativeHard.DeleteByID(ctx, id))
+	require.NoError(t, db.Unscoped().Model(&gormSoftEntity{}).Count(&rows).Error)
+	assert.Equal(t, int64(0), rows)
+}
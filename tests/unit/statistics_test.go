@@ -0,0 +1,206 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statsDialect runs on SQLite and reports canned planner and table statistics
+type statsDialect struct {
+	dialect.SQLite
+}
+
+func (statsDialect) Name() string { return "sqlite_stats" }
+
+// ExplainRows runs the query itself, reporting ten times its row count as the estimate
+func (statsDialect) ExplainRows(query string) string {
+	return "SELECT COUNT(*) * 10 AS estimate FROM (" + query + ")"
+}
+
+func (statsDialect) PlanRows(plan []map[string]interface{}) (int64, bool) {
+	if len(plan) == 0 {
+		return 0, false
+	}
+	rows, ok := plan[0]["estimate"].(int64)
+	return rows, ok
+}
+
+// EstimateRows reports 1000 rows for existing tables and nothing for missing ones
+func (statsDialect) EstimateRows() string {
+	return "SELECT CASE WHEN EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?) THEN 1000 END"
+}
+
+func (statsDialect) TableStats() (string, bool) {
+	return "SELECT COUNT(*) AS row_estimate, 8192 AS table_bytes, 4096 AS index_bytes, 3 AS dead_tuples, " +
+		"NULL AS last_vacuum, NULL AS last_analyze FROM sqlite_master m JOIN test_entities ON 1 = 1 " +
+		"WHERE m.type = 'table' AND m.name = ?", true
+}
+
+func (statsDialect) IndexStats() string {
+	return "SELECT name AS index_name, 2048 AS index_bytes FROM sqlite_master WHERE type = 'index' AND tbl_name = ? ORDER BY name"
+}
+
+func init() {
+	dialect.Register(statsDialect{})
+}
+
+// seedStatsEntities creates entities aged 20 to 29
+func seedStatsEntities(t *testing.T, repo *repository.BaseRepository[TestEntity]) {
+	for i := 0; i < 10; i++ {
+		require.NoError(t, repo.Create(context.Background(), &TestEntity{Name: "Entity", Age: 20 + i}))
+	}
+}
+
+func TestBaseRepository_EstimateCount(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	ctx := context.Background()
+	seedStatsEntities(t, repo)
+
+	// SQLite has no planner estimates, the count is exact
+	count, err := repo.EstimateCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), count)
+
+	count, err = repo.EstimateCount(ctx, "age >= ?", 25)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), count)
+
+	var first TestEntity
+	require.NoError(t, repo.FindFirstByConditions(ctx, &first, "age = ?", 25))
+	require.NoError(t, repo.SoftDeleteByID(ctx, first.GetID()))
+	count, err = repo.EstimateCount(ctx, "age >= ?", 25)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+
+	t.Run("planner estimate", func(t *testing.T) {
+		config := repository.DefaultRepositoryConfig()
+		config.Dialect = "sqlite_stats"
+		statsRepo := repository.NewBaseRepository[TestEntity](db, logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{}), config)
+
+		// The explained query keeps its bind variables and soft delete scope
+		count, err := statsRepo.EstimateCount(ctx, "age >= ? AND name = ?", 25, "Entity")
+		require.NoError(t, err)
+		assert.Equal(t, int64(40), count)
+	})
+}
+
+func TestBaseRepository_TableStats(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	ctx := context.Background()
+	seedStatsEntities(t, repo)
+
+	stats, err := repo.TableStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "test_entities", stats.Table)
+	assert.Equal(t, "sqlite", stats.Dialect)
+	assert.Equal(t, int64(10), stats.EstimatedRows)
+	assert.True(t, stats.ExactRowCount)
+	assert.False(t, stats.Detailed)
+	assert.Zero(t, stats.TotalBytes)
+
+	t.Run("dialect statistics", func(t *testing.T) {
+		require.NoError(t, db.Exec("CREATE INDEX idx_test_entities_age ON test_entities (age)").Error)
+
+		config := repository.DefaultRepositoryConfig()
+		config.Dialect = "sqlite_stats"
+		statsRepo := repository.NewBaseRepository[TestEntity](db, logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{}), config)
+
+		stats, err := statsRepo.TableStats(ctx)
+		require.NoError(t, err)
+		assert.True(t, stats.Detailed)
+		assert.False(t, stats.ExactRowCount)
+		assert.Equal(t, int64(10), stats.EstimatedRows)
+		assert.Equal(t, int64(8192), stats.TableBytes)
+		assert.Equal(t, int64(4096), stats.IndexBytes)
+		assert.Equal(t, int64(12288), stats.TotalBytes)
+		require.NotNil(t, stats.DeadTuples)
+		assert.Equal(t, int64(3), *stats.DeadTuples)
+		assert.Nil(t, stats.LastVacuum)
+		assert.Nil(t, stats.LastAnalyze)
+		require.NotEmpty(t, stats.Indexes)
+		assert.Contains(t, stats.Indexes, repository.IndexStats{Name: "idx_test_entities_age", Bytes: 2048})
+	})
+
+	t.Run("missing table", func(t *testing.T) {
+		require.NoError(t, db.Migrator().DropTable(&TestEntity{}))
+		_, err := repo.TableStats(ctx)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "does not exist")
+	})
+}
+
+func TestBaseRepository_StatisticsOfSchemaTable(t *testing.T) {
+	_, db := setupTestRepository(t)
+	ctx := context.Background()
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	require.NoError(t, db.AutoMigrate(&plainWidget{}))
+	widgets := repository.NewBaseRepository[plainWidget](db, logger, nil)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, widgets.Create(ctx, &plainWidget{Name: "widget"}))
+	}
+
+	stats, err := widgets.TableStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "plain_widgets", stats.Table)
+	assert.Equal(t, int64(3), stats.EstimatedRows)
+
+	config := repository.DefaultRepositoryConfig()
+	config.Dialect = "sqlite_stats"
+	statsWidgets := repository.NewBaseRepository[plainWidget](db, logger, config)
+
+	// The table statistics are read for plain_widgets, not for the type name
+	count, err := statsWidgets.EstimateCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), count)
+
+	stats, err = statsWidgets.TableStats(ctx)
+	require.NoError(t, err)
+	assert.True(t, stats.Detailed)
+	assert.Contains(t, stats.Indexes, repository.IndexStats{Name: "idx_plain_widgets_deleted_at", Bytes: 2048})
+}
+
+func TestDialect_PlanRows(t *testing.T) {
+	postgres := dialect.Postgres{}
+	assert.Equal(t, "EXPLAIN (FORMAT JSON) SELECT 1", postgres.ExplainRows("SELECT 1"))
+	rows, ok := postgres.PlanRows([]map[string]interface{}{
+		{"QUERY PLAN": []byte(`[{"Plan": {"Node Type": "Seq Scan", "Plan Rows": 1234, "Plan Width": 8}}]`)},
+	})
+	assert.True(t, ok)
+	assert.Equal(t, int64(1234), rows)
+	_, ok = postgres.PlanRows(nil)
+	assert.False(t, ok)
+
+	cockroach := dialect.CockroachDB{}
+	rows, ok = cockroach.PlanRows([]map[string]interface{}{
+		{"info": "distribution: local"},
+		{"info": "• filter"},
+		{"info": "│ estimated row count: 12,345"},
+		{"info": "  estimated row count: 1,000,000 (100% of the table)"},
+	})
+	assert.True(t, ok)
+	assert.Equal(t, int64(12345), rows)
+
+	mysql := dialect.MySQL{}
+	rows, ok = mysql.PlanRows([]map[string]interface{}{
+		{"table": []byte("users"), "rows": []byte("1000"), "filtered": []byte("12.50")},
+	})
+	assert.True(t, ok)
+	assert.Equal(t, int64(125), rows)
+
+	_, ok = cockroach.TableStats()
+	assert.False(t, ok)
+	_, ok = postgres.TableStats()
+	assert.True(t, ok)
+
+	var sqlite dialect.Dialect = dialect.SQLite{}
+	_, ok = sqlite.(dialect.PlanEstimator)
+	assert.False(t, ok)
+	_, ok = sqlite.(dialect.TableStatsReader)
+	assert.False(t, ok)
+}
@@ -0,0 +1,183 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// tenantNote is an entity owned by a tenant
+type tenantNote struct {
+	models.BaseModel
+	TenantID string `gorm:"index"`
+	Title    string `gorm:"not null"`
+}
+
+// setupTenantRepository creates a tenant-scoped repository of notes
+func setupTenantRepository(t *testing.T, config *repository.TenancyConfig) (*repository.TenantScopedRepository[tenantNote], *gorm.DB) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&tenantNote{}))
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	base := repository.NewBaseRepository[tenantNote](db, logger, repository.DefaultRepositoryConfig())
+
+	repo, err := repository.NewTenantScopedRepository(base, config)
+	require.NoError(t, err)
+	return repo, db
+}
+
+func TestTenantScopedRepository_Reads(t *testing.T) {
+	repo, _ := setupTenantRepository(t, nil)
+	acme := repository.WithTenant(context.Background(), "acme")
+	globex := repository.WithTenant(context.Background(), "globex")
+
+	first := &tenantNote{Title: "first"}
+	require.NoError(t, repo.Create(acme, first))
+	assert.Equal(t, "acme", first.TenantID)
+	require.NoError(t, repo.Create(acme, &tenantNote{Title: "second"}))
+	other := &tenantNote{Title: "first"}
+	require.NoError(t, repo.Create(globex, other))
+	assert.Equal(t, "globex", other.TenantID)
+
+	var notes []tenantNote
+	require.NoError(t, repo.FindAllWithOffset(acme, 10, 0, &notes))
+	assert.Len(t, notes, 2)
+
+	count, err := repo.CountByConditions(acme)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// OR conditions stay within the tenant
+	count, err = repo.CountByConditions(acme, "title = ? OR title = ?", "first", "second")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	count, err = repo.CountByConditions(globex, map[string]interface{}{"title": "first"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// Entities of other tenants are not found
+	_, err = repo.FindFirstByID(acme, other.ID)
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+	found, err := repo.FindFirstByID(globex, other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, other.ID, found.ID)
+
+	exists, err := repo.ExistsByID(acme, other.ID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	var note tenantNote
+	require.NoError(t, repo.FindFirstBy(globex, &note, "title", "first"))
+	assert.Equal(t, other.ID, note.ID)
+
+	// The underlying repository is not scoped
+	count, err = repo.Unscoped().CountByConditions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+}
+
+func TestTenantScopedRepository_CrossTenantWrites(t *testing.T) {
+	repo, db := setupTenantRepository(t, nil)
+	acme := repository.WithTenant(context.Background(), "acme")
+	globex := repository.WithTenant(context.Background(), "globex")
+
+	mine := &tenantNote{Title: "mine"}
+	require.NoError(t, repo.Create(acme, mine))
+	theirs := &tenantNote{Title: "theirs"}
+	require.NoError(t, repo.Create(globex, theirs))
+
+	// Creating or updating an entity of another tenant is refused
+	err := repo.Create(acme, &tenantNote{TenantID: "globex", Title: "forged"})
+	assert.True(t, errors.Is(err, repository.ErrCrossTenant))
+
+	stolen := *theirs
+	stolen.TenantID = "acme"
+	stolen.Title = "stolen"
+	assert.True(t, errors.Is(repo.Update(acme, &stolen), repository.ErrCrossTenant))
+	assert.True(t, errors.Is(repo.DeleteByID(acme, theirs.ID), repository.ErrCrossTenant))
+	assert.True(t, errors.Is(repo.SoftDeleteByID(acme, theirs.ID), repository.ErrCrossTenant))
+
+	_, err = repo.UpdateAllByConditions(acme, map[string]interface{}{"tenant_id": "globex"}, "title = ?", "mine")
+	assert.True(t, errors.Is(err, repository.ErrCrossTenant))
+
+	matched, err := repo.UpdateIf(acme, theirs.ID, nil, map[string]interface{}{"title": "stolen"})
+	require.NoError(t, err)
+	assert.False(t, matched)
+
+	// Writes by conditions only reach the tenant's rows
+	updated, err := repo.UpdateAllByConditions(acme, map[string]interface{}{"title": "renamed"}, "title <> ?", "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), updated)
+
+	deleted, err := repo.DeleteAllByConditions(acme, "title <> ?", "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	var remaining tenantNote
+	require.NoError(t, db.Unscoped().First(&remaining, "id = ?", theirs.ID).Error)
+	assert.Equal(t, "theirs", remaining.Title)
+	assert.Nil(t, remaining.DeletedAt)
+
+	// The tenant still writes its own entities
+	own := &tenantNote{Title: "own"}
+	require.NoError(t, repo.Create(acme, own))
+	own.Title = "updated"
+	require.NoError(t, repo.Update(acme, own))
+	require.NoError(t, repo.DeleteByID(acme, own.ID))
+}
+
+func TestTenantScopedRepository_Strictness(t *testing.T) {
+	strict, _ := setupTenantRepository(t, nil)
+	_, err := strict.CountByConditions(context.Background())
+	assert.True(t, errors.Is(err, repository.ErrTenantRequired))
+	assert.True(t, errors.Is(strict.Create(context.Background(), &tenantNote{Title: "orphan"}), repository.ErrTenantRequired))
+
+	// Without strict mode operations without tenant run unscoped
+	lenient, _ := setupTenantRepository(t, &repository.TenancyConfig{Column: "tenant_id", ContextKey: "org", Strict: false})
+	org := context.WithValue(context.Background(), "org", "acme")
+	require.NoError(t, lenient.Create(org, &tenantNote{Title: "scoped"}))
+	require.NoError(t, lenient.Create(context.Background(), &tenantNote{TenantID: "globex", Title: "unscoped"}))
+
+	count, err := lenient.CountByConditions(org)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	count, err = lenient.CountByConditions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// Entities without a tenant column cannot be scoped
+	_, err = repository.NewTenantScopedRepository(repository.NewBaseRepository[TestEntity](setupTestDB(t), nil, nil), nil)
+	assert.Error(t, err)
+}
+
+func TestTenantScopedRepository_Transactions(t *testing.T) {
+	repo, _ := setupTenantRepository(t, nil)
+	acme := repository.WithTenant(context.Background(), "acme")
+	globex := repository.WithTenant(context.Background(), "globex")
+
+	theirs := &tenantNote{Title: "theirs"}
+	require.NoError(t, repo.Create(globex, theirs))
+
+	err := repo.WithTransaction(acme, func(tx repository.Repository[tenantNote]) error {
+		if err := tx.Create(acme, &tenantNote{Title: "in tx"}); err != nil {
+			return err
+		}
+		count, err := tx.CountByConditions(acme)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		return tx.DeleteByID(acme, theirs.ID)
+	})
+	assert.True(t, errors.Is(err, repository.ErrCrossTenant))
+
+	// The transaction rolled back
+	count, err := repo.CountByConditions(acme)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
@@ -0,0 +1,291 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seasbee/go-ormx/pkg/config"
+	"github.com/seasbee/go-ormx/pkg/database"
+	"github.com/seasbee/go-ormx/pkg/dialect"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/observability"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// slowCount counts long enough for any test deadline to expire
+const slowCount = "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 1000000000) SELECT count(*) FROM c"
+
+// setupTimeoutObserver returns a test database observed by a new manager
+func setupTimeoutObserver(t *testing.T) (*gorm.DB, *observability.ObservabilityManager) {
+	db := setupTestDB(t)
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	manager := observability.NewObservabilityManager(observability.DefaultObservabilityConfig(), logger)
+	require.NoError(t, db.Use(database.NewTimeoutObserver(manager)))
+	return db, manager
+}
+
+// timeoutSeries returns the value of the metric series matching labels
+func timeoutSeries(t *testing.T, manager *observability.ObservabilityManager, name string, labels map[string]string) float64 {
+	metrics, err := manager.GetMetrics().Collect(context.Background())
+	require.NoError(t, err)
+	for _, metric := range metrics {
+		if metric.Name != name {
+			continue
+		}
+		matches := true
+		for key, value := range labels {
+			if metric.Labels[key] != value {
+				matches = false
+			}
+		}
+		if matches {
+			return metric.Value
+		}
+	}
+	return 0
+}
+
+func TestTimeoutObserver_AcquireStage(t *testing.T) {
+	db, manager := setupTimeoutObserver(t)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	held, err := sqlDB.Conn(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	var entities []TestEntity
+	err = db.WithContext(ctx).Find(&entities).Error
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// The BEGIN of the default transaction waits for a connection as well
+	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	require.Error(t, db.WithContext(ctx).Create(&TestEntity{Name: "Alice", Age: 30}).Error)
+
+	require.NoError(t, held.Close())
+
+	assert.Equal(t, 1.0, timeoutSeries(t, manager, "orm_query_timeouts_total",
+		map[string]string{"operation": "query", "stage": "acquire", "cause": "timeout"}))
+	assert.Equal(t, 1.0, timeoutSeries(t, manager, "orm_query_timeouts_total",
+		map[string]string{"operation": "create", "stage": "acquire", "cause": "timeout"}))
+	assert.GreaterOrEqual(t, timeoutSeries(t, manager, "orm_query_timeout_budget_consumed_ratio",
+		map[string]string{"operation": "query", "stage": "acquire"}), 0.9)
+
+	// Connections are released and the pool restored once the wait is over
+	require.NoError(t, db.WithContext(context.Background()).Create(&TestEntity{Name: "Bob", Age: 40}).Error)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, db.WithContext(ctx).Find(&entities).Error)
+	assert.Len(t, entities, 1)
+}
+
+func TestTimeoutObserver_ExecuteStage(t *testing.T) {
+	db, manager := setupTimeoutObserver(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.Error(t, db.WithContext(ctx).Exec(slowCount).Error)
+
+	assert.Equal(t, 1.0, timeoutSeries(t, manager, "orm_query_timeouts_total",
+		map[string]string{"operation": "raw", "stage": "execute", "cause": "timeout"}))
+	// SQLite interrupts the statement in process
+	assert.Equal(t, 1.0, timeoutSeries(t, manager, "orm_query_timeout_server_cancels_total",
+		map[string]string{"operation": "raw", "delivered": "true"}))
+
+	spans := manager.GetTracer().FinishedSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "execute", spans[0].Attributes["interruption.stage"])
+	assert.Equal(t, "timeout", spans[0].Attributes["interruption.cause"])
+	assert.Equal(t, observability.SpanStatusError, spans[0].Status)
+	require.Len(t, spans[0].Events, 1)
+	assert.Equal(t, "query_interrupted", spans[0].Events[0].Name)
+	assert.Contains(t, spans[0].Events[0].Attributes, "budget_consumed")
+}
+
+func TestTimeoutObserver_ScanStage(t *testing.T) {
+	db, manager := setupTimeoutObserver(t)
+
+	// SQLite computes result rows while they are read
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var counts []int64
+	require.Error(t, db.WithContext(ctx).Raw(slowCount).Find(&counts).Error)
+
+	assert.Equal(t, 1.0, timeoutSeries(t, manager, "orm_query_timeouts_total",
+		map[string]string{"operation": "query", "stage": "scan", "cause": "timeout"}))
+}
+
+func TestTimeoutObserver_Cancellation(t *testing.T) {
+	db, manager := setupTimeoutObserver(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	timer := time.AfterFunc(30*time.Millisecond, cancel)
+	defer timer.Stop()
+	require.Error(t, db.WithContext(ctx).Exec(slowCount).Error)
+
+	assert.Equal(t, 1.0, timeoutSeries(t, manager, "orm_query_timeouts_total",
+		map[string]string{"operation": "raw", "stage": "execute", "cause": "canceled"}))
+	// Without a deadline there is no budget to consume
+	assert.Equal(t, 0.0, timeoutSeries(t, manager, "orm_query_timeout_budget_consumed_ratio",
+		map[string]string{"cause": "canceled"}))
+}
+
+func TestTimeoutObserver_IgnoresCompletedStatements(t *testing.T) {
+	db, manager := setupTimeoutObserver(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	entity := &TestEntity{Name: "Alice", Age: 30}
+	require.NoError(t, db.WithContext(ctx).Create(entity).Error)
+	require.NoError(t, db.WithContext(ctx).Model(entity).Update("age", 31).Error)
+
+	var found TestEntity
+	require.NoError(t, db.WithContext(ctx).First(&found, "id = ?", entity.ID).Error)
+	assert.Equal(t, 31, found.Age)
+	require.NoError(t, db.WithContext(ctx).Delete(&found).Error)
+
+	// Errors not caused by the context are not interruptions
+	assert.Error(t, db.WithContext(ctx).Exec("SELECT * FROM missing_table").Error)
+
+	assert.Equal(t, 0.0, timeoutSeries(t, manager, "orm_query_timeouts_total", nil))
+	assert.Empty(t, manager.GetTracer().FinishedSpans())
+}
+
+func TestQueryInterruption_BudgetConsumed(t *testing.T) {
+	qi := observability.QueryInterruption{Budget: 200 * time.Millisecond, Elapsed: 50 * time.Millisecond}
+	assert.InDelta(t, 0.25, qi.BudgetConsumed(), 0.0001)
+
+	qi.Budget = 0
+	assert.Equal(t, 0.0, qi.BudgetConsumed())
+}
+
+func TestDialect_ServerCancelled(t *testing.T) {
+	tests := []struct {
+		dialect   string
+		err       error
+		cancelled bool
+	}{
+		{"postgres", errors.New("ERROR: canceling statement due to user request (SQLSTATE 57014)"), true},
+		{"postgres", errors.New("timeout: context deadline exceeded"), false},
+		{"cockroachdb", errors.New("ERROR: query execution canceled (SQLSTATE 57014)"), true},
+		{"mysql", errors.New("Error 3024 (HY000): Query execution was interrupted, maximum statement execution time exceeded"), true},
+		{"mysql", context.DeadlineExceeded, false},
+		{"sqlite", errors.New("interrupted"), true},
+		{"oracle", errors.New("ORA-01013: user requested cancel of current operation"), true},
+	}
+
+	for _, tt := range tests {
+		d, ok := dialect.Get(tt.dialect)
+		require.True(t, ok, tt.dialect)
+		detector, ok := d.(dialect.CancelDetector)
+		require.True(t, ok, tt.dialect)
+		assert.Equal(t, tt.cancelled, detector.ServerCancelled(tt.err), "%s: %v", tt.dialect, tt.err)
+	}
+
+	var sqlServer dialect.Dialect = dialect.SQLServer{}
+	_, ok := sqlServer.(dialect.CancelDetector)
+	assert.False(t, ok)
+}
+
+func TestBaseRepository_QueryTimeout(t *testing.T) {
+	db := setupTestDB(t)
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	config := repository.DefaultRepositoryConfig()
+	config.QueryTimeout = 50 * time.Millisecond
+	repo := repository.NewBaseRepository[TestEntity](db, logger, config)
+	ctx := context.Background()
+
+	start := time.Now()
+	_, err := repo.Exec(ctx, slowCount, nil)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// Statements within the timeout run, including the default transaction of creates
+	require.NoError(t, repo.Create(ctx, &TestEntity{Name: "Alice", Age: 30}))
+	_, err = repo.CountByConditions(ctx, repository.Expr("age < ("+slowCount+")"))
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+
+	count, err := repo.CountByConditions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// Statements of other repositories of the database are not bounded
+	unbounded := repository.NewBaseRepository[TestEntity](db, logger, nil)
+	shortCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = unbounded.Exec(shortCtx, slowCount, nil)
+	require.Error(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestBaseRepository_TransactionTimeout(t *testing.T) {
+	// The connection of the expired transaction is discarded, which would
+	// lose an in-memory database
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "tx.db")), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&TestEntity{}))
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	config := repository.DefaultRepositoryConfig()
+	config.TransactionTimeout = 100 * time.Millisecond
+	repo := repository.NewBaseRepository[TestEntity](db, logger, config)
+	ctx := context.Background()
+
+	err = repo.WithTransactionOpts(ctx, repository.TxOptions{}, func(ctx context.Context, tx repository.Repository[TestEntity]) error {
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		assert.LessOrEqual(t, time.Until(deadline), 100*time.Millisecond)
+		require.NoError(t, tx.Create(ctx, &TestEntity{Name: "Alice", Age: 30}))
+
+		// A nested transaction shares the bound of the outer one
+		require.NoError(t, repo.WithTransactionOpts(ctx, repository.TxOptions{Propagation: repository.PropagationNested}, func(nested context.Context, _ repository.Repository[TestEntity]) error {
+			nestedDeadline, ok := nested.Deadline()
+			assert.True(t, ok)
+			assert.Equal(t, deadline, nestedDeadline)
+			return nil
+		}))
+
+		<-ctx.Done()
+		return nil
+	})
+	require.Error(t, err)
+	assert.Equal(t, int64(0), countRows(t, db, &TestEntity{}))
+
+	require.NoError(t, repo.WithTransaction(ctx, func(tx repository.Repository[TestEntity]) error {
+		return tx.Create(ctx, &TestEntity{Name: "Bob", Age: 40})
+	}))
+	assert.Equal(t, int64(1), countRows(t, db, &TestEntity{}))
+}
+
+func TestDatabaseConfig_StatementTimeout(t *testing.T) {
+	cfg := &config.DatabaseConfig{
+		Driver:           "postgres",
+		Host:             "localhost",
+		Port:             5432,
+		Username:         "user",
+		Password:         "pass",
+		Database:         "testdb",
+		SSLMode:          "disable",
+		StatementTimeout: 1500 * time.Millisecond,
+		ReadReplicas:     []config.ReadReplicaConfig{{Host: "replica", Port: 5432, Enabled: true}},
+	}
+	assert.Equal(t, "host=localhost port=5432 user=user password=pass dbname=testdb sslmode=disable statement_timeout=1500", cfg.ConnectionString())
+	assert.Contains(t, cfg.ReadReplicaDSNs()[0], " statement_timeout=1500")
+	assert.Contains(t, cfg.ReadReplicaConnectionStrings()[0], " statement_timeout=1500")
+
+	cfg.Driver = "mysql"
+	assert.NotContains(t, cfg.ConnectionString(), "statement_timeout")
+}
@@ -0,0 +1,103 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaseRepository_TransactionHooks(t *testing.T) {
+	repo, _ := setupTestRepository(t)
+	ctx := context.Background()
+
+	var events []string
+	record := func(event string) repository.TxHook {
+		return func(ctx context.Context) { events = append(events, event) }
+	}
+
+	t.Run("After commit", func(t *testing.T) {
+		events = nil
+		err := repo.WithTransaction(ctx, func(txRepo repository.Repository[TestEntity]) error {
+			txRepo.AfterCommit(ctx, record("published"))
+			txRepo.AfterRollback(ctx, record("compensated"))
+			require.NoError(t, txRepo.Create(ctx, &TestEntity{Name: "committed", Age: 30}))
+
+			// Nothing runs before the commit
+			assert.Empty(t, events)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"published"}, events)
+	})
+
+	t.Run("After rollback", func(t *testing.T) {
+		events = nil
+		err := repo.WithTransaction(ctx, func(txRepo repository.Repository[TestEntity]) error {
+			txRepo.AfterCommit(ctx, record("published"))
+			txRepo.AfterRollback(ctx, record("compensated"))
+			return assert.AnError
+		})
+		assert.Error(t, err)
+		assert.Equal(t, []string{"compensated"}, events)
+	})
+
+	t.Run("Panic rolls back", func(t *testing.T) {
+		events = nil
+		err := repo.WithTransaction(ctx, func(txRepo repository.Repository[TestEntity]) error {
+			txRepo.AfterCommit(ctx, record("published"))
+			txRepo.AfterRollback(ctx, record("compensated"))
+			require.NoError(t, txRepo.Create(ctx, &TestEntity{Name: "panicked", Age: 30}))
+			panic("boom")
+		})
+		assert.ErrorContains(t, err, "panicked")
+		assert.Equal(t, []string{"compensated"}, events)
+
+		exists, err := repo.ExistsByConditions(ctx, "name = ?", "panicked")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("Nested transactions", func(t *testing.T) {
+		events = nil
+		err := repo.WithTransaction(ctx, func(txRepo repository.Repository[TestEntity]) error {
+			require.NoError(t, txRepo.WithTransaction(ctx, func(inner repository.Repository[TestEntity]) error {
+				inner.AfterCommit(ctx, record("inner committed"))
+				return nil
+			}))
+			assert.Empty(t, events, "nested commit hooks wait for the outer commit")
+
+			assert.Error(t, txRepo.WithTransaction(ctx, func(inner repository.Repository[TestEntity]) error {
+				inner.AfterCommit(ctx, record("discarded"))
+				inner.AfterRollback(ctx, record("savepoint rolled back"))
+				return assert.AnError
+			}))
+			assert.Equal(t, []string{"savepoint rolled back"}, events)
+
+			txRepo.AfterCommit(ctx, record("outer committed"))
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"savepoint rolled back", "inner committed", "outer committed"}, events)
+	})
+
+	t.Run("Panicking hook", func(t *testing.T) {
+		events = nil
+		err := repo.WithTransaction(ctx, func(txRepo repository.Repository[TestEntity]) error {
+			txRepo.AfterCommit(ctx, func(ctx context.Context) { panic("hook failed") })
+			txRepo.AfterCommit(ctx, record("published"))
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"published"}, events)
+	})
+
+	t.Run("Outside a transaction", func(t *testing.T) {
+		events = nil
+		repo.AfterCommit(ctx, record("immediate"))
+		repo.AfterRollback(ctx, record("never"))
+		assert.Equal(t, []string{"immediate"}, events)
+	})
+}
@@ -0,0 +1,91 @@
+package unit
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnitOfWork_Atomic(t *testing.T) {
+	entities, events, db := setupPropagation(t)
+	ctx := context.Background()
+	uow := repository.NewUnitOfWork(db, logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{}))
+
+	var order []string
+	err := uow.Do(ctx, func(ctx context.Context) error {
+		_, ok := repository.TxFromContext(ctx)
+		require.True(t, ok)
+
+		users := entities.WithTx(ctx)
+		require.NoError(t, users.Create(ctx, &TestEntity{Name: "Alice", Age: 30}))
+		users.AfterCommit(ctx, func(context.Context) { order = append(order, "committed") })
+
+		log := events.WithTx(ctx)
+		require.NoError(t, log.Create(ctx, &propagationEvent{Kind: "signup"}))
+
+		// Reads inside the unit see its uncommitted writes
+		count, err := users.CountByConditions(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), countRows(t, db, &TestEntity{}))
+	assert.Equal(t, int64(1), countRows(t, db, &propagationEvent{}))
+	assert.Equal(t, []string{"committed"}, order)
+
+	failure := stderrors.New("order rejected")
+	err = uow.Do(ctx, func(ctx context.Context) error {
+		require.NoError(t, entities.WithTx(ctx).Create(ctx, &TestEntity{Name: "Bob", Age: 40}))
+		events.WithTx(ctx).AfterRollback(ctx, func(context.Context) { order = append(order, "rolled back") })
+		require.NoError(t, events.WithTx(ctx).Create(ctx, &propagationEvent{Kind: "order"}))
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, int64(1), countRows(t, db, &TestEntity{}), "both repositories roll back")
+	assert.Equal(t, int64(1), countRows(t, db, &propagationEvent{}))
+	assert.Equal(t, []string{"committed", "rolled back"}, order)
+
+	err = uow.Do(ctx, func(ctx context.Context) error {
+		require.NoError(t, entities.WithTx(ctx).Create(ctx, &TestEntity{Name: "Carol", Age: 50}))
+		panic("boom")
+	})
+	assert.ErrorContains(t, err, "panicked")
+	assert.Equal(t, int64(1), countRows(t, db, &TestEntity{}))
+
+	// Without a transaction in ctx repositories are returned unchanged
+	assert.Same(t, entities, entities.WithTx(ctx))
+}
+
+func TestUnitOfWork_Joins(t *testing.T) {
+	entities, events, db := setupPropagation(t)
+	ctx := context.Background()
+	uow := repository.NewUnitOfWork(db, nil)
+
+	// A unit of work inside a transaction joins it
+	err := entities.WithTransactionOpts(ctx, repository.TxOptions{}, func(ctx context.Context, tx repository.Repository[TestEntity]) error {
+		require.NoError(t, tx.Create(ctx, &TestEntity{Name: "Alice", Age: 30}))
+		require.NoError(t, uow.Do(ctx, func(ctx context.Context) error {
+			return events.WithTx(ctx).Create(ctx, &propagationEvent{Kind: "joined"})
+		}))
+		return stderrors.New("abort")
+	})
+	assert.Error(t, err)
+	assert.Zero(t, countRows(t, db, &propagationEvent{}))
+
+	// A transaction begun by the caller is shared through the context
+	tx := db.Begin()
+	txCtx := repository.ContextWithTx(ctx, tx)
+	require.NoError(t, entities.WithTx(txCtx).Create(txCtx, &TestEntity{Name: "Bob", Age: 40}))
+	require.NoError(t, events.WithTransactionOpts(txCtx, repository.TxOptions{}, func(ctx context.Context, etx repository.Repository[propagationEvent]) error {
+		return etx.Create(ctx, &propagationEvent{Kind: "external"})
+	}))
+	require.NoError(t, tx.Rollback().Error)
+	assert.Zero(t, countRows(t, db, &TestEntity{}))
+	assert.Zero(t, countRows(t, db, &propagationEvent{}))
+}
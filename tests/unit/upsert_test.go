@@ -0,0 +1,232 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/seasbee/go-ormx/pkg/errors"
+	"github.com/seasbee/go-ormx/pkg/logging"
+	"github.com/seasbee/go-ormx/pkg/models"
+	"github.com/seasbee/go-ormx/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upsertProduct is an entity with a unique key besides its ID
+type upsertProduct struct {
+	models.BaseModel
+	SKU     string `gorm:"uniqueIndex;not null"`
+	Name    string `gorm:"not null"`
+	Stock   int    `gorm:"not null"`
+	Version int    `gorm:"not null"`
+}
+
+// setupUpsertRepository creates a repository of products
+func setupUpsertRepository(t *testing.T) *repository.BaseRepository[upsertProduct] {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&upsertProduct{}))
+	logger := logging.NewLogger(logging.LogLevelInfo, nil, &logging.TextFormatter{})
+	return repository.NewBaseRepository[upsertProduct](db, logger, repository.DefaultRepositoryConfig())
+}
+
+func TestBaseRepository_UpsertConflictColumns(t *testing.T) {
+	repo := setupUpsertRepository(t)
+	ctx := context.Background()
+
+	original := &upsertProduct{SKU: "A-1", Name: "Anvil", Stock: 5, Version: 1}
+	require.NoError(t, repo.Create(ctx, original))
+
+	// Conflicting on the unique key merges into the existing row
+	incoming := &upsertProduct{SKU: "A-1", Name: "Heavy anvil", Stock: 7, Version: 2}
+	require.NoError(t, repo.Upsert(ctx, incoming, repository.OnConflict("sku")))
+	assert.Equal(t, original.ID, incoming.ID)
+
+	stored, err := repo.FindFirstByID(ctx, original.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Heavy anvil", stored.Name)
+	assert.Equal(t, 7, stored.Stock)
+	count, err := repo.CountByConditions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// Only the listed columns are overwritten
+	partial := &upsertProduct{SKU: "A-1", Name: "Ignored", Stock: 9, Version: 3}
+	require.NoError(t, repo.Upsert(ctx, partial, repository.ConflictOptions{Columns: []string{"sku"}, DoUpdates: []string{"stock"}}))
+	stored, err = repo.FindFirstByID(ctx, original.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Heavy anvil", stored.Name)
+	assert.Equal(t, 9, stored.Stock)
+	assert.Equal(t, 2, stored.Version)
+
+	// Doing nothing keeps the existing row
+	require.NoError(t, repo.Upsert(ctx, &upsertProduct{SKU: "A-1", Name: "Ignored", Stock: 0}, repository.OnConflictDoNothing("sku")))
+	stored, err = repo.FindFirstByID(ctx, original.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 9, stored.Stock)
+
+	// New keys are inserted
+	fresh := &upsertProduct{SKU: "B-2", Name: "Bucket", Stock: 1, Version: 1}
+	require.NoError(t, repo.Upsert(ctx, fresh, repository.OnConflict("sku")))
+	assert.NotEqual(t, original.ID, fresh.ID)
+	count, err = repo.CountByConditions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestBaseRepository_UpsertConditional(t *testing.T) {
+	repo := setupUpsertRepository(t)
+	ctx := context.Background()
+
+	product := &upsertProduct{SKU: "A-1", Name: "Anvil", Stock: 5, Version: 2}
+	require.NoError(t, repo.Create(ctx, product))
+
+	// Stale versions do not overwrite newer rows
+	newer := repository.ConflictOptions{Columns: []string{"sku"}, Where: "upsert_products.version < excluded.version"}
+	require.NoError(t, repo.Upsert(ctx, &upsertProduct{SKU: "A-1", Name: "Stale", Stock: 1, Version: 1}, newer))
+	stored, err := repo.FindFirstByID(ctx, product.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Anvil", stored.Name)
+
+	require.NoError(t, repo.Upsert(ctx, &upsertProduct{SKU: "A-1", Name: "Fresh", Stock: 1, Version: 3}, newer))
+	stored, err = repo.FindFirstByID(ctx, product.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Fresh", stored.Name)
+
+	// Conditions restrict the existing rows that may be updated
+	require.NoError(t, repo.UpsertByConditions(ctx, &upsertProduct{SKU: "A-1", Name: "Restocked", Stock: 10, Version: 4},
+		repository.OnConflict("sku"), "stock > ?", 100))
+	stored, err = repo.FindFirstByID(ctx, product.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Fresh", stored.Name)
+
+	require.NoError(t, repo.UpsertByConditions(ctx, &upsertProduct{SKU: "A-1", Name: "Restocked", Stock: 10, Version: 4},
+		repository.OnConflict("sku"), "stock < ?", 100))
+	stored, err = repo.FindFirstByID(ctx, product.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Restocked", stored.Name)
+}
+
+func TestBaseRepository_UpsertByIDAndBatches(t *testing.T) {
+	repo := setupUpsertRepository(t)
+	ctx := context.Background()
+
+	id := uuid.New()
+	require.NoError(t, repo.UpsertByID(ctx, &upsertProduct{SKU: "A-1", Name: "Anvil", Stock: 5}, id, repository.ConflictOptions{}))
+	require.NoError(t, repo.UpsertByID(ctx, &upsertProduct{SKU: "A-1", Name: "Anvil", Stock: 6}, id, repository.ConflictOptions{}))
+	stored, err := repo.FindFirstByID(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, 6, stored.Stock)
+
+	mismatched := &upsertProduct{SKU: "A-1"}
+	mismatched.ID = uuid.New()
+	assert.Error(t, repo.UpsertByID(ctx, mismatched, id, repository.ConflictOptions{}))
+	assert.Error(t, repo.UpsertByID(ctx, &upsertProduct{SKU: "A-1"}, uuid.Nil, repository.ConflictOptions{}))
+
+	products := []upsertProduct{
+		{SKU: "A-1", Name: "Anvil", Stock: 8},
+		{SKU: "B-2", Name: "Bucket", Stock: 2},
+		{SKU: "C-3", Name: "Crate", Stock: 3},
+	}
+	require.NoError(t, repo.UpsertInBatches(ctx, products, 2, repository.ConflictOptions{Columns: []string{"sku"}, DoUpdates: []string{"stock"}}))
+	count, err := repo.CountByConditions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	stored, err = repo.FindFirstByID(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, 8, stored.Stock)
+
+	// Batches only update the existing rows matching the conditions
+	products = []upsertProduct{{SKU: "B-2", Name: "Bucket", Stock: 20}, {SKU: "C-3", Name: "Crate", Stock: 30}}
+	require.NoError(t, repo.UpsertInBatchesByConditions(ctx, products, 10,
+		repository.ConflictOptions{Columns: []string{"sku"}, DoUpdates: []string{"stock"}}, "sku = ?", "C-3"))
+	var all []upsertProduct
+	require.NoError(t, repo.FindAllByConditionsWithOffset(ctx, 10, 0, &all, "stock >= ?", 20))
+	require.Len(t, all, 1)
+	assert.Equal(t, "C-3", all[0].SKU)
+}
+
+func TestTenantScopedRepository_UpsertConflictColumns(t *testing.T) {
+	repo, db := setupTenantRepository(t, nil)
+	require.NoError(t, db.Exec("CREATE UNIQUE INDEX idx_tenant_notes_title ON tenant_notes (title)").Error)
+	acme := repository.WithTenant(context.Background(), "acme")
+	globex := repository.WithTenant(context.Background(), "globex")
+
+	note := &tenantNote{Title: "shared"}
+	require.NoError(t, repo.Create(acme, note))
+
+	// A conflict with another tenant's row does not overwrite it
+	intruder := &tenantNote{Title: "shared"}
+	assert.ErrorIs(t, repo.Upsert(globex, intruder, repository.OnConflict("title")), repository.ErrCrossTenant)
+	assert.NotEqual(t, note.ID, intruder.ID)
+	var stored tenantNote
+	require.NoError(t, db.First(&stored, "id = ?", note.ID).Error)
+	assert.Equal(t, "acme", stored.TenantID)
+
+	again := &tenantNote{Title: "shared"}
+	require.NoError(t, repo.Upsert(acme, again, repository.OnConflict("title")))
+	assert.Equal(t, note.ID, again.ID)
+	count, err := repo.CountByConditions(acme)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestUpsertInBatches_PartialFailure(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&upsertProduct{}))
+	logger := logging.NewLogger(logging.LogLevelError, nil, &logging.TextFormatter{})
+	repos := map[string]repository.Repository[upsertProduct]{
+		"base":   repository.NewBaseRepository[upsertProduct](db, logger, repository.DefaultRepositoryConfig()),
+		"memory": repository.NewMemoryRepository[upsertProduct](repository.DefaultRepositoryConfig()),
+	}
+	ctx := context.Background()
+
+	for kind, repo := range repos {
+		t.Run(kind, func(t *testing.T) {
+			existing := &upsertProduct{SKU: kind + "-0", Name: "Existing"}
+			require.NoError(t, repo.Create(ctx, existing))
+
+			// The second batch reuses the ID of the existing row under
+			// another SKU, conflicting on the primary key
+			batches := func() []upsertProduct {
+				products := make([]upsertProduct, 6)
+				for i := range products {
+					products[i] = upsertProduct{SKU: fmt.Sprintf("%s-%d", kind, i+1), Name: "Product", Stock: i}
+				}
+				products[2].ID = existing.ID
+				return products
+			}
+			conflict := repository.OnConflict("sku")
+
+			err := repo.UpsertInBatches(ctx, batches(), 2, conflict)
+			require.Error(t, err)
+			batchErr, ok := errors.AsBatchError(err)
+			require.True(t, ok)
+			assert.Equal(t, 6, batchErr.Total)
+			assert.Equal(t, 2, batchErr.Succeeded)
+			assert.Equal(t, 4, batchErr.Failed())
+			failures := batchErr.Failures()
+			require.Len(t, failures, 1)
+			assert.Equal(t, 1, failures[0].Batch)
+			assert.Equal(t, 2, failures[0].Offset)
+			assert.Equal(t, 2, failures[0].Size)
+			assert.True(t, errors.IsType(err, errors.ErrorTypeDuplicate) || errors.IsType(err, errors.ErrorTypeConstraint), "%v", err)
+
+			count, err := repo.CountByConditions(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), count)
+
+			// The batches following the failing one are upserted as well
+			conflict.ContinueOnError = true
+			err = repo.UpsertInBatches(ctx, batches(), 2, conflict)
+			batchErr, ok = errors.AsBatchError(err)
+			require.True(t, ok)
+			assert.Equal(t, 4, batchErr.Succeeded)
+			require.Len(t, batchErr.Failures(), 1)
+			count, err = repo.CountByConditions(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, int64(5), count)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package unit
 
 import (
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -10,6 +11,10 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
 	"github.com/seasbee/go-ormx/pkg/utils"
 )
 
@@ -728,3 +733,100 @@ func TestValidateUUIDv7_StressTest(t *testing.T) {
 	// Average time should be reasonable
 	assert.Less(t, avgTime, time.Millisecond, "Average UUID processing time too high: %v", avgTime)
 }
+
+func TestUUIDv7FromV4(t *testing.T) {
+	old := uuid.New()
+	created := time.Date(2024, 3, 15, 10, 7, 30, 0, time.UTC)
+
+	id := utils.UUIDv7FromV4(old, created)
+	assert.True(t, utils.IsUUIDv7(id))
+	assert.Equal(t, uuid.RFC4122, id.Variant())
+	parsed, err := utils.ParseUUIDv7Time(id)
+	require.NoError(t, err)
+	assert.True(t, created.Equal(parsed))
+
+	assert.Equal(t, id, utils.UUIDv7FromV4(old, created), "conversion is deterministic")
+	assert.Equal(t, old[9:], id[9:], "random bits are kept")
+	assert.NotEqual(t, id, utils.UUIDv7FromV4(uuid.New(), created))
+
+	// Keys of rows created later sort after
+	later := utils.UUIDv7FromV4(uuid.New(), created.Add(time.Millisecond))
+	assert.Less(t, id.String(), later.String())
+}
+
+// keyedRow is a row keyed by a UUID, to compare the index locality of key versions
+type keyedRow struct {
+	ID      uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Payload string    `gorm:"size:64"`
+}
+
+// setupKeyedTable creates a table of 20000 keyed rows in a WAL database not
+// checkpointing on its own, so the WAL counts the pages written since
+func setupKeyedTable(tb testing.TB, key func() uuid.UUID) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(tb.TempDir(), "keys.db")), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(tb, err)
+	sqlDB, err := db.DB()
+	require.NoError(tb, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(tb, db.Exec("PRAGMA journal_mode = WAL").Error)
+	require.NoError(tb, db.Exec("PRAGMA synchronous = OFF").Error)
+	require.NoError(tb, db.Exec("PRAGMA wal_autocheckpoint = 0").Error)
+	require.NoError(tb, db.AutoMigrate(&keyedRow{}))
+
+	for i := 0; i < 40; i++ {
+		insertKeyed(tb, db, 500, key)
+	}
+	require.NoError(tb, db.Exec("PRAGMA wal_checkpoint(TRUNCATE)").Error)
+	return db
+}
+
+func insertKeyed(tb testing.TB, db *gorm.DB, n int, key func() uuid.UUID) {
+	rows := make([]keyedRow, n)
+	for i := range rows {
+		rows[i] = keyedRow{ID: key(), Payload: "payload"}
+	}
+	require.NoError(tb, db.Create(rows).Error)
+}
+
+// pagesWritten returns the pages written to the WAL since the last checkpoint
+func pagesWritten(tb testing.TB, db *gorm.DB) int64 {
+	var busy, log, checkpointed int64
+	require.NoError(tb, db.Raw("PRAGMA wal_checkpoint(PASSIVE)").Row().Scan(&busy, &log, &checkpointed))
+	return log
+}
+
+func TestUUIDv7_IndexLocality(t *testing.T) {
+	// Random keys land on any page of the primary key index, so each batch
+	// rewrites pages all over it, while ordered keys fill its rightmost pages
+	written := func(key func() uuid.UUID) int64 {
+		db := setupKeyedTable(t, key)
+		for i := 0; i < 10; i++ {
+			insertKeyed(t, db, 100, key)
+		}
+		return pagesWritten(t, db)
+	}
+
+	v4, v7 := written(uuid.New), written(utils.GenerateUUIDv7)
+	assert.Less(t, v7*4, v4, "v4 wrote %d pages, v7 %d", v4, v7)
+}
+
+func benchmarkKeyedInsert(b *testing.B, key func() uuid.UUID) {
+	db := setupKeyedTable(b, key)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		insertKeyed(b, db, 100, key)
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(pagesWritten(b, db))*10/float64(b.N), "pages/1k-rows")
+}
+
+// BenchmarkInsert_UUIDv4 inserts batches of 100 rows keyed by random UUIDs
+func BenchmarkInsert_UUIDv4(b *testing.B) {
+	benchmarkKeyedInsert(b, uuid.New)
+}
+
+// BenchmarkInsert_UUIDv7 inserts batches of 100 rows keyed by ordered UUIDs
+func BenchmarkInsert_UUIDv7(b *testing.B) {
+	benchmarkKeyedInsert(b, utils.GenerateUUIDv7)
+}